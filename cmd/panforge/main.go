@@ -2,14 +2,21 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"slices"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 
 	"github.com/rapjul/panforge/internal/app"
+	"github.com/rapjul/panforge/internal/cache"
+	"github.com/rapjul/panforge/internal/config"
 	"github.com/rapjul/panforge/internal/options"
 	"github.com/rapjul/panforge/internal/pandoc"
 	"github.com/rapjul/panforge/internal/utils"
@@ -46,22 +53,41 @@ To generate shell completion scripts, run:
 
   # Dry run to see the generated command
   panforge input.md --dry-run`,
-		SilenceUsage: true, // Don't show usage on runtime errors
+		SilenceUsage:  true, // Don't show usage on runtime errors
+		SilenceErrors: true, // Errors are printed by main, which picks the exit code
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := app.ApplyEnv(cmd); err != nil {
+				return &app.ExitError{Code: app.ExitConfig, Err: err}
+			}
+			if err := utils.SetColorMode(opts.Color); err != nil {
+				return &app.ExitError{Code: app.ExitConfig, Err: err}
+			}
+			if from, to, err := config.MigrateDataDir(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v; still using %s\n", err, config.DataDirName())
+			} else if from != "" && !opts.Quiet {
+				fmt.Fprintf(os.Stderr, "Moved the panforge data directory from %s to %s\n", from, to)
+			}
+			pandoc.SetBinary(app.PandocPath(opts))
+			return app.CheckDeprecatedFlags(cmd, opts, os.Stderr)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// Configure Logging
-			logLevel := slog.LevelInfo
-			if opts.Verbose {
-				logLevel = slog.LevelDebug
-			} else if opts.Quiet {
-				logLevel = slog.LevelError
+			logger, err := newLogger(opts)
+			if err != nil {
+				return &app.ExitError{Code: app.ExitConfig, Err: err}
 			}
-
-			handler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-				Level: logLevel,
-			})
-			logger := slog.New(handler)
 			opts.Logger = logger
 
+			if opts.Recipe != "" {
+				recipe, err := app.LoadRecipe(config.DataDirName(), opts.Recipe)
+				if err != nil {
+					return &app.ExitError{Code: app.ExitConfig, Err: err}
+				}
+				if args, err = app.ApplyRecipe(cmd, recipe, args); err != nil {
+					return &app.ExitError{Code: app.ExitConfig, Err: err}
+				}
+			}
+
 			executor := &app.RealExecutor{
 				DryRun:  opts.DryRun,
 				Verbose: opts.Verbose,
@@ -80,31 +106,37 @@ To generate shell completion scripts, run:
 	// Define flags
 	rootCmd.Flags().StringSliceVarP(&opts.Targets, "to", "t", []string{}, "Specify output format(s)")
 	rootCmd.Flags().BoolVarP(&opts.All, "all", "a", false, "Convert to all formats specified in the YAML header (default: false)")
-	rootCmd.Flags().StringVarP(&opts.Output, "output", "o", "", "Specify output filename (default: <filename>.<format>)")
-	rootCmd.Flags().BoolVarP(&opts.Force, "force", "f", false, "Overwrite existing output file(s) (default: false)")
-	rootCmd.Flags().BoolVarP(&opts.DryRun, "dry-run", "n", false, "Print the Pandoc command(s) without executing them (default: false)")
-	rootCmd.Flags().BoolVarP(&opts.Verbose, "verbose", "v", false, "Run Pandoc showing output (default: false)")
-	rootCmd.Flags().BoolVarP(&opts.Quiet, "quiet", "q", false, "Suppress program messages (default: false)")
-	rootCmd.Flags().StringVarP(&opts.Log, "log", "l", "", "Append program calls to FILE (default: none)")
-	rootCmd.Flags().IntVarP(&opts.Concurrency, "concurrency", "c", 0, "Limit number of concurrent pandoc processes (default: number of CPUs)")
-
+	rootCmd.Flags().StringVarP(&opts.Output, "output", "o", "", "Specify output filename, or - for stdout with a single target (default: <filename>.<format>)")
 	rootCmd.Flags().BoolVarP(&opts.Watch, "watch", "w", false, "Watch input file for changes and re-run (implies --force for overwriting existing output file(s))")
-
-	// Disable auto-sorting of flags to preserve order of post-args if mixed
-	rootCmd.Flags().SortFlags = false
+	rootCmd.PersistentFlags().StringVar(&opts.PandocPath, "pandoc-path", "", "Run this pandoc binary instead of the one on the PATH (default: env PANFORGE_PANDOC_PATH, else pandoc-path in the default config, else pandoc)")
+	rootCmd.PersistentFlags().StringVar(&opts.Config, "config", "", "Use this config file, or the config of this name in the data directory, instead of default.yaml")
+	rootCmd.PersistentFlags().StringVar(&opts.Color, "color", utils.ColorAuto, "Color output: auto (on a terminal, unless NO_COLOR is set), always, or never")
+	rootCmd.PersistentFlags().BoolVarP(&opts.Quiet, "quiet", "q", false, "Suppress program messages; dry-run commands and requested reports are still printed (default: false)")
+	addConvertFlags(rootCmd, &opts)
 
 	// Register completion for --watch/-w flag
 	_ = rootCmd.RegisterFlagCompletionFunc("watch", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		return []string{"true", "false"}, cobra.ShellCompDirectiveNoFileComp
 	})
 
+	_ = rootCmd.RegisterFlagCompletionFunc("color", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return utils.ColorModes, cobra.ShellCompDirectiveNoFileComp
+	})
+
 	// Register completion for --to/-t flag
 	_ = rootCmd.RegisterFlagCompletionFunc("to", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		formats, err := pandoc.GetSupportedFormats()
-		if err != nil {
-			return nil, cobra.ShellCompDirectiveError
+		input := ""
+		if len(args) > 0 {
+			input = args[0]
 		}
-		return formats, cobra.ShellCompDirectiveNoFileComp
+		targets := app.ConfiguredTargets(input, opts)
+		formats, _ := pandoc.OutputFormats()
+		for _, format := range formats {
+			if !slices.Contains(targets, format) {
+				targets = append(targets, format)
+			}
+		}
+		return targets, cobra.ShellCompDirectiveNoFileComp
 	})
 
 	// Init Command
@@ -114,6 +146,7 @@ To generate shell completion scripts, run:
 		Short: "Initialize a new project or file",
 		Long:  `Generate a default configuration file or a scaffolded Markdown file.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			initOpts.Quiet = opts.Quiet
 			return app.RunInit(initOpts)
 		},
 	}
@@ -134,11 +167,20 @@ To generate shell completion scripts, run:
 If a file is provided, it checks only for the tools required by that file's configuration.
 If no file is provided, it checks for all known tools.`,
 		Run: func(cmd *cobra.Command, args []string) {
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
-			_, _ = fmt.Fprintln(w, "Tool\tStatus\tVersion/Path")
-			_, _ = fmt.Fprintln(w, "----\t------\t------------")
+			var table bytes.Buffer
+			w := tabwriter.NewWriter(&table, 0, 0, 3, ' ', 0)
+			headerDone := false
 
 			check := func(res utils.CheckResult) {
+				// --quiet reports only the missing tools
+				if opts.Quiet && res.Found {
+					return
+				}
+				if !headerDone {
+					_, _ = fmt.Fprintln(w, "Tool\tStatus\tVersion/Path")
+					_, _ = fmt.Fprintln(w, "----\t------\t------------")
+					headerDone = true
+				}
 				status := "FOUND"
 				if !res.Found {
 					status = "MISSING"
@@ -166,23 +208,11 @@ If no file is provided, it checks for all known tools.`,
 				var err error
 				toolsToCheck, err = app.GetRequiredTools(inputFile, opts)
 				if err != nil {
-					fmt.Fprintf(os.Stderr, "Error analyzing file %s: %v\n", inputFile, err)
+					fmt.Fprintf(os.Stderr, "%s %s: %v\n", utils.Colorize(os.Stderr, utils.Red, "Error analyzing file"), inputFile, err)
 					os.Exit(1)
 				}
 			} else {
-				// Default list of all interesting tools
-				toolsToCheck = []string{
-					"pandoc",
-					"typst",
-					"pdflatex",
-					"xelatex",
-					"lualatex",
-					"tectonic",
-					"wkhtmltopdf",
-					"pandoc-crossref",
-					"rsvg-convert",
-					// "python",
-				}
+				toolsToCheck = app.KnownTools
 			}
 
 			// deduplicate just in case
@@ -200,17 +230,531 @@ If no file is provided, it checks for all known tools.`,
 				// Re-using utils.CheckTool directly for everything is easiest.
 				// However, `utils.CheckPDFEngine` etc were just wrappers.
 				// Let's just use CheckTool directly.
-				check(utils.CheckTool(tool, ""))
+				check(app.CheckTool(tool))
 			}
 
 			_ = w.Flush()
+			// Colored after aligning, so the escape codes do not count as width
+			for _, line := range strings.SplitAfter(table.String(), "\n") {
+				line = strings.Replace(line, " FOUND ", " "+utils.Colorize(os.Stdout, utils.Green, "FOUND")+" ", 1)
+				line = strings.Replace(line, " MISSING ", " "+utils.Colorize(os.Stdout, utils.Red, "MISSING")+" ", 1)
+				_, _ = io.WriteString(os.Stdout, line)
+			}
+		},
+	}
+
+	// Import Command
+	var importOpts app.ImportOptions
+	var importCmd = &cobra.Command{
+		Use:   "import [flags] <file>...",
+		Short: "Convert an office document to Markdown",
+		Long: `Convert a DOCX, ODT, or other office document into clean Markdown.
+Images are extracted next to the output, typographic quotes are normalized,
+and a YAML header is synthesized from the document's properties. Several
+documents can be imported at once; each gets its own Markdown file and media
+directory.`,
+		Example: `  # Import a Word document as report.md
+  panforge import report.docx
+
+  # Import every Word document in the folder
+  panforge import *.docx
+
+  # Wrap lines at 80 columns and keep curly quotes
+  panforge import report.docx --wrap auto --columns 80 --keep-quotes`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			importOpts.Quiet = opts.Quiet
+			executor := &app.RealExecutor{DryRun: importOpts.DryRun}
+			return app.RunImports(cmd.Context(), args, importOpts, executor)
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return []string{"docx", "odt", "rtf", "epub", "html"}, cobra.ShellCompDirectiveFilterFileExt
 		},
 	}
+	importCmd.Flags().StringVarP(&importOpts.Output, "output", "o", "", "Specify output filename (default: <filename>.md)")
+	importCmd.Flags().StringVar(&importOpts.From, "from", "", "Input format (default: detected from the file extension)")
+	importCmd.Flags().StringVar(&importOpts.To, "to", "markdown", "Markdown flavor to produce (e.g. markdown, gfm, commonmark)")
+	importCmd.Flags().StringVar(&importOpts.Wrap, "wrap", "none", "Line wrapping: auto, none, or preserve")
+	importCmd.Flags().IntVar(&importOpts.Columns, "columns", 0, "Line length when --wrap=auto (default: pandoc's default)")
+	importCmd.Flags().StringVar(&importOpts.MediaDir, "media-dir", "", "Directory to extract images to (default: <filename>-media)")
+	importCmd.Flags().BoolVar(&importOpts.NoMedia, "no-media", false, "Do not extract images")
+	importCmd.Flags().BoolVar(&importOpts.KeepQuotes, "keep-quotes", false, "Keep typographic quotes instead of normalizing them")
+	importCmd.Flags().BoolVar(&importOpts.NoFrontmatter, "no-frontmatter", false, "Do not synthesize a YAML header from document properties")
+	importCmd.Flags().BoolVarP(&importOpts.Force, "force", "f", false, "Overwrite an existing output file")
+	importCmd.Flags().BoolVarP(&importOpts.DryRun, "dry-run", "n", false, "Print the Pandoc command without executing it")
+
+	// Diff-DOCX Command
+	var diffOpts app.DiffDocxOptions
+	var diffDocxCmd = &cobra.Command{
+		Use:   "diff-docx [flags] <original.md> <reviewed.docx>",
+		Short: "Compare a reviewed DOCX against its Markdown source",
+		Long: `Convert a reviewed DOCX back to Markdown and print a unified diff against the
+original source, so reviewers' edits can be incorporated by hand. Both sides are
+normalized through pandoc first so only content changes are reported.
+
+With --replace-body, a file is written that keeps the original YAML header and
+takes its whole body from the reviewed document. This is not a three-way merge:
+edits made to the source since the DOCX was exported are lost, so review the diff
+first.`,
+		Example: `  # Show what the reviewer changed
+  panforge diff-docx paper.md paper-reviewed.docx
+
+  # Take the reviewed body, rejecting unaccepted tracked changes
+  panforge diff-docx paper.md paper-reviewed.docx --track-changes reject --replace-body paper.reviewed.md`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			diffOpts.Quiet = opts.Quiet
+			executor := &app.RealExecutor{DryRun: diffOpts.DryRun}
+			return app.RunDiffDocx(cmd.Context(), args[0], args[1], diffOpts, executor, os.Stdout)
+		},
+	}
+	diffDocxCmd.Flags().StringVar(&diffOpts.TrackChanges, "track-changes", "accept", "How to treat tracked changes in the DOCX: accept, reject, or all")
+	diffDocxCmd.Flags().IntVarP(&diffOpts.Context, "context", "U", 3, "Number of unchanged lines to show around each change")
+	diffDocxCmd.Flags().StringVar(&diffOpts.ReplaceBody, "replace-body", "", "Write the original frontmatter with the reviewed body to FILE")
+	diffDocxCmd.Flags().BoolVarP(&diffOpts.Force, "force", "f", false, "Overwrite an existing --replace-body file")
+	diffDocxCmd.Flags().BoolVarP(&diffOpts.DryRun, "dry-run", "n", false, "Print the Pandoc commands without executing them")
+
+	_ = diffDocxCmd.RegisterFlagCompletionFunc("track-changes", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"accept", "reject", "all"}, cobra.ShellCompDirectiveNoFileComp
+	})
+
+	// Cache Command
+	var cacheCmd = &cobra.Command{
+		Use:   "cache",
+		Short: "Inspect and manage the incremental build cache",
+		Long: `Inspect and manage the incremental build cache stored in the panforge data directory.
+Each record remembers the last successful build of an output file so unchanged targets can be skipped.
+A copy of each output is kept under its content hash, so a build made on another branch or in
+another worktree is restored instead of converted again.`,
+	}
+	cacheCmd.AddCommand(&cobra.Command{
+		Use:   "info",
+		Short: "Show the cache location, size, and number of records",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return app.RunCacheInfo(cache.New(cache.DefaultDir()), os.Stdout)
+		},
+	})
+	cacheCmd.AddCommand(&cobra.Command{
+		Use:   "stats",
+		Short: "Show cache hit rates and the space used by stored outputs",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return app.RunCacheStats(cache.New(cache.DefaultDir()), os.Stdout)
+		},
+	})
+	var cleanStale bool
+	cacheCleanCmd := &cobra.Command{
+		Use:   "clean",
+		Short: "Remove cache records",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var out io.Writer = os.Stdout
+			if opts.Quiet {
+				out = io.Discard
+			}
+			return app.RunCacheClean(cache.New(cache.DefaultDir()), cleanStale, out)
+		},
+	}
+	cacheCleanCmd.Flags().BoolVar(&cleanStale, "stale", false, "Remove only records whose input or output is missing or modified")
+	cacheCmd.AddCommand(cacheCleanCmd)
+	cacheCmd.AddCommand(&cobra.Command{
+		Use:   "verify",
+		Short: "Report records whose input or output is missing or modified",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return app.RunCacheVerify(cache.New(cache.DefaultDir()), os.Stdout)
+		},
+	})
+
+	// Build Command
+	var workspaceFile string
+	var buildCmd = &cobra.Command{
+		Use:   "build",
+		Short: "Build every project listed in a workspace file",
+		Long: `Build all document projects listed in a ` + config.WorkspaceFileName + ` workspace file.
+Each project is a Markdown file or a directory of Markdown files with its own
+configuration. All projects share the --concurrency limit, and a combined report
+of every target is printed at the end.
+
+Without a file argument, --workspace uses the nearest ` + config.WorkspaceFileName + ` in the
+current directory or its parents.`,
+		Example: `  # Build the workspace found in the current directory or above
+  panforge build --workspace
+
+  # Build a specific workspace, only PDFs
+  panforge build --workspace docs/panforge.work -t pdf`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := workspaceFile
+			if path == "" || path == config.WorkspaceFileName {
+				if _, err := os.Stat(config.WorkspaceFileName); err != nil {
+					found, err := config.FindWorkspace(".")
+					if err != nil {
+						return err
+					}
+					path = found
+				}
+			}
+			ws, err := config.LoadWorkspace(path)
+			if err != nil {
+				return &app.ExitError{Code: app.ExitConfig, Err: fmt.Errorf("failed to load workspace: %w", err)}
+			}
+
+			if opts.Recipe != "" {
+				recipe, err := app.LoadRecipe(config.DataDirName(), opts.Recipe)
+				if err != nil {
+					return &app.ExitError{Code: app.ExitConfig, Err: err}
+				}
+				// A workspace build has no command line of pandoc arguments to extend
+				if len(recipe.PandocArgs) > 0 {
+					return &app.ExitError{Code: app.ExitConfig, Err: fmt.Errorf("recipe %s passes pandoc arguments, which a workspace build cannot use", opts.Recipe)}
+				}
+				if _, err := app.ApplyRecipe(cmd, recipe, nil); err != nil {
+					return &app.ExitError{Code: app.ExitConfig, Err: err}
+				}
+			}
+
+			if opts.Logger, err = newLogger(opts); err != nil {
+				return &app.ExitError{Code: app.ExitConfig, Err: err}
+			}
+
+			executor := &app.RealExecutor{DryRun: opts.DryRun, Verbose: opts.Verbose}
+			return app.RunWorkspace(cmd.Context(), ws, opts, executor, os.Stdout)
+		},
+	}
+	buildCmd.Flags().StringVar(&workspaceFile, "workspace", "", "Workspace file to build (default: nearest "+config.WorkspaceFileName+")")
+	buildCmd.Flags().Lookup("workspace").NoOptDefVal = config.WorkspaceFileName
+	buildCmd.Flags().StringSliceVarP(&opts.Targets, "to", "t", []string{}, "Only build these output format(s) (overrides per-project targets)")
+	addConvertFlags(buildCmd, &opts)
+
+	// Sync Command
+	var syncOpts app.SyncOptions
+	var syncCmd = &cobra.Command{
+		Use:   "sync [flags] [source]",
+		Short: "Install a team-shared configuration bundle into the data directory",
+		Long: `Install a shared configuration bundle (default configs, templates, filters,
+reference documents) into the panforge data directory, so every team member
+produces identically styled documents.
+
+The source is a git repository, a local directory, or the URL or path of a
+.tar.gz/.tgz/.zip archive. Git sources can be pinned to a branch, tag, or commit
+with --ref. The bundle's SHA-256 checksum is printed after every sync; pass it
+with --sha256 to refuse anything else. Without a source, the last synced source,
+ref, and checksum are used again.`,
+		Example: `  # Sync a tagged release of the team's config repository
+  panforge sync https://github.com/acme/docs-config.git --ref v1.4.0
+
+  # Sync a pinned archive
+  panforge sync https://example.com/docs-config.tar.gz --sha256 3f5a...
+
+  # Re-sync whatever was synced last
+  panforge sync`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 1 {
+				syncOpts.Source = args[0]
+			}
+			dataDir := config.DataDirName()
+			if err := os.MkdirAll(dataDir, 0750); err != nil {
+				return fmt.Errorf("failed to create data directory: %w", err)
+			}
+			syncOpts.Quiet = opts.Quiet
+			return app.RunSync(cmd.Context(), syncOpts, dataDir, os.Stdout)
+		},
+	}
+	syncCmd.Flags().StringVar(&syncOpts.Ref, "ref", "", "Git branch, tag, or commit to sync (default: the repository's HEAD)")
+	syncCmd.Flags().StringVar(&syncOpts.SHA256, "sha256", "", "Expected SHA-256 checksum of the bundle")
+	syncCmd.Flags().BoolVarP(&syncOpts.DryRun, "dry-run", "n", false, "List the files that would be installed without writing them")
+
+	// Report Bug Command
+	var reportOpts app.ReportBugOptions
+	var reportBugCmd = &cobra.Command{
+		Use:   "report-bug [flags] [file]",
+		Short: "Bundle environment, configuration, and the last run into an archive for an issue",
+		Long: `Gather everything needed to reproduce a conversion problem into a single zip
+archive: panforge, Go, and OS versions, the versions of pandoc and the other tools
+(or of the tools the given file needs), the default configuration files, the
+document's front matter and effective configuration, and the plan, pandoc stderr,
+and log of the last run.
+
+Values of sensitive settings such as tokens, passwords, and webhooks are redacted
+and the home directory is shown as "~". Review the archive before attaching it.`,
+		Example: `  # Report a problem converting report.md
+  panforge report.md -t pdf
+  panforge report-bug report.md`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 1 {
+				reportOpts.Input = args[0]
+			}
+			reportOpts.Version = versionStr
+			reportOpts.Quiet = opts.Quiet
+			reportOpts.Config = opts.Config
+			return app.RunReportBug(reportOpts, config.DataDirName(), os.Stdout)
+		},
+	}
+	reportBugCmd.Flags().StringVarP(&reportOpts.Output, "output", "o", "", "Archive to write (default: panforge-report-<timestamp>.zip)")
+
+	// Validate Command
+	var printSchema bool
+	var validateCmd = &cobra.Command{
+		Use:   "validate [file]...",
+		Short: "Check front matter and configs against the config schema",
+		Long: `Check the front matter of documents, and the project config that applies to each,
+against panforge's JSON Schema before any pandoc run. Each problem is reported with
+its file, line, and key, e.g. a string where a boolean belongs or an unknown
+on-conflict policy. Without files, the project config of the current directory and
+the default config are checked.
+
+Use --schema to print the schema, e.g. for an editor's YAML language server.`,
+		Example: `  panforge validate report.md
+  panforge validate --schema > panforge.schema.json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if printSchema {
+				_, err := os.Stdout.Write(config.Schema())
+				return err
+			}
+			return app.RunValidate(args, opts, os.Stdout)
+		},
+	}
+	validateCmd.Flags().BoolVar(&printSchema, "schema", false, "Print the JSON Schema of configs instead of validating")
+
+	// Config Command
+	var configCmd = &cobra.Command{
+		Use:   "config",
+		Short: "Read and change the default config",
+		Long: `Read and change settings of the default config (default.yaml in the data
+directory, or the config --config names) without editing it by hand. Nested keys are
+separated by dots, e.g. output.pdf.pdf-engine.`,
+	}
+	configCmd.AddCommand(&cobra.Command{
+		Use:     "get KEY",
+		Short:   "Print a setting of the default config",
+		Example: `  panforge config get output.pdf.pdf-engine`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return app.RunConfigGet(args[0], opts, os.Stdout)
+		},
+	})
+	configCmd.AddCommand(&cobra.Command{
+		Use:   "set KEY VALUE",
+		Short: "Change a setting of the default config, keeping its comments",
+		Long: `Change a setting of the default config, creating the config and any maps on the
+way to the key. The value is read as YAML: true and 2 keep their types and [html, pdf]
+is a list. Comments in the file are kept. Only YAML configs can be changed.`,
+		Example: `  panforge config set output.pdf.pdf-engine xelatex
+  panforge config set outputs "[html, pdf]"`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return app.RunConfigSet(args[0], args[1], opts, os.Stdout)
+		},
+	})
+	var editProject bool
+	var configEditCmd = &cobra.Command{
+		Use:   "edit",
+		Short: "Open the default config in $EDITOR",
+		Long: `Open the default config, or with --project the nearest project config, in $VISUAL or
+$EDITOR (default: vi). A config that does not exist yet is created from the same
+template as panforge init. The config is checked against the schema after editing.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return app.RunConfigEdit(editProject, opts, os.Stderr)
+		},
+	}
+	configEditCmd.Flags().BoolVar(&editProject, "project", false, "Edit the nearest "+config.ProjectFileName+" (default: a new one in the current directory) instead")
+	configCmd.AddCommand(configEditCmd)
+	configCmd.AddCommand(&cobra.Command{
+		Use:   "path",
+		Short: "Print the path of the default config",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return app.RunConfigPath(opts, os.Stdout)
+		},
+	})
+	configCmd.AddCommand(&cobra.Command{
+		Use:   "list [file]",
+		Short: "List the config files that apply, in the order they are applied",
+		Long: `Print the data directory and the config files a conversion would load, in the order
+they are applied: the default config, the nearest project config, and the document's
+front matter, each after the files it includes. Later files override earlier ones.
+Without a file, the configs that apply in the current directory are listed.`,
+		Example: `  panforge config list report.md`,
+		Args:    cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var input string
+			if len(args) == 1 {
+				input = args[0]
+			}
+			return app.RunConfigList(input, opts, os.Stdout)
+		},
+	})
+
+	// Recipe Command
+	var recipeCmd = &cobra.Command{
+		Use:   "recipe",
+		Short: "Save and list reusable conversion recipes",
+		Long: `A recipe is a saved invocation: the flags and pandoc arguments of a run and the
+settings of the document it converted, without its input, output names, title,
+and other per-document metadata. Apply it to any file with --recipe NAME.`,
+	}
+	recipeCmd.AddCommand(&cobra.Command{
+		Use:   "save NAME",
+		Short: "Save the last conversion as a named recipe",
+		Example: `  panforge thesis.md -t pdf --backup -- --pdf-engine=xelatex
+  panforge recipe save thesis-pdf
+  panforge other.md --recipe thesis-pdf`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var out io.Writer = os.Stdout
+			if opts.Quiet {
+				out = io.Discard
+			}
+			return app.RunRecipeSave(rootCmd, config.DataDirName(), args[0], out)
+		},
+	})
+	recipeCmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the saved recipes",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return app.RunRecipeList(config.DataDirName(), os.Stdout)
+		},
+	})
 
 	rootCmd.AddCommand(initCmd)
 	rootCmd.AddCommand(checkCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(diffDocxCmd)
+	rootCmd.AddCommand(cacheCmd)
+	rootCmd.AddCommand(buildCmd)
+	rootCmd.AddCommand(syncCmd)
+	rootCmd.AddCommand(reportBugCmd)
+	rootCmd.AddCommand(validateCmd)
+	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(recipeCmd)
+
+	// Invalid flags are configuration errors
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return &app.ExitError{Code: app.ExitConfig, Err: err}
+	})
 
 	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+		// Skipped targets were already reported; only the exit code tells
+		if !errors.Is(err, app.ErrSkipped) {
+			fmt.Fprintln(os.Stderr, utils.Colorize(os.Stderr, utils.Red, "Error:"), err)
+		}
+		os.Exit(app.ExitCode(err))
+	}
+}
+
+// addConvertFlags registers the conversion flags shared by the root command and build.
+//
+// Parameters:
+//   - `cmd`: the command to register the flags on
+//   - `opts`: the options the flags are bound to
+func addConvertFlags(cmd *cobra.Command, opts *options.Options) {
+	fs := cmd.Flags()
+	fs.StringVar(&opts.OutputDir, "output-dir", "", "Write generated outputs into DIR; -o is used as written (default: next to the input; env PANFORGE_OUTPUT_DIR)")
+	fs.StringSliceVar(&opts.DefaultTargets, "default-to", []string{}, "Format(s) to build when neither -t nor the document names any (default: html; env PANFORGE_DEFAULT_TO)")
+	fs.StringVar(&opts.From, "from", "", "Input format, e.g. rst, org, or docx (default: detected from the file extension)")
+	fs.BoolVarP(&opts.Force, "force", "f", false, "Overwrite existing output file(s) (default: false)")
+	fs.BoolVarP(&opts.DryRun, "dry-run", "n", false, "Print the Pandoc command(s) without executing them (default: false)")
+	fs.BoolVarP(&opts.Verbose, "verbose", "v", false, "Run Pandoc showing output (default: false)")
+	fs.StringVarP(&opts.Log, "log", "l", "", "Append program calls to FILE (default: none)")
+	fs.StringVar(&opts.LogDir, "log-dir", "", "Write one log per target to DIR: command, pandoc output, duration, and exit status (default: none)")
+	fs.IntVarP(&opts.Concurrency, "concurrency", "c", 0, "Limit number of concurrent pandoc processes (default: number of CPUs; env PANFORGE_CONCURRENCY)")
+	fs.BoolVar(&opts.NoCache, "no-cache", false, "Always run pandoc, even if inputs are unchanged since the last build (default: false)")
+	fs.BoolVar(&opts.Notify, "notify", false, "Show a desktop notification when a run finishes or fails (default: false)")
+	fs.StringVar(&opts.ChangedSince, "changed-since", "", "Only convert Markdown files changed in git since REF (default REF: HEAD)")
+	fs.Lookup("changed-since").NoOptDefVal = "HEAD"
+	fs.BoolVar(&opts.CheckPaths, "check-paths", false, "Verify that files referenced by the document and its options exist before converting, even in dry-run mode (default: false)")
+	fs.BoolVar(&opts.Strict, "strict", false, "Enable strict checks; implies --check-paths (default: false)")
+	fs.BoolVar(&opts.TraceConfig, "trace-config", false, "Print every effective setting with the config that supplied it (default: false)")
+	fs.StringVar(&opts.Archive, "archive", "", "Bundle every generated output into a .zip, .tar.gz, or .tar archive (default: none)")
+	fs.StringVar(&opts.Manifest, "manifest", "", "Write a JSON manifest of the outputs with sizes and SHA-256 checksums (default file: panforge-manifest.json)")
+	fs.Lookup("manifest").NoOptDefVal = "panforge-manifest.json"
+	fs.StringVar(&opts.KeepIntermediates, "keep-intermediates", "", "Keep generated sources (.tex, Typst), preprocessed copies, and LaTeX auxiliary files in DIR/<document>/<target> for debugging (default DIR: panforge-intermediates)")
+	fs.Lookup("keep-intermediates").NoOptDefVal = "panforge-intermediates"
+	fs.StringVar(&opts.Chdir, "chdir", "", "Run pandoc in DIR, so relative paths in its options resolve from there; without DIR, in the input file's directory (default: the working-dir setting, else the current directory)")
+	fs.Lookup("chdir").NoOptDefVal = "input"
+	fs.StringVar(&opts.LogFormat, "log-format", "text", "Write log lines to stderr as text or json (one object per line)")
+	fs.BoolVar(&opts.NoProgress, "no-progress", false, "Log plainly instead of showing a live view of the targets being converted (default: live view on a terminal)")
+	fs.StringVar(&opts.Annotations, "annotations", "", "Also print pandoc warnings and errors as CI annotations; github prints workflow commands that show inline on pull requests (default: none)")
+	fs.StringVar(&opts.Report, "report", "", "Write a summary of the run (per-target status, duration, output, warnings, errors) as json or yaml to stdout (default: none)")
+	fs.StringVar(&opts.ReportFile, "report-file", "", "Write the --report summary to FILE instead of stdout; the format defaults to the file extension (default: none)")
+	fs.BoolVarP(&opts.KeepGoing, "keep-going", "k", false, "Build every target even if one fails, then report all failures (default: false)")
+	fs.IntVar(&opts.Retries, "retries", 0, "Retry a target's failed pandoc run up to N times, waiting 1s, 2s, 4s, ... in between (default: 0)")
+	fs.DurationVar(&opts.Timeout, "timeout", 0, "Stop a target's pandoc run after this long, e.g. 10m; overrides the timeout key (default: no limit)")
+	fs.StringVar(&opts.NoInput, "no-input", "", "Never prompt, for CI: an existing output is skipped, or with --no-input=fail fails its target (default: on when stdin is not a terminal)")
+	fs.Lookup("no-input").NoOptDefVal = "skip"
+	fs.StringVar(&opts.Recipe, "recipe", "", "Apply a recipe saved with panforge recipe save; flags given on the command line win (default: none)")
+	fs.StringVar(&opts.SubprocessOutput, "subprocess-output", "", "Show pandoc's output: discard, on-failure, stream, or a FILE per target ({target} is replaced) (default: on-failure; stream with --verbose)")
+	fs.StringVar(&opts.OnConflict, "on-conflict", "", "What to do when an output exists: prompt, skip, overwrite, rename, or trash (default: prompt; overwrite with --force)")
+	fs.StringVar(&opts.Backup, "backup", "", "Rename existing outputs to <name>.bak before overwriting them; --backup=timestamp keeps every version (default: off)")
+	fs.Lookup("backup").NoOptDefVal = "simple"
+	fs.StringArrayVar(&opts.Matrix, "matrix", nil, "Build every target once per value of a matrix dimension, e.g. profile=draft,final; repeat for more dimensions (default: none)")
+	fs.IntVar(&opts.SamplePages, "sample-pages", 0, "For PDF targets, build only the first N top-level sections into <name>.sample.pdf for a quick preview (default: off)")
+	fs.BoolVar(&opts.NoInteractive, "no-interactive", false, "Never prompt for the targets to build; build every target the document defines (default: false)")
+	// Keep the flags in the order they are defined
+	fs.SortFlags = false
+
+	_ = cmd.RegisterFlagCompletionFunc("log-format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return logFormats, cobra.ShellCompDirectiveNoFileComp
+	})
+
+	_ = cmd.RegisterFlagCompletionFunc("annotations", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return app.AnnotationFormats, cobra.ShellCompDirectiveNoFileComp
+	})
+
+	_ = cmd.RegisterFlagCompletionFunc("report", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return app.ReportFormats, cobra.ShellCompDirectiveNoFileComp
+	})
+
+	_ = cmd.RegisterFlagCompletionFunc("recipe", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return app.RecipeNames(config.DataDirName()), cobra.ShellCompDirectiveNoFileComp
+	})
+
+	_ = cmd.RegisterFlagCompletionFunc("no-input", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return app.NoInputModes, cobra.ShellCompDirectiveNoFileComp
+	})
+
+	_ = cmd.RegisterFlagCompletionFunc("on-conflict", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return app.ConflictPolicies, cobra.ShellCompDirectiveNoFileComp
+	})
+}
+
+// logFormats lists the formats accepted by --log-format.
+var logFormats = []string{"text", "json"}
+
+// newLogger returns the logger of a run. Log lines go to stderr, so stdout only carries
+// what was asked for (dry-run commands, reports); --log-format json writes one JSON
+// object per line for log aggregators, and text lines are colored by level unless
+// --color or NO_COLOR turns colors off.
+//
+// Parameters:
+//   - `opts`: runtime options
+//
+// Returns:
+//   - *slog.Logger: the logger
+//   - error: if the log format is not supported
+func newLogger(opts options.Options) (*slog.Logger, error) {
+	level := slog.LevelInfo
+	if opts.Verbose {
+		level = slog.LevelDebug
+	} else if opts.Quiet {
+		level = slog.LevelError
+	}
+	handlerOpts := &slog.HandlerOptions{Level: level}
+	switch strings.ToLower(opts.LogFormat) {
+	case "", "text":
+		var w io.Writer = os.Stderr
+		if utils.UseColor(os.Stderr) {
+			w = utils.LevelColorWriter{W: os.Stderr}
+		}
+		return slog.New(slog.NewTextHandler(w, handlerOpts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stderr, handlerOpts)), nil
 	}
+	return nil, fmt.Errorf("invalid --log-format %q (want %s)", opts.LogFormat, strings.Join(logFormats, " or "))
 }
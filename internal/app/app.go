@@ -3,23 +3,31 @@ package app
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/spf13/cobra"
 	"golang.org/x/sync/errgroup"
 	"golang.org/x/sync/semaphore"
 
+	"github.com/rapjul/panforge/internal/cache"
 	"github.com/rapjul/panforge/internal/config"
 	"github.com/rapjul/panforge/internal/options"
 	"github.com/rapjul/panforge/internal/pandoc"
+	"github.com/rapjul/panforge/internal/preprocess"
 	"github.com/rapjul/panforge/internal/utils"
 )
 
@@ -36,9 +44,14 @@ type RealExecutor struct {
 	DryRun bool
 	// Verbose indicates if the command should be logged behavior details.
 	Verbose bool
+	// Dir is the working directory of the commands ("" for panforge's own).
+	Dir string
+	// Env holds "KEY=value" entries added to the environment of the commands.
+	Env []string
 }
 
-// Run executes a system command using os/exec.
+// Run executes a system command using os/exec. "pandoc" runs the binary selected with
+// --pandoc-path or `pandoc-path`.
 //
 // Parameters:
 //   - `ctx`: context for cancellation
@@ -47,15 +60,116 @@ type RealExecutor struct {
 //   - `stdout`: writer for standard output
 //   - `stderr`: writer for standard error
 func (e *RealExecutor) Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	return e.runCmd(ctx, e.Dir, nil, name, args, nil, stdout, stderr)
+}
+
+// RunStdin executes a system command like Run, with stdin as its standard input.
+//
+// Parameters:
+//   - `ctx`: context for cancellation
+//   - `name`: command name
+//   - `args`: command arguments
+//   - `stdin`: reader for standard input
+//   - `stdout`: writer for standard output
+//   - `stderr`: writer for standard error
+func (e *RealExecutor) RunStdin(ctx context.Context, name string, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	return e.runCmd(ctx, e.Dir, nil, name, args, stdin, stdout, stderr)
+}
+
+// RunIn executes a system command like Run, in the working directory dir and with env
+// added to the executor's environment.
+//
+// Parameters:
+//   - `ctx`: context for cancellation
+//   - `dir`: the working directory
+//   - `env`: "KEY=value" entries to add
+//   - `name`: command name
+//   - `args`: command arguments
+//   - `stdout`: writer for standard output
+//   - `stderr`: writer for standard error
+func (e *RealExecutor) RunIn(ctx context.Context, dir string, env []string, name string, args []string, stdout, stderr io.Writer) error {
+	return e.runCmd(ctx, dir, env, name, args, nil, stdout, stderr)
+}
+
+// runCmd runs a command for Run, RunStdin, and RunIn.
+//
+// Parameters:
+//   - `ctx`: context for cancellation
+//   - `dir`: the working directory ("" for panforge's own)
+//   - `env`: "KEY=value" entries added after the executor's Env
+//   - `name`: command name; "pandoc" runs the selected pandoc binary
+//   - `args`: command arguments
+//   - `stdin`: reader for standard input, or nil
+//   - `stdout`: writer for standard output
+//   - `stderr`: writer for standard error
+func (e *RealExecutor) runCmd(ctx context.Context, dir string, env []string, name string, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
 	if e.DryRun {
 		return nil
 	}
+	if name == "pandoc" {
+		name = pandoc.Binary()
+	}
 	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	cmd.Stdin = stdin
 	cmd.Stdout = stdout
 	cmd.Stderr = stderr
+	if extra := append(append([]string(nil), e.Env...), env...); len(extra) > 0 {
+		cmd.Env = append(os.Environ(), extra...)
+	}
+	// When the context ends the command is killed; don't wait long for its children
+	// (e.g. a LaTeX engine started by pandoc) to release the output pipes
+	cmd.WaitDelay = commandWaitDelay
 	return cmd.Run()
 }
 
+// commandWaitDelay bounds how long a killed command's output is still waited for.
+const commandWaitDelay = 5 * time.Second
+
+// loadDefaultConfig loads the default config: the one --config names, as a path or as the
+// name of a config in the data directory, else default.yaml there if it exists.
+//
+// Parameters:
+//   - `name`: the --config value, or "" for the default
+//
+// Returns:
+//   - string: absolute path of the loaded file ("" if there is none)
+//   - *config.Config: its settings
+//   - error: if it is invalid, or if the config --config names does not exist
+func loadDefaultConfig(name string) (string, *config.Config, error) {
+	if name == "" {
+		return config.LoadDefaultConfig("default")
+	}
+	path, cfg, err := config.LoadDefaultConfig(name)
+	if err == nil && path == "" {
+		err = fmt.Errorf("config %s not found in %s", name, config.DataDirName())
+	}
+	return path, cfg, err
+}
+
+// PandocPath returns the pandoc binary to run: --pandoc-path, else the `pandoc-path` key
+// of the default config (relative to the config file), else "pandoc" from the PATH.
+//
+// Parameters:
+//   - `opts`: runtime options
+func PandocPath(opts options.Options) string {
+	if opts.PandocPath != "" {
+		return opts.PandocPath
+	}
+	cfgPath, cfg, err := loadDefaultConfig(opts.Config)
+	if err != nil || cfg == nil {
+		return "pandoc"
+	}
+	path, _ := cfg.Generic["pandoc-path"].(string)
+	if path == "" {
+		return "pandoc"
+	}
+	if strings.ContainsRune(path, filepath.Separator) && !filepath.IsAbs(path) && cfgPath != "" {
+		path = filepath.Join(filepath.Dir(cfgPath), path)
+	}
+	return path
+}
+
 // Options holds CLI flags
 // Moved to internal/options
 
@@ -67,55 +181,172 @@ func (e *RealExecutor) Run(ctx context.Context, name string, args []string, stdo
 //   - `args`: command line arguments
 //   - `opts`: parsed command line flags
 //   - `executor`: interface for running system commands
+//
+// Returns:
+//   - error: the run's failure, or ErrSkipped if no target failed but some were skipped;
+//     ExitCode maps it to the command's exit code
 func Run(ctx context.Context, cmd *cobra.Command, args []string, opts options.Options, executor CommandExecutor) error {
+	if _, err := reportFormat(opts); err != nil {
+		return configError(err)
+	}
+	if _, err := annotationsFormat(opts); err != nil {
+		return configError(err)
+	}
+	if opts.Config != "" {
+		if _, _, err := loadDefaultConfig(opts.Config); err != nil {
+			return configError(err)
+		}
+	}
+
 	// 1. Parse Input File
 	inputFile, postArgs := parseArgs(args)
 	if inputFile == "" {
 		if len(opts.Targets) > 0 || opts.Output != "" {
-			return fmt.Errorf("no input file found")
+			return configError(fmt.Errorf("no input file found"))
 		}
 		return cmd.Help()
 	}
 
-	// Resolve input file path (if not stdin)
-	if inputFile != "-" {
-		resolvedInput, err := utils.ResolvePath(inputFile)
-		if err != nil {
-			return fmt.Errorf("failed to resolve input file path: %w", err)
+	// Read a document from stdin; it is streamed to pandoc rather than saved to a file
+	if inputFile == stdinInput {
+		if opts.Watch || opts.ChangedSince != "" {
+			return configError(fmt.Errorf("--watch and --changed-since need an input file, not stdin"))
 		}
-		inputFile = resolvedInput
-	}
-
-	// Handle stdin input
-	if inputFile == "-" {
-		tmpFile, err := os.CreateTemp("", "panforge-stdin-*.md")
+		data, err := io.ReadAll(cmd.InOrStdin())
 		if err != nil {
-			return fmt.Errorf("failed to create temp file for stdin: %w", err)
-		}
-		defer func() { _ = os.Remove(tmpFile.Name()) }()
-
-		if _, err := io.Copy(tmpFile, cmd.InOrStdin()); err != nil {
-			_ = tmpFile.Close()
 			return fmt.Errorf("failed to read stdin: %w", err)
 		}
-		if err := tmpFile.Close(); err != nil {
-			return fmt.Errorf("failed to close temp file: %w", err)
+		inputFile = stdinDocument(opts.From)
+		start := time.Now()
+		results, err := processFileIn(ctx, inputFile, postArgs, opts, executor, processEnv{interactive: true, stdin: data})
+		notifyResult(opts, inputFile, start, err)
+		err = skippedError(results, err)
+		if rerr := writeReport(opts, results, start, err, cmd.OutOrStdout()); rerr != nil {
+			return errors.Join(err, rerr)
 		}
-		inputFile = tmpFile.Name()
+		return err
+	}
+
+	resolvedInput, err := utils.ResolvePath(inputFile)
+	if err != nil {
+		return fmt.Errorf("failed to resolve input file path: %w", err)
 	}
+	inputFile = resolvedInput
 
 	// 2. Initial Config Loading & Execution
 	// If watch mode is enabled, we'll hand off to the Watcher (implemented elsewhere).
 	// For now, let's just call Process once if Watch is false.
 
-	// Determine default config path for watching
-	defaultConfigPath, _, _ := config.LoadDefaultConfig("default")
+	// Determine the config paths for watching
+	var configFiles []string
+	if path := config.FindProjectConfig(filepath.Dir(inputFile)); path != "" {
+		configFiles = append(configFiles, path)
+	}
+	if path, _, _ := loadDefaultConfig(opts.Config); path != "" {
+		configFiles = append(configFiles, path)
+	}
+
+	// Directory input and --changed-since convert a set of files
+	files, multi, err := resolveInputs(inputFile, opts)
+	if err != nil {
+		return err
+	}
+	if multi {
+		if opts.Watch {
+			return configError(fmt.Errorf("--watch cannot be combined with a directory input or --changed-since"))
+		}
+		if len(files) == 0 {
+			if !opts.Quiet {
+				fmt.Println("No changed Markdown files to convert.")
+			}
+			return nil
+		}
+		start := time.Now()
+		results, err := runMany(ctx, files, postArgs, opts, executor)
+		if rerr := writeReport(opts, results, start, err, cmd.OutOrStdout()); rerr != nil {
+			return errors.Join(err, rerr)
+		}
+		return err
+	}
 
 	if opts.Watch {
-		return Watch(ctx, inputFile, defaultConfigPath, postArgs, opts, executor)
+		return Watch(ctx, inputFile, configFiles, postArgs, opts, executor)
+	}
+
+	start := time.Now()
+	results, err := processFile(ctx, inputFile, postArgs, opts, executor)
+	notifyResult(opts, inputFile, start, err)
+	err = skippedError(results, err)
+	if rerr := writeReport(opts, results, start, err, cmd.OutOrStdout()); rerr != nil {
+		return errors.Join(err, rerr)
+	}
+	return err
+}
+
+// notifier shows desktop notifications; replaced in tests.
+var notifier = utils.Notify
+
+// notifyResult sends a desktop notification summarizing a run if --notify is set.
+//
+// Parameters:
+//   - `opts`: runtime options
+//   - `inputFile`: the converted file
+//   - `start`: when the run started
+//   - `err`: the run's result
+func notifyResult(opts options.Options, inputFile string, start time.Time, err error) {
+	if !opts.Notify {
+		return
+	}
+	elapsed := time.Since(start).Round(100 * time.Millisecond)
+	title := "panforge: build succeeded"
+	message := fmt.Sprintf("%s converted in %s", filepath.Base(inputFile), elapsed)
+	if err != nil {
+		title = "panforge: build failed"
+		message = fmt.Sprintf("%s failed after %s: %v", filepath.Base(inputFile), elapsed, err)
+	}
+	sendNotification(opts, title, message)
+}
+
+// notifySummary sends one desktop notification for a run over several documents
+// (a directory, --changed-since, or a workspace) if --notify is set.
+//
+// Parameters:
+//   - `opts`: runtime options
+//   - `total`: the number of documents in the run
+//   - `failed`: the number of documents that failed
+//   - `start`: when the run started
+//   - `err`: the run's result
+func notifySummary(opts options.Options, total, failed int, start time.Time, err error) {
+	if !opts.Notify {
+		return
+	}
+	elapsed := time.Since(start).Round(100 * time.Millisecond)
+	noun := "documents"
+	if total == 1 {
+		noun = "document"
 	}
+	title := "panforge: build succeeded"
+	message := fmt.Sprintf("%d %s converted in %s", total, noun, elapsed)
+	if err != nil {
+		title = "panforge: build failed"
+		message = fmt.Sprintf("%d of %d %s failed after %s", failed, total, noun, elapsed)
+		if failed == 0 {
+			message = fmt.Sprintf("%d %s, failed after %s: %v", total, noun, elapsed, err)
+		}
+	}
+	sendNotification(opts, title, message)
+}
 
-	return Process(ctx, inputFile, postArgs, opts, executor)
+// sendNotification shows a desktop notification, logging a failure to show it.
+//
+// Parameters:
+//   - `opts`: runtime options
+//   - `title`: the notification title
+//   - `message`: the notification body
+func sendNotification(opts options.Options, title, message string) {
+	if nerr := notifier(title, message); nerr != nil && opts.Logger != nil {
+		opts.Logger.Debug("desktop notification failed", "error", nerr)
+	}
 }
 
 // Process handles a single run of the conversion logic.
@@ -126,159 +357,559 @@ func Run(ctx context.Context, cmd *cobra.Command, args []string, opts options.Op
 //   - `postArgs`: additional arguments to pass to pandoc
 //   - `opts`: configuration options
 //   - `executor`: used to run the pandoc command
+func Process(ctx context.Context, inputFile string, postArgs []string, opts options.Options, executor CommandExecutor) error {
+	_, err := processFile(ctx, inputFile, postArgs, opts, executor)
+	return err
+}
+
+// processFile implements Process and also returns the per-target results.
+func processFile(ctx context.Context, inputFile string, postArgs []string, opts options.Options, executor CommandExecutor) ([]TargetResult, error) {
+	return processFileIn(ctx, inputFile, postArgs, opts, executor, processEnv{interactive: true})
+}
+
+// processFileIn is processFile with the given process environment.
+func processFileIn(ctx context.Context, inputFile string, postArgs []string, opts options.Options, executor CommandExecutor, env processEnv) ([]TargetResult, error) {
+	start := time.Now()
+	results, err := process(ctx, inputFile, postArgs, opts, executor, env)
+	if !opts.Quiet {
+		writeDiagnostics(os.Stderr, workingDir(), results)
+	}
+	if opts.Annotations != "" {
+		writeAnnotations(annotationsWriter(opts), results)
+	}
+	recordLastRun(config.DataDirName(), inputFile, opts, start, results, err)
+	return results, err
+}
+
+// processEnv carries state shared between several Process runs, such as a workspace build.
+type processEnv struct {
+	// sem limits concurrent pandoc processes; nil creates one from opts.Concurrency.
+	sem *semaphore.Weighted
+	// baseDir is the directory relative output paths are resolved against ("" for the working directory).
+	baseDir string
+	// interactive allows prompting for targets when the document defines several.
+	interactive bool
+	// workspace holds the shared settings of a workspace build (nil otherwise).
+	workspace *workspaceEnv
+	// part is the part being built of a book with `split-chapters` (nil otherwise).
+	part *bookPart
+	// run is the build directory shared by the parts of a book (nil creates one from `keep-builds`).
+	run *buildRun
+	// builds holds the build directories shared by the files of one invocation (nil when
+	// each document gets its own).
+	builds *buildRuns
+	// stdin is the document read from stdin (nil when it is read from the input file).
+	stdin []byte
+}
+
+// promptMu serializes overwrite prompts across concurrent targets.
+var promptMu sync.Mutex
+
+// process implements Process and also returns the per-target results.
 //
 //nolint:gocyclo // Code is complex but manageable; refactoring deferred
-func Process(ctx context.Context, inputFile string, postArgs []string, opts options.Options, executor CommandExecutor) error {
+func process(ctx context.Context, inputFile string, postArgs []string, opts options.Options, executor CommandExecutor, env processEnv) ([]TargetResult, error) {
 	// 2. Initial Config Loading
-	formats, err := pandoc.GetSupportedFormats()
-	if err != nil {
-		return fmt.Errorf("failed to get supported formats: %w", err)
+	// Planning (dry runs) works without pandoc; only actual conversions need the binary.
+	if _, installed := pandoc.OutputFormats(); !installed {
+		if !opts.DryRun {
+			return nil, &ExitError{Code: ExitPandocMissing, Err: fmt.Errorf("pandoc not found. Please install it from https://pandoc.org/installing.html")}
+		}
+		if opts.Logger != nil {
+			opts.Logger.Warn("pandoc not found, planning with the built-in format list")
+		} else {
+			fmt.Fprintln(os.Stderr, "Warning: pandoc not found, planning with the built-in format list")
+		}
 	}
-	if len(formats) == 0 {
-		return fmt.Errorf("pandoc not found. Please install it from https://pandoc.org/installing.html")
+
+	promptMode, err := noInputMode(opts)
+	if err != nil {
+		return nil, configError(err)
 	}
 
-	_, cfg, err := config.LoadConfig(inputFile)
+	var cfg *config.Config
+	if env.stdin != nil {
+		cfg, err = parseDocumentConfig(env.stdin, inputFile, opts.From)
+	} else {
+		cfg, err = loadDocumentConfig(inputFile, opts.From)
+	}
 	if err != nil {
 		// If config loading fails (e.g. no YAML header), we only proceed if
 		// the user explicitly provided targets via CLI args.
-		if len(opts.Targets) == 0 {
-			return fmt.Errorf("input file has no valid YAML header and no target format specified: %w", err)
+		if len(opts.Targets) == 0 && opts.Recipe == "" {
+			if errors.Is(err, errNoHeader) {
+				return nil, configError(fmt.Errorf("%w to name the targets; choose them with -t (e.g. -t html)", err))
+			}
+			return nil, configError(fmt.Errorf("input file has no valid YAML header and no target format specified: %w", err))
 		}
 		// Proceed with empty config if interactive/CLI targets are present
 		cfg = &config.Config{}
 	}
 
-	_, defaultCfg, _ := config.LoadDefaultConfig("default")
-	if defaultCfg != nil {
-		if cfg.Title == "" {
-			cfg.Title = defaultCfg.Title
+	projectPath, projectCfg, err := config.LoadProjectConfig(inputFile)
+	if err != nil {
+		return nil, configError(err)
+	}
+	defaultPath, defaultCfg, err := loadDefaultConfig(opts.Config)
+	if err != nil {
+		return nil, configError(err)
+	}
+	// Checked before merging so a document cannot disable a sandbox enabled by the defaults
+	sandboxed := configSandboxed(cfg) || configSandboxed(projectCfg) || configSandboxed(defaultCfg)
+	var trace *configTrace
+	if opts.TraceConfig {
+		trace = newConfigTrace(cfg, "document "+inputFile)
+	}
+	trace.merge(cfg, projectCfg, "project config "+projectPath)
+	if env.workspace != nil {
+		trace.merge(cfg, env.workspace.defaultsConfig(), "workspace defaults")
+	}
+	if opts.Recipe != "" {
+		recipe, err := LoadRecipe(config.DataDirName(), opts.Recipe)
+		if err != nil {
+			return nil, configError(err)
+		}
+		trace.merge(cfg, &recipe.Config, "recipe "+opts.Recipe)
+	}
+	trace.merge(cfg, defaultCfg, "default config "+defaultPath)
+	if err := config.ResolveTargetExtends(cfg); err != nil {
+		return nil, configError(err)
+	}
+	trace.print(traceWriter(opts), inputFile, cfg)
+	if env.part != nil {
+		// The part is one chapter of the book; it must not be combined again
+		delete(cfg.Generic, "chapters")
+	}
+
+	// 3. Determine Targets
+	targets := DetermineTargets(opts, cfg)
+	if env.interactive && shouldPickTargets(opts, targets) {
+		promptMu.Lock()
+		targets = pickTargets(targets, os.Stdin, os.Stderr)
+		promptMu.Unlock()
+		if len(targets) == 0 {
+			if !opts.Quiet {
+				fmt.Println("No targets selected.")
+			}
+			return nil, nil
 		}
-		if cfg.FilenameTemplate == "" {
-			cfg.FilenameTemplate = defaultCfg.FilenameTemplate
+	}
+
+	if err := checkFormatDefaults(cfg, targets); err != nil {
+		return nil, configError(err)
+	}
+
+	if env.part == nil {
+		if err := lintOptions(cfg, targets, opts, os.Stderr); err != nil {
+			return nil, err
 		}
-		if cfg.SlugifyFilename == nil {
-			cfg.SlugifyFilename = defaultCfg.SlugifyFilename
+		if err := checkDeprecatedConfig(cfg, targets, opts, os.Stderr); err != nil {
+			return nil, err
 		}
-		if cfg.OutputMap == nil {
-			cfg.OutputMap = defaultCfg.OutputMap
-		} else {
-			for k, v := range defaultCfg.OutputMap {
-				if _, exists := cfg.OutputMap[k]; !exists {
-					cfg.OutputMap[k] = v
-				}
+	}
+
+	if env.part == nil && boolSetting(cfg, nil, "split-chapters") && cfg.Generic["chapters"] != nil {
+		return processParts(ctx, inputFile, postArgs, opts, executor, env, cfg, targets, sandboxed)
+	}
+
+	// A document from stdin is streamed to pandoc, unless a step needs it as a file
+	sourceFile := inputFile
+	stdin := env.stdin
+	if stdin != nil {
+		sourceFile = stdinInput
+		if stdinNeedsFile(stdin, cfg, targets, opts, executor) {
+			copied, err := writeStdinCopy(inputFile, stdin)
+			if err != nil {
+				return nil, err
 			}
+			defer func() { _ = os.Remove(copied) }()
+			sourceFile, stdin = copied, nil
 		}
-		if cfg.Generic == nil {
-			cfg.Generic = make(map[string]interface{})
+	}
+
+	// TOML and JSON front matter is rewritten as the YAML header pandoc reads
+	if headerFormat(sourceFormat(inputFile, opts.From)) {
+		converted, convertedStdin, err := convertFrontmatter(sourceFile, stdin)
+		if err != nil {
+			return nil, configError(err)
 		}
-		if defaultCfg.Generic != nil {
-			for k, v := range defaultCfg.Generic {
-				if _, exists := cfg.Generic[k]; !exists {
-					cfg.Generic[k] = v
-				}
-			}
+		if converted != sourceFile {
+			defer func() { _ = os.Remove(converted) }()
 		}
+		sourceFile, stdin = converted, convertedStdin
 	}
 
-	// 3. Determine Targets
-	targets := DetermineTargets(opts, cfg)
+	// Expand includes and combine book chapters into one source
+	preparedFile, err := prepareSource(sourceFile, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if preparedFile != "" {
+		keepInput, name := inputFile, "source"+filepath.Ext(inputFile)
+		if env.part != nil {
+			keepInput, name = env.part.Source, fmt.Sprintf("source-part-%02d%s", env.part.Index, filepath.Ext(inputFile))
+		}
+		defer newIntermediates(opts, keepInput, "").discard(preparedFile, name)
+		sourceFile = preparedFile
+	}
+	steps, err := parsePreprocessSteps(cfg.Generic["preprocess"])
+	if err != nil {
+		return nil, configError(err)
+	}
+	if len(steps) > 0 {
+		if err := runPreprocess(ctx, steps, sourceFile, inputFile, opts, executor, sandboxed); err != nil {
+			return nil, err
+		}
+	}
+
+	// Verify referenced files up front, even in dry-run mode
+	if opts.CheckPaths || opts.Strict {
+		if err := checkPaths(sourceFile, cfg, targets, postArgs); err != nil {
+			return nil, err
+		}
+	}
 
 	// 4. Process Each Target
-	g, ctx := errgroup.WithContext(ctx)
+	g, groupCtx := errgroup.WithContext(ctx)
+	if opts.KeepGoing {
+		// A failed target does not stop the others; failures are collected after the run
+		g, groupCtx = &errgroup.Group{}, ctx
+	}
+	start := time.Now()
+	var resultsMu sync.Mutex
+	var results []TargetResult
 
 	// Semaphore to limit concurrency
-	limit := int64(opts.Concurrency)
-	if limit <= 0 {
-		limit = int64(runtime.NumCPU())
+	sem := env.sem
+	if sem == nil {
+		sem = newSemaphore(opts.Concurrency)
 	}
-	sem := semaphore.NewWeighted(limit)
 
 	var logMu sync.Mutex
-	var promptMu sync.Mutex
 	var logFile *os.File
 	if opts.Log != "" {
 		var err error
 		logFile, err = os.OpenFile(opts.Log, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644) //nolint:gosec // 0644 is standard for logs
 		if err != nil {
-			return fmt.Errorf("failed to open log file: %w", err)
+			return nil, fmt.Errorf("failed to open log file: %w", err)
 		}
 		defer func() { _ = logFile.Close() }()
 	}
 
-	for _, t := range targets {
-		t := t // capture loop variable
-		g.Go(func() error {
-			if err := sem.Acquire(ctx, 1); err != nil {
+	// Numbered build directories (keep-builds)
+	run, ownRun := env.run, env.run == nil && env.builds == nil
+	if env.run == nil && env.builds != nil {
+		if run, err = env.builds.get(cfg, env.baseDir); err != nil {
+			return nil, configError(err)
+		}
+	}
+	if ownRun {
+		if run, err = newBuildRun(cfg, start, env.baseDir, !opts.DryRun); err != nil {
+			return nil, configError(err)
+		}
+		if run != nil && !opts.DryRun {
+			// Only succeeds if the run ends early without writing anything
+			defer func() { _ = os.Remove(run.Dir) }()
+		}
+	}
+
+	var buildCache *cache.Cache
+	pandocVersion, version, versionKnown := pandoc.InstalledVersion()
+	if opts.DryRun && pandocVersion != "" {
+		fmt.Printf("panforge using: %s\n", pandocVersion)
+	}
+	var cacheStats cache.Stats
+	var statsMu sync.Mutex
+	countCache := func(hit, restored, miss int64) {
+		statsMu.Lock()
+		cacheStats.Hits += hit
+		cacheStats.Restored += restored
+		cacheStats.Misses += miss
+		statsMu.Unlock()
+	}
+	if !opts.NoCache {
+		buildCache = cache.New(cache.DefaultDir())
+	}
+
+	dims, err := resolveMatrix(opts, cfg)
+	if err != nil {
+		return nil, configError(err)
+	}
+	cells := matrixCells(targets, dims)
+	labels := make([]string, len(cells))
+	for i, cell := range cells {
+		labels[i] = cell.label()
+		if env.part != nil {
+			vars := append(append([]matrixVar(nil), cell.Vars...), env.part.variable())
+			labels[i] = matrixCell{Target: cell.Target, Vars: vars}.label()
+		}
+	}
+	// With -o -, the single target writes a temporary file that is then copied to stdout
+	var stream *stdoutStream
+	if opts.Output == stdoutOutput {
+		if len(cells) != 1 {
+			return nil, configError(fmt.Errorf("-o - writes to stdout and needs exactly one target, got %d", len(cells)))
+		}
+		if ReportToStdout(opts) {
+			return nil, configError(fmt.Errorf("-o - cannot be combined with a report written to stdout"))
+		}
+		format, _ := resolveTarget(cfg, cells[0].Target)
+		if stream, err = newStdoutStream(inputFile, format); err != nil {
+			return nil, err
+		}
+		defer stream.close()
+		opts.Output = stream.file
+		// Messages would end up in the document, and a cache record would name the temporary file
+		buildCache = nil
+		if !opts.DryRun {
+			opts.Quiet = true
+		}
+	}
+	prog := newProgress(opts, cfg, labels, env)
+	if prog != nil && opts.Logger != nil {
+		// Log lines are printed above the live view
+		opts.Logger = slog.New(progressHandler{Handler: opts.Logger.Handler(), p: prog})
+	}
+
+	// Name every output before any target runs, so targets that would write the same
+	// file are caught instead of clobbering each other
+	plans := make([]*targetPlan, len(cells))
+	for i, cell := range cells {
+		namingInput := inputFile
+		if env.part != nil {
+			cell.Vars = append(append([]matrixVar(nil), cell.Vars...), env.part.variable())
+			namingInput = env.part.Source
+		}
+		plans[i] = planTarget(groupCtx, cfg, cell, namingInput, opts, env, run, sandboxed)
+	}
+	if err := resolveCollisions(cfg, cells, plans); err != nil {
+		return nil, configError(err)
+	}
+	if run != nil && !opts.DryRun {
+		if err := os.MkdirAll(run.Dir, 0750); err != nil {
+			return nil, fmt.Errorf("failed to create build directory: %w", err)
+		}
+	}
+	if opts.OutputDir != "" && opts.Output == "" && !opts.DryRun {
+		dir, err := resolveIn(env.baseDir, opts.OutputDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve output directory: %w", err)
+		}
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			return nil, fmt.Errorf("failed to create output directory: %w", err)
+		}
+	}
+
+	prog.start()
+	for i, cell := range cells {
+		cell := cell // capture loop variable
+		plan := plans[i]
+		t := cell.Target
+		label := cell.label()
+		namingInput := inputFile
+		if env.part != nil {
+			cell.Vars = append(cell.Vars, env.part.variable())
+			namingInput = env.part.Source
+		}
+		g.Go(func() (err error) {
+			res := TargetResult{Target: cell.label(), Input: namingInput}
+			targetStart := time.Now()
+			var tlog *targetLog
+			if opts.LogDir != "" && !opts.DryRun {
+				tlog = &targetLog{}
+			}
+			keep := newIntermediates(opts, namingInput, cell.label())
+			defer func() {
+				res.Duration = time.Since(targetStart)
+				if err != nil {
+					res.Status = StatusFailed
+					res.Error = err.Error()
+				} else if res.Status == "" {
+					res.Status = StatusSuccess
+				}
+				if werr := tlog.write(opts.LogDir, res); werr != nil {
+					if opts.Logger != nil {
+						opts.Logger.Warn("failed to write target log", "target", res.Target, "error", werr)
+					} else {
+						fmt.Fprintf(os.Stderr, "Warning: target %s: %v\n", res.Target, werr)
+					}
+				}
+				prog.set(res.Target, res.Status)
+				resultsMu.Lock()
+				results = append(results, res)
+				resultsMu.Unlock()
+				if env.part != nil {
+					env.part.track(res.Output, label)
+				}
+			}()
+
+			if err := sem.Acquire(groupCtx, 1); err != nil {
 				return err
 			}
 			defer sem.Release(1)
+			prog.set(res.Target, statusRunning)
+
+			fmtStr, metaOut, buildTime, outputFile := plan.format, plan.meta, plan.buildTime, plan.output
+			res.Format = fmtStr
+			if plan.err != nil {
+				return plan.err
+			}
+			targetCtx := groupCtx
+			// The tools a reproducible target runs get its build time
+			targetExec := executor
+			if !buildTime.IsZero() {
+				targetExec = withCommandScope(executor, "", sourceDateEnv(buildTime))
+			}
+			sampling := opts.SamplePages > 0 && isPDFOutput(outputFile)
+			res.Output = outputFile
+
+			// Apply the CriticMarkup policy on a per-target copy of the input
+			targetInput := sourceFile
+			if policy := stringSetting(cfg, metaOut, "changes"); policy != "" {
+				criticFile, err := writeCriticCopy(sourceFile, policy, fmtStr, cfg.Author)
+				if err != nil {
+					return fmt.Errorf("target %s: %w", t, err)
+				}
+				if criticFile != "" {
+					defer keep.discard(criticFile, "changes"+filepath.Ext(criticFile))
+					targetInput = criticFile
+				}
+			}
+
+			// Keep only the first sections for a quick --sample-pages preview
+			if sampling {
+				sampleFile, total, err := writeSampleCopy(targetInput, opts.SamplePages)
+				if err != nil {
+					return fmt.Errorf("target %s: %w", t, err)
+				}
+				if sampleFile != "" {
+					defer keep.discard(sampleFile, "sample"+filepath.Ext(sampleFile))
+					targetInput = sampleFile
+					if opts.Logger != nil {
+						opts.Logger.Info("building a sample", "target", t, "sections", opts.SamplePages, "of", total)
+					} else if !opts.Quiet {
+						fmt.Printf("Sampling the first %d of %d sections for target %s\n", opts.SamplePages, total, t)
+					}
+				}
+			}
 
-			// Resolve Format
-			fmtStr := pandoc.NormalizeFormat(t)
-			// Check if t maps to an output entry in YAML
-			var metaOut map[string]interface{}
-
-			// Logic to find specific output config in YAML:
-			// logic similar to ruby resolve_target_format
-			if val, ok := cfg.OutputMap[t]; ok {
-				if m, ok := val.(map[string]interface{}); ok {
-					metaOut = m
-					if to, ok := m["to"].(string); ok && to != "" {
-						fmtStr = to
+			// Point links to other workspace documents at their outputs
+			if env.workspace != nil {
+				linkFile, unresolved, err := env.workspace.rewriteLinks(targetInput, inputFile, outputFile, t, fmtStr)
+				if err != nil {
+					return fmt.Errorf("target %s: %w", t, err)
+				}
+				for _, link := range unresolved {
+					if opts.Logger != nil {
+						opts.Logger.Warn("workspace link has no matching output", "target", t, "link", link)
+					} else {
+						fmt.Fprintf(os.Stderr, "Warning: %s: no %s output to link to for target %s\n", inputFile, link, t)
 					}
 				}
-			} else if val, ok := cfg.Generic[t]; ok {
-				if m, ok := val.(map[string]interface{}); ok {
-					metaOut = m
+				if linkFile != "" {
+					defer keep.discard(linkFile, "links"+filepath.Ext(linkFile))
+					targetInput = linkFile
 				}
 			}
 
-			if metaOut == nil {
-				metaOut = make(map[string]interface{})
+			// Render diagrams (external tools are not run in sandbox mode; a document
+			// streamed from stdin has none, or it would have been copied to a file)
+			targetSandboxed := sandboxed || isSandboxed(metaOut)
+			if kinds := diagramKinds(cfg, metaOut, fmtStr, opts, executor); len(kinds) > 0 && !targetSandboxed && stdin == nil {
+				diagramFile, err := renderDiagrams(targetCtx, targetInput, kinds)
+				if err != nil {
+					return fmt.Errorf("target %s: %w", t, err)
+				}
+				if diagramFile != "" {
+					defer keep.discard(diagramFile, "diagrams"+filepath.Ext(diagramFile))
+					targetInput = diagramFile
+				}
 			}
 
-			// Generate Output Filename
-			outputFile := opts.Output
-			if outputFile == "" {
-				outputFile = pandoc.GenerateOutputFilename(inputFile, cfg, metaOut, fmtStr)
+			// Downscale large images and extract media next to the output
+			media, hasMedia := resolveMedia(cfg, metaOut)
+			if hasMedia && !opts.DryRun {
+				mediaFile, err := optimizeImages(targetInput, media, mediaDirFor(media, outputFile))
+				if err != nil {
+					return fmt.Errorf("target %s: %w", t, err)
+				}
+				if mediaFile != "" {
+					defer keep.discard(mediaFile, "media"+filepath.Ext(mediaFile))
+					targetInput = mediaFile
+				}
 			}
 
-			// Resolve output file path
-			resolvedOutput, err := utils.ResolvePath(outputFile)
+			// pandoc may run in another directory; the paths panforge passes stay valid
+			workDir, err := resolveWorkingDir(opts, cfg, metaOut, inputFile)
 			if err != nil {
-				return fmt.Errorf("failed to resolve output file path: %w", err)
+				return fmt.Errorf("target %s: %w", t, configError(err))
 			}
-			outputFile = resolvedOutput
-
-			// Check overwrite
-			if _, err := os.Stat(outputFile); err == nil {
-				// If watch mode is on, we implicitly allow overwrite (otherwise it would block execution)
-				if !opts.Force && !opts.Watch && !isOverwriteAllowed(cfg, metaOut) {
-					// Ask for confirmation
-					promptMu.Lock()
-					overwrite := askForConfirmation(outputFile, os.Stdin, os.Stderr)
-					promptMu.Unlock()
-
-					if !overwrite {
-						// Log that we are skipping to avoid aborting other targets in the errgroup
-						if opts.Logger != nil {
-							opts.Logger.Warn("skipping target", "file", outputFile, "reason", "already exists and overwrite declined")
-						} else {
-							fmt.Fprintf(os.Stderr, "Skipping %s: file already exists and overwrite was declined\n", outputFile)
-						}
-						return nil
-					}
+			pandocExec := withCommandScope(targetExec, workDir, nil)
+			if workDir != "" && targetInput != stdinInput {
+				if abs, err := filepath.Abs(targetInput); err == nil {
+					targetInput = abs
 				}
 			}
 
 			// Build Command
-			pandocArgs := []string{inputFile}
+			pandocArgs := []string{targetInput}
 			pandocArgs = append(pandocArgs, "--to", fmtStr)
 			pandocArgs = append(pandocArgs, "--output", outputFile)
 
 			// Add YAML args
-			pandocArgs = append(pandocArgs, pandoc.GetArgs(metaOut)...)
+			metaArgs := append(readerArgs(cfg, metaOut, inputFile, inputFormat(opts, cfg, metaOut, inputFile)), pandoc.GetArgs(metaOut)...)
+			if env.workspace != nil {
+				metaArgs = append(metaArgs, env.workspace.pandocArgs(cfg, append(metaArgs, postArgs...), fmtStr)...)
+			}
+			if _, ok := metaOut["extract-media"]; hasMedia && !ok {
+				metaArgs = append(metaArgs, "--extract-media", mediaDirFor(media, outputFile))
+			}
+			metaArgs = append(metaArgs, cell.metadataArgs()...)
+			metaArgs = append(metaArgs, plan.metadata...)
+			metaArgs = append(metaArgs, resourcePathArgs(cfg, metaOut, fmtStr, inputFile, workDir, append(metaArgs, postArgs...))...)
+			if boolSetting(cfg, metaOut, "git-metadata") {
+				metaArgs = append(metaArgs, gitMetadataArgs(gitInfo(namingInput))...)
+			}
+			if env.part != nil {
+				partArgs, headerFile, err := env.part.numberingArgs(label, fmtStr, cfg, metaOut)
+				if err != nil {
+					return fmt.Errorf("target %s: %w", t, err)
+				}
+				if headerFile != "" {
+					defer keep.discard(headerFile, "part-header"+filepath.Ext(headerFile))
+				}
+				metaArgs = append(metaArgs, partArgs...)
+			}
+			if mermaid := resolveMermaid(cfg, metaOut); mermaid.Enabled && mermaid.Renderer == rendererFilter {
+				metaArgs = append(metaArgs, "--filter", "mermaid-filter")
+			}
+			if targetSandboxed {
+				var removed []string
+				metaArgs, removed = stripSandboxedArgs(metaArgs)
+				for _, r := range removed {
+					if opts.Logger != nil {
+						opts.Logger.Warn("sandbox: ignoring option", "target", t, "option", r)
+					} else {
+						fmt.Fprintf(os.Stderr, "Warning: sandbox: ignoring %s for target %s\n", r, t)
+					}
+				}
+				metaArgs = append(metaArgs, "--sandbox")
+			}
+			pandocArgs = append(pandocArgs, metaArgs...)
+
+			// Add the generated title page
+			var tpFile string
+			if tp := resolveTitlePage(cfg, metaOut); tp != nil {
+				var tpArgs []string
+				tpArgs, tpFile, err = titlePageArgs(tp, fmtStr)
+				if err != nil {
+					return fmt.Errorf("target %s: %w", t, err)
+				}
+				if tpFile != "" {
+					defer keep.discard(tpFile, "titlepage"+filepath.Ext(tpFile))
+				}
+				pandocArgs = append(pandocArgs, tpArgs...)
+			}
 
 			// Add CLI args that were passed after inputs or generically
 			// (Note: this logic is simplified compared to Ruby's careful flag stripping)
@@ -289,28 +920,216 @@ func Process(ctx context.Context, inputFile string, postArgs []string, opts opti
 				}
 			}
 			pandocArgs = append(pandocArgs, postArgs...)
+			if versionKnown {
+				var warnings []string
+				pandocArgs, warnings = pandoc.AdaptArgs(pandocArgs, version)
+				for _, w := range warnings {
+					if opts.Logger != nil {
+						opts.Logger.Warn("unsupported pandoc option", "target", t, "warning", w)
+					} else {
+						fmt.Fprintf(os.Stderr, "Warning: target %s: %s\n", t, w)
+					}
+				}
+			}
 
-			// Execute
-			// Improve logging to show quoted arguments
-			var quotedArgs []string
-			for _, arg := range pandocArgs {
-				if strings.Contains(arg, " ") || strings.Contains(arg, "\"") {
-					quotedArgs = append(quotedArgs, fmt.Sprintf("%q", arg))
-				} else {
-					quotedArgs = append(quotedArgs, arg)
+			postCmds, err := parsePostprocess(metaOut["postprocess"])
+			if err != nil {
+				return fmt.Errorf("target %s: %w", t, err)
+			}
+			var compress *compressConfig
+			var protect *protectConfig
+			if isPDFOutput(outputFile) {
+				if compress, err = resolveCompressPDF(cfg, metaOut); err != nil {
+					return fmt.Errorf("target %s: %w", t, configError(err))
+				}
+				if protect, err = resolvePDFProtect(cfg, metaOut); err != nil {
+					return fmt.Errorf("target %s: %w", t, configError(err))
+				}
+			}
+			// With `typst-compile`, pandoc writes Typst source and panforge runs typst itself
+			var typstCfg *typstConfig
+			var typstSource string
+			if isPDFOutput(outputFile) && pdfEngine(pandocArgs) == "typst" {
+				if typstCfg, err = resolveTypstCompile(cfg, metaOut, filepath.Dir(inputFile)); err != nil {
+					return fmt.Errorf("target %s: %w", t, configError(err))
+				}
+				if typstCfg != nil {
+					typstSource = typstSourcePath(inputFile, cell.label())
+				}
+			}
+			// With `latex-passes`, panforge builds a PDF from the .tex output itself
+			var latexPasses *latexPassesConfig
+			if pandoc.NormalizeFormat(fmtStr) == "latex" && strings.EqualFold(filepath.Ext(outputFile), ".tex") {
+				if latexPasses, err = resolveLatexPasses(cfg, metaOut); err != nil {
+					return fmt.Errorf("target %s: %w", t, configError(err))
+				}
+				if latexPasses != nil && !slices.Contains(pandocArgs, "--standalone") && !slices.Contains(pandocArgs, "-s") {
+					pandocArgs = append(pandocArgs, "--standalone")
 				}
 			}
-			cmdStr := "pandoc " + strings.Join(quotedArgs, " ")
 
-			// Log execution
-			// We use Info level. If --quiet is set, logger should be configured to Error level only.
-			if opts.Logger != nil {
-				opts.Logger.Info("executing command", "command", cmdStr)
-			} else if !opts.Quiet {
-				// Fallback if no logger validation
-				fmt.Printf("panforge calling: %s\n", cmdStr)
+			// Skip the conversion if nothing changed since the last successful build.
+			// The PDF of latex-passes and documents from stdin are not cached, so such
+			// targets always run.
+			var cacheKey string
+			if buildCache != nil && latexPasses == nil && stdin == nil {
+				keyArgs := append(append([]string(nil), pandocArgs[1:]...), postCmds...)
+				if i := slices.Index(keyArgs, tpFile); tpFile != "" && i >= 0 {
+					// The title page's temp name differs on every run; its content does not
+					if hash, err := cache.HashFile(tpFile); err == nil {
+						keyArgs[i] = "titlepage:" + hash
+					}
+				}
+				keyArgs = append(keyArgs, fmt.Sprintf("inline-css=%t", boolSetting(cfg, metaOut, "inline-css")), fmt.Sprintf("minify-html=%t", boolSetting(cfg, metaOut, "minify-html")))
+				if compress != nil {
+					keyArgs = append(keyArgs, fmt.Sprintf("compress-pdf=%s/%s", compress.Tool, compress.Preset))
+				}
+				if protect != nil {
+					keyArgs = append(keyArgs, fmt.Sprintf("pdf-protect=%+v", *protect))
+				}
+				if typstCfg != nil {
+					keyArgs = append(keyArgs, fmt.Sprintf("typst-compile=%+v", *typstCfg))
+				}
+				if !buildTime.IsZero() {
+					keyArgs = append(keyArgs, fmt.Sprintf("reproducible=%d", buildTime.Unix()))
+				}
+				if workDir != "" {
+					// Relative paths in the arguments resolve from the working directory
+					if rel, err := filepath.Rel(filepath.Dir(inputFile), workDir); err == nil {
+						keyArgs = append(keyArgs, "working-dir="+filepath.ToSlash(rel))
+					}
+				}
+				if key, err := buildCacheKey(targetInput, keyArgs, workDir, pandocVersion); err == nil {
+					cacheKey = key
+				}
+				if cacheKey != "" && buildCache.IsFresh(outputFile, cacheKey) {
+					if opts.Logger != nil {
+						opts.Logger.Info("up to date, skipping", "target", t, "file", outputFile)
+					} else if !opts.Quiet {
+						fmt.Printf("%s is up to date\n", outputFile)
+					}
+					res.Status = StatusUpToDate
+					countCache(1, 0, 0)
+					return nil
+				}
+			}
+
+			// Resolve a conflict with an existing output
+			if _, err := os.Stat(outputFile); err == nil {
+				policy, err := conflictPolicy(opts, cfg, metaOut)
+				if err != nil {
+					return fmt.Errorf("target %s: %w", t, configError(err))
+				}
+				switch policy {
+				case conflictPrompt, conflictSkip:
+					reason := "already exists"
+					if policy == conflictPrompt && promptMode == noInputFail {
+						return fmt.Errorf("target %s: %s already exists (use --force or --on-conflict to decide without a prompt)", t, outputFile)
+					}
+					if policy == conflictPrompt && promptMode == "" {
+						promptMu.Lock()
+						var overwrite bool
+						prog.pause(func() { overwrite = askForConfirmation(outputFile, os.Stdin, os.Stderr) })
+						promptMu.Unlock()
+						if overwrite {
+							break
+						}
+						reason = "already exists and overwrite was declined"
+					} else if policy == conflictPrompt {
+						reason = "already exists (not prompting without input)"
+					}
+					// Log that we are skipping to avoid aborting other targets in the errgroup
+					if opts.Logger != nil {
+						opts.Logger.Warn("skipping target", "file", outputFile, "reason", reason)
+					} else {
+						fmt.Fprintf(os.Stderr, "Skipping %s: file %s\n", outputFile, reason)
+					}
+					res.Status = StatusSkipped
+					return nil
+				case conflictRename:
+					outputFile = renamedOutput(outputFile)
+					pandocArgs = withOutput(pandocArgs, outputFile)
+					res.Output = outputFile
+					if opts.Logger != nil {
+						opts.Logger.Info("output exists, writing a new file", "target", t, "file", outputFile)
+					}
+				case conflictTrash:
+					if !opts.DryRun {
+						if err := utils.Trash(outputFile); err != nil {
+							return fmt.Errorf("target %s: %w", t, err)
+						}
+					}
+					if opts.Logger != nil {
+						opts.Logger.Info("moved previous output to the trash", "target", t, "file", outputFile)
+					} else if !opts.Quiet {
+						fmt.Printf("Moved %s to the trash\n", outputFile)
+					}
+				}
+			}
+
+			backupMode, err := backupSetting(opts, cfg, metaOut)
+			if err != nil {
+				return fmt.Errorf("target %s: %w", t, configError(err))
+			}
+			timeout, err := timeoutSetting(opts, cfg, metaOut)
+			if err != nil {
+				return fmt.Errorf("target %s: %w", t, configError(err))
+			}
+			logBackup := func(backup string) {
+				if backup == "" {
+					return
+				}
+				if opts.Logger != nil {
+					opts.Logger.Info("backed up previous output", "target", t, "file", outputFile, "backup", backup)
+				} else if !opts.Quiet {
+					fmt.Printf("Backed up %s to %s\n", outputFile, backup)
+				}
 			}
 
+			// Reuse an identical build from another location (branch, worktree) if one is cached
+			if buildCache != nil && cacheKey != "" && !opts.DryRun && atomicOutput(outputFile, fmtStr) {
+				// Like pandoc's output, the copy replaces the previous version only once it is complete
+				restoreFile, err := tempOutput(outputFile)
+				if err != nil {
+					return fmt.Errorf("target %s: %w", t, err)
+				}
+				defer func() { _ = os.Remove(restoreFile) }()
+				restored, err := buildCache.Restore(cacheKey, restoreFile)
+				if err != nil && opts.Logger != nil {
+					opts.Logger.Debug("failed to restore from build cache", "file", outputFile, "error", err)
+				}
+				if restored {
+					backup, err := commitOutput(restoreFile, outputFile, backupMode)
+					logBackup(backup)
+					if err != nil {
+						return fmt.Errorf("target %s: %w", t, err)
+					}
+					if opts.Logger != nil {
+						opts.Logger.Info("restored from cache", "target", t, "file", outputFile)
+					} else if !opts.Quiet {
+						fmt.Printf("%s restored from cache\n", outputFile)
+					}
+					rec := cache.Record{Key: cacheKey, Input: inputFile, Target: t, Output: outputFile}
+					if err := buildCache.Store(rec); err != nil && opts.Logger != nil {
+						opts.Logger.Debug("failed to update build cache", "file", outputFile, "error", err)
+					}
+					res.Status = StatusUpToDate
+					countCache(0, 1, 0)
+					return nil
+				}
+			}
+
+			// Execute
+			cmdArgs := pandocArgs
+			if typstCfg != nil {
+				cmdArgs = sourceArgs(pandocArgs, "typst", typstSource)
+			}
+			cmdStr := formatCommand("pandoc", cmdArgs)
+			res.Command = cmdStr
+
+			// Log execution
+			echoCommand(opts, cmdStr)
+
 			if logFile != nil {
 				logMu.Lock()
 				_, _ = fmt.Fprintf(logFile, "panforge calling: %s\n", cmdStr)
@@ -319,14 +1138,375 @@ func Process(ctx context.Context, inputFile string, postArgs []string, opts opti
 
 			// Use executor
 			// Note: Writing to os.Stdout/Stderr concurrently might interleave output
-			if err := executor.Run(ctx, "pandoc", pandocArgs, os.Stdout, os.Stderr); err != nil {
-				return fmt.Errorf("pandoc failed: %w", err)
+			// Stderr is also captured so pandoc's warnings can be reported per target.
+			// Pandoc writes to a temp file that replaces the output only on success,
+			// so a killed or failed run leaves the previous output intact.
+			runArgs := pandocArgs
+			var tmpOutput string
+			if !opts.DryRun && atomicOutput(outputFile, fmtStr) {
+				if tmpOutput, err = tempOutput(outputFile); err != nil {
+					return fmt.Errorf("target %s: %w", t, err)
+				}
+				defer func() { _ = os.Remove(tmpOutput) }()
+				runArgs = withOutput(pandocArgs, tmpOutput)
+			} else if !opts.DryRun {
+				backup, err := backupOutput(outputFile, backupMode)
+				if err != nil {
+					return fmt.Errorf("target %s: %w", t, err)
+				}
+				logBackup(backup)
+			}
+			pdfFile := outputFile
+			if tmpOutput != "" {
+				pdfFile = tmpOutput
+			}
+			if typstCfg != nil {
+				runArgs = sourceArgs(runArgs, "typst", typstSource)
+				if !opts.DryRun {
+					defer keep.discard(typstSource, strings.TrimSuffix(filepath.Base(outputFile), filepath.Ext(outputFile))+".typ")
+				}
+			}
+			procOut, err := resolveSubprocessOutput(opts, cfg, metaOut, cell.label(), env.baseDir)
+			if err != nil {
+				return fmt.Errorf("target %s: %w", t, configError(err))
+			}
+			if len(cells) > 1 {
+				procOut.Prefix = cell.label()
+			}
+			var stderr bytes.Buffer
+			stdoutW, stderrW, err := procOut.writers(&stderr)
+			if err != nil {
+				return fmt.Errorf("target %s: %w", t, err)
+			}
+			stdoutW, stderrW = tlog.writers(stdoutW, stderrW)
+			var timedOut bool
+			runErr := retry(targetCtx, opts.Retries, func() error {
+				runCtx, cancel := withTimeout(targetCtx, timeout)
+				defer cancel()
+				var err error
+				if stdin != nil {
+					err = runWithStdin(runCtx, pandocExec, "pandoc", runArgs, bytes.NewReader(stdin), stdoutW, stderrW)
+				} else {
+					err = pandocExec.Run(runCtx, "pandoc", runArgs, stdoutW, stderrW)
+				}
+				tlog.attempt(err)
+				timedOut = errors.Is(runCtx.Err(), context.DeadlineExceeded)
+				return err
+			}, func(n int, err error, delay time.Duration) {
+				if opts.Logger != nil {
+					opts.Logger.Warn("pandoc failed, retrying", "target", cell.label(), "retry", n, "retries", opts.Retries, "delay", delay, "error", err)
+				} else {
+					fmt.Fprintf(os.Stderr, "Warning: target %s: pandoc failed (%v), retry %d of %d in %s\n", cell.label(), err, n, opts.Retries, delay)
+				}
+				tlog.note("attempt failed (%v), retry %d of %d", err, n, opts.Retries)
+				// Only the last attempt's output is reported
+				stderr.Reset()
+				procOut.buf.Reset()
+			})
+			var typstErr error
+			if runErr == nil && typstCfg != nil {
+				runCtx, cancel := withTimeout(targetCtx, timeout)
+				typstErr = compileTypst(runCtx, typstCfg, typstSource, pdfFile, opts, targetExec, stdoutW, stderrW)
+				cancel()
+			}
+			prog.pause(func() { procOut.finish(cell.label(), runErr != nil || typstErr != nil) })
+			res.Diagnostics = attributeDiagnostics(pandoc.ParseDiagnostics(stderr.String(), fmtStr), inputFile)
+			res.stderr = tail(stderr.String(), maxRecordedStderr)
+			if runErr != nil && timedOut {
+				return fmt.Errorf("target %s: pandoc timed out after %s and was stopped", cell.label(), timeout)
+			}
+			if runErr != nil {
+				// Explain well-known PDF engine errors, which are buried in pandoc's output
+				if hints := pandoc.FailureHints(stderr.String()); len(hints) > 0 {
+					return fmt.Errorf("pandoc failed: %w (hint: %s)", runErr, strings.Join(hints, "; hint: "))
+				}
+				return fmt.Errorf("pandoc failed: %w", runErr)
+			}
+			if typstErr != nil {
+				return fmt.Errorf("target %s: %w", t, typstErr)
+			}
+			if keep.keeping() && isPDFOutput(outputFile) && typstCfg == nil {
+				if err := keepPDFSource(targetCtx, keep, pandocArgs, fmtStr, outputFile, pandocExec); err != nil {
+					if opts.Logger != nil {
+						opts.Logger.Warn("failed to keep the PDF engine's source", "target", t, "error", err)
+					} else {
+						fmt.Fprintf(os.Stderr, "Warning: target %s: failed to keep the PDF engine's source: %v\n", t, err)
+					}
+				}
+			}
+			if tmpOutput != "" {
+				backup, err := commitOutput(tmpOutput, outputFile, backupMode)
+				logBackup(backup)
+				if err != nil {
+					return fmt.Errorf("target %s: %w", t, err)
+				}
+			}
+			if latexPasses != nil {
+				// The engines are chatty; their output is only shown if a pass fails
+				var texOut bytes.Buffer
+				runCtx, cancel := withTimeout(targetCtx, timeout)
+				pdf, err := runLatexPasses(runCtx, latexPasses, latexPassesEngine(latexPasses, pandocArgs), outputFile, opts, targetExec, keep, &texOut, &texOut)
+				cancel()
+				if err != nil {
+					prog.pause(func() { _, _ = os.Stderr.WriteString(tail(texOut.String(), 4<<10)) })
+					if hints := pandoc.FailureHints(texOut.String()); len(hints) > 0 {
+						return fmt.Errorf("target %s: %w (hint: %s)", t, err, strings.Join(hints, "; hint: "))
+					}
+					return fmt.Errorf("target %s: %w", t, err)
+				}
+				if opts.Logger != nil {
+					opts.Logger.Info("built PDF with latex-passes", "target", t, "file", pdf, "passes", latexPasses.Passes)
+				} else if !opts.Quiet && !opts.DryRun {
+					fmt.Printf("Built %s with %d LaTeX passes\n", pdf, latexPasses.Passes)
+				}
+			}
+			if err := runHTMLPostprocess(cfg, metaOut, fmtStr, inputFile, outputFile, opts); err != nil {
+				return fmt.Errorf("target %s: %w", t, err)
+			}
+			if compress != nil {
+				if err := compressPDF(targetCtx, compress, outputFile, opts, targetExec); err != nil {
+					return fmt.Errorf("target %s: %w", t, err)
+				}
+			}
+			// Encrypt last: compressing would drop the encryption
+			if protect != nil {
+				if err := protectPDF(targetCtx, protect, outputFile, opts, targetExec); err != nil {
+					return fmt.Errorf("target %s: %w", t, err)
+				}
+			}
+			if err := runPostprocess(targetCtx, postCmds, outputFile, opts, targetExec, targetSandboxed); err != nil {
+				return fmt.Errorf("target %s: %w", t, err)
+			}
+			if patterns, auto := resolveAssets(cfg, metaOut, fmtStr); (len(patterns) > 0 || auto) && !opts.DryRun {
+				if auto {
+					patterns = append(patterns, referencedAssets(cfg, metaOut, inputFile)...)
+				}
+				copied, warnings, err := copyAssets(inputFile, outputFile, patterns)
+				for _, w := range warnings {
+					if opts.Logger != nil {
+						opts.Logger.Warn("skipping asset", "target", t, "reason", w)
+					} else {
+						fmt.Fprintf(os.Stderr, "Warning: assets: %s (target %s)\n", w, t)
+					}
+				}
+				if err != nil {
+					return fmt.Errorf("target %s: %w", t, err)
+				}
+				if copied > 0 && opts.Logger != nil {
+					opts.Logger.Debug("copied assets", "target", t, "files", copied)
+				}
+			}
+
+			if buildCache != nil && cacheKey != "" && !opts.DryRun {
+				countCache(0, 0, 1)
+				rec := cache.Record{Key: cacheKey, Input: inputFile, Target: t, Output: outputFile}
+				if err := buildCache.Store(rec); err != nil && opts.Logger != nil {
+					opts.Logger.Debug("failed to update build cache", "file", outputFile, "error", err)
+				}
 			}
 			return nil
 		})
 	}
 
-	return g.Wait()
+	err = g.Wait()
+	prog.stop()
+	if opts.KeepGoing && err != nil && ctx.Err() == nil {
+		err = targetErrors(results)
+	}
+	if stream != nil && !opts.DryRun {
+		err = stream.finish(results, err)
+	}
+
+	if buildCache != nil && !opts.DryRun {
+		if serr := buildCache.AddStats(cacheStats); serr != nil && opts.Logger != nil {
+			opts.Logger.Debug("failed to update cache stats", "error", serr)
+		}
+	}
+
+	if run != nil && ownRun && !opts.DryRun {
+		finishBuildRun(run, opts)
+	}
+
+	if env.part != nil {
+		// The book reports once, after its last part
+		return results, err
+	}
+	return results, reportBuild(ctx, inputFile, cfg, opts, env, sandboxed, results, start, err)
+}
+
+// reportBuild writes the manifest and archive of a build and sends its webhook.
+//
+// Parameters:
+//   - `ctx`: context for cancellation
+//   - `inputFile`: the document
+//   - `cfg`: the document config
+//   - `opts`: runtime options
+//   - `env`: the shared build state
+//   - `sandboxed`: whether the document or the defaults enable sandbox mode
+//   - `results`: the per-target results
+//   - `start`: when the build started
+//   - `err`: the build error, if any
+//
+// Returns:
+//   - error: err, or the first error writing the manifest or archive
+func reportBuild(ctx context.Context, inputFile string, cfg *config.Config, opts options.Options, env processEnv, sandboxed bool, results []TargetResult, start time.Time, err error) error {
+	if path := manifestSetting(opts, cfg, env.baseDir); path != "" {
+		if merr := finishManifest(path, results, time.Since(start), opts); merr != nil && err == nil {
+			err = merr
+		}
+	}
+	if path := archiveSetting(opts, cfg, env.baseDir); path != "" {
+		if aerr := finishArchive(path, results, err, opts); aerr != nil && err == nil {
+			err = aerr
+		}
+	}
+
+	if hook, ok := cfg.Generic["webhook"]; ok {
+		event := newBuildEvent(inputFile, results, time.Since(start), err)
+		if opts.DryRun {
+			if opts.Logger != nil {
+				opts.Logger.Info("skipping webhook in dry-run mode")
+			}
+		} else if sandboxed {
+			if opts.Logger != nil {
+				opts.Logger.Info("skipping webhook in sandbox mode")
+			}
+		} else if werr := sendWebhook(ctx, hook, event); werr != nil {
+			if opts.Logger != nil {
+				opts.Logger.Warn("webhook failed", "error", werr)
+			} else {
+				fmt.Fprintf(os.Stderr, "Warning: webhook failed: %v\n", werr)
+			}
+		}
+	}
+
+	return err
+}
+
+// mergeConfig fills settings the document does not set from a defaults config. Output
+// formats and top-level keys are merged one by one; the document's values win.
+//
+// Parameters:
+//   - `cfg`: the document config, updated in place
+//   - `defaults`: the defaults (may be nil)
+func mergeConfig(cfg, defaults *config.Config) {
+	if defaults == nil {
+		return
+	}
+	if cfg.Title == "" {
+		cfg.Title = defaults.Title
+	}
+	if cfg.FilenameTemplate == "" {
+		cfg.FilenameTemplate = defaults.FilenameTemplate
+	}
+	if cfg.SlugifyFilename == nil {
+		cfg.SlugifyFilename = defaults.SlugifyFilename
+	}
+	if cfg.OutputMap == nil && defaults.OutputMap != nil {
+		// Copied so later merges never write into the defaults
+		cfg.OutputMap = make(map[string]interface{}, len(defaults.OutputMap))
+	}
+	for k, v := range defaults.OutputMap {
+		if _, exists := cfg.OutputMap[k]; !exists {
+			cfg.OutputMap[k] = v
+		}
+	}
+	if cfg.Generic == nil {
+		cfg.Generic = make(map[string]interface{})
+	}
+	for k, v := range defaults.Generic {
+		if _, exists := cfg.Generic[k]; !exists {
+			cfg.Generic[k] = v
+		}
+	}
+}
+
+// resolveTarget finds the pandoc format and the format-specific config for a target.
+//
+// Parameters:
+//   - `cfg`: the global config
+//   - `t`: the target name
+//
+// Returns:
+//   - string: the pandoc output format
+//   - map[string]interface{}: the target's config (never nil)
+func resolveTarget(cfg *config.Config, t string) (string, map[string]interface{}) {
+	fmtStr := pandoc.NormalizeFormat(t)
+	// Check if t maps to an output entry in YAML
+	var metaOut map[string]interface{}
+
+	// Logic to find specific output config in YAML:
+	// logic similar to ruby resolve_target_format
+	if val, ok := cfg.OutputMap[t]; ok {
+		if m, ok := val.(map[string]interface{}); ok {
+			metaOut = m
+			if to, ok := m["to"].(string); ok && to != "" {
+				fmtStr = to
+			}
+		}
+	} else if val, ok := cfg.Generic[t]; ok {
+		if m, ok := val.(map[string]interface{}); ok {
+			metaOut = m
+		}
+	}
+
+	if metaOut == nil {
+		metaOut = make(map[string]interface{})
+	}
+	// The user's options for every target of the format go beneath the target's own;
+	// checkFormatDefaults reports a file that cannot be read
+	if defaults, err := config.FormatDefaults(fmtStr); err == nil && len(defaults) > 0 {
+		metaOut = config.MergeOptions(metaOut, defaults)
+	}
+	return fmtStr, metaOut
+}
+
+// checkFormatDefaults reads the format defaults of each target, which resolveTarget
+// merges into its options, so an invalid file fails the run instead of being ignored.
+//
+// Parameters:
+//   - `cfg`: the merged configuration
+//   - `targets`: the targets of the run
+//
+// Returns:
+//   - error: if an options file cannot be read or parsed
+func checkFormatDefaults(cfg *config.Config, targets []string) error {
+	for _, t := range targets {
+		fmtStr, _ := resolveTarget(cfg, t)
+		if _, err := config.FormatDefaults(fmtStr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newSemaphore creates the limit on concurrent pandoc processes.
+//
+// Parameters:
+//   - `concurrency`: the --concurrency value; 0 or less uses the number of CPUs
+func newSemaphore(concurrency int) *semaphore.Weighted {
+	limit := int64(concurrency)
+	if limit <= 0 {
+		limit = int64(runtime.NumCPU())
+	}
+	return semaphore.NewWeighted(limit)
+}
+
+// formatCommand renders a command line for logging, quoting arguments that contain spaces or quotes.
+//
+// Parameters:
+//   - `name`: the command name
+//   - `args`: the command arguments
+func formatCommand(name string, args []string) string {
+	quotedArgs := []string{name}
+	for _, arg := range args {
+		if strings.Contains(arg, " ") || strings.Contains(arg, "\"") {
+			quotedArgs = append(quotedArgs, fmt.Sprintf("%q", arg))
+		} else {
+			quotedArgs = append(quotedArgs, arg)
+		}
+	}
+	return strings.Join(quotedArgs, " ")
 }
 
 // parseArgs determines the input file from the command line arguments.
@@ -387,10 +1567,176 @@ func DetermineTargets(opts options.Options, cfg *config.Config) []string {
 		return targets
 	}
 
-	// Fallback to auto detection or default
+	// Fallback to --default-to (PANFORGE_DEFAULT_TO) or html
+	if len(opts.DefaultTargets) > 0 {
+		return opts.DefaultTargets
+	}
 	return []string{"html"}
 }
 
+// cacheFileFlags are pandoc options whose value names a file the output depends on.
+// The cache key covers the file's content, so editing a template or bibliography
+// rebuilds the targets that use it.
+var cacheFileFlags = map[string]bool{
+	"--template": true, "--bibliography": true, "--csl": true, "--citation-abbreviations": true,
+	"--css": true, "-c": true, "--reference-doc": true, "--metadata-file": true,
+	"--include-in-header": true, "-H": true, "--include-before-body": true, "-B": true,
+	"--include-after-body": true, "-A": true, "--defaults": true, "-d": true,
+	"--lua-filter": true, "-L": true, "--filter": true, "-F": true,
+	"--syntax-definition": true, "--highlight-style": true, "--abbreviations": true,
+	"--epub-cover-image": true, "--epub-metadata": true, "--epub-embed-font": true,
+}
+
+// buildCacheKey computes the incremental build key for one target. The key depends on
+// content rather than location: the output path is reduced to its extension, files
+// named by the arguments count by content, and absolute paths of those files and of
+// `--extract-media` are made relative to the input and the output, so the same document
+// built on another branch or in another worktree produces the same key.
+//
+// Parameters:
+//   - `input`: the file handed to pandoc
+//   - `args`: the pandoc arguments, excluding the input file
+//   - `dir`: the directory pandoc resolves relative paths from ("" for the working directory)
+//   - `pandocVersion`: the pandoc version line
+func buildCacheKey(input string, args []string, dir, pandocVersion string) (string, error) {
+	inputHash, err := cache.HashFile(input)
+	if err != nil {
+		return "", err
+	}
+	outputDir := ""
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == "--output" || args[i] == "-o" {
+			outputDir = filepath.Dir(args[i+1])
+		}
+	}
+
+	parts := []string{inputHash, pandocVersion}
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := args[i], "", false
+		if strings.HasPrefix(name, "--") {
+			name, value, hasValue = strings.Cut(name, "=")
+		}
+		isFile := cacheFileFlags[name]
+		if !isFile && name != "--extract-media" && name != "--output" && name != "-o" {
+			parts = append(parts, args[i])
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				parts = append(parts, args[i])
+				continue
+			}
+			i++
+			value = args[i]
+		}
+		switch {
+		case name == "--output" || name == "-o":
+			parts = append(parts, name, "*"+filepath.Ext(value))
+		case name == "--extract-media":
+			parts = append(parts, name, relativeTo(outputDir, value))
+		default:
+			parts = append(parts, name, relativeTo(filepath.Dir(input), value))
+			path := value
+			if !filepath.IsAbs(path) && dir != "" {
+				path = filepath.Join(dir, path)
+			}
+			if hash, err := cache.HashFile(path); err == nil {
+				parts = append(parts, "sha256:"+hash)
+			}
+		}
+	}
+	return cache.ComputeKey(parts...), nil
+}
+
+// relativeTo expresses an absolute path relative to base, for location-independent
+// cache keys; relative paths and paths on another volume are returned as they are.
+//
+// Parameters:
+//   - `base`: the directory to express the path against
+//   - `path`: the path
+func relativeTo(base, path string) string {
+	if base == "" || !filepath.IsAbs(path) {
+		return path
+	}
+	if rel, err := filepath.Rel(base, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// boolSetting looks up a boolean option on the target first, then in the global config.
+//
+// Parameters:
+//   - `cfg`: the global config
+//   - `metaOut`: the format-specific config
+//   - `key`: the option name
+func boolSetting(cfg *config.Config, metaOut map[string]interface{}, key string) bool {
+	if v, ok := metaOut[key].(bool); ok {
+		return v
+	}
+	v, _ := cfg.Generic[key].(bool)
+	return v
+}
+
+// stringSetting looks up a string option on the target first, then in the global config.
+//
+// Parameters:
+//   - `cfg`: the global config
+//   - `metaOut`: the format-specific config
+//   - `key`: the option name
+func stringSetting(cfg *config.Config, metaOut map[string]interface{}, key string) string {
+	if v, ok := metaOut[key].(string); ok && v != "" {
+		return v
+	}
+	if v, ok := cfg.Generic[key].(string); ok {
+		return v
+	}
+	return ""
+}
+
+// writeCriticCopy writes a copy of the input with CriticMarkup resolved for one target.
+// The copy is placed next to the input so relative resource paths keep working.
+//
+// Parameters:
+//   - `inputFile`: the source document
+//   - `policy`: the `changes` policy (accept, reject, show)
+//   - `fmtStr`: the target pandoc format
+//   - `author`: the author attributed to tracked changes
+//
+// Returns:
+//   - string: the path of the copy, or "" if the input contains no CriticMarkup
+//   - error: any error reading, transforming, or writing the copy
+func writeCriticCopy(inputFile, policy, fmtStr, author string) (string, error) {
+	//nolint:gosec // G304: reading the input file is intended
+	data, err := os.ReadFile(inputFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read input for CriticMarkup: %w", err)
+	}
+	content := string(data)
+	if !preprocess.HasCriticMarkup(content) {
+		return "", nil
+	}
+	content, err = preprocess.ApplyCriticMarkup(content, policy, fmtStr, author)
+	if err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(inputFile), ".panforge-changes-*"+filepath.Ext(inputFile))
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	if _, err := tmp.WriteString(content); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to close temp file: %w", err)
+	}
+	return tmp.Name(), nil
+}
+
 // isOverwriteAllowed checks if overwrite is explicitly allowed in configuration.
 //
 // Parameters:
@@ -458,34 +1804,19 @@ func GetRequiredTools(inputFile string, opts options.Options) ([]string, error)
 	}
 
 	// We use LoadConfig logic. Capture error but proceed if possible (logic similar to Process)
-	_, cfg, err := config.LoadConfig(inputFile)
+	cfg, err := loadDocumentConfig(inputFile, "")
 	if err != nil {
 		// If we can't load config, we can't determine specific tools, just return base
 		return required, nil
 	}
 
-	// Load default config to fill in gaps if possible, mostly for output map
-	_, defaultCfg, _ := config.LoadDefaultConfig("default")
-	if defaultCfg != nil {
-		if cfg.OutputMap == nil {
-			cfg.OutputMap = defaultCfg.OutputMap
-		} else {
-			for k, v := range defaultCfg.OutputMap {
-				if _, exists := cfg.OutputMap[k]; !exists {
-					cfg.OutputMap[k] = v
-				}
-			}
-		}
-		if cfg.Generic == nil {
-			cfg.Generic = make(map[string]interface{})
-		}
-		if defaultCfg.Generic != nil {
-			for k, v := range defaultCfg.Generic {
-				if _, exists := cfg.Generic[k]; !exists {
-					cfg.Generic[k] = v
-				}
-			}
-		}
+	// Load the project and default configs to fill in gaps if possible, mostly for output map
+	_, projectCfg, _ := config.LoadProjectConfig(inputFile)
+	mergeConfig(cfg, projectCfg)
+	_, defaultCfg, _ := loadDefaultConfig(opts.Config)
+	mergeConfig(cfg, defaultCfg)
+	if err := config.ResolveTargetExtends(cfg); err != nil {
+		return nil, err
 	}
 
 	targets := DetermineTargets(opts, cfg)
@@ -493,23 +1824,8 @@ func GetRequiredTools(inputFile string, opts options.Options) ([]string, error)
 	hasTypst := false
 
 	for _, t := range targets {
-		// Normalize format
-		fmtStr := pandoc.NormalizeFormat(t)
-
-		// Check for overrides in config to fully resolve format (e.g. target "paper" might be "latex" or "typst")
-		var metaOut map[string]interface{}
-		if val, ok := cfg.OutputMap[t]; ok {
-			if m, ok := val.(map[string]interface{}); ok {
-				metaOut = m
-				if to, ok := m["to"].(string); ok && to != "" {
-					fmtStr = to
-				}
-			}
-		} else if val, ok := cfg.Generic[t]; ok {
-			if m, ok := val.(map[string]interface{}); ok {
-				metaOut = m
-			}
-		}
+		// Fully resolve the format (e.g. target "paper" might be "latex" or "typst")
+		fmtStr, metaOut := resolveTarget(cfg, t)
 
 		if fmtStr == "typst" {
 			hasTypst = true
@@ -544,6 +1860,34 @@ func GetRequiredTools(inputFile string, opts options.Options) ([]string, error)
 				required = append(required, engine)
 			}
 		}
+		if cc, err := resolveCompressPDF(cfg, metaOut); err == nil && cc != nil && (fmtStr == "pdf" || fmtStr == "beamer") {
+			for _, tool := range pdfTools(cc) {
+				if !contains(required, tool) {
+					required = append(required, tool)
+				}
+			}
+		}
+		if pc, err := resolvePDFProtect(cfg, metaOut); err == nil && pc != nil && (fmtStr == "pdf" || fmtStr == "beamer") && !contains(required, "qpdf") {
+			required = append(required, "qpdf")
+		}
+		if lp, err := resolveLatexPasses(cfg, metaOut); err == nil && lp != nil && fmtStr == "latex" {
+			// The target's pdf-engine was added above
+			var tools []string
+			if lp.Engine != "" {
+				tools = append(tools, lp.Engine)
+			}
+			if lp.Bibliography == bibBiber || lp.Bibliography == bibBibTeX {
+				tools = append(tools, lp.Bibliography)
+			}
+			if lp.Index == "true" {
+				tools = append(tools, "makeindex")
+			}
+			for _, tool := range tools {
+				if !contains(required, tool) {
+					required = append(required, tool)
+				}
+			}
+		}
 	}
 
 	// "pdf" format in pandoc implies using a pdf-engine.
@@ -556,6 +1900,16 @@ func GetRequiredTools(inputFile string, opts options.Options) ([]string, error)
 		required = append(required, "typst")
 	}
 
+	// Diagram renderers, if the document contains diagram blocks
+	//nolint:gosec // G304: reading the input file is intended
+	if data, err := os.ReadFile(inputFile); err == nil {
+		for _, tool := range diagramTools(cfg, string(data)) {
+			if !contains(required, tool) {
+				required = append(required, tool)
+			}
+		}
+	}
+
 	return required, nil
 }
 
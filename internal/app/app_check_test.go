@@ -3,6 +3,7 @@ package app
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/rapjul/panforge/internal/options"
@@ -103,3 +104,45 @@ outputs:
 		})
 	}
 }
+
+func TestPandocPath(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", dir)
+	if got := PandocPath(options.Options{}); got != "pandoc" {
+		t.Errorf("expected pandoc from the PATH without a setting, got %q", got)
+	}
+
+	dataDir := filepath.Join(dir, "panforge")
+	_ = os.MkdirAll(dataDir, 0750)
+	_ = os.WriteFile(filepath.Join(dataDir, "default.yaml"), []byte("pandoc-path: bin/pandoc\n"), 0600)
+	if got := PandocPath(options.Options{}); got != filepath.Join(dataDir, "bin", "pandoc") {
+		t.Errorf("expected the config's pandoc relative to it, got %q", got)
+	}
+	if got := PandocPath(options.Options{PandocPath: "/opt/pandoc"}); got != "/opt/pandoc" {
+		t.Errorf("expected --pandoc-path to win, got %q", got)
+	}
+}
+
+func TestLoadDefaultConfig_Config(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", dir)
+	dataDir := filepath.Join(dir, "panforge")
+	_ = os.MkdirAll(dataDir, 0750)
+	_ = os.WriteFile(filepath.Join(dataDir, "default.yaml"), []byte("pandoc-path: /usr/bin/pandoc\n"), 0600)
+	_ = os.WriteFile(filepath.Join(dataDir, "work.yaml"), []byte("pandoc-path: /opt/work/pandoc\n"), 0600)
+	other := filepath.Join(dir, "client.yaml")
+	_ = os.WriteFile(other, []byte("pandoc-path: /opt/client/pandoc\n"), 0600)
+
+	if got := PandocPath(options.Options{Config: "work"}); got != "/opt/work/pandoc" {
+		t.Errorf("expected the named config, got %q", got)
+	}
+	if got := PandocPath(options.Options{Config: other}); got != "/opt/client/pandoc" {
+		t.Errorf("expected the config file, got %q", got)
+	}
+	if _, _, err := loadDefaultConfig("missing"); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("expected an error for a missing named config, got %v", err)
+	}
+	if _, _, err := loadDefaultConfig(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("expected an error for a missing config file")
+	}
+}
@@ -4,12 +4,14 @@ import (
 	"context"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/spf13/cobra"
 
 	"github.com/rapjul/panforge/internal/app"
+	"github.com/rapjul/panforge/internal/cache"
 	"github.com/rapjul/panforge/internal/options"
 )
 
@@ -27,8 +29,9 @@ func (m *MockExecutor) Run(ctx context.Context, name string, args []string, stdo
 
 // TestExecutor captures the command execution details
 type TestExecutor struct {
-	CapturedName string
-	CapturedArgs []string
+	CapturedName  string
+	CapturedArgs  []string
+	CapturedStdin string
 }
 
 func (t *TestExecutor) Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
@@ -37,6 +40,13 @@ func (t *TestExecutor) Run(ctx context.Context, name string, args []string, stdo
 	return nil
 }
 
+func (t *TestExecutor) RunStdin(ctx context.Context, name string, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	data, err := io.ReadAll(stdin)
+	t.CapturedStdin = string(data)
+	_ = t.Run(ctx, name, args, stdout, stderr)
+	return err
+}
+
 func TestRun_PostArgs_ToFlagConversion(t *testing.T) {
 	// Create a temp file to simulate input
 	// Use os.WriteFile to specify permissions and ensure content
@@ -94,6 +104,7 @@ func TestRun_PostArgs_ToFlagConversion(t *testing.T) {
 
 func TestRun_Stdin(t *testing.T) {
 	// Setup
+	t.Chdir(t.TempDir())
 	executor := &TestExecutor{}
 	opts := options.Options{
 		Targets: []string{"html"}, // Minimal target
@@ -119,14 +130,15 @@ func TestRun_Stdin(t *testing.T) {
 		t.Fatalf("app.Run failed with stdin: %v", err)
 	}
 
-	// Verify executor was called with a temp file
+	// Verify the document was streamed to pandoc on stdin
 	if len(executor.CapturedArgs) == 0 {
 		t.Fatal("Executor was not called")
 	}
-	firstArg := executor.CapturedArgs[0]
-	// It should be a temp file path (containing "panforge-stdin-")
-	if !strings.Contains(firstArg, "panforge-stdin-") {
-		t.Errorf("Expected first arg to be a temp file path (containing 'panforge-stdin-'), got: %s", firstArg)
+	if firstArg := executor.CapturedArgs[0]; firstArg != "-" {
+		t.Errorf("Expected pandoc to read stdin (-), got: %s", firstArg)
+	}
+	if executor.CapturedStdin != inputContent {
+		t.Errorf("Expected the document on stdin, got: %q", executor.CapturedStdin)
 	}
 }
 
@@ -151,3 +163,119 @@ func TestRun_ExecutionError(t *testing.T) {
 		t.Error("Expected app.Run to fail when executor fails, but it succeeded")
 	}
 }
+
+// WritingExecutor simulates pandoc by writing the --output file and counting calls
+type WritingExecutor struct {
+	Calls int
+}
+
+func (w *WritingExecutor) Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	w.Calls++
+	for i, arg := range args {
+		if arg == "--output" && i+1 < len(args) {
+			return os.WriteFile(args[i+1], []byte("converted"), 0600)
+		}
+	}
+	return nil
+}
+
+func TestProcess_IncrementalBuild(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(tmpDir, "data"))
+
+	input := filepath.Join(tmpDir, "doc.md")
+	_ = os.WriteFile(input, []byte("---\ntitle: Doc\noutput:\n  html:\n    output: "+filepath.Join(tmpDir, "doc.html")+"\n---\n# Doc\n"), 0600)
+
+	executor := &WritingExecutor{}
+	opts := options.Options{Force: true}
+	for i := 0; i < 2; i++ {
+		if err := app.Process(context.Background(), input, nil, opts, executor); err != nil {
+			t.Fatalf("Process run %d failed: %v", i+1, err)
+		}
+	}
+	if executor.Calls != 1 {
+		t.Errorf("expected unchanged input to be skipped, pandoc ran %d times", executor.Calls)
+	}
+
+	// --no-cache always rebuilds
+	opts.NoCache = true
+	if err := app.Process(context.Background(), input, nil, opts, executor); err != nil {
+		t.Fatalf("Process with --no-cache failed: %v", err)
+	}
+	if executor.Calls != 2 {
+		t.Errorf("expected --no-cache to rebuild, pandoc ran %d times", executor.Calls)
+	}
+
+	// Editing the input invalidates the cache
+	opts.NoCache = false
+	_ = os.WriteFile(input, []byte("---\ntitle: Doc\noutput:\n  html:\n    output: "+filepath.Join(tmpDir, "doc.html")+"\n---\n# Changed\n"), 0600)
+	if err := app.Process(context.Background(), input, nil, opts, executor); err != nil {
+		t.Fatalf("Process after edit failed: %v", err)
+	}
+	if executor.Calls != 3 {
+		t.Errorf("expected edited input to rebuild, pandoc ran %d times", executor.Calls)
+	}
+}
+
+func TestProcess_CacheAcrossWorktrees(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(tmpDir, "data"))
+
+	// The same document checked out in two worktrees
+	content := []byte("---\ntitle: Doc\noutput:\n  html:\n    output: doc.html\n---\n# Doc\n")
+	var inputs []string
+	for _, tree := range []string{"main", "feature"} {
+		dir := filepath.Join(tmpDir, tree)
+		_ = os.MkdirAll(dir, 0750)
+		input := filepath.Join(dir, "doc.md")
+		_ = os.WriteFile(input, content, 0600)
+		inputs = append(inputs, input)
+	}
+
+	executor := &WritingExecutor{}
+	opts := options.Options{Force: true, Quiet: true}
+	for _, input := range inputs {
+		t.Chdir(filepath.Dir(input))
+		if err := app.Process(context.Background(), input, nil, opts, executor); err != nil {
+			t.Fatalf("Process(%s) failed: %v", input, err)
+		}
+	}
+	if executor.Calls != 1 {
+		t.Errorf("expected the second worktree to be restored from cache, pandoc ran %d times", executor.Calls)
+	}
+	if data, err := os.ReadFile(filepath.Join(tmpDir, "feature", "doc.html")); err != nil || string(data) != "converted" {
+		t.Errorf("restored output = %q, %v", data, err)
+	}
+
+	st, err := cache.New(cache.DefaultDir()).ReadStats()
+	if err != nil || st.Misses != 1 || st.Restored != 1 {
+		t.Errorf("unexpected stats %+v, %v", st, err)
+	}
+}
+
+func TestProcess_DryRunWithoutPandoc(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(tmpDir, "data"))
+	t.Setenv("PATH", tmpDir)
+
+	input := filepath.Join(tmpDir, "doc.md")
+	_ = os.WriteFile(input, []byte("---\noutput:\n  html:\n    output: "+filepath.Join(tmpDir, "doc.html")+"\n---\n# Doc\n"), 0600)
+
+	executor := &TestExecutor{}
+	opts := options.Options{DryRun: true, Quiet: true, NoCache: true}
+	if err := app.Process(context.Background(), input, nil, opts, executor); err != nil {
+		t.Fatalf("dry-run should plan without pandoc, got: %v", err)
+	}
+	if executor.CapturedName != "pandoc" {
+		t.Errorf("expected the pandoc command to be planned, got %q", executor.CapturedName)
+	}
+
+	opts.DryRun = false
+	err := app.Process(context.Background(), input, nil, opts, executor)
+	if err == nil || !strings.Contains(err.Error(), "pandoc not found") {
+		t.Errorf("expected a real run to require pandoc, got %v", err)
+	}
+	if code := app.ExitCode(err); code != app.ExitPandocMissing {
+		t.Errorf("expected exit code %d without pandoc, got %d", app.ExitPandocMissing, code)
+	}
+}
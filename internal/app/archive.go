@@ -0,0 +1,232 @@
+package app
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+// archiveSetting returns the archive a run should write: --archive, else the
+// document's `archive` key resolved against baseDir, else "".
+//
+// Parameters:
+//   - `opts`: runtime options
+//   - `cfg`: the document config
+//   - `baseDir`: the directory relative paths are resolved against
+func archiveSetting(opts options.Options, cfg *config.Config, baseDir string) string {
+	if opts.Archive != "" {
+		return opts.Archive
+	}
+	path := stringSetting(cfg, nil, "archive")
+	if path == "" {
+		return ""
+	}
+	resolved, err := resolveIn(baseDir, path)
+	if err != nil {
+		return path
+	}
+	return resolved
+}
+
+// archiveOutputs returns the output files of the targets that were built or are up to date.
+//
+// Parameters:
+//   - `results`: the per-target results
+func archiveOutputs(results []TargetResult) []string {
+	var outputs []string
+	for _, res := range results {
+		if res.Output == "" || (res.Status != StatusSuccess && res.Status != StatusUpToDate) {
+			continue
+		}
+		if info, err := os.Stat(res.Output); err == nil && info.Mode().IsRegular() {
+			outputs = append(outputs, res.Output)
+		}
+	}
+	sort.Strings(outputs)
+	return outputs
+}
+
+// finishArchive bundles a run's outputs into an archive, or explains why it did not.
+// The archive is skipped in dry-run mode and when the run failed, since it would be incomplete.
+//
+// Parameters:
+//   - `path`: the archive to write
+//   - `results`: the per-target results of the run
+//   - `runErr`: the run's error, if any
+//   - `opts`: runtime options
+//
+// Returns:
+//   - error: if the archive could not be written
+func finishArchive(path string, results []TargetResult, runErr error, opts options.Options) error {
+	if opts.DryRun {
+		if opts.Logger != nil {
+			opts.Logger.Info("skipping archive in dry-run mode", "archive", path)
+		}
+		return nil
+	}
+	if runErr != nil {
+		if opts.Logger != nil {
+			opts.Logger.Warn("not writing archive, the build failed", "archive", path)
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: not writing %s, the build failed\n", path)
+		}
+		return nil
+	}
+	outputs := archiveOutputs(results)
+	if err := writeArchive(path, outputs); err != nil {
+		return err
+	}
+	if opts.Logger != nil {
+		opts.Logger.Info("archived outputs", "archive", path, "files", len(outputs))
+	} else if !opts.Quiet {
+		fmt.Printf("Archived %d output(s) into %s\n", len(outputs), path)
+	}
+	return nil
+}
+
+// writeArchive writes files into a .zip, .tar.gz/.tgz, or .tar archive, chosen by the
+// extension of path. Entries are named relative to the files' common directory.
+//
+// Parameters:
+//   - `path`: the archive to create
+//   - `files`: absolute paths of the files to add
+func writeArchive(path string, files []string) (err error) {
+	lower := strings.ToLower(path)
+	isZip := strings.HasSuffix(lower, ".zip")
+	isTarGz := strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz")
+	if !isZip && !isTarGz && !strings.HasSuffix(lower, ".tar") {
+		return fmt.Errorf("unsupported archive type %q (use .zip, .tar.gz, .tgz, or .tar)", filepath.Base(path))
+	}
+
+	//nolint:gosec // G304: the archive path comes from the command line or config
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil && cerr != nil {
+			err = fmt.Errorf("failed to write archive: %w", cerr)
+		}
+		if err != nil {
+			_ = os.Remove(path)
+		}
+	}()
+
+	root := commonDir(files)
+	if isZip {
+		zw := zip.NewWriter(f)
+		for _, file := range files {
+			if err := addToZip(zw, file, archiveName(root, file)); err != nil {
+				return err
+			}
+		}
+		if err := zw.Close(); err != nil {
+			return fmt.Errorf("failed to write archive: %w", err)
+		}
+		return nil
+	}
+
+	var w io.Writer = f
+	var gz *gzip.Writer
+	if isTarGz {
+		gz = gzip.NewWriter(f)
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+	for _, file := range files {
+		if err := addToTar(tw, file, archiveName(root, file)); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return fmt.Errorf("failed to write archive: %w", err)
+		}
+	}
+	return nil
+}
+
+// addToZip copies a file into a zip archive.
+func addToZip(zw *zip.Writer, file, name string) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %w", file, err)
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %w", file, err)
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+	w, err := zw.CreateHeader(header)
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %w", file, err)
+	}
+	return copyInto(w, file)
+}
+
+// addToTar copies a file into a tar archive.
+func addToTar(tw *tar.Writer, file, name string) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %w", file, err)
+	}
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %w", file, err)
+	}
+	header.Name = name
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to archive %s: %w", file, err)
+	}
+	return copyInto(tw, file)
+}
+
+// copyInto streams a file into w.
+func copyInto(w io.Writer, file string) error {
+	//nolint:gosec // G304: archiving generated outputs is intended
+	src, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %w", file, err)
+	}
+	defer func() { _ = src.Close() }()
+	if _, err := io.Copy(w, src); err != nil {
+		return fmt.Errorf("failed to archive %s: %w", file, err)
+	}
+	return nil
+}
+
+// commonDir returns the deepest directory containing every file.
+func commonDir(files []string) string {
+	if len(files) == 0 {
+		return ""
+	}
+	dir := filepath.Dir(files[0])
+	for _, f := range files[1:] {
+		for !strings.HasPrefix(f, dir+string(filepath.Separator)) && filepath.Dir(dir) != dir {
+			dir = filepath.Dir(dir)
+		}
+	}
+	return dir
+}
+
+// archiveName returns the slash-separated entry name of a file below root.
+func archiveName(root, file string) string {
+	rel, err := filepath.Rel(root, file)
+	if err != nil {
+		rel = filepath.Base(file)
+	}
+	return filepath.ToSlash(rel)
+}
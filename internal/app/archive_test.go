@@ -0,0 +1,106 @@
+package app
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+func TestWriteArchive(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{filepath.Join(dir, "out", "doc.pdf"), filepath.Join(dir, "out", "site", "index.html")}
+	for _, f := range files {
+		_ = os.MkdirAll(filepath.Dir(f), 0750)
+		_ = os.WriteFile(f, []byte(filepath.Base(f)), 0644)
+	}
+	want := []string{"doc.pdf", "site/index.html"}
+
+	zipPath := filepath.Join(dir, "release.zip")
+	if err := writeArchive(zipPath, files); err != nil {
+		t.Fatalf("writeArchive zip failed: %v", err)
+	}
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range zr.File {
+		got = append(got, f.Name)
+	}
+	_ = zr.Close()
+	sort.Strings(got)
+	if len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("zip entries = %v, want %v", got, want)
+	}
+
+	tgzPath := filepath.Join(dir, "release.tar.gz")
+	if err := writeArchive(tgzPath, files); err != nil {
+		t.Fatalf("writeArchive tar.gz failed: %v", err)
+	}
+	f, _ := os.Open(tgzPath)
+	defer func() { _ = f.Close() }()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	got = nil
+	for {
+		h, err := tr.Next()
+		if err != nil {
+			break
+		}
+		got = append(got, h.Name)
+	}
+	if len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("tar entries = %v, want %v", got, want)
+	}
+
+	if err := writeArchive(filepath.Join(dir, "release.rar"), files); err == nil {
+		t.Error("expected an error for an unsupported archive type")
+	}
+}
+
+func TestArchiveSetting(t *testing.T) {
+	cfg := &config.Config{Generic: map[string]interface{}{"archive": "dist/release.zip"}}
+	if got := archiveSetting(options.Options{}, cfg, "/work"); got != filepath.Join("/work", "dist", "release.zip") {
+		t.Errorf("config archive = %q", got)
+	}
+	if got := archiveSetting(options.Options{Archive: "cli.tgz"}, cfg, "/work"); got != "cli.tgz" {
+		t.Errorf("--archive should win, got %q", got)
+	}
+}
+
+func TestRunMany_Archive(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	var files []string
+	for _, name := range []string{"a", "b"} {
+		input := filepath.Join(dir, name+".md")
+		_ = os.WriteFile(input, []byte("---\noutput:\n  html:\n    output: "+filepath.Join(dir, name+".html")+"\n---\n# "+name+"\n"), 0600)
+		files = append(files, input)
+	}
+
+	archive := filepath.Join(dir, "all.zip")
+	rec := &argsRecorder{inputs: map[string]string{}, args: map[string][]string{}}
+	opts := options.Options{Targets: []string{"html"}, Archive: archive, NoCache: true, Quiet: true}
+	if _, err := runMany(context.Background(), files, nil, opts, rec); err != nil {
+		t.Fatalf("runMany failed: %v", err)
+	}
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		t.Fatalf("archive not written: %v", err)
+	}
+	defer func() { _ = zr.Close() }()
+	if len(zr.File) != 2 {
+		t.Errorf("expected both outputs in one archive, got %d entries", len(zr.File))
+	}
+}
@@ -0,0 +1,258 @@
+package app
+
+import (
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/rapjul/panforge/internal/config"
+)
+
+// htmlFormats are the output formats whose results reference assets at run time.
+var htmlFormats = map[string]bool{
+	"html": true, "html4": true, "html5": true, "chunkedhtml": true,
+	"revealjs": true, "slidy": true, "slideous": true, "s5": true, "dzslides": true,
+}
+
+// assetsAuto is the `assets` value that copies the document's own relative references.
+const assetsAuto = "auto"
+
+// resolveAssets reads the `assets` key. A target's own list always applies; the global
+// list only applies to HTML targets. The list holds files, directories, or glob patterns
+// relative to the input document; the entry "auto" (or `assets: true`) adds the
+// stylesheets named by `css` and the document's images.
+//
+// Parameters:
+//   - `cfg`: the global config
+//   - `metaOut`: the format-specific config
+//   - `fmtStr`: the target pandoc format
+//
+// Returns:
+//   - []string: the patterns to copy
+//   - bool: true if referenced assets should be found automatically
+func resolveAssets(cfg *config.Config, metaOut map[string]interface{}, fmtStr string) ([]string, bool) {
+	raw, ok := metaOut["assets"]
+	if !ok {
+		if !htmlFormats[fmtStr] {
+			return nil, false
+		}
+		raw = cfg.Generic["assets"]
+	}
+
+	var patterns []string
+	auto := false
+	switch v := raw.(type) {
+	case bool:
+		auto = v
+	default:
+		for _, p := range toStringSlice(v) {
+			if p == assetsAuto {
+				auto = true
+			} else {
+				patterns = append(patterns, p)
+			}
+		}
+	}
+	return patterns, auto
+}
+
+// referencedAssets lists the relative stylesheets and images a document refers to.
+//
+// Parameters:
+//   - `cfg`: the global config
+//   - `metaOut`: the format-specific config
+//   - `input`: the document
+func referencedAssets(cfg *config.Config, metaOut map[string]interface{}, input string) []string {
+	var refs []string
+	css, ok := metaOut["css"]
+	if !ok {
+		css = cfg.Generic["css"]
+	}
+	refs = append(refs, toStringSlice(css)...)
+
+	//nolint:gosec // G304: reading the input file is intended
+	if data, err := os.ReadFile(input); err == nil {
+		for _, m := range markdownImage.FindAllStringSubmatch(string(data), -1) {
+			p := m[1]
+			if decoded, err := url.PathUnescape(p); err == nil {
+				p = decoded
+			}
+			refs = append(refs, p)
+		}
+	}
+
+	var local []string
+	for _, r := range refs {
+		if !isRemote(r) && !filepath.IsAbs(r) {
+			local = append(local, r)
+		}
+	}
+	return local
+}
+
+// copyAssets copies assets into the output file's directory, keeping their paths relative
+// to the input document, so the output can be moved or published as a whole. Files that
+// are already up to date are not copied again, and paths outside the input's directory are
+// skipped with a warning.
+//
+// Parameters:
+//   - `inputFile`: the document, whose directory the patterns are relative to
+//   - `outputFile`: the absolute output path
+//   - `patterns`: files, directories, or glob patterns
+//
+// Returns:
+//   - int: the number of files copied
+//   - []string: patterns that were skipped and why
+//   - error: any error copying a file
+func copyAssets(inputFile, outputFile string, patterns []string) (int, []string, error) {
+	srcDir := filepath.Dir(inputFile)
+	dstDir := filepath.Dir(outputFile)
+	if same, err := sameDir(srcDir, dstDir); err != nil || same {
+		return 0, nil, err
+	}
+
+	var warnings []string
+	files := make(map[string]bool)
+	for _, p := range patterns {
+		rel := filepath.Clean(filepath.FromSlash(p))
+		if filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			warnings = append(warnings, fmt.Sprintf("%s is outside the document's directory", p))
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(srcDir, rel))
+		if err != nil || len(matches) == 0 {
+			warnings = append(warnings, fmt.Sprintf("%s matches no files", p))
+			continue
+		}
+		for _, m := range matches {
+			err := filepath.WalkDir(m, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.Type().IsRegular() {
+					files[path] = true
+				}
+				return nil
+			})
+			if err != nil {
+				return 0, warnings, fmt.Errorf("failed to read asset %s: %w", m, err)
+			}
+		}
+	}
+
+	sorted := make([]string, 0, len(files))
+	for f := range files {
+		sorted = append(sorted, f)
+	}
+	sort.Strings(sorted)
+
+	copied := 0
+	for _, src := range sorted {
+		rel, err := filepath.Rel(srcDir, src)
+		if err != nil {
+			return copied, warnings, err
+		}
+		dst := filepath.Join(dstDir, rel)
+		if upToDate(src, dst) {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0750); err != nil {
+			return copied, warnings, fmt.Errorf("failed to create asset directory: %w", err)
+		}
+		if err := copyFileContents(src, dst); err != nil {
+			return copied, warnings, fmt.Errorf("failed to copy asset %s: %w", rel, err)
+		}
+		copied++
+	}
+	return copied, warnings, nil
+}
+
+// upToDate reports whether dst exists with the same size and is not older than src.
+func upToDate(src, dst string) bool {
+	si, err := os.Stat(src)
+	if err != nil {
+		return false
+	}
+	di, err := os.Stat(dst)
+	if err != nil {
+		return false
+	}
+	return si.Size() == di.Size() && !di.ModTime().Before(si.ModTime())
+}
+
+// sameDir reports whether two paths name the same directory.
+func sameDir(a, b string) (bool, error) {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	bi, err := os.Stat(b)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return os.SameFile(ai, bi), nil
+}
+
+// resourcePathArgs returns the --resource-path pandoc needs to find a document's
+// relative images and stylesheets when it lives outside the directory pandoc runs in:
+// the document's directory, pandoc's directory (its default), and the directories
+// listed in `assets`. Nothing is added when the arguments already set a resource path,
+// or when the default would do.
+//
+// Parameters:
+//   - `cfg`: the global config
+//   - `metaOut`: the format-specific config
+//   - `fmtStr`: the target pandoc format
+//   - `inputFile`: the document
+//   - `dir`: the directory pandoc runs in ("" for the working directory)
+//   - `args`: the target's other pandoc arguments
+//
+// Returns:
+//   - []string: the --resource-path argument, or nil
+func resourcePathArgs(cfg *config.Config, metaOut map[string]interface{}, fmtStr, inputFile, dir string, args []string) []string {
+	for _, arg := range args {
+		if arg == "--resource-path" || strings.HasPrefix(arg, "--resource-path=") {
+			return nil
+		}
+	}
+	wd := dir
+	if wd == "" {
+		wd = workingDir()
+	}
+	docDir := filepath.Dir(inputFile)
+	// Relative paths keep the arguments, and so the build cache key, free of the location
+	rel := func(dir string) string {
+		if r, err := filepath.Rel(wd, dir); err == nil {
+			return r
+		}
+		return dir
+	}
+
+	var paths []string
+	add := func(dir string) {
+		if !slices.Contains(paths, dir) {
+			paths = append(paths, dir)
+		}
+	}
+	add(rel(docDir))
+	add(".")
+	patterns, _ := resolveAssets(cfg, metaOut, fmtStr)
+	for _, p := range patterns {
+		assetDir := filepath.Join(docDir, p)
+		if info, err := os.Stat(assetDir); err == nil && info.IsDir() {
+			add(rel(assetDir))
+		}
+	}
+	if len(paths) == 1 {
+		return nil
+	}
+	return []string{"--resource-path", strings.Join(paths, string(filepath.ListSeparator))}
+}
@@ -0,0 +1,106 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/config"
+)
+
+func TestResolveAssets(t *testing.T) {
+	cfg := &config.Config{Generic: map[string]interface{}{"assets": []interface{}{"css/*.css", "auto"}}}
+
+	patterns, auto := resolveAssets(cfg, map[string]interface{}{}, "html")
+	if !reflect.DeepEqual(patterns, []string{"css/*.css"}) || !auto {
+		t.Errorf("html: got %v, %v", patterns, auto)
+	}
+	if patterns, auto := resolveAssets(cfg, map[string]interface{}{}, "docx"); patterns != nil || auto {
+		t.Errorf("global assets should not apply to docx, got %v, %v", patterns, auto)
+	}
+	if patterns, _ := resolveAssets(cfg, map[string]interface{}{"assets": "fonts"}, "epub"); !reflect.DeepEqual(patterns, []string{"fonts"}) {
+		t.Errorf("target assets should apply to any format, got %v", patterns)
+	}
+}
+
+func TestCopyAssets(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "doc.md")
+	files := map[string]string{
+		"doc.md":            "![Logo](img/logo.png)",
+		"css/site.css":      "body{}",
+		"css/print.css":     "@media print{}",
+		"img/logo.png":      "png",
+		"js/vendor/app.js":  "app()",
+		"notes/private.txt": "secret",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		_ = os.MkdirAll(filepath.Dir(path), 0750)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	output := filepath.Join(dir, "public", "doc.html")
+
+	cfg := &config.Config{Generic: map[string]interface{}{}}
+	patterns := append([]string{"css/*.css", "js", "../outside.css", "missing/*.svg"}, referencedAssets(cfg, map[string]interface{}{}, input)...)
+	copied, warnings, err := copyAssets(input, output, patterns)
+	if err != nil {
+		t.Fatalf("copyAssets failed: %v", err)
+	}
+	if copied != 4 {
+		t.Errorf("copied %d files, want 4", copied)
+	}
+	for _, name := range []string{"css/site.css", "css/print.css", "img/logo.png", "js/vendor/app.js"} {
+		if _, err := os.Stat(filepath.Join(dir, "public", filepath.FromSlash(name))); err != nil {
+			t.Errorf("%s was not copied", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "public", "notes")); !os.IsNotExist(err) {
+		t.Error("unlisted files should not be copied")
+	}
+	if len(warnings) != 2 || !strings.Contains(warnings[0], "outside") || !strings.Contains(warnings[1], "matches no files") {
+		t.Errorf("unexpected warnings %v", warnings)
+	}
+
+	// Up-to-date assets are not copied again.
+	if copied, _, _ := copyAssets(input, output, patterns); copied != 0 {
+		t.Errorf("copied %d files on the second run, want 0", copied)
+	}
+
+	// Nothing is copied when the output sits next to the input.
+	if copied, _, err := copyAssets(input, filepath.Join(dir, "doc.html"), patterns); err != nil || copied != 0 {
+		t.Errorf("expected no copies into the input directory, got %d, %v", copied, err)
+	}
+}
+
+func TestResourcePathArgs(t *testing.T) {
+	wd := t.TempDir()
+	t.Chdir(wd)
+	docDir := filepath.Join(wd, "docs")
+	_ = os.MkdirAll(filepath.Join(docDir, "images"), 0750)
+	input := filepath.Join(docDir, "guide.md")
+	sep := string(filepath.ListSeparator)
+	cfg := &config.Config{Generic: map[string]interface{}{}}
+
+	if got := resourcePathArgs(cfg, nil, "html", filepath.Join(wd, "notes.md"), "", nil); got != nil {
+		t.Errorf("expected no argument for a document in the working directory, got %v", got)
+	}
+	if got := strings.Join(resourcePathArgs(cfg, nil, "html", input, "", nil), " "); got != "--resource-path docs"+sep+"." {
+		t.Errorf("expected the document's directory first, got %q", got)
+	}
+	meta := map[string]interface{}{"assets": []interface{}{"images/", "missing/", "*.css"}}
+	want := "--resource-path " + strings.Join([]string{"docs", ".", filepath.Join("docs", "images")}, sep)
+	if got := strings.Join(resourcePathArgs(cfg, meta, "html", input, "", nil), " "); got != want {
+		t.Errorf("expected the asset directories too, got %q, want %q", got, want)
+	}
+	if got := resourcePathArgs(cfg, nil, "html", input, "", []string{"--toc", "--resource-path=assets"}); got != nil {
+		t.Errorf("expected an explicit resource path to win, got %v", got)
+	}
+	if got := resourcePathArgs(cfg, nil, "html", input, docDir, nil); got != nil {
+		t.Errorf("expected no argument when pandoc runs in the document's directory, got %v", got)
+	}
+}
@@ -0,0 +1,84 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rapjul/panforge/internal/pandoc"
+)
+
+// atomicOutput reports whether pandoc's output can be written to a temp file and renamed
+// into place. chunkedhtml without an extension writes a directory, which cannot be swapped atomically.
+func atomicOutput(outputFile, format string) bool {
+	if outputFile == "" || outputFile == "-" {
+		return false
+	}
+	return !(pandoc.NormalizeFormat(format) == "chunkedhtml" && filepath.Ext(outputFile) == "")
+}
+
+// tempOutput reserves a temp file name next to outputFile for pandoc to write to, so an
+// interrupted run never leaves a truncated output behind. The name keeps the output's
+// base name and extension, since pandoc picks e.g. PDF generation from the extension.
+// The file itself is left for pandoc to create.
+//
+// Parameters:
+//   - `outputFile`: the final output path
+//
+// Returns:
+//   - string: the temp file path
+//   - error: if the temp file could not be created
+func tempOutput(outputFile string) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(outputFile), ".panforge-*-"+filepath.Base(outputFile))
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp output: %w", err)
+	}
+	_ = tmp.Close()
+	if err := os.Remove(tmp.Name()); err != nil {
+		return "", fmt.Errorf("failed to create temp output: %w", err)
+	}
+	return tmp.Name(), nil
+}
+
+// commitOutput renames a finished temp output over the destination, keeping the
+// permissions of the file it replaces, which is first backed up if backupMode is set.
+// It does nothing if pandoc wrote no file.
+//
+// Parameters:
+//   - `tmpFile`: the temp file pandoc wrote
+//   - `outputFile`: the final output path
+//   - `backupMode`: how to back up the previous output ("" for none)
+//
+// Returns:
+//   - string: the backup of the previous output, or "" if none was made
+//   - error: if the output could not be moved into place
+func commitOutput(tmpFile, outputFile, backupMode string) (string, error) {
+	if _, err := os.Stat(tmpFile); os.IsNotExist(err) {
+		return "", nil
+	}
+	if info, err := os.Stat(outputFile); err == nil && info.Mode().IsRegular() {
+		if err := os.Chmod(tmpFile, info.Mode().Perm()); err != nil {
+			return "", fmt.Errorf("failed to finalize output: %w", err)
+		}
+	}
+	backup, err := backupOutput(outputFile, backupMode)
+	if err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmpFile, outputFile); err != nil {
+		return backup, fmt.Errorf("failed to finalize output: %w", err)
+	}
+	return backup, nil
+}
+
+// withOutput returns a copy of args with the value of --output replaced.
+func withOutput(args []string, outputFile string) []string {
+	out := append([]string(nil), args...)
+	for i := 0; i+1 < len(out); i++ {
+		if out[i] == "--output" {
+			out[i+1] = outputFile
+			break
+		}
+	}
+	return out
+}
@@ -0,0 +1,59 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/options"
+)
+
+// partialExecutor writes part of an output and then fails, like a killed pandoc.
+type partialExecutor struct{}
+
+func (partialExecutor) Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	for i, arg := range args {
+		if arg == "--output" && i+1 < len(args) {
+			_ = os.WriteFile(args[i+1], []byte("trunc"), 0600)
+		}
+	}
+	return errors.New("signal: killed")
+}
+
+func TestProcess_AtomicOutput(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	input := filepath.Join(dir, "doc.md")
+	output := filepath.Join(dir, "doc.html")
+	_ = os.WriteFile(input, []byte("---\noutput:\n  html:\n    output: doc.html\n---\n# Doc\n"), 0600)
+	_ = os.WriteFile(output, []byte("previous"), 0640)
+
+	opts := options.Options{Targets: []string{"html"}, Force: true, NoCache: true, Quiet: true}
+	if _, err := process(context.Background(), input, nil, opts, partialExecutor{}, processEnv{baseDir: dir}); err == nil {
+		t.Fatal("expected the failed run to return an error")
+	}
+	if data, _ := os.ReadFile(output); string(data) != "previous" {
+		t.Errorf("a failed run must keep the previous output, got %q", data)
+	}
+
+	rec := &argsRecorder{inputs: map[string]string{}, args: map[string][]string{}}
+	if _, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir}); err != nil {
+		t.Fatalf("process failed: %v", err)
+	}
+	info, err := os.Stat(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(output); string(data) != "converted" || info.Mode().Perm() != 0640 {
+		t.Errorf("expected the output to be replaced with its mode kept, got %q (%v)", data, info.Mode().Perm())
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if filepath.Ext(e.Name()) == ".html" && e.Name() != "doc.html" {
+			t.Errorf("temp output left behind: %s", e.Name())
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+const (
+	// backupSimple keeps one previous version as <name>.bak.
+	backupSimple = "simple"
+	// backupTimestamp keeps every previous version as <name>.<timestamp>.bak.
+	backupTimestamp = "timestamp"
+)
+
+// backupSetting returns how existing outputs are backed up before they are overwritten:
+// --backup, else the target's or document's `backup` key (true, "simple", or
+// "timestamp"), else "" for no backup.
+//
+// Parameters:
+//   - `opts`: runtime options
+//   - `cfg`: the global config
+//   - `metaOut`: the format-specific config
+//
+// Returns:
+//   - string: backupSimple, backupTimestamp, or ""
+//   - error: if the setting is not a known mode
+func backupSetting(opts options.Options, cfg *config.Config, metaOut map[string]interface{}) (string, error) {
+	mode := opts.Backup
+	if mode == "" {
+		raw, ok := metaOut["backup"]
+		if !ok {
+			raw = cfg.Generic["backup"]
+		}
+		switch v := raw.(type) {
+		case bool:
+			if v {
+				mode = backupSimple
+			}
+		case string:
+			mode = v
+		}
+	}
+	switch mode {
+	case "", "false", "none":
+		return "", nil
+	case "true", backupSimple:
+		return backupSimple, nil
+	case backupTimestamp:
+		return backupTimestamp, nil
+	default:
+		return "", fmt.Errorf("backup: unknown mode %q (use simple or timestamp)", mode)
+	}
+}
+
+// backupOutput renames an existing output out of the way before it is overwritten.
+// It does nothing if the mode is "" or the output does not exist.
+//
+// Parameters:
+//   - `outputFile`: the output about to be replaced
+//   - `mode`: backupSimple or backupTimestamp
+//
+// Returns:
+//   - string: the backup path, or "" if nothing was backed up
+//   - error: if the output could not be renamed
+func backupOutput(outputFile, mode string) (string, error) {
+	if mode == "" {
+		return "", nil
+	}
+	if _, err := os.Stat(outputFile); err != nil {
+		return "", nil
+	}
+	backup := outputFile + ".bak"
+	if mode == backupTimestamp {
+		backup = outputFile + "." + time.Now().Format("20060102-150405") + ".bak"
+	}
+	if err := os.Rename(outputFile, backup); err != nil {
+		return "", fmt.Errorf("failed to back up %s: %w", outputFile, err)
+	}
+	return backup, nil
+}
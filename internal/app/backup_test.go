@@ -0,0 +1,90 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+func TestBackupSetting(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    options.Options
+		value   interface{}
+		want    string
+		wantErr bool
+	}{
+		{"unset", options.Options{}, nil, "", false},
+		{"true", options.Options{}, true, backupSimple, false},
+		{"timestamp", options.Options{}, "timestamp", backupTimestamp, false},
+		{"flag wins", options.Options{Backup: "timestamp"}, true, backupTimestamp, false},
+		{"unknown", options.Options{}, "rotate", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Generic: map[string]interface{}{}}
+			if tt.value != nil {
+				cfg.Generic["backup"] = tt.value
+			}
+			got, err := backupSetting(tt.opts, cfg, nil)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("backupSetting = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcess_Backup(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	input := filepath.Join(dir, "doc.md")
+	output := filepath.Join(dir, "doc.html")
+	_ = os.WriteFile(input, []byte("---\noutput:\n  html:\n    output: doc.html\n---\n# Doc\n"), 0600)
+	_ = os.WriteFile(output, []byte("previous"), 0600)
+
+	rec := &argsRecorder{inputs: map[string]string{}, args: map[string][]string{}}
+	opts := options.Options{Targets: []string{"html"}, Force: true, NoCache: true, Quiet: true, Backup: backupSimple}
+	if _, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir}); err != nil {
+		t.Fatalf("process failed: %v", err)
+	}
+	if data, _ := os.ReadFile(output + ".bak"); string(data) != "previous" {
+		t.Errorf("expected the previous output in doc.html.bak, got %q", data)
+	}
+	if data, _ := os.ReadFile(output); string(data) != "converted" {
+		t.Errorf("expected the new output, got %q", data)
+	}
+
+	opts.Backup = backupTimestamp
+	if _, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir}); err != nil {
+		t.Fatalf("process failed: %v", err)
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "doc.html.*-*.bak"))
+	if len(matches) != 1 {
+		t.Errorf("expected one timestamped backup, got %v", matches)
+	}
+}
+
+func TestProcess_BackupOnCacheMissKeepsOutput(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	input := filepath.Join(dir, "doc.md")
+	output := filepath.Join(dir, "doc.html")
+	_ = os.WriteFile(input, []byte("---\noutput:\n  html:\n    output: doc.html\n---\n# Edited\n"), 0600)
+	_ = os.WriteFile(output, []byte("previous"), 0600)
+
+	// The cache is on, but has no build for the edited document, and pandoc fails
+	opts := options.Options{Targets: []string{"html"}, Force: true, Quiet: true, Backup: backupSimple}
+	if _, err := process(context.Background(), input, nil, opts, partialExecutor{}, processEnv{baseDir: dir}); err == nil {
+		t.Fatal("expected the failed run to return an error")
+	}
+	if data, _ := os.ReadFile(output); string(data) != "previous" {
+		t.Errorf("a failed run must keep the previous output, got %q", data)
+	}
+	if _, err := os.Stat(output + ".bak"); err == nil {
+		t.Error("a failed run must not back up the previous output")
+	}
+}
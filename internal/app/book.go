@@ -0,0 +1,251 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/preprocess"
+)
+
+// chapter is one entry of the `chapters` list.
+type chapter struct {
+	// File is the chapter's path, resolved against the main document's directory.
+	File string
+	// Meta is the chapter's metadata: its own YAML header overridden by the config entry.
+	Meta map[string]interface{}
+}
+
+// parseChapters reads the `chapters` list. Entries are paths or maps with a `file` key
+// and per-chapter metadata.
+//
+// Parameters:
+//   - `raw`: the `chapters` config value
+//   - `baseDir`: the directory relative chapter paths are resolved against
+//
+// Returns:
+//   - []chapter: the chapters in order
+//   - error: if an entry is malformed
+func parseChapters(raw interface{}, baseDir string) ([]chapter, error) {
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("chapters must be a list of files")
+	}
+	chapters := make([]chapter, 0, len(list))
+	for i, item := range list {
+		var ch chapter
+		switch v := item.(type) {
+		case string:
+			ch.File = v
+		case map[string]interface{}:
+			file, _ := v["file"].(string)
+			if file == "" {
+				return nil, fmt.Errorf("chapter %d has no file", i+1)
+			}
+			ch.File = file
+			ch.Meta = make(map[string]interface{}, len(v))
+			for k, val := range v {
+				if k != "file" {
+					ch.Meta[k] = val
+				}
+			}
+		default:
+			return nil, fmt.Errorf("chapter %d must be a path or a map with a file key", i+1)
+		}
+		if !filepath.IsAbs(ch.File) {
+			ch.File = filepath.Join(baseDir, ch.File)
+		}
+		chapters = append(chapters, ch)
+	}
+	return chapters, nil
+}
+
+// bookChapterFiles returns the chapter files of a book document, for watching.
+//
+// Parameters:
+//   - `inputFile`: the main document
+//
+// Returns:
+//   - []string: the chapter paths (nil if the document has no `chapters` list)
+func bookChapterFiles(inputFile string) []string {
+	cfg, err := loadDocumentConfig(inputFile, "")
+	if err != nil || cfg.Generic["chapters"] == nil {
+		return nil
+	}
+	chapters, err := parseChapters(cfg.Generic["chapters"], filepath.Dir(inputFile))
+	if err != nil {
+		return nil
+	}
+	files := make([]string, len(chapters))
+	for i, ch := range chapters {
+		files[i] = ch.File
+	}
+	return files
+}
+
+// prepareSource writes the file pandoc should convert when the document needs assembling:
+// include directives are expanded (`includes: true`) and, in book mode, the main document
+// and its chapters are concatenated. With a `preprocess` list the copy is made even if
+// nothing else changes, so the steps never touch the original. Chapter YAML headers are removed so they cannot
+// override the book's metadata; their fields are applied to the chapter's heading instead
+// (see chapterBody).
+//
+// Parameters:
+//   - `inputFile`: the main document, whose header holds the document's metadata
+//   - `cfg`: the main document's config
+//
+// Returns:
+//   - string: the path of the prepared file, or "" if the input can be converted as-is
+//   - error: any error reading a chapter or include, or writing the prepared file
+func prepareSource(inputFile string, cfg *config.Config) (string, error) {
+	includes, _ := cfg.Generic["includes"].(bool)
+	raw := cfg.Generic["chapters"]
+	if raw == nil && !includes && cfg.Generic["preprocess"] == nil {
+		return "", nil
+	}
+	mainDoc, err := readBookSource(inputFile, includes)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	sb.WriteString(mainDoc)
+
+	if raw != nil {
+		chapters, err := parseChapters(raw, filepath.Dir(inputFile))
+		if err != nil {
+			return "", err
+		}
+		sb.Reset()
+		sb.WriteString(strings.TrimRight(mainDoc, "\n"))
+		sb.WriteString("\n")
+		for _, ch := range chapters {
+			text, err := readChapter(ch, includes)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString("\n")
+			sb.WriteString(text)
+			sb.WriteString("\n")
+		}
+	}
+
+	// Kept next to the input so relative resource paths keep working
+	tmp, err := os.CreateTemp(filepath.Dir(inputFile), ".panforge-source-*"+filepath.Ext(inputFile))
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	if _, err := tmp.WriteString(sb.String()); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to close temp file: %w", err)
+	}
+	return tmp.Name(), nil
+}
+
+// readBookSource reads the main document or a chapter, expanding include directives if enabled.
+func readBookSource(path string, includes bool) (string, error) {
+	if includes {
+		return preprocess.ExpandIncludes(path)
+	}
+	//nolint:gosec // G304: the input and its chapters are named by the user
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return string(data), nil
+}
+
+// readChapter reads a chapter and returns its body with the chapter metadata applied.
+//
+// Parameters:
+//   - `ch`: the chapter
+//   - `includes`: whether include directives are expanded
+func readChapter(ch chapter, includes bool) (string, error) {
+	data, err := readBookSource(ch.File, includes)
+	if err != nil {
+		return "", fmt.Errorf("failed to read chapter: %w", err)
+	}
+	fm, body := config.SplitFrontmatter(data)
+	meta := make(map[string]interface{})
+	if fm != "" {
+		if err := yaml.Unmarshal([]byte(fm), &meta); err != nil {
+			return "", fmt.Errorf("error parsing YAML in chapter '%s': %w", ch.File, err)
+		}
+	}
+	for k, v := range ch.Meta {
+		meta[k] = v
+	}
+	return chapterBody(body, meta), nil
+}
+
+// chapterBody applies a chapter's metadata to its Markdown. A `title` adds a level-one
+// heading; `id`, `class`, `unnumbered`, and any other keys become attributes of the
+// chapter's heading (the added one, or an existing leading `# ` heading).
+//
+// Parameters:
+//   - `body`: the chapter text without its YAML header
+//   - `meta`: the chapter metadata
+func chapterBody(body string, meta map[string]interface{}) string {
+	body = strings.Trim(body, "\n")
+	attrs := headingAttributes(meta)
+	title, _ := meta["title"].(string)
+	if title != "" {
+		return "# " + title + attrs + "\n\n" + body + "\n"
+	}
+	if attrs != "" {
+		first, rest, _ := strings.Cut(body, "\n")
+		if strings.HasPrefix(first, "# ") && !strings.HasSuffix(strings.TrimSpace(first), "}") {
+			return strings.TrimRight(first, " ") + attrs + "\n" + rest + "\n"
+		}
+	}
+	return body + "\n"
+}
+
+// headingAttributes renders chapter metadata as a pandoc attribute block (e.g. ` {#intro .unnumbered}`).
+//
+// Parameters:
+//   - `meta`: the chapter metadata
+//
+// Returns:
+//   - string: the attribute block with a leading space, or "" if there are no attributes
+func headingAttributes(meta map[string]interface{}) string {
+	var parts []string
+	if id, ok := meta["id"].(string); ok && id != "" {
+		parts = append(parts, "#"+id)
+	}
+	for _, class := range toStringSlice(meta["class"]) {
+		parts = append(parts, "."+class)
+	}
+	if b, ok := meta["unnumbered"].(bool); ok && b {
+		parts = append(parts, ".unnumbered")
+	}
+
+	var keys []string
+	for k := range meta {
+		switch k {
+		case "title", "id", "class", "unnumbered":
+		default:
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if meta[k] == nil {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s=%q", k, fmt.Sprintf("%v", meta[k])))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " {" + strings.Join(parts, " ") + "}"
+}
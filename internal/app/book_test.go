@@ -0,0 +1,102 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/config"
+)
+
+func TestPrepareSource_Book(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.MkdirAll(filepath.Join(dir, "chapters"), 0750)
+	files := map[string]string{
+		"book.md":         "---\ntitle: The Book\nchapters:\n  - chapters/one.md\n  - file: chapters/two.md\n    id: two\n---\n",
+		"chapters/one.md": "---\ntitle: Beginnings\nunnumbered: true\n---\nOnce upon a time.\n",
+		"chapters/two.md": "# Middle\n\nThings happened.\n",
+	}
+	for name, content := range files {
+		_ = os.WriteFile(filepath.Join(dir, name), []byte(content), 0600)
+	}
+	input := filepath.Join(dir, "book.md")
+	_, cfg, err := config.LoadConfig(input)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	bookFile, err := prepareSource(input, cfg)
+	if err != nil {
+		t.Fatalf("prepareSource failed: %v", err)
+	}
+	defer func() { _ = os.Remove(bookFile) }()
+	if filepath.Dir(bookFile) != dir {
+		t.Errorf("expected the combined file next to the input, got %s", bookFile)
+	}
+
+	data, _ := os.ReadFile(bookFile)
+	got := string(data)
+	for _, want := range []string{
+		"title: The Book",
+		"# Beginnings {.unnumbered}\n\nOnce upon a time.",
+		"# Middle {#two}\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("combined book missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Count(got, "---\n") != 2 {
+		t.Errorf("expected chapter YAML headers to be removed:\n%s", got)
+	}
+	if strings.Index(got, "Beginnings") > strings.Index(got, "Middle") {
+		t.Error("chapters out of order")
+	}
+
+	// Documents without chapters are converted as-is
+	if f, err := prepareSource(input, &config.Config{}); f != "" || err != nil {
+		t.Errorf("prepareSource() without chapters = %q, %v", f, err)
+	}
+
+	// Missing chapters are reported
+	cfg.Generic["chapters"] = []interface{}{"missing.md"}
+	if _, err := prepareSource(input, cfg); err == nil {
+		t.Error("expected an error for a missing chapter")
+	}
+}
+
+func TestHeadingAttributes(t *testing.T) {
+	got := headingAttributes(map[string]interface{}{"id": "intro", "class": "appendix", "author": "Ann", "title": "x"})
+	if got != ` {#intro .appendix author="Ann"}` {
+		t.Errorf("headingAttributes() = %q", got)
+	}
+	if got := headingAttributes(map[string]interface{}{"title": "only"}); got != "" {
+		t.Errorf("expected no attributes, got %q", got)
+	}
+}
+
+func TestPrepareSource_Includes(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.MkdirAll(filepath.Join(dir, "chapters", "shared"), 0750)
+	files := map[string]string{
+		"book.md":                 "---\nincludes: true\nchapters:\n  - chapters/one.md\n---\n{{include: chapters/shared/note.md}}\n",
+		"chapters/one.md":         "# One\n\n!include shared/note.md\n",
+		"chapters/shared/note.md": "A shared note.\n",
+	}
+	for name, content := range files {
+		_ = os.WriteFile(filepath.Join(dir, name), []byte(content), 0600)
+	}
+	input := filepath.Join(dir, "book.md")
+	_, cfg, _ := config.LoadConfig(input)
+
+	prepared, err := prepareSource(input, cfg)
+	if err != nil {
+		t.Fatalf("prepareSource failed: %v", err)
+	}
+	defer func() { _ = os.Remove(prepared) }()
+	data, _ := os.ReadFile(prepared)
+	// Includes resolve relative to the including file, in the main document and in chapters
+	if got := strings.Count(string(data), "A shared note."); got != 2 {
+		t.Errorf("expected the note to be included twice, got %d:\n%s", got, data)
+	}
+}
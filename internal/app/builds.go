@@ -0,0 +1,279 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+	"github.com/rapjul/panforge/internal/utils"
+)
+
+// buildDirLayout is the timestamp layout used for numbered build directories.
+const buildDirLayout = "20060102-150405"
+
+var buildDirRegex = regexp.MustCompile(`^\d{8}-\d{6}(\.\d+)?$`)
+
+// buildRun is the numbered output directory of one run when `keep-builds` is set.
+type buildRun struct {
+	// Root is the parent directory holding all builds (default: build).
+	Root string
+	// Dir is this run's directory, Root/<timestamp>.
+	Dir string
+	// Keep is how many build directories to retain.
+	Keep int
+}
+
+// newBuildRun sets up the build directory for a run from the `keep-builds` and `build-dir` keys.
+// The directory is named after the run's start time, with a `.N` suffix when a run of
+// the same second already exists.
+//
+// Parameters:
+//   - `cfg`: the global config
+//   - `now`: the run's start time
+//   - `base`: the directory a relative `build-dir` is resolved against ("" for the working directory)
+//   - `create`: create the directory, claiming its name (false for dry runs)
+//
+// Returns:
+//   - *buildRun: the build directory, or nil if `keep-builds` is not set
+//   - error: if `keep-builds` is invalid or the directory cannot be resolved or created
+func newBuildRun(cfg *config.Config, now time.Time, base string, create bool) (*buildRun, error) {
+	root, keep, err := buildSettings(cfg, base)
+	if err != nil || keep == 0 {
+		return nil, err
+	}
+	return claimBuildDir(root, keep, now, create)
+}
+
+// buildSettings reads the `keep-builds` and `build-dir` keys.
+//
+// Parameters:
+//   - `cfg`: the global config
+//   - `base`: the directory a relative `build-dir` is resolved against ("" for the working directory)
+//
+// Returns:
+//   - string: the absolute build root
+//   - int: how many build directories to retain, 0 if `keep-builds` is not set
+//   - error: if `keep-builds` is invalid or the directory cannot be resolved
+func buildSettings(cfg *config.Config, base string) (string, int, error) {
+	raw, ok := cfg.Generic["keep-builds"]
+	if !ok || raw == nil {
+		return "", 0, nil
+	}
+	keep, ok := raw.(int)
+	if !ok || keep < 1 {
+		return "", 0, fmt.Errorf("keep-builds must be a positive integer, got %v", raw)
+	}
+
+	root := "build"
+	if dir, ok := cfg.Generic["build-dir"].(string); ok && dir != "" {
+		root = dir
+	}
+	root, err := resolveIn(base, root)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to resolve build directory: %w", err)
+	}
+	return root, keep, nil
+}
+
+// claimBuildDir picks the directory of a run under root.
+//
+// Parameters:
+//   - `root`: the absolute build root
+//   - `keep`: how many build directories to retain
+//   - `now`: the run's start time
+//   - `create`: create the directory, claiming its name (false for dry runs)
+func claimBuildDir(root string, keep int, now time.Time, create bool) (*buildRun, error) {
+	if create {
+		if err := os.MkdirAll(root, 0750); err != nil {
+			return nil, fmt.Errorf("failed to create build directory: %w", err)
+		}
+	}
+
+	// Mkdir claims a name atomically, so concurrent runs never share a directory
+	name := now.Format(buildDirLayout)
+	dir := filepath.Join(root, name)
+	for i := 1; ; i++ {
+		if !create {
+			if _, err := os.Stat(dir); os.IsNotExist(err) {
+				break
+			}
+		} else if err := os.Mkdir(dir, 0750); err == nil {
+			break
+		} else if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create build directory: %w", err)
+		}
+		dir = filepath.Join(root, name+"."+strconv.Itoa(i))
+	}
+	return &buildRun{Root: root, Dir: dir, Keep: keep}, nil
+}
+
+// buildRuns shares build directories between the files converted by one invocation,
+// one per build root, so a later file does not prune the outputs of an earlier one.
+type buildRuns struct {
+	mu     sync.Mutex
+	start  time.Time
+	create bool
+	runs   map[string]*buildRun
+}
+
+// newBuildRuns returns an empty set of build directories for an invocation.
+//
+// Parameters:
+//   - `start`: the invocation's start time, which names the directories
+//   - `create`: create the directories (false for dry runs)
+func newBuildRuns(start time.Time, create bool) *buildRuns {
+	return &buildRuns{start: start, create: create, runs: map[string]*buildRun{}}
+}
+
+// get returns the build directory for a document's config, claiming it on first use.
+//
+// Parameters:
+//   - `cfg`: the document's config
+//   - `base`: the directory a relative `build-dir` is resolved against ("" for the working directory)
+//
+// Returns:
+//   - *buildRun: the build directory, or nil if `keep-builds` is not set
+//   - error: if `keep-builds` is invalid or the directory cannot be resolved or created
+func (r *buildRuns) get(cfg *config.Config, base string) (*buildRun, error) {
+	root, keep, err := buildSettings(cfg, base)
+	if err != nil || keep == 0 {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if run, ok := r.runs[root]; ok {
+		run.Keep = max(run.Keep, keep)
+		return run, nil
+	}
+	run, err := claimBuildDir(root, keep, r.start, r.create)
+	if err != nil {
+		return nil, err
+	}
+	r.runs[root] = run
+	return run, nil
+}
+
+// finish finishes every build directory once the invocation's last file is converted.
+//
+// Parameters:
+//   - `opts`: runtime options
+func (r *buildRuns) finish(opts options.Options) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	roots := make([]string, 0, len(r.runs))
+	for root := range r.runs {
+		roots = append(roots, root)
+	}
+	sort.Strings(roots)
+	for _, root := range roots {
+		finishBuildRun(r.runs[root], opts)
+	}
+}
+
+// finishBuildRun finishes a build directory, warning if that fails.
+//
+// Parameters:
+//   - `run`: the build directory
+//   - `opts`: runtime options
+func finishBuildRun(run *buildRun, opts options.Options) {
+	if err := run.finish(); err != nil {
+		if opts.Logger != nil {
+			opts.Logger.Warn("failed to finalize build directory", "dir", run.Dir, "error", err)
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: failed to finalize build directory %s: %v\n", run.Dir, err)
+		}
+	}
+}
+
+// place moves a relative output path into the run's directory; absolute paths are kept.
+//
+// Parameters:
+//   - `outputFile`: the output path as configured
+func (b *buildRun) place(outputFile string) string {
+	if filepath.IsAbs(outputFile) {
+		return outputFile
+	}
+	return filepath.Join(b.Dir, outputFile)
+}
+
+// finish points the `latest` symlink at this run and prunes old build directories.
+// A run that wrote nothing leaves no directory behind.
+func (b *buildRun) finish() error {
+	if entries, err := os.ReadDir(b.Dir); err == nil && len(entries) == 0 {
+		_ = os.Remove(b.Dir)
+	} else if err == nil {
+		latest := filepath.Join(b.Root, "latest")
+		if info, err := os.Lstat(latest); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			_ = os.Remove(latest)
+		}
+		if err := os.Symlink(filepath.Base(b.Dir), latest); err != nil {
+			return fmt.Errorf("failed to update latest symlink: %w", err)
+		}
+	}
+	return b.prune()
+}
+
+// prune removes the oldest build directories beyond the retention limit.
+func (b *buildRun) prune() error {
+	entries, err := os.ReadDir(b.Root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var builds []string
+	for _, e := range entries {
+		if e.IsDir() && buildDirRegex.MatchString(e.Name()) {
+			builds = append(builds, e.Name())
+		}
+	}
+	// Oldest first: by timestamp, then by same-second suffix (.2 before .10)
+	sort.Slice(builds, func(i, j int) bool {
+		ti, ni := buildOrder(builds[i])
+		tj, nj := buildOrder(builds[j])
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return ni < nj
+	})
+	for len(builds) > b.Keep {
+		if err := os.RemoveAll(filepath.Join(b.Root, builds[0])); err != nil {
+			return fmt.Errorf("failed to prune %s: %w", builds[0], err)
+		}
+		builds = builds[1:]
+	}
+	return nil
+}
+
+// buildOrder parses a build directory name into its timestamp and same-second suffix
+// (0 for none).
+//
+// Parameters:
+//   - `name`: a name matching buildDirRegex
+func buildOrder(name string) (time.Time, int) {
+	stamp, suffix, _ := strings.Cut(name, ".")
+	t, _ := time.Parse(buildDirLayout, stamp)
+	n, _ := strconv.Atoi(suffix)
+	return t, n
+}
+
+// resolveIn resolves a path to an absolute one, treating relative paths as relative to base.
+//
+// Parameters:
+//   - `base`: the base directory ("" for the working directory)
+//   - `path`: the path to resolve
+func resolveIn(base, path string) (string, error) {
+	if base != "" && path != "" && !filepath.IsAbs(path) {
+		path = filepath.Join(base, path)
+	}
+	return utils.ResolvePath(path)
+}
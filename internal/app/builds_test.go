@@ -0,0 +1,173 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+func TestBuildRun(t *testing.T) {
+	root := t.TempDir()
+	cfg := &config.Config{Generic: map[string]interface{}{"keep-builds": 2, "build-dir": root}}
+
+	// Existing builds, oldest first, plus an unrelated directory that must survive pruning
+	for _, name := range []string{"20240101-100000", "20240102-100000", "notes"} {
+		_ = os.MkdirAll(filepath.Join(root, name), 0750)
+	}
+
+	run, err := newBuildRun(cfg, time.Date(2024, 1, 3, 10, 0, 0, 0, time.UTC), "", true)
+	if err != nil || run == nil {
+		t.Fatalf("newBuildRun() = %v, %v", run, err)
+	}
+	if got := run.place("doc.pdf"); got != filepath.Join(root, "20240103-100000", "doc.pdf") {
+		t.Errorf("place() = %s", got)
+	}
+	if got := run.place("/abs/doc.pdf"); got != "/abs/doc.pdf" {
+		t.Errorf("place() changed an absolute path: %s", got)
+	}
+
+	_ = os.WriteFile(run.place("doc.pdf"), []byte("pdf"), 0600)
+	if err := run.finish(); err != nil {
+		t.Fatalf("finish failed: %v", err)
+	}
+
+	for name, want := range map[string]bool{"20240101-100000": false, "20240102-100000": true, "20240103-100000": true, "notes": true} {
+		_, err := os.Stat(filepath.Join(root, name))
+		if exists := err == nil; exists != want {
+			t.Errorf("%s exists = %v, want %v", name, exists, want)
+		}
+	}
+	if target, err := os.Readlink(filepath.Join(root, "latest")); err != nil || target != "20240103-100000" {
+		t.Errorf("latest -> %q (%v)", target, err)
+	}
+
+	if run, _ := newBuildRun(&config.Config{}, time.Now(), "", true); run != nil {
+		t.Error("expected no build run without keep-builds")
+	}
+	if _, err := newBuildRun(&config.Config{Generic: map[string]interface{}{"keep-builds": "five"}}, time.Now(), "", true); err == nil {
+		t.Error("expected error for invalid keep-builds")
+	}
+}
+
+func TestBuildRunSameSecond(t *testing.T) {
+	root := t.TempDir()
+	cfg := &config.Config{Generic: map[string]interface{}{"keep-builds": 3, "build-dir": root}}
+	now := time.Date(2024, 1, 3, 10, 0, 0, 0, time.UTC)
+
+	// Runs started in the same second each claim their own directory
+	var runs []*buildRun
+	for i := 0; i < 12; i++ {
+		run, err := newBuildRun(cfg, now, "", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		runs = append(runs, run)
+	}
+	if runs[0].Dir != filepath.Join(root, "20240103-100000") || runs[11].Dir != filepath.Join(root, "20240103-100000.11") {
+		t.Errorf("unexpected directories %s ... %s", runs[0].Dir, runs[11].Dir)
+	}
+
+	// An empty run leaves nothing behind and does not move latest
+	last := runs[len(runs)-1]
+	for _, run := range runs[:len(runs)-1] {
+		_ = os.WriteFile(run.place("doc.pdf"), []byte("pdf"), 0600)
+	}
+	if err := last.finish(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(last.Dir); !os.IsNotExist(err) {
+		t.Errorf("empty run directory should be removed, got %v", err)
+	}
+
+	// Pruning keeps the newest runs: .10 is newer than .2
+	if err := runs[10].finish(); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(root)
+	var kept []string
+	for _, e := range entries {
+		kept = append(kept, e.Name())
+	}
+	want := []string{"20240103-100000.10", "20240103-100000.8", "20240103-100000.9", "latest"}
+	if !slices.Equal(kept, want) {
+		t.Errorf("kept %v, want %v", kept, want)
+	}
+}
+
+func TestProcess_MaxPathLengthInBuildDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	build := filepath.Join(dir, "build")
+	// Room for the build directory, a timestamped run, and a 12-byte name
+	limit := len(build) + len("/20261018-023452/") + 12
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte(fmt.Sprintf("---\ntitle: An Extremely Long Document\nkeep-builds: 3\nbuild-dir: %s\nmax-path-length: %d\noutputs: [html, html5]\noutput-collision: suffix\n---\n# Doc\n", build, limit)), 0600)
+
+	rec := &argsRecorder{inputs: map[string]string{}, args: map[string][]string{}}
+	opts := options.Options{NoCache: true, Quiet: true, Force: true}
+	if _, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	var outputs []string
+	for _, args := range rec.args {
+		output := args[slices.Index(args, "--output")+1]
+		if strings.HasPrefix(filepath.Base(output), ".panforge-") {
+			output = filepath.Join(filepath.Dir(output), strings.SplitN(filepath.Base(output), "-", 3)[2])
+		}
+		outputs = append(outputs, output)
+	}
+	if len(outputs) != 2 {
+		t.Fatalf("expected two outputs, got %v", outputs)
+	}
+	for _, output := range outputs {
+		if len(output) > limit || !strings.HasPrefix(output, build+string(filepath.Separator)) {
+			t.Errorf("expected %s in the build directory within %d bytes, got %d", output, limit, len(output))
+		}
+	}
+	if !strings.HasSuffix(outputs[0], "-html5.html") && !strings.HasSuffix(outputs[1], "-html5.html") {
+		t.Errorf("expected the collision suffix kept, got %v", outputs)
+	}
+}
+
+func TestRunMany_KeepBuilds(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	build := filepath.Join(dir, "build")
+	// An older build that the run may prune
+	_ = os.MkdirAll(filepath.Join(build, "20240101-100000"), 0750)
+
+	var files []string
+	for _, name := range []string{"d1", "d2", "d3", "d4"} {
+		input := filepath.Join(dir, name+".md")
+		_ = os.WriteFile(input, []byte(fmt.Sprintf("---\ntitle: %s\nkeep-builds: 1\nbuild-dir: %s\nfilename-timestamps: false\n---\n# %s\n", name, build, name)), 0600)
+		files = append(files, input)
+	}
+
+	rec := &argsRecorder{inputs: map[string]string{}, args: map[string][]string{}}
+	opts := options.Options{Targets: []string{"html"}, NoCache: true, Quiet: true}
+	if _, err := runMany(context.Background(), files, nil, opts, rec); err != nil {
+		t.Fatalf("runMany failed: %v", err)
+	}
+
+	// Every file's output is in the run's one directory, which is the only build kept
+	runs, _ := filepath.Glob(filepath.Join(build, "2*"))
+	if len(runs) != 1 {
+		t.Fatalf("expected one build directory, got %v", runs)
+	}
+	for _, name := range []string{"d1", "d2", "d3", "d4"} {
+		if _, err := os.Stat(filepath.Join(runs[0], name+".html")); err != nil {
+			t.Errorf("output of %s was lost: %v", name, err)
+		}
+	}
+	if target, err := os.Readlink(filepath.Join(build, "latest")); err != nil || target != filepath.Base(runs[0]) {
+		t.Errorf("latest -> %q (%v)", target, err)
+	}
+}
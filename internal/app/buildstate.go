@@ -0,0 +1,157 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/rapjul/panforge/internal/cache"
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+// buildState remembers what the last successful workspace build produced, so documents
+// that did not change can be skipped without loading or preprocessing them.
+type buildState struct {
+	// Files maps each document's path to its last successful build.
+	Files map[string]builtFile `json:"files"`
+}
+
+// builtFile is the last successful build of one workspace document.
+type builtFile struct {
+	// Fingerprint hashes the document, the settings it was built with, and the pandoc version.
+	Fingerprint string `json:"fingerprint"`
+	// Outputs are the targets it produced.
+	Outputs []builtOutput `json:"outputs"`
+}
+
+// builtOutput is one output of a built document.
+type builtOutput struct {
+	Target string `json:"target"`
+	Format string `json:"format,omitempty"`
+	Output string `json:"output"`
+}
+
+// buildStatePath returns where the build state of a workspace is kept: in the build cache,
+// so --no-cache ignores it and `panforge cache clean` removes it.
+//
+// Parameters:
+//   - `ws`: the workspace
+func buildStatePath(ws *config.Workspace) string {
+	sum := sha256.Sum256([]byte(ws.Path))
+	return filepath.Join(cache.DefaultDir(), "workspaces", hex.EncodeToString(sum[:])+".json")
+}
+
+// loadBuildState reads a workspace's build state; a missing or unreadable file is an empty state.
+func loadBuildState(path string) *buildState {
+	state := &buildState{Files: map[string]builtFile{}}
+	//nolint:gosec // G304: the state file lives in the panforge cache directory
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return state
+	}
+	if err := json.Unmarshal(data, state); err != nil || state.Files == nil {
+		return &buildState{Files: map[string]builtFile{}}
+	}
+	return state
+}
+
+// save writes the build state.
+func (s *buildState) save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return fmt.Errorf("failed to save build state: %w", err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to save build state: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to save build state: %w", err)
+	}
+	return nil
+}
+
+// upToDate returns the results of a document whose last successful build had the same
+// fingerprint and whose outputs all still exist, or nil if it must be built.
+//
+// Parameters:
+//   - `input`: the document
+//   - `fingerprint`: the document's current fingerprint
+func (s *buildState) upToDate(input, fingerprint string) []TargetResult {
+	built, ok := s.Files[input]
+	if !ok || built.Fingerprint != fingerprint || len(built.Outputs) == 0 {
+		return nil
+	}
+	results := make([]TargetResult, 0, len(built.Outputs))
+	for _, out := range built.Outputs {
+		if _, err := os.Stat(out.Output); err != nil {
+			return nil
+		}
+		results = append(results, TargetResult{Target: out.Target, Format: out.Format, Output: out.Output, Status: StatusUpToDate})
+	}
+	return results
+}
+
+// record remembers a document's build if every target succeeded or was up to date,
+// and forgets it otherwise.
+//
+// Parameters:
+//   - `input`: the document
+//   - `fingerprint`: the fingerprint it was built with
+//   - `results`: its per-target results
+//   - `err`: its error, if any
+func (s *buildState) record(input, fingerprint string, results []TargetResult, err error) {
+	delete(s.Files, input)
+	if err != nil || len(results) == 0 {
+		return
+	}
+	built := builtFile{Fingerprint: fingerprint}
+	for _, r := range sortedResults(results) {
+		if (r.Status != StatusSuccess && r.Status != StatusUpToDate) || r.Output == "" {
+			return
+		}
+		built.Outputs = append(built.Outputs, builtOutput{Target: r.Target, Format: r.Format, Output: r.Output})
+	}
+	s.Files[input] = built
+}
+
+// documentFingerprint hashes what decides a workspace document's outputs: its content,
+// the shared files (workspace file, bibliography, citation style), the default config,
+// the options that change outputs, and the pandoc version. Files it includes are not followed.
+//
+// Parameters:
+//   - `input`: the document
+//   - `sharedFiles`: absolute paths of the files every document of the workspace depends on
+//   - `opts`: the document's options
+//   - `pandocVersion`: the first line of `pandoc --version`
+//
+// Returns:
+//   - string: the fingerprint
+//   - error: if the document could not be read
+func documentFingerprint(input string, sharedFiles []string, opts options.Options, pandocVersion string) (string, error) {
+	inputHash, err := cache.HashFile(input)
+	if err != nil {
+		return "", err
+	}
+	parts := []string{inputHash, pandocVersion,
+		strings.Join(opts.Targets, ","), strings.Join(opts.Matrix, ";"), strconv.Itoa(opts.SamplePages)}
+
+	files := append([]string(nil), sharedFiles...)
+	if path := config.FindProjectConfig(filepath.Dir(input)); path != "" {
+		files = append(files, path)
+	}
+	if path, _, err := loadDefaultConfig(opts.Config); err == nil {
+		files = append(files, path)
+	}
+	for _, f := range files {
+		// A missing file hashes as empty, so it changes the fingerprint once it appears
+		h, _ := cache.HashFile(f)
+		parts = append(parts, f, h)
+	}
+	return cache.ComputeKey(parts...), nil
+}
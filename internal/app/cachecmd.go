@@ -0,0 +1,124 @@
+package app
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/rapjul/panforge/internal/cache"
+)
+
+// RunCacheInfo prints the location, size, and record count of the build cache.
+//
+// Parameters:
+//   - `c`: the build cache
+//   - `w`: where the report is written
+func RunCacheInfo(c *cache.Cache, w io.Writer) error {
+	records, corrupt, err := c.Records()
+	if err != nil {
+		return fmt.Errorf("failed to read cache: %w", err)
+	}
+	size, files, err := c.Size()
+	if err != nil {
+		return fmt.Errorf("failed to measure cache: %w", err)
+	}
+	_, _ = fmt.Fprintf(w, "Location:  %s\n", c.Dir)
+	_, _ = fmt.Fprintf(w, "Records:   %d\n", len(records)+len(corrupt))
+	_, _ = fmt.Fprintf(w, "Files:     %d\n", files)
+	_, _ = fmt.Fprintf(w, "Size:      %s\n", formatBytes(size))
+	return nil
+}
+
+// RunCacheStats prints the cache's hit rate and how much space records and stored outputs use.
+//
+// Parameters:
+//   - `c`: the build cache
+//   - `w`: where the report is written
+func RunCacheStats(c *cache.Cache, w io.Writer) error {
+	st, err := c.ReadStats()
+	if err != nil {
+		return fmt.Errorf("failed to read cache stats: %w", err)
+	}
+	records, corrupt, err := c.Records()
+	if err != nil {
+		return fmt.Errorf("failed to read cache: %w", err)
+	}
+	objects, objectSize, err := c.Objects()
+	if err != nil {
+		return fmt.Errorf("failed to measure cache: %w", err)
+	}
+	size, _, err := c.Size()
+	if err != nil {
+		return fmt.Errorf("failed to measure cache: %w", err)
+	}
+
+	lookups := st.Hits + st.Restored + st.Misses
+	_, _ = fmt.Fprintf(w, "Lookups:   %d", lookups)
+	if !st.Since.IsZero() {
+		_, _ = fmt.Fprintf(w, " (since %s)", st.Since.Format("2006-01-02"))
+	}
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintf(w, "Hits:      %d up to date, %d restored\n", st.Hits, st.Restored)
+	_, _ = fmt.Fprintf(w, "Misses:    %d\n", st.Misses)
+	_, _ = fmt.Fprintf(w, "Hit rate:  %.1f%%\n", st.HitRate()*100)
+	_, _ = fmt.Fprintf(w, "Records:   %d\n", len(records)+len(corrupt))
+	_, _ = fmt.Fprintf(w, "Outputs:   %d (%s)\n", objects, formatBytes(objectSize))
+	_, _ = fmt.Fprintf(w, "Size:      %s\n", formatBytes(size))
+	return nil
+}
+
+// RunCacheClean removes build records from the cache.
+//
+// Parameters:
+//   - `c`: the build cache
+//   - `staleOnly`: remove only records that no longer match the filesystem
+//   - `w`: where the summary is written
+func RunCacheClean(c *cache.Cache, staleOnly bool, w io.Writer) error {
+	removed, err := c.Clean(staleOnly)
+	if err != nil {
+		return fmt.Errorf("failed to clean cache: %w", err)
+	}
+	_, _ = fmt.Fprintf(w, "Removed %d cache record(s)\n", removed)
+	return nil
+}
+
+// RunCacheVerify reports cache records that no longer match the filesystem.
+// It returns an error if any stale records are found.
+//
+// Parameters:
+//   - `c`: the build cache
+//   - `w`: where the report is written
+func RunCacheVerify(c *cache.Cache, w io.Writer) error {
+	problems, err := c.Verify()
+	if err != nil {
+		return fmt.Errorf("failed to verify cache: %w", err)
+	}
+	if len(problems) == 0 {
+		_, _ = fmt.Fprintln(w, "All cache records are valid.")
+		return nil
+	}
+	for _, p := range problems {
+		name := p.Record.Output
+		if name == "" {
+			name = p.Record.Path
+		}
+		_, _ = fmt.Fprintf(w, "%s: %s\n", p.Reason, name)
+	}
+	return fmt.Errorf("%d stale cache record(s) found (run `panforge cache clean --stale` to remove them)", len(problems))
+}
+
+// formatBytes renders a byte count with a binary unit suffix.
+//
+// Parameters:
+//   - `n`: the number of bytes
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,62 @@
+package app
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/cache"
+)
+
+func TestCacheCommands(t *testing.T) {
+	dir := t.TempDir()
+	c := cache.New(filepath.Join(dir, "cache"))
+	out := filepath.Join(dir, "doc.html")
+	_ = os.WriteFile(out, []byte("x"), 0600)
+	if err := c.Store(cache.Record{Key: "k", Output: out}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := RunCacheInfo(c, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Records:   1") {
+		t.Errorf("unexpected info output:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	_ = c.AddStats(cache.Stats{Hits: 1, Misses: 1})
+	if err := RunCacheStats(c, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Hit rate:  50.0%") || !strings.Contains(buf.String(), "Outputs:   1") {
+		t.Errorf("unexpected stats output:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := RunCacheVerify(c, &buf); err != nil {
+		t.Errorf("verify of valid cache failed: %v", err)
+	}
+
+	_ = os.Remove(out)
+	buf.Reset()
+	if err := RunCacheVerify(c, &buf); err == nil || !strings.Contains(buf.String(), "output missing") {
+		t.Errorf("verify did not report missing output: %v\n%s", err, buf.String())
+	}
+
+	buf.Reset()
+	if err := RunCacheClean(c, true, &buf); err != nil || !strings.Contains(buf.String(), "Removed 1") {
+		t.Errorf("clean --stale: %v\n%s", err, buf.String())
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{512: "512 B", 2048: "2.0 KiB", 5 << 20: "5.0 MiB"} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %s, want %s", n, got, want)
+		}
+	}
+}
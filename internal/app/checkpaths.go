@@ -0,0 +1,194 @@
+package app
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/pandoc"
+)
+
+// pathFlags are pandoc options whose value is a file. The bool reports whether pandoc
+// also looks the value up in its user data directory, so a bare name may be valid
+// without a local file.
+var pathFlags = map[string]bool{
+	"--css":                    false,
+	"-c":                       false,
+	"--template":               true,
+	"--bibliography":           false,
+	"--csl":                    true,
+	"--citation-abbreviations": false,
+	"--reference-doc":          true,
+	"--include-in-header":      false,
+	"-H":                       false,
+	"--include-before-body":    false,
+	"-B":                       false,
+	"--include-after-body":     false,
+	"-A":                       false,
+	"--lua-filter":             true,
+	"-L":                       true,
+	"--epub-cover-image":       false,
+	"--epub-metadata":          false,
+	"--epub-embed-font":        false,
+	"--abbreviations":          false,
+	"--syntax-definition":      false,
+	"--defaults":               true,
+	"-d":                       true,
+}
+
+// pathMetadataKeys are document metadata fields pandoc reads files from.
+var pathMetadataKeys = []string{"bibliography", "csl"}
+
+// markdownImage matches the target of a Markdown image, e.g. ![alt](images/a.png "title").
+var markdownImage = regexp.MustCompile(`!\[[^\]]*\]\(\s*<?([^)\s>]+)>?`)
+
+// missingPath is a referenced file that does not exist or cannot be read.
+type missingPath struct {
+	// Source describes where the path came from (e.g. "--css", "image").
+	Source string
+	// Path is the path as written.
+	Path string
+	// Target is the target that references it ("" for the document itself).
+	Target string
+}
+
+// checkPaths verifies that every file referenced by the resolved pandoc arguments,
+// the document metadata, and the document's images exists and is readable.
+//
+// Parameters:
+//   - `sourceFile`: the document pandoc will read
+//   - `cfg`: the global config
+//   - `targets`: the targets being built
+//   - `postArgs`: extra pandoc arguments from the command line
+//
+// Returns:
+//   - error: a single error listing every missing path, or nil
+func checkPaths(sourceFile string, cfg *config.Config, targets []string, postArgs []string) error {
+	var missing []missingPath
+	seen := make(map[string]bool)
+	report := func(m missingPath) {
+		key := m.Source + "\x00" + m.Path + "\x00" + m.Target
+		if !seen[key] {
+			seen[key] = true
+			missing = append(missing, m)
+		}
+	}
+
+	for _, key := range pathMetadataKeys {
+		for _, p := range toStringSlice(cfg.Generic[key]) {
+			if !isReadable(p) {
+				report(missingPath{Source: key, Path: p})
+			}
+		}
+	}
+
+	// Images resolve from the document's directory too (see resourcePathArgs)
+	resourcePaths := []string{filepath.Dir(sourceFile), "."}
+	scan := func(args []string, target string) {
+		for i := 0; i < len(args); i++ {
+			name, value, hasValue := strings.Cut(args[i], "=")
+			if name == "--resource-path" {
+				if !hasValue && i+1 < len(args) {
+					i++
+					value = args[i]
+				}
+				resourcePaths = filepath.SplitList(value)
+				continue
+			}
+			dataDir, ok := pathFlags[name]
+			if !ok {
+				continue
+			}
+			if !hasValue {
+				if i+1 >= len(args) {
+					return
+				}
+				i++
+				value = args[i]
+			}
+			if isRemote(value) || isReadable(value) {
+				continue
+			}
+			if dataDir && !strings.ContainsAny(value, `/\`) {
+				continue // may live in pandoc's user data directory
+			}
+			report(missingPath{Source: name, Path: value, Target: target})
+		}
+	}
+	for _, t := range targets {
+		_, metaOut := resolveTarget(cfg, t)
+		// GetArgs consumes pandoc-args, so work on a copy of the target's config
+		meta := make(map[string]interface{}, len(metaOut))
+		for k, v := range metaOut {
+			meta[k] = v
+		}
+		scan(append(readerArgs(cfg, metaOut, sourceFile, ""), pandoc.GetArgs(meta)...), t)
+	}
+	scan(postArgs, "")
+
+	//nolint:gosec // G304: reading the input file is intended
+	data, err := os.ReadFile(sourceFile)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", sourceFile, err)
+	}
+	for _, m := range markdownImage.FindAllStringSubmatch(string(data), -1) {
+		p := m[1]
+		if isRemote(p) {
+			continue
+		}
+		if decoded, err := url.PathUnescape(p); err == nil {
+			p = decoded
+		}
+		found := false
+		for _, dir := range resourcePaths {
+			if isReadable(filepath.Join(dir, p)) || (filepath.IsAbs(p) && isReadable(p)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			report(missingPath{Source: "image", Path: m[1]})
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.SliceStable(missing, func(i, j int) bool { return missing[i].Path < missing[j].Path })
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d referenced file(s) missing or unreadable:", len(missing))
+	for _, m := range missing {
+		fmt.Fprintf(&sb, "\n  - %s (%s", m.Path, m.Source)
+		if m.Target != "" {
+			fmt.Fprintf(&sb, ", target %s", m.Target)
+		}
+		sb.WriteString(")")
+	}
+	return fmt.Errorf("%s", sb.String())
+}
+
+// isRemote reports whether a reference is a URL rather than a local file.
+func isRemote(p string) bool {
+	if strings.HasPrefix(p, "data:") {
+		return true
+	}
+	u, err := url.Parse(p)
+	return err == nil && u.Scheme != "" && len(u.Scheme) > 1 && u.Host != ""
+}
+
+// isReadable reports whether a path names a regular file that can be opened.
+func isReadable(p string) bool {
+	//nolint:gosec // G304: only probing referenced files
+	f, err := os.Open(p)
+	if err != nil {
+		return false
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	return err == nil && !info.IsDir()
+}
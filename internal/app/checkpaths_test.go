@@ -0,0 +1,59 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/config"
+)
+
+func TestCheckPaths(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	_ = os.MkdirAll("img", 0750)
+	for _, name := range []string{"style.css", "refs.bib", "img/ok.png"} {
+		_ = os.WriteFile(name, []byte("x"), 0600)
+	}
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte("# Doc\n\n![ok](img/ok.png)\n![gone](img/gone.png \"title\")\n![web](https://example.com/a.png)\n"), 0600)
+
+	cfg := &config.Config{
+		OutputMap: map[string]interface{}{
+			"html": map[string]interface{}{"css": "style.css", "include-in-header": "header.html"},
+			"docx": map[string]interface{}{"reference-doc": "templates/ref.docx", "template": "eisvogel"},
+		},
+		Generic: map[string]interface{}{"bibliography": []interface{}{"refs.bib", "missing.bib"}},
+	}
+
+	err := checkPaths(input, cfg, []string{"html", "docx"}, []string{"--lua-filter", "./filters/x.lua"})
+	if err == nil {
+		t.Fatal("expected missing paths to be reported")
+	}
+	msg := err.Error()
+	for _, want := range []string{
+		"5 referenced file(s)",
+		"header.html (--include-in-header, target html)",
+		"templates/ref.docx (--reference-doc, target docx)",
+		"missing.bib (bibliography)",
+		"img/gone.png (image)",
+		"./filters/x.lua (--lua-filter)",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error missing %q:\n%s", want, msg)
+		}
+	}
+	for _, unwanted := range []string{"style.css", "refs.bib", "ok.png", "example.com", "eisvogel"} {
+		if strings.Contains(msg, unwanted) {
+			t.Errorf("error should not mention %q:\n%s", unwanted, msg)
+		}
+	}
+
+	// GetArgs must not consume the target's pandoc_args during the check
+	cfg.OutputMap["html"].(map[string]interface{})["pandoc_args"] = []interface{}{"--toc"}
+	_ = checkPaths(input, cfg, []string{"html"}, nil)
+	if _, ok := cfg.OutputMap["html"].(map[string]interface{})["pandoc_args"]; !ok {
+		t.Error("checkPaths modified the target config")
+	}
+}
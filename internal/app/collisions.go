@@ -0,0 +1,206 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+	"github.com/rapjul/panforge/internal/pandoc"
+	"github.com/rapjul/panforge/internal/utils"
+)
+
+// What to do when two targets of a run resolve to the same output (`output-collision`).
+const (
+	collisionError  = "error"
+	collisionSuffix = "suffix"
+)
+
+// targetPlan is what is decided about a target before any target runs.
+type targetPlan struct {
+	// format is the resolved pandoc output format.
+	format string
+	// meta is the format-specific config, with the cell's options.
+	meta map[string]interface{}
+	// metadata are the --metadata arguments of the top-level metadata computed by
+	// template expressions.
+	metadata []string
+	// buildTime is the fixed time of a reproducible build, or the zero time.
+	buildTime time.Time
+	// output is the resolved output path.
+	output string
+	// fit keeps output within `max-path-length`, if the name is panforge's to shorten.
+	fit func(path string) (string, error)
+	// unfit is output before fit shortened it.
+	unfit string
+	// err is why the target cannot run; it fails when its turn comes.
+	err error
+}
+
+// planTarget resolves the format and output path of a target.
+//
+// Parameters:
+//   - `ctx`: context for cancellation
+//   - `cfg`: the global config
+//   - `cell`: the target and its matrix values
+//   - `namingInput`: the document the output is named after
+//   - `opts`: runtime options
+//   - `env`: the process environment
+//   - `run`: the numbered build directory, or nil
+//   - `sandboxed`: whether the document is sandboxed
+func planTarget(ctx context.Context, cfg *config.Config, cell matrixCell, namingInput string, opts options.Options, env processEnv, run *buildRun, sandboxed bool) *targetPlan {
+	t := cell.Target
+	plan := &targetPlan{}
+	plan.format, plan.meta = resolveTarget(cfg, t)
+	plan.meta = cell.options(plan.meta)
+
+	// A reproducible build uses one fixed time for names and for the tools it runs
+	buildTime, err := resolveReproducible(cfg, plan.meta, namingInput)
+	if err != nil {
+		plan.err = fmt.Errorf("target %s: %w", t, configError(err))
+		return plan
+	}
+	plan.buildTime = buildTime
+
+	// Evaluate the template expressions in the target's options and the metadata
+	tc := newTemplateContext(cfg, t, plan.format, namingInput, buildTime)
+	plan.meta = expandTemplates(plan.meta, tc)
+	plan.metadata = templateMetadataArgs(cfg, tc)
+
+	// Generate Output Filename
+	outputFile := opts.Output
+	if outputFile == "" {
+		req := namingRequest{Input: namingInput, Target: t, Format: plan.format, Config: cfg, Meta: plan.meta, BaseDir: env.baseDir, Now: buildTime, DryRun: opts.DryRun}
+		req, distinct := cell.naming(req)
+		outputFile, err = outputFilename(ctx, req, sandboxed || isSandboxed(plan.meta))
+		if err != nil {
+			plan.err = fmt.Errorf("target %s: %w", t, err)
+			return plan
+		}
+		if plan.fit, err = pathFitter(cfg, req.Meta, outputFile); err != nil {
+			plan.err = fmt.Errorf("target %s: %w", t, configError(err))
+			return plan
+		}
+		if !distinct {
+			outputFile = cell.suffix(outputFile)
+		}
+		if run != nil {
+			outputFile = run.place(outputFile)
+		}
+		if opts.OutputDir != "" && !filepath.IsAbs(outputFile) {
+			outputFile = filepath.Join(opts.OutputDir, outputFile)
+		}
+	} else if !cell.tokenized(outputFile) {
+		outputFile = cell.suffix(outputFile)
+	} else {
+		outputFile = cell.expand(outputFile)
+	}
+
+	// Resolve output file path
+	resolvedOutput, err := resolveIn(env.baseDir, outputFile)
+	if err != nil {
+		plan.err = fmt.Errorf("failed to resolve output file path: %w", err)
+		return plan
+	}
+	outputFile = resolvedOutput
+	if opts.SamplePages > 0 && isPDFOutput(outputFile) && opts.Output == "" {
+		outputFile = sampleOutput(outputFile)
+	}
+	// The limit applies to the final path, in the build directory and with all suffixes
+	if plan.fit != nil {
+		plan.unfit = outputFile
+		if outputFile, err = plan.fit(outputFile); err != nil {
+			plan.err = fmt.Errorf("target %s: %w", t, err)
+			return plan
+		}
+	}
+	plan.output = outputFile
+	return plan
+}
+
+// pathFitter returns the function that keeps a target's output path within its
+// `max-path-length`, or nil if it has none or the name was set with `output`. Only the
+// generated name may be cut; suffixes added to it later, such as a matrix cell's values,
+// `.sample`, or a collision suffix, are kept.
+//
+// Parameters:
+//   - `cfg`: the global config
+//   - `meta`: the target's options
+//   - `name`: the generated output name
+//
+// Returns:
+//   - func(path string) (string, error): shortens a final output path, or nil
+//   - error: if the setting is invalid
+func pathFitter(cfg *config.Config, meta map[string]interface{}, name string) (func(path string) (string, error), error) {
+	limit, hash, err := pandoc.PathLimit(cfg, meta)
+	if err != nil || limit == 0 {
+		return nil, err
+	}
+	if s, ok := meta["output"].(string); ok && s != "" {
+		return nil, nil
+	}
+	base := filepath.Base(name)
+	stem := len(base) - len(filepath.Ext(base))
+	return func(path string) (string, error) {
+		return pandoc.FitPathLength(path, stem, limit, hash)
+	}, nil
+}
+
+// resolveCollisions finds targets that would write the same file; run concurrently,
+// they would clobber each other. With `output-collision: suffix` the later ones get the
+// target as a suffix (doc.pdf becomes doc-beamer.pdf), otherwise it is an error.
+//
+// Parameters:
+//   - `cfg`: the global config
+//   - `cells`: the targets
+//   - `plans`: their plans, in the same order; outputs are renamed in place
+//
+// Returns:
+//   - error: listing the collisions that remain
+func resolveCollisions(cfg *config.Config, cells []matrixCell, plans []*targetPlan) error {
+	owners := make(map[string]int, len(plans))
+	var collisions []string
+	for i, plan := range plans {
+		if plan.err != nil {
+			continue
+		}
+		first, taken := owners[plan.output]
+		if !taken {
+			owners[plan.output] = i
+			continue
+		}
+		policy := stringSetting(cfg, plan.meta, "output-collision")
+		switch policy {
+		case "", collisionError:
+		case collisionSuffix:
+			output := plan.output
+			if plan.fit != nil {
+				output = plan.unfit
+			}
+			ext := filepath.Ext(output)
+			renamed := strings.TrimSuffix(output, ext) + "-" + utils.Slugify(cells[i].label()) + ext
+			if plan.fit != nil {
+				fitted, err := plan.fit(renamed)
+				if err != nil {
+					return err
+				}
+				renamed = fitted
+			}
+			if _, taken := owners[renamed]; !taken {
+				plan.output = renamed
+				owners[renamed] = i
+				continue
+			}
+		default:
+			return fmt.Errorf("output-collision: unknown policy %q (use %s or %s)", policy, collisionError, collisionSuffix)
+		}
+		collisions = append(collisions, fmt.Sprintf("targets %s and %s both write %s", cells[first].label(), cells[i].label(), plan.output))
+	}
+	if len(collisions) > 0 {
+		return fmt.Errorf("%s (name them apart, or set output-collision: suffix)", strings.Join(collisions, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+func TestResolveCollisions(t *testing.T) {
+	cells := []matrixCell{{Target: "pdf"}, {Target: "beamer"}, {Target: "html"}}
+	plans := func() []*targetPlan {
+		return []*targetPlan{{output: "/out/doc.pdf"}, {output: "/out/doc.pdf"}, {output: "/out/doc.html"}}
+	}
+
+	err := resolveCollisions(&config.Config{Generic: map[string]interface{}{}}, cells, plans())
+	if err == nil || !strings.Contains(err.Error(), "targets pdf and beamer both write /out/doc.pdf") {
+		t.Errorf("expected a collision error, got %v", err)
+	}
+
+	p := plans()
+	cfg := &config.Config{Generic: map[string]interface{}{"output-collision": "suffix"}}
+	if err := resolveCollisions(cfg, cells, p); err != nil {
+		t.Fatal(err)
+	}
+	if p[0].output != "/out/doc.pdf" || p[1].output != "/out/doc-beamer.pdf" || p[2].output != "/out/doc.html" {
+		t.Errorf("expected the later target to get a suffix, got %s, %s, %s", p[0].output, p[1].output, p[2].output)
+	}
+
+	if err := resolveCollisions(&config.Config{Generic: map[string]interface{}{"output-collision": "merge"}}, cells, plans()); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+}
+
+func TestProcess_OutputCollision(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	input := filepath.Join(dir, "doc.md")
+	header := "---\nfilename-template: \"{title}.{ext}\"\noutputs: [pdf, beamer]\n"
+	_ = os.WriteFile(input, []byte(header+"---\n# Doc\n"), 0600)
+
+	rec := &envRecorder{}
+	opts := options.Options{NoCache: true, Quiet: true}
+	if _, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir}); err == nil || !strings.Contains(err.Error(), "both write") {
+		t.Fatalf("expected a collision error, got %v", err)
+	}
+	if len(rec.args) != 0 {
+		t.Errorf("expected no target to run, got %v", rec.args)
+	}
+
+	_ = os.WriteFile(input, []byte(header+"output-collision: suffix\n---\n# Doc\n"), 0600)
+	if _, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Doc.pdf", "Doc-beamer.pdf"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("expected %s: %v", name, err)
+		}
+	}
+}
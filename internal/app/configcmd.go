@@ -0,0 +1,246 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+	"github.com/rapjul/panforge/internal/templates"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultConfigFile returns the default config that the `config` commands work on: the
+// file --config names, else default.yaml (or .yml, .toml, .json) in the data
+// directory. A config that does not exist yet is a YAML file there.
+//
+// Parameters:
+//   - `name`: the --config value
+func defaultConfigFile(name string) string {
+	if name == "" {
+		name = "default"
+	}
+	if strings.ContainsAny(name, "./\\") {
+		return name
+	}
+	if path := config.FindDefaultConfig(name); path != "" {
+		return path
+	}
+	return filepath.Join(config.DataDirName(), name+".yaml")
+}
+
+// RunConfigGet prints one setting of the default config: a scalar as written, a map or
+// list as YAML.
+//
+// Parameters:
+//   - `key`: the setting, e.g. `output.pdf.pdf-engine`
+//   - `opts`: runtime options (Config)
+//   - `w`: where the value is written
+//
+// Returns:
+//   - error: if the key is not set or the config is invalid
+func RunConfigGet(key string, opts options.Options, w io.Writer) error {
+	path := defaultConfigFile(opts.Config)
+	node, err := config.GetValue(path, key)
+	if errors.Is(err, config.ErrKeyNotSet) {
+		return fmt.Errorf("%w in %s", err, path)
+	}
+	if err != nil {
+		return configError(err)
+	}
+	if node.Kind == yaml.ScalarNode {
+		_, _ = fmt.Fprintln(w, node.Value)
+		return nil
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(node); err != nil {
+		return err
+	}
+	_ = enc.Close()
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// RunConfigSet sets one setting of the default config, creating it if needed.
+//
+// Parameters:
+//   - `key`: the setting, e.g. `output.pdf.pdf-engine`
+//   - `value`: the new value, as YAML
+//   - `opts`: runtime options (Config, Quiet)
+//   - `w`: where the confirmation is written
+//
+// Returns:
+//   - error: if the config cannot be edited
+func RunConfigSet(key, value string, opts options.Options, w io.Writer) error {
+	path := defaultConfigFile(opts.Config)
+	if err := config.SetValue(path, key, value); err != nil {
+		return configError(err)
+	}
+	if !opts.Quiet {
+		_, _ = fmt.Fprintf(w, "Set %s in %s\n", key, path)
+	}
+	return nil
+}
+
+// editorCommand returns the editor to open configs in: $VISUAL, else $EDITOR, else vi
+// (notepad on Windows).
+func editorCommand() string {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if editor := strings.TrimSpace(os.Getenv(name)); editor != "" {
+			return editor
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return "notepad"
+	}
+	return "vi"
+}
+
+// runEditor opens a file in the editor and waits for it to exit; replaced in tests. Like
+// git, the editor is run by the shell, so it may carry arguments (`code --wait`).
+var runEditor = func(editor, path string) error {
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+	//nolint:gosec // G204: the editor comes from the user's environment
+	cmd := exec.Command(shell, flag, editor+" "+shellQuote(path))
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+// RunConfigEdit opens the default config, or with project the project config, in the
+// editor, creating it from the config template first if it does not exist. The edited
+// config is checked against the schema afterwards.
+//
+// Parameters:
+//   - `project`: edit the nearest project config (a new one goes in the current directory)
+//   - `opts`: runtime options (Config, Quiet)
+//   - `w`: where messages and schema problems are written
+//
+// Returns:
+//   - error: if the config cannot be created or the editor fails
+func RunConfigEdit(project bool, opts options.Options, w io.Writer) error {
+	path := defaultConfigFile(opts.Config)
+	if project {
+		if path = config.FindProjectConfig("."); path == "" {
+			path = config.ProjectFileName
+		}
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		content, err := templates.GetConfigTemplate()
+		if err != nil {
+			return fmt.Errorf("failed to load config template: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			return err
+		}
+		if !opts.Quiet {
+			_, _ = fmt.Fprintf(w, "Created %s\n", path)
+		}
+	}
+
+	editor := editorCommand()
+	if err := runEditor(editor, path); err != nil {
+		return fmt.Errorf("editor %s failed: %w", editor, err)
+	}
+
+	problems, err := config.ValidateFile(path)
+	if err != nil {
+		_, _ = fmt.Fprintf(w, "Warning: %v\n", err)
+	}
+	for _, p := range problems {
+		_, _ = fmt.Fprintf(w, "Warning: %s\n", p)
+	}
+	return nil
+}
+
+// RunConfigPath prints the path of the default config that the `config` commands and
+// conversions use, whether or not it exists yet.
+//
+// Parameters:
+//   - `opts`: runtime options (Config)
+//   - `w`: where the path is written
+func RunConfigPath(opts options.Options, w io.Writer) error {
+	path, err := filepath.Abs(defaultConfigFile(opts.Config))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, path)
+	return err
+}
+
+// RunConfigList prints the data directory and the config files a conversion of
+// inputFile would load, in the order they are applied: the per-format options of the
+// formats directory, the default config, the project config, and the document's front
+// matter, each after the files it includes.
+//
+// Parameters:
+//   - `inputFile`: the document, or "" for the configs that apply in the current directory
+//   - `opts`: runtime options (Config)
+//   - `w`: where the list is written
+//
+// Returns:
+//   - error: if an include cannot be resolved
+func RunConfigList(inputFile string, opts options.Options, w io.Writer) error {
+	_, _ = fmt.Fprintf(w, "Data directory: %s\n", config.DataDirName())
+	_, _ = fmt.Fprintln(w, "Config files, in the order they are applied (later ones override earlier ones):")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	list := func(label, path string) error {
+		if _, err := os.Stat(path); err != nil {
+			_, _ = fmt.Fprintf(tw, "  %s\t%s (not found)\n", label, path)
+			return nil
+		}
+		includes, err := config.IncludedFiles(path)
+		if err != nil {
+			return configError(err)
+		}
+		for _, include := range includes {
+			_, _ = fmt.Fprintf(tw, "  %s include\t%s\n", label, include)
+		}
+		_, _ = fmt.Fprintf(tw, "  %s\t%s\n", label, path)
+		return nil
+	}
+
+	for _, ext := range config.ConfigExts {
+		formats, _ := filepath.Glob(filepath.Join(config.DataDirName(), config.FormatDefaultsDir, "*"+ext))
+		for _, path := range formats {
+			_, _ = fmt.Fprintf(tw, "  format options\t%s\n", path)
+		}
+	}
+	defaultPath, _ := filepath.Abs(defaultConfigFile(opts.Config))
+	if err := list("default config", defaultPath); err != nil {
+		return err
+	}
+	dir := "."
+	if inputFile != "" {
+		dir = filepath.Dir(inputFile)
+	}
+	if project := config.FindProjectConfig(dir); project != "" {
+		if err := list("project config", project); err != nil {
+			return err
+		}
+	} else {
+		_, _ = fmt.Fprintf(tw, "  project config\t(none)\n")
+	}
+	if inputFile != "" {
+		abs, _ := filepath.Abs(inputFile)
+		if err := list("front matter", abs); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
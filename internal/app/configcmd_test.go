@@ -0,0 +1,117 @@
+package app
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+func TestRunConfigGetSet(t *testing.T) {
+	t.Setenv("APPDATA", t.TempDir())
+	var out bytes.Buffer
+	if err := RunConfigSet("output.pdf.pdf-engine", "xelatex", options.Options{}, &out); err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(config.DataDirName(), "default.yaml")
+	if out.String() != "Set output.pdf.pdf-engine in "+want+"\n" {
+		t.Errorf("unexpected confirmation %q", out.String())
+	}
+
+	out.Reset()
+	if err := RunConfigGet("output.pdf.pdf-engine", options.Options{}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "xelatex\n" {
+		t.Errorf("expected the value, got %q", out.String())
+	}
+	out.Reset()
+	if err := RunConfigGet("output.pdf", options.Options{}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "pdf-engine: xelatex\n" {
+		t.Errorf("expected the map as YAML, got %q", out.String())
+	}
+	if err := RunConfigGet("output.html", options.Options{}, &out); err == nil || ExitCode(err) == ExitConfig {
+		t.Errorf("expected a plain error for an unset key, got %v", err)
+	}
+}
+
+func TestRunConfigEdit(t *testing.T) {
+	t.Setenv("APPDATA", t.TempDir())
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "nano -w")
+	var gotEditor, gotPath string
+	orig := runEditor
+	t.Cleanup(func() { runEditor = orig })
+	runEditor = func(editor, path string) error {
+		gotEditor, gotPath = editor, path
+		data, err := os.ReadFile(path)
+		if err != nil || len(data) == 0 {
+			t.Errorf("expected the config to be created from the template, got %v", err)
+		}
+		return os.WriteFile(path, []byte("sandbox: maybe\n"), 0600)
+	}
+
+	var out bytes.Buffer
+	if err := RunConfigEdit(false, options.Options{}, &out); err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(config.DataDirName(), "default.yaml")
+	if gotEditor != "nano -w" || gotPath != want {
+		t.Errorf("expected nano -w on %s, got %q on %s", want, gotEditor, gotPath)
+	}
+	if !strings.Contains(out.String(), "Created "+want) || !strings.Contains(out.String(), "Warning: "+want+":1:10: sandbox: expected a boolean, got string") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+
+	dir := t.TempDir()
+	t.Chdir(dir)
+	_ = os.MkdirAll("sub", 0750)
+	_ = os.WriteFile(config.ProjectFileName, []byte("toc: true\n"), 0600)
+	t.Chdir(filepath.Join(dir, "sub"))
+	if err := RunConfigEdit(true, options.Options{Quiet: true}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if gotPath != filepath.Join(dir, config.ProjectFileName) {
+		t.Errorf("expected the nearest project config, got %s", gotPath)
+	}
+}
+
+func TestRunConfigList(t *testing.T) {
+	t.Setenv("APPDATA", t.TempDir())
+	data := config.DataDirName()
+	_ = os.MkdirAll(data, 0750)
+	_ = os.WriteFile(filepath.Join(data, "team.yaml"), []byte("toc: true\n"), 0600)
+	_ = os.WriteFile(filepath.Join(data, "default.yaml"), []byte("include: team.yaml\n"), 0600)
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, config.ProjectFileName), []byte("toc: false\n"), 0600)
+	doc := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(doc, []byte("---\ntitle: Doc\n---\n"), 0600)
+
+	var out bytes.Buffer
+	if err := RunConfigList(doc, options.Options{}, &out); err != nil {
+		t.Fatal(err)
+	}
+	want := "Data directory: " + data + "\n" +
+		"Config files, in the order they are applied (later ones override earlier ones):\n" +
+		"  default config include  " + filepath.Join(data, "team.yaml") + "\n" +
+		"  default config          " + filepath.Join(data, "default.yaml") + "\n" +
+		"  project config          " + filepath.Join(dir, config.ProjectFileName) + "\n" +
+		"  front matter            " + doc + "\n"
+	if out.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	if err := RunConfigPath(options.Options{Config: "work"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != filepath.Join(data, "work.yaml")+"\n" {
+		t.Errorf("expected the path a new work config would get, got %q", out.String())
+	}
+}
@@ -0,0 +1,171 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/pandoc"
+	"github.com/rapjul/panforge/internal/utils"
+)
+
+// templateOpen and templateClose delimit the Go-template expressions in config values,
+// e.g. `output: "{{= .Title | slug }}-{{= .Format }}.pdf"`. The `=` keeps the braces of
+// LaTeX (`{{\bf #1}}`) and of pandoc's own templates from being read as expressions.
+const (
+	templateOpen  = "{{="
+	templateClose = "}}"
+)
+
+// templateContext is what the expressions in config values see.
+type templateContext struct {
+	// Title is the document title.
+	Title string
+	// Author is the document author.
+	Author string
+	// Date is the document's `date`, or the build date as YYYY-MM-DD.
+	Date string
+	// Target is the target name.
+	Target string
+	// Format is the pandoc output format.
+	Format string
+	// Ext is the output extension, without the dot.
+	Ext string
+	// Input is the document's file name.
+	Input string
+	// Stem is the document's file name without its extension.
+	Stem string
+	// Meta holds the top-level settings and metadata.
+	Meta map[string]interface{}
+}
+
+// configTemplateFuncs are the functions expressions may call. They only transform
+// values, so expressions are safe in documents from elsewhere.
+var configTemplateFuncs = template.FuncMap{
+	"slug":  utils.Slugify,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"trim":  strings.TrimSpace,
+	"replace": func(old, new, s string) string {
+		return strings.ReplaceAll(s, old, new)
+	},
+	"default": func(fallback string, v interface{}) string {
+		if v == nil || fmt.Sprint(v) == "" {
+			return fallback
+		}
+		return fmt.Sprint(v)
+	},
+}
+
+// newTemplateContext describes a target to the expressions in its config.
+//
+// Parameters:
+//   - `cfg`: the document config
+//   - `target`: the target name
+//   - `format`: the pandoc output format
+//   - `inputFile`: the document
+//   - `now`: the build time, or the zero time for the clock
+func newTemplateContext(cfg *config.Config, target, format, inputFile string, now time.Time) templateContext {
+	if now.IsZero() {
+		now = time.Now()
+	}
+	date := now.Format("2006-01-02")
+	if d, ok := cfg.Generic["date"].(string); ok && d != "" && !strings.Contains(d, templateOpen) {
+		date = d
+	}
+	base := filepath.Base(inputFile)
+	return templateContext{
+		Title:  cfg.Title,
+		Author: cfg.Author,
+		Date:   date,
+		Target: target,
+		Format: format,
+		Ext:    pandoc.ExtForFormat(format),
+		Input:  base,
+		Stem:   strings.TrimSuffix(base, filepath.Ext(base)),
+		Meta:   cfg.Generic,
+	}
+}
+
+// expandTemplates evaluates the expressions in a target's options, in strings nested in
+// lists and maps too. The options are copied where they change, since they are shared
+// with the config.
+//
+// Parameters:
+//   - `meta`: the target's options
+//   - `tc`: the target's context
+//
+// Returns:
+//   - map[string]interface{}: the options with their expressions evaluated
+func expandTemplates(meta map[string]interface{}, tc templateContext) map[string]interface{} {
+	out, _ := expandTemplateValue(meta, tc).(map[string]interface{})
+	return out
+}
+
+// expandTemplateValue evaluates the expressions in a decoded YAML value. A string whose
+// expressions cannot be evaluated is kept as written, as pandoc would have read it.
+func expandTemplateValue(v interface{}, tc templateContext) interface{} {
+	switch v := v.(type) {
+	case string:
+		if !strings.Contains(v, templateOpen) {
+			return v
+		}
+		tmpl, err := template.New("").Delims(templateOpen, templateClose).Funcs(configTemplateFuncs).Parse(v)
+		if err != nil {
+			return v
+		}
+		var b bytes.Buffer
+		if err := tmpl.Execute(&b, tc); err != nil {
+			return v
+		}
+		return b.String()
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = expandTemplateValue(item, tc)
+		}
+		return out
+	case map[string]interface{}:
+		if v == nil {
+			return v
+		}
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			out[k] = expandTemplateValue(item, tc)
+		}
+		return out
+	}
+	return v
+}
+
+// templateMetadataArgs evaluates the expressions in the top-level metadata of a
+// document, which pandoc would otherwise read as written, and passes the results with
+// --metadata, which wins over the header.
+//
+// Parameters:
+//   - `cfg`: the document config
+//   - `tc`: the target's context
+//
+// Returns:
+//   - []string: the --metadata arguments
+func templateMetadataArgs(cfg *config.Config, tc templateContext) []string {
+	keys := make([]string, 0, len(cfg.Generic))
+	for k, v := range cfg.Generic {
+		if s, ok := v.(string); ok && strings.Contains(s, templateOpen) && !pandoc.IsPanforgeKey(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var args []string
+	for _, k := range keys {
+		if v := expandTemplateValue(cfg.Generic[k], tc); v != cfg.Generic[k] {
+			args = append(args, "--metadata", fmt.Sprintf("%s=%v", k, v))
+		}
+	}
+	return args
+}
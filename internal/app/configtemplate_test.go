@@ -0,0 +1,80 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+func TestExpandTemplates(t *testing.T) {
+	cfg := &config.Config{Title: "My Report", Generic: map[string]interface{}{"client": "ACME"}}
+	tc := newTemplateContext(cfg, "print", "latex", "/docs/report.md", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC))
+	meta := map[string]interface{}{
+		"output":    "{{= .Title | slug }}-{{= .Format }}.pdf",
+		"variables": map[string]interface{}{"footer": "{{= .Meta.client | lower }} / {{= .Date }}"},
+		"css":       []interface{}{"{{= .Stem }}.css", "plain.css"},
+		"toc":       true,
+	}
+	got := expandTemplates(meta, tc)
+	if got["output"] != "my-report-latex.pdf" {
+		t.Errorf("unexpected output %v", got["output"])
+	}
+	if footer := got["variables"].(map[string]interface{})["footer"]; footer != "acme / 2026-03-01" {
+		t.Errorf("unexpected footer %v", footer)
+	}
+	if css := got["css"].([]interface{}); css[0] != "report.css" || css[1] != "plain.css" || got["toc"] != true {
+		t.Errorf("unexpected options %v", got)
+	}
+	if meta["output"] != "{{= .Title | slug }}-{{= .Format }}.pdf" {
+		t.Error("expected the config to be left alone")
+	}
+
+	// Braces without the marker, and expressions that cannot be evaluated, are kept as written
+	literal := map[string]interface{}{
+		"header-includes": `\newcommand{\x}[1]{{\bf #1}}`,
+		"variables":       map[string]interface{}{"x": "{{x}}", "y": "{{= .Nope }}", "z": "{{= nope }}"},
+	}
+	if got := expandTemplates(literal, tc); !reflect.DeepEqual(got, literal) {
+		t.Errorf("expected the values to be passed on as written, got %#v", got)
+	}
+}
+
+func TestProcess_ConfigTemplates(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte(`---
+title: Field Notes
+subtitle: "{{= .Target | upper }} edition"
+header-includes: '\newcommand{\x}[1]{{\bf #1}}'
+output:
+  html:
+    output: "{{= .Title | slug }}-{{= .Target }}.{{= .Ext }}"
+---
+# Notes
+`), 0600)
+
+	rec := &envRecorder{}
+	opts := options.Options{NoCache: true, Quiet: true}
+	if _, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	args := rec.args[0]
+	if _, err := os.Stat(filepath.Join(dir, "field-notes-html.html")); err != nil {
+		t.Errorf("expected the output name evaluated: %v", err)
+	}
+	if !slices.Contains(args, "subtitle=HTML edition") {
+		t.Errorf("expected the computed metadata, got %v", args)
+	}
+	if strings.Contains(strings.Join(args, " "), "header-includes") {
+		t.Errorf("LaTeX braces must be left in the header, got %v", args)
+	}
+}
@@ -0,0 +1,75 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+// What to do when a target's output already exists (--on-conflict).
+const (
+	conflictPrompt    = "prompt"
+	conflictSkip      = "skip"
+	conflictOverwrite = "overwrite"
+	conflictRename    = "rename"
+	conflictTrash     = "trash"
+)
+
+// ConflictPolicies lists the accepted --on-conflict values.
+var ConflictPolicies = []string{conflictPrompt, conflictSkip, conflictOverwrite, conflictRename, conflictTrash}
+
+// conflictPolicy returns what to do with an existing output: --on-conflict, else
+// overwrite for --force and watch mode, else the target's or document's `on-conflict`
+// key, else overwrite if `overwrite: true`, else prompt.
+//
+// Parameters:
+//   - `opts`: runtime options
+//   - `cfg`: the global config
+//   - `metaOut`: the format-specific config
+//
+// Returns:
+//   - string: one of ConflictPolicies
+//   - error: if the policy is unknown
+func conflictPolicy(opts options.Options, cfg *config.Config, metaOut map[string]interface{}) (string, error) {
+	policy := opts.OnConflict
+	if policy == "" && (opts.Force || opts.Watch) {
+		// Watch mode would otherwise block on a prompt after every save
+		policy = conflictOverwrite
+	}
+	if policy == "" {
+		policy = stringSetting(cfg, metaOut, "on-conflict")
+	}
+	if policy == "" {
+		if isOverwriteAllowed(cfg, metaOut) {
+			return conflictOverwrite, nil
+		}
+		return conflictPrompt, nil
+	}
+	for _, p := range ConflictPolicies {
+		if policy == p {
+			return policy, nil
+		}
+	}
+	return "", fmt.Errorf("on-conflict: unknown policy %q (use %s)", policy, strings.Join(ConflictPolicies, ", "))
+}
+
+// renamedOutput returns the first name that does not exist yet by appending an
+// incrementing suffix before the extension: doc.pdf becomes doc-1.pdf, doc-2.pdf, ...
+//
+// Parameters:
+//   - `outputFile`: the output that already exists
+func renamedOutput(outputFile string) string {
+	ext := filepath.Ext(outputFile)
+	base := strings.TrimSuffix(outputFile, ext)
+	for i := 1; ; i++ {
+		candidate := base + "-" + strconv.Itoa(i) + ext
+		if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+func TestConflictPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    options.Options
+		generic map[string]interface{}
+		want    string
+		wantErr bool
+	}{
+		{"default", options.Options{}, nil, conflictPrompt, false},
+		{"force", options.Options{Force: true}, nil, conflictOverwrite, false},
+		{"watch", options.Options{Watch: true}, nil, conflictOverwrite, false},
+		{"flag wins over force", options.Options{Force: true, OnConflict: "rename"}, nil, conflictRename, false},
+		{"config", options.Options{}, map[string]interface{}{"on-conflict": "skip"}, conflictSkip, false},
+		{"overwrite key", options.Options{}, map[string]interface{}{"overwrite": true}, conflictOverwrite, false},
+		{"unknown", options.Options{OnConflict: "merge"}, nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Generic: tt.generic}
+			got, err := conflictPolicy(tt.opts, cfg, nil)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("conflictPolicy = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcess_OnConflict(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	input := filepath.Join(dir, "doc.md")
+	output := filepath.Join(dir, "doc.html")
+	_ = os.WriteFile(input, []byte("---\noutput:\n  html:\n    output: doc.html\n---\n# Doc\n"), 0600)
+	_ = os.WriteFile(output, []byte("previous"), 0600)
+	_ = os.WriteFile(filepath.Join(dir, "doc-1.html"), []byte("taken"), 0600)
+
+	rec := &argsRecorder{inputs: map[string]string{}, args: map[string][]string{}}
+	opts := options.Options{Targets: []string{"html"}, NoCache: true, Quiet: true, OnConflict: conflictSkip}
+	results, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir})
+	if err != nil {
+		t.Fatalf("process failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Status != StatusSkipped || len(rec.args) != 0 {
+		t.Errorf("expected the target to be skipped, got %+v", results)
+	}
+
+	opts.OnConflict = conflictRename
+	results, err = process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir})
+	if err != nil {
+		t.Fatalf("process failed: %v", err)
+	}
+	renamed := filepath.Join(dir, "doc-2.html")
+	if data, _ := os.ReadFile(renamed); string(data) != "converted" || results[0].Output != renamed {
+		t.Errorf("expected a new doc-2.html, got %+v", results)
+	}
+	if data, _ := os.ReadFile(output); string(data) != "previous" {
+		t.Errorf("the existing output must be kept, got %q", data)
+	}
+}
+
+func TestProcess_NoInput(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte("---\noutput:\n  html:\n    output: doc.html\n---\n# Doc\n"), 0600)
+	_ = os.WriteFile(filepath.Join(dir, "doc.html"), []byte("previous"), 0600)
+
+	rec := &argsRecorder{inputs: map[string]string{}, args: map[string][]string{}}
+	opts := options.Options{Targets: []string{"html"}, NoCache: true, Quiet: true, NoInput: noInputSkip}
+	results, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir})
+	if err != nil || len(results) != 1 || results[0].Status != StatusSkipped {
+		t.Fatalf("expected the prompt to skip the target, got %+v, %v", results, err)
+	}
+
+	opts.NoInput = noInputFail
+	results, err = process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir})
+	if err == nil || len(results) != 1 || results[0].Status != StatusFailed {
+		t.Errorf("expected the prompt to fail the target, got %+v, %v", results, err)
+	}
+	if len(rec.args) != 0 {
+		t.Error("pandoc must not run when the prompt is replaced")
+	}
+}
@@ -0,0 +1,63 @@
+package app
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// countersName is the file in the data directory that holds the {counter} values.
+const countersName = "counters.json"
+
+// countersMu serializes the targets of a run that read and advance counters.
+var countersMu sync.Mutex
+
+// nextCounter returns the next {counter} value of an input and format: 1 for its first
+// output, then one more than the last. The values persist in the data directory, so
+// repeated exports get report_v1.pdf, report_v2.pdf, and so on.
+//
+// Parameters:
+//   - `dataDir`: the panforge data directory
+//   - `input`: the converted document
+//   - `format`: the pandoc output format
+//   - `reserve`: whether to record the value; otherwise it is only looked up, e.g. in
+//     dry-run mode
+//
+// Returns:
+//   - int: the counter value
+//   - error: if the counters cannot be read or saved
+func nextCounter(dataDir, input, format string, reserve bool) (int, error) {
+	if abs, err := filepath.Abs(input); err == nil {
+		input = abs
+	}
+	key := input + "#" + format
+	path := filepath.Join(dataDir, countersName)
+
+	countersMu.Lock()
+	defer countersMu.Unlock()
+	counters := map[string]int{}
+	//nolint:gosec // G304: the counters file lives in panforge's own data directory
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &counters); err != nil {
+			return 0, err
+		}
+	} else if !os.IsNotExist(err) {
+		return 0, err
+	}
+	counters[key]++
+	if !reserve {
+		return counters[key], nil
+	}
+	data, err := json.MarshalIndent(counters, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(dataDir, 0750); err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return 0, err
+	}
+	return counters[key], nil
+}
@@ -0,0 +1,24 @@
+package app
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestNextCounter(t *testing.T) {
+	dataDir := filepath.Join(t.TempDir(), "data")
+	for want := 1; want <= 2; want++ {
+		if n, err := nextCounter(dataDir, "report.md", "pdf", true); err != nil || n != want {
+			t.Fatalf("nextCounter() = %d, %v, want %d", n, err, want)
+		}
+	}
+	if n, _ := nextCounter(dataDir, "report.md", "pdf", false); n != 3 {
+		t.Errorf("expected the next value to be looked up, got %d", n)
+	}
+	if n, _ := nextCounter(dataDir, "report.md", "pdf", true); n != 3 {
+		t.Errorf("expected a lookup not to advance the counter, got %d", n)
+	}
+	if n, _ := nextCounter(dataDir, "report.md", "html", true); n != 1 {
+		t.Errorf("expected each format to count on its own, got %d", n)
+	}
+}
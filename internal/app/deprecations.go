@@ -0,0 +1,131 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+	"github.com/spf13/cobra"
+)
+
+// deprecation is a config key or flag that still works but is on its way out.
+type deprecation struct {
+	// name is what the user wrote, e.g. "pandoc_args" or "init --config".
+	name string
+	// hint says what to use instead.
+	hint string
+}
+
+// String formats the deprecation for warnings and errors.
+func (d deprecation) String() string {
+	return fmt.Sprintf("%s is deprecated; %s", d.name, d.hint)
+}
+
+// deprecatedKeys are config keys with a replacement, at the top level or in a target.
+var deprecatedKeys = map[string]string{
+	"pandoc_args": "use pandoc-args instead",
+}
+
+// deprecatedFlag is a flag of one command with a replacement.
+type deprecatedFlag struct {
+	command, flag, hint string
+}
+
+// deprecatedFlags are the flags with a replacement.
+var deprecatedFlags = []deprecatedFlag{
+	{command: "init", flag: "config", hint: "leave it out: init writes a config file unless --markdown is given"},
+}
+
+// warnedDeprecations remembers what was reported, so each deprecation is printed once
+// per run however many documents or targets use it.
+var warnedDeprecations sync.Map
+
+// reportDeprecation prints a deprecation once per run, or with --strict returns it as a
+// configuration error.
+//
+// Parameters:
+//   - `d`: the deprecation
+//   - `opts`: runtime options (Strict)
+//   - `w`: where the warning is written
+//
+// Returns:
+//   - error: with --strict, the deprecation
+func reportDeprecation(d deprecation, opts options.Options, w io.Writer) error {
+	if opts.Strict {
+		return configError(fmt.Errorf("%s", d))
+	}
+	if _, warned := warnedDeprecations.LoadOrStore(d.name, true); !warned {
+		_, _ = fmt.Fprintf(w, "Warning: %s\n", d)
+	}
+	return nil
+}
+
+// checkDeprecatedConfig reports deprecated keys of a document's merged config and of its
+// targets, and targets configured at the top level instead of under `output`.
+//
+// Parameters:
+//   - `cfg`: the merged configuration
+//   - `targets`: the targets of the run
+//   - `opts`: runtime options (Strict)
+//   - `w`: where warnings are written
+//
+// Returns:
+//   - error: with --strict, the first deprecation
+func checkDeprecatedConfig(cfg *config.Config, targets []string, opts options.Options, w io.Writer) error {
+	var found []deprecation
+	for key := range cfg.Generic {
+		if hint, ok := deprecatedKeys[key]; ok {
+			found = append(found, deprecation{name: key, hint: hint})
+		}
+	}
+	for _, t := range targets {
+		if _, ok := cfg.OutputMap[t]; !ok {
+			if _, ok := cfg.Generic[t].(map[string]interface{}); ok {
+				found = append(found, deprecation{
+					name: fmt.Sprintf("configuring target %s at the top level", t),
+					hint: fmt.Sprintf("move it under output.%s", t),
+				})
+			}
+		}
+		_, metaOut := resolveTarget(cfg, t)
+		for key := range metaOut {
+			if hint, ok := deprecatedKeys[key]; ok {
+				found = append(found, deprecation{name: key, hint: hint})
+			}
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].name < found[j].name })
+	for _, d := range found {
+		if err := reportDeprecation(d, opts, w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckDeprecatedFlags reports the deprecated flags given to a command.
+//
+// Parameters:
+//   - `cmd`: the command being run
+//   - `opts`: runtime options (Strict)
+//   - `w`: where warnings are written
+//
+// Returns:
+//   - error: with --strict, the first deprecated flag
+func CheckDeprecatedFlags(cmd *cobra.Command, opts options.Options, w io.Writer) error {
+	for _, f := range deprecatedFlags {
+		if cmd.Name() != f.command {
+			continue
+		}
+		if flag := cmd.Flags().Lookup(f.flag); flag != nil && flag.Changed {
+			d := deprecation{name: fmt.Sprintf("%s --%s", f.command, f.flag), hint: f.hint}
+			if err := reportDeprecation(d, opts, w); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package app
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+	"github.com/spf13/cobra"
+)
+
+func TestCheckDeprecatedConfig(t *testing.T) {
+	warnedDeprecations.Clear()
+	t.Cleanup(warnedDeprecations.Clear)
+	cfg := &config.Config{
+		OutputMap: map[string]interface{}{
+			"html": map[string]interface{}{"pandoc_args": []interface{}{"--toc"}},
+		},
+		Generic: map[string]interface{}{
+			"pdf": map[string]interface{}{"toc": true},
+		},
+	}
+	var out bytes.Buffer
+	for i := 0; i < 2; i++ {
+		if err := checkDeprecatedConfig(cfg, []string{"html", "pdf"}, options.Options{}, &out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "Warning: configuring target pdf at the top level is deprecated; move it under output.pdf\n" +
+		"Warning: pandoc_args is deprecated; use pandoc-args instead\n"
+	if out.String() != want {
+		t.Errorf("expected each warning once, got:\n%s", out.String())
+	}
+
+	err := checkDeprecatedConfig(cfg, []string{"html"}, options.Options{Strict: true}, &out)
+	if ExitCode(err) != ExitConfig {
+		t.Errorf("expected a configuration error with --strict, got %v", err)
+	}
+}
+
+func TestCheckDeprecatedFlags(t *testing.T) {
+	warnedDeprecations.Clear()
+	t.Cleanup(warnedDeprecations.Clear)
+	cmd := &cobra.Command{Use: "init"}
+	cmd.Flags().Bool("config", false, "")
+	var out bytes.Buffer
+	if err := CheckDeprecatedFlags(cmd, options.Options{}, &out); err != nil || out.Len() != 0 {
+		t.Fatalf("expected nothing without the flag, got %v, %q", err, out.String())
+	}
+	_ = cmd.Flags().Set("config", "true")
+	if err := CheckDeprecatedFlags(cmd, options.Options{}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "Warning: init --config is deprecated; leave it out: init writes a config file unless --markdown is given\n" {
+		t.Errorf("unexpected warning %q", out.String())
+	}
+}
@@ -0,0 +1,152 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rapjul/panforge/internal/options"
+	"github.com/rapjul/panforge/internal/pandoc"
+)
+
+// attributeDiagnostics points diagnostics without a source file, or that name one of
+// panforge's temporary copies, at the input document. LaTeX messages keep no file
+// because their line numbers refer to the generated .tex file.
+//
+// Parameters:
+//   - `diags`: the parsed diagnostics
+//   - `inputFile`: the document being converted
+func attributeDiagnostics(diags []pandoc.Diagnostic, inputFile string) []pandoc.Diagnostic {
+	for i := range diags {
+		d := &diags[i]
+		if d.Kind == pandoc.KindLaTeX {
+			continue
+		}
+		if d.File == "" || strings.HasPrefix(filepath.Base(d.File), ".panforge-") {
+			d.File = inputFile
+		} else if !filepath.IsAbs(d.File) {
+			d.File = filepath.Join(filepath.Dir(inputFile), d.File)
+		}
+	}
+	return diags
+}
+
+// countDiagnostics returns the number of diagnostics across results.
+func countDiagnostics(results []TargetResult) int {
+	n := 0
+	for _, r := range results {
+		n += len(r.Diagnostics)
+	}
+	return n
+}
+
+// writeDiagnostics prints every target's diagnostics as `file:line:col: severity: message`,
+// one per line, followed by the target in parentheses. Paths are shown relative to root.
+//
+// Parameters:
+//   - `w`: where the diagnostics are written
+//   - `root`: the directory paths are shortened against
+//   - `results`: the target results
+func writeDiagnostics(w io.Writer, root string, results []TargetResult) {
+	n := countDiagnostics(results)
+	if n == 0 {
+		return
+	}
+	_, _ = fmt.Fprintf(w, "\n%d pandoc diagnostic(s):\n", n)
+	for _, r := range sortedResults(results) {
+		for _, d := range r.Diagnostics {
+			if d.File != "" {
+				d.File = relTo(root, d.File)
+			}
+			_, _ = fmt.Fprintf(w, "  %s (%s)\n", d, r.Target)
+		}
+	}
+}
+
+// workingDir returns the working directory, or "" if it cannot be determined.
+func workingDir() string {
+	wd, _ := os.Getwd()
+	return wd
+}
+
+// annotationsGitHub prints diagnostics as GitHub Actions workflow commands.
+const annotationsGitHub = "github"
+
+// AnnotationFormats lists the formats accepted by --annotations.
+var AnnotationFormats = []string{annotationsGitHub}
+
+// annotationsFormat validates --annotations.
+//
+// Returns:
+//   - string: the annotation format ("" if annotations are off)
+//   - error: if the format is not supported
+func annotationsFormat(opts options.Options) (string, error) {
+	format := strings.ToLower(opts.Annotations)
+	if format != "" && format != annotationsGitHub {
+		return "", fmt.Errorf("invalid --annotations %q (want %s)", opts.Annotations, strings.Join(AnnotationFormats, ", "))
+	}
+	return format, nil
+}
+
+// annotationsWriter returns where annotations are printed: stdout, where GitHub Actions
+// reads workflow commands, unless a --report takes it.
+func annotationsWriter(opts options.Options) io.Writer {
+	if ReportToStdout(opts) {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// writeAnnotations prints every target's diagnostics and failure as GitHub Actions
+// workflow commands (`::warning file=...::message`), so they show up inline on pull
+// requests. Paths are made relative to GITHUB_WORKSPACE, or else the working directory.
+//
+// Parameters:
+//   - `w`: where the workflow commands are written
+//   - `results`: the target results
+func writeAnnotations(w io.Writer, results []TargetResult) {
+	root := os.Getenv("GITHUB_WORKSPACE")
+	if root == "" {
+		root = workingDir()
+	}
+	for _, r := range sortedResults(results) {
+		for _, d := range r.Diagnostics {
+			props := []string{}
+			if d.File != "" {
+				props = append(props, "file="+escapeProperty(filepath.ToSlash(relTo(root, d.File))))
+				if d.Line > 0 {
+					props = append(props, fmt.Sprintf("line=%d", d.Line))
+					if d.Column > 0 {
+						props = append(props, fmt.Sprintf("col=%d", d.Column))
+					}
+				}
+			}
+			title := "pandoc (" + r.Target + ")"
+			if d.Kind != pandoc.KindOther {
+				title = "pandoc " + d.Kind + " (" + r.Target + ")"
+			}
+			props = append(props, "title="+escapeProperty(title))
+			_, _ = fmt.Fprintf(w, "::%s %s::%s\n", d.Severity, strings.Join(props, ","), escapeData(d.Message))
+		}
+		if r.Status == StatusFailed && r.Error != "" {
+			props := []string{}
+			if r.Input != "" {
+				props = append(props, "file="+escapeProperty(filepath.ToSlash(relTo(root, r.Input))))
+			}
+			props = append(props, "title="+escapeProperty("panforge target "+r.Target+" failed"))
+			_, _ = fmt.Fprintf(w, "::error %s::%s\n", strings.Join(props, ","), escapeData(r.Error))
+		}
+	}
+}
+
+// escapeData escapes the message of a workflow command.
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty escapes a property value of a workflow command.
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
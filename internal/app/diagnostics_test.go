@@ -0,0 +1,102 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/options"
+	"github.com/rapjul/panforge/internal/pandoc"
+)
+
+func TestAttributeDiagnostics(t *testing.T) {
+	input := filepath.Join("/work", "docs", "doc.md")
+	diags := attributeDiagnostics([]pandoc.Diagnostic{
+		{Kind: pandoc.KindDuplicateNote},
+		{Kind: pandoc.KindUndefinedReference, File: "/work/docs/.panforge-source-123.md"},
+		{Kind: pandoc.KindDuplicateIdentifier, File: "chapter.md"},
+		{Kind: pandoc.KindLaTeX, Line: 40},
+	}, input)
+
+	want := []string{input, input, filepath.Join("/work", "docs", "chapter.md"), ""}
+	for i, w := range want {
+		if diags[i].File != w {
+			t.Errorf("diagnostic %d file = %q, want %q", i, diags[i].File, w)
+		}
+	}
+}
+
+func TestWriteDiagnostics(t *testing.T) {
+	var buf bytes.Buffer
+	writeDiagnostics(&buf, "/work", []TargetResult{{Target: "html"}})
+	if buf.Len() != 0 {
+		t.Errorf("expected no output without diagnostics, got %q", buf.String())
+	}
+
+	writeDiagnostics(&buf, "/work", []TargetResult{
+		{Target: "pdf", Diagnostics: []pandoc.Diagnostic{{Severity: pandoc.SeverityWarning, Kind: pandoc.KindMissingResource, Message: "Could not fetch resource a.png", File: "/work/doc.md"}}},
+		{Target: "html", Diagnostics: []pandoc.Diagnostic{{Severity: pandoc.SeverityWarning, Kind: pandoc.KindDuplicateNote, Message: "Duplicate note reference '1'", File: "/work/doc.md", Line: 3, Column: 1}}},
+	})
+	out := buf.String()
+	if !strings.Contains(out, "2 pandoc diagnostic(s)") {
+		t.Errorf("missing count:\n%s", out)
+	}
+	html := strings.Index(out, "doc.md:3:1: warning: Duplicate note reference '1' [duplicate-note] (html)")
+	pdf := strings.Index(out, "doc.md: warning: Could not fetch resource a.png [missing-resource] (pdf)")
+	if html < 0 || pdf < 0 || html > pdf {
+		t.Errorf("unexpected diagnostics output:\n%s", out)
+	}
+}
+
+func TestWriteAnnotations(t *testing.T) {
+	t.Setenv("GITHUB_WORKSPACE", "/work")
+	var buf bytes.Buffer
+	writeAnnotations(&buf, []TargetResult{
+		{Target: "pdf", Input: "/work/docs/doc.md", Status: StatusFailed, Error: "target pdf: exit status 43\nsee log", Diagnostics: []pandoc.Diagnostic{
+			{Severity: pandoc.SeverityError, Kind: pandoc.KindLaTeX, Message: "LaTeX Error: File `x.sty' not found."},
+		}},
+		{Target: "html", Status: StatusSuccess, Diagnostics: []pandoc.Diagnostic{
+			{Severity: pandoc.SeverityWarning, Kind: pandoc.KindDuplicateNote, Message: "Duplicate note reference '1'", File: "/work/docs/doc.md", Line: 3, Column: 1},
+		}},
+	})
+	want := []string{
+		"::warning file=docs/doc.md,line=3,col=1,title=pandoc duplicate-note (html)::Duplicate note reference '1'",
+		"::error title=pandoc latex (pdf)::LaTeX Error: File `x.sty' not found.",
+		"::error file=docs/doc.md,title=panforge target pdf failed::target pdf: exit status 43%0Asee log",
+	}
+	if got := strings.Split(strings.TrimSpace(buf.String()), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("writeAnnotations() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if _, err := annotationsFormat(options.Options{Annotations: "gitlab"}); err == nil {
+		t.Error("expected an error for an unsupported annotation format")
+	}
+}
+
+// latexFailer fails the PDF target with a LaTeX error on stderr.
+type latexFailer struct {
+	formatFailer
+}
+
+func (l *latexFailer) Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	_, _ = io.WriteString(stderr, "Error producing PDF.\n! LaTeX Error: File `fontawesome5.sty' not found.\n")
+	return l.formatFailer.Run(ctx, name, args, stdout, stderr)
+}
+
+func TestProcess_FailureHints(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte("---\noutput:\n  pdf:\n    output: doc.pdf\n---\n# Doc\n"), 0600)
+
+	exec := &latexFailer{formatFailer{argsRecorder: argsRecorder{inputs: map[string]string{}, args: map[string][]string{}}, format: "pdf"}}
+	opts := options.Options{Targets: []string{"pdf"}, NoCache: true, Quiet: true}
+	_, err := process(context.Background(), input, nil, opts, exec, processEnv{baseDir: dir})
+	if err == nil || !strings.Contains(err.Error(), "pandoc failed: exit status 43 (hint: the LaTeX package fontawesome5 is not installed") {
+		t.Errorf("expected a hint in the error, got %v", err)
+	}
+}
@@ -0,0 +1,422 @@
+package app
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rapjul/panforge/internal/cache"
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+	"github.com/rapjul/panforge/internal/preprocess"
+)
+
+// diagramsDir holds rendered diagrams, next to the input document.
+const diagramsDir = ".panforge-diagrams"
+
+// Diagram renderers for the `mermaid` key.
+const (
+	// rendererMmdc renders blocks with the mermaid CLI before conversion.
+	rendererMmdc = "mmdc"
+	// rendererFilter leaves blocks to the mermaid-filter pandoc filter.
+	rendererFilter = "filter"
+)
+
+// diagramServerTimeout bounds how long a diagram server request may take.
+const diagramServerTimeout = 30 * time.Second
+
+// diagramMu serializes diagram rendering so concurrent targets never write the same file twice.
+var diagramMu sync.Mutex
+
+// diagramRenderer renders one diagram block to the given image path.
+type diagramRenderer func(ctx context.Context, b preprocess.DiagramBlock, image string) error
+
+// diagramKind is an enabled diagram language and how to render it for a target.
+type diagramKind struct {
+	// Lang is the code block language (e.g. "mermaid").
+	Lang string
+	// Ext is the image format.
+	Ext string
+	// Settings distinguish images of the same source rendered with different options.
+	Settings []string
+	// Render produces the image.
+	Render diagramRenderer
+}
+
+// mermaidConfig is the resolved `mermaid` setting for a target.
+type mermaidConfig struct {
+	// Enabled is false when the target or document sets `mermaid: false`.
+	Enabled bool
+	// Renderer is rendererMmdc or rendererFilter.
+	Renderer string
+	// Format is the image format (svg, png, pdf); "" picks one for the output format.
+	Format string
+	// Theme, Background, and Width are passed to mmdc when set.
+	Theme      string
+	Background string
+	Width      int
+}
+
+// plantumlConfig is the resolved `plantuml` setting for a target.
+type plantumlConfig struct {
+	// Enabled is true once the document or target sets `plantuml`; rendering is opt-in.
+	Enabled bool
+	// Jar runs `java -jar <Jar>` instead of the plantuml command.
+	Jar string
+	// Server renders through a PlantUML server (e.g. https://www.plantuml.com/plantuml).
+	Server string
+	// Format is the image format (svg, png); "" picks one for the output format.
+	Format string
+}
+
+// resolveMermaid reads the `mermaid` key, with the target's value taking precedence.
+// The key may be a bool or a map with renderer, format, theme, background, and width.
+//
+// Parameters:
+//   - `cfg`: the global config
+//   - `metaOut`: the format-specific config
+func resolveMermaid(cfg *config.Config, metaOut map[string]interface{}) mermaidConfig {
+	mc := mermaidConfig{Enabled: true, Renderer: rendererMmdc}
+	for _, raw := range []interface{}{cfg.Generic["mermaid"], metaOut["mermaid"]} {
+		switch v := raw.(type) {
+		case bool:
+			mc.Enabled = v
+		case map[string]interface{}:
+			mc.Enabled = true
+			if s, ok := v["renderer"].(string); ok && s != "" {
+				mc.Renderer = s
+			}
+			if s, ok := v["format"].(string); ok && s != "" {
+				mc.Format = s
+			}
+			if s, ok := v["theme"].(string); ok {
+				mc.Theme = s
+			}
+			if s, ok := v["background"].(string); ok {
+				mc.Background = s
+			}
+			if n, ok := v["width"].(int); ok {
+				mc.Width = n
+			}
+		}
+	}
+	return mc
+}
+
+// resolvePlantUML reads the `plantuml` key, with the target's value taking precedence.
+// The key may be a bool or a map with jar, server, and format.
+//
+// Parameters:
+//   - `cfg`: the global config
+//   - `metaOut`: the format-specific config
+func resolvePlantUML(cfg *config.Config, metaOut map[string]interface{}) plantumlConfig {
+	var pc plantumlConfig
+	for _, raw := range []interface{}{cfg.Generic["plantuml"], metaOut["plantuml"]} {
+		switch v := raw.(type) {
+		case bool:
+			pc.Enabled = v
+		case map[string]interface{}:
+			pc.Enabled = true
+			if s, ok := v["jar"].(string); ok && s != "" {
+				pc.Jar = s
+			}
+			if s, ok := v["server"].(string); ok && s != "" {
+				pc.Server = strings.TrimRight(s, "/")
+			}
+			if s, ok := v["format"].(string); ok && s != "" {
+				pc.Format = s
+			}
+		}
+	}
+	return pc
+}
+
+// diagramFormatFor picks the image format that embeds best in an output format:
+// SVG for web formats, PDF for LaTeX, and PNG elsewhere (e.g. DOCX).
+//
+// Parameters:
+//   - `fmtStr`: the pandoc output format
+func diagramFormatFor(fmtStr string) string {
+	switch fmtStr {
+	case "html", "html4", "html5", "epub", "epub2", "epub3", "revealjs", "slidy", "s5", "dzslides":
+		return "svg"
+	case "latex", "pdf", "beamer":
+		return "pdf"
+	default:
+		return "png"
+	}
+}
+
+// diagramKinds returns the diagram languages rendered before conversion for a target.
+//
+// Parameters:
+//   - `cfg`: the global config
+//   - `metaOut`: the format-specific config
+//   - `fmtStr`: the target pandoc format
+//   - `opts`: runtime options
+//   - `executor`: used to run the renderers
+func diagramKinds(cfg *config.Config, metaOut map[string]interface{}, fmtStr string, opts options.Options, executor CommandExecutor) []diagramKind {
+	var kinds []diagramKind
+	if mc := resolveMermaid(cfg, metaOut); mc.Enabled && mc.Renderer == rendererMmdc {
+		kinds = append(kinds, mermaidKind(mc, fmtStr, opts, executor))
+	}
+	if pc := resolvePlantUML(cfg, metaOut); pc.Enabled {
+		kinds = append(kinds, plantumlKind(pc, fmtStr, opts, executor))
+	}
+	return kinds
+}
+
+// mermaidKind renders ```mermaid blocks with mmdc.
+func mermaidKind(mc mermaidConfig, fmtStr string, opts options.Options, executor CommandExecutor) diagramKind {
+	ext := mc.Format
+	if ext == "" {
+		ext = diagramFormatFor(fmtStr)
+	}
+	return diagramKind{
+		Lang:     "mermaid",
+		Ext:      ext,
+		Settings: []string{mc.Theme, mc.Background, strconv.Itoa(mc.Width)},
+		Render: func(ctx context.Context, b preprocess.DiagramBlock, image string) error {
+			src := strings.TrimSuffix(image, filepath.Ext(image)) + ".mmd"
+			args := []string{"-i", src, "-o", image}
+			if mc.Theme != "" {
+				args = append(args, "-t", mc.Theme)
+			}
+			if mc.Background != "" {
+				args = append(args, "-b", mc.Background)
+			}
+			if mc.Width > 0 {
+				args = append(args, "-w", strconv.Itoa(mc.Width))
+			}
+			return runDiagramTool(ctx, "mmdc", args, b.Code, src, opts, executor)
+		},
+	}
+}
+
+// plantumlKind renders ```plantuml blocks with the plantuml command, a jar, or a server.
+// PlantUML's PDF output needs extra libraries, so LaTeX targets get PNG by default.
+func plantumlKind(pc plantumlConfig, fmtStr string, opts options.Options, executor CommandExecutor) diagramKind {
+	ext := pc.Format
+	if ext == "" {
+		ext = diagramFormatFor(fmtStr)
+		if ext == "pdf" {
+			ext = "png"
+		}
+	}
+	return diagramKind{
+		Lang: "plantuml",
+		Ext:  ext,
+		Render: func(ctx context.Context, b preprocess.DiagramBlock, image string) error {
+			code := b.Code
+			if !strings.Contains(code, "@start") {
+				code = "@startuml\n" + code + "@enduml\n"
+			}
+			if pc.Server != "" {
+				return fetchDiagram(ctx, pc.Server+"/"+ext+"/~h"+hex.EncodeToString([]byte(code)), image, opts)
+			}
+			// PlantUML writes <name>.<ext> next to the source file
+			src := strings.TrimSuffix(image, filepath.Ext(image)) + ".puml"
+			tool, args := "plantuml", []string{"-t" + ext, src}
+			if pc.Jar != "" {
+				tool, args = "java", append([]string{"-jar", pc.Jar}, args...)
+			}
+			return runDiagramTool(ctx, tool, args, code, src, opts, executor)
+		},
+	}
+}
+
+// renderDiagrams replaces the diagram blocks of every enabled kind with rendered images
+// and writes the result to a per-target copy next to the input. Images are named by a
+// hash of their source and settings, so unchanged diagrams are not rendered again.
+//
+// Parameters:
+//   - `ctx`: context for cancellation
+//   - `input`: the document pandoc will read
+//   - `kinds`: the enabled diagram kinds
+//
+// Returns:
+//   - string: the path of the copy, or "" if the input has no diagram blocks
+//   - error: any error rendering a diagram or writing the copy
+func renderDiagrams(ctx context.Context, input string, kinds []diagramKind) (string, error) {
+	//nolint:gosec // G304: reading the input file is intended
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return "", fmt.Errorf("failed to read input for diagrams: %w", err)
+	}
+	content := string(data)
+	dir := filepath.Join(filepath.Dir(input), diagramsDir)
+
+	changed := false
+	for _, kind := range kinds {
+		if !preprocess.HasDiagramBlocks(content, kind.Lang) {
+			continue
+		}
+		changed = true
+		content, err = preprocess.ReplaceDiagramBlocks(content, kind.Lang, func(b preprocess.DiagramBlock) (string, error) {
+			parts := append([]string{kind.Lang, b.Code, kind.Ext}, kind.Settings...)
+			image := filepath.Join(dir, cache.ComputeKey(parts...)[:16]+"."+kind.Ext)
+			diagramMu.Lock()
+			defer diagramMu.Unlock()
+			if _, err := os.Stat(image); err != nil {
+				if err := os.MkdirAll(dir, 0750); err != nil {
+					return "", fmt.Errorf("failed to create diagrams directory: %w", err)
+				}
+				if err := kind.Render(ctx, b, image); err != nil {
+					return "", err
+				}
+			}
+			return diagramImage(b, image), nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	if !changed {
+		return "", nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(input), ".panforge-diagrams-*"+filepath.Ext(input))
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	if _, err := tmp.WriteString(content); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to close temp file: %w", err)
+	}
+	return tmp.Name(), nil
+}
+
+// runDiagramTool writes a diagram's source and runs the tool that renders it.
+//
+// Parameters:
+//   - `ctx`: context for cancellation
+//   - `tool`: the renderer command
+//   - `args`: the renderer arguments
+//   - `code`: the diagram source
+//   - `srcFile`: where the source is written (removed afterwards)
+//   - `opts`: runtime options
+//   - `executor`: used to run the tool
+func runDiagramTool(ctx context.Context, tool string, args []string, code, srcFile string, opts options.Options, executor CommandExecutor) error {
+	cmdStr := formatCommand(tool, args)
+	echoCommand(opts, cmdStr)
+	if opts.DryRun {
+		return nil
+	}
+
+	//nolint:gosec // G306: diagram sources are not sensitive
+	if err := os.WriteFile(srcFile, []byte(code), 0644); err != nil {
+		return fmt.Errorf("failed to write diagram source: %w", err)
+	}
+	defer func() { _ = os.Remove(srcFile) }()
+	if err := executor.Run(ctx, tool, args, os.Stdout, os.Stderr); err != nil {
+		return fmt.Errorf("%s failed: %w", tool, err)
+	}
+	return nil
+}
+
+// fetchDiagram downloads a rendered diagram from a diagram server.
+//
+// Parameters:
+//   - `ctx`: context for cancellation
+//   - `url`: the image URL
+//   - `image`: where the image is written
+//   - `opts`: runtime options
+func fetchDiagram(ctx context.Context, url, image string, opts options.Options) error {
+	if opts.Logger != nil {
+		opts.Logger.Info("fetching diagram", "url", url)
+	}
+	if opts.DryRun {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, diagramServerTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create diagram request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch diagram: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("diagram server returned %s", resp.Status)
+	}
+
+	tmp := image + ".tmp"
+	//nolint:gosec // G304: the image path is derived from the diagram hash
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create diagram: %w", err)
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write diagram: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write diagram: %w", err)
+	}
+	return os.Rename(tmp, image)
+}
+
+// diagramTools returns the external tools needed to render the diagrams in a document.
+//
+// Parameters:
+//   - `cfg`: the global config
+//   - `content`: the document content
+func diagramTools(cfg *config.Config, content string) []string {
+	tools := make(map[string]bool)
+	if preprocess.HasDiagramBlocks(content, "mermaid") {
+		if mc := resolveMermaid(cfg, nil); mc.Enabled && mc.Renderer == rendererFilter {
+			tools["mermaid-filter"] = true
+		} else if mc.Enabled {
+			tools["mmdc"] = true
+		}
+	}
+	if preprocess.HasDiagramBlocks(content, "plantuml") {
+		if pc := resolvePlantUML(cfg, nil); pc.Enabled && pc.Server == "" {
+			if pc.Jar != "" {
+				tools["java"] = true
+			} else {
+				tools["plantuml"] = true
+			}
+		}
+	}
+	var list []string
+	for t := range tools {
+		list = append(list, t)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// diagramImage returns the Markdown image that replaces a diagram block. The path is
+// relative to the working directory, which is where pandoc resolves images.
+//
+// Parameters:
+//   - `b`: the diagram block
+//   - `image`: the absolute path of the rendered image
+func diagramImage(b preprocess.DiagramBlock, image string) string {
+	md := fmt.Sprintf("![%s](%s)", b.Caption, filepath.ToSlash(relToWorkingDir(image)))
+	if b.Attrs != "" {
+		md += "{" + b.Attrs + "}"
+	}
+	return md + "\n"
+}
@@ -0,0 +1,191 @@
+package app
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+// diagramExecutor writes an empty image where mmdc or plantuml would and counts the runs.
+type diagramExecutor struct {
+	runs  int
+	tools []string
+}
+
+func (e *diagramExecutor) Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	e.runs++
+	e.tools = append(e.tools, name)
+	for i, a := range args {
+		if a == "-o" && i+1 < len(args) {
+			return os.WriteFile(args[i+1], nil, 0644)
+		}
+		if strings.HasPrefix(a, "-t") && i+1 < len(args) {
+			src := args[len(args)-1]
+			return os.WriteFile(strings.TrimSuffix(src, ".puml")+"."+strings.TrimPrefix(a, "-t"), nil, 0644)
+		}
+	}
+	return nil
+}
+
+func TestResolveMermaid(t *testing.T) {
+	cfg := &config.Config{Generic: map[string]interface{}{
+		"mermaid": map[string]interface{}{"theme": "dark", "width": 800},
+	}}
+	mc := resolveMermaid(cfg, map[string]interface{}{"mermaid": map[string]interface{}{"renderer": "filter"}})
+	if !mc.Enabled || mc.Renderer != rendererFilter || mc.Theme != "dark" || mc.Width != 800 {
+		t.Errorf("resolveMermaid = %+v", mc)
+	}
+	if mc := resolveMermaid(cfg, map[string]interface{}{"mermaid": false}); mc.Enabled {
+		t.Error("mermaid: false should disable rendering")
+	}
+}
+
+func TestDiagramFormatFor(t *testing.T) {
+	for fmtStr, want := range map[string]string{"html": "svg", "pdf": "pdf", "beamer": "pdf", "docx": "png"} {
+		if got := diagramFormatFor(fmtStr); got != want {
+			t.Errorf("diagramFormatFor(%q) = %q, want %q", fmtStr, got, want)
+		}
+	}
+}
+
+func TestRenderDiagrams_Mermaid(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	input := filepath.Join(dir, "doc.md")
+	src := "# Doc\n\n```{.mermaid caption=\"Flow\" #fig-flow}\ngraph TD; A-->B\n```\n"
+	if err := os.WriteFile(input, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	exec := &diagramExecutor{}
+	opts := options.Options{Quiet: true}
+	kinds := []diagramKind{mermaidKind(mermaidConfig{Enabled: true, Renderer: rendererMmdc}, "html", opts, exec)}
+	out, err := renderDiagrams(context.Background(), input, kinds)
+	if err != nil {
+		t.Fatalf("renderMermaid failed: %v", err)
+	}
+	defer func() { _ = os.Remove(out) }()
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	if !strings.Contains(got, "![Flow](.panforge-diagrams/") || !strings.Contains(got, ".svg){#fig-flow}") {
+		t.Errorf("unexpected output:\n%s", got)
+	}
+	if strings.Contains(got, "graph TD") {
+		t.Error("diagram source should have been replaced")
+	}
+
+	// Unchanged diagrams are not rendered again.
+	out2, err := renderDiagrams(context.Background(), input, kinds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = os.Remove(out2)
+	if exec.runs != 1 {
+		t.Errorf("mmdc ran %d times, want 1", exec.runs)
+	}
+
+	plain := filepath.Join(dir, "plain.md")
+	_ = os.WriteFile(plain, []byte("# No diagrams\n"), 0644)
+	if out, err := renderDiagrams(context.Background(), plain, kinds); err != nil || out != "" {
+		t.Errorf("expected no copy without mermaid blocks, got %q, %v", out, err)
+	}
+}
+
+func TestResolvePlantUML(t *testing.T) {
+	cfg := &config.Config{Generic: map[string]interface{}{}}
+	if resolvePlantUML(cfg, nil).Enabled {
+		t.Error("plantuml rendering should be opt-in")
+	}
+	cfg.Generic["plantuml"] = map[string]interface{}{"server": "https://plantuml.example.com/plantuml/"}
+	pc := resolvePlantUML(cfg, map[string]interface{}{"plantuml": map[string]interface{}{"format": "png"}})
+	if !pc.Enabled || pc.Server != "https://plantuml.example.com/plantuml" || pc.Format != "png" {
+		t.Errorf("resolvePlantUML = %+v", pc)
+	}
+}
+
+func TestRenderDiagrams_PlantUML(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	input := filepath.Join(dir, "doc.md")
+	src := "```plantuml\nAlice -> Bob: hello\n```\n\n```mermaid\ngraph TD; A-->B\n```\n"
+	if err := os.WriteFile(input, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	exec := &diagramExecutor{}
+	opts := options.Options{Quiet: true}
+	kinds := []diagramKind{
+		mermaidKind(mermaidConfig{Enabled: true, Renderer: rendererMmdc}, "docx", opts, exec),
+		plantumlKind(plantumlConfig{Enabled: true, Jar: "/opt/plantuml.jar"}, "pdf", opts, exec),
+	}
+	out, err := renderDiagrams(context.Background(), input, kinds)
+	if err != nil {
+		t.Fatalf("renderDiagrams failed: %v", err)
+	}
+	defer func() { _ = os.Remove(out) }()
+
+	data, _ := os.ReadFile(out)
+	if strings.Count(string(data), ".png)") != 2 {
+		t.Errorf("expected two PNG images:\n%s", data)
+	}
+	if strings.Join(exec.tools, ",") != "mmdc,java" {
+		t.Errorf("tools = %v, want mmdc then java", exec.tools)
+	}
+}
+
+func TestRenderDiagrams_PlantUMLServer(t *testing.T) {
+	var requested string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.Path
+		_, _ = w.Write([]byte("<svg/>"))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	t.Chdir(dir)
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte("```plantuml\nA -> B\n```\n"), 0644)
+
+	kinds := []diagramKind{plantumlKind(plantumlConfig{Enabled: true, Server: srv.URL}, "html", options.Options{Quiet: true}, &diagramExecutor{})}
+	out, err := renderDiagrams(context.Background(), input, kinds)
+	if err != nil {
+		t.Fatalf("renderDiagrams failed: %v", err)
+	}
+	defer func() { _ = os.Remove(out) }()
+
+	if !strings.HasPrefix(requested, "/svg/~h") {
+		t.Errorf("unexpected server path %q", requested)
+	}
+	images, _ := filepath.Glob(filepath.Join(dir, diagramsDir, "*.svg"))
+	if len(images) != 1 {
+		t.Fatalf("expected one rendered image, got %v", images)
+	}
+	if data, _ := os.ReadFile(images[0]); string(data) != "<svg/>" {
+		t.Errorf("image content = %q", data)
+	}
+}
+
+func TestDiagramTools(t *testing.T) {
+	content := "```mermaid\nA\n```\n```plantuml\nB\n```\n"
+	cfg := &config.Config{Generic: map[string]interface{}{}}
+	if got := strings.Join(diagramTools(cfg, content), ","); got != "mmdc" {
+		t.Errorf("diagramTools = %q, want mmdc", got)
+	}
+	cfg.Generic["plantuml"] = true
+	cfg.Generic["mermaid"] = map[string]interface{}{"renderer": "filter"}
+	if got := strings.Join(diagramTools(cfg, content), ","); got != "mermaid-filter,plantuml" {
+		t.Errorf("diagramTools = %q, want mermaid-filter,plantuml", got)
+	}
+}
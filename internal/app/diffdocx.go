@@ -0,0 +1,147 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/utils"
+)
+
+// DiffDocxOptions holds flags for the diff-docx command.
+type DiffDocxOptions struct {
+	// TrackChanges is pandoc's docx reader policy for tracked changes (accept, reject, all).
+	TrackChanges string
+	// Context is the number of unchanged lines shown around each change.
+	Context int
+	// ReplaceBody is the path of a file to write with the original frontmatter and the
+	// reviewed body. It replaces the body wholesale; it is not a three-way merge.
+	ReplaceBody string
+	// Force enables overwriting an existing ReplaceBody file.
+	Force bool
+	// DryRun prints the pandoc commands without executing them.
+	DryRun bool
+	// Quiet suppresses informational messages.
+	Quiet bool
+}
+
+// RunDiffDocx converts a reviewed DOCX back to Markdown and diffs it against the original source.
+// Both sides are normalized through pandoc so only content changes show up in the diff.
+//
+// Parameters:
+//   - `ctx`: context for cancellation
+//   - `original`: path to the Markdown source
+//   - `reviewed`: path to the reviewed DOCX file
+//   - `opts`: diff options
+//   - `executor`: used to run the pandoc commands
+//   - `w`: where the diff is written
+func RunDiffDocx(ctx context.Context, original, reviewed string, opts DiffDocxOptions, executor CommandExecutor, w io.Writer) error {
+	//nolint:gosec // G304: reading the user-supplied source file is intended
+	source, err := os.ReadFile(original)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", original, err)
+	}
+	if _, err := os.Stat(reviewed); err != nil {
+		return fmt.Errorf("failed to read %s: %w", reviewed, err)
+	}
+	frontmatter, body := config.SplitFrontmatter(string(source))
+
+	trackChanges := opts.TrackChanges
+	if trackChanges == "" {
+		trackChanges = "accept"
+	}
+
+	// Normalize the original body so formatting differences do not drown out real edits
+	bodyFile, err := os.CreateTemp("", "panforge-diff-*.md")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer func() { _ = os.Remove(bodyFile.Name()) }()
+	if _, err := bodyFile.WriteString(body); err != nil {
+		_ = bodyFile.Close()
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := bodyFile.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+
+	normalizedOriginal, err := convertToMarkdown(ctx, executor, opts, bodyFile.Name(), "markdown")
+	if err != nil {
+		return err
+	}
+	normalizedReviewed, err := convertToMarkdown(ctx, executor, opts, reviewed, "docx", "--track-changes", trackChanges)
+	if err != nil {
+		return err
+	}
+	if opts.DryRun {
+		return nil
+	}
+
+	diff := utils.UnifiedDiff(filepath.Base(original), filepath.Base(reviewed), normalizedOriginal, normalizedReviewed, opts.Context)
+	if diff == "" {
+		if !opts.Quiet {
+			_, _ = fmt.Fprintln(w, "No differences found.")
+		}
+	} else {
+		_, _ = fmt.Fprint(w, diff)
+	}
+
+	if opts.ReplaceBody != "" {
+		if _, err := os.Stat(opts.ReplaceBody); err == nil && !opts.Force {
+			return fmt.Errorf("file '%s' already exists (use --force to overwrite)", opts.ReplaceBody)
+		}
+		replaced := frontmatter
+		if replaced != "" {
+			replaced += "\n"
+		}
+		replaced += normalizedReviewed
+		//nolint:gosec // G306: Markdown output should be readable
+		if err := os.WriteFile(opts.ReplaceBody, []byte(replaced), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", opts.ReplaceBody, err)
+		}
+		if !opts.Quiet {
+			fmt.Printf("Wrote the original header with the reviewed body to %s\n", opts.ReplaceBody)
+		}
+	}
+	return nil
+}
+
+// convertToMarkdown runs pandoc on a file and returns the cleaned Markdown output.
+//
+// Parameters:
+//   - `ctx`: context for cancellation
+//   - `executor`: used to run the pandoc command
+//   - `opts`: diff options (dry-run and quiet handling)
+//   - `input`: the file to convert
+//   - `from`: the reader format
+//   - `extra`: additional pandoc arguments
+func convertToMarkdown(ctx context.Context, executor CommandExecutor, opts DiffDocxOptions, input, from string, extra ...string) (string, error) {
+	out, err := os.CreateTemp("", "panforge-diff-*.md")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	_ = out.Close()
+	defer func() { _ = os.Remove(out.Name()) }()
+
+	args := []string{input, "--from", from, "--to", "markdown", "--wrap", "none", "--output", out.Name()}
+	args = append(args, extra...)
+	if opts.DryRun || !opts.Quiet {
+		fmt.Printf("panforge calling: %s\n", formatCommand("pandoc", args))
+	}
+	if opts.DryRun {
+		return "", nil
+	}
+	if err := executor.Run(ctx, "pandoc", args, os.Stdout, os.Stderr); err != nil {
+		return "", fmt.Errorf("pandoc failed on %s: %w", input, err)
+	}
+
+	//nolint:gosec // G304: reading the file pandoc just produced
+	data, err := os.ReadFile(out.Name())
+	if err != nil {
+		return "", fmt.Errorf("failed to read converted output: %w", err)
+	}
+	return CleanImportedMarkdown(string(data), true), nil
+}
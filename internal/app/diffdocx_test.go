@@ -0,0 +1,61 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// diffExecutor simulates pandoc: Markdown inputs are copied, DOCX inputs yield fixed text.
+type diffExecutor struct {
+	reviewed string
+}
+
+func (e *diffExecutor) Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	var output string
+	for i, arg := range args {
+		if arg == "--output" {
+			output = args[i+1]
+		}
+	}
+	content := []byte(e.reviewed)
+	if strings.HasSuffix(args[0], ".md") {
+		var err error
+		if content, err = os.ReadFile(args[0]); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(output, content, 0600)
+}
+
+func TestRunDiffDocx(t *testing.T) {
+	tmpDir := t.TempDir()
+	original := filepath.Join(tmpDir, "paper.md")
+	reviewed := filepath.Join(tmpDir, "paper.docx")
+	replaced := filepath.Join(tmpDir, "replaced.md")
+	_ = os.WriteFile(original, []byte("---\ntitle: Paper\n---\n# Intro\n\nOld sentence.\n"), 0600)
+	_ = os.WriteFile(reviewed, []byte("docx"), 0600)
+
+	exec := &diffExecutor{reviewed: "# Intro\n\nNew sentence.\n"}
+	var out bytes.Buffer
+	opts := DiffDocxOptions{Context: 3, ReplaceBody: replaced, Quiet: true}
+	if err := RunDiffDocx(context.Background(), original, reviewed, opts, exec, &out); err != nil {
+		t.Fatalf("RunDiffDocx failed: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "-Old sentence.\n+New sentence.\n") {
+		t.Errorf("unexpected diff:\n%s", out.String())
+	}
+
+	data, err := os.ReadFile(replaced)
+	if err != nil {
+		t.Fatalf("replaced body not written: %v", err)
+	}
+	if string(data) != "---\ntitle: Paper\n---\n\n# Intro\n\nNew sentence.\n" {
+		t.Errorf("replaced body = %q", data)
+	}
+}
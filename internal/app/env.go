@@ -0,0 +1,45 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// envFlags are the environment variables that set flags, for containers and CI where
+// a command line is awkward to change. A flag given on the command line wins; the
+// variables win over the config files.
+var envFlags = []struct {
+	name string
+	flag string
+}{
+	{"PANFORGE_OUTPUT_DIR", "output-dir"},
+	{"PANFORGE_CONCURRENCY", "concurrency"},
+	{"PANFORGE_PANDOC_PATH", "pandoc-path"},
+	{"PANFORGE_DEFAULT_TO", "default-to"},
+}
+
+// ApplyEnv sets the flags of a command from the PANFORGE_* environment variables that
+// are set, unless the command line gives them. Variables for flags the command does
+// not have are ignored.
+//
+// Parameters:
+//   - `cmd`: the command being run, with its flags parsed
+//
+// Returns:
+//   - error: if a variable holds an invalid value
+func ApplyEnv(cmd *cobra.Command) error {
+	fs := cmd.Flags()
+	for _, e := range envFlags {
+		value := strings.TrimSpace(os.Getenv(e.name))
+		if value == "" || fs.Lookup(e.flag) == nil || fs.Changed(e.flag) {
+			continue
+		}
+		if err := fs.Set(e.flag, value); err != nil {
+			return fmt.Errorf("%s: %w", e.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rapjul/panforge/internal/options"
+)
+
+func TestApplyEnv(t *testing.T) {
+	var opts options.Options
+	cmd := &cobra.Command{}
+	cmd.Flags().StringVar(&opts.OutputDir, "output-dir", "", "")
+	cmd.Flags().IntVarP(&opts.Concurrency, "concurrency", "c", 0, "")
+	cmd.Flags().StringSliceVar(&opts.DefaultTargets, "default-to", nil, "")
+	if err := cmd.ParseFlags([]string{"--concurrency", "2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("PANFORGE_OUTPUT_DIR", "out")
+	t.Setenv("PANFORGE_CONCURRENCY", "8")
+	t.Setenv("PANFORGE_DEFAULT_TO", "pdf,docx")
+	t.Setenv("PANFORGE_PANDOC_PATH", "/opt/pandoc") // the command has no such flag
+	if err := ApplyEnv(cmd); err != nil {
+		t.Fatal(err)
+	}
+	if opts.OutputDir != "out" || len(opts.DefaultTargets) != 2 {
+		t.Errorf("expected the variables to set the flags, got %+v", opts)
+	}
+	if opts.Concurrency != 2 {
+		t.Errorf("expected the command line to win, got %d", opts.Concurrency)
+	}
+
+	cmd = &cobra.Command{}
+	cmd.Flags().IntVarP(&opts.Concurrency, "concurrency", "c", 0, "")
+	t.Setenv("PANFORGE_CONCURRENCY", "many")
+	if err := ApplyEnv(cmd); err == nil {
+		t.Error("expected an error for an invalid value")
+	}
+}
+
+func TestProcess_OutputDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte("---\ntitle: Doc\noutputs: [html]\nfilename-timestamps: false\n---\n# Doc\n"), 0600)
+
+	rec := &envRecorder{}
+	opts := options.Options{NoCache: true, Quiet: true, OutputDir: "out"}
+	if _, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out", "Doc.html")); err != nil {
+		t.Errorf("expected the output in the output directory: %v (pandoc args %v)", err, rec.args)
+	}
+}
@@ -0,0 +1,112 @@
+package app
+
+import (
+	"errors"
+)
+
+// Exit codes of the panforge command, so scripts and CI can branch on the outcome.
+const (
+	// ExitOK means every target was built or already up to date.
+	ExitOK = 0
+	// ExitFailed means at least one target failed.
+	ExitFailed = 1
+	// ExitConfig means the command line or a document's settings are invalid.
+	ExitConfig = 2
+	// ExitSkipped means nothing failed, but at least one target was skipped.
+	ExitSkipped = 3
+	// ExitPandocMissing means pandoc is not installed.
+	ExitPandocMissing = 4
+)
+
+// ErrSkipped is returned by Run when no target failed but some were skipped.
+var ErrSkipped = &ExitError{Code: ExitSkipped, Err: errors.New("some targets were skipped")}
+
+// ExitError is an error with the exit code it should end the command with.
+type ExitError struct {
+	// Code is the exit code.
+	Code int
+	// Err is the underlying error.
+	Err error
+}
+
+// Error returns the message of the underlying error.
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// configError marks an error as an invalid configuration.
+func configError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ExitError{Code: ExitConfig, Err: err}
+}
+
+// exitSeverity orders exit codes for runs with several outcomes, most severe last:
+// a missing pandoc outranks a failed target, which outranks an invalid configuration,
+// which outranks a skipped target.
+var exitSeverity = map[int]int{
+	ExitOK:            0,
+	ExitSkipped:       1,
+	ExitConfig:        2,
+	ExitFailed:        3,
+	ExitPandocMissing: 4,
+}
+
+// ExitCode returns the exit code for the error a command returned. Joined errors, e.g.
+// of a directory build, are walked completely and the most severe code wins, so the
+// result does not depend on the order of the files.
+//
+// Parameters:
+//   - `err`: the command's error
+//
+// Returns:
+//   - int: ExitOK for nil, the most severe code of the ExitErrors in the tree, and
+//     ExitFailed for errors without one
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	switch e := err.(type) {
+	case *ExitError:
+		return e.Code
+	case interface{ Unwrap() []error }:
+		code := ExitOK
+		for _, inner := range e.Unwrap() {
+			if c := ExitCode(inner); exitSeverity[c] > exitSeverity[code] {
+				code = c
+			}
+		}
+		return code
+	case interface{ Unwrap() error }:
+		if inner := e.Unwrap(); inner != nil {
+			return ExitCode(inner)
+		}
+	}
+	return ExitFailed
+}
+
+// skippedError returns ErrSkipped if a run that succeeded skipped any target.
+//
+// Parameters:
+//   - `results`: the results of the run
+//   - `err`: the run's error
+//
+// Returns:
+//   - error: err if set, ErrSkipped if a target was skipped, otherwise nil
+func skippedError(results []TargetResult, err error) error {
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		if r.Status == StatusSkipped {
+			return ErrSkipped
+		}
+	}
+	return nil
+}
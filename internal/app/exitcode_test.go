@@ -0,0 +1,77 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rapjul/panforge/internal/options"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"ok", nil, ExitOK},
+		{"failed", errors.New("boom"), ExitFailed},
+		{"skipped", ErrSkipped, ExitSkipped},
+		{"wrapped config", fmt.Errorf("target pdf: %w", configError(errors.New("bad"))), ExitConfig},
+		{"joined", errors.Join(errors.New("boom"), &ExitError{Code: ExitPandocMissing, Err: errors.New("no pandoc")}), ExitPandocMissing},
+		{"config then failure", errors.Join(fmt.Errorf("a.md: %w", configError(errors.New("bad"))), fmt.Errorf("b.md: %w", errors.New("boom"))), ExitFailed},
+		{"failure then config", errors.Join(fmt.Errorf("a.md: %w", errors.New("boom")), fmt.Errorf("b.md: %w", configError(errors.New("bad")))), ExitFailed},
+		{"config and skipped", errors.Join(ErrSkipped, configError(errors.New("bad"))), ExitConfig},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(tt.err); got != tt.want {
+				t.Errorf("ExitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRun_ExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	t.Chdir(dir)
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte("---\noutput:\n  html:\n    output: doc.html\n  pdf:\n    output: doc.pdf\n    timeout: soon\n---\n# Doc\n"), 0600)
+	_ = os.WriteFile(filepath.Join(dir, "doc.html"), []byte("previous"), 0600)
+
+	rec := &argsRecorder{inputs: map[string]string{}, args: map[string][]string{}}
+	cmd := &cobra.Command{}
+	opts := options.Options{Targets: []string{"html"}, NoCache: true, Quiet: true, OnConflict: conflictSkip}
+	if err := Run(context.Background(), cmd, []string{input}, opts, rec); ExitCode(err) != ExitSkipped {
+		t.Errorf("expected a skipped target to exit %d, got %v", ExitSkipped, err)
+	}
+
+	opts.Targets = []string{"pdf"}
+	if err := Run(context.Background(), cmd, []string{input}, opts, rec); ExitCode(err) != ExitConfig {
+		t.Errorf("expected an invalid setting to exit %d, got %v", ExitConfig, err)
+	}
+}
+
+func TestRunMany_ExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	// a.md has an invalid setting (exit 2); b.md fails to build (exit 1)
+	invalid := filepath.Join(dir, "a.md")
+	_ = os.WriteFile(invalid, []byte("---\noutput:\n  html:\n    output: a.html\n    timeout: soon\n---\n# A\n"), 0600)
+	failing := filepath.Join(dir, "b.md")
+	_ = os.WriteFile(failing, []byte("---\noutput:\n  html:\n    output: b.html\n---\n# B\n"), 0600)
+
+	opts := options.Options{Targets: []string{"html"}, NoCache: true, Quiet: true, Force: true}
+	for _, files := range [][]string{{invalid, failing}, {failing, invalid}} {
+		_, err := runMany(context.Background(), files, nil, opts, &partialExecutor{})
+		if code := ExitCode(err); code != ExitFailed {
+			t.Errorf("runMany(%v) exit code = %d, want %d (%v)", files, code, ExitFailed, err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rapjul/panforge/internal/config"
+)
+
+// convertFrontmatter gives pandoc a YAML header in place of TOML (`+++`) or JSON front
+// matter, which it would otherwise convert as text. A document from stdin is converted
+// in memory; a file is copied next to itself, so relative resource paths keep working.
+//
+// Parameters:
+//   - `sourceFile`: the file pandoc converts, or "-" for stdin
+//   - `stdin`: the document when it is streamed from stdin, else nil
+//
+// Returns:
+//   - string: the file to convert: sourceFile, or a converted copy the caller removes
+//   - []byte: the document to stream, converted
+//   - error: if the file cannot be read or written, or its front matter is invalid
+func convertFrontmatter(sourceFile string, stdin []byte) (string, []byte, error) {
+	if stdin != nil {
+		converted, _, err := config.YAMLFrontmatter(stdin)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid front matter: %w", err)
+		}
+		return sourceFile, converted, nil
+	}
+	//nolint:gosec // G304: reading the input file is intended
+	data, err := os.ReadFile(sourceFile)
+	if err != nil {
+		return "", nil, err
+	}
+	converted, ok, err := config.YAMLFrontmatter(data)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: invalid front matter: %w", sourceFile, err)
+	}
+	if !ok {
+		return sourceFile, nil, nil
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(sourceFile), ".panforge-frontmatter-*"+filepath.Ext(sourceFile))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	if _, err := tmp.Write(converted); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return "", nil, fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", nil, fmt.Errorf("failed to close temp file: %w", err)
+	}
+	return tmp.Name(), nil, nil
+}
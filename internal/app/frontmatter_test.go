@@ -0,0 +1,47 @@
+package app
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/options"
+)
+
+// frontmatterRecorder records the source pandoc is given to convert.
+type frontmatterRecorder struct {
+	envRecorder
+	source string
+}
+
+func (r *frontmatterRecorder) Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	if data, err := os.ReadFile(args[0]); err == nil {
+		r.source = string(data)
+	}
+	return r.envRecorder.Run(ctx, name, args, stdout, stderr)
+}
+
+func TestProcess_TOMLFrontmatter(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	input := filepath.Join(dir, "post.md")
+	_ = os.WriteFile(input, []byte("+++\ntitle = \"Post\"\noutputs = [\"html\"]\nfilename-timestamps = false\n+++\n# Body\n"), 0600)
+
+	rec := &frontmatterRecorder{}
+	opts := options.Options{NoCache: true, Quiet: true}
+	if _, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(rec.source, "---\ntitle: Post\n") || strings.Contains(rec.source, "+++") {
+		t.Errorf("expected pandoc to get a YAML header, got:\n%s", rec.source)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Post.html")); err != nil {
+		t.Errorf("expected the output named after the TOML title: %v", err)
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(dir, ".panforge-frontmatter-*")); len(leftovers) > 0 {
+		t.Errorf("expected the converted copy to be removed, got %v", leftovers)
+	}
+}
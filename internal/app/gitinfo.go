@@ -0,0 +1,44 @@
+package app
+
+import (
+	"path/filepath"
+	"sync"
+
+	"github.com/rapjul/panforge/internal/utils"
+)
+
+// gitInfoCache holds the git description of each input directory for the process, so
+// the targets of a run ask git once.
+var gitInfoCache sync.Map
+
+// gitInfo describes the git repository containing an input; it is empty outside one.
+//
+// Parameters:
+//   - `inputFile`: the converted document
+func gitInfo(inputFile string) utils.GitInfo {
+	dir := filepath.Dir(inputFile)
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	if info, ok := gitInfoCache.Load(dir); ok {
+		return info.(utils.GitInfo)
+	}
+	info, _ := utils.GitDescribe(dir)
+	gitInfoCache.Store(dir, info)
+	return info
+}
+
+// gitMetadataArgs passes the git description of an input to pandoc as the git-version,
+// git-commit, and git-branch metadata; values git does not know are left out.
+//
+// Parameters:
+//   - `info`: the git description
+func gitMetadataArgs(info utils.GitInfo) []string {
+	var args []string
+	for _, kv := range [][2]string{{"git-version", info.Version}, {"git-commit", info.Commit}, {"git-branch", info.Branch}} {
+		if kv[1] != "" {
+			args = append(args, "--metadata", kv[0]+"="+kv[1])
+		}
+	}
+	return args
+}
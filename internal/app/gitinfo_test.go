@@ -0,0 +1,39 @@
+package app
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/options"
+)
+
+func TestProcess_GitVariables(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte("---\ngit-metadata: true\nfilename-template: \"{title}-{version}-{branch}.{ext}\"\noutputs: [html]\n---\n# Doc\n"), 0600)
+	for _, args := range [][]string{{"init", "-q", "-b", "main"}, {"add", "."}, {"commit", "-q", "-m", "init"}, {"tag", "v2.0"}} {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=t", "-c", "user.email=t@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	rec := &envRecorder{}
+	if _, err := process(context.Background(), input, nil, options.Options{NoCache: true, Quiet: true}, rec, processEnv{baseDir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Doc-v2.0-main.html")); err != nil {
+		t.Errorf("expected the name to use the tag and branch: %v", err)
+	}
+	if len(rec.args) != 1 || !slices.Contains(rec.args[0], "git-version=v2.0") || !slices.Contains(rec.args[0], "git-branch=main") {
+		t.Errorf("expected the git metadata to be passed to pandoc, got %v", rec.args)
+	}
+}
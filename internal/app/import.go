@@ -0,0 +1,279 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/rapjul/panforge/internal/pandoc"
+	"github.com/rapjul/panforge/internal/preprocess"
+	"github.com/rapjul/panforge/internal/utils"
+)
+
+// ImportOptions holds flags for the import command.
+type ImportOptions struct {
+	// Output is the Markdown file to write (default: <input>.md next to the input).
+	Output string
+	// From overrides the reader format detected from the input extension.
+	From string
+	// To is the Markdown flavor to produce (default: markdown).
+	To string
+	// Wrap is pandoc's --wrap setting (auto, none, preserve).
+	Wrap string
+	// Columns is the line length used when Wrap is "auto".
+	Columns int
+	// MediaDir is the directory images are extracted to (default: <input>-media next to the output).
+	MediaDir string
+	// NoMedia disables image extraction.
+	NoMedia bool
+	// KeepQuotes disables smart-quote normalization.
+	KeepQuotes bool
+	// NoFrontmatter disables frontmatter synthesis from document properties.
+	NoFrontmatter bool
+	// Force enables overwriting an existing output file.
+	Force bool
+	// DryRun prints the pandoc command without executing it.
+	DryRun bool
+	// Quiet suppresses informational messages.
+	Quiet bool
+}
+
+var (
+	trailingSpaceRegex = regexp.MustCompile(`(?m)[ \t]+$`)
+	blankLinesRegex    = regexp.MustCompile(`\n{3,}`)
+	quoteReplacer      = strings.NewReplacer(
+		"‘", "'", "’", "'", "‚", "'", "‛", "'",
+		"“", `"`, "”", `"`, "„", `"`, "‟", `"`,
+		" ", " ",
+	)
+)
+
+// RunImport converts an office document (DOCX, ODT, ...) into clean Markdown.
+//
+// Parameters:
+//   - `ctx`: context for cancellation
+//   - `inputFile`: path to the document to import
+//   - `opts`: import options
+//   - `executor`: used to run the pandoc command
+func RunImport(ctx context.Context, inputFile string, opts ImportOptions, executor CommandExecutor) error {
+	resolvedInput, err := utils.ResolvePath(inputFile)
+	if err != nil {
+		return fmt.Errorf("failed to resolve input file path: %w", err)
+	}
+	inputFile = resolvedInput
+	if _, err := os.Stat(inputFile); err != nil {
+		return fmt.Errorf("input file not found: %w", err)
+	}
+
+	from := opts.From
+	if from == "" {
+		from = pandoc.InputFormatForExt(filepath.Ext(inputFile))
+	}
+	if from == "" {
+		return fmt.Errorf("cannot determine input format of %s (use --from)", inputFile)
+	}
+
+	base := strings.TrimSuffix(filepath.Base(inputFile), filepath.Ext(inputFile))
+	outputFile := opts.Output
+	if outputFile == "" {
+		outputFile = filepath.Join(filepath.Dir(inputFile), base+".md")
+	}
+	outputFile, err = utils.ResolvePath(outputFile)
+	if err != nil {
+		return fmt.Errorf("failed to resolve output file path: %w", err)
+	}
+	if _, err := os.Stat(outputFile); err == nil && !opts.Force {
+		return fmt.Errorf("file '%s' already exists (use --force to overwrite)", outputFile)
+	}
+	outDir := filepath.Dir(outputFile)
+
+	to := opts.To
+	if to == "" {
+		to = "markdown"
+	}
+	wrap := opts.Wrap
+	if wrap == "" {
+		wrap = "none"
+	}
+
+	// pandoc writes to a temporary file first so the cleanup pass can rewrite it
+	tmpFile := outputFile + ".panforge-import.tmp"
+	args := []string{inputFile, "--from", from, "--to", to, "--wrap", wrap, "--output", tmpFile}
+	if wrap == "auto" && opts.Columns > 0 {
+		args = append(args, "--columns", strconv.Itoa(opts.Columns))
+	}
+	mediaDir := ""
+	if !opts.NoMedia {
+		mediaDir = opts.MediaDir
+		if mediaDir == "" {
+			mediaDir = filepath.Join(outDir, base+"-media")
+		}
+		mediaDir, err = utils.ResolvePath(mediaDir)
+		if err != nil {
+			return fmt.Errorf("failed to resolve media directory: %w", err)
+		}
+		args = append(args, "--extract-media", mediaDir)
+	}
+
+	cmdStr := formatCommand("pandoc", args)
+	if opts.DryRun {
+		fmt.Printf("panforge calling: %s\n", cmdStr)
+		return nil
+	}
+	if !opts.Quiet {
+		fmt.Printf("panforge calling: %s\n", cmdStr)
+	}
+
+	defer func() { _ = os.Remove(tmpFile) }()
+	if err := executor.Run(ctx, "pandoc", args, os.Stdout, os.Stderr); err != nil {
+		return fmt.Errorf("pandoc failed: %w", err)
+	}
+
+	//nolint:gosec // G304: reading the file pandoc just produced
+	converted, err := os.ReadFile(tmpFile)
+	if err != nil {
+		return fmt.Errorf("failed to read converted output: %w", err)
+	}
+
+	content := string(converted)
+	if mediaDir != "" {
+		content = relativizeMediaLinks(content, outDir)
+	}
+	content = CleanImportedMarkdown(content, !opts.KeepQuotes)
+
+	if !opts.NoFrontmatter && !strings.HasPrefix(content, "---\n") {
+		props, err := utils.ReadDocumentProperties(inputFile)
+		if err != nil {
+			props = &utils.DocumentProperties{}
+		}
+		frontmatter, err := synthesizeFrontmatter(props, from, base)
+		if err != nil {
+			return err
+		}
+		content = frontmatter + "\n" + content
+	}
+
+	//nolint:gosec // G306: Markdown output should be readable
+	if err := os.WriteFile(outputFile, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", outputFile, err)
+	}
+	if !opts.Quiet {
+		fmt.Printf("Imported %s to %s\n", inputFile, outputFile)
+	}
+	return nil
+}
+
+// relativizeMediaLinks makes links and images that point into dir relative to it, so
+// extracted media is found next to the Markdown file. Only link targets (`](` and
+// `src="`) are rewritten; the same path in the text is left alone.
+//
+// Parameters:
+//   - `content`: the converted Markdown
+//   - `dir`: the directory of the Markdown file
+func relativizeMediaLinks(content, dir string) string {
+	prefix := regexp.QuoteMeta(filepath.ToSlash(dir) + "/")
+	if native := dir + string(os.PathSeparator); native != filepath.ToSlash(dir)+"/" {
+		prefix += "|" + regexp.QuoteMeta(native)
+	}
+	link := regexp.MustCompile(`(\]\(\s*<?|\bsrc=["']?)(?:` + prefix + `)`)
+	return link.ReplaceAllString(content, "${1}")
+}
+
+// RunImports imports several documents, each to Markdown next to it. A failed import
+// does not stop the others; their errors are reported together.
+//
+// Parameters:
+//   - `ctx`: context for cancellation
+//   - `inputFiles`: paths to the documents to import
+//   - `opts`: import options; --output and --media-dir only apply to a single document
+//   - `executor`: used to run the pandoc commands
+//
+// Returns:
+//   - error: the joined errors of all failed imports
+func RunImports(ctx context.Context, inputFiles []string, opts ImportOptions, executor CommandExecutor) error {
+	if len(inputFiles) == 1 {
+		return RunImport(ctx, inputFiles[0], opts, executor)
+	}
+	if opts.Output != "" || opts.MediaDir != "" {
+		return configError(fmt.Errorf("--output and --media-dir name the files of one document; import several documents without them"))
+	}
+	var errs []error
+	for _, inputFile := range inputFiles {
+		if ctx.Err() != nil {
+			errs = append(errs, ctx.Err())
+			break
+		}
+		if err := RunImport(ctx, inputFile, opts, executor); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", inputFile, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CleanImportedMarkdown tidies Markdown produced by a reverse conversion. Fenced code
+// blocks and inline code spans are left as pandoc wrote them.
+//
+// Parameters:
+//   - `content`: the Markdown text
+//   - `normalizeQuotes`: replace typographic quotes and non-breaking spaces with ASCII
+//
+// Returns:
+//   - string: the cleaned Markdown, ending with a single newline
+func CleanImportedMarkdown(content string, normalizeQuotes bool) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content, restore := preprocess.MaskCode(content)
+	if normalizeQuotes {
+		content = quoteReplacer.Replace(content)
+	}
+	content = trailingSpaceRegex.ReplaceAllString(content, "")
+	content = blankLinesRegex.ReplaceAllString(content, "\n\n")
+	return restore(strings.TrimSpace(content)) + "\n"
+}
+
+// synthesizeFrontmatter builds a YAML header from office document properties.
+//
+// Parameters:
+//   - `props`: the document properties
+//   - `from`: the source format, used as the default round-trip output
+//   - `fallbackTitle`: title used when the document has none
+func synthesizeFrontmatter(props *utils.DocumentProperties, from string, fallbackTitle string) (string, error) {
+	fm := struct {
+		Title       string   `yaml:"title"`
+		Author      string   `yaml:"author,omitempty"`
+		Date        string   `yaml:"date,omitempty"`
+		Subject     string   `yaml:"subject,omitempty"`
+		Description string   `yaml:"description,omitempty"`
+		Keywords    []string `yaml:"keywords,omitempty"`
+		Outputs     []string `yaml:"outputs"`
+	}{
+		Title:       props.Title,
+		Author:      props.Author,
+		Subject:     props.Subject,
+		Description: props.Description,
+		Keywords:    props.Keywords,
+		Outputs:     []string{from},
+	}
+	if fm.Title == "" {
+		fm.Title = fallbackTitle
+	}
+	if len(props.Created) >= 10 {
+		fm.Date = props.Created[:10]
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(fm); err != nil {
+		return "", fmt.Errorf("failed to encode frontmatter: %w", err)
+	}
+	_ = enc.Close()
+	return "---\n" + buf.String() + "---\n", nil
+}
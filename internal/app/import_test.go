@@ -0,0 +1,114 @@
+package app
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// importExecutor simulates pandoc by writing fixed Markdown to the --output path.
+type importExecutor struct {
+	content string
+	args    []string
+}
+
+func (e *importExecutor) Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	e.args = args
+	for i, arg := range args {
+		if arg == "--output" && i+1 < len(args) {
+			return os.WriteFile(args[i+1], []byte(e.content), 0600)
+		}
+	}
+	return nil
+}
+
+func TestRunImport(t *testing.T) {
+	tmpDir := t.TempDir()
+	input := filepath.Join(tmpDir, "report.docx")
+	if err := os.WriteFile(input, []byte("not a real docx"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	exec := &importExecutor{content: "# “Hello”   \n\n\n\nIt’s here.\n"}
+	if err := RunImport(context.Background(), input, ImportOptions{Quiet: true}, exec); err != nil {
+		t.Fatalf("RunImport failed: %v", err)
+	}
+
+	joined := strings.Join(exec.args, " ")
+	if !strings.Contains(joined, "--from docx") || !strings.Contains(joined, "--extract-media") {
+		t.Errorf("unexpected pandoc args: %v", exec.args)
+	}
+
+	got, err := os.ReadFile(filepath.Join(tmpDir, "report.md"))
+	if err != nil {
+		t.Fatalf("output not written: %v", err)
+	}
+	want := "---\ntitle: report\noutputs:\n  - docx\n---\n\n# \"Hello\"\n\nIt's here.\n"
+	if string(got) != want {
+		t.Errorf("RunImport output = %q, want %q", got, want)
+	}
+
+	// A second import must not clobber the file without --force
+	if err := RunImport(context.Background(), input, ImportOptions{Quiet: true}, exec); err == nil {
+		t.Error("expected error when output exists, got nil")
+	}
+}
+
+func TestCleanImportedMarkdown(t *testing.T) {
+	got := CleanImportedMarkdown("“a”\r\n\r\n\r\nb  \n", false)
+	if got != "“a”\n\nb\n" {
+		t.Errorf("CleanImportedMarkdown() = %q", got)
+	}
+
+	// Code keeps its quotes, trailing spaces, and blank lines
+	in := "Say “hi”   \n\n\n\n``` python\nprint(“hi”)  \n\n\n\nx = ‘a’\n```\n\nRun `echo “x”` now.\n"
+	got = CleanImportedMarkdown(in, true)
+	want := "Say \"hi\"\n\n``` python\nprint(“hi”)  \n\n\n\nx = ‘a’\n```\n\nRun `echo “x”` now.\n"
+	if got != want {
+		t.Errorf("CleanImportedMarkdown() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestRelativizeMediaLinks(t *testing.T) {
+	dir := filepath.Join("/docs", "report")
+	content := "The files live in /docs/report/ on the server.\n\n" +
+		"![Chart](/docs/report/report-media/media/image1.png)\n\n" +
+		"[data](</docs/report/report-media/data.xlsx>)\n\n" +
+		`<img src="/docs/report/report-media/media/image2.png" />` + "\n"
+	want := "The files live in /docs/report/ on the server.\n\n" +
+		"![Chart](report-media/media/image1.png)\n\n" +
+		"[data](<report-media/data.xlsx>)\n\n" +
+		`<img src="report-media/media/image2.png" />` + "\n"
+	if got := relativizeMediaLinks(content, dir); got != want {
+		t.Errorf("relativizeMediaLinks() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRunImports(t *testing.T) {
+	tmpDir := t.TempDir()
+	var inputs []string
+	for _, name := range []string{"a.docx", "b.odt"} {
+		input := filepath.Join(tmpDir, name)
+		_ = os.WriteFile(input, []byte("not a real document"), 0600)
+		inputs = append(inputs, input)
+	}
+	inputs = append(inputs, filepath.Join(tmpDir, "missing.docx"))
+
+	exec := &importExecutor{content: "Text.\n"}
+	err := RunImports(context.Background(), inputs, ImportOptions{Quiet: true}, exec)
+	if err == nil || !strings.Contains(err.Error(), "missing.docx") {
+		t.Errorf("expected the missing document to fail, got %v", err)
+	}
+	for _, name := range []string{"a.md", "b.md"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, name)); err != nil {
+			t.Errorf("expected %s despite the failure: %v", name, err)
+		}
+	}
+
+	if err := RunImports(context.Background(), inputs[:2], ImportOptions{Quiet: true, Output: "out.md"}, exec); err == nil {
+		t.Error("expected --output to be refused for several documents")
+	}
+}
@@ -20,6 +20,8 @@ type InitOptions struct {
 	Force bool
 	// Formats is a list of targets to include in the scaffolded markdown.
 	Formats []string
+	// Quiet suppresses the "Created ..." message.
+	Quiet bool
 }
 
 // KnownFormats are the formats supported by the scaffold generator.
@@ -47,7 +49,7 @@ func createConfig(opts InitOptions) error {
 		return fmt.Errorf("failed to load config template: %w", err)
 	}
 	// For now, config template is static, but we could template it later
-	return createFile(".panforge.yaml", content, opts.Force)
+	return createFile(".panforge.yaml", content, opts)
 }
 
 // createScaffold generates a sample markdown input file.
@@ -74,17 +76,17 @@ func createScaffold(opts InitOptions) error {
 		return fmt.Errorf("failed to execute template: %w", err)
 	}
 
-	return createFile("input.md", buf.String(), opts.Force)
+	return createFile("input.md", buf.String(), opts)
 }
 
 // createFile writes content to a file.
 // filename is the name of the file to create.
 // content is the string content to write.
-// force determines if existing files should be overwritten.
-func createFile(filename string, content string, force bool) error {
+// opts decides if existing files are overwritten (Force) and if the result is reported (Quiet).
+func createFile(filename string, content string, opts InitOptions) error {
 	// Check if file exists
 	if _, err := os.Stat(filename); err == nil {
-		if !force {
+		if !opts.Force {
 			return fmt.Errorf("file '%s' already exists (use --force to overwrite)", filename)
 		}
 	}
@@ -95,7 +97,9 @@ func createFile(filename string, content string, force bool) error {
 		return fmt.Errorf("failed to write %s: %w", filename, err)
 	}
 
-	absPath, _ := filepath.Abs(filename)
-	fmt.Printf("Created %s at %s\n", filename, absPath)
+	if !opts.Quiet {
+		absPath, _ := filepath.Abs(filename)
+		fmt.Printf("Created %s at %s\n", filename, absPath)
+	}
 	return nil
 }
@@ -0,0 +1,150 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/pandoc"
+)
+
+// errNoHeader reports an input whose format has no place for a YAML header.
+var errNoHeader = errors.New("no YAML header")
+
+// sourceFormat returns the pandoc reader of an input: the format given with --from or
+// `from` without its extensions, else the one its file extension implies.
+//
+// Parameters:
+//   - `inputFile`: the document
+//   - `from`: the explicit input format, or ""
+//
+// Returns:
+//   - string: the reader, or "" if it is not known
+func sourceFormat(inputFile, from string) string {
+	if from != "" {
+		if i := strings.IndexAny(from, "+-"); i > 0 {
+			return from[:i]
+		}
+		return from
+	}
+	return pandoc.SourceFormatForExt(filepath.Ext(inputFile))
+}
+
+// loadDocumentConfig reads the panforge settings of a document (see parseDocumentConfig).
+//
+// Parameters:
+//   - `inputFile`: the document
+//   - `from`: the explicit input format, or ""
+//
+// Returns:
+//   - *config.Config: the settings
+//   - error: errNoHeader for formats without settings, or a read or parse error
+func loadDocumentConfig(inputFile, from string) (*config.Config, error) {
+	switch format := sourceFormat(inputFile, from); {
+	case headerFormat(format):
+		_, cfg, err := config.LoadConfig(inputFile)
+		return cfg, err
+	case format == "ipynb" || format == "org":
+		//nolint:gosec // G304: reading the input file is intended
+		data, err := os.ReadFile(inputFile)
+		if err != nil {
+			return nil, err
+		}
+		return parseDocumentConfig(data, inputFile, from)
+	default:
+		return nil, fmt.Errorf("%s input: %w", format, errNoHeader)
+	}
+}
+
+// headerFormat reports whether an input format carries a YAML header. Files of unknown
+// type are read as Markdown, as pandoc does.
+func headerFormat(format string) bool {
+	switch format {
+	case "", "markdown", "commonmark", "commonmark_x", "gfm", "markdown_strict", "markdown_mmd", "markdown_phpextra":
+		return true
+	}
+	return false
+}
+
+// parseDocumentConfig reads the panforge settings of a document the way its format
+// carries them: the YAML header of Markdown, the notebook metadata of Jupyter
+// notebooks, and the #+TITLE, #+AUTHOR, and #+DATE keywords of Org files. Other formats
+// have nowhere to put them.
+//
+// Parameters:
+//   - `data`: the document's content
+//   - `inputFile`: the document's path, whose extension tells its format
+//   - `from`: the explicit input format, or ""
+//
+// Returns:
+//   - *config.Config: the settings
+//   - error: errNoHeader for formats without settings, or a parse error
+func parseDocumentConfig(data []byte, inputFile, from string) (*config.Config, error) {
+	switch format := sourceFormat(inputFile, from); {
+	case headerFormat(format):
+		return config.ParseConfig(data)
+	case format == "ipynb":
+		return notebookConfig(data, inputFile)
+	case format == "org":
+		return orgConfig(data)
+	default:
+		return nil, fmt.Errorf("%s input: %w", format, errNoHeader)
+	}
+}
+
+// notebookConfig reads the settings of a Jupyter notebook from its metadata, which
+// pandoc also reads as the document's metadata.
+func notebookConfig(data []byte, inputFile string) (*config.Config, error) {
+	var notebook struct {
+		Metadata json.RawMessage `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &notebook); err != nil {
+		return nil, fmt.Errorf("error parsing notebook '%s': %w", inputFile, err)
+	}
+	cfg := &config.Config{}
+	if len(notebook.Metadata) == 0 {
+		return cfg, nil
+	}
+	// JSON is YAML, so the metadata decodes like a YAML header
+	if err := yaml.Unmarshal(notebook.Metadata, cfg); err != nil {
+		return nil, fmt.Errorf("error parsing notebook metadata in '%s': %w", inputFile, err)
+	}
+	return cfg, nil
+}
+
+// orgConfig reads the title, author, and date keywords at the top of an Org file.
+func orgConfig(data []byte) (*config.Config, error) {
+	cfg := &config.Config{Generic: map[string]interface{}{}}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "# ") {
+			continue
+		}
+		if !strings.HasPrefix(line, "#+") {
+			break // the keywords end where the text starts
+		}
+		key, value, ok := strings.Cut(line[2:], ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(key) {
+		case "title":
+			cfg.Title = value
+		case "author":
+			cfg.Author = value
+		case "date":
+			cfg.Generic["date"] = value
+		}
+	}
+	return cfg, scanner.Err()
+}
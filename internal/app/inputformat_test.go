@@ -0,0 +1,62 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/options"
+)
+
+func TestLoadDocumentConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		_ = os.WriteFile(path, []byte(content), 0600)
+		return path
+	}
+
+	notebook := write("analysis.ipynb", `{"cells": [], "metadata": {"title": "Analysis", "outputs": ["html", "pdf"]}, "nbformat": 4}`)
+	cfg, err := loadDocumentConfig(notebook, "")
+	if err != nil || cfg.Title != "Analysis" || len(cfg.Outputs) != 2 {
+		t.Errorf("expected the notebook metadata, got %+v, %v", cfg, err)
+	}
+
+	org := write("plan.org", "#+TITLE: The Plan\n#+AUTHOR: Ann\n#+DATE: 2024-01-02\n\n* Heading\n#+TITLE: not this\n")
+	cfg, err = loadDocumentConfig(org, "")
+	if err != nil || cfg.Title != "The Plan" || cfg.Author != "Ann" || cfg.Generic["date"] != "2024-01-02" {
+		t.Errorf("expected the Org keywords, got %+v, %v", cfg, err)
+	}
+
+	rst := write("page.rst", "Title\n=====\n\nText.\n")
+	if _, err := loadDocumentConfig(rst, ""); !errors.Is(err, errNoHeader) {
+		t.Errorf("expected rst to have no header, got %v", err)
+	}
+	if _, err := loadDocumentConfig(write("page.txt", "---\ntitle: Text\n---\n"), "rst"); !errors.Is(err, errNoHeader) {
+		t.Errorf("expected --from to pick the strategy, got %v", err)
+	}
+}
+
+func TestProcess_DetectsInputFormat(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	input := filepath.Join(dir, "page.rst")
+	_ = os.WriteFile(input, []byte("Title\n=====\n\nText.\n"), 0600)
+
+	rec := &envRecorder{}
+	opts := options.Options{NoCache: true, Quiet: true}
+	if _, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir}); err == nil || !strings.Contains(err.Error(), "-t") {
+		t.Errorf("expected a hint to choose targets with -t, got %v", err)
+	}
+	opts.Targets = []string{"html"}
+	if _, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	if i := slices.Index(rec.args[0], "--from"); i < 0 || rec.args[0][i+1] != "rst" {
+		t.Errorf("expected --from rst, got %v", rec.args)
+	}
+}
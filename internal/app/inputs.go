@@ -0,0 +1,181 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rapjul/panforge/internal/options"
+	"github.com/rapjul/panforge/internal/utils"
+)
+
+// markdownExts are the file extensions treated as Markdown sources in directory mode.
+var markdownExts = map[string]bool{
+	".md":       true,
+	".markdown": true,
+}
+
+// collectInputs finds the Markdown sources under a directory, skipping hidden directories.
+//
+// Parameters:
+//   - `dir`: the directory to search
+//
+// Returns:
+//   - []string: absolute paths of Markdown files, sorted
+//   - error: any error walking the directory
+func collectInputs(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if markdownExts[strings.ToLower(filepath.Ext(path))] {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// filterChanged keeps only the files git reports as changed since a ref.
+//
+// Parameters:
+//   - `files`: candidate input files (absolute paths)
+//   - `dir`: a directory inside the git repository
+//   - `ref`: the git ref to compare against
+//
+// Returns:
+//   - []string: the changed files, in their original order
+//   - error: if git cannot determine the changed files
+func filterChanged(files []string, dir, ref string) ([]string, error) {
+	changed, err := utils.GitChangedFiles(dir, ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files changed since %s: %w", ref, err)
+	}
+	// Compare resolved paths so symlinked temp or home directories still match
+	set := make(map[string]bool, len(changed))
+	for _, f := range changed {
+		set[evalPath(f)] = true
+	}
+	var kept []string
+	for _, f := range files {
+		if set[evalPath(f)] {
+			kept = append(kept, f)
+		}
+	}
+	return kept, nil
+}
+
+// evalPath resolves symlinks in a path, falling back to the cleaned path.
+func evalPath(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return filepath.Clean(path)
+}
+
+// runMany converts several input files one after another, continuing past failures.
+//
+// Parameters:
+//   - `ctx`: context for cancellation
+//   - `files`: the input files
+//   - `postArgs`: extra pandoc arguments
+//   - `opts`: runtime options
+//   - `executor`: interface for running system commands
+//
+// Returns:
+//   - []TargetResult: the results of every file
+//   - error: the joined errors of all failed files, or ErrSkipped if a target was skipped
+func runMany(ctx context.Context, files []string, postArgs []string, opts options.Options, executor CommandExecutor) ([]TargetResult, error) {
+	// Never prompt for targets once per file
+	opts.NoInteractive = true
+	// --archive and --manifest cover the outputs of every file at once
+	archive, manifest := opts.Archive, opts.Manifest
+	opts.Archive, opts.Manifest = "", ""
+	runStart := time.Now()
+	// The files share one build directory (keep-builds), pruned after the last one
+	env := processEnv{interactive: true, builds: newBuildRuns(runStart, !opts.DryRun)}
+	var errs []error
+	var all []TargetResult
+	failed := 0
+	for _, file := range files {
+		if ctx.Err() != nil {
+			errs = append(errs, ctx.Err())
+			break
+		}
+		results, err := processFileIn(ctx, file, postArgs, opts, executor, env)
+		all = append(all, results...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", file, err))
+			failed++
+		}
+	}
+	if !opts.DryRun {
+		env.builds.finish(opts)
+	}
+	if manifest != "" {
+		if err := finishManifest(manifest, all, time.Since(runStart), opts); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if archive != "" {
+		if err := finishArchive(archive, all, errors.Join(errs...), opts); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	// One notification for the whole run, not one per file
+	notifySummary(opts, len(files), failed, runStart, errors.Join(errs...))
+	return all, skippedError(all, errors.Join(errs...))
+}
+
+// resolveInputs expands a directory input and applies --changed-since filtering.
+//
+// Parameters:
+//   - `inputFile`: the resolved input path
+//   - `opts`: runtime options
+//
+// Returns:
+//   - []string: the files to convert (nil if the input is a single file that is converted as-is)
+//   - bool: true if the input is a directory or filtering applies
+//   - error: any error scanning or querying git
+func resolveInputs(inputFile string, opts options.Options) ([]string, bool, error) {
+	info, err := os.Stat(inputFile)
+	if err != nil {
+		return nil, false, nil
+	}
+	if !info.IsDir() {
+		if opts.ChangedSince == "" {
+			return nil, false, nil
+		}
+		files, err := filterChanged([]string{inputFile}, filepath.Dir(inputFile), opts.ChangedSince)
+		return files, true, err
+	}
+
+	files, err := collectInputs(inputFile)
+	if err != nil {
+		return nil, true, err
+	}
+	if opts.ChangedSince != "" {
+		files, err = filterChanged(files, inputFile, opts.ChangedSince)
+		if err != nil {
+			return nil, true, err
+		}
+	}
+	return files, true, nil
+}
@@ -0,0 +1,131 @@
+package app
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rapjul/panforge/internal/options"
+	"github.com/rapjul/panforge/internal/utils"
+)
+
+// defaultIntermediatesDir is where --keep-intermediates keeps files without a directory.
+const defaultIntermediatesDir = "panforge-intermediates"
+
+// intermediates disposes of the temporary files of a document or target: they are
+// removed, or with --keep-intermediates moved to <dir>/<document>/<target> for debugging.
+type intermediates struct {
+	// dir is where the files are kept, or "" to remove them.
+	dir string
+}
+
+// newIntermediates returns how the temporary files of a document or target are disposed
+// of. Nothing is kept in dry-run mode, which writes no files.
+//
+// Parameters:
+//   - `opts`: runtime options
+//   - `inputFile`: the converted document
+//   - `label`: the target, with its matrix variables ("" for the document's own files)
+func newIntermediates(opts options.Options, inputFile, label string) *intermediates {
+	if opts.KeepIntermediates == "" || opts.DryRun {
+		return &intermediates{}
+	}
+	stem := strings.TrimSuffix(filepath.Base(inputFile), filepath.Ext(inputFile))
+	dir := filepath.Join(opts.KeepIntermediates, stem)
+	if label != "" {
+		dir = filepath.Join(dir, utils.Slugify(label))
+	}
+	return &intermediates{dir: dir}
+}
+
+// keeping reports whether files are kept rather than removed.
+func (k *intermediates) keeping() bool {
+	return k.dir != ""
+}
+
+// path returns where a file that is only written for debugging is kept, creating its
+// directory.
+//
+// Parameters:
+//   - `name`: the file name
+//
+// Returns:
+//   - string: the path
+//   - error: if the directory cannot be created
+func (k *intermediates) path(name string) (string, error) {
+	if err := os.MkdirAll(k.dir, 0750); err != nil {
+		return "", err
+	}
+	return filepath.Join(k.dir, name), nil
+}
+
+// discard removes a temporary file, or moves it into the directory under name. A file
+// that cannot be moved is removed.
+//
+// Parameters:
+//   - `file`: the temporary file
+//   - `name`: the name it is kept under
+func (k *intermediates) discard(file, name string) {
+	if k.keeping() {
+		if dest, err := k.path(name); err == nil {
+			if os.Rename(file, dest) == nil {
+				return
+			}
+			// The directory may be on another file system
+			if copyFileContents(file, dest) == nil {
+				_ = os.Remove(file)
+				return
+			}
+		}
+	}
+	_ = os.Remove(file)
+}
+
+// pdfSourceFormat returns the format pandoc writes before its PDF engine runs, and the
+// extension of that source file.
+//
+// Parameters:
+//   - `fmtStr`: the target format (pdf or beamer)
+//   - `engine`: the target's pdf-engine ("" for pandoc's default)
+func pdfSourceFormat(fmtStr, engine string) (string, string) {
+	switch engine {
+	case "typst":
+		return "typst", ".typ"
+	case "context":
+		return "context", ".tex"
+	case "pdfroff", "groff":
+		return "ms", ".ms"
+	case "wkhtmltopdf", "weasyprint", "prince", "pagedjs-cli":
+		return "html", ".html"
+	}
+	if strings.EqualFold(fmtStr, "beamer") {
+		return "beamer", ".tex"
+	}
+	return "latex", ".tex"
+}
+
+// keepPDFSource writes the source pandoc hands its PDF engine (e.g. the .tex file of a
+// LaTeX build) into the intermediates directory. pandoc never writes it to disk, so it
+// is converted again; a failure only loses the debugging copy.
+//
+// Parameters:
+//   - `ctx`: context for cancellation
+//   - `k`: the target's intermediates
+//   - `pandocArgs`: the target's pandoc arguments
+//   - `fmtStr`: the target format
+//   - `outputFile`: the PDF output
+//   - `executor`: used to run pandoc
+//
+// Returns:
+//   - error: if the source could not be written
+func keepPDFSource(ctx context.Context, k *intermediates, pandocArgs []string, fmtStr, outputFile string, executor CommandExecutor) error {
+	format, ext := pdfSourceFormat(fmtStr, pdfEngine(pandocArgs))
+	stem := strings.TrimSuffix(filepath.Base(outputFile), filepath.Ext(outputFile))
+	dest, err := k.path(stem + ext)
+	if err != nil {
+		return err
+	}
+	return executor.Run(ctx, "pandoc", sourceArgs(pandocArgs, format, dest), io.Discard, io.Discard)
+}
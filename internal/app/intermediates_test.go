@@ -0,0 +1,77 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/options"
+)
+
+func TestIntermediatesDiscard(t *testing.T) {
+	dir := t.TempDir()
+	tmp := filepath.Join(dir, ".panforge-doc.md")
+	_ = os.WriteFile(tmp, []byte("# Doc\n"), 0600)
+
+	keep := newIntermediates(options.Options{KeepIntermediates: filepath.Join(dir, "keep")}, "doc.md", "pdf")
+	keep.discard(tmp, "links.md")
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Error("expected the temporary file to be moved")
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "keep", "doc", "pdf", "links.md")); err != nil || string(data) != "# Doc\n" {
+		t.Errorf("expected the file in keep/doc/pdf, got %q, %v", data, err)
+	}
+
+	_ = os.WriteFile(tmp, []byte("# Doc\n"), 0600)
+	newIntermediates(options.Options{}, "doc.md", "pdf").discard(tmp, "links.md")
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Error("expected the temporary file to be removed without --keep-intermediates")
+	}
+	if newIntermediates(options.Options{KeepIntermediates: "keep", DryRun: true}, "doc.md", "").keeping() {
+		t.Error("expected nothing to be kept in dry-run mode")
+	}
+}
+
+func TestPDFSourceFormat(t *testing.T) {
+	tests := []struct {
+		fmtStr, engine, format, ext string
+	}{
+		{"pdf", "", "latex", ".tex"},
+		{"pdf", "xelatex", "latex", ".tex"},
+		{"beamer", "lualatex", "beamer", ".tex"},
+		{"pdf", "typst", "typst", ".typ"},
+		{"pdf", "weasyprint", "html", ".html"},
+		{"pdf", "context", "context", ".tex"},
+	}
+	for _, tt := range tests {
+		if format, ext := pdfSourceFormat(tt.fmtStr, tt.engine); format != tt.format || ext != tt.ext {
+			t.Errorf("pdfSourceFormat(%q, %q) = %q, %q, want %q, %q", tt.fmtStr, tt.engine, format, ext, tt.format, tt.ext)
+		}
+	}
+}
+
+func TestProcess_KeepIntermediates(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte("---\noutput:\n  pdf:\n    output: doc.pdf\n    pdf-engine: xelatex\n---\n# Doc\n"), 0600)
+
+	rec := &stageRecorder{}
+	keepDir := filepath.Join(dir, "panforge-intermediates")
+	opts := options.Options{Targets: []string{"pdf"}, NoCache: true, Quiet: true, KeepIntermediates: keepDir}
+	if _, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.commands) != 2 {
+		t.Fatalf("expected the PDF and its LaTeX source to be converted, got %v", rec.commands)
+	}
+	source := rec.commands[1]
+	if !slices.Contains(source, "latex") || slices.Contains(source, "--pdf-engine") {
+		t.Errorf("expected the source conversion to write LaTeX, got %v", source)
+	}
+	if _, err := os.Stat(filepath.Join(keepDir, "doc", "pdf", "doc.tex")); err != nil {
+		t.Errorf("expected the .tex source to be kept: %v", err)
+	}
+}
@@ -1,7 +1,15 @@
 package app
 
 import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/rapjul/panforge/internal/config"
 	"github.com/rapjul/panforge/internal/options"
@@ -48,6 +56,18 @@ func TestDetermineTargets(t *testing.T) {
 			cfg:      &config.Config{},
 			expected: []string{"html"},
 		},
+		{
+			name:     "Fallback to --default-to",
+			opts:     options.Options{DefaultTargets: []string{"pdf", "docx"}},
+			cfg:      &config.Config{},
+			expected: []string{"pdf", "docx"},
+		},
+		{
+			name:     "Document outputs win over --default-to",
+			opts:     options.Options{DefaultTargets: []string{"pdf"}},
+			cfg:      &config.Config{Outputs: []interface{}{"epub"}},
+			expected: []string{"epub"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -125,3 +145,366 @@ func TestIsOverwriteAllowed(t *testing.T) {
 		})
 	}
 }
+
+func TestNotifyResult(t *testing.T) {
+	var gotTitle, gotMessage string
+	orig := notifier
+	notifier = func(title, message string) error {
+		gotTitle, gotMessage = title, message
+		return nil
+	}
+	defer func() { notifier = orig }()
+
+	notifyResult(options.Options{}, "doc.md", time.Now(), nil)
+	if gotTitle != "" {
+		t.Errorf("notification sent without --notify: %q", gotTitle)
+	}
+
+	notifyResult(options.Options{Notify: true}, "/tmp/doc.md", time.Now(), nil)
+	if gotTitle != "panforge: build succeeded" || !strings.HasPrefix(gotMessage, "doc.md converted in") {
+		t.Errorf("success notification = %q / %q", gotTitle, gotMessage)
+	}
+
+	notifyResult(options.Options{Notify: true}, "doc.md", time.Now(), errors.New("boom"))
+	if gotTitle != "panforge: build failed" || !strings.HasSuffix(gotMessage, "boom") {
+		t.Errorf("failure notification = %q / %q", gotTitle, gotMessage)
+	}
+}
+
+func TestRunMany_NotifiesOnce(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	var messages []string
+	orig := notifier
+	notifier = func(title, message string) error {
+		messages = append(messages, title+": "+message)
+		return nil
+	}
+	defer func() { notifier = orig }()
+
+	var files []string
+	for _, name := range []string{"a.md", "b.md", "c.md"} {
+		path := filepath.Join(dir, name)
+		_ = os.WriteFile(path, []byte("# "+name+"\n"), 0600)
+		files = append(files, path)
+	}
+	opts := options.Options{Targets: []string{"html"}, NoCache: true, Quiet: true, Force: true, Notify: true}
+	if _, err := runMany(context.Background(), files, nil, opts, &argsRecorder{inputs: map[string]string{}, args: map[string][]string{}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || !strings.HasPrefix(messages[0], "panforge: build succeeded: 3 documents converted in") {
+		t.Errorf("notifications = %q, want one summary", messages)
+	}
+
+	messages = nil
+	if _, err := runMany(context.Background(), files[:2], nil, opts, &partialExecutor{}); err == nil {
+		t.Fatal("expected the run to fail")
+	}
+	if len(messages) != 1 || !strings.HasPrefix(messages[0], "panforge: build failed: 2 of 2 documents failed after") {
+		t.Errorf("notifications = %q, want one summary", messages)
+	}
+}
+
+func TestWriteCriticCopy(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte("Keep {--this--}{++that++}.\n"), 0600)
+
+	copyPath, err := writeCriticCopy(input, "accept", "html", "")
+	if err != nil {
+		t.Fatalf("writeCriticCopy failed: %v", err)
+	}
+	defer func() { _ = os.Remove(copyPath) }()
+	if filepath.Dir(copyPath) != dir {
+		t.Errorf("copy written to %s, want next to input", copyPath)
+	}
+	got, _ := os.ReadFile(copyPath)
+	if string(got) != "Keep that.\n" {
+		t.Errorf("copy content = %q", got)
+	}
+
+	plain := filepath.Join(dir, "plain.md")
+	_ = os.WriteFile(plain, []byte("No markup.\n"), 0600)
+	if copyPath, _ := writeCriticCopy(plain, "accept", "html", ""); copyPath != "" {
+		t.Errorf("expected no copy for input without CriticMarkup, got %s", copyPath)
+	}
+}
+
+func TestCollectInputs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.md", "sub/b.markdown", "notes.txt", ".git/c.md"} {
+		path := filepath.Join(dir, name)
+		_ = os.MkdirAll(filepath.Dir(path), 0750)
+		_ = os.WriteFile(path, []byte("# x"), 0600)
+	}
+
+	files, err := collectInputs(dir)
+	if err != nil {
+		t.Fatalf("collectInputs failed: %v", err)
+	}
+	want := []string{filepath.Join(dir, "a.md"), filepath.Join(dir, "sub", "b.markdown")}
+	if len(files) != len(want) || files[0] != want[0] || files[1] != want[1] {
+		t.Errorf("collectInputs() = %v, want %v", files, want)
+	}
+}
+
+func TestResolveInputs_ChangedSince(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=t", "-c", "user.email=t@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	_ = os.WriteFile(filepath.Join(dir, "a.md"), []byte("a"), 0600)
+	_ = os.WriteFile(filepath.Join(dir, "b.md"), []byte("b"), 0600)
+	git("add", ".")
+	git("commit", "-q", "-m", "init")
+	_ = os.WriteFile(filepath.Join(dir, "b.md"), []byte("changed"), 0600)
+
+	files, multi, err := resolveInputs(dir, options.Options{ChangedSince: "HEAD"})
+	if err != nil {
+		t.Fatalf("resolveInputs failed: %v", err)
+	}
+	if !multi || len(files) != 1 || filepath.Base(files[0]) != "b.md" {
+		t.Errorf("resolveInputs() = %v, %v", files, multi)
+	}
+
+	// A single unchanged file is filtered out
+	files, multi, err = resolveInputs(filepath.Join(dir, "a.md"), options.Options{ChangedSince: "HEAD"})
+	if err != nil || !multi || len(files) != 0 {
+		t.Errorf("resolveInputs(a.md) = %v, %v, %v", files, multi, err)
+	}
+
+	// Without the flag a single file is converted as-is
+	if _, multi, _ := resolveInputs(filepath.Join(dir, "a.md"), options.Options{}); multi {
+		t.Error("expected single-file mode without --changed-since")
+	}
+}
+
+func TestStripSandboxedArgs(t *testing.T) {
+	args := []string{"--toc", "--filter", "pandoc-crossref", "--lua-filter=diagram.lua", "-F", "x", "--standalone"}
+	kept, removed := stripSandboxedArgs(args)
+	if strings.Join(kept, " ") != "--toc --standalone" {
+		t.Errorf("kept = %v", kept)
+	}
+	want := []string{"--filter pandoc-crossref", "--lua-filter=diagram.lua", "-F x"}
+	if strings.Join(removed, "|") != strings.Join(want, "|") {
+		t.Errorf("removed = %v, want %v", removed, want)
+	}
+}
+
+func TestStripSandboxedArgs_EnginesAndDefaults(t *testing.T) {
+	args := []string{
+		"--defaults", "evil.yaml", "-devil.yaml", "-Fpandoc-crossref",
+		"--pdf-engine", "xelatex", "--pdf-engine=./evil.sh", "--pdf-engine", "/usr/bin/xelatex",
+		"--pdf-engine-opt=-shell-escape", "--toc",
+	}
+	kept, removed := stripSandboxedArgs(args)
+	if strings.Join(kept, " ") != "--pdf-engine xelatex --toc" {
+		t.Errorf("kept = %v", kept)
+	}
+	want := []string{"--defaults evil.yaml", "-devil.yaml", "-Fpandoc-crossref", "--pdf-engine=./evil.sh", "--pdf-engine /usr/bin/xelatex", "--pdf-engine-opt=-shell-escape"}
+	if strings.Join(removed, "|") != strings.Join(want, "|") {
+		t.Errorf("removed = %v, want %v", removed, want)
+	}
+}
+
+func TestProcess_SandboxBlocksDefaultsAndEngine(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	_ = os.WriteFile(filepath.Join(dir, "evil.yaml"), []byte("filters: [./evil.lua]\n"), 0600)
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte("---\ntitle: Doc\nsandbox: true\noutput:\n  pdf:\n    defaults: evil.yaml\n    pdf-engine: ./evil.sh\n    pdf-engine-opt: -shell-escape\n---\n# Doc\n"), 0600)
+
+	rec := &envRecorder{}
+	opts := options.Options{NoCache: true, Quiet: true, Force: true}
+	if _, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.args) != 1 {
+		t.Fatalf("expected one pandoc call, got %v", rec.args)
+	}
+	args := strings.Join(rec.args[0], " ")
+	for _, blocked := range []string{"evil.yaml", "evil.sh", "shell-escape"} {
+		if strings.Contains(args, blocked) {
+			t.Errorf("sandbox let %s through: %s", blocked, args)
+		}
+	}
+	if !strings.Contains(args, "--sandbox") {
+		t.Errorf("expected --sandbox, got %s", args)
+	}
+}
+
+func TestIsSandboxed(t *testing.T) {
+	if isSandboxed(map[string]interface{}{"sandbox": false}, nil) {
+		t.Error("expected sandbox off")
+	}
+	if !isSandboxed(map[string]interface{}{"sandbox": false}, map[string]interface{}{"sandbox": true}) {
+		t.Error("expected any layer to enable the sandbox")
+	}
+	if !configSandboxed(&config.Config{Generic: map[string]interface{}{"sandbox": true}}) || configSandboxed(nil) {
+		t.Error("configSandboxed mismatch")
+	}
+}
+
+func TestProcess_ProjectConfig(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	_ = os.MkdirAll(filepath.Join(dir, "chapters"), 0750)
+	_ = os.WriteFile(filepath.Join(dir, config.ProjectFileName), []byte("output:\n  html:\n    toc: true\n"), 0600)
+	one := filepath.Join(dir, "chapters", "one.md")
+	_ = os.WriteFile(one, []byte("---\ntitle: One\noutputs: [html]\n---\n# One\n"), 0600)
+	two := filepath.Join(dir, "chapters", "two.md")
+	_ = os.WriteFile(two, []byte("---\ntitle: Two\noutput:\n  html:\n    standalone: true\n---\n# Two\n"), 0600)
+
+	rec := &envRecorder{}
+	opts := options.Options{NoCache: true, Quiet: true}
+	for _, input := range []string{one, two} {
+		if _, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if args := strings.Join(rec.args[0], " "); !strings.Contains(args, "--toc") {
+		t.Errorf("expected the project config's html options, got %s", args)
+	}
+	if args := strings.Join(rec.args[1], " "); strings.Contains(args, "--toc") || !strings.Contains(args, "--standalone") {
+		t.Errorf("expected the document's html options to win, got %s", args)
+	}
+}
+
+func TestProcess_TargetExtends(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	_ = os.WriteFile(filepath.Join(dir, config.ProjectFileName), []byte("output:\n  pdf:\n    pdf-engine: xelatex\n"), 0600)
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte("---\ntitle: Doc\noutput:\n  draft:\n    extends: pdf\n    variables:\n      watermark: DRAFT\n---\n# Doc\n"), 0600)
+
+	rec := &envRecorder{}
+	opts := options.Options{NoCache: true, Quiet: true, DryRun: true, Targets: []string{"draft"}}
+	if _, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	args := strings.Join(rec.args[0], " ")
+	for _, want := range []string{"--to pdf", "--pdf-engine xelatex", "--variables watermark=DRAFT"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("expected %q in %s", want, args)
+		}
+	}
+	if strings.Contains(args, "extends") {
+		t.Errorf("extends must not reach pandoc: %s", args)
+	}
+}
+
+func TestProcess_FormatDefaults(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	formats := filepath.Join(config.DataDirName(), config.FormatDefaultsDir)
+	_ = os.MkdirAll(formats, 0750)
+	_ = os.WriteFile(filepath.Join(formats, "pdf.yaml"), []byte("pdf-engine: lualatex\ntoc: true\n"), 0600)
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte("---\ntitle: Doc\noutput:\n  pdf:\n    pdf-engine: xelatex\n---\n# Doc\n"), 0600)
+
+	rec := &envRecorder{}
+	opts := options.Options{NoCache: true, Quiet: true, DryRun: true, Targets: []string{"pdf"}}
+	if _, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	args := strings.Join(rec.args[0], " ")
+	if !strings.Contains(args, "--toc") || !strings.Contains(args, "--pdf-engine xelatex") || strings.Contains(args, "lualatex") {
+		t.Errorf("expected the pdf defaults beneath the document's options, got %s", args)
+	}
+}
+
+func TestProcess_FormatDefaultsKeepOutputNames(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte("---\ntitle: Doc\n---\n# Doc\n"), 0600)
+
+	output := func() string {
+		rec := &envRecorder{}
+		opts := options.Options{NoCache: true, Quiet: true, DryRun: true, Force: true, Targets: []string{"html"}}
+		if _, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir}); err != nil {
+			t.Fatal(err)
+		}
+		return rec.args[0][slices.Index(rec.args[0], "--output")+1]
+	}
+	without := output()
+	formats := filepath.Join(config.DataDirName(), config.FormatDefaultsDir)
+	_ = os.MkdirAll(formats, 0750)
+	_ = os.WriteFile(filepath.Join(formats, "html.yaml"), []byte("toc: true\n"), 0600)
+	if with := output(); with != without {
+		t.Errorf("format defaults must not change the output name: %s, was %s", with, without)
+	}
+
+	_ = os.WriteFile(filepath.Join(formats, "html.yaml"), []byte("toc: [\n"), 0600)
+	opts := options.Options{NoCache: true, Quiet: true, DryRun: true, Force: true, Targets: []string{"html"}}
+	if _, err := process(context.Background(), input, nil, opts, &envRecorder{}, processEnv{baseDir: dir}); ExitCode(err) != ExitConfig {
+		t.Errorf("expected a configuration error for an invalid options file, got %v", err)
+	}
+}
+
+func TestBuildCacheKey(t *testing.T) {
+	dir := t.TempDir()
+	var keys []string
+	for _, tree := range []string{"main", "feature"} {
+		root := filepath.Join(dir, tree)
+		_ = os.MkdirAll(root, 0750)
+		input := filepath.Join(root, "doc.md")
+		css := filepath.Join(root, "style.css")
+		_ = os.WriteFile(input, []byte("# Doc\n"), 0600)
+		_ = os.WriteFile(css, []byte("body {}"), 0600)
+		args := []string{"--to", "html", "--output", filepath.Join(root, "doc.html"), "--css", css, "--extract-media", filepath.Join(root, "doc-media")}
+		key, err := buildCacheKey(input, args, "", "pandoc 3.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+	if keys[0] != keys[1] {
+		t.Error("the same document in two directories should have the same key")
+	}
+
+	// Editing a referenced file changes the key
+	_ = os.WriteFile(filepath.Join(dir, "feature", "style.css"), []byte("body { color: red }"), 0600)
+	args := []string{"--to", "html", "--output", filepath.Join(dir, "feature", "doc.html"), "--css=" + filepath.Join(dir, "feature", "style.css"), "--extract-media", filepath.Join(dir, "feature", "doc-media")}
+	if key, _ := buildCacheKey(filepath.Join(dir, "feature", "doc.md"), args, "", "pandoc 3.1"); key == keys[1] {
+		t.Error("expected an edited stylesheet to change the key")
+	}
+}
+
+func TestProcess_CacheTracksReferencedFiles(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	t.Chdir(dir)
+	template := filepath.Join(dir, "page.html")
+	_ = os.WriteFile(template, []byte("$body$"), 0600)
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte("---\ntitle: Doc\noutput:\n  html:\n    output: doc.html\n    template: page.html\n---\n# Doc\n"), 0600)
+
+	opts := options.Options{Force: true, Quiet: true}
+	runs := 0
+	build := func() {
+		t.Helper()
+		rec := &argsRecorder{inputs: map[string]string{}, args: map[string][]string{}}
+		if _, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir}); err != nil {
+			t.Fatal(err)
+		}
+		runs += len(rec.args)
+	}
+	build()
+	build()
+	if runs != 1 {
+		t.Fatalf("expected the second run to be up to date, pandoc ran %d times", runs)
+	}
+	_ = os.WriteFile(template, []byte("<main>$body$</main>"), 0600)
+	build()
+	if runs != 2 {
+		t.Errorf("expected an edited template to rebuild, pandoc ran %d times", runs)
+	}
+}
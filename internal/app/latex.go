@@ -0,0 +1,263 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+// latexEngines are the TeX engines `latex-passes` can run; latexmk and tectonic run
+// their own passes.
+var latexEngines = map[string]bool{
+	"pdflatex": true,
+	"xelatex":  true,
+	"lualatex": true,
+}
+
+// Bibliography processors of `latex-passes`.
+const (
+	bibAuto   = "auto"
+	bibBiber  = "biber"
+	bibBibTeX = "bibtex"
+	bibNone   = "none"
+)
+
+// defaultLatexPasses is how many times the engine runs by default: once to collect
+// citations and labels, and twice more so the bibliography and references settle.
+const defaultLatexPasses = 3
+
+// latexAuxExts are the files an engine and its helpers leave next to the .tex output.
+var latexAuxExts = []string{
+	".aux", ".log", ".out", ".toc", ".lof", ".lot", ".bbl", ".blg", ".bcf", ".run.xml",
+	".idx", ".ind", ".ilg", ".nav", ".snm", ".vrb",
+}
+
+// latexPassesConfig holds the resolved `latex-passes` settings of a target.
+type latexPassesConfig struct {
+	// Engine is the TeX engine, or "" for the target's pdf-engine (pdflatex by default).
+	Engine string
+	// Passes is how many times the engine runs.
+	Passes int
+	// Bibliography is bibAuto, bibBiber, bibBibTeX, or bibNone.
+	Bibliography string
+	// Index runs makeindex: "auto" when the engine wrote an index, "true", or "false".
+	Index string
+}
+
+// resolveLatexPasses reads the `latex-passes` setting of a target: true, the number of
+// engine runs, or a map with `engine`, `passes`, `bibliography` (auto, biber, bibtex,
+// or none), and `index` (auto, true, or false) keys. The target value wins over the
+// global one.
+//
+// Parameters:
+//   - `cfg`: the global config
+//   - `metaOut`: the format-specific config
+//
+// Returns:
+//   - *latexPassesConfig: the settings, or nil if the .tex output is left as is
+//   - error: if the value is invalid
+func resolveLatexPasses(cfg *config.Config, metaOut map[string]interface{}) (*latexPassesConfig, error) {
+	raw, ok := metaOut["latex-passes"]
+	if !ok {
+		raw = cfg.Generic["latex-passes"]
+	}
+	lp := &latexPassesConfig{Passes: defaultLatexPasses, Bibliography: bibAuto, Index: "auto"}
+	switch v := raw.(type) {
+	case nil:
+		return nil, nil
+	case bool:
+		if !v {
+			return nil, nil
+		}
+	case int:
+		lp.Passes = v
+	case map[string]interface{}:
+		if engine, ok := v["engine"]; ok {
+			s, ok := engine.(string)
+			if !ok {
+				return nil, fmt.Errorf("latex-passes: engine must be a string")
+			}
+			lp.Engine = s
+		}
+		if passes, ok := v["passes"]; ok {
+			n, ok := passes.(int)
+			if !ok {
+				return nil, fmt.Errorf("latex-passes: passes must be a number")
+			}
+			lp.Passes = n
+		}
+		if bib, ok := v["bibliography"]; ok {
+			s, _ := bib.(string)
+			switch s {
+			case bibAuto, bibBiber, bibBibTeX, bibNone:
+				lp.Bibliography = s
+			default:
+				return nil, fmt.Errorf("latex-passes: unknown bibliography %v (use auto, biber, bibtex, or none)", bib)
+			}
+		}
+		switch index := v["index"].(type) {
+		case nil:
+		case bool:
+			lp.Index = fmt.Sprint(index)
+		case string:
+			if index != "auto" {
+				return nil, fmt.Errorf("latex-passes: index must be auto, true, or false")
+			}
+		default:
+			return nil, fmt.Errorf("latex-passes: index must be auto, true, or false")
+		}
+	default:
+		return nil, fmt.Errorf("latex-passes must be true, a number of passes, or a map")
+	}
+	if lp.Passes < 1 {
+		return nil, fmt.Errorf("latex-passes: passes must be at least 1")
+	}
+	if lp.Engine != "" && !latexEngines[lp.Engine] {
+		return nil, fmt.Errorf("latex-passes: unsupported engine %q (use pdflatex, xelatex, or lualatex)", lp.Engine)
+	}
+	return lp, nil
+}
+
+// latexPassesEngine picks the engine for a target: the `latex-passes` engine, else the
+// target's pdf-engine if it is one latex-passes can run, else pdflatex.
+//
+// Parameters:
+//   - `lp`: the latex-passes settings
+//   - `pandocArgs`: the target's pandoc arguments
+func latexPassesEngine(lp *latexPassesConfig, pandocArgs []string) string {
+	if lp.Engine != "" {
+		return lp.Engine
+	}
+	if engine := pdfEngine(pandocArgs); latexEngines[engine] {
+		return engine
+	}
+	return "pdflatex"
+}
+
+// dirRunner is implemented by executors that can run a command in a given directory.
+// BibTeX and makeindex refuse to write outside their working directory by default, so
+// the passes run where the .tex file is.
+// It also adds env to the command's environment.
+type dirRunner interface {
+	RunIn(ctx context.Context, dir string, env []string, name string, args []string, stdout, stderr io.Writer) error
+}
+
+// runInDir runs a command in dir, with env added, if the executor supports it.
+func runInDir(ctx context.Context, executor CommandExecutor, dir string, env []string, name string, args []string, stdout, stderr io.Writer) error {
+	if r, ok := executor.(dirRunner); ok {
+		return r.RunIn(ctx, dir, env, name, args, stdout, stderr)
+	}
+	return executor.Run(ctx, name, args, stdout, stderr)
+}
+
+// runLatexPasses builds a PDF next to a .tex output: the engine runs several times, with
+// biber or bibtex after the first run and makeindex when the document has an index, so
+// citations, cross-references, and the index resolve. The auxiliary files the passes
+// create are removed afterwards, or kept with --keep-intermediates.
+//
+// Parameters:
+//   - `ctx`: context for cancellation
+//   - `lp`: the latex-passes settings
+//   - `engine`: the TeX engine
+//   - `texFile`: the .tex output
+//   - `opts`: runtime options
+//   - `executor`: used to run the tools
+//   - `keep`: disposes of the auxiliary files
+//   - `stdout`: where the tools' output goes
+//   - `stderr`: where the tools' errors go
+//
+// Returns:
+//   - string: the PDF written
+//   - error: if a tool failed
+func runLatexPasses(ctx context.Context, lp *latexPassesConfig, engine, texFile string, opts options.Options, executor CommandExecutor, keep *intermediates, stdout, stderr io.Writer) (string, error) {
+	dir := filepath.Dir(texFile)
+	stem := strings.TrimSuffix(filepath.Base(texFile), filepath.Ext(texFile))
+	aux := func(ext string) string { return filepath.Join(dir, stem+ext) }
+
+	// Remember which auxiliary files were already there, so only new ones are cleaned up
+	existing := map[string]bool{}
+	for _, ext := range latexAuxExts {
+		if _, err := os.Stat(aux(ext)); err == nil {
+			existing[ext] = true
+		}
+	}
+	if !opts.DryRun {
+		defer func() {
+			for _, ext := range latexAuxExts {
+				if _, err := os.Stat(aux(ext)); err == nil && !existing[ext] {
+					keep.discard(aux(ext), stem+ext)
+				}
+			}
+		}()
+	}
+
+	run := func(name string, args ...string) error {
+		echoCommand(opts, formatCommand(name, args)+" (in "+dir+")")
+		if opts.DryRun {
+			return nil
+		}
+		if err := runInDir(ctx, executor, dir, nil, name, args, stdout, stderr); err != nil {
+			return fmt.Errorf("latex-passes: %s failed: %w", name, err)
+		}
+		return nil
+	}
+	engineArgs := []string{"-interaction=nonstopmode", "-halt-on-error", stem + ".tex"}
+
+	if err := run(engine, engineArgs...); err != nil {
+		return "", err
+	}
+	bib := lp.Bibliography
+	if bib == bibAuto {
+		bib = detectBibliography(aux(".bcf"), aux(".aux"))
+	}
+	switch bib {
+	case bibBiber:
+		if err := run("biber", stem); err != nil {
+			return "", err
+		}
+	case bibBibTeX:
+		if err := run("bibtex", stem); err != nil {
+			return "", err
+		}
+	}
+	_, idxErr := os.Stat(aux(".idx"))
+	if lp.Index == "true" || (lp.Index == "auto" && idxErr == nil) {
+		if err := run("makeindex", stem+".idx"); err != nil {
+			return "", err
+		}
+	}
+	for i := 1; i < lp.Passes; i++ {
+		if err := run(engine, engineArgs...); err != nil {
+			return "", err
+		}
+	}
+	return aux(".pdf"), nil
+}
+
+// detectBibliography tells from the first engine run which bibliography processor the
+// document needs: biblatex writes a .bcf control file for biber, and \bibliography
+// writes \bibdata into the .aux file for bibtex.
+//
+// Parameters:
+//   - `bcfFile`: the biblatex control file
+//   - `auxFile`: the .aux file
+//
+// Returns:
+//   - string: bibBiber, bibBibTeX, or bibNone
+func detectBibliography(bcfFile, auxFile string) string {
+	if _, err := os.Stat(bcfFile); err == nil {
+		return bibBiber
+	}
+	//nolint:gosec // G304: the engine's own .aux file
+	if data, err := os.ReadFile(auxFile); err == nil && bytes.Contains(data, []byte(`\bibdata`)) {
+		return bibBibTeX
+	}
+	return bibNone
+}
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+// texRunner fakes pandoc and a TeX toolchain: the engine writes an .aux file that asks
+// for bibtex, an index, and the PDF, in the directory it runs in.
+type texRunner struct {
+	commands []string
+	dirs     []string
+}
+
+func (r *texRunner) Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	r.commands = append(r.commands, name+" "+strings.Join(args, " "))
+	for i, arg := range args {
+		if arg == "--output" && i+1 < len(args) {
+			return os.WriteFile(args[i+1], []byte("\\documentclass{article}"), 0600)
+		}
+	}
+	return nil
+}
+
+func (r *texRunner) RunIn(ctx context.Context, dir string, env []string, name string, args []string, stdout, stderr io.Writer) error {
+	r.dirs = append(r.dirs, dir)
+	r.commands = append(r.commands, name+" "+strings.Join(args, " "))
+	if name == "pdflatex" {
+		stem := strings.TrimSuffix(args[len(args)-1], ".tex")
+		_ = os.WriteFile(filepath.Join(dir, stem+".aux"), []byte("\\bibdata{refs}\n"), 0600)
+		_ = os.WriteFile(filepath.Join(dir, stem+".idx"), nil, 0600)
+		_ = os.WriteFile(filepath.Join(dir, stem+".log"), []byte("This is pdfTeX"), 0600)
+		return os.WriteFile(filepath.Join(dir, stem+".pdf"), []byte("pdf"), 0600)
+	}
+	return nil
+}
+
+func TestResolveLatexPasses(t *testing.T) {
+	cfg := &config.Config{Generic: map[string]interface{}{}}
+	if lp, err := resolveLatexPasses(cfg, map[string]interface{}{}); lp != nil || err != nil {
+		t.Errorf("expected no passes without a setting, got %+v, %v", lp, err)
+	}
+	lp, err := resolveLatexPasses(cfg, map[string]interface{}{"latex-passes": map[string]interface{}{
+		"engine": "xelatex", "passes": 4, "bibliography": "biber", "index": false,
+	}})
+	want := &latexPassesConfig{Engine: "xelatex", Passes: 4, Bibliography: bibBiber, Index: "false"}
+	if err != nil || !reflect.DeepEqual(lp, want) {
+		t.Errorf("resolveLatexPasses() = %+v, %v, want %+v", lp, err, want)
+	}
+	for _, bad := range []interface{}{"yes", 0, map[string]interface{}{"engine": "tectonic"}, map[string]interface{}{"bibliography": "natbib"}} {
+		if _, err := resolveLatexPasses(cfg, map[string]interface{}{"latex-passes": bad}); err == nil {
+			t.Errorf("expected an error for %v", bad)
+		}
+	}
+}
+
+func TestRunLatexPasses(t *testing.T) {
+	dir := t.TempDir()
+	tex := filepath.Join(dir, "doc.tex")
+	_ = os.WriteFile(tex, nil, 0600)
+	// A file the passes did not create is kept
+	_ = os.WriteFile(filepath.Join(dir, "doc.toc"), []byte("mine"), 0600)
+
+	rec := &texRunner{}
+	lp := &latexPassesConfig{Passes: 3, Bibliography: bibAuto, Index: "auto"}
+	pdf, err := runLatexPasses(context.Background(), lp, "pdflatex", tex, options.Options{Quiet: true}, rec, &intermediates{}, io.Discard, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := "pdflatex -interaction=nonstopmode -halt-on-error doc.tex"
+	want := []string{engine, "bibtex doc", "makeindex doc.idx", engine, engine}
+	if !reflect.DeepEqual(rec.commands, want) {
+		t.Errorf("commands = %q, want %q", rec.commands, want)
+	}
+	if rec.dirs[0] != dir {
+		t.Errorf("expected the passes to run in %s, got %s", dir, rec.dirs[0])
+	}
+	if pdf != filepath.Join(dir, "doc.pdf") {
+		t.Errorf("unexpected PDF %s", pdf)
+	}
+	for _, ext := range []string{".aux", ".idx", ".log"} {
+		if _, err := os.Stat(filepath.Join(dir, "doc"+ext)); !os.IsNotExist(err) {
+			t.Errorf("expected doc%s to be removed", ext)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "doc.toc")); err != nil {
+		t.Error("expected the existing doc.toc to be kept")
+	}
+}
+
+func TestProcess_LatexPasses(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte("---\noutput:\n  latex:\n    output: doc.tex\n    latex-passes: 2\n---\n# Doc\n"), 0600)
+
+	rec := &texRunner{}
+	opts := options.Options{Targets: []string{"latex"}, Quiet: true}
+	if _, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.commands) != 5 || !strings.HasPrefix(rec.commands[0], "pandoc ") || !slices.Contains(strings.Fields(rec.commands[0]), "--standalone") {
+		t.Fatalf("expected pandoc with --standalone, then the passes, got %q", rec.commands)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "doc.pdf")); err != nil {
+		t.Error("expected the PDF next to the .tex output")
+	}
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+	"github.com/rapjul/panforge/internal/pandoc"
+)
+
+// lintOptions reports targets that are neither named in the config nor pandoc formats,
+// such as a misspelled `-t blgo`, and target options that are not pandoc options, such as
+// a misspelled `tocc`, which pandoc would otherwise reject with a bare usage error. They
+// are warnings, or a configuration error with --strict.
+//
+// Parameters:
+//   - `cfg`: the merged configuration
+//   - `targets`: the targets of the run
+//   - `opts`: runtime options (Strict)
+//   - `w`: where warnings are written
+//
+// Returns:
+//   - error: with --strict, the unknown options
+func lintOptions(cfg *config.Config, targets []string, opts options.Options, w io.Writer) error {
+	var problems []string
+	for _, t := range targets {
+		if problem := unknownTarget(cfg, t); problem != "" {
+			problems = append(problems, problem)
+			continue
+		}
+		_, metaOut := resolveTarget(cfg, t)
+		for _, u := range pandoc.UnknownOptions(metaOut) {
+			problems = append(problems, fmt.Sprintf("target %s: %s", t, u))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	if opts.Strict {
+		return configError(errors.New(strings.Join(problems, "; ")))
+	}
+	for _, p := range problems {
+		_, _ = fmt.Fprintf(w, "Warning: %s\n", p)
+	}
+	return nil
+}
+
+// unknownTarget describes a target that is neither a named target of the config nor a
+// pandoc format, with the closest of them when one is close, or returns "". Names with
+// a dot or a path separator are custom Lua writers, which pandoc resolves.
+func unknownTarget(cfg *config.Config, t string) string {
+	if _, ok := cfg.OutputMap[t]; ok {
+		return ""
+	}
+	if _, ok := cfg.Generic[t].(map[string]interface{}); ok {
+		return ""
+	}
+	if strings.ContainsAny(t, "./\\") || pandoc.IsOutputFormat(t) {
+		return ""
+	}
+	problem := fmt.Sprintf("target %s is neither a target of the config nor a pandoc format", t)
+	candidates := append(configuredTargets(cfg), pandoc.BuiltinOutputFormats...)
+	if suggestion := pandoc.Closest(t, candidates); suggestion != "" {
+		problem += fmt.Sprintf(" (did you mean %s?)", suggestion)
+	}
+	return problem
+}
+
+// configuredTargets returns the names of the targets of the output map, sorted.
+func configuredTargets(cfg *config.Config) []string {
+	targets := make([]string, 0, len(cfg.OutputMap))
+	for name := range cfg.OutputMap {
+		targets = append(targets, name)
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// ConfiguredTargets returns the named targets a conversion of inputFile could build with
+// `-t`: those of the document, the project config, and the default config. Shell
+// completion offers them before pandoc's formats.
+//
+// Parameters:
+//   - `inputFile`: the document, or "" for the configs that apply in the current directory
+//   - `opts`: runtime options (Config)
+func ConfiguredTargets(inputFile string, opts options.Options) []string {
+	cfg := &config.Config{}
+	dir := "."
+	if inputFile != "" {
+		if docCfg, err := loadDocumentConfig(inputFile, ""); err == nil {
+			cfg = docCfg
+		}
+		dir = filepath.Dir(inputFile)
+	}
+	if project := config.FindProjectConfig(dir); project != "" {
+		_, projectCfg, _ := config.LoadConfig(project)
+		mergeConfig(cfg, projectCfg)
+	}
+	_, defaultCfg, _ := loadDefaultConfig(opts.Config)
+	mergeConfig(cfg, defaultCfg)
+	return configuredTargets(cfg)
+}
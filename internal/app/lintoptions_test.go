@@ -0,0 +1,85 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+func TestLintOptions(t *testing.T) {
+	cfg := &config.Config{OutputMap: map[string]interface{}{
+		"html": map[string]interface{}{"tocc": true, "standalone": true},
+		"pdf":  map[string]interface{}{"pdf-engine": "xelatex"},
+	}}
+	var out bytes.Buffer
+	if err := lintOptions(cfg, []string{"html", "pdf"}, options.Options{}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "Warning: target html: unknown pandoc option tocc (did you mean toc?)\n" {
+		t.Errorf("unexpected warnings %q", got)
+	}
+}
+
+func TestProcess_StrictUnknownOption(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte("---\noutput:\n  html:\n    tocc: true\n---\n# Doc\n"), 0600)
+
+	rec := &envRecorder{}
+	opts := options.Options{NoCache: true, Quiet: true, Strict: true}
+	_, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir})
+	if ExitCode(err) != ExitConfig || !strings.Contains(err.Error(), "did you mean toc?") {
+		t.Fatalf("expected a configuration error with a suggestion, got %v", err)
+	}
+	if len(rec.args) != 0 {
+		t.Errorf("expected pandoc not to run, got %v", rec.args)
+	}
+}
+
+func TestLintOptions_UnknownTarget(t *testing.T) {
+	cfg := &config.Config{OutputMap: map[string]interface{}{
+		"blog": map[string]interface{}{"to": "html"},
+	}}
+	var out bytes.Buffer
+	if err := lintOptions(cfg, []string{"blog", "html+smart", "writer.lua", "blogg"}, options.Options{}, &out); err != nil {
+		t.Fatal(err)
+	}
+	want := "Warning: target blogg is neither a target of the config nor a pandoc format (did you mean blog?)\n"
+	if out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
+
+func TestProcess_NamedTargets(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	_ = os.MkdirAll(config.DataDirName(), 0750)
+	_ = os.WriteFile(filepath.Join(config.DataDirName(), "default.yaml"), []byte("output:\n  print:\n    to: pdf\n    pdf-engine: xelatex\n"), 0600)
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte("---\ntitle: Doc\noutput:\n  blog:\n    to: html\n    template: blog.html\n---\n# Doc\n"), 0600)
+
+	for target, want := range map[string]string{
+		"blog":  "--to html --output " + filepath.Join(dir, "Doc.html") + " --template blog.html",
+		"print": "--to pdf --output " + filepath.Join(dir, "Doc.pdf") + " --pdf-engine xelatex",
+	} {
+		rec := &envRecorder{}
+		opts := options.Options{NoCache: true, Quiet: true, DryRun: true, Targets: []string{target}}
+		if _, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir}); err != nil {
+			t.Fatal(err)
+		}
+		if args := strings.Join(rec.args[0], " "); !strings.Contains(args, want) {
+			t.Errorf("-t %s: expected %q in %s", target, want, args)
+		}
+	}
+
+	if got := ConfiguredTargets(input, options.Options{}); strings.Join(got, ",") != "blog,print" {
+		t.Errorf("expected the document's and the default config's targets, got %v", got)
+	}
+}
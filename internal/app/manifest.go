@@ -0,0 +1,149 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+	"github.com/rapjul/panforge/internal/pandoc"
+)
+
+// defaultManifestName is written when --manifest or `manifest: true` gives no path.
+const defaultManifestName = "panforge-manifest.json"
+
+// Manifest describes the outputs of a run, for downstream verification and clean-up tools.
+type Manifest struct {
+	// Generated is when the manifest was written.
+	Generated time.Time `json:"generated"`
+	// PandocVersion is the first line of `pandoc --version`.
+	PandocVersion string `json:"pandoc_version,omitempty"`
+	// Duration is how long the run took.
+	Duration time.Duration `json:"duration_ns"`
+	// Outputs lists every target of the run.
+	Outputs []ManifestEntry `json:"outputs"`
+}
+
+// ManifestEntry describes one target's output.
+type ManifestEntry struct {
+	// Target is the target name.
+	Target string `json:"target"`
+	// Format is the resolved pandoc output format.
+	Format string `json:"format,omitempty"`
+	// Path is the absolute path of the output file.
+	Path string `json:"path"`
+	// Status is the target's result status.
+	Status string `json:"status"`
+	// Size is the output size in bytes (0 if it does not exist).
+	Size int64 `json:"size"`
+	// SHA256 is the hex checksum of the output ("" if it does not exist).
+	SHA256 string `json:"sha256,omitempty"`
+	// Duration is how long the target took.
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// manifestSetting returns the manifest a run should write: --manifest, else the
+// document's `manifest` key (true or a path) resolved against baseDir, else "".
+//
+// Parameters:
+//   - `opts`: runtime options
+//   - `cfg`: the document config
+//   - `baseDir`: the directory relative paths are resolved against
+func manifestSetting(opts options.Options, cfg *config.Config, baseDir string) string {
+	if opts.Manifest != "" {
+		return opts.Manifest
+	}
+	var path string
+	switch v := cfg.Generic["manifest"].(type) {
+	case bool:
+		if v {
+			path = defaultManifestName
+		}
+	case string:
+		path = v
+	}
+	if path == "" {
+		return ""
+	}
+	resolved, err := resolveIn(baseDir, path)
+	if err != nil {
+		return path
+	}
+	return resolved
+}
+
+// buildManifest describes the outputs of a run, hashing every output that exists.
+//
+// Parameters:
+//   - `results`: the per-target results
+//   - `elapsed`: the duration of the run
+func buildManifest(results []TargetResult, elapsed time.Duration) Manifest {
+	m := Manifest{Generated: time.Now().UTC(), Duration: elapsed, Outputs: []ManifestEntry{}}
+	m.PandocVersion, _, _ = pandoc.InstalledVersion()
+	for _, res := range results {
+		if res.Output == "" {
+			continue
+		}
+		entry := ManifestEntry{Target: res.Target, Format: res.Format, Path: res.Output, Status: res.Status, Duration: res.Duration}
+		if sum, size, err := fileSHA256(res.Output); err == nil {
+			entry.SHA256, entry.Size = sum, size
+		}
+		m.Outputs = append(m.Outputs, entry)
+	}
+	sort.Slice(m.Outputs, func(i, j int) bool { return m.Outputs[i].Path < m.Outputs[j].Path })
+	return m
+}
+
+// finishManifest writes the manifest of a run. It is skipped in dry-run mode; failed
+// targets are listed with their status so tools can tell what is missing.
+//
+// Parameters:
+//   - `path`: the manifest file
+//   - `results`: the per-target results
+//   - `elapsed`: the duration of the run
+//   - `opts`: runtime options
+//
+// Returns:
+//   - error: if the manifest could not be written
+func finishManifest(path string, results []TargetResult, elapsed time.Duration, opts options.Options) error {
+	if opts.DryRun {
+		if opts.Logger != nil {
+			opts.Logger.Info("skipping manifest in dry-run mode", "manifest", path)
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(buildManifest(results, elapsed), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	//nolint:gosec // G306: the manifest is meant to be read by other tools
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if opts.Logger != nil {
+		opts.Logger.Debug("wrote manifest", "manifest", path)
+	}
+	return nil
+}
+
+// fileSHA256 returns the hex SHA-256 checksum and size of a file.
+func fileSHA256(path string) (string, int64, error) {
+	//nolint:gosec // G304: hashing generated outputs is intended
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() { _ = f.Close() }()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+func TestManifestSetting(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  options.Options
+		value interface{}
+		want  string
+	}{
+		{"unset", options.Options{}, nil, ""},
+		{"true", options.Options{}, true, filepath.Join("/work", defaultManifestName)},
+		{"path", options.Options{}, "dist/manifest.json", filepath.Join("/work", "dist", "manifest.json")},
+		{"flag wins", options.Options{Manifest: "m.json"}, true, "m.json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Generic: map[string]interface{}{}}
+			if tt.value != nil {
+				cfg.Generic["manifest"] = tt.value
+			}
+			if got := manifestSetting(tt.opts, cfg, "/work"); got != tt.want {
+				t.Errorf("manifestSetting = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcess_Manifest(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte("---\nmanifest: true\noutput:\n  html:\n    output: doc.html\n---\n# Doc\n"), 0600)
+
+	rec := &argsRecorder{inputs: map[string]string{}, args: map[string][]string{}}
+	opts := options.Options{Targets: []string{"html"}, NoCache: true, Quiet: true}
+	if _, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir}); err != nil {
+		t.Fatalf("process failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, defaultManifestName))
+	if err != nil {
+		t.Fatalf("manifest not written: %v", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Outputs) != 1 {
+		t.Fatalf("expected one output, got %+v", m.Outputs)
+	}
+	sum := sha256.Sum256([]byte("converted"))
+	e := m.Outputs[0]
+	if e.Target != "html" || e.Path != filepath.Join(dir, "doc.html") || e.Size != int64(len("converted")) || e.SHA256 != hex.EncodeToString(sum[:]) || e.Status != StatusSuccess {
+		t.Errorf("unexpected manifest entry: %+v", e)
+	}
+}
@@ -0,0 +1,274 @@
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+// matrixDim is one dimension of a build matrix, e.g. profile: [draft, final].
+type matrixDim struct {
+	Name   string
+	Values []matrixValue
+}
+
+// matrixValue is one value of a dimension, with the options it adds to every target.
+type matrixValue struct {
+	Value   string
+	Options map[string]interface{}
+}
+
+// matrixVar is the value a matrix cell takes for one dimension.
+type matrixVar struct {
+	Name    string
+	Value   string
+	Options map[string]interface{}
+}
+
+// matrixCell is one build of a target: the target with a value for every matrix dimension.
+// Without a matrix every target is a single cell with no variables.
+type matrixCell struct {
+	Target string
+	Vars   []matrixVar
+}
+
+// resolveMatrix returns the dimensions of the build matrix: the document's `matrix` block,
+// with dimensions given by --matrix name=a,b replacing those of the same name.
+// Dimensions are ordered by name.
+//
+// Parameters:
+//   - `opts`: runtime options
+//   - `cfg`: the document config
+//
+// Returns:
+//   - []matrixDim: the dimensions (nil without a matrix)
+//   - error: if the matrix is malformed
+func resolveMatrix(opts options.Options, cfg *config.Config) ([]matrixDim, error) {
+	dims := map[string]matrixDim{}
+	if raw, ok := cfg.Generic["matrix"]; ok && raw != nil {
+		block, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("matrix must be a map of dimension names to values")
+		}
+		for name, v := range block {
+			dim, err := parseMatrixDim(name, v)
+			if err != nil {
+				return nil, err
+			}
+			dims[name] = dim
+		}
+	}
+	for _, spec := range opts.Matrix {
+		name, list, ok := strings.Cut(spec, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid --matrix %q, expected name=value1,value2", spec)
+		}
+		dim := matrixDim{Name: name}
+		for _, value := range strings.Split(list, ",") {
+			if value = strings.TrimSpace(value); value == "" {
+				continue
+			}
+			// Keep the options the config defines for the value
+			mv := matrixValue{Value: value}
+			for _, existing := range dims[name].Values {
+				if existing.Value == value {
+					mv.Options = existing.Options
+				}
+			}
+			dim.Values = append(dim.Values, mv)
+		}
+		if len(dim.Values) == 0 {
+			return nil, fmt.Errorf("--matrix %s has no values", name)
+		}
+		dims[name] = dim
+	}
+
+	names := make([]string, 0, len(dims))
+	for name := range dims {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var out []matrixDim
+	for _, name := range names {
+		out = append(out, dims[name])
+	}
+	return out, nil
+}
+
+// parseMatrixDim parses one dimension of the `matrix` block: a list of values, or a map
+// of values to the target options each one adds.
+func parseMatrixDim(name string, v interface{}) (matrixDim, error) {
+	dim := matrixDim{Name: name}
+	switch vals := v.(type) {
+	case []interface{}:
+		for _, item := range vals {
+			dim.Values = append(dim.Values, matrixValue{Value: fmt.Sprint(item)})
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(vals))
+		for k := range vals {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			mv := matrixValue{Value: k}
+			if vals[k] != nil {
+				opts, ok := vals[k].(map[string]interface{})
+				if !ok {
+					return dim, fmt.Errorf("matrix %s.%s must be a map of options", name, k)
+				}
+				mv.Options = opts
+			}
+			dim.Values = append(dim.Values, mv)
+		}
+	default:
+		return dim, fmt.Errorf("matrix %s must be a list of values or a map of values to options", name)
+	}
+	if len(dim.Values) == 0 {
+		return dim, fmt.Errorf("matrix %s has no values", name)
+	}
+	return dim, nil
+}
+
+// matrixCells returns the cartesian product of the targets and every matrix dimension.
+//
+// Parameters:
+//   - `targets`: the targets to build
+//   - `dims`: the matrix dimensions
+func matrixCells(targets []string, dims []matrixDim) []matrixCell {
+	combos := [][]matrixVar{nil}
+	for _, dim := range dims {
+		var next [][]matrixVar
+		for _, combo := range combos {
+			for _, v := range dim.Values {
+				vars := append(append([]matrixVar(nil), combo...), matrixVar{Name: dim.Name, Value: v.Value, Options: v.Options})
+				next = append(next, vars)
+			}
+		}
+		combos = next
+	}
+	var cells []matrixCell
+	for _, t := range targets {
+		for _, combo := range combos {
+			cells = append(cells, matrixCell{Target: t, Vars: combo})
+		}
+	}
+	return cells
+}
+
+// label names the cell in messages and results, e.g. pdf[profile=draft].
+func (c matrixCell) label() string {
+	if len(c.Vars) == 0 {
+		return c.Target
+	}
+	parts := make([]string, len(c.Vars))
+	for i, v := range c.Vars {
+		parts[i] = v.Name + "=" + v.Value
+	}
+	return c.Target + "[" + strings.Join(parts, ",") + "]"
+}
+
+// expand replaces the {name} token of every matrix dimension in s.
+func (c matrixCell) expand(s string) string {
+	for _, v := range c.Vars {
+		s = strings.ReplaceAll(s, "{"+v.Name+"}", v.Value)
+	}
+	return s
+}
+
+// tokenized reports whether s names every dimension, so outputs of different cells differ.
+func (c matrixCell) tokenized(s string) bool {
+	for _, v := range c.Vars {
+		if !strings.Contains(s, "{"+v.Name+"}") {
+			return false
+		}
+	}
+	return true
+}
+
+// options returns a copy of a target's options with the options of the cell's values
+// applied. Maps such as `variables` and `metadata` are merged one level deep.
+func (c matrixCell) options(metaOut map[string]interface{}) map[string]interface{} {
+	if len(c.Vars) == 0 {
+		return metaOut
+	}
+	out := make(map[string]interface{}, len(metaOut))
+	for k, v := range metaOut {
+		out[k] = v
+	}
+	for _, v := range c.Vars {
+		for k, val := range v.Options {
+			base, baseOK := out[k].(map[string]interface{})
+			add, addOK := val.(map[string]interface{})
+			if !baseOK || !addOK {
+				out[k] = val
+				continue
+			}
+			merged := make(map[string]interface{}, len(base)+len(add))
+			for bk, bv := range base {
+				merged[bk] = bv
+			}
+			for ak, av := range add {
+				merged[ak] = av
+			}
+			out[k] = merged
+		}
+	}
+	return out
+}
+
+// metadataArgs passes every matrix value to pandoc as metadata, so templates and
+// filters can test e.g. $profile$.
+func (c matrixCell) metadataArgs() []string {
+	var args []string
+	for _, v := range c.Vars {
+		args = append(args, "--metadata", v.Name+"="+v.Value)
+	}
+	return args
+}
+
+// naming returns the naming request of the cell, with the {name} tokens of the `output`
+// key and `filename-template` expanded, and whether those names tell the cells apart.
+//
+// Parameters:
+//   - `req`: the naming request of the target
+func (c matrixCell) naming(req namingRequest) (namingRequest, bool) {
+	if len(c.Vars) == 0 {
+		return req, true
+	}
+	if out, ok := req.Meta["output"].(string); ok && out != "" {
+		meta := make(map[string]interface{}, len(req.Meta))
+		for k, v := range req.Meta {
+			meta[k] = v
+		}
+		meta["output"] = c.expand(out)
+		req.Meta = meta
+		return req, c.tokenized(out)
+	}
+	if strategy, err := resolveNamingStrategy(req.Config, req.Meta, false); err != nil || strategy != (templateNaming{}) {
+		return req, false
+	}
+	cfg := *req.Config
+	tokenized := c.tokenized(cfg.FilenameTemplate)
+	cfg.FilenameTemplate = c.expand(cfg.FilenameTemplate)
+	req.Config = &cfg
+	return req, tokenized
+}
+
+// suffix inserts the cell's values before the extension, e.g. doc.pdf becomes doc-draft.pdf.
+func (c matrixCell) suffix(outputFile string) string {
+	if len(c.Vars) == 0 {
+		return outputFile
+	}
+	values := make([]string, len(c.Vars))
+	for i, v := range c.Vars {
+		values[i] = v.Value
+	}
+	ext := filepath.Ext(outputFile)
+	return strings.TrimSuffix(outputFile, ext) + "-" + strings.Join(values, "-") + ext
+}
@@ -0,0 +1,102 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+func TestResolveMatrix(t *testing.T) {
+	cfg := &config.Config{Generic: map[string]interface{}{
+		"matrix": map[string]interface{}{
+			"profile": map[string]interface{}{
+				"draft": map[string]interface{}{"variables": map[string]interface{}{"watermark": "DRAFT"}},
+				"final": nil,
+			},
+			"paper": []interface{}{"a4", "letter"},
+		},
+	}}
+	dims, err := resolveMatrix(options.Options{}, cfg)
+	if err != nil {
+		t.Fatalf("resolveMatrix failed: %v", err)
+	}
+	if len(dims) != 2 || dims[0].Name != "paper" || dims[1].Name != "profile" {
+		t.Fatalf("unexpected dimensions: %+v", dims)
+	}
+	if cells := matrixCells([]string{"pdf", "html"}, dims); len(cells) != 8 {
+		t.Errorf("expected 2 targets x 2 x 2 cells, got %d", len(cells))
+	}
+
+	// --matrix replaces a dimension but keeps the options of known values
+	dims, err = resolveMatrix(options.Options{Matrix: []string{"profile=draft"}}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := dims[1].Values; len(v) != 1 || v[0].Value != "draft" || v[0].Options == nil {
+		t.Errorf("unexpected profile values: %+v", v)
+	}
+
+	if _, err := resolveMatrix(options.Options{Matrix: []string{"profile"}}, &config.Config{}); err == nil {
+		t.Error("expected an error for --matrix without values")
+	}
+	if _, err := resolveMatrix(options.Options{}, &config.Config{Generic: map[string]interface{}{"matrix": "draft"}}); err == nil {
+		t.Error("expected an error for a malformed matrix block")
+	}
+}
+
+func TestMatrixCell(t *testing.T) {
+	cell := matrixCell{Target: "pdf", Vars: []matrixVar{{Name: "profile", Value: "draft", Options: map[string]interface{}{
+		"variables": map[string]interface{}{"watermark": "DRAFT"},
+	}}}}
+	if got := cell.label(); got != "pdf[profile=draft]" {
+		t.Errorf("label = %q", got)
+	}
+	if got := cell.suffix("out/doc.pdf"); got != "out/doc-draft.pdf" {
+		t.Errorf("suffix = %q", got)
+	}
+	meta := map[string]interface{}{"variables": map[string]interface{}{"papersize": "a4"}}
+	got := cell.options(meta)
+	vars := got["variables"].(map[string]interface{})
+	if vars["papersize"] != "a4" || vars["watermark"] != "DRAFT" {
+		t.Errorf("expected merged variables, got %v", vars)
+	}
+	if _, ok := meta["variables"].(map[string]interface{})["watermark"]; ok {
+		t.Error("the target's own options must not be modified")
+	}
+}
+
+func TestProcess_Matrix(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte("---\nmatrix:\n  profile: [draft, final]\noutput:\n  html:\n    output: doc-{profile}.html\n  docx:\n    output: doc.docx\n---\n# Doc\n"), 0600)
+
+	rec := &argsRecorder{inputs: map[string]string{}, args: map[string][]string{}}
+	opts := options.Options{Targets: []string{"html", "docx"}, NoCache: true, Quiet: true}
+	results, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir})
+	if err != nil {
+		t.Fatalf("process failed: %v", err)
+	}
+
+	var names []string
+	for name := range rec.args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	want := []string{"doc-draft.docx", "doc-draft.html", "doc-final.docx", "doc-final.html"}
+	if strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Errorf("outputs = %v, want %v", names, want)
+	}
+	if args := strings.Join(rec.args["doc-draft.html"], " "); !strings.Contains(args, "--metadata profile=draft") {
+		t.Errorf("expected the matrix value as metadata, got %s", args)
+	}
+	if len(results) != 4 {
+		t.Errorf("expected one result per cell, got %d", len(results))
+	}
+}
@@ -0,0 +1,261 @@
+package app
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rapjul/panforge/internal/cache"
+	"github.com/rapjul/panforge/internal/config"
+)
+
+// defaultJPEGQuality is used when `media.quality` is not set.
+const defaultJPEGQuality = 85
+
+// mediaConfig is the resolved `media` setting for a target.
+type mediaConfig struct {
+	// Dir is passed to pandoc's --extract-media, relative to the output file.
+	Dir string
+	// MaxWidth downscales wider PNG and JPEG images; 0 keeps their size.
+	MaxWidth int
+	// Quality is the JPEG quality for re-encoded images.
+	Quality int
+}
+
+// resolveMedia reads the `media` key, with the target's value taking precedence.
+// The key is either the media directory or a map with dir, max-width, and quality.
+//
+// Parameters:
+//   - `cfg`: the global config
+//   - `metaOut`: the format-specific config
+//
+// Returns:
+//   - mediaConfig: the settings
+//   - bool: false if media handling is not configured
+func resolveMedia(cfg *config.Config, metaOut map[string]interface{}) (mediaConfig, bool) {
+	mc := mediaConfig{Quality: defaultJPEGQuality}
+	found := false
+	for _, raw := range []interface{}{cfg.Generic["media"], metaOut["media"]} {
+		switch v := raw.(type) {
+		case bool:
+			found = v
+		case string:
+			found = true
+			mc.Dir = v
+		case map[string]interface{}:
+			found = true
+			if s, ok := v["dir"].(string); ok {
+				mc.Dir = s
+			}
+			if n, ok := v["max-width"].(int); ok {
+				mc.MaxWidth = n
+			}
+			if n, ok := v["quality"].(int); ok && n > 0 && n <= 100 {
+				mc.Quality = n
+			}
+		}
+	}
+	if found && mc.Dir == "" {
+		mc.Dir = "media"
+	}
+	return mc, found
+}
+
+// mediaDirFor resolves the media directory of a target relative to its output file.
+//
+// Parameters:
+//   - `mc`: the media settings
+//   - `outputFile`: the absolute output path
+func mediaDirFor(mc mediaConfig, outputFile string) string {
+	if filepath.IsAbs(mc.Dir) {
+		return mc.Dir
+	}
+	return filepath.Join(filepath.Dir(outputFile), mc.Dir)
+}
+
+// optimizeImages downscales the document's local PNG and JPEG images that are wider than
+// `mc.MaxWidth` into the media directory and writes a per-target copy of the input whose
+// image links point at the smaller versions. Images are named by a hash of the original
+// and the settings, so unchanged images are not processed again.
+//
+// Parameters:
+//   - `input`: the document pandoc will read
+//   - `mc`: the media settings
+//   - `mediaDir`: the absolute media directory
+//
+// Returns:
+//   - string: the path of the copy, or "" if no image was replaced
+//   - error: any error writing the copy (images that cannot be decoded are left alone)
+func optimizeImages(input string, mc mediaConfig, mediaDir string) (string, error) {
+	if mc.MaxWidth <= 0 {
+		return "", nil
+	}
+	//nolint:gosec // G304: reading the input file is intended
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return "", fmt.Errorf("failed to read input for media: %w", err)
+	}
+	content := string(data)
+
+	var b strings.Builder
+	last := 0
+	replaced := 0
+	for _, m := range markdownImage.FindAllStringSubmatchIndex(content, -1) {
+		link := content[m[2]:m[3]]
+		optimized, err := optimizeImage(resolveImage(link, input), mc, mediaDir)
+		if err != nil || optimized == "" {
+			continue
+		}
+		b.WriteString(content[last:m[2]])
+		b.WriteString(filepath.ToSlash(relToWorkingDir(optimized)))
+		last = m[3]
+		replaced++
+	}
+	if replaced == 0 {
+		return "", nil
+	}
+	b.WriteString(content[last:])
+
+	tmp, err := os.CreateTemp(filepath.Dir(input), ".panforge-media-*"+filepath.Ext(input))
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	if _, err := tmp.WriteString(b.String()); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to close temp file: %w", err)
+	}
+	return tmp.Name(), nil
+}
+
+// resolveImage finds an image link on disk: relative to the working directory (where
+// pandoc looks first), then relative to the input document.
+//
+// Parameters:
+//   - `link`: the image path from the document
+//   - `input`: the document path
+func resolveImage(link, input string) string {
+	if isRemote(link) || filepath.IsAbs(link) {
+		return link
+	}
+	if _, err := os.Stat(link); err == nil {
+		if abs, err := filepath.Abs(link); err == nil {
+			return abs
+		}
+	}
+	return filepath.Join(filepath.Dir(input), link)
+}
+
+// optimizeImage writes a downscaled copy of a PNG or JPEG image into the media directory.
+//
+// Parameters:
+//   - `path`: the image path
+//   - `mc`: the media settings
+//   - `mediaDir`: the absolute media directory
+//
+// Returns:
+//   - string: the optimized image, or "" if the image is remote, another format, or small enough
+//   - error: any error reading, decoding, or writing the image
+func optimizeImage(path string, mc mediaConfig, mediaDir string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if isRemote(path) || (ext != ".png" && ext != ".jpg" && ext != ".jpeg") {
+		return "", nil
+	}
+	hash, err := cache.HashFile(path)
+	if err != nil {
+		return "", err
+	}
+	name := cache.ComputeKey(hash, fmt.Sprint(mc.MaxWidth), fmt.Sprint(mc.Quality))[:16]
+	out := filepath.Join(mediaDir, name+"-"+filepath.Base(path))
+	if _, err := os.Stat(out); err == nil {
+		return out, nil
+	}
+
+	//nolint:gosec // G304: reading images referenced by the document is intended
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	img, _, err := image.Decode(f)
+	_ = f.Close()
+	if err != nil {
+		return "", err
+	}
+	if img.Bounds().Dx() <= mc.MaxWidth {
+		return "", nil
+	}
+	img = downscale(img, mc.MaxWidth)
+
+	if err := os.MkdirAll(mediaDir, 0750); err != nil {
+		return "", fmt.Errorf("failed to create media directory: %w", err)
+	}
+	tmp := out + ".tmp"
+	//nolint:gosec // G304: the output path is derived from the image hash
+	w, err := os.Create(tmp)
+	if err != nil {
+		return "", err
+	}
+	if ext == ".png" {
+		err = png.Encode(w, img)
+	} else {
+		err = jpeg.Encode(w, img, &jpeg.Options{Quality: mc.Quality})
+	}
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return "", fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	return out, os.Rename(tmp, out)
+}
+
+// downscale resizes an image to the given width, keeping its aspect ratio, by averaging
+// the source pixels that fall into each destination pixel.
+//
+// Parameters:
+//   - `src`: the image
+//   - `width`: the new width, smaller than the current one
+func downscale(src image.Image, width int) image.Image {
+	sb := src.Bounds()
+	height := max(1, sb.Dy()*width/sb.Dx())
+	dst := image.NewRGBA64(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := sb.Min.Y + y*sb.Dy()/height
+		y1 := max(y0+1, sb.Min.Y+(y+1)*sb.Dy()/height)
+		for x := 0; x < width; x++ {
+			x0 := sb.Min.X + x*sb.Dx()/width
+			x1 := max(x0+1, sb.Min.X+(x+1)*sb.Dx()/width)
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			//nolint:gosec // G115: averages of 16-bit channels fit in uint16
+			dst.SetRGBA64(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
+
+// relToWorkingDir shortens a path relative to the working directory when it lies below it.
+func relToWorkingDir(path string) string {
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return path
+}
@@ -0,0 +1,93 @@
+package app
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/config"
+)
+
+func writePNG(t *testing.T, path string, w, h int) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolveMedia(t *testing.T) {
+	cfg := &config.Config{Generic: map[string]interface{}{}}
+	if _, ok := resolveMedia(cfg, nil); ok {
+		t.Error("media handling should be off by default")
+	}
+	cfg.Generic["media"] = "assets"
+	mc, ok := resolveMedia(cfg, map[string]interface{}{"media": map[string]interface{}{"max-width": 800, "quality": 70}})
+	if !ok || mc.Dir != "assets" || mc.MaxWidth != 800 || mc.Quality != 70 {
+		t.Errorf("resolveMedia = %+v, %v", mc, ok)
+	}
+	if mc, _ := resolveMedia(&config.Config{Generic: map[string]interface{}{"media": true}}, nil); mc.Dir != "media" || mc.Quality != defaultJPEGQuality {
+		t.Errorf("defaults = %+v", mc)
+	}
+}
+
+func TestOptimizeImages(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	writePNG(t, filepath.Join(dir, "big.png"), 200, 100)
+	writePNG(t, filepath.Join(dir, "small.png"), 50, 50)
+	input := filepath.Join(dir, "doc.md")
+	src := "![Big](big.png)\n\n![Small](small.png)\n\n![Remote](https://example.com/a.png)\n"
+	if err := os.WriteFile(input, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mediaDir := filepath.Join(dir, "out", "media")
+	out, err := optimizeImages(input, mediaConfig{Dir: "media", MaxWidth: 100, Quality: 80}, mediaDir)
+	if err != nil {
+		t.Fatalf("optimizeImages failed: %v", err)
+	}
+	if out == "" {
+		t.Fatal("expected a rewritten copy")
+	}
+	defer func() { _ = os.Remove(out) }()
+
+	data, _ := os.ReadFile(out)
+	got := string(data)
+	if !strings.Contains(got, "![Big](out/media/") || !strings.Contains(got, "![Small](small.png)") || !strings.Contains(got, "https://example.com/a.png") {
+		t.Errorf("unexpected links:\n%s", got)
+	}
+
+	images, _ := filepath.Glob(filepath.Join(mediaDir, "*-big.png"))
+	if len(images) != 1 {
+		t.Fatalf("expected one optimized image, got %v", images)
+	}
+	f, err := os.Open(images[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	cfg, err := png.DecodeConfig(f)
+	if err != nil || cfg.Width != 100 || cfg.Height != 50 {
+		t.Errorf("optimized image is %dx%d (%v), want 100x50", cfg.Width, cfg.Height, err)
+	}
+
+	// Without max-width nothing is rewritten.
+	if out, err := optimizeImages(input, mediaConfig{Dir: "media"}, mediaDir); err != nil || out != "" {
+		t.Errorf("expected no copy without max-width, got %q, %v", out, err)
+	}
+}
@@ -0,0 +1,268 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/pandoc"
+	"github.com/rapjul/panforge/internal/utils"
+)
+
+// namingStrategy computes the output filename of a target that neither -o nor the
+// target's `output` key names explicitly. It is selected with `naming-strategy`.
+type namingStrategy interface {
+	outputName(ctx context.Context, req namingRequest) (string, error)
+}
+
+// namingRequest describes the target a name is computed for.
+type namingRequest struct {
+	// Input is the document being converted.
+	Input string
+	// Target is the target name.
+	Target string
+	// Format is the resolved pandoc output format.
+	Format string
+	// Config is the document config.
+	Config *config.Config
+	// Meta is the format-specific config.
+	Meta map[string]interface{}
+	// BaseDir is the directory relative names are resolved against ("" for the working directory).
+	BaseDir string
+	// Peek computes the name without reserving it, e.g. to plan workspace cross-links.
+	Peek bool
+	// DryRun computes the name without saving state such as {counter} values.
+	DryRun bool
+	// Now is the time of {date} and {time}, or the zero time for the clock.
+	Now time.Time
+}
+
+// templateNaming expands `filename-template`; it is the default strategy.
+type templateNaming struct{}
+
+func (templateNaming) outputName(_ context.Context, req namingRequest) (string, error) {
+	now := req.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	counter := func() (int, error) {
+		return nextCounter(config.DataDirName(), req.Input, req.Format, !req.Peek && !req.DryRun)
+	}
+	return pandoc.GenerateOutputFilenameWith(req.Input, req.Config, req.Meta, req.Format, pandoc.FilenameVars{
+		Now:     now,
+		Counter: counter,
+		Git:     func() utils.GitInfo { return gitInfo(req.Input) },
+	})
+}
+
+// hashNaming names outputs after the SHA-256 of the input, so identical content
+// always gets the same name.
+type hashNaming struct {
+	Prefix string
+	Length int
+}
+
+func (s hashNaming) outputName(_ context.Context, req namingRequest) (string, error) {
+	//nolint:gosec // G304: reading the input file is intended
+	data, err := os.ReadFile(req.Input)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash input: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	if s.Length > 0 && s.Length < len(digest) {
+		digest = digest[:s.Length]
+	}
+	return utils.SanitizeFilename(s.Prefix + digest + "." + pandoc.ExtForFormat(req.Format)), nil
+}
+
+// sequentialNaming numbers outputs: the first name not yet taken in the output
+// directory, e.g. DOC-0007.pdf. Every format is numbered on its own, so the
+// outputs of one run usually share a number.
+type sequentialNaming struct {
+	Prefix string
+	Digits int
+}
+
+var (
+	// sequenceMu guards sequenceTaken.
+	sequenceMu sync.Mutex
+	// sequenceTaken holds names handed out during this process, so concurrent targets
+	// and documents never get the same number before their files exist.
+	sequenceTaken = make(map[string]bool)
+)
+
+func (s sequentialNaming) outputName(_ context.Context, req namingRequest) (string, error) {
+	ext := pandoc.ExtForFormat(req.Format)
+	// The prefix may name a subdirectory, e.g. "archive/DOC-"
+	prefixDir, prefixBase := filepath.Split(s.Prefix)
+	pattern := regexp.MustCompile(`^` + regexp.QuoteMeta(prefixBase) + `(\d+)\.` + regexp.QuoteMeta(ext) + `$`)
+	dir := filepath.Join(req.BaseDir, prefixDir)
+	if dir == "" {
+		dir = "."
+	}
+
+	sequenceMu.Lock()
+	defer sequenceMu.Unlock()
+	next := 1
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if m := pattern.FindStringSubmatch(e.Name()); m != nil {
+			if n, err := strconv.Atoi(m[1]); err == nil && n >= next {
+				next = n + 1
+			}
+		}
+	}
+	for {
+		name := fmt.Sprintf("%s%0*d.%s", s.Prefix, s.Digits, next, ext)
+		key := filepath.Join(req.BaseDir, name)
+		if !sequenceTaken[key] {
+			if !req.Peek {
+				sequenceTaken[key] = true
+			}
+			return name, nil
+		}
+		next++
+	}
+}
+
+// commandNaming asks an external command for the name. `{input}`, `{target}`,
+// `{format}`, and `{ext}` in the command are replaced; the same values and the
+// title and author are also passed as PANFORGE_* environment variables. The
+// first non-empty line of its output is the name.
+type commandNaming struct {
+	Command string
+}
+
+func (s commandNaming) outputName(ctx context.Context, req namingRequest) (string, error) {
+	ext := pandoc.ExtForFormat(req.Format)
+	cmdLine := strings.ReplaceAll(s.Command, "{input}", shellQuote(req.Input))
+	cmdLine = strings.ReplaceAll(cmdLine, "{target}", shellQuote(req.Target))
+	cmdLine = strings.ReplaceAll(cmdLine, "{format}", shellQuote(req.Format))
+	cmdLine = strings.ReplaceAll(cmdLine, "{ext}", shellQuote(ext))
+
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+	// The command runs even in dry-run mode: it only computes a name.
+	//nolint:gosec // G204: the naming command comes from the user's configuration
+	cmd := exec.CommandContext(ctx, shell, flag, cmdLine)
+	cmd.Dir = req.BaseDir
+	cmd.Env = os.Environ()
+	if !req.Now.IsZero() {
+		// A reproducible build's naming command gets its build time too
+		cmd.Env = append(cmd.Env, sourceDateEnv(req.Now)...)
+	}
+	cmd.Env = append(cmd.Env,
+		"PANFORGE_INPUT="+req.Input,
+		"PANFORGE_TARGET="+req.Target,
+		"PANFORGE_FORMAT="+req.Format,
+		"PANFORGE_EXT="+ext,
+		"PANFORGE_TITLE="+req.Config.Title,
+		"PANFORGE_AUTHOR="+req.Config.Author,
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("naming command %q failed: %w: %s", s.Command, err, strings.TrimSpace(stderr.String()))
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if name := strings.TrimSpace(line); name != "" {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("naming command %q printed no filename", s.Command)
+}
+
+// resolveNamingStrategy reads the `naming-strategy` setting of a target: a strategy
+// name ("template", "hash", "sequential") or a map with a `type` key and the
+// strategy's options. The target value wins over the global one.
+//
+// Parameters:
+//   - `cfg`: the global config
+//   - `metaOut`: the format-specific config
+//   - `sandboxed`: whether external commands are blocked
+//
+// Returns:
+//   - namingStrategy: the strategy (templateNaming if unset)
+//   - error: if the setting is invalid or not allowed
+func resolveNamingStrategy(cfg *config.Config, metaOut map[string]interface{}, sandboxed bool) (namingStrategy, error) {
+	raw, ok := metaOut["naming-strategy"]
+	if !ok {
+		raw = cfg.Generic["naming-strategy"]
+	}
+	var kind string
+	var settings map[string]interface{}
+	switch v := raw.(type) {
+	case nil:
+		return templateNaming{}, nil
+	case string:
+		kind = v
+	case map[string]interface{}:
+		kind, _ = v["type"].(string)
+		settings = v
+	default:
+		return nil, fmt.Errorf("naming-strategy must be a strategy name or a map")
+	}
+
+	prefix, _ := settings["prefix"].(string)
+	switch kind {
+	case "", "template":
+		return templateNaming{}, nil
+	case "hash":
+		length := 16
+		if n, ok := settings["length"].(int); ok && n > 0 {
+			length = n
+		}
+		return hashNaming{Prefix: prefix, Length: length}, nil
+	case "sequential":
+		digits := 4
+		if n, ok := settings["digits"].(int); ok && n > 0 {
+			digits = n
+		}
+		return sequentialNaming{Prefix: prefix, Digits: digits}, nil
+	case "command":
+		command, _ := settings["command"].(string)
+		if command == "" {
+			return nil, fmt.Errorf("naming-strategy: the command strategy needs a command")
+		}
+		if sandboxed {
+			return nil, fmt.Errorf("naming-strategy: the command strategy is not allowed in sandbox mode")
+		}
+		return commandNaming{Command: command}, nil
+	default:
+		return nil, fmt.Errorf("naming-strategy: unknown strategy %q (use template, hash, sequential, or command)", kind)
+	}
+}
+
+// outputFilename returns the output filename of a target: the target's `output` key
+// if set, otherwise the name computed by its naming strategy.
+//
+// Parameters:
+//   - `ctx`: context for cancellation
+//   - `req`: the target
+//   - `sandboxed`: whether external commands are blocked
+func outputFilename(ctx context.Context, req namingRequest, sandboxed bool) (string, error) {
+	if s, ok := req.Meta["output"].(string); ok && s != "" {
+		return s, nil
+	}
+	strategy, err := resolveNamingStrategy(req.Config, req.Meta, sandboxed)
+	if err != nil {
+		return "", err
+	}
+	return strategy.outputName(ctx, req)
+}
@@ -0,0 +1,97 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/config"
+)
+
+func TestResolveNamingStrategy(t *testing.T) {
+	tests := []struct {
+		name      string
+		global    interface{}
+		target    interface{}
+		sandboxed bool
+		want      namingStrategy
+		wantErr   bool
+	}{
+		{"default", nil, nil, false, templateNaming{}, false},
+		{"hash", "hash", nil, false, hashNaming{Length: 16}, false},
+		{"target wins", "hash", map[string]interface{}{"type": "sequential", "prefix": "DOC-", "digits": 3}, false, sequentialNaming{Prefix: "DOC-", Digits: 3}, false},
+		{"command", nil, map[string]interface{}{"type": "command", "command": "echo x"}, false, commandNaming{Command: "echo x"}, false},
+		{"command sandboxed", nil, map[string]interface{}{"type": "command", "command": "echo x"}, true, nil, true},
+		{"command missing", nil, map[string]interface{}{"type": "command"}, false, nil, true},
+		{"unknown", "random", nil, false, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Generic: map[string]interface{}{}}
+			if tt.global != nil {
+				cfg.Generic["naming-strategy"] = tt.global
+			}
+			metaOut := map[string]interface{}{}
+			if tt.target != nil {
+				metaOut["naming-strategy"] = tt.target
+			}
+			got, err := resolveNamingStrategy(cfg, metaOut, tt.sandboxed)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNamingStrategies(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte("# Hello\n"), 0644)
+	cfg := &config.Config{Title: "Hello"}
+	req := namingRequest{Input: input, Target: "pdf", Format: "pdf", Config: cfg, BaseDir: dir}
+	ctx := context.Background()
+
+	name, err := hashNaming{Prefix: "h-", Length: 8}.outputName(ctx, req)
+	if err != nil || !strings.HasPrefix(name, "h-") || len(name) != len("h-12345678.pdf") {
+		t.Errorf("hash name = %q, %v", name, err)
+	}
+
+	_ = os.WriteFile(filepath.Join(dir, "DOC-007.pdf"), nil, 0644)
+	seq := sequentialNaming{Prefix: "DOC-", Digits: 3}
+	if name, _ := seq.outputName(ctx, namingRequest{Format: "pdf", BaseDir: dir, Peek: true}); name != "DOC-008.pdf" {
+		t.Errorf("peeked sequential name = %q, want DOC-008.pdf", name)
+	}
+	first, _ := seq.outputName(ctx, req)
+	second, _ := seq.outputName(ctx, req)
+	if first != "DOC-008.pdf" || second != "DOC-009.pdf" {
+		t.Errorf("sequential names = %q, %q", first, second)
+	}
+	if name, _ := seq.outputName(ctx, namingRequest{Format: "html", BaseDir: dir}); name != "DOC-001.html" {
+		t.Errorf("formats should be numbered separately, got %q", name)
+	}
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the command strategy test uses a POSIX shell")
+	}
+	name, err = commandNaming{Command: `echo "$PANFORGE_TITLE"-{target}."$PANFORGE_EXT"`}.outputName(ctx, req)
+	if err != nil || name != "Hello-pdf.pdf" {
+		t.Errorf("command name = %q, %v", name, err)
+	}
+	if _, err := (commandNaming{Command: "true"}).outputName(ctx, req); err == nil {
+		t.Error("expected an error when the command prints nothing")
+	}
+}
+
+func TestOutputFilename_ExplicitOutputWins(t *testing.T) {
+	cfg := &config.Config{Generic: map[string]interface{}{"naming-strategy": "hash"}}
+	req := namingRequest{Input: "missing.md", Format: "html", Config: cfg, Meta: map[string]interface{}{"output": "site/index.html"}}
+	if name, err := outputFilename(context.Background(), req, false); err != nil || name != "site/index.html" {
+		t.Errorf("outputFilename = %q, %v", name, err)
+	}
+}
@@ -0,0 +1,193 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+	"github.com/rapjul/panforge/internal/utils"
+)
+
+// echoCommand reports a command panforge is about to run. --quiet hides it, except in
+// dry-run mode, where printing the commands is what was asked for: they are printed
+// plainly, dimmed on a color terminal, instead of logged.
+//
+// Parameters:
+//   - `opts`: runtime options
+//   - `cmdStr`: the formatted command line
+func echoCommand(opts options.Options, cmdStr string) {
+	switch {
+	case opts.Quiet && !opts.DryRun:
+		return
+	case opts.Logger != nil && !opts.Quiet && !opts.DryRun:
+		opts.Logger.Info("executing command", "command", cmdStr)
+	default:
+		fmt.Printf("panforge calling: %s\n", utils.Colorize(os.Stdout, utils.Dim, cmdStr))
+	}
+}
+
+// How the output of a target's pandoc process is shown (`subprocess-output`).
+const (
+	// subprocessDiscard drops the output; pandoc's warnings are still reported as diagnostics.
+	subprocessDiscard = "discard"
+	// subprocessOnFailure buffers the output and prints it only if the target fails.
+	subprocessOnFailure = "on-failure"
+	// subprocessStream passes the output through as it is written.
+	subprocessStream = "stream"
+)
+
+// outputMu keeps the buffered output of parallel targets from interleaving.
+var outputMu sync.Mutex
+
+// subprocessOutput routes the output of one target's pandoc process.
+type subprocessOutput struct {
+	// Mode is subprocessDiscard, subprocessOnFailure, or subprocessStream.
+	Mode string
+	// File also receives the output, if set.
+	File string
+	// Prefix starts every streamed line, so the output of parallel targets can be told apart.
+	Prefix string
+
+	buf     bytes.Buffer
+	file    *os.File
+	streams []*prefixWriter
+}
+
+// resolveSubprocessOutput returns how a target's pandoc output is routed: --subprocess-output,
+// else the target's or document's `subprocess-output` key, else stream with --verbose and
+// on-failure otherwise. Any other value is a file (with {target} replaced) that receives the
+// output, which is then shown on failure too.
+//
+// Parameters:
+//   - `opts`: runtime options
+//   - `cfg`: the global config
+//   - `metaOut`: the format-specific config
+//   - `target`: the target name
+//   - `baseDir`: the directory relative files are resolved against
+func resolveSubprocessOutput(opts options.Options, cfg *config.Config, metaOut map[string]interface{}, target, baseDir string) (*subprocessOutput, error) {
+	mode := opts.SubprocessOutput
+	if mode == "" {
+		mode = stringSetting(cfg, metaOut, "subprocess-output")
+	}
+	switch mode {
+	case "":
+		if opts.Verbose {
+			return &subprocessOutput{Mode: subprocessStream}, nil
+		}
+		return &subprocessOutput{Mode: subprocessOnFailure}, nil
+	case subprocessDiscard, subprocessOnFailure, subprocessStream:
+		return &subprocessOutput{Mode: mode}, nil
+	}
+	file, err := resolveIn(baseDir, strings.ReplaceAll(mode, "{target}", utils.Slugify(target)))
+	if err != nil {
+		return nil, fmt.Errorf("subprocess-output: %w", err)
+	}
+	return &subprocessOutput{Mode: subprocessOnFailure, File: file}, nil
+}
+
+// writers returns the stdout and stderr writers for the process; diag also receives
+// stderr so warnings can be parsed. Call finish once the process has exited.
+//
+// Parameters:
+//   - `diag`: the buffer pandoc's diagnostics are parsed from
+func (s *subprocessOutput) writers(diag io.Writer) (io.Writer, io.Writer, error) {
+	var stdout, stderr io.Writer = io.Discard, io.Discard
+	switch s.Mode {
+	case subprocessStream:
+		stdout, stderr = os.Stdout, os.Stderr
+		if s.Prefix != "" {
+			s.streams = []*prefixWriter{{w: os.Stdout, prefix: s.Prefix}, {w: os.Stderr, prefix: s.Prefix}}
+			stdout, stderr = s.streams[0], s.streams[1]
+		}
+	case subprocessOnFailure:
+		stdout, stderr = &s.buf, &s.buf
+	}
+	if s.File != "" {
+		if err := os.MkdirAll(filepath.Dir(s.File), 0750); err != nil {
+			return nil, nil, fmt.Errorf("failed to create output log directory: %w", err)
+		}
+		//nolint:gosec // G304: the log file is named by the user
+		f, err := os.Create(s.File)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create output log: %w", err)
+		}
+		s.file = f
+		stdout, stderr = io.MultiWriter(stdout, f), io.MultiWriter(stderr, f)
+	}
+	return stdout, io.MultiWriter(stderr, diag), nil
+}
+
+// finish closes the log file and, if the process failed, prints the buffered output
+// in one block.
+//
+// Parameters:
+//   - `target`: the target name, used as a heading
+//   - `failed`: whether the process failed
+func (s *subprocessOutput) finish(target string, failed bool) {
+	if s.file != nil {
+		_ = s.file.Close()
+	}
+	for _, w := range s.streams {
+		w.flush()
+	}
+	if !failed || s.buf.Len() == 0 {
+		return
+	}
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	fmt.Fprintf(os.Stderr, "--- pandoc output for target %s ---\n", target)
+	_, _ = os.Stderr.Write(s.buf.Bytes())
+	if !bytes.HasSuffix(s.buf.Bytes(), []byte("\n")) {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+// prefixWriter writes whole lines, each starting with a prefix, so lines streamed by
+// parallel processes do not interleave mid-line.
+type prefixWriter struct {
+	w       io.Writer
+	prefix  string
+	mu      sync.Mutex
+	partial []byte
+}
+
+// Write prints every complete line and keeps the rest until the next write.
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.partial = append(p.partial, b...)
+	for {
+		i := bytes.IndexByte(p.partial, '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		if err := p.writeLine(p.partial[:i+1]); err != nil {
+			return 0, err
+		}
+		p.partial = p.partial[i+1:]
+	}
+}
+
+// flush prints an unterminated last line.
+func (p *prefixWriter) flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.partial) > 0 {
+		_ = p.writeLine(append(p.partial, '\n'))
+		p.partial = nil
+	}
+}
+
+// writeLine prints one line with the prefix.
+func (p *prefixWriter) writeLine(line []byte) error {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	_, err := fmt.Fprintf(p.w, "[%s] %s", p.prefix, line)
+	return err
+}
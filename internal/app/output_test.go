@@ -0,0 +1,139 @@
+package app
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+// captureStdout returns what fn writes to os.Stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = orig }()
+	fn()
+	_ = w.Close()
+	out, _ := io.ReadAll(r)
+	return string(out)
+}
+
+func TestEchoCommand(t *testing.T) {
+	quietLogger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+	tests := []struct {
+		name string
+		opts options.Options
+		want string
+	}{
+		{"default", options.Options{}, "panforge calling: pandoc a.md\n"},
+		{"quiet", options.Options{Quiet: true}, ""},
+		{"quiet with logger", options.Options{Quiet: true, Logger: quietLogger}, ""},
+		{"quiet dry-run", options.Options{Quiet: true, DryRun: true, Logger: quietLogger}, "panforge calling: pandoc a.md\n"},
+		{"dry-run with logger", options.Options{DryRun: true, Logger: quietLogger}, "panforge calling: pandoc a.md\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := captureStdout(t, func() { echoCommand(tt.opts, "pandoc a.md") }); got != tt.want {
+				t.Errorf("echoCommand printed %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	var logged bytes.Buffer
+	opts := options.Options{Logger: slog.New(slog.NewTextHandler(&logged, nil))}
+	if got := captureStdout(t, func() { echoCommand(opts, "pandoc a.md") }); got != "" || !bytes.Contains(logged.Bytes(), []byte("pandoc a.md")) {
+		t.Errorf("expected the command to be logged, stdout %q, log %q", got, logged.String())
+	}
+}
+
+func TestResolveSubprocessOutput(t *testing.T) {
+	cfg := &config.Config{Generic: map[string]interface{}{}}
+	tests := []struct {
+		name     string
+		opts     options.Options
+		meta     map[string]interface{}
+		wantMode string
+		wantFile string
+	}{
+		{"default", options.Options{}, nil, subprocessOnFailure, ""},
+		{"verbose", options.Options{Verbose: true}, nil, subprocessStream, ""},
+		{"target", options.Options{}, map[string]interface{}{"subprocess-output": "discard"}, subprocessDiscard, ""},
+		{"flag wins", options.Options{SubprocessOutput: "stream"}, map[string]interface{}{"subprocess-output": "discard"}, subprocessStream, ""},
+		{"file", options.Options{SubprocessOutput: "logs/{target}.log"}, nil, subprocessOnFailure, filepath.Join("/work", "logs", "pdf.log")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveSubprocessOutput(tt.opts, cfg, tt.meta, "pdf", "/work")
+			if err != nil || got.Mode != tt.wantMode || got.File != tt.wantFile {
+				t.Errorf("resolveSubprocessOutput = %+v, %v; want %s %q", got, err, tt.wantMode, tt.wantFile)
+			}
+		})
+	}
+}
+
+func TestSubprocessOutput_OnFailure(t *testing.T) {
+	dir := t.TempDir()
+	run := func(failed bool) (string, string) {
+		out := &subprocessOutput{Mode: subprocessOnFailure, File: filepath.Join(dir, "pdf.log")}
+		var diag bytes.Buffer
+		stdout, stderr, err := out.writers(&diag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.WriteString(stdout, "progress\n")
+		_, _ = io.WriteString(stderr, "[WARNING] oops\n")
+		return captureStderr(t, func() { out.finish("pdf", failed) }), diag.String()
+	}
+
+	if shown, diag := run(false); shown != "" || diag != "[WARNING] oops\n" {
+		t.Errorf("a successful run should show nothing, got %q (diagnostics %q)", shown, diag)
+	}
+	shown, _ := run(true)
+	if !strings.Contains(shown, "--- pandoc output for target pdf ---") || !strings.Contains(shown, "progress\n[WARNING] oops") {
+		t.Errorf("expected the buffered output on failure, got %q", shown)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "pdf.log")); string(data) != "progress\n[WARNING] oops\n" {
+		t.Errorf("expected the output in the log file, got %q", data)
+	}
+}
+
+// captureStderr returns what fn writes to os.Stderr.
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = orig }()
+	fn()
+	_ = w.Close()
+	out, _ := io.ReadAll(r)
+	return string(out)
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := &prefixWriter{w: &buf, prefix: "pdf"}
+	_, _ = w.Write([]byte("[WARNING] Could not "))
+	_, _ = w.Write([]byte("fetch resource\nrunning lualatex\nlast"))
+	if buf.String() != "[pdf] [WARNING] Could not fetch resource\n[pdf] running lualatex\n" {
+		t.Errorf("expected whole prefixed lines, got %q", buf.String())
+	}
+	w.flush()
+	if !strings.HasSuffix(buf.String(), "[pdf] last\n") {
+		t.Errorf("expected the last line on flush, got %q", buf.String())
+	}
+}
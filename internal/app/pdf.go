@@ -0,0 +1,338 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+// pdfPresets are the ghostscript PDFSETTINGS accepted by `compress-pdf`.
+var pdfPresets = map[string]bool{
+	"screen":   true,
+	"ebook":    true,
+	"printer":  true,
+	"prepress": true,
+	"default":  true,
+}
+
+// compressConfig holds the resolved `compress-pdf` settings of a target.
+type compressConfig struct {
+	// Tool is "gs", "qpdf", or "" to use whichever is installed (ghostscript first).
+	Tool string
+	// Preset is the ghostscript quality preset; qpdf always compresses losslessly.
+	Preset string
+}
+
+// toolAvailable reports whether a program is on the PATH; replaced in tests.
+var toolAvailable = func(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// ghostscriptName returns the platform's ghostscript command.
+func ghostscriptName() string {
+	if runtime.GOOS == "windows" {
+		return "gswin64c"
+	}
+	return "gs"
+}
+
+// isPDFOutput reports whether a target writes a PDF file.
+func isPDFOutput(outputFile string) bool {
+	return strings.EqualFold(filepath.Ext(outputFile), ".pdf")
+}
+
+// resolveCompressPDF reads the `compress-pdf` setting of a target: true, a quality
+// preset ("screen", "ebook", "printer", "prepress", "default", or "lossless"), or a
+// map with `tool` and `quality` keys. The target value wins over the global one.
+//
+// Parameters:
+//   - `cfg`: the global config
+//   - `metaOut`: the format-specific config
+//
+// Returns:
+//   - *compressConfig: the settings, or nil if compression is off
+//   - error: if the value is invalid
+func resolveCompressPDF(cfg *config.Config, metaOut map[string]interface{}) (*compressConfig, error) {
+	raw, ok := metaOut["compress-pdf"]
+	if !ok {
+		raw = cfg.Generic["compress-pdf"]
+	}
+	cc := &compressConfig{Preset: "ebook"}
+	switch v := raw.(type) {
+	case nil:
+		return nil, nil
+	case bool:
+		if !v {
+			return nil, nil
+		}
+	case string:
+		cc.Preset = v
+	case map[string]interface{}:
+		if tool, ok := v["tool"].(string); ok {
+			cc.Tool = tool
+		}
+		if quality, ok := v["quality"].(string); ok {
+			cc.Preset = quality
+		}
+	default:
+		return nil, fmt.Errorf("compress-pdf must be true, a quality preset, or a map")
+	}
+
+	if cc.Preset == "lossless" {
+		if cc.Tool == "gs" {
+			return nil, fmt.Errorf("compress-pdf: the lossless preset requires qpdf")
+		}
+		cc.Tool = "qpdf"
+	} else if !pdfPresets[cc.Preset] {
+		return nil, fmt.Errorf("compress-pdf: unknown quality %q (use screen, ebook, printer, prepress, default, or lossless)", cc.Preset)
+	}
+	if cc.Tool != "" && cc.Tool != "gs" && cc.Tool != "qpdf" {
+		return nil, fmt.Errorf("compress-pdf: unknown tool %q (use gs or qpdf)", cc.Tool)
+	}
+	return cc, nil
+}
+
+// pdfTools returns the tools a target's `compress-pdf` setting may use, for the check command.
+//
+// Parameters:
+//   - `cc`: the resolved settings
+func pdfTools(cc *compressConfig) []string {
+	switch cc.Tool {
+	case "gs":
+		return []string{ghostscriptName()}
+	case "qpdf":
+		return []string{"qpdf"}
+	}
+	return []string{ghostscriptName(), "qpdf"}
+}
+
+// compressPDF rewrites a PDF output through ghostscript or qpdf and reports the size
+// before and after. The original is kept if the result is not smaller.
+//
+// Parameters:
+//   - `ctx`: context for cancellation
+//   - `cc`: the compression settings
+//   - `outputFile`: the PDF, replaced in place
+//   - `opts`: runtime options
+//   - `executor`: used to run the tool
+//
+// Returns:
+//   - error: if no tool is installed or the tool failed
+func compressPDF(ctx context.Context, cc *compressConfig, outputFile string, opts options.Options, executor CommandExecutor) error {
+	tool := cc.Tool
+	if tool == "" {
+		if toolAvailable(ghostscriptName()) {
+			tool = "gs"
+		} else {
+			tool = "qpdf"
+		}
+	}
+
+	tmp := strings.TrimSuffix(outputFile, filepath.Ext(outputFile)) + ".panforge-compress.pdf"
+	var name string
+	var args []string
+	if tool == "gs" {
+		name = ghostscriptName()
+		args = []string{
+			"-sDEVICE=pdfwrite", "-dCompatibilityLevel=1.5", "-dPDFSETTINGS=/" + cc.Preset,
+			"-dNOPAUSE", "-dQUIET", "-dBATCH", "-sOutputFile=" + tmp, outputFile,
+		}
+	} else {
+		name = "qpdf"
+		args = []string{
+			"--compress-streams=y", "--object-streams=generate", "--recompress-flate",
+			"--compression-level=9", outputFile, tmp,
+		}
+	}
+	if cc.Tool == "" && !opts.DryRun && !toolAvailable(name) {
+		return fmt.Errorf("compress-pdf requires ghostscript (%s) or qpdf; run 'panforge check' to see installed tools", ghostscriptName())
+	}
+
+	cmdStr := formatCommand(name, args)
+	echoCommand(opts, cmdStr)
+	if opts.DryRun {
+		return nil
+	}
+
+	before, err := os.Stat(outputFile)
+	if err != nil {
+		return fmt.Errorf("failed to read PDF for compression: %w", err)
+	}
+	if err := executor.Run(ctx, name, args, os.Stdout, os.Stderr); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("compress-pdf: %s failed: %w", name, err)
+	}
+	after, err := os.Stat(tmp)
+	if err != nil {
+		return fmt.Errorf("compress-pdf: %s produced no output: %w", name, err)
+	}
+
+	if after.Size() >= before.Size() {
+		_ = os.Remove(tmp)
+		if opts.Logger != nil {
+			opts.Logger.Info("compress-pdf: output kept, compression did not reduce its size", "file", outputFile, "size", before.Size())
+		} else if !opts.Quiet {
+			fmt.Printf("Kept %s (%s): compression did not reduce its size\n", outputFile, formatBytes(before.Size()))
+		}
+		return nil
+	}
+	if err := os.Rename(tmp, outputFile); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to replace compressed PDF: %w", err)
+	}
+
+	saved := 100 - after.Size()*100/before.Size()
+	if opts.Logger != nil {
+		opts.Logger.Info("compressed PDF", "file", outputFile, "before", before.Size(), "after", after.Size())
+	} else if !opts.Quiet {
+		fmt.Printf("Compressed %s: %s -> %s (-%d%%)\n", outputFile, formatBytes(before.Size()), formatBytes(after.Size()), saved)
+	}
+	return nil
+}
+
+// protectConfig holds the resolved `pdf-protect` settings of a target.
+type protectConfig struct {
+	// UserPassword is needed to open the PDF (empty opens without a password).
+	UserPassword string
+	// OwnerPassword is needed to change the permissions.
+	OwnerPassword string
+	// Print is "full", "low" (low resolution only), or "none".
+	Print string
+	// Copy allows extracting text and images.
+	Copy bool
+	// Modify allows editing, annotating, and filling in forms.
+	Modify bool
+}
+
+// resolvePDFProtect reads the `pdf-protect` map of a target, e.g.
+// {user-password: x, owner-password: y, print: low, copy: false, modify: false}.
+// Printing, copying, and modifying are allowed unless turned off. The target
+// value wins over the global one.
+//
+// Parameters:
+//   - `cfg`: the global config
+//   - `metaOut`: the format-specific config
+//
+// Returns:
+//   - *protectConfig: the settings, or nil if protection is off
+//   - error: if the value is invalid
+func resolvePDFProtect(cfg *config.Config, metaOut map[string]interface{}) (*protectConfig, error) {
+	raw, ok := metaOut["pdf-protect"]
+	if !ok {
+		raw = cfg.Generic["pdf-protect"]
+	}
+	var v map[string]interface{}
+	switch r := raw.(type) {
+	case nil:
+		return nil, nil
+	case bool:
+		if !r {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("pdf-protect needs at least an owner-password")
+	case map[string]interface{}:
+		v = r
+	default:
+		return nil, fmt.Errorf("pdf-protect must be a map")
+	}
+
+	pc := &protectConfig{Print: "full", Copy: true, Modify: true}
+	pc.UserPassword, _ = v["user-password"].(string)
+	pc.OwnerPassword, _ = v["owner-password"].(string)
+	if pc.OwnerPassword == "" {
+		return nil, fmt.Errorf("pdf-protect needs an owner-password")
+	}
+	switch p := v["print"].(type) {
+	case nil:
+	case bool:
+		if !p {
+			pc.Print = "none"
+		}
+	case string:
+		if p != "full" && p != "low" && p != "none" {
+			return nil, fmt.Errorf("pdf-protect: print must be true, false, full, low, or none")
+		}
+		pc.Print = p
+	default:
+		return nil, fmt.Errorf("pdf-protect: print must be true, false, full, low, or none")
+	}
+	if c, ok := v["copy"].(bool); ok {
+		pc.Copy = c
+	}
+	if m, ok := v["modify"].(bool); ok {
+		pc.Modify = m
+	}
+	return pc, nil
+}
+
+// protectPDF encrypts a PDF output with qpdf (AES-256) and applies the permissions.
+// The passwords are passed in a qpdf @argfile only the user can read, so they never
+// appear in the process list or in the logged command.
+//
+// Parameters:
+//   - `ctx`: context for cancellation
+//   - `pc`: the protection settings
+//   - `outputFile`: the PDF, replaced in place
+//   - `opts`: runtime options
+//   - `executor`: used to run qpdf
+//
+// Returns:
+//   - error: if qpdf failed
+func protectPDF(ctx context.Context, pc *protectConfig, outputFile string, opts options.Options, executor CommandExecutor) error {
+	yesNo := func(b bool) string {
+		if b {
+			return "y"
+		}
+		return "n"
+	}
+	modify := "none"
+	if pc.Modify {
+		modify = "all"
+	}
+	if strings.ContainsAny(pc.UserPassword+pc.OwnerPassword, "\r\n") {
+		return fmt.Errorf("pdf-protect: passwords cannot contain line breaks")
+	}
+	encrypt := []string{
+		"--encrypt", "--user-password=" + pc.UserPassword, "--owner-password=" + pc.OwnerPassword, "--bits=256",
+		"--print=" + pc.Print, "--extract=" + yesNo(pc.Copy), "--modify=" + modify, "--",
+	}
+
+	argFile := "ENCRYPT-OPTIONS"
+	if !opts.DryRun {
+		dir, err := os.MkdirTemp("", "panforge-qpdf-*")
+		if err != nil {
+			return fmt.Errorf("pdf-protect: %w", err)
+		}
+		defer func() { _ = os.RemoveAll(dir) }()
+		argFile = filepath.Join(dir, "encrypt")
+		if err := os.WriteFile(argFile, []byte(strings.Join(encrypt, "\n")+"\n"), 0600); err != nil {
+			return fmt.Errorf("pdf-protect: %w", err)
+		}
+	}
+	tmp := strings.TrimSuffix(outputFile, filepath.Ext(outputFile)) + ".panforge-protect.pdf"
+	args := []string{"@" + argFile, outputFile, tmp}
+
+	cmdStr := formatCommand("qpdf", args)
+	echoCommand(opts, cmdStr)
+	if opts.DryRun {
+		return nil
+	}
+
+	if err := executor.Run(ctx, "qpdf", args, os.Stdout, os.Stderr); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("pdf-protect: qpdf failed: %w", err)
+	}
+	if err := os.Rename(tmp, outputFile); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to replace protected PDF: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package app
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+// pdfToolExecutor writes a fixed-size result where gs or qpdf would write the processed PDF.
+// Arguments read from a qpdf @argfile are recorded with the file's mode.
+type pdfToolExecutor struct {
+	size     int
+	calls    []string
+	argFiles []string
+	argModes []os.FileMode
+}
+
+func (e *pdfToolExecutor) Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	e.calls = append(e.calls, name+" "+strings.Join(args, " "))
+	for _, a := range args {
+		if path, ok := strings.CutPrefix(a, "@"); ok {
+			data, _ := os.ReadFile(path)
+			info, _ := os.Stat(path)
+			e.argFiles = append(e.argFiles, string(data))
+			e.argModes = append(e.argModes, info.Mode().Perm())
+		}
+	}
+	out := args[len(args)-1]
+	for _, a := range args {
+		if strings.HasPrefix(a, "-sOutputFile=") {
+			out = strings.TrimPrefix(a, "-sOutputFile=")
+		}
+	}
+	return os.WriteFile(out, make([]byte, e.size), 0644)
+}
+
+func TestResolveCompressPDF(t *testing.T) {
+	tests := []struct {
+		name    string
+		global  interface{}
+		target  interface{}
+		want    *compressConfig
+		wantErr bool
+	}{
+		{"unset", nil, nil, nil, false},
+		{"true", true, nil, &compressConfig{Preset: "ebook"}, false},
+		{"target disables", true, false, nil, false},
+		{"preset", nil, "screen", &compressConfig{Preset: "screen"}, false},
+		{"lossless", nil, "lossless", &compressConfig{Tool: "qpdf", Preset: "lossless"}, false},
+		{"map", nil, map[string]interface{}{"tool": "gs", "quality": "printer"}, &compressConfig{Tool: "gs", Preset: "printer"}, false},
+		{"unknown preset", "tiny", nil, nil, true},
+		{"unknown tool", nil, map[string]interface{}{"tool": "mutool"}, nil, true},
+		{"gs lossless", nil, map[string]interface{}{"tool": "gs", "quality": "lossless"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Generic: map[string]interface{}{}}
+			if tt.global != nil {
+				cfg.Generic["compress-pdf"] = tt.global
+			}
+			metaOut := map[string]interface{}{}
+			if tt.target != nil {
+				metaOut["compress-pdf"] = tt.target
+			}
+			got, err := resolveCompressPDF(cfg, metaOut)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompressPDF(t *testing.T) {
+	restore := toolAvailable
+	defer func() { toolAvailable = restore }()
+	toolAvailable = func(name string) bool { return name == "qpdf" }
+
+	dir := t.TempDir()
+	out := filepath.Join(dir, "doc.pdf")
+	opts := options.Options{Quiet: true}
+
+	// Smaller result replaces the output; ghostscript is missing so qpdf is used
+	_ = os.WriteFile(out, make([]byte, 1000), 0644)
+	exec := &pdfToolExecutor{size: 400}
+	if err := compressPDF(context.Background(), &compressConfig{Preset: "ebook"}, out, opts, exec); err != nil {
+		t.Fatalf("compressPDF failed: %v", err)
+	}
+	if len(exec.calls) != 1 || !strings.HasPrefix(exec.calls[0], "qpdf ") {
+		t.Errorf("expected one qpdf call, got %v", exec.calls)
+	}
+	if info, _ := os.Stat(out); info.Size() != 400 {
+		t.Errorf("output size = %d, want 400", info.Size())
+	}
+
+	// A larger result is discarded
+	exec = &pdfToolExecutor{size: 4000}
+	if err := compressPDF(context.Background(), &compressConfig{Tool: "gs", Preset: "screen"}, out, opts, exec); err != nil {
+		t.Fatalf("compressPDF failed: %v", err)
+	}
+	if !strings.Contains(exec.calls[0], "-dPDFSETTINGS=/screen") {
+		t.Errorf("expected ghostscript preset, got %v", exec.calls)
+	}
+	if info, _ := os.Stat(out); info.Size() != 400 {
+		t.Errorf("output should be kept, size = %d", info.Size())
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "*.panforge-compress.pdf")); len(matches) != 0 {
+		t.Errorf("temporary file left behind: %v", matches)
+	}
+
+	// No tool installed
+	toolAvailable = func(string) bool { return false }
+	if err := compressPDF(context.Background(), &compressConfig{Preset: "ebook"}, out, opts, exec); err == nil {
+		t.Error("expected an error when neither gs nor qpdf is installed")
+	}
+}
+
+func TestResolvePDFProtect(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    *protectConfig
+		wantErr bool
+	}{
+		{"unset", nil, nil, false},
+		{"disabled", false, nil, false},
+		{"no owner password", map[string]interface{}{"user-password": "open"}, nil, true},
+		{"defaults allow everything", map[string]interface{}{"owner-password": "o"}, &protectConfig{OwnerPassword: "o", Print: "full", Copy: true, Modify: true}, false},
+		{"restricted", map[string]interface{}{"user-password": "u", "owner-password": "o", "print": "low", "copy": false, "modify": false}, &protectConfig{UserPassword: "u", OwnerPassword: "o", Print: "low"}, false},
+		{"no printing", map[string]interface{}{"owner-password": "o", "print": false}, &protectConfig{OwnerPassword: "o", Print: "none", Copy: true, Modify: true}, false},
+		{"bad print", map[string]interface{}{"owner-password": "o", "print": "high"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Generic: map[string]interface{}{}}
+			metaOut := map[string]interface{}{}
+			if tt.value != nil {
+				metaOut["pdf-protect"] = tt.value
+			}
+			got, err := resolvePDFProtect(cfg, metaOut)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProtectPDF(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "doc.pdf")
+	_ = os.WriteFile(out, make([]byte, 100), 0644)
+
+	exec := &pdfToolExecutor{size: 120}
+	pc := &protectConfig{UserPassword: "u", OwnerPassword: "o", Print: "low"}
+	if err := protectPDF(context.Background(), pc, out, options.Options{Quiet: true}, exec); err != nil {
+		t.Fatalf("protectPDF failed: %v", err)
+	}
+	if len(exec.calls) != 1 || !strings.HasPrefix(exec.calls[0], "qpdf @") || strings.Contains(exec.calls[0], "--encrypt") {
+		t.Errorf("passwords should be passed in an argfile, got %v", exec.calls)
+	}
+	wantArgs := "--encrypt\n--user-password=u\n--owner-password=o\n--bits=256\n--print=low\n--extract=n\n--modify=none\n--\n"
+	if len(exec.argFiles) != 1 || exec.argFiles[0] != wantArgs || exec.argModes[0] != 0600 {
+		t.Errorf("argfile = %q (mode %v), want %q", exec.argFiles, exec.argModes, wantArgs)
+	}
+	argFile := strings.TrimPrefix(strings.Fields(exec.calls[0])[1], "@")
+	if _, err := os.Stat(filepath.Dir(argFile)); !os.IsNotExist(err) {
+		t.Errorf("argfile directory should be removed, got %v", err)
+	}
+	if info, _ := os.Stat(out); info.Size() != 120 {
+		t.Errorf("output was not replaced, size = %d", info.Size())
+	}
+
+	pc.OwnerPassword = "o\n--decrypt"
+	if err := protectPDF(context.Background(), pc, out, options.Options{Quiet: true}, exec); err == nil {
+		t.Error("expected an error for a password with a line break")
+	}
+}
@@ -0,0 +1,158 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rapjul/panforge/internal/options"
+	"github.com/rapjul/panforge/internal/utils"
+)
+
+// isInteractive reports whether panforge can prompt the user; replaced in tests.
+var isInteractive = func() bool {
+	return utils.IsTerminal(os.Stdin) && utils.IsTerminal(os.Stderr)
+}
+
+const (
+	// noInputSkip answers every prompt with its safe default, e.g. leaving an existing output alone.
+	noInputSkip = "skip"
+	// noInputFail fails the target instead of prompting.
+	noInputFail = "fail"
+)
+
+// NoInputModes lists the values accepted by --no-input.
+var NoInputModes = []string{noInputSkip, noInputFail}
+
+// noInputMode returns what replaces the prompts of a run: "" to prompt, or noInputSkip or
+// noInputFail as set with --no-input. When stdin or stderr is not a terminal, prompts
+// are replaced with noInputSkip, so builds in CI never wait for an answer.
+//
+// Parameters:
+//   - `opts`: runtime options
+//
+// Returns:
+//   - string: "", noInputSkip, or noInputFail
+//   - error: if --no-input has an unknown value
+func noInputMode(opts options.Options) (string, error) {
+	switch opts.NoInput {
+	case "":
+		if isInteractive() {
+			return "", nil
+		}
+		return noInputSkip, nil
+	case noInputSkip, noInputFail:
+		return opts.NoInput, nil
+	default:
+		return "", fmt.Errorf("--no-input: unknown mode %q (use skip or fail)", opts.NoInput)
+	}
+}
+
+// shouldPickTargets reports whether the user should choose the targets to build: the
+// document defines several, none were requested with -t or --all, and panforge runs
+// in a terminal without --no-interactive, --no-input, or --watch.
+//
+// Parameters:
+//   - `opts`: runtime options
+//   - `targets`: the targets defined by the document
+func shouldPickTargets(opts options.Options, targets []string) bool {
+	if len(targets) < 2 || len(opts.Targets) > 0 || opts.All || opts.NoInteractive || opts.NoInput != "" || opts.Watch {
+		return false
+	}
+	return isInteractive()
+}
+
+// pickTargets asks the user which of the document's targets to build. The answer is a
+// list of numbers, ranges, or target names separated by commas or spaces; an empty
+// answer (or end of input) builds everything, and "none" builds nothing.
+//
+// Parameters:
+//   - `targets`: the available targets
+//   - `r`: the input reader (usually stdin)
+//   - `w`: the output writer (usually stderr)
+//
+// Returns:
+//   - []string: the selected targets, in the document's order
+func pickTargets(targets []string, r io.Reader, w io.Writer) []string {
+	_, _ = fmt.Fprintf(w, "This document defines %d targets:\n", len(targets))
+	for i, t := range targets {
+		_, _ = fmt.Fprintf(w, "  %d) %s\n", i+1, t)
+	}
+
+	reader := bufio.NewReader(r)
+	for {
+		_, _ = fmt.Fprint(w, "Build which? (e.g. 1,3 or 1-2 or pdf; Enter for all, \"none\" to cancel): ")
+		response, err := reader.ReadString('\n')
+		response = strings.TrimSpace(response)
+		if response == "" {
+			if err != nil {
+				_, _ = fmt.Fprintln(w)
+			}
+			return targets
+		}
+		selected, perr := parseSelection(response, targets)
+		if perr == nil {
+			return selected
+		}
+		_, _ = fmt.Fprintf(w, "%v\n", perr)
+		if err != nil {
+			return targets
+		}
+	}
+}
+
+// parseSelection turns a target picker answer into the selected targets.
+//
+// Parameters:
+//   - `answer`: the user's answer
+//   - `targets`: the available targets
+func parseSelection(answer string, targets []string) ([]string, error) {
+	if strings.EqualFold(answer, "none") {
+		return []string{}, nil
+	}
+	if strings.EqualFold(answer, "all") {
+		return targets, nil
+	}
+
+	chosen := make([]bool, len(targets))
+	fields := strings.FieldsFunc(answer, func(r rune) bool { return r == ',' || r == ' ' })
+	for _, f := range fields {
+		lo, hi, isRange := strings.Cut(f, "-")
+		if n, err := strconv.Atoi(lo); err == nil {
+			m := n
+			if isRange {
+				if m, err = strconv.Atoi(hi); err != nil {
+					return nil, fmt.Errorf("invalid range %q", f)
+				}
+			}
+			if n < 1 || m > len(targets) || n > m {
+				return nil, fmt.Errorf("%q is out of range 1-%d", f, len(targets))
+			}
+			for i := n; i <= m; i++ {
+				chosen[i-1] = true
+			}
+			continue
+		}
+		found := false
+		for i, t := range targets {
+			if t == f {
+				chosen[i] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown target %q", f)
+		}
+	}
+
+	var selected []string
+	for i, ok := range chosen {
+		if ok {
+			selected = append(selected, targets[i])
+		}
+	}
+	return selected, nil
+}
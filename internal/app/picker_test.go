@@ -0,0 +1,112 @@
+package app
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/options"
+)
+
+func TestParseSelection(t *testing.T) {
+	targets := []string{"docx", "html", "pdf", "epub"}
+	tests := []struct {
+		answer  string
+		want    []string
+		wantErr bool
+	}{
+		{"1,3", []string{"docx", "pdf"}, false},
+		{"2-4", []string{"html", "pdf", "epub"}, false},
+		{"pdf html", []string{"html", "pdf"}, false},
+		{"3, 1", []string{"docx", "pdf"}, false},
+		{"all", targets, false},
+		{"none", []string{}, false},
+		{"5", nil, true},
+		{"3-2", nil, true},
+		{"odt", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseSelection(tt.answer, targets)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSelection(%q) error = %v, wantErr %v", tt.answer, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSelection(%q) = %v, want %v", tt.answer, got, tt.want)
+		}
+	}
+}
+
+func TestPickTargets(t *testing.T) {
+	targets := []string{"html", "pdf"}
+	var w bytes.Buffer
+	got := pickTargets(targets, strings.NewReader("7\n2\n"), &w)
+	if !reflect.DeepEqual(got, []string{"pdf"}) {
+		t.Errorf("pickTargets = %v, want [pdf]", got)
+	}
+	if !strings.Contains(w.String(), "  1) html") || !strings.Contains(w.String(), "out of range") {
+		t.Errorf("unexpected prompt output:\n%s", w.String())
+	}
+
+	if got := pickTargets(targets, strings.NewReader("\n"), &w); !reflect.DeepEqual(got, targets) {
+		t.Errorf("empty answer should select all, got %v", got)
+	}
+	if got := pickTargets(targets, strings.NewReader(""), &w); !reflect.DeepEqual(got, targets) {
+		t.Errorf("end of input should select all, got %v", got)
+	}
+}
+
+func TestShouldPickTargets(t *testing.T) {
+	orig := isInteractive
+	defer func() { isInteractive = orig }()
+	isInteractive = func() bool { return true }
+
+	two := []string{"html", "pdf"}
+	tests := []struct {
+		name    string
+		opts    options.Options
+		targets []string
+		want    bool
+	}{
+		{"several targets", options.Options{}, two, true},
+		{"single target", options.Options{}, []string{"html"}, false},
+		{"explicit targets", options.Options{Targets: []string{"pdf"}}, two, false},
+		{"all", options.Options{All: true}, two, false},
+		{"no-interactive", options.Options{NoInteractive: true}, two, false},
+		{"no-input", options.Options{NoInput: noInputSkip}, two, false},
+		{"watch", options.Options{Watch: true}, two, false},
+	}
+	for _, tt := range tests {
+		if got := shouldPickTargets(tt.opts, tt.targets); got != tt.want {
+			t.Errorf("%s: shouldPickTargets = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	isInteractive = func() bool { return false }
+	if shouldPickTargets(options.Options{}, two) {
+		t.Error("should not prompt without a terminal")
+	}
+}
+
+func TestNoInputMode(t *testing.T) {
+	orig := isInteractive
+	defer func() { isInteractive = orig }()
+
+	isInteractive = func() bool { return true }
+	if mode, err := noInputMode(options.Options{}); mode != "" || err != nil {
+		t.Errorf("noInputMode() in a terminal = %q, %v", mode, err)
+	}
+	if mode, _ := noInputMode(options.Options{NoInput: noInputFail}); mode != noInputFail {
+		t.Errorf("noInputMode(fail) = %q", mode)
+	}
+	if _, err := noInputMode(options.Options{NoInput: "ask"}); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+
+	// Without a terminal prompts are skipped rather than waiting for input
+	isInteractive = func() bool { return false }
+	if mode, _ := noInputMode(options.Options{}); mode != noInputSkip {
+		t.Errorf("noInputMode() without a terminal = %q", mode)
+	}
+}
@@ -0,0 +1,174 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/rapjul/panforge/internal/options"
+	"github.com/rapjul/panforge/internal/preprocess"
+)
+
+// preprocessStep is one entry of the `preprocess` list: either a built-in or a shell command.
+type preprocessStep struct {
+	// Builtin is the name of a built-in step (see preprocess.BuiltinNames).
+	Builtin string
+	// Run is a shell command that prints the transformed document; {input} is replaced by its path.
+	Run string
+}
+
+// String describes the step for logs and errors.
+func (s preprocessStep) String() string {
+	if s.Builtin != "" {
+		return "builtin " + s.Builtin
+	}
+	return s.Run
+}
+
+// parsePreprocessSteps reads the `preprocess` list. A string entry names a built-in if one
+// exists and is otherwise a shell command; map entries use a `builtin` or `run` key.
+//
+// Parameters:
+//   - `raw`: the `preprocess` config value
+//
+// Returns:
+//   - []preprocessStep: the steps in order
+//   - error: if an entry is malformed or names an unknown built-in
+func parsePreprocessSteps(raw interface{}) ([]preprocessStep, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("preprocess must be a list of steps")
+	}
+	steps := make([]preprocessStep, 0, len(list))
+	for i, item := range list {
+		var step preprocessStep
+		switch v := item.(type) {
+		case string:
+			if _, ok := preprocess.LookupBuiltin(v); ok {
+				step.Builtin = v
+			} else {
+				step.Run = v
+			}
+		case map[string]interface{}:
+			step.Builtin, _ = v["builtin"].(string)
+			step.Run, _ = v["run"].(string)
+			if step.Builtin != "" {
+				if _, ok := preprocess.LookupBuiltin(step.Builtin); !ok {
+					return nil, fmt.Errorf("preprocess step %d: unknown builtin %q (available: %s)",
+						i+1, step.Builtin, strings.Join(preprocess.BuiltinNames(), ", "))
+				}
+			}
+		}
+		if (step.Builtin == "") == (step.Run == "") {
+			return nil, fmt.Errorf("preprocess step %d must be a builtin name, a command, or a map with builtin or run", i+1)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// runPreprocess applies the preprocessing steps to a temporary copy of the document.
+// Commands run through the executor, so dry-run prints them without running them (the
+// copy is then left unchanged). In sandbox mode commands are skipped.
+//
+// Parameters:
+//   - `ctx`: context for cancellation
+//   - `steps`: the steps to run in order
+//   - `file`: the temporary copy, rewritten after each step
+//   - `origFile`: the original input, used to resolve relative paths in built-ins
+//   - `opts`: runtime options
+//   - `executor`: used to run commands
+//   - `sandboxed`: whether external commands are blocked
+func runPreprocess(ctx context.Context, steps []preprocessStep, file, origFile string, opts options.Options, executor CommandExecutor, sandboxed bool) error {
+	for _, step := range steps {
+		//nolint:gosec // G304: reading the temporary copy
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read preprocessed file: %w", err)
+		}
+
+		var content string
+		if step.Builtin != "" {
+			fn, _ := preprocess.LookupBuiltin(step.Builtin)
+			if opts.Logger != nil {
+				opts.Logger.Debug("preprocessing", "step", step.String())
+			}
+			content, err = fn(string(data), origFile)
+			if err != nil {
+				return fmt.Errorf("preprocess %s: %w", step, err)
+			}
+		} else {
+			if sandboxed {
+				if opts.Logger != nil {
+					opts.Logger.Warn("sandbox: skipping preprocess command", "command", step.Run)
+				} else {
+					fmt.Fprintf(os.Stderr, "Warning: sandbox: skipping preprocess command %s\n", step.Run)
+				}
+				continue
+			}
+			var stdout bytes.Buffer
+			if err := runShell(ctx, executor, opts, expandPathPlaceholder(step.Run, "{input}", file), &stdout); err != nil {
+				return fmt.Errorf("preprocess %q failed: %w", step.Run, err)
+			}
+			if opts.DryRun {
+				continue
+			}
+			content = stdout.String()
+		}
+
+		//nolint:gosec // G306: the copy is removed after conversion
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to write preprocessed file: %w", err)
+		}
+	}
+	return nil
+}
+
+// runShell runs a command line through the platform shell using the executor, logging
+// it like pandoc invocations so it shows up in dry-run output.
+//
+// Parameters:
+//   - `ctx`: context for cancellation
+//   - `executor`: used to run the shell
+//   - `opts`: runtime options (logging)
+//   - `cmdLine`: the command line
+//   - `stdout`: where the command's standard output goes
+func runShell(ctx context.Context, executor CommandExecutor, opts options.Options, cmdLine string, stdout io.Writer) error {
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+	args := []string{flag, cmdLine}
+	cmdStr := formatCommand(shell, args)
+	echoCommand(opts, cmdStr)
+	return executor.Run(ctx, shell, args, stdout, os.Stderr)
+}
+
+// expandPathPlaceholder substitutes a quoted path for a placeholder in a command line,
+// or appends the path if the placeholder is absent.
+//
+// Parameters:
+//   - `cmdLine`: the configured command
+//   - `placeholder`: e.g. "{input}" or "{output}"
+//   - `path`: the file path to insert
+func expandPathPlaceholder(cmdLine, placeholder, path string) string {
+	if strings.Contains(cmdLine, placeholder) {
+		return strings.ReplaceAll(cmdLine, placeholder, shellQuote(path))
+	}
+	return cmdLine + " " + shellQuote(path)
+}
+
+// shellQuote quotes a path for the platform shell used by preprocess commands.
+func shellQuote(s string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + s + `"`
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,84 @@
+package app
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/options"
+)
+
+// upperExecutor simulates a shell filter that upper-cases the file named in the command.
+type upperExecutor struct {
+	commands []string
+}
+
+func (e *upperExecutor) Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	e.commands = append(e.commands, name+" "+strings.Join(args, " "))
+	cmdLine := args[len(args)-1]
+	path := cmdLine[strings.Index(cmdLine, "'")+1 : strings.LastIndex(cmdLine, "'")]
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(stdout, strings.ToUpper(string(data)))
+	return err
+}
+
+func TestParsePreprocessSteps(t *testing.T) {
+	steps, err := parsePreprocessSteps([]interface{}{"envsubst", "sed -e s/a/b/", map[string]interface{}{"builtin": "strip-comments"}, map[string]interface{}{"run": "cat {input}"}})
+	if err != nil {
+		t.Fatalf("parsePreprocessSteps failed: %v", err)
+	}
+	if len(steps) != 4 || steps[0].Builtin != "envsubst" || steps[1].Run != "sed -e s/a/b/" || steps[2].Builtin != "strip-comments" || steps[3].Run != "cat {input}" {
+		t.Errorf("unexpected steps: %+v", steps)
+	}
+
+	for _, bad := range []interface{}{"envsubst", []interface{}{map[string]interface{}{"builtin": "nope"}}, []interface{}{map[string]interface{}{}}} {
+		if _, err := parsePreprocessSteps(bad); err == nil {
+			t.Errorf("expected error for %v", bad)
+		}
+	}
+}
+
+func TestRunPreprocess(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "copy.md")
+	t.Setenv("PANFORGE_TEST_NAME", "world")
+
+	steps := []preprocessStep{{Builtin: "envsubst"}, {Run: "my-filter --fast"}}
+	write := func() { _ = os.WriteFile(file, []byte("hello ${PANFORGE_TEST_NAME} costs $5\n"), 0600) }
+
+	write()
+	executor := &upperExecutor{}
+	if err := runPreprocess(context.Background(), steps, file, file, options.Options{Quiet: true}, executor, false); err != nil {
+		t.Fatalf("runPreprocess failed: %v", err)
+	}
+	data, _ := os.ReadFile(file)
+	if string(data) != "HELLO WORLD COSTS $5\n" {
+		t.Errorf("unexpected result %q", data)
+	}
+	if len(executor.commands) != 1 || !strings.HasPrefix(executor.commands[0], "sh -c my-filter --fast '") {
+		t.Errorf("unexpected commands: %v", executor.commands)
+	}
+
+	// Dry-run still runs the executor (which prints nothing) but keeps the content
+	write()
+	if err := runPreprocess(context.Background(), steps, file, file, options.Options{Quiet: true, DryRun: true}, &RealExecutor{DryRun: true}, false); err != nil {
+		t.Fatalf("runPreprocess dry-run failed: %v", err)
+	}
+	if data, _ := os.ReadFile(file); string(data) != "hello world costs $5\n" {
+		t.Errorf("dry-run should only apply built-ins, got %q", data)
+	}
+
+	// Sandbox mode skips commands
+	write()
+	executor = &upperExecutor{}
+	_ = runPreprocess(context.Background(), steps, file, file, options.Options{Quiet: true}, executor, true)
+	if len(executor.commands) != 0 {
+		t.Errorf("expected commands to be skipped in sandbox mode, ran %v", executor.commands)
+	}
+}
@@ -0,0 +1,116 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+	"github.com/rapjul/panforge/internal/postprocess"
+)
+
+// parsePostprocess reads a target's `postprocess` value: a command or a list of commands.
+//
+// Parameters:
+//   - `raw`: the config value
+//
+// Returns:
+//   - []string: the commands in order
+//   - error: if the value is not a string or a list of strings
+func parsePostprocess(raw interface{}) ([]string, error) {
+	switch v := raw.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		cmds := make([]string, 0, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok || s == "" {
+				return nil, fmt.Errorf("postprocess step %d must be a command string", i+1)
+			}
+			cmds = append(cmds, s)
+		}
+		return cmds, nil
+	default:
+		return nil, fmt.Errorf("postprocess must be a command or a list of commands")
+	}
+}
+
+// runPostprocess runs a target's postprocess commands on its output after pandoc succeeded.
+// `{output}` in a command is replaced by the quoted output path; without it, the path is
+// appended. In sandbox mode the commands are skipped.
+//
+// Parameters:
+//   - `ctx`: context for cancellation
+//   - `cmds`: the commands to run in order
+//   - `outputFile`: the generated file
+//   - `opts`: runtime options
+//   - `executor`: used to run the commands
+//   - `sandboxed`: whether external commands are blocked
+//
+// Returns:
+//   - error: the first failing command
+func runPostprocess(ctx context.Context, cmds []string, outputFile string, opts options.Options, executor CommandExecutor, sandboxed bool) error {
+	for _, cmd := range cmds {
+		if sandboxed {
+			if opts.Logger != nil {
+				opts.Logger.Warn("sandbox: skipping postprocess command", "command", cmd)
+			} else {
+				fmt.Fprintf(os.Stderr, "Warning: sandbox: skipping postprocess command %s\n", cmd)
+			}
+			continue
+		}
+		if err := runShell(ctx, executor, opts, expandPathPlaceholder(cmd, "{output}", outputFile), os.Stdout); err != nil {
+			return fmt.Errorf("postprocess %q failed: %w", cmd, err)
+		}
+	}
+	return nil
+}
+
+// runHTMLPostprocess applies the built-in `inline-css` and `minify-html` options to an
+// HTML output. Stylesheets are looked up next to the output first, then next to the input.
+//
+// Parameters:
+//   - `cfg`: the global config
+//   - `metaOut`: the format-specific config
+//   - `fmtStr`: the target pandoc format
+//   - `inputFile`: the converted document
+//   - `outputFile`: the HTML output, rewritten in place
+//   - `opts`: runtime options
+func runHTMLPostprocess(cfg *config.Config, metaOut map[string]interface{}, fmtStr, inputFile, outputFile string, opts options.Options) error {
+	inline := boolSetting(cfg, metaOut, "inline-css")
+	minify := boolSetting(cfg, metaOut, "minify-html")
+	if (!inline && !minify) || !htmlFormats[fmtStr] || opts.DryRun {
+		return nil
+	}
+
+	//nolint:gosec // G304: reading the output file is intended
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		return fmt.Errorf("failed to read output for post-processing: %w", err)
+	}
+	doc := string(data)
+	if inline {
+		var missing []string
+		doc, missing = postprocess.InlineCSS(doc, filepath.Dir(outputFile), filepath.Dir(inputFile))
+		for _, href := range missing {
+			if opts.Logger != nil {
+				opts.Logger.Warn("inline-css: stylesheet not found", "file", outputFile, "href", href)
+			} else {
+				fmt.Fprintf(os.Stderr, "Warning: inline-css: stylesheet %s not found\n", href)
+			}
+		}
+	}
+	if minify {
+		doc = postprocess.MinifyHTML(doc)
+	}
+	//nolint:gosec // G306: outputs use the same permissions pandoc gives them
+	if err := os.WriteFile(outputFile, []byte(doc), 0644); err != nil {
+		return fmt.Errorf("failed to write post-processed output: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+// commandRecorder records shell commands and fails those containing "fail".
+type commandRecorder struct {
+	commands []string
+}
+
+func (r *commandRecorder) Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	cmd := args[len(args)-1]
+	r.commands = append(r.commands, cmd)
+	if strings.Contains(cmd, "fail") {
+		return errors.New("exit status 1")
+	}
+	return nil
+}
+
+func TestParsePostprocess(t *testing.T) {
+	if cmds, err := parsePostprocess("tidy -m"); err != nil || len(cmds) != 1 || cmds[0] != "tidy -m" {
+		t.Errorf("parsePostprocess(string) = %v, %v", cmds, err)
+	}
+	if cmds, err := parsePostprocess([]interface{}{"a", "b {output}"}); err != nil || len(cmds) != 2 {
+		t.Errorf("parsePostprocess(list) = %v, %v", cmds, err)
+	}
+	if _, err := parsePostprocess([]interface{}{1}); err == nil {
+		t.Error("expected error for non-string step")
+	}
+}
+
+func TestRunPostprocess(t *testing.T) {
+	rec := &commandRecorder{}
+	opts := options.Options{Quiet: true}
+	err := runPostprocess(context.Background(), []string{"tidy -m", "svgo {output} -o {output}"}, "/out/doc.html", opts, rec, false)
+	if err != nil {
+		t.Fatalf("runPostprocess failed: %v", err)
+	}
+	want := []string{"tidy -m '/out/doc.html'", "svgo '/out/doc.html' -o '/out/doc.html'"}
+	if strings.Join(rec.commands, "|") != strings.Join(want, "|") {
+		t.Errorf("commands = %v, want %v", rec.commands, want)
+	}
+
+	rec = &commandRecorder{}
+	err = runPostprocess(context.Background(), []string{"fail-step", "never"}, "/out/doc.html", opts, rec, false)
+	if err == nil || !strings.Contains(err.Error(), `"fail-step"`) || len(rec.commands) != 1 {
+		t.Errorf("expected the first failure to stop the pipeline: %v, %v", err, rec.commands)
+	}
+
+	rec = &commandRecorder{}
+	_ = runPostprocess(context.Background(), []string{"tidy"}, "/out/doc.html", opts, rec, true)
+	if len(rec.commands) != 0 {
+		t.Errorf("sandbox mode should skip commands, ran %v", rec.commands)
+	}
+}
+
+func TestRunHTMLPostprocess(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "doc.md")
+	output := filepath.Join(dir, "out", "doc.html")
+	_ = os.MkdirAll(filepath.Dir(output), 0750)
+	_ = os.WriteFile(filepath.Join(dir, "style.css"), []byte("body { color : red; }"), 0644)
+	html := "<html>\n  <head>\n    <link rel=\"stylesheet\" href=\"style.css\">\n  </head>\n  <body>\n    <p>Hi</p>\n  </body>\n</html>\n"
+	_ = os.WriteFile(output, []byte(html), 0644)
+
+	cfg := &config.Config{Generic: map[string]interface{}{"minify-html": true}}
+	opts := options.Options{Quiet: true}
+
+	// Not applied to non-HTML targets
+	if err := runHTMLPostprocess(cfg, map[string]interface{}{"inline-css": true}, "docx", input, output, opts); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(output); string(data) != html {
+		t.Errorf("docx output should be untouched, got %q", data)
+	}
+
+	if err := runHTMLPostprocess(cfg, map[string]interface{}{"inline-css": true}, "html", input, output, opts); err != nil {
+		t.Fatalf("runHTMLPostprocess failed: %v", err)
+	}
+	data, _ := os.ReadFile(output)
+	want := "<html><head><style>body{color:red}</style></head><body><p>Hi</p></body></html>"
+	if string(data) != want {
+		t.Errorf("got %q, want %q", data, want)
+	}
+}
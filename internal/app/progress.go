@@ -0,0 +1,231 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+	"github.com/rapjul/panforge/internal/utils"
+)
+
+// progressTerminal reports whether the progress view can be drawn on stderr; replaced in tests.
+var progressTerminal = func() bool {
+	return utils.IsTerminal(os.Stderr) && os.Getenv("TERM") != "dumb"
+}
+
+// progressInterval is how often the progress view is redrawn.
+const progressInterval = 100 * time.Millisecond
+
+// spinnerFrames animate the targets that are running.
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// Statuses of the progress view for targets that have not finished: every target waits
+// for a free pandoc slot, then runs.
+const (
+	statusWaiting = "waiting"
+	statusRunning = "running"
+)
+
+// progressLine is the state of one target in the progress view.
+type progressLine struct {
+	label  string
+	status string
+	start  time.Time
+	end    time.Time
+}
+
+// progress is a live view of the targets of a run, one line per target with its status
+// and elapsed time, redrawn in place on a terminal. Other output goes through pause, so
+// it is printed above the view instead of through it.
+type progress struct {
+	w     io.Writer
+	mu    sync.Mutex
+	lines []*progressLine
+	index map[string]*progressLine
+	drawn int
+	frame int
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// newProgress returns a progress view for the targets of a run, or nil if the run should
+// log plainly: with a single target, when stderr is not a terminal, with --quiet,
+// --verbose, --dry-run, or --no-progress, and when pandoc's output is streamed.
+//
+// Parameters:
+//   - `opts`: runtime options
+//   - `cfg`: the document config
+//   - `labels`: the targets of the run
+//   - `env`: the shared build state
+func newProgress(opts options.Options, cfg *config.Config, labels []string, env processEnv) *progress {
+	if len(labels) < 2 || opts.Quiet || opts.Verbose || opts.DryRun || opts.NoProgress || env.workspace != nil {
+		return nil
+	}
+	if opts.SubprocessOutput == subprocessStream || (opts.SubprocessOutput == "" && stringSetting(cfg, nil, "subprocess-output") == subprocessStream) {
+		return nil
+	}
+	if !progressTerminal() {
+		return nil
+	}
+	p := &progress{w: os.Stderr, index: map[string]*progressLine{}}
+	for _, label := range labels {
+		line := &progressLine{label: label, status: statusWaiting}
+		p.lines = append(p.lines, line)
+		p.index[label] = line
+	}
+	return p
+}
+
+// start draws the view and keeps redrawing it until stop is called.
+func (p *progress) start() {
+	if p == nil {
+		return
+	}
+	p.done = make(chan struct{})
+	p.mu.Lock()
+	p.draw()
+	p.mu.Unlock()
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.done:
+				return
+			case <-ticker.C:
+				p.mu.Lock()
+				p.frame++
+				p.clear()
+				p.draw()
+				p.mu.Unlock()
+			}
+		}
+	}()
+}
+
+// stop draws the final state of every target and leaves it on screen.
+func (p *progress) stop() {
+	if p == nil {
+		return
+	}
+	close(p.done)
+	p.wg.Wait()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+	p.draw()
+}
+
+// set changes the status of a target.
+//
+// Parameters:
+//   - `label`: the target
+//   - `status`: statusRunning or one of the result statuses
+func (p *progress) set(label, status string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	line := p.index[label]
+	if line == nil {
+		return
+	}
+	now := time.Now()
+	if status == statusRunning {
+		line.start = now
+	} else if line.start.IsZero() {
+		line.start = now
+	}
+	if status != statusRunning {
+		line.end = now
+	}
+	line.status = status
+}
+
+// pause removes the view while fn prints other output or prompts, then draws it again
+// below that output. It runs fn directly without a view.
+func (p *progress) pause(fn func()) {
+	if p == nil {
+		fn()
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+	fn()
+	p.draw()
+}
+
+// clear erases the lines drawn last.
+func (p *progress) clear() {
+	if p.drawn > 0 {
+		// Move up over the view and erase to the end of the screen
+		_, _ = fmt.Fprintf(p.w, "\x1b[%dA\x1b[J", p.drawn)
+		p.drawn = 0
+	}
+}
+
+// draw prints one line per target.
+func (p *progress) draw() {
+	width := 0
+	for _, line := range p.lines {
+		width = max(width, len(line.label))
+	}
+	var sb strings.Builder
+	now := time.Now()
+	for _, line := range p.lines {
+		icon, elapsed := " ", ""
+		switch line.status {
+		case statusWaiting:
+			icon = "·"
+		case statusRunning:
+			icon = spinnerFrames[p.frame%len(spinnerFrames)]
+			elapsed = now.Sub(line.start).Round(100 * time.Millisecond).String()
+		case StatusSuccess:
+			icon = "✓"
+		case StatusFailed:
+			icon = "✗"
+		case StatusSkipped, StatusUpToDate:
+			icon = "-"
+		}
+		if !line.end.IsZero() {
+			elapsed = line.end.Sub(line.start).Round(100 * time.Millisecond).String()
+		}
+		fmt.Fprintf(&sb, "%s %-*s  %-10s %s\n", icon, width, line.label, line.status, elapsed)
+	}
+	_, _ = io.WriteString(p.w, sb.String())
+	p.drawn = len(p.lines)
+}
+
+// progressHandler is a slog.Handler that prints log records above the progress view.
+type progressHandler struct {
+	slog.Handler
+	p *progress
+}
+
+// Handle pauses the view while the record is written.
+func (h progressHandler) Handle(ctx context.Context, r slog.Record) error {
+	var err error
+	h.p.pause(func() { err = h.Handler.Handle(ctx, r) })
+	return err
+}
+
+// WithAttrs keeps the handler above the view.
+func (h progressHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return progressHandler{Handler: h.Handler.WithAttrs(attrs), p: h.p}
+}
+
+// WithGroup keeps the handler above the view.
+func (h progressHandler) WithGroup(name string) slog.Handler {
+	return progressHandler{Handler: h.Handler.WithGroup(name), p: h.p}
+}
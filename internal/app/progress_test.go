@@ -0,0 +1,62 @@
+package app
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+func TestNewProgress(t *testing.T) {
+	restore := progressTerminal
+	defer func() { progressTerminal = restore }()
+	progressTerminal = func() bool { return true }
+	cfg := &config.Config{Generic: map[string]interface{}{}}
+	labels := []string{"html", "pdf"}
+
+	if newProgress(options.Options{}, cfg, labels, processEnv{}) == nil {
+		t.Error("expected a progress view for several targets on a terminal")
+	}
+	for _, opts := range []options.Options{{Quiet: true}, {Verbose: true}, {NoProgress: true}, {SubprocessOutput: subprocessStream}} {
+		if newProgress(opts, cfg, labels, processEnv{}) != nil {
+			t.Errorf("expected plain logs with %+v", opts)
+		}
+	}
+	if newProgress(options.Options{}, cfg, labels[:1], processEnv{}) != nil {
+		t.Error("expected plain logs for a single target")
+	}
+	progressTerminal = func() bool { return false }
+	if newProgress(options.Options{}, cfg, labels, processEnv{}) != nil {
+		t.Error("expected plain logs without a terminal")
+	}
+}
+
+func TestProgress(t *testing.T) {
+	restore := progressTerminal
+	defer func() { progressTerminal = restore }()
+	progressTerminal = func() bool { return true }
+
+	p := newProgress(options.Options{}, &config.Config{}, []string{"html", "pdf[profile=final]"}, processEnv{})
+	var buf bytes.Buffer
+	p.w = &buf
+	p.start()
+	p.set("html", statusRunning)
+	p.pause(func() { buf.WriteString("a log line\n") })
+	p.set("html", StatusSuccess)
+	p.set("pdf[profile=final]", StatusFailed)
+	p.stop()
+
+	out := buf.String()
+	if !strings.Contains(out, "\x1b[2A\x1b[J") {
+		t.Errorf("expected the view to be redrawn in place:\n%q", out)
+	}
+	last := out[strings.LastIndex(out, "\x1b[J")+len("\x1b[J"):]
+	if !strings.HasPrefix(last, "✓ html                success") || !strings.Contains(last, "✗ pdf[profile=final]  failed") {
+		t.Errorf("unexpected final view:\n%s", last)
+	}
+	if log := strings.Index(out, "a log line"); log < 0 || !strings.Contains(out[log:], "· pdf") {
+		t.Errorf("expected the view to be drawn again below other output:\n%q", out)
+	}
+}
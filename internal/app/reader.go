@@ -0,0 +1,125 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+	"github.com/rapjul/panforge/internal/pandoc"
+)
+
+// readerOptions collects the `from-options` entry for one input format. Target-level
+// entries override the global ones key by key.
+//
+// Parameters:
+//   - `cfg`: the global config
+//   - `metaOut`: the format-specific config
+//   - `readerFmt`: the input format (e.g. "docx", "markdown")
+//
+// Returns:
+//   - map[string]interface{}: the merged reader options (empty if none are configured)
+func readerOptions(cfg *config.Config, metaOut map[string]interface{}, readerFmt string) map[string]interface{} {
+	merged := make(map[string]interface{})
+	for _, layer := range []interface{}{cfg.Generic["from-options"], metaOut["from-options"]} {
+		byFormat, ok := layer.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		opts, ok := byFormat[readerFmt].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for k, v := range opts {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// inputFormat resolves the pandoc reader of a target: --from, else the target's `from`
+// key, else the global one, else the format the input's extension implies. Extensions
+// may be given too (e.g. "markdown+smart"). Markdown, pandoc's default, is left to pandoc.
+//
+// Parameters:
+//   - `opts`: runtime options
+//   - `cfg`: the global config
+//   - `metaOut`: the format-specific config
+//   - `inputFile`: the source document
+//
+// Returns:
+//   - string: the reader, or "" to let pandoc pick one
+func inputFormat(opts options.Options, cfg *config.Config, metaOut map[string]interface{}, inputFile string) string {
+	if opts.From != "" {
+		return opts.From
+	}
+	if from := stringSetting(cfg, metaOut, "from"); from != "" {
+		return from
+	}
+	if detected := sourceFormat(inputFile, ""); detected != "markdown" {
+		return detected
+	}
+	return ""
+}
+
+// readerArgs returns the pandoc arguments for the input format and the reader options
+// configured for it. An explicit format is passed with --from; otherwise the input
+// file's extension selects the reader. An `extensions` list (e.g. ["+smart",
+// "-auto_identifiers"]) is appended to the --from value; all other keys become reader
+// flags such as --track-changes or --tab-stop.
+//
+// Parameters:
+//   - `cfg`: the global config
+//   - `metaOut`: the format-specific config
+//   - `inputFile`: the source document, whose extension selects the reader
+//   - `from`: the explicit input format, or ""
+//
+// Returns:
+//   - []string: the reader arguments (nil if no options apply)
+func readerArgs(cfg *config.Config, metaOut map[string]interface{}, inputFile, from string) []string {
+	readerFmt := sourceFormat(inputFile, from)
+	if readerFmt == "" {
+		return nil
+	}
+	opts := readerOptions(cfg, metaOut, readerFmt)
+	if len(opts) == 0 && from == "" {
+		return nil
+	}
+
+	var args []string
+	exts, hasExts := opts["extensions"]
+	if hasExts || from != "" {
+		delete(opts, "extensions")
+		var sb strings.Builder
+		if from != "" {
+			sb.WriteString(from)
+		} else {
+			sb.WriteString(readerFmt)
+		}
+		for _, ext := range toStringSlice(exts) {
+			if !strings.HasPrefix(ext, "+") && !strings.HasPrefix(ext, "-") {
+				ext = "+" + ext
+			}
+			sb.WriteString(ext)
+		}
+		args = append(args, "--from", sb.String())
+	}
+	return append(args, pandoc.GetArgs(opts)...)
+}
+
+// toStringSlice converts a YAML scalar or list into a slice of strings.
+func toStringSlice(v interface{}) []string {
+	switch val := v.(type) {
+	case string:
+		return strings.Fields(val)
+	case []interface{}:
+		out := make([]string, 0, len(val))
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+func TestReaderArgs(t *testing.T) {
+	cfg := &config.Config{
+		Generic: map[string]interface{}{
+			"from-options": map[string]interface{}{
+				"docx":     map[string]interface{}{"track-changes": "all"},
+				"markdown": map[string]interface{}{"extensions": []interface{}{"+smart", "auto_identifiers"}},
+				"org":      map[string]interface{}{"tab-stop": 2},
+			},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		from    string
+		metaOut map[string]interface{}
+		want    string
+	}{
+		{"docx reader", "review.docx", "", nil, "--track-changes all"},
+		{"markdown extensions", "notes.md", "", nil, "--from markdown+smart+auto_identifiers"},
+		{"org reader", "plan.org", "", nil, "--tab-stop 2"},
+		{"unconfigured format", "page.rst", "", nil, ""},
+		{"unknown extension", "data.bin", "", nil, ""},
+		{"explicit format", "page.txt", "rst", nil, "--from rst"},
+		{"explicit format with options", "notes.txt", "markdown", nil, "--from markdown+smart+auto_identifiers"},
+		{"explicit extensions", "notes.txt", "markdown-smart", nil, "--from markdown-smart+smart+auto_identifiers"},
+		{
+			"target override",
+			"review.docx",
+			"",
+			map[string]interface{}{"from-options": map[string]interface{}{"docx": map[string]interface{}{"track-changes": "accept"}}},
+			"--track-changes accept",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := strings.Join(readerArgs(cfg, tt.metaOut, tt.input, tt.from), " ")
+			if got != tt.want {
+				t.Errorf("readerArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInputFormat(t *testing.T) {
+	cfg := &config.Config{Generic: map[string]interface{}{"from": "org"}}
+	if got := inputFormat(options.Options{}, cfg, nil, "doc.md"); got != "org" {
+		t.Errorf("expected the global from, got %q", got)
+	}
+	if got := inputFormat(options.Options{}, cfg, map[string]interface{}{"from": "rst"}, "doc.md"); got != "rst" {
+		t.Errorf("expected the target's from, got %q", got)
+	}
+	if got := inputFormat(options.Options{From: "docx"}, cfg, map[string]interface{}{"from": "rst"}, "doc.md"); got != "docx" {
+		t.Errorf("expected --from to win, got %q", got)
+	}
+	if got := inputFormat(options.Options{}, &config.Config{}, nil, "page.rst"); got != "rst" {
+		t.Errorf("expected the reader of the extension, got %q", got)
+	}
+	if got := inputFormat(options.Options{}, &config.Config{}, nil, "notes.md"); got != "" {
+		t.Errorf("expected Markdown to be left to pandoc, got %q", got)
+	}
+}
@@ -0,0 +1,366 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rapjul/panforge/internal/config"
+)
+
+// recipesDirName holds saved recipes inside the data directory.
+const recipesDirName = "recipes"
+
+// Recipe is a saved, reusable invocation: the input-agnostic flags and pandoc arguments
+// of a run and the settings of the document it converted.
+type Recipe struct {
+	// Saved is when the recipe was saved.
+	Saved time.Time `yaml:"saved"`
+	// From is the document the recipe was saved from.
+	From string `yaml:"from,omitempty"`
+	// Args are panforge flags, as given on the command line.
+	Args []string `yaml:"args,omitempty"`
+	// PandocArgs are passed to pandoc after the recipe's other arguments.
+	PandocArgs []string `yaml:"pandoc-args,omitempty"`
+	// Config holds the document settings, applied like defaults.
+	Config config.Config `yaml:"config,omitempty"`
+}
+
+// recipeSkippedFlags are flags that describe one run rather than a conversion, so they
+// are not saved in a recipe.
+var recipeSkippedFlags = map[string]bool{
+	"output":        true,
+	"dry-run":       true,
+	"recipe":        true,
+	"changed-since": true,
+	"watch":         true,
+}
+
+// recipeMetadataKeys are document metadata that belong to one document, so they are
+// not saved in a recipe.
+var recipeMetadataKeys = []string{"subtitle", "date", "abstract", "keywords", "chapters", "bibliography"}
+
+// recipePath returns where a named recipe is kept.
+//
+// Parameters:
+//   - `dataDir`: the panforge data directory
+//   - `name`: the recipe name
+//
+// Returns:
+//   - string: the recipe file
+//   - error: if the name is empty or contains a path separator
+func recipePath(dataDir, name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid recipe name %q", name)
+	}
+	return filepath.Join(dataDir, recipesDirName, name+".yaml"), nil
+}
+
+// LoadRecipe reads a saved recipe.
+//
+// Parameters:
+//   - `dataDir`: the panforge data directory
+//   - `name`: the recipe name
+//
+// Returns:
+//   - *Recipe: the recipe
+//   - error: if the recipe does not exist or cannot be parsed
+func LoadRecipe(dataDir, name string) (*Recipe, error) {
+	path, err := recipePath(dataDir, name)
+	if err != nil {
+		return nil, err
+	}
+	//nolint:gosec // G304: recipes live in the panforge data directory
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("recipe %q not found (see panforge recipe list)", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var r Recipe
+	if err := yaml.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("error parsing recipe %q: %w", name, err)
+	}
+	return &r, nil
+}
+
+// RecipeNames lists the saved recipes, sorted by name.
+//
+// Parameters:
+//   - `dataDir`: the panforge data directory
+func RecipeNames(dataDir string) []string {
+	matches, _ := filepath.Glob(filepath.Join(dataDir, recipesDirName, "*.yaml"))
+	names := make([]string, 0, len(matches))
+	for _, m := range matches {
+		names = append(names, strings.TrimSuffix(filepath.Base(m), ".yaml"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RunRecipeSave saves the last run as a named recipe. The input file, output, and
+// flags that only make sense for one run (such as --dry-run) are left out; the
+// settings of the converted document are kept without its title, author, and
+// other per-document metadata, and without per-target output names.
+//
+// Parameters:
+//   - `cmd`: the root command, whose flags tell which arguments take a value
+//   - `dataDir`: the panforge data directory
+//   - `name`: the recipe name
+//   - `w`: where the result is reported
+//
+// Returns:
+//   - error: if there is no last run or the recipe cannot be written
+func RunRecipeSave(cmd *cobra.Command, dataDir, name string, w io.Writer) error {
+	path, err := recipePath(dataDir, name)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(filepath.Join(dataDir, lastRunName))
+	if err != nil {
+		return fmt.Errorf("no run to save: convert a document first")
+	}
+	var last LastRun
+	if err := json.Unmarshal(data, &last); err != nil {
+		return fmt.Errorf("failed to read the last run: %w", err)
+	}
+
+	recipe := &Recipe{}
+	args, pandocArgs, base := splitRunArgs(cmd, last.Args)
+	if base != "" {
+		// A run that applied a recipe saves what it applied as well
+		if recipe, err = LoadRecipe(dataDir, base); err != nil {
+			return err
+		}
+	}
+	recipe.Saved = time.Now().Truncate(time.Second)
+	recipe.From = last.Input
+	recipe.Args = append(recipe.Args, args...)
+	recipe.PandocArgs = append(recipe.PandocArgs, pandocArgs...)
+	if cfg, err := loadDocumentConfig(last.Input, ""); err == nil && cfg != nil {
+		stripDocumentSettings(cfg)
+		mergeConfig(cfg, &recipe.Config)
+		recipe.Config = *cfg
+	}
+
+	out, err := yaml.Marshal(recipe)
+	if err != nil {
+		return fmt.Errorf("failed to save recipe: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return fmt.Errorf("failed to save recipe: %w", err)
+	}
+	if err := os.WriteFile(path, out, 0600); err != nil {
+		return fmt.Errorf("failed to save recipe: %w", err)
+	}
+	_, _ = fmt.Fprintf(w, "Saved recipe %s to %s\n", name, path)
+	return nil
+}
+
+// RunRecipeList prints the saved recipes with the flags each one applies.
+//
+// Parameters:
+//   - `dataDir`: the panforge data directory
+//   - `w`: where the list is written
+func RunRecipeList(dataDir string, w io.Writer) error {
+	names := RecipeNames(dataDir)
+	if len(names) == 0 {
+		_, _ = fmt.Fprintln(w, "No recipes saved. Save the last run with: panforge recipe save NAME")
+		return nil
+	}
+	for _, name := range names {
+		r, err := LoadRecipe(dataDir, name)
+		if err != nil {
+			_, _ = fmt.Fprintf(w, "%s\t(%v)\n", name, err)
+			continue
+		}
+		line := strings.Join(r.Args, " ")
+		if len(r.PandocArgs) > 0 {
+			line = strings.TrimSpace(line + " -- " + strings.Join(r.PandocArgs, " "))
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\n", name, line)
+	}
+	return nil
+}
+
+// ApplyRecipe sets the flags saved in a recipe that were not given on the command line,
+// and adds the recipe's pandoc arguments before those of the command line.
+//
+// Parameters:
+//   - `cmd`: the command whose flags are set
+//   - `recipe`: the recipe
+//   - `args`: the command's positional arguments: the input, then pandoc arguments
+//
+// Returns:
+//   - []string: the positional arguments with the recipe's pandoc arguments added
+//   - error: if a saved flag has an invalid value
+func ApplyRecipe(cmd *cobra.Command, recipe *Recipe, args []string) ([]string, error) {
+	fs := cmd.Flags()
+	flags, _ := parseFlagArgs(cmd, recipe.Args)
+	given := map[string]bool{}
+	for _, f := range flags {
+		given[f.name] = fs.Changed(f.name)
+	}
+	for _, f := range flags {
+		if given[f.name] {
+			continue
+		}
+		if err := fs.Set(f.name, f.value); err != nil {
+			return nil, fmt.Errorf("recipe: --%s: %w", f.name, err)
+		}
+	}
+
+	if len(recipe.PandocArgs) == 0 {
+		return args, nil
+	}
+	for i, arg := range args {
+		if arg == "-" || !strings.HasPrefix(arg, "-") {
+			out := append(append([]string(nil), args[:i+1]...), recipe.PandocArgs...)
+			return append(out, args[i+1:]...), nil
+		}
+	}
+	return args, nil
+}
+
+// flagArg is one flag of a command line.
+type flagArg struct {
+	name  string
+	value string
+	// tokens are the arguments the flag was written as.
+	tokens []string
+}
+
+// parseFlagArgs parses a command line using a command's flag definitions.
+//
+// Parameters:
+//   - `cmd`: the command that defines the flags
+//   - `args`: the arguments, without the program name and anything after `--`
+//
+// Returns:
+//   - []flagArg: the known flags, in order
+//   - []string: the other arguments (positional arguments and unknown flags)
+func parseFlagArgs(cmd *cobra.Command, args []string) ([]flagArg, []string) {
+	fs := cmd.Flags()
+	var flags []flagArg
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case strings.HasPrefix(arg, "--"):
+			name, value, hasValue := strings.Cut(arg[2:], "=")
+			f := fs.Lookup(name)
+			if f == nil {
+				rest = append(rest, arg)
+				continue
+			}
+			fa := flagArg{name: name, value: value, tokens: []string{arg}}
+			if !hasValue {
+				if f.NoOptDefVal != "" {
+					fa.value = f.NoOptDefVal
+				} else if i+1 < len(args) {
+					i++
+					fa.value = args[i]
+					fa.tokens = append(fa.tokens, args[i])
+				}
+			}
+			flags = append(flags, fa)
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			if fs.ShorthandLookup(arg[1:2]) == nil {
+				rest = append(rest, arg)
+				continue
+			}
+			// Shorthands may be combined (-qf) or carry their value (-tpdf)
+			for j := 1; j < len(arg); j++ {
+				f := fs.ShorthandLookup(arg[j : j+1])
+				if f == nil {
+					break
+				}
+				if f.NoOptDefVal != "" {
+					flags = append(flags, flagArg{name: f.Name, value: f.NoOptDefVal, tokens: []string{"-" + arg[j:j+1]}})
+					continue
+				}
+				fa := flagArg{name: f.Name, value: strings.TrimPrefix(arg[j+1:], "=")}
+				if fa.value == "" && i+1 < len(args) {
+					i++
+					fa.value = args[i]
+				}
+				fa.tokens = []string{"-" + arg[j:j+1], fa.value}
+				flags = append(flags, fa)
+				break
+			}
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return flags, rest
+}
+
+// splitRunArgs separates the command line of a run into the panforge flags worth
+// saving in a recipe and the arguments passed on to pandoc.
+//
+// Parameters:
+//   - `cmd`: the root command
+//   - `args`: the run's arguments, without the program name
+//
+// Returns:
+//   - []string: panforge flags, without the input and run-specific flags
+//   - []string: pandoc arguments (after the input or `--`)
+//   - string: the recipe the run applied, if any
+func splitRunArgs(cmd *cobra.Command, args []string) ([]string, []string, string) {
+	var pandocArgs []string
+	for i, arg := range args {
+		if arg == "--" {
+			args, pandocArgs = args[:i], append([]string(nil), args[i+1:]...)
+			break
+		}
+	}
+	flags, rest := parseFlagArgs(cmd, args)
+	// Arguments after the input are passed to pandoc as well
+	if len(rest) > 1 {
+		pandocArgs = append(append([]string(nil), rest[1:]...), pandocArgs...)
+	}
+
+	var kept []string
+	base := ""
+	for _, f := range flags {
+		if f.name == "recipe" {
+			base = f.value
+		}
+		if !recipeSkippedFlags[f.name] {
+			kept = append(kept, f.tokens...)
+		}
+	}
+	return kept, pandocArgs, base
+}
+
+// stripDocumentSettings removes what belongs to one document from its config: its
+// title, author, and other metadata, and the output names of its targets.
+func stripDocumentSettings(cfg *config.Config) {
+	cfg.Title = ""
+	cfg.Author = ""
+	for _, key := range recipeMetadataKeys {
+		delete(cfg.Generic, key)
+	}
+	for name, v := range cfg.OutputMap {
+		if m, ok := v.(map[string]interface{}); ok {
+			if _, ok := m["output"]; ok {
+				copied := make(map[string]interface{}, len(m))
+				for k, val := range m {
+					if k != "output" {
+						copied[k] = val
+					}
+				}
+				cfg.OutputMap[name] = copied
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// recipeCommand returns a command with a few of panforge's flags.
+func recipeCommand() (*cobra.Command, *[]string, *bool, *string) {
+	cmd := &cobra.Command{Use: "panforge"}
+	targets := &[]string{}
+	force := new(bool)
+	backup := new(string)
+	cmd.Flags().StringSliceVarP(targets, "target", "t", nil, "")
+	cmd.Flags().BoolVarP(force, "force", "f", false, "")
+	cmd.Flags().StringP("output", "o", "", "")
+	cmd.Flags().Bool("dry-run", false, "")
+	cmd.Flags().String("recipe", "", "")
+	cmd.Flags().StringVar(backup, "backup", "", "")
+	cmd.Flags().Lookup("backup").NoOptDefVal = "simple"
+	return cmd, targets, force, backup
+}
+
+func TestSplitRunArgs(t *testing.T) {
+	cmd, _, _, _ := recipeCommand()
+	args := []string{"-ft", "pdf", "thesis.md", "-o", "out.pdf", "--dry-run", "--backup", "--recipe=base", "--toc", "--", "--pdf-engine=xelatex"}
+	flags, pandocArgs, base := splitRunArgs(cmd, args)
+	if want := []string{"-f", "-t", "pdf", "--backup"}; !reflect.DeepEqual(flags, want) {
+		t.Errorf("flags = %q, want %q", flags, want)
+	}
+	if want := []string{"--toc", "--pdf-engine=xelatex"}; !reflect.DeepEqual(pandocArgs, want) {
+		t.Errorf("pandoc args = %q, want %q", pandocArgs, want)
+	}
+	if base != "base" {
+		t.Errorf("base recipe = %q", base)
+	}
+}
+
+func TestApplyRecipe(t *testing.T) {
+	cmd, targets, force, backup := recipeCommand()
+	if err := cmd.Flags().Parse([]string{"--backup=timestamp"}); err != nil {
+		t.Fatal(err)
+	}
+	recipe := &Recipe{Args: []string{"-t", "pdf", "-t", "html", "-f", "--backup"}, PandocArgs: []string{"--toc"}}
+	args, err := ApplyRecipe(cmd, recipe, []string{"doc.md", "--number-sections"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*targets, []string{"pdf", "html"}) || !*force {
+		t.Errorf("expected the recipe's flags to be set, got %v %v", *targets, *force)
+	}
+	if *backup != "timestamp" {
+		t.Errorf("a flag given on the command line must win, got %q", *backup)
+	}
+	if want := []string{"doc.md", "--toc", "--number-sections"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %q, want %q", args, want)
+	}
+}
+
+func TestRunRecipeSave(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "thesis.md")
+	_ = os.WriteFile(input, []byte("---\ntitle: Thesis\ndate: today\ntoc: true\noutput:\n  pdf:\n    output: thesis.pdf\n    pdf-engine: xelatex\n---\n# Hi\n"), 0600)
+	last, _ := json.Marshal(LastRun{Args: []string{input, "-t", "pdf", "--", "--toc"}, Input: input})
+	_ = os.WriteFile(filepath.Join(dir, lastRunName), last, 0600)
+
+	cmd, _, _, _ := recipeCommand()
+	var out bytes.Buffer
+	if err := RunRecipeSave(cmd, dir, "thesis-pdf", &out); err != nil {
+		t.Fatalf("RunRecipeSave failed: %v", err)
+	}
+	recipe, err := LoadRecipe(dir, "thesis-pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(recipe.Args, []string{"-t", "pdf"}) || !reflect.DeepEqual(recipe.PandocArgs, []string{"--toc"}) {
+		t.Errorf("recipe args = %q, %q", recipe.Args, recipe.PandocArgs)
+	}
+	pdf, _ := recipe.Config.OutputMap["pdf"].(map[string]interface{})
+	if recipe.Config.Title != "" || recipe.Config.Generic["date"] != nil || pdf["output"] != nil || pdf["pdf-engine"] != "xelatex" {
+		t.Errorf("expected the document's settings without its metadata and output names, got %+v", recipe.Config)
+	}
+
+	out.Reset()
+	if err := RunRecipeList(dir, &out); err != nil || !strings.Contains(out.String(), "thesis-pdf\t-t pdf -- --toc") {
+		t.Errorf("RunRecipeList() = %q, %v", out.String(), err)
+	}
+	if _, err := LoadRecipe(dir, "../escape"); err == nil {
+		t.Error("expected an error for a recipe name with a path")
+	}
+}
@@ -0,0 +1,223 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/rapjul/panforge/internal/options"
+	"github.com/rapjul/panforge/internal/pandoc"
+)
+
+// Formats accepted by --report.
+const (
+	reportJSON = "json"
+	reportYAML = "yaml"
+)
+
+// ReportFormats lists the formats accepted by --report.
+var ReportFormats = []string{reportJSON, reportYAML}
+
+// Report summarizes a run for scripts and CI, written by --report.
+type Report struct {
+	// Generated is when the report was written.
+	Generated time.Time `json:"generated" yaml:"generated"`
+	// Status is the outcome of the run: ok, failed, skipped, config-error, or pandoc-missing.
+	Status string `json:"status" yaml:"status"`
+	// ExitCode is the exit code panforge ends with.
+	ExitCode int `json:"exit_code" yaml:"exit_code"`
+	// Error is the run's error message, if it failed.
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+	// Duration is how long the run took.
+	Duration time.Duration `json:"duration_ns" yaml:"duration_ns"`
+	// Summary counts the targets by status.
+	Summary ReportSummary `json:"summary" yaml:"summary"`
+	// Targets lists every target of the run.
+	Targets []ReportTarget `json:"targets" yaml:"targets"`
+}
+
+// ReportSummary counts the targets of a run by status.
+type ReportSummary struct {
+	Total     int `json:"total" yaml:"total"`
+	Succeeded int `json:"succeeded" yaml:"succeeded"`
+	UpToDate  int `json:"up_to_date" yaml:"up_to_date"`
+	Skipped   int `json:"skipped" yaml:"skipped"`
+	Failed    int `json:"failed" yaml:"failed"`
+}
+
+// ReportTarget describes the outcome of one target.
+type ReportTarget struct {
+	// Input is the converted document.
+	Input string `json:"input,omitempty" yaml:"input,omitempty"`
+	// Target is the target name.
+	Target string `json:"target" yaml:"target"`
+	// Format is the resolved pandoc output format.
+	Format string `json:"format,omitempty" yaml:"format,omitempty"`
+	// Output is the absolute path of the output file.
+	Output string `json:"output,omitempty" yaml:"output,omitempty"`
+	// Status is one of the target statuses (success, skipped, up-to-date, failed).
+	Status string `json:"status" yaml:"status"`
+	// Duration is how long the target took.
+	Duration time.Duration `json:"duration_ns" yaml:"duration_ns"`
+	// Warnings are the warnings pandoc reported, as `file:line:col: warning: message`.
+	Warnings []string `json:"warnings,omitempty" yaml:"warnings,omitempty"`
+	// Errors are the errors pandoc reported and the target's failure, if any.
+	Errors []string `json:"errors,omitempty" yaml:"errors,omitempty"`
+}
+
+// exitStatuses names the outcome of a run for each exit code.
+var exitStatuses = map[int]string{
+	ExitOK:            "ok",
+	ExitFailed:        "failed",
+	ExitConfig:        "config-error",
+	ExitSkipped:       "skipped",
+	ExitPandocMissing: "pandoc-missing",
+}
+
+// reportFormat returns the format of the report a run should write: --report, else the
+// extension of --report-file (.yaml or .yml for YAML, JSON otherwise).
+//
+// Parameters:
+//   - `opts`: runtime options
+//
+// Returns:
+//   - string: the report format ("" if no report is requested)
+//   - error: if the format is not supported
+func reportFormat(opts options.Options) (string, error) {
+	format := strings.ToLower(opts.Report)
+	if format == "" {
+		if opts.ReportFile == "" {
+			return "", nil
+		}
+		switch strings.ToLower(filepath.Ext(opts.ReportFile)) {
+		case ".yaml", ".yml":
+			return reportYAML, nil
+		}
+		return reportJSON, nil
+	}
+	if format != reportJSON && format != reportYAML {
+		return "", fmt.Errorf("invalid --report %q (want %s)", opts.Report, strings.Join(ReportFormats, " or "))
+	}
+	return format, nil
+}
+
+// ReportToStdout reports whether a run writes its report to stdout, so other output
+// should go elsewhere.
+func ReportToStdout(opts options.Options) bool {
+	if opts.ReportFile != "" {
+		return opts.ReportFile == "-"
+	}
+	return opts.Report != ""
+}
+
+// buildReport summarizes the results of a run.
+//
+// Parameters:
+//   - `results`: the results of every target
+//   - `elapsed`: how long the run took
+//   - `err`: the run's error
+//
+// Returns:
+//   - *Report: the report, with targets ordered by input and name
+func buildReport(results []TargetResult, elapsed time.Duration, err error) *Report {
+	code := ExitCode(err)
+	r := &Report{
+		Generated: time.Now(),
+		Status:    exitStatuses[code],
+		ExitCode:  code,
+		Duration:  elapsed,
+		Targets:   []ReportTarget{},
+	}
+	if err != nil && code != ExitSkipped {
+		r.Error = err.Error()
+	}
+	sorted := append([]TargetResult(nil), results...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Input != sorted[j].Input {
+			return sorted[i].Input < sorted[j].Input
+		}
+		return sorted[i].Target < sorted[j].Target
+	})
+	for _, res := range sorted {
+		t := ReportTarget{
+			Input:    res.Input,
+			Target:   res.Target,
+			Format:   res.Format,
+			Output:   res.Output,
+			Status:   res.Status,
+			Duration: res.Duration,
+		}
+		for _, d := range res.Diagnostics {
+			if d.Severity == pandoc.SeverityError {
+				t.Errors = append(t.Errors, d.String())
+			} else {
+				t.Warnings = append(t.Warnings, d.String())
+			}
+		}
+		if res.Error != "" {
+			t.Errors = append(t.Errors, res.Error)
+		}
+		r.Targets = append(r.Targets, t)
+
+		r.Summary.Total++
+		switch res.Status {
+		case StatusSuccess:
+			r.Summary.Succeeded++
+		case StatusUpToDate:
+			r.Summary.UpToDate++
+		case StatusSkipped:
+			r.Summary.Skipped++
+		case StatusFailed:
+			r.Summary.Failed++
+		}
+	}
+	return r
+}
+
+// writeReport writes the report of a run requested with --report or --report-file, to
+// the file or else to stdout.
+//
+// Parameters:
+//   - `opts`: runtime options
+//   - `results`: the results of every target
+//   - `start`: when the run started
+//   - `err`: the run's error
+//   - `stdout`: where the report goes without --report-file
+//
+// Returns:
+//   - error: any error encoding or writing the report
+func writeReport(opts options.Options, results []TargetResult, start time.Time, err error, stdout io.Writer) error {
+	format, ferr := reportFormat(opts)
+	if ferr != nil || format == "" {
+		return ferr
+	}
+	report := buildReport(results, time.Since(start), err)
+
+	var data []byte
+	if format == reportYAML {
+		data, ferr = yaml.Marshal(report)
+	} else {
+		data, ferr = json.MarshalIndent(report, "", "  ")
+		data = append(data, '\n')
+	}
+	if ferr != nil {
+		return fmt.Errorf("failed to encode report: %w", ferr)
+	}
+
+	if ReportToStdout(opts) {
+		_, werr := stdout.Write(data)
+		return werr
+	}
+	//nolint:gosec // G306: the report is meant to be read by other tools
+	if werr := os.WriteFile(opts.ReportFile, data, 0644); werr != nil {
+		return fmt.Errorf("failed to write report: %w", werr)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rapjul/panforge/internal/options"
+	"github.com/rapjul/panforge/internal/pandoc"
+)
+
+func TestReportFormat(t *testing.T) {
+	tests := []struct {
+		opts    options.Options
+		want    string
+		wantErr bool
+	}{
+		{options.Options{}, "", false},
+		{options.Options{Report: "JSON"}, reportJSON, false},
+		{options.Options{ReportFile: "run.yml"}, reportYAML, false},
+		{options.Options{ReportFile: "run.txt"}, reportJSON, false},
+		{options.Options{Report: "xml"}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := reportFormat(tt.opts)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("reportFormat(%+v) = %q, %v; want %q", tt.opts, got, err, tt.want)
+		}
+	}
+}
+
+func TestBuildReport(t *testing.T) {
+	results := []TargetResult{
+		{Target: "pdf", Input: "b.md", Status: StatusFailed, Error: "target pdf: exit status 43"},
+		{Target: "html", Input: "b.md", Status: StatusSuccess, Diagnostics: []pandoc.Diagnostic{{Severity: pandoc.SeverityWarning, Message: "Could not fetch resource"}}},
+		{Target: "html", Input: "a.md", Status: StatusSkipped},
+	}
+	r := buildReport(results, time.Second, errors.New("target pdf: exit status 43"))
+	if r.Status != "failed" || r.ExitCode != ExitFailed || r.Error == "" {
+		t.Errorf("unexpected outcome: %+v", r)
+	}
+	if r.Summary != (ReportSummary{Total: 3, Succeeded: 1, Skipped: 1, Failed: 1}) {
+		t.Errorf("Summary = %+v", r.Summary)
+	}
+	if r.Targets[0].Input != "a.md" || r.Targets[1].Target != "html" || len(r.Targets[1].Warnings) != 1 || len(r.Targets[2].Errors) != 1 {
+		t.Errorf("Targets = %+v", r.Targets)
+	}
+
+	if r := buildReport(results[2:], 0, ErrSkipped); r.Status != "skipped" || r.Error != "" {
+		t.Errorf("a skipped run is not an error: %+v", r)
+	}
+}
+
+func TestRun_Report(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	t.Chdir(dir)
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte("---\noutput:\n  html:\n    output: doc.html\n---\n# Doc\n"), 0600)
+
+	rec := &argsRecorder{inputs: map[string]string{}, args: map[string][]string{}}
+	var out bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetOut(&out)
+	opts := options.Options{Targets: []string{"html"}, NoCache: true, Quiet: true, Report: reportJSON}
+	if err := Run(context.Background(), cmd, []string{input}, opts, rec); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	var r Report
+	if err := json.Unmarshal(out.Bytes(), &r); err != nil {
+		t.Fatalf("expected a JSON report on stdout: %v\n%s", err, out.String())
+	}
+	if r.Status != "ok" || len(r.Targets) != 1 || r.Targets[0].Input != input || r.Targets[0].Status != StatusSuccess {
+		t.Errorf("unexpected report: %+v", r)
+	}
+
+	opts.Report, opts.ReportFile = "", filepath.Join(dir, "report.yaml")
+	opts.Force = true
+	if err := Run(context.Background(), cmd, []string{input}, opts, rec); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	data, err := os.ReadFile(opts.ReportFile)
+	if err != nil || yaml.Unmarshal(data, &r) != nil || !strings.Contains(string(data), "status: ok") {
+		t.Errorf("expected a YAML report file, got %q (%v)", data, err)
+	}
+}
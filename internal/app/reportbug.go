@@ -0,0 +1,469 @@
+package app
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+	"github.com/rapjul/panforge/internal/pandoc"
+	"github.com/rapjul/panforge/internal/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// lastRunName records the most recent conversion inside the data directory.
+const lastRunName = "last-run.json"
+
+// maxRecordedStderr bounds how much of pandoc's stderr is kept per target.
+const maxRecordedStderr = 64 << 10
+
+// maxReportedLog bounds how much of the --log file is included in a bug report.
+const maxReportedLog = 256 << 10
+
+// KnownTools lists the external programs panforge can make use of.
+var KnownTools = []string{
+	"pandoc",
+	"typst",
+	"pdflatex",
+	"xelatex",
+	"lualatex",
+	"tectonic",
+	"wkhtmltopdf",
+	"pandoc-crossref",
+	"rsvg-convert",
+	"mmdc",
+	"plantuml",
+	"gs",
+	"qpdf",
+}
+
+// CheckTool checks whether one of KnownTools is installed; pandoc is the binary selected
+// with --pandoc-path or `pandoc-path`.
+//
+// Parameters:
+//   - `tool`: the tool name
+func CheckTool(tool string) utils.CheckResult {
+	name := tool
+	if tool == "pandoc" {
+		name = pandoc.Binary()
+	}
+	res := utils.CheckTool(name, "")
+	res.Name = tool
+	return res
+}
+
+// LastRun describes the most recent conversion, as recorded for bug reports.
+type LastRun struct {
+	// Started is when the run began.
+	Started time.Time `json:"started"`
+	// Duration is how long the run took.
+	Duration time.Duration `json:"duration_ns"`
+	// Args are the command-line arguments panforge was called with.
+	Args []string `json:"args"`
+	// WorkingDir is the directory panforge ran in.
+	WorkingDir string `json:"working_dir"`
+	// Input is the converted file.
+	Input string `json:"input"`
+	// DryRun is set when no commands were executed.
+	DryRun bool `json:"dry_run,omitempty"`
+	// Log is the --log file of the run, if any.
+	Log string `json:"log,omitempty"`
+	// Error is the run's error message, if it failed.
+	Error string `json:"error,omitempty"`
+	// Targets holds the per-target plan and outcome.
+	Targets []LastRunTarget `json:"targets"`
+}
+
+// LastRunTarget is a target result together with the tail of pandoc's stderr.
+type LastRunTarget struct {
+	TargetResult
+	// Stderr is the end of what pandoc wrote to standard error.
+	Stderr string `json:"stderr,omitempty"`
+}
+
+// ReportBugOptions holds flags for the report-bug command.
+type ReportBugOptions struct {
+	// Input is the document the report is about (optional).
+	Input string
+	// Output is the archive to write; empty uses panforge-report-<timestamp>.zip.
+	Output string
+	// Version is the panforge version string.
+	Version string
+	// Quiet prints only the archive path.
+	Quiet bool
+	// Config is the --config of the runs being reported ("" for the default config).
+	Config string
+}
+
+// recordLastRun saves the plan and outcome of a run to the data directory, so
+// report-bug can include it. Nothing is written if the data directory does not
+// exist; failures are only logged, since recording must never break a build.
+//
+// Parameters:
+//   - `dataDir`: the panforge data directory
+//   - `inputFile`: the converted file
+//   - `opts`: the run's options
+//   - `start`: when the run began
+//   - `results`: the per-target results
+//   - `runErr`: the run's error, if any
+func recordLastRun(dataDir, inputFile string, opts options.Options, start time.Time, results []TargetResult, runErr error) {
+	if !isDir(dataDir) {
+		return
+	}
+	run := LastRun{
+		Started:    start,
+		Duration:   time.Since(start),
+		Args:       os.Args[1:],
+		WorkingDir: workingDir(),
+		Input:      inputFile,
+		DryRun:     opts.DryRun,
+		Log:        opts.Log,
+		Targets:    []LastRunTarget{},
+	}
+	if abs, err := filepath.Abs(inputFile); err == nil {
+		run.Input = abs
+	}
+	if opts.Log != "" {
+		if abs, err := filepath.Abs(opts.Log); err == nil {
+			run.Log = abs
+		}
+	}
+	if runErr != nil {
+		run.Error = runErr.Error()
+	}
+	for _, res := range results {
+		run.Targets = append(run.Targets, LastRunTarget{TargetResult: res, Stderr: res.stderr})
+	}
+	sort.Slice(run.Targets, func(i, j int) bool { return run.Targets[i].Target < run.Targets[j].Target })
+
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(dataDir, lastRunName), append(data, '\n'), 0600)
+	}
+	if err != nil && opts.Logger != nil {
+		opts.Logger.Debug("failed to record last run", "error", err)
+	}
+}
+
+// RunReportBug writes an archive with everything needed to reproduce a
+// conversion problem: panforge and tool versions, OS information, the default
+// and effective configuration, and the plan, stderr, and log of the last run.
+// Values of sensitive keys (tokens, passwords, webhooks, ...) are redacted and
+// the home directory is replaced by "~".
+//
+// Parameters:
+//   - `opts`: report options
+//   - `dataDir`: the panforge data directory
+//   - `w`: where progress messages are written
+//
+// Returns:
+//   - error: if the archive could not be written
+func RunReportBug(opts ReportBugOptions, dataDir string, w io.Writer) error {
+	output := opts.Output
+	if output == "" {
+		output = "panforge-report-" + time.Now().Format("20060102-150405") + ".zip"
+	}
+
+	files := map[string][]byte{
+		"environment.txt": []byte(environmentReport(opts, dataDir)),
+	}
+
+	var defaults []string
+	for _, ext := range config.ConfigExts {
+		matches, _ := filepath.Glob(filepath.Join(dataDir, "*"+ext))
+		defaults = append(defaults, matches...)
+	}
+	for _, path := range defaults {
+		//nolint:gosec // G304: the files come from panforge's own data directory
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		files["config/"+filepath.Base(path)] = redactYAML(data)
+	}
+
+	if opts.Input != "" {
+		//nolint:gosec // G304: Potential file inclusion via variable is intended behavior for CLI file arguments
+		content, err := os.ReadFile(opts.Input)
+		if err != nil {
+			return fmt.Errorf("failed to read input file: %w", err)
+		}
+		if front, _ := config.SplitFrontmatter(string(content)); front != "" {
+			files["document.yaml"] = redactYAML([]byte(front))
+		}
+		if effective, err := effectiveConfig(opts.Input, opts.Config); err == nil {
+			files["effective.yaml"] = effective
+		}
+	}
+
+	//nolint:gosec // G304: the file comes from panforge's own data directory
+	if data, err := os.ReadFile(filepath.Join(dataDir, lastRunName)); err == nil {
+		var run LastRun
+		if json.Unmarshal(data, &run) == nil {
+			files[lastRunName] = redactJSON(data)
+			if run.Log != "" {
+				if logData, err := readTail(run.Log, maxReportedLog); err == nil {
+					files["logs/"+filepath.Base(run.Log)] = []byte(redactString(string(logData)))
+				}
+			}
+		}
+	}
+
+	if err := writeZip(output, files); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(w, "Wrote %s (%d file(s))\n", output, len(files))
+	if !opts.Quiet {
+		_, _ = fmt.Fprintln(w, "Sensitive values are redacted, but paths and document metadata are included; review the archive before attaching it to an issue.")
+	}
+	return nil
+}
+
+// environmentReport describes panforge, the OS, relevant environment variables, and tool versions.
+//
+// Parameters:
+//   - `opts`: report options
+//   - `dataDir`: the panforge data directory
+func environmentReport(opts ReportBugOptions, dataDir string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "panforge: %s\n", opts.Version)
+	fmt.Fprintf(&b, "go: %s\n", runtime.Version())
+	fmt.Fprintf(&b, "os: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&b, "data dir: %s\n", redactString(dataDir))
+	fmt.Fprintf(&b, "working dir: %s\n", redactString(workingDir()))
+	fmt.Fprintf(&b, "generated: %s\n", time.Now().Format(time.RFC3339))
+
+	var env []string
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		if !reportedEnv(name) {
+			continue
+		}
+		if sensitiveKey.MatchString(name) {
+			value = redacted
+		}
+		env = append(env, name+"="+redactString(value))
+	}
+	sort.Strings(env)
+	b.WriteString("\nEnvironment:\n")
+	for _, kv := range env {
+		fmt.Fprintf(&b, "  %s\n", kv)
+	}
+
+	tools := KnownTools
+	if opts.Input != "" {
+		if required, err := GetRequiredTools(opts.Input, options.Options{}); err == nil {
+			tools = append([]string{"pandoc"}, required...)
+		}
+	}
+	b.WriteString("\nTools:\n")
+	seen := make(map[string]bool)
+	for _, tool := range tools {
+		if seen[tool] {
+			continue
+		}
+		seen[tool] = true
+		res := CheckTool(tool)
+		switch {
+		case !res.Found:
+			fmt.Fprintf(&b, "  %s: missing\n", tool)
+		case res.Version != "":
+			fmt.Fprintf(&b, "  %s: %s (%s)\n", tool, res.Version, redactString(res.Path))
+		default:
+			fmt.Fprintf(&b, "  %s: %s\n", tool, redactString(res.Path))
+		}
+	}
+	return b.String()
+}
+
+// reportedEnv reports whether an environment variable can affect a conversion.
+func reportedEnv(name string) bool {
+	for _, prefix := range []string{"PANFORGE", "PANDOC", "TEX", "TYPST", "SOURCE_DATE_EPOCH", "LANG", "LC_"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// effectiveConfig returns the redacted configuration a conversion of inputFile would use.
+//
+// Parameters:
+//   - `inputFile`: the document
+func effectiveConfig(inputFile, configName string) ([]byte, error) {
+	cfg, err := loadDocumentConfig(inputFile, "")
+	if err != nil {
+		return nil, err
+	}
+	_, projectCfg, _ := config.LoadProjectConfig(inputFile)
+	mergeConfig(cfg, projectCfg)
+	_, defaultCfg, _ := loadDefaultConfig(configName)
+	mergeConfig(cfg, defaultCfg)
+	if err := config.ResolveTargetExtends(cfg); err != nil {
+		return nil, err
+	}
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return redactYAML(data), nil
+}
+
+// redacted replaces the values of sensitive settings.
+const redacted = "REDACTED"
+
+var (
+	// sensitiveKey matches setting and variable names whose values must not be shared.
+	sensitiveKey = regexp.MustCompile(`(?i)(token|secret|passw(or)?d|api[-_]?key|authorization|credential|private[-_]?key|webhook|cookie)`)
+	// sensitiveAssign matches name=value and name: value pairs with a sensitive name inside free text.
+	sensitiveAssign = regexp.MustCompile(`(?i)([\w.-]*(?:token|secret|passw(?:or)?d|api[-_]?key|authorization|credential)[\w.-]*\s*[=:]\s*)("[^"]*"|'[^']*'|\S+)`)
+	// urlUserinfo matches credentials embedded in URLs.
+	urlUserinfo = regexp.MustCompile(`(://)[^/@\s]+@`)
+)
+
+// redactValue returns v with the values of sensitive keys replaced, recursively.
+//
+// Parameters:
+//   - `v`: a decoded YAML or JSON value
+func redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			if sensitiveKey.MatchString(k) {
+				out[k] = redacted
+			} else {
+				out[k] = redactValue(item)
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = redactValue(item)
+		}
+		return out
+	case string:
+		return redactString(val)
+	default:
+		return v
+	}
+}
+
+// redactString hides credentials in URLs and name=value pairs, and replaces the home directory with "~".
+//
+// Parameters:
+//   - `s`: the text to redact
+func redactString(s string) string {
+	s = urlUserinfo.ReplaceAllString(s, "${1}"+redacted+"@")
+	s = sensitiveAssign.ReplaceAllString(s, "${1}"+redacted)
+	if home, err := os.UserHomeDir(); err == nil && len(home) > 1 {
+		s = strings.ReplaceAll(s, home, "~")
+	}
+	return s
+}
+
+// redactYAML redacts a YAML document; unparsable input is redacted as plain text.
+//
+// Parameters:
+//   - `data`: the YAML document
+func redactYAML(data []byte) []byte {
+	var v map[string]interface{}
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return []byte(redactString(string(data)))
+	}
+	out, err := yaml.Marshal(redactValue(v))
+	if err != nil {
+		return []byte(redactString(string(data)))
+	}
+	return out
+}
+
+// redactJSON redacts a JSON document; unparsable input is redacted as plain text.
+//
+// Parameters:
+//   - `data`: the JSON document
+func redactJSON(data []byte) []byte {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return []byte(redactString(string(data)))
+	}
+	out, err := json.MarshalIndent(redactValue(v), "", "  ")
+	if err != nil {
+		return []byte(redactString(string(data)))
+	}
+	return append(out, '\n')
+}
+
+// readTail reads at most limit bytes from the end of a file.
+//
+// Parameters:
+//   - `path`: the file to read
+//   - `limit`: the maximum number of bytes
+func readTail(path string, limit int64) ([]byte, error) {
+	f, err := os.Open(path) //nolint:gosec // G304: the path is the log file recorded by panforge
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > limit {
+		if _, err := f.Seek(-limit, io.SeekEnd); err != nil {
+			return nil, err
+		}
+	}
+	return io.ReadAll(f)
+}
+
+// tail returns at most n bytes from the end of s.
+func tail(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[len(s)-n:]
+}
+
+// writeZip writes files into a new zip archive, in name order.
+//
+// Parameters:
+//   - `path`: the archive to create
+//   - `files`: the archive contents by name
+func writeZip(path string, files map[string][]byte) error {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		fw, err := zw.Create(name)
+		if err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+		if _, err := fw.Write(files[name]); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
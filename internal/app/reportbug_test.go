@@ -0,0 +1,111 @@
+package app
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rapjul/panforge/internal/options"
+)
+
+func TestRedactValue(t *testing.T) {
+	in := map[string]interface{}{
+		"author":  "Jane",
+		"webhook": "https://hooks.example.com/abc",
+		"output": map[string]interface{}{
+			"pdf": map[string]interface{}{"owner-password": "hunter2", "pdf-engine": "xelatex"},
+		},
+		"postprocess": []interface{}{"curl -H 'X-Api-Key: abc123' https://user:pw@example.com/upload"},
+	}
+	out := redactValue(in).(map[string]interface{})
+
+	if out["author"] != "Jane" {
+		t.Errorf("author should be kept, got %v", out["author"])
+	}
+	if out["webhook"] != redacted {
+		t.Errorf("webhook should be redacted, got %v", out["webhook"])
+	}
+	pdf := out["output"].(map[string]interface{})["pdf"].(map[string]interface{})
+	if pdf["owner-password"] != redacted || pdf["pdf-engine"] != "xelatex" {
+		t.Errorf("unexpected pdf settings: %v", pdf)
+	}
+	cmd := out["postprocess"].([]interface{})[0].(string)
+	if strings.Contains(cmd, "abc123") || strings.Contains(cmd, "user:pw") {
+		t.Errorf("credentials leaked in %q", cmd)
+	}
+}
+
+func TestRunReportBug(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", dir)
+	dataDir := filepath.Join(dir, "panforge")
+	_ = os.MkdirAll(dataDir, 0750)
+	_ = os.WriteFile(filepath.Join(dataDir, "default.yaml"), []byte("api-token: s3cret\ntoc: true\n"), 0644)
+
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte("---\ntitle: Doc\noutputs: [html]\n---\n# Hi\n"), 0644)
+	logFile := filepath.Join(dir, "run.log")
+	_ = os.WriteFile(logFile, []byte("panforge calling: pandoc doc.md\n"), 0644)
+
+	results := []TargetResult{{Target: "html", Status: StatusFailed, Command: "pandoc doc.md -t html", stderr: "[WARNING] Could not fetch resource"}}
+	recordLastRun(dataDir, input, options.Options{Log: logFile}, time.Now(), results, errors.New("pandoc failed"))
+
+	output := filepath.Join(dir, "report.zip")
+	var out bytes.Buffer
+	if err := RunReportBug(ReportBugOptions{Input: input, Output: output, Version: "1.2.3"}, dataDir, &out); err != nil {
+		t.Fatalf("RunReportBug failed: %v", err)
+	}
+
+	zr, err := zip.OpenReader(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = zr.Close() }()
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		data, _ := io.ReadAll(rc)
+		_ = rc.Close()
+		files[f.Name] = string(data)
+	}
+
+	for _, name := range []string{"environment.txt", "config/default.yaml", "document.yaml", "effective.yaml", lastRunName, "logs/run.log"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("report is missing %s (have %d files)", name, len(files))
+		}
+	}
+	if !strings.Contains(files["environment.txt"], "panforge: 1.2.3") {
+		t.Errorf("environment.txt lacks the version:\n%s", files["environment.txt"])
+	}
+	for name, content := range files {
+		if strings.Contains(content, "s3cret") {
+			t.Errorf("%s leaks a secret:\n%s", name, content)
+		}
+	}
+	if !strings.Contains(files["effective.yaml"], "toc: true") {
+		t.Errorf("effective config should include defaults:\n%s", files["effective.yaml"])
+	}
+
+	var run LastRun
+	if err := json.Unmarshal([]byte(files[lastRunName]), &run); err != nil {
+		t.Fatal(err)
+	}
+	if run.Error != "pandoc failed" || len(run.Targets) != 1 || run.Targets[0].Stderr == "" || run.Targets[0].Command == "" {
+		t.Errorf("unexpected last run: %+v", run)
+	}
+}
+
+func TestRecordLastRun_NoDataDir(t *testing.T) {
+	dataDir := filepath.Join(t.TempDir(), "missing")
+	recordLastRun(dataDir, "doc.md", options.Options{}, time.Now(), nil, nil)
+	if _, err := os.Stat(dataDir); !os.IsNotExist(err) {
+		t.Errorf("data directory should not be created, stat error: %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rapjul/panforge/internal/config"
+)
+
+// resolveReproducible reads the `reproducible` setting of a target. A reproducible
+// build uses one fixed time instead of the clock: SOURCE_DATE_EPOCH if it is set, else
+// the modification time of the input. The target value wins over the global one.
+//
+// Parameters:
+//   - `cfg`: the global config
+//   - `metaOut`: the format-specific config
+//   - `inputFile`: the converted document
+//
+// Returns:
+//   - time.Time: the build time, or the zero time if the build is not reproducible
+//   - error: if the setting or SOURCE_DATE_EPOCH is invalid
+func resolveReproducible(cfg *config.Config, metaOut map[string]interface{}, inputFile string) (time.Time, error) {
+	raw, ok := metaOut["reproducible"]
+	if !ok {
+		raw = cfg.Generic["reproducible"]
+	}
+	switch v := raw.(type) {
+	case nil:
+		return time.Time{}, nil
+	case bool:
+		if !v {
+			return time.Time{}, nil
+		}
+	default:
+		return time.Time{}, fmt.Errorf("reproducible must be true or false")
+	}
+	if epoch := strings.TrimSpace(os.Getenv("SOURCE_DATE_EPOCH")); epoch != "" {
+		secs, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q: must be a number of seconds", epoch)
+		}
+		return time.Unix(secs, 0).UTC(), nil
+	}
+	info, err := os.Stat(inputFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime().Truncate(time.Second).UTC(), nil
+}
+
+// sourceDateEnv returns the environment that makes pandoc and the engines it runs use
+// the build time: pandoc dates EPUB, DOCX, and ODT packages with SOURCE_DATE_EPOCH, the
+// TeX engines and typst date PDFs with it, and FORCE_SOURCE_DATE makes \today use it.
+//
+// Parameters:
+//   - `buildTime`: the build time
+func sourceDateEnv(buildTime time.Time) []string {
+	return []string{
+		"SOURCE_DATE_EPOCH=" + strconv.FormatInt(buildTime.Unix(), 10),
+		"FORCE_SOURCE_DATE=1",
+	}
+}
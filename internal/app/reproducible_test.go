@@ -0,0 +1,84 @@
+package app
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+// envRecorder records the extra environment of every command and writes its output.
+type envRecorder struct {
+	mu   sync.Mutex
+	envs [][]string
+	args [][]string
+}
+
+func (r *envRecorder) Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	return r.RunIn(ctx, "", nil, name, args, stdout, stderr)
+}
+
+func (r *envRecorder) RunIn(ctx context.Context, dir string, env []string, name string, args []string, stdout, stderr io.Writer) error {
+	r.mu.Lock()
+	r.envs = append(r.envs, env)
+	r.args = append(r.args, args)
+	r.mu.Unlock()
+	for i, arg := range args {
+		if arg == "--output" && i+1 < len(args) {
+			return os.WriteFile(args[i+1], []byte(name), 0600)
+		}
+	}
+	return nil
+}
+
+func TestResolveReproducible(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte("# Doc\n"), 0600)
+	mtime := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	_ = os.Chtimes(input, mtime, mtime)
+	cfg := &config.Config{Generic: map[string]interface{}{"reproducible": true}}
+
+	t.Setenv("SOURCE_DATE_EPOCH", "")
+	if got, err := resolveReproducible(cfg, map[string]interface{}{}, input); err != nil || !got.Equal(mtime) {
+		t.Errorf("expected the input's modification time, got %v, %v", got, err)
+	}
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	if got, err := resolveReproducible(cfg, map[string]interface{}{}, input); err != nil || got.Unix() != 1700000000 {
+		t.Errorf("expected SOURCE_DATE_EPOCH, got %v, %v", got, err)
+	}
+	if got, _ := resolveReproducible(cfg, map[string]interface{}{"reproducible": false}, input); !got.IsZero() {
+		t.Errorf("expected the target to turn reproducible builds off, got %v", got)
+	}
+	t.Setenv("SOURCE_DATE_EPOCH", "yesterday")
+	if _, err := resolveReproducible(cfg, map[string]interface{}{}, input); err == nil {
+		t.Error("expected an error for an invalid SOURCE_DATE_EPOCH")
+	}
+}
+
+func TestProcess_Reproducible(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte("---\nreproducible: true\nfilename-template: \"{title}_{date}_{time}.{ext}\"\noutputs: [html]\n---\n# Doc\n"), 0600)
+
+	rec := &envRecorder{}
+	opts := options.Options{NoCache: true, Quiet: true}
+	if _, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.envs) != 1 || !slices.Contains(rec.envs[0], "SOURCE_DATE_EPOCH=1700000000") || !slices.Contains(rec.envs[0], "FORCE_SOURCE_DATE=1") {
+		t.Fatalf("expected pandoc to run with SOURCE_DATE_EPOCH, got %v", rec.envs)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Doc_2023-11-14_22-13-20.html")); err != nil {
+		t.Errorf("expected the name to use SOURCE_DATE_EPOCH: %v (pandoc args %v)", err, rec.args)
+	}
+}
@@ -0,0 +1,87 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rapjul/panforge/internal/pandoc"
+)
+
+// Target statuses recorded in TargetResult.
+const (
+	// StatusSuccess means pandoc produced the output.
+	StatusSuccess = "success"
+	// StatusSkipped means the target was not built (e.g. overwrite declined).
+	StatusSkipped = "skipped"
+	// StatusUpToDate means the build cache showed nothing changed since the last build.
+	StatusUpToDate = "up-to-date"
+	// StatusFailed means building the target returned an error.
+	StatusFailed = "failed"
+)
+
+// TargetResult records the outcome of building a single target.
+type TargetResult struct {
+	// Target is the name requested on the command line or in the config.
+	Target string `json:"target"`
+	// Input is the converted document.
+	Input string `json:"input,omitempty"`
+	// Format is the resolved pandoc output format.
+	Format string `json:"format,omitempty"`
+	// Output is the absolute path of the output file.
+	Output string `json:"output,omitempty"`
+	// Status is one of StatusSuccess, StatusSkipped, StatusUpToDate, or StatusFailed.
+	Status string `json:"status"`
+	// Error holds the failure message, if any.
+	Error string `json:"error,omitempty"`
+	// Duration is how long the target took.
+	Duration time.Duration `json:"duration_ns"`
+	// Diagnostics are the warnings and errors pandoc reported for the target.
+	Diagnostics []pandoc.Diagnostic `json:"diagnostics,omitempty"`
+	// Command is the pandoc command line that produced the output.
+	Command string `json:"command,omitempty"`
+
+	// stderr holds the tail of pandoc's standard error, kept for bug reports.
+	stderr string
+}
+
+// sortedResults returns a copy of the results ordered by target name.
+func sortedResults(results []TargetResult) []TargetResult {
+	sorted := append([]TargetResult(nil), results...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Target < sorted[j].Target })
+	return sorted
+}
+
+// TargetErrors is the error of a --keep-going run: every target that failed, with the
+// number of targets that were attempted.
+type TargetErrors struct {
+	// Failed are the results of the failed targets, ordered by target name.
+	Failed []TargetResult
+	// Total is the number of targets of the run.
+	Total int
+}
+
+// targetErrors returns the failures among results as a TargetErrors, or nil if none failed.
+func targetErrors(results []TargetResult) error {
+	e := &TargetErrors{Total: len(results)}
+	for _, r := range sortedResults(results) {
+		if r.Status == StatusFailed {
+			e.Failed = append(e.Failed, r)
+		}
+	}
+	if len(e.Failed) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Error lists every failed target on its own line.
+func (e *TargetErrors) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d of %d targets failed:", len(e.Failed), e.Total)
+	for _, r := range e.Failed {
+		fmt.Fprintf(&sb, "\n  %s: %s", r.Target, strings.TrimPrefix(r.Error, "target "+r.Target+": "))
+	}
+	return sb.String()
+}
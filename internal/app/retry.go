@@ -0,0 +1,48 @@
+package app
+
+import (
+	"context"
+	"time"
+)
+
+// maxRetryDelay caps the wait between two attempts.
+const maxRetryDelay = 30 * time.Second
+
+// retryDelay returns how long to wait before the nth retry (starting at 1): one second,
+// doubling with every retry; replaced in tests.
+var retryDelay = func(n int) time.Duration {
+	d := time.Second << (n - 1)
+	if d <= 0 || d > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return d
+}
+
+// retry runs fn until it succeeds or has been retried `retries` times, waiting longer
+// before each retry. It stops early once ctx ends, e.g. because another target failed.
+//
+// Parameters:
+//   - `ctx`: context for cancellation
+//   - `retries`: how often a failure is retried (0 runs fn once)
+//   - `fn`: the attempt
+//   - `onRetry`: called with the retry number, the error, and the delay before each retry (may be nil)
+//
+// Returns:
+//   - error: the error of the last attempt, or nil
+func retry(ctx context.Context, retries int, fn func() error, onRetry func(n int, err error, delay time.Duration)) error {
+	for n := 1; ; n++ {
+		err := fn()
+		if err == nil || n > retries || ctx.Err() != nil {
+			return err
+		}
+		delay := retryDelay(n)
+		if onRetry != nil {
+			onRetry(n, err, delay)
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rapjul/panforge/internal/options"
+)
+
+func TestRetry(t *testing.T) {
+	restore := retryDelay
+	defer func() { retryDelay = restore }()
+	retryDelay = func(int) time.Duration { return 0 }
+
+	calls := 0
+	var retries []int
+	err := retry(context.Background(), 3, func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("busy")
+		}
+		return nil
+	}, func(n int, err error, delay time.Duration) { retries = append(retries, n) })
+	if err != nil || calls != 3 || len(retries) != 2 {
+		t.Errorf("retry() = %v after %d calls, retries %v", err, calls, retries)
+	}
+
+	calls = 0
+	if err := retry(context.Background(), 1, func() error { calls++; return errors.New("broken") }, nil); err == nil || calls != 2 {
+		t.Errorf("expected the last error after 2 attempts, got %v after %d", err, calls)
+	}
+
+	// A cancelled run is not retried
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls = 0
+	_ = retry(ctx, 5, func() error { calls++; return errors.New("cancelled") }, nil)
+	if calls != 1 {
+		t.Errorf("expected no retries after cancellation, got %d calls", calls)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	if retryDelay(1) != time.Second || retryDelay(3) != 4*time.Second || retryDelay(10) != maxRetryDelay {
+		t.Errorf("unexpected backoff: %v %v %v", retryDelay(1), retryDelay(3), retryDelay(10))
+	}
+}
+
+// flakyExecutor fails the first `failures` pandoc runs, then writes the output.
+type flakyExecutor struct {
+	mu       sync.Mutex
+	failures int
+	runs     int
+}
+
+func (f *flakyExecutor) Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.runs++
+	if f.runs <= f.failures {
+		return errors.New("exit status 43")
+	}
+	for i, arg := range args {
+		if arg == "--output" && i+1 < len(args) {
+			return os.WriteFile(args[i+1], []byte("converted"), 0600)
+		}
+	}
+	return nil
+}
+
+func TestProcess_Retries(t *testing.T) {
+	restore := retryDelay
+	defer func() { retryDelay = restore }()
+	retryDelay = func(int) time.Duration { return 0 }
+
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte("---\noutput:\n  html:\n    output: doc.html\n---\n# Doc\n"), 0600)
+
+	exec := &flakyExecutor{failures: 2}
+	opts := options.Options{Targets: []string{"html"}, NoCache: true, Quiet: true, Retries: 2}
+	captureStderr(t, func() {
+		if _, err := process(context.Background(), input, nil, opts, exec, processEnv{baseDir: dir}); err != nil {
+			t.Fatalf("expected the third attempt to succeed: %v", err)
+		}
+	})
+	if data, _ := os.ReadFile(filepath.Join(dir, "doc.html")); string(data) != "converted" || exec.runs != 3 {
+		t.Errorf("expected 3 runs and an output, got %d runs and %q", exec.runs, data)
+	}
+}
+
+// formatFailer fails the pandoc runs of one format and converts the others.
+type formatFailer struct {
+	argsRecorder
+	format string
+}
+
+func (f *formatFailer) Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	for i, arg := range args {
+		if arg == "--to" && i+1 < len(args) && args[i+1] == f.format {
+			return errors.New("exit status 43")
+		}
+	}
+	return f.argsRecorder.Run(ctx, name, args, stdout, stderr)
+}
+
+func TestProcess_KeepGoing(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte("---\noutput:\n  html:\n    output: doc.html\n  docx:\n    output: doc.docx\n  pdf:\n    output: doc.pdf\n---\n# Doc\n"), 0600)
+
+	exec := &formatFailer{argsRecorder: argsRecorder{inputs: map[string]string{}, args: map[string][]string{}}, format: "docx"}
+	opts := options.Options{All: true, NoCache: true, Quiet: true, KeepGoing: true}
+	results, err := process(context.Background(), input, nil, opts, exec, processEnv{baseDir: dir})
+	var terr *TargetErrors
+	if !errors.As(err, &terr) || len(terr.Failed) != 1 || terr.Total != 3 || terr.Failed[0].Target != "docx" {
+		t.Fatalf("expected one failed target out of three, got %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), "1 of 3 targets failed:\n  docx: ") {
+		t.Errorf("unexpected message: %q", err.Error())
+	}
+	if len(results) != 3 || len(exec.args) != 2 {
+		t.Errorf("expected the other targets to be built, got %d results and %d runs", len(results), len(exec.args))
+	}
+}
@@ -0,0 +1,55 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rapjul/panforge/internal/preprocess"
+)
+
+// sampleOutput returns the preview name used by --sample-pages: doc.pdf becomes doc.sample.pdf,
+// so a preview never replaces the full build.
+func sampleOutput(outputFile string) string {
+	ext := filepath.Ext(outputFile)
+	return strings.TrimSuffix(outputFile, ext) + ".sample" + ext
+}
+
+// writeSampleCopy writes a copy of the input holding only its first n top-level sections,
+// for a fast --sample-pages preview. It returns "" if the input has no more sections than that.
+//
+// Parameters:
+//   - `inputFile`: the target's current input
+//   - `n`: the number of sections to keep
+//
+// Returns:
+//   - string: the temp file path, or "" if no copy was needed
+//   - int: the number of top-level sections in the input
+//   - error: if the copy could not be written
+func writeSampleCopy(inputFile string, n int) (string, int, error) {
+	//nolint:gosec // G304: reading the input file is intended
+	data, err := os.ReadFile(inputFile)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read input for sampling: %w", err)
+	}
+	content, total := preprocess.TruncateSections(string(data), n)
+	if total <= n {
+		return "", total, nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(inputFile), ".panforge-sample-*"+filepath.Ext(inputFile))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	if _, err := tmp.WriteString(content); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return "", 0, fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", 0, fmt.Errorf("failed to close temp file: %w", err)
+	}
+	return tmp.Name(), total, nil
+}
@@ -0,0 +1,39 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/options"
+)
+
+func TestProcess_SamplePages(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	input := filepath.Join(dir, "book.md")
+	_ = os.WriteFile(input, []byte("---\ntitle: Book\noutput:\n  pdf:\n    output: "+filepath.Join(dir, "book.pdf")+
+		"\n  html:\n    output: "+filepath.Join(dir, "book.html")+"\n---\n# One\n\nfirst\n\n# Two\n\nsecond\n\n# Three\n"), 0600)
+
+	rec := &argsRecorder{inputs: map[string]string{}, args: map[string][]string{}}
+	opts := options.Options{Targets: []string{"pdf", "html"}, SamplePages: 1, NoCache: true, Quiet: true, NoInteractive: true}
+	if _, err := process(context.Background(), input, nil, opts, rec, processEnv{}); err != nil {
+		t.Fatalf("process failed: %v", err)
+	}
+
+	sample, ok := rec.inputs["book.sample.pdf"]
+	if !ok {
+		t.Fatalf("expected the PDF to be written to book.sample.pdf, got %v", rec.inputs)
+	}
+	if !strings.Contains(sample, "first") || strings.Contains(sample, "second") {
+		t.Errorf("sample should hold only the first section:\n%s", sample)
+	}
+	if html := rec.inputs["book.html"]; !strings.Contains(html, "second") {
+		t.Errorf("non-PDF targets should be built in full:\n%s", html)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, ".panforge-sample-*")); len(matches) != 0 {
+		t.Errorf("temporary sample left behind: %v", matches)
+	}
+}
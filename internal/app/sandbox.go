@@ -0,0 +1,87 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/rapjul/panforge/internal/config"
+)
+
+// sandboxBlockedFlags are pandoc options that run external code, which pandoc's own
+// --sandbox does not restrict: filters, defaults files (which can declare filters),
+// and options passed to the PDF engine (e.g. -shell-escape).
+var sandboxBlockedFlags = map[string]bool{
+	"--filter":         true,
+	"-F":               true,
+	"--lua-filter":     true,
+	"-L":               true,
+	"--defaults":       true,
+	"-d":               true,
+	"--pdf-engine-opt": true,
+}
+
+// sandboxPDFEngines are the PDF engines a sandboxed document may select, by bare name.
+var sandboxPDFEngines = map[string]bool{
+	"pdflatex": true, "xelatex": true, "lualatex": true, "latexmk": true, "tectonic": true,
+	"context": true, "wkhtmltopdf": true, "weasyprint": true, "pagedjs-cli": true,
+	"prince": true, "pdfroff": true, "groff": true, "typst": true,
+}
+
+// isSandboxed reports whether a config layer enables `sandbox: true`.
+// Any layer may turn the sandbox on; a later layer cannot turn it back off.
+//
+// Parameters:
+//   - `layers`: the global or target-level config maps to check
+func isSandboxed(layers ...map[string]interface{}) bool {
+	for _, layer := range layers {
+		if b, ok := layer["sandbox"].(bool); ok && b {
+			return true
+		}
+	}
+	return false
+}
+
+// configSandboxed reports whether a loaded config enables the sandbox globally.
+//
+// Parameters:
+//   - `cfg`: the config, which may be nil
+func configSandboxed(cfg *config.Config) bool {
+	return cfg != nil && isSandboxed(cfg.Generic)
+}
+
+// stripSandboxedArgs removes options that would run external programs (filters,
+// defaults files, engine options, or a PDF engine that is not a known one by its bare
+// name) from arguments derived from the document's config.
+//
+// Parameters:
+//   - `args`: the pandoc arguments
+//
+// Returns:
+//   - []string: the arguments without those options
+//   - []string: the removed options, for reporting
+func stripSandboxedArgs(args []string) ([]string, []string) {
+	var kept, removed []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := strings.Cut(arg, "=")
+		if len(arg) > 2 && arg[0] == '-' && arg[1] != '-' && sandboxBlockedFlags[arg[:2]] {
+			// A short option with its value attached, e.g. -Fpandoc-crossref
+			name, hasValue = arg[:2], true
+		}
+		if !sandboxBlockedFlags[name] && name != "--pdf-engine" {
+			kept = append(kept, arg)
+			continue
+		}
+		option := []string{arg}
+		if !hasValue && i+1 < len(args) {
+			i++
+			value = args[i]
+			option = append(option, value)
+		}
+		if name == "--pdf-engine" && sandboxPDFEngines[value] {
+			kept = append(kept, option...)
+			continue
+		}
+		removed = append(removed, strings.Join(option, " "))
+	}
+	return kept, removed
+}
@@ -0,0 +1,294 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rapjul/panforge/internal/cache"
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+	"github.com/rapjul/panforge/internal/pandoc"
+	"github.com/rapjul/panforge/internal/preprocess"
+)
+
+// bookPart is one output of a book built with `split-chapters`: the main document's own
+// text or one chapter, numbered as if it continued the parts before it.
+type bookPart struct {
+	// Index is the part's position, starting at 1.
+	Index int
+	// Source is the main document; outputs are named after it.
+	Source string
+	// Offsets counts the chapters, figures, and tables of the parts before this one.
+	Offsets preprocess.Numbering
+	// Pages maps a target (without the part) to the pages of its earlier PDFs.
+	Pages map[string]int
+
+	mu sync.Mutex
+	// outputs maps the outputs of this part to their target, for counting pages.
+	outputs map[string]string
+}
+
+// variable returns the matrix variable that tells the part's outputs apart: {part} in
+// output names, or a -01, -02 suffix.
+func (p *bookPart) variable() matrixVar {
+	return matrixVar{Name: "part", Value: fmt.Sprintf("%02d", p.Index)}
+}
+
+// track remembers which target an output of the part belongs to.
+func (p *bookPart) track(outputFile, label string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.outputs == nil {
+		p.outputs = map[string]string{}
+	}
+	p.outputs[outputFile] = label
+}
+
+// numberingArgs returns the pandoc arguments that continue the numbering of the parts
+// before this one: --number-offset for the chapter number, and for LaTeX output a
+// header setting the chapter, figure, table, and page counters.
+//
+// Parameters:
+//   - `label`: the target, without the part
+//   - `fmtStr`: the target pandoc format
+//   - `cfg`: the global config
+//   - `metaOut`: the format-specific config
+//
+// Returns:
+//   - []string: extra pandoc arguments
+//   - string: a temporary file the caller must remove ("" if none)
+//   - error: any error writing the temporary file
+func (p *bookPart) numberingArgs(label, fmtStr string, cfg *config.Config, metaOut map[string]interface{}) ([]string, string, error) {
+	var args []string
+	if p.Offsets.Chapters > 0 {
+		args = append(args, "--number-offset="+strconv.Itoa(p.Offsets.Chapters))
+	}
+	if !latexOutput(fmtStr, stringSetting(cfg, metaOut, "pdf-engine")) {
+		return args, "", nil
+	}
+
+	var counters []string
+	if n := p.Offsets.Chapters; n > 0 {
+		counters = append(counters, fmt.Sprintf(`\makeatletter\@ifundefined{c@chapter}{\setcounter{section}{%d}}{\setcounter{chapter}{%d}}\makeatother`, n, n))
+	}
+	if n := p.Offsets.Figures; n > 0 {
+		counters = append(counters, fmt.Sprintf(`\setcounter{figure}{%d}`, n))
+	}
+	if n := p.Offsets.Tables; n > 0 {
+		counters = append(counters, fmt.Sprintf(`\setcounter{table}{%d}`, n))
+	}
+	if n := p.Pages[label]; n > 0 {
+		counters = append(counters, fmt.Sprintf(`\setcounter{page}{%d}`, n+1))
+	}
+	if len(counters) == 0 {
+		return args, "", nil
+	}
+	content := `\AtBeginDocument{` + strings.Join(counters, "") + "}\n"
+
+	// A content-addressed name keeps the arguments stable so the build cache still hits
+	name := filepath.Join(os.TempDir(), "panforge-part-"+cache.ComputeKey(label, content)[:16]+".tex")
+	//nolint:gosec // G306: generated include files are not sensitive
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		return nil, "", fmt.Errorf("failed to write numbering header: %w", err)
+	}
+	return append(args, "--include-in-header", name), name, nil
+}
+
+// latexOutput reports whether a format is typeset by LaTeX, whose counters can be set
+// from a header: latex and beamer, and pdf unless another engine makes the PDF.
+func latexOutput(fmtStr, engine string) bool {
+	switch pandoc.NormalizeFormat(fmtStr) {
+	case "latex", "beamer":
+		return true
+	case "pdf":
+		switch engine {
+		case "", "pdflatex", "xelatex", "lualatex", "latexmk", "tectonic":
+			return true
+		}
+	}
+	return false
+}
+
+// splitBook reads the parts of a book built with `split-chapters`: the main document's
+// text, unless it is empty, then each chapter. Every part keeps the main document's YAML
+// header, so it is converted with the book's settings.
+//
+// Parameters:
+//   - `inputFile`: the main document
+//   - `cfg`: the main document's config
+//
+// Returns:
+//   - []string: the Markdown source of each part
+//   - error: any error reading the document or a chapter
+func splitBook(inputFile string, cfg *config.Config) ([]string, error) {
+	includes, _ := cfg.Generic["includes"].(bool)
+	chapters, err := parseChapters(cfg.Generic["chapters"], filepath.Dir(inputFile))
+	if err != nil {
+		return nil, err
+	}
+	mainDoc, err := readBookSource(inputFile, includes)
+	if err != nil {
+		return nil, err
+	}
+	header, body := config.SplitFrontmatter(mainDoc)
+
+	var parts []string
+	if strings.TrimSpace(body) != "" {
+		parts = append(parts, header+"\n"+strings.Trim(body, "\n")+"\n")
+	}
+	for _, ch := range chapters {
+		text, err := readChapter(ch, includes)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, header+"\n"+text)
+	}
+	return parts, nil
+}
+
+// processParts builds a book with `split-chapters` as one output per part. Parts are
+// built in order, each continuing the chapter, figure, table, and page numbering of the
+// ones before it, so the outputs read like one document.
+//
+// Parameters:
+//   - `ctx`: context for cancellation
+//   - `inputFile`: the main document
+//   - `postArgs`: extra arguments for pandoc
+//   - `opts`: runtime options
+//   - `executor`: command executor
+//   - `env`: the shared build state
+//   - `cfg`: the main document's config
+//   - `targets`: the targets to build
+//   - `sandboxed`: whether the document or the defaults enable sandbox mode
+//
+// Returns:
+//   - []TargetResult: the results of every part
+//   - error: the first part that failed
+func processParts(ctx context.Context, inputFile string, postArgs []string, opts options.Options, executor CommandExecutor, env processEnv, cfg *config.Config, targets []string, sandboxed bool) ([]TargetResult, error) {
+	start := time.Now()
+	parts, err := splitBook(inputFile, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// The parts share one build directory, the invocation's if several files are converted
+	var run *buildRun
+	if env.builds != nil {
+		run, err = env.builds.get(cfg, env.baseDir)
+	} else {
+		run, err = newBuildRun(cfg, start, env.baseDir, !opts.DryRun)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ownRun := run != nil && env.builds == nil && !opts.DryRun
+	if ownRun {
+		// Only succeeds if the run ends early without writing anything
+		defer func() { _ = os.Remove(run.Dir) }()
+	}
+
+	partOpts := opts
+	partOpts.Targets = targets
+	partEnv := env
+	partEnv.interactive = false
+	partEnv.run = run
+
+	var results []TargetResult
+	var offsets preprocess.Numbering
+	pages := map[string]int{}
+	countPages := !opts.DryRun
+	for i, text := range parts {
+		part := &bookPart{Index: i + 1, Source: inputFile, Offsets: offsets, Pages: pages}
+		partEnv.part = part
+
+		// Kept next to the input so relative resource paths keep working
+		partFile, werr := writePartFile(inputFile, text)
+		if werr != nil {
+			err = werr
+			break
+		}
+		var partResults []TargetResult
+		partResults, err = process(ctx, partFile, postArgs, partOpts, executor, partEnv)
+		_ = os.Remove(partFile)
+		results = append(results, partResults...)
+		if err != nil && !opts.KeepGoing {
+			break
+		}
+
+		offsets = offsets.Add(preprocess.CountNumbering(text))
+		if countPages {
+			countPages = addPages(ctx, executor, part, partResults, pages, opts)
+		}
+	}
+
+	if ownRun {
+		finishBuildRun(run, opts)
+	}
+	if opts.KeepGoing && ctx.Err() == nil {
+		// A later part's success must not hide an earlier failure
+		if terr := targetErrors(results); terr != nil {
+			err = terr
+		}
+	}
+	return results, reportBuild(ctx, inputFile, cfg, opts, env, sandboxed, results, start, err)
+}
+
+// writePartFile writes the source of one part next to the main document.
+func writePartFile(inputFile, text string) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(inputFile), ".panforge-part-*"+filepath.Ext(inputFile))
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	if _, err := tmp.WriteString(text); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to close temp file: %w", err)
+	}
+	return tmp.Name(), nil
+}
+
+// addPages adds the page counts of a part's PDFs to the page offsets of their targets,
+// using `qpdf --show-npages`.
+//
+// Returns:
+//   - bool: false if pages cannot be counted, so later parts stop trying
+func addPages(ctx context.Context, executor CommandExecutor, part *bookPart, results []TargetResult, pages map[string]int, opts options.Options) bool {
+	warn := func(reason string) bool {
+		if opts.Logger != nil {
+			opts.Logger.Warn("split-chapters: page numbers do not continue across parts", "reason", reason)
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: split-chapters: page numbers do not continue across parts: %s\n", reason)
+		}
+		return false
+	}
+	for _, r := range sortedResults(results) {
+		if (r.Status != StatusSuccess && r.Status != StatusUpToDate) || !isPDFOutput(r.Output) {
+			continue
+		}
+		if !toolAvailable("qpdf") {
+			return warn("qpdf is not installed")
+		}
+		var stdout bytes.Buffer
+		if err := executor.Run(ctx, "qpdf", []string{"--show-npages", r.Output}, &stdout, io.Discard); err != nil {
+			return warn(err.Error())
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(stdout.String()))
+		if err != nil {
+			return warn(fmt.Sprintf("cannot count the pages of %s", r.Output))
+		}
+		pages[part.outputs[r.Output]] += n
+	}
+	return true
+}
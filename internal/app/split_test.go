@@ -0,0 +1,124 @@
+package app
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+	"github.com/rapjul/panforge/internal/preprocess"
+)
+
+// pageCounter records conversions and reports every PDF as three pages long.
+type pageCounter struct {
+	argsRecorder
+}
+
+func (p *pageCounter) Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	if name == "qpdf" {
+		_, err := io.WriteString(stdout, "3\n")
+		return err
+	}
+	return p.argsRecorder.Run(ctx, name, args, stdout, stderr)
+}
+
+func TestProcess_SplitChapters(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	restore := toolAvailable
+	defer func() { toolAvailable = restore }()
+	toolAvailable = func(name string) bool { return name == "qpdf" }
+
+	files := map[string]string{
+		"book.md": "---\ntitle: The Book\nsplit-chapters: true\nchapters:\n  - one.md\n  - two.md\n" +
+			"output:\n  html:\n    output: book.html\n  pdf:\n    output: book.pdf\n---\n",
+		"one.md": "# One\n\n![A figure](a.png)\n\n# Aside {-}\n",
+		"two.md": "# Two\n",
+	}
+	for name, content := range files {
+		_ = os.WriteFile(filepath.Join(dir, name), []byte(content), 0600)
+	}
+
+	rec := &pageCounter{argsRecorder{inputs: map[string]string{}, args: map[string][]string{}}}
+	opts := options.Options{NoCache: true, Quiet: true}
+	results, err := process(context.Background(), filepath.Join(dir, "book.md"), nil, opts, rec, processEnv{baseDir: dir})
+	if err != nil {
+		t.Fatalf("process failed: %v", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("expected 2 parts x 2 targets, got %+v", results)
+	}
+	for _, name := range []string{"book-01.html", "book-02.html", "book-01.pdf", "book-02.pdf"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("expected %s: %v", name, err)
+		}
+	}
+	if in := rec.inputs["book-02.html"]; !strings.Contains(in, "title: The Book") || !strings.Contains(in, "# Two") || strings.Contains(in, "# One") {
+		t.Errorf("expected the second part to hold the book header and chapter two only:\n%s", in)
+	}
+	if args := strings.Join(rec.args["book-01.html"], " "); strings.Contains(args, "--number-offset") {
+		t.Errorf("the first part must not be offset: %s", args)
+	}
+	if args := strings.Join(rec.args["book-02.html"], " "); !strings.Contains(args, "--number-offset=1") || strings.Contains(args, "--include-in-header") {
+		t.Errorf("expected the HTML part to continue chapter numbering: %s", args)
+	}
+	if args := strings.Join(rec.args["book-02.pdf"], " "); !strings.Contains(args, "--include-in-header") {
+		t.Errorf("expected the PDF part to continue its counters: %s", args)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, ".panforge-part-*")); len(matches) > 0 {
+		t.Errorf("part files left behind: %v", matches)
+	}
+}
+
+func TestBookPart_NumberingArgs(t *testing.T) {
+	part := &bookPart{Index: 3, Offsets: preprocess.Numbering{Chapters: 2, Figures: 5}, Pages: map[string]int{"pdf": 40}}
+	cfg := &config.Config{Generic: map[string]interface{}{}}
+
+	args, header, err := part.numberingArgs("pdf", "pdf", cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Remove(header) }()
+	if len(args) != 3 || args[0] != "--number-offset=2" || args[2] != header {
+		t.Fatalf("numberingArgs() = %v", args)
+	}
+	data, _ := os.ReadFile(header)
+	for _, want := range []string{`\setcounter{chapter}{2}`, `\setcounter{figure}{5}`, `\setcounter{page}{41}`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("header missing %s:\n%s", want, data)
+		}
+	}
+	if strings.Contains(string(data), "{table}") {
+		t.Errorf("unused counters must not be set:\n%s", data)
+	}
+
+	// HTML-based PDF engines cannot read LaTeX counters
+	args, header, _ = part.numberingArgs("pdf", "pdf", cfg, map[string]interface{}{"pdf-engine": "weasyprint"})
+	if header != "" || len(args) != 1 {
+		t.Errorf("numberingArgs() with weasyprint = %v, %q", args, header)
+	}
+}
+
+func TestSplitBook(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "book.md"), []byte("---\ntitle: B\nchapters: [one.md]\n---\n\n"), 0600)
+	_ = os.WriteFile(filepath.Join(dir, "one.md"), []byte("---\ntitle: Start\n---\nText.\n"), 0600)
+	input := filepath.Join(dir, "book.md")
+	_, cfg, err := config.LoadConfig(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts, err := splitBook(input, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A main document without text of its own is not a part
+	if len(parts) != 1 || !strings.HasPrefix(parts[0], "---\ntitle: B\n") || !strings.Contains(parts[0], "# Start\n\nText.") {
+		t.Errorf("splitBook() = %q", parts)
+	}
+}
@@ -0,0 +1,118 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+	"github.com/rapjul/panforge/internal/pandoc"
+	"github.com/rapjul/panforge/internal/preprocess"
+)
+
+// stdinInput is the input argument that reads the document from stdin, for panforge
+// and pandoc alike.
+const stdinInput = "-"
+
+// stdinRunner is implemented by executors that can feed a command's standard input.
+// A document read from stdin is streamed to pandoc this way.
+type stdinRunner interface {
+	RunStdin(ctx context.Context, name string, args []string, stdin io.Reader, stdout, stderr io.Writer) error
+}
+
+// runWithStdin runs a command with stdin as its standard input.
+//
+// Parameters:
+//   - `ctx`: context for cancellation
+//   - `executor`: used to run the command; it must support standard input
+//   - `name`: command name
+//   - `args`: command arguments
+//   - `stdin`: the standard input
+//   - `stdout`: writer for standard output
+//   - `stderr`: writer for standard error
+func runWithStdin(ctx context.Context, executor CommandExecutor, name string, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	r, ok := executor.(stdinRunner)
+	if !ok {
+		return fmt.Errorf("%s: this executor cannot pass a document on stdin", name)
+	}
+	return r.RunStdin(ctx, name, args, stdin, stdout, stderr)
+}
+
+// stdinDocument names a document read from stdin. It stands in the working directory,
+// so outputs are written there and relative resource paths resolve from there, as they
+// do for pandoc. Its extension follows --from and is .md by default.
+//
+// Parameters:
+//   - `from`: the input format, or ""
+func stdinDocument(from string) string {
+	ext := "md"
+	if format := sourceFormat("", from); format != "" && !headerFormat(format) {
+		ext = pandoc.ExtForFormat(format)
+	}
+	return filepath.Join(workingDir(), "stdin."+ext)
+}
+
+// stdinNeedsFile reports whether a run has steps that read or rewrite the source as a
+// file: includes, chapters, preprocessing, --check-paths, --sample-pages, kept
+// intermediates, diagrams to render, and the per-target `changes` and media settings.
+// The document is then written to a file first instead of being streamed to pandoc.
+//
+// Parameters:
+//   - `data`: the document
+//   - `cfg`: the document config
+//   - `targets`: the targets of the run
+//   - `opts`: runtime options
+//   - `executor`: used to run the diagram tools
+func stdinNeedsFile(data []byte, cfg *config.Config, targets []string, opts options.Options, executor CommandExecutor) bool {
+	if boolSetting(cfg, nil, "includes") || cfg.Generic["chapters"] != nil || cfg.Generic["preprocess"] != nil {
+		return true
+	}
+	if opts.CheckPaths || opts.Strict || opts.SamplePages > 0 || opts.KeepIntermediates != "" {
+		return true
+	}
+	for _, t := range targets {
+		fmtStr, metaOut := resolveTarget(cfg, t)
+		if stringSetting(cfg, metaOut, "changes") != "" {
+			return true
+		}
+		for _, kind := range diagramKinds(cfg, metaOut, fmtStr, opts, executor) {
+			if preprocess.HasDiagramBlocks(string(data), kind.Lang) {
+				return true
+			}
+		}
+		if _, hasMedia := resolveMedia(cfg, metaOut); hasMedia {
+			return true
+		}
+	}
+	return false
+}
+
+// writeStdinCopy writes a document read from stdin to a hidden file next to where it
+// stands in, so relative resource paths keep working.
+//
+// Parameters:
+//   - `inputFile`: the path the document stands in for
+//   - `data`: the document
+//
+// Returns:
+//   - string: the file written; the caller removes it
+//   - error: if the file cannot be written
+func writeStdinCopy(inputFile string, data []byte) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(inputFile), ".panforge-stdin-*"+filepath.Ext(inputFile))
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file for stdin: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write temp file for stdin: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to close temp file: %w", err)
+	}
+	return tmp.Name(), nil
+}
@@ -0,0 +1,91 @@
+package app
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/options"
+)
+
+// stdinRecorder records the commands it runs and what they read on stdin, and writes
+// their output.
+type stdinRecorder struct {
+	mu    sync.Mutex
+	args  [][]string
+	stdin []string
+}
+
+func (r *stdinRecorder) Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	return r.RunStdin(ctx, name, args, nil, stdout, stderr)
+}
+
+func (r *stdinRecorder) RunStdin(ctx context.Context, name string, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	var data []byte
+	if stdin != nil {
+		data, _ = io.ReadAll(stdin)
+	}
+	r.mu.Lock()
+	r.args = append(r.args, args)
+	r.stdin = append(r.stdin, string(data))
+	r.mu.Unlock()
+	for i, arg := range args {
+		if arg == "--output" && i+1 < len(args) {
+			return os.WriteFile(args[i+1], []byte(name), 0600)
+		}
+	}
+	return nil
+}
+
+func TestProcess_Stdin(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	doc := "---\ntitle: Piped\noutputs: [html]\n---\n# Piped\n"
+	input := filepath.Join(dir, "stdin.md")
+
+	rec := &stdinRecorder{}
+	opts := options.Options{NoCache: true, Quiet: true}
+	if _, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir, stdin: []byte(doc)}); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.args) != 1 || rec.args[0][0] != "-" || rec.stdin[0] != doc {
+		t.Fatalf("expected the document to be streamed to pandoc, got %v reading %q", rec.args, rec.stdin)
+	}
+	if outputs, _ := filepath.Glob(filepath.Join(dir, "Piped*.html")); len(outputs) != 1 {
+		t.Errorf("expected the output next to where the document stands in, got %v", outputs)
+	}
+}
+
+func TestProcess_StdinCopiedForSourceSteps(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	doc := "---\ntitle: Piped\noutputs: [html]\nchanges: accept\n---\nSome {++new++} text.\n"
+	input := filepath.Join(dir, "stdin.md")
+
+	rec := &stdinRecorder{}
+	opts := options.Options{NoCache: true, Quiet: true}
+	if _, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir, stdin: []byte(doc)}); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.args) != 1 || rec.args[0][0] == "-" || rec.stdin[0] != "" {
+		t.Fatalf("expected pandoc to read a file, got %v reading %q", rec.args, rec.stdin)
+	}
+	if copies, _ := filepath.Glob(filepath.Join(dir, ".panforge-stdin-*")); len(copies) > 0 {
+		t.Errorf("expected the copy of stdin to be removed, got %v", copies)
+	}
+}
+
+func TestStdinDocument(t *testing.T) {
+	if got := filepath.Base(stdinDocument("")); got != "stdin.md" {
+		t.Errorf("expected stdin.md, got %s", got)
+	}
+	if got := filepath.Base(stdinDocument("rst")); got != "stdin.rst" {
+		t.Errorf("expected stdin.rst, got %s", got)
+	}
+	if got := filepath.Base(stdinDocument("markdown+smart")); got != "stdin.md" {
+		t.Errorf("expected stdin.md, got %s", got)
+	}
+}
@@ -0,0 +1,78 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rapjul/panforge/internal/pandoc"
+)
+
+// stdoutOutput is the --output value that streams the converted document to stdout.
+const stdoutOutput = "-"
+
+// streamStdout is where `-o -` writes the document; replaced in tests.
+var streamStdout io.Writer = os.Stdout
+
+// stdoutStream sends the output of a single target to stdout. The target writes a
+// temporary file as usual, so PDF engines, postprocessing, and the other steps that
+// need a real file keep working, and the file is copied to stdout once it succeeded.
+type stdoutStream struct {
+	// dir holds the temporary output.
+	dir string
+	// file is the temporary output the target writes.
+	file string
+}
+
+// newStdoutStream creates the temporary output of a target streamed to stdout.
+//
+// Parameters:
+//   - `input`: the converted document, which names the temporary file
+//   - `format`: the pandoc output format, which picks its extension
+//
+// Returns:
+//   - *stdoutStream: the stream
+//   - error: if the temporary directory cannot be created
+func newStdoutStream(input, format string) (*stdoutStream, error) {
+	dir, err := os.MkdirTemp("", "panforge-stdout-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a temporary output: %w", err)
+	}
+	name := strings.TrimSuffix(filepath.Base(input), filepath.Ext(input)) + "." + pandoc.ExtForFormat(format)
+	return &stdoutStream{dir: dir, file: filepath.Join(dir, name)}, nil
+}
+
+// finish copies the output to stdout if the target succeeded, and reports it as
+// written to "-".
+//
+// Parameters:
+//   - `results`: the results of the run, updated in place
+//   - `err`: the run's error
+//
+// Returns:
+//   - error: err, or the error copying the output
+func (s *stdoutStream) finish(results []TargetResult, err error) error {
+	for i := range results {
+		results[i].Output = stdoutOutput
+	}
+	if err != nil {
+		return err
+	}
+	//nolint:gosec // G304: the temporary output written by this run
+	f, err := os.Open(s.file)
+	if err != nil {
+		return fmt.Errorf("failed to read the output: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := io.Copy(streamStdout, f); err != nil {
+		return fmt.Errorf("failed to write the output to stdout: %w", err)
+	}
+	return nil
+}
+
+// close removes the temporary output.
+func (s *stdoutStream) close() {
+	_ = os.RemoveAll(s.dir)
+}
@@ -0,0 +1,53 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/options"
+)
+
+func TestProcess_OutputStdout(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	input := filepath.Join(dir, "note.md")
+	_ = os.WriteFile(input, []byte("---\noutputs: [html, docx]\n---\n# Note\n"), 0600)
+
+	var out bytes.Buffer
+	old := streamStdout
+	streamStdout = &out
+	defer func() { streamStdout = old }()
+
+	rec := &envRecorder{}
+	opts := options.Options{Output: "-", Targets: []string{"html"}}
+	results, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "pandoc" {
+		t.Errorf("expected the output on stdout, got %q", out.String())
+	}
+	if len(results) != 1 || results[0].Output != "-" {
+		t.Errorf("expected the result to report stdout, got %+v", results)
+	}
+	output := rec.args[0][slices.Index(rec.args[0], "--output")+1]
+	if filepath.Ext(output) != ".html" || strings.HasPrefix(output, dir) {
+		t.Errorf("expected a temporary .html output, got %s", output)
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("expected the temporary output to be removed, got %v", err)
+	}
+	if written, _ := filepath.Glob(filepath.Join(dir, "*.html")); len(written) > 0 {
+		t.Errorf("expected no file next to the input, got %v", written)
+	}
+
+	opts.Targets = nil
+	if _, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir}); err == nil || !strings.Contains(err.Error(), "exactly one target") {
+		t.Errorf("expected an error for several targets, got %v", err)
+	}
+}
@@ -0,0 +1,445 @@
+package app
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rapjul/panforge/internal/cache"
+	"github.com/rapjul/panforge/internal/utils"
+)
+
+// syncLockName records the last synced bundle inside the data directory.
+const syncLockName = "sync.lock.json"
+
+// syncTimeout bounds how long downloading a bundle may take.
+const syncTimeout = 5 * time.Minute
+
+// SyncOptions holds flags for the sync command.
+type SyncOptions struct {
+	// Source is a git repository, a directory, or the URL or path of a .tar.gz/.tgz/.zip bundle.
+	// Empty re-syncs the source recorded by the previous sync.
+	Source string
+	// Ref is the git branch, tag, or commit to pin (git sources only).
+	Ref string
+	// SHA256 is the expected checksum; the sync fails if the bundle does not match.
+	SHA256 string
+	// DryRun lists the files that would be installed without writing them.
+	DryRun bool
+	// Quiet suppresses the summary printed after a sync.
+	Quiet bool
+}
+
+// SyncLock records where the shared configuration in the data directory came from.
+type SyncLock struct {
+	// Source is the repository or bundle that was synced.
+	Source string `json:"source"`
+	// Ref is the requested git ref, if any.
+	Ref string `json:"ref,omitempty"`
+	// Commit is the git commit that was checked out, if any.
+	Commit string `json:"commit,omitempty"`
+	// SHA256 is the checksum of the archive, or of the file tree for git sources.
+	SHA256 string `json:"sha256"`
+	// Files lists the installed files, relative to the data directory.
+	Files []string `json:"files"`
+	// SyncedAt is when the bundle was installed.
+	SyncedAt time.Time `json:"synced_at"`
+}
+
+// RunSync installs a shared configuration bundle (configs, templates, filters, reference
+// docs) into the data directory. The checksum of the bundle is always printed so it can
+// be pinned with --sha256. Files installed by a previous sync that are no longer in the
+// bundle are removed; other files in the data directory are left alone.
+//
+// Parameters:
+//   - `ctx`: context for cancellation
+//   - `opts`: the sync options
+//   - `dataDir`: the panforge data directory
+//   - `w`: where progress is written
+func RunSync(ctx context.Context, opts SyncOptions, dataDir string, w io.Writer) error {
+	prev, err := readSyncLock(dataDir)
+	if err != nil {
+		return err
+	}
+	if opts.Source == "" {
+		if prev == nil {
+			return fmt.Errorf("no source given and nothing synced before")
+		}
+		opts.Source = prev.Source
+		if opts.Ref == "" {
+			opts.Ref = prev.Ref
+		}
+		if opts.SHA256 == "" {
+			opts.SHA256 = prev.SHA256
+		}
+	}
+
+	tmp, err := os.MkdirTemp("", "panforge-sync-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	lock := SyncLock{Source: opts.Source, Ref: opts.Ref}
+	bundle := filepath.Join(tmp, "bundle")
+	if err := os.Mkdir(bundle, 0750); err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+
+	var root string
+	switch {
+	case isGitSource(opts.Source):
+		ref := opts.Ref
+		if ref == "" {
+			ref = "HEAD"
+		}
+		commit, err := utils.GitFetch(bundle, opts.Source, ref)
+		if err != nil {
+			return fmt.Errorf("failed to fetch %s: %w", opts.Source, err)
+		}
+		lock.Commit = commit
+		if err := os.RemoveAll(filepath.Join(bundle, ".git")); err != nil {
+			return fmt.Errorf("failed to clean checkout: %w", err)
+		}
+		root = bundle
+		if lock.SHA256, err = treeChecksum(root); err != nil {
+			return err
+		}
+	case opts.Ref != "":
+		return fmt.Errorf("--ref only applies to git sources")
+	case isDir(opts.Source):
+		root = opts.Source
+		if lock.SHA256, err = treeChecksum(root); err != nil {
+			return err
+		}
+	default:
+		data, err := readBundle(ctx, opts.Source)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		lock.SHA256 = hex.EncodeToString(sum[:])
+		if err := extractBundle(data, opts.Source, bundle); err != nil {
+			return err
+		}
+		root = bundleRoot(bundle)
+	}
+
+	if opts.SHA256 != "" && !strings.EqualFold(opts.SHA256, lock.SHA256) {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", opts.Source, opts.SHA256, lock.SHA256)
+	}
+
+	files, err := bundleFiles(root)
+	if err != nil {
+		return err
+	}
+	lock.Files = files
+
+	if opts.DryRun {
+		for _, f := range files {
+			_, _ = fmt.Fprintf(w, "would install %s\n", filepath.Join(dataDir, f))
+		}
+		_, _ = fmt.Fprintf(w, "sha256: %s\n", lock.SHA256)
+		return nil
+	}
+
+	for _, f := range files {
+		dst := filepath.Join(dataDir, f)
+		if err := os.MkdirAll(filepath.Dir(dst), 0750); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := copyFileContents(filepath.Join(root, f), dst); err != nil {
+			return fmt.Errorf("failed to install %s: %w", f, err)
+		}
+	}
+	if prev != nil {
+		current := make(map[string]bool, len(files))
+		for _, f := range files {
+			current[f] = true
+		}
+		for _, f := range prev.Files {
+			if !current[f] {
+				_ = os.Remove(filepath.Join(dataDir, f))
+			}
+		}
+	}
+
+	lock.SyncedAt = time.Now().UTC()
+	if err := writeSyncLock(dataDir, lock); err != nil {
+		return err
+	}
+
+	from := opts.Source
+	if lock.Commit != "" {
+		from += "@" + lock.Commit[:min(12, len(lock.Commit))]
+	}
+	if !opts.Quiet {
+		_, _ = fmt.Fprintf(w, "Synced %d file(s) from %s into %s\n", len(files), from, dataDir)
+		_, _ = fmt.Fprintf(w, "sha256: %s\n", lock.SHA256)
+	}
+	return nil
+}
+
+// isGitSource reports whether a sync source is a git repository rather than an archive.
+//
+// Parameters:
+//   - `source`: the sync source
+func isGitSource(source string) bool {
+	lower := strings.ToLower(source)
+	if strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz") || strings.HasSuffix(lower, ".zip") {
+		return false
+	}
+	if strings.HasSuffix(lower, ".git") || strings.HasPrefix(lower, "git@") || strings.HasPrefix(lower, "git://") || strings.HasPrefix(lower, "ssh://") {
+		return true
+	}
+	if isDir(filepath.Join(source, ".git")) {
+		return true
+	}
+	// Hosted repositories are usually given without the .git suffix
+	return strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "http://")
+}
+
+// isDir reports whether path is an existing directory.
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// readBundle downloads or reads an archive.
+//
+// Parameters:
+//   - `ctx`: context for cancellation
+//   - `source`: a URL or a local path
+func readBundle(ctx context.Context, source string) ([]byte, error) {
+	if !isRemote(source) {
+		//nolint:gosec // G304: reading the bundle named on the command line is intended
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundle: %w", err)
+		}
+		return data, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, syncTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download bundle: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download bundle: %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download bundle: %w", err)
+	}
+	return data, nil
+}
+
+// extractBundle unpacks a .tar.gz/.tgz or .zip archive into dir, rejecting entries that
+// would land outside it.
+//
+// Parameters:
+//   - `data`: the archive contents
+//   - `name`: the archive name, used to pick the format
+//   - `dir`: the destination directory
+func extractBundle(data []byte, name, dir string) error {
+	write := func(entry string, r io.Reader) error {
+		clean := filepath.Clean(filepath.FromSlash(entry))
+		if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("bundle entry %q escapes the bundle", entry)
+		}
+		dst := filepath.Join(dir, clean)
+		if err := os.MkdirAll(filepath.Dir(dst), 0750); err != nil {
+			return err
+		}
+		//nolint:gosec // G304: the path was checked to stay inside dir
+		f, err := os.Create(dst)
+		if err != nil {
+			return err
+		}
+		//nolint:gosec // G110: bundles are trusted (and may be pinned by checksum)
+		if _, err := io.Copy(f, r); err != nil {
+			_ = f.Close()
+			return err
+		}
+		return f.Close()
+	}
+
+	if strings.HasSuffix(strings.ToLower(name), ".zip") {
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return fmt.Errorf("failed to open bundle: %w", err)
+		}
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return fmt.Errorf("failed to extract %s: %w", f.Name, err)
+			}
+			err = write(f.Name, rc)
+			_ = rc.Close()
+			if err != nil {
+				return fmt.Errorf("failed to extract %s: %w", f.Name, err)
+			}
+		}
+		return nil
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to open bundle: %w", err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read bundle: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := write(hdr.Name, tr); err != nil {
+			return fmt.Errorf("failed to extract %s: %w", hdr.Name, err)
+		}
+	}
+}
+
+// bundleRoot descends into the single top-level directory that archives from code
+// hosts wrap their contents in (e.g. "team-config-1.2.0/").
+//
+// Parameters:
+//   - `dir`: the extracted bundle
+func bundleRoot(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 || !entries[0].IsDir() {
+		return dir
+	}
+	return filepath.Join(dir, entries[0].Name())
+}
+
+// bundleFiles lists the regular files of a bundle, relative to its root and sorted,
+// skipping hidden files and directories.
+//
+// Parameters:
+//   - `root`: the bundle root
+func bundleFiles(root string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != root && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() {
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bundle: %w", err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// treeChecksum hashes the paths and contents of a bundle's files, so a git checkout can
+// be pinned the same way as an archive.
+//
+// Parameters:
+//   - `root`: the bundle root
+func treeChecksum(root string) (string, error) {
+	files, err := bundleFiles(root)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	for _, f := range files {
+		sum, err := cache.HashFile(filepath.Join(root, f))
+		if err != nil {
+			return "", fmt.Errorf("failed to hash %s: %w", f, err)
+		}
+		_, _ = fmt.Fprintf(h, "%s\x00%s\n", filepath.ToSlash(f), sum)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// copyFileContents copies a file's contents to dst, replacing it.
+func copyFileContents(src, dst string) error {
+	//nolint:gosec // G304: copying bundle files is intended
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	//nolint:gosec // G306: configuration files are not sensitive
+	return os.WriteFile(dst, data, 0644)
+}
+
+// readSyncLock loads the lock written by the previous sync, or nil if there is none.
+//
+// Parameters:
+//   - `dataDir`: the panforge data directory
+func readSyncLock(dataDir string) (*SyncLock, error) {
+	//nolint:gosec // G304: the lock lives in the data directory
+	data, err := os.ReadFile(filepath.Join(dataDir, syncLockName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync lock: %w", err)
+	}
+	var lock SyncLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse sync lock: %w", err)
+	}
+	return &lock, nil
+}
+
+// writeSyncLock records a finished sync.
+//
+// Parameters:
+//   - `dataDir`: the panforge data directory
+//   - `lock`: the sync record
+func writeSyncLock(dataDir string, lock SyncLock) error {
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode sync lock: %w", err)
+	}
+	//nolint:gosec // G306: the lock is not sensitive
+	if err := os.WriteFile(filepath.Join(dataDir, syncLockName), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write sync lock: %w", err)
+	}
+	return nil
+}
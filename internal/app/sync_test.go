@@ -0,0 +1,142 @@
+package app
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTarGz writes a .tar.gz archive with the given files.
+func writeTarGz(t *testing.T, path string, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		_, _ = tw.Write([]byte(content))
+	}
+	_ = tw.Close()
+	_ = gz.Close()
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRunSync_Archive(t *testing.T) {
+	dir := t.TempDir()
+	dataDir := filepath.Join(dir, "data")
+	_ = os.MkdirAll(dataDir, 0750)
+	_ = os.WriteFile(filepath.Join(dataDir, "personal.yaml"), []byte("mine"), 0644)
+
+	bundle := filepath.Join(dir, "team.tar.gz")
+	data := writeTarGz(t, bundle, map[string]string{
+		"team-config-1.0/default.yaml":        "title: Team",
+		"team-config-1.0/templates/memo.html": "<html/>",
+		"team-config-1.0/old.lua":             "-- filter",
+	})
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+
+	var out bytes.Buffer
+	if err := RunSync(context.Background(), SyncOptions{Source: bundle, SHA256: checksum}, dataDir, &out); err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dataDir, "templates", "memo.html")); string(got) != "<html/>" {
+		t.Errorf("template not installed, got %q", got)
+	}
+	if !strings.Contains(out.String(), "Synced 3 file(s)") || !strings.Contains(out.String(), checksum) {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+
+	// A wrong checksum installs nothing.
+	if err := RunSync(context.Background(), SyncOptions{Source: bundle, SHA256: strings.Repeat("0", 64)}, dataDir, io.Discard); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("expected a checksum mismatch, got %v", err)
+	}
+
+	// Re-syncing a new version removes files dropped from the bundle but keeps personal ones.
+	writeTarGz(t, bundle, map[string]string{"team-config-1.1/default.yaml": "title: Team 2"})
+	if err := RunSync(context.Background(), SyncOptions{Source: bundle}, dataDir, io.Discard); err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "old.lua")); !os.IsNotExist(err) {
+		t.Error("files dropped from the bundle should be removed")
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "personal.yaml")); err != nil {
+		t.Error("files not installed by sync should be kept")
+	}
+
+	lock, err := readSyncLock(dataDir)
+	if err != nil || lock == nil || lock.Source != bundle || len(lock.Files) != 1 {
+		t.Errorf("unexpected lock %+v, %v", lock, err)
+	}
+}
+
+func TestRunSync_RejectsEscapingEntries(t *testing.T) {
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create("../evil.yaml")
+	_, _ = w.Write([]byte("x"))
+	_ = zw.Close()
+	bundle := filepath.Join(dir, "evil.zip")
+	_ = os.WriteFile(bundle, buf.Bytes(), 0644)
+
+	err := RunSync(context.Background(), SyncOptions{Source: bundle}, filepath.Join(dir, "data"), io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "escapes") {
+		t.Errorf("expected an escaping entry to be rejected, got %v", err)
+	}
+}
+
+func TestRunSync_Git(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=t", "-c", "user.email=t@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	_ = os.WriteFile(filepath.Join(repo, "default.yaml"), []byte("v1"), 0644)
+	git("add", ".")
+	git("commit", "-q", "-m", "v1")
+	git("tag", "v1")
+	_ = os.WriteFile(filepath.Join(repo, "default.yaml"), []byte("v2"), 0644)
+	git("commit", "-q", "-am", "v2")
+
+	dataDir := t.TempDir()
+	if err := RunSync(context.Background(), SyncOptions{Source: repo, Ref: "v1"}, dataDir, io.Discard); err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dataDir, "default.yaml")); string(got) != "v1" {
+		t.Errorf("default.yaml = %q, want the pinned v1", got)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, ".git")); !os.IsNotExist(err) {
+		t.Error("the .git directory should not be installed")
+	}
+
+	// Without a source, the previous source, ref, and checksum are reused.
+	if err := RunSync(context.Background(), SyncOptions{}, dataDir, io.Discard); err != nil {
+		t.Fatalf("re-sync failed: %v", err)
+	}
+	lock, _ := readSyncLock(dataDir)
+	if lock == nil || lock.Ref != "v1" || lock.Commit == "" {
+		t.Errorf("unexpected lock %+v", lock)
+	}
+}
@@ -0,0 +1,132 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rapjul/panforge/internal/utils"
+)
+
+// targetLog collects what a --log-dir log records about one target: the pandoc command,
+// everything pandoc wrote, and how the run ended.
+type targetLog struct {
+	mu      sync.Mutex
+	output  bytes.Buffer
+	started time.Time
+	// exitCode is pandoc's exit status, or -1 if it did not exit on its own.
+	exitCode int
+	ran      bool
+}
+
+// writers returns stdout and stderr writers that also record into the log.
+//
+// Parameters:
+//   - `stdout`: the process stdout writer
+//   - `stderr`: the process stderr writer
+func (l *targetLog) writers(stdout, stderr io.Writer) (io.Writer, io.Writer) {
+	if l == nil {
+		return stdout, stderr
+	}
+	l.ran = true
+	l.started = time.Now()
+	return io.MultiWriter(stdout, lockedWriter{l}), io.MultiWriter(stderr, lockedWriter{l})
+}
+
+// attempt records how one pandoc run ended.
+//
+// Parameters:
+//   - `err`: the run's error
+func (l *targetLog) attempt(err error) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.exitCode = 0
+	if err != nil {
+		l.exitCode = -1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			l.exitCode = exitErr.ExitCode()
+		}
+	}
+}
+
+// note adds a line between pandoc's output, e.g. to mark a retry.
+func (l *targetLog) note(format string, args ...interface{}) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintf(&l.output, "--- "+format+" ---\n", args...)
+}
+
+// lockedWriter appends to a target log; pandoc's stdout and stderr are copied concurrently.
+type lockedWriter struct{ l *targetLog }
+
+func (w lockedWriter) Write(p []byte) (int, error) {
+	w.l.mu.Lock()
+	defer w.l.mu.Unlock()
+	return w.l.output.Write(p)
+}
+
+// targetLogPath returns the --log-dir log of a target: the input's name and the target,
+// e.g. logs/thesis.pdf.log.
+//
+// Parameters:
+//   - `dir`: the log directory
+//   - `inputFile`: the converted document
+//   - `label`: the target, with its matrix variables
+func targetLogPath(dir, inputFile, label string) string {
+	stem := strings.TrimSuffix(filepath.Base(inputFile), filepath.Ext(inputFile))
+	return filepath.Join(dir, stem+"."+utils.Slugify(label)+".log")
+}
+
+// write saves the log of a target whose pandoc command ran, replacing the log of an
+// earlier run.
+//
+// Parameters:
+//   - `dir`: the log directory
+//   - `res`: the target's result
+//
+// Returns:
+//   - error: any error writing the log
+func (l *targetLog) write(dir string, res TargetResult) error {
+	if l == nil || !l.ran {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "input: %s\n", res.Input)
+	fmt.Fprintf(&sb, "target: %s\n", res.Target)
+	fmt.Fprintf(&sb, "command: %s\n", res.Command)
+	fmt.Fprintf(&sb, "started: %s\n", l.started.Format(time.RFC3339))
+	fmt.Fprintf(&sb, "duration: %s\n", time.Since(l.started).Round(time.Millisecond))
+	fmt.Fprintf(&sb, "status: %s\n", res.Status)
+	fmt.Fprintf(&sb, "exit status: %d\n", l.exitCode)
+	if res.Error != "" {
+		fmt.Fprintf(&sb, "error: %s\n", res.Error)
+	}
+	sb.WriteString("--- pandoc output ---\n")
+	sb.Write(l.output.Bytes())
+
+	path := targetLogPath(dir, res.Input, res.Target)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+	//nolint:gosec // G306: logs are meant to be read by the user
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write target log: %w", err)
+	}
+	return nil
+}
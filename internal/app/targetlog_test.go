@@ -0,0 +1,56 @@
+package app
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/options"
+)
+
+// chattyFailer writes to stderr and fails the PDF target.
+type chattyFailer struct {
+	formatFailer
+}
+
+func (c *chattyFailer) Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	_, _ = io.WriteString(stderr, "[WARNING] Could not fetch resource a.png\n")
+	return c.formatFailer.Run(ctx, name, args, stdout, stderr)
+}
+
+func TestProcess_LogDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte("---\noutput:\n  html:\n    output: doc.html\n  pdf:\n    output: doc.pdf\n---\n# Doc\n"), 0600)
+
+	rec := &chattyFailer{formatFailer{argsRecorder: argsRecorder{inputs: map[string]string{}, args: map[string][]string{}}, format: "pdf"}}
+	logDir := filepath.Join(dir, "logs")
+	opts := options.Options{Targets: []string{"html", "pdf"}, NoCache: true, Quiet: true, KeepGoing: true, LogDir: logDir}
+	if _, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir}); err == nil {
+		t.Fatal("expected the PDF target to fail")
+	}
+
+	html, err := os.ReadFile(filepath.Join(logDir, "doc.html.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"target: html\n", "command: pandoc ", "status: success\n", "exit status: 0\n", "--- pandoc output ---\n[WARNING] Could not fetch resource a.png\n"} {
+		if !strings.Contains(string(html), want) {
+			t.Errorf("html log missing %q:\n%s", want, html)
+		}
+	}
+	pdf, _ := os.ReadFile(filepath.Join(logDir, "doc.pdf.log"))
+	if !strings.Contains(string(pdf), "status: failed\n") || !strings.Contains(string(pdf), "error: pandoc failed: exit status 43\n") {
+		t.Errorf("unexpected pdf log:\n%s", pdf)
+	}
+}
+
+func TestTargetLogPath(t *testing.T) {
+	if got := targetLogPath("logs", "/docs/thesis.md", "pdf[profile=final]"); got != filepath.Join("logs", "thesis.pdf-profile-final.log") {
+		t.Errorf("targetLogPath() = %q", got)
+	}
+}
@@ -0,0 +1,62 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+// timeoutSetting returns how long a target's pandoc run may take: --timeout, else the
+// target's or document's `timeout` key, a duration such as "10m" or a number of minutes.
+// Zero means no limit.
+//
+// Parameters:
+//   - `opts`: runtime options
+//   - `cfg`: the global config
+//   - `metaOut`: the format-specific config
+//
+// Returns:
+//   - time.Duration: the limit, or 0 for none
+//   - error: if the setting is not a duration
+func timeoutSetting(opts options.Options, cfg *config.Config, metaOut map[string]interface{}) (time.Duration, error) {
+	if opts.Timeout > 0 {
+		return opts.Timeout, nil
+	}
+	raw, ok := metaOut["timeout"]
+	if !ok {
+		raw = cfg.Generic["timeout"]
+	}
+	var d time.Duration
+	switch v := raw.(type) {
+	case nil:
+		return 0, nil
+	case int:
+		d = time.Duration(v) * time.Minute
+	case float64:
+		d = time.Duration(v * float64(time.Minute))
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, fmt.Errorf("timeout: invalid duration %q (e.g. 10m or 90s)", v)
+		}
+		d = parsed
+	default:
+		return 0, fmt.Errorf("timeout: invalid duration %v (e.g. 10m or 90s)", raw)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("timeout: must not be negative, got %s", d)
+	}
+	return d, nil
+}
+
+// withTimeout returns a context that is cancelled after timeout, or ctx itself if there
+// is no limit.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
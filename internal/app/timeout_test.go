@@ -0,0 +1,68 @@
+package app
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+func TestTimeoutSetting(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    options.Options
+		value   interface{}
+		want    time.Duration
+		wantErr bool
+	}{
+		{"unset", options.Options{}, nil, 0, false},
+		{"duration", options.Options{}, "90s", 90 * time.Second, false},
+		{"minutes", options.Options{}, 10, 10 * time.Minute, false},
+		{"flag wins", options.Options{Timeout: time.Minute}, "1h", time.Minute, false},
+		{"invalid", options.Options{}, "soon", 0, true},
+		{"negative", options.Options{}, "-1m", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Generic: map[string]interface{}{}}
+			meta := map[string]interface{}{}
+			if tt.value != nil {
+				meta["timeout"] = tt.value
+			}
+			got, err := timeoutSetting(tt.opts, cfg, meta)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("timeoutSetting = %v, %v; want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+// hangingExecutor blocks until its context ends, like a stuck LaTeX run.
+type hangingExecutor struct{}
+
+func (hangingExecutor) Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestProcess_Timeout(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte("---\noutput:\n  pdf:\n    output: doc.pdf\n    timeout: 10ms\n---\n# Doc\n"), 0600)
+
+	opts := options.Options{Targets: []string{"pdf"}, NoCache: true, Quiet: true}
+	results, err := process(context.Background(), input, nil, opts, hangingExecutor{}, processEnv{baseDir: dir})
+	if err == nil || !strings.Contains(err.Error(), "target pdf: pandoc timed out after 10ms") {
+		t.Fatalf("expected the target to time out, got %v", err)
+	}
+	if len(results) != 1 || results[0].Status != StatusFailed {
+		t.Errorf("expected a failed result, got %+v", results)
+	}
+}
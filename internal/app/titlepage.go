@@ -0,0 +1,268 @@
+package app
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"strings"
+
+	"github.com/rapjul/panforge/internal/config"
+)
+
+// titlePage is the declarative description from the `titlepage` config block.
+type titlePage struct {
+	Title       string
+	Subtitle    string
+	Author      string
+	Institution string
+	Version     string
+	Date        string
+	Logo        string
+}
+
+var (
+	latexEscaper = strings.NewReplacer(
+		`\`, `\textbackslash{}`, `&`, `\&`, `%`, `\%`, `$`, `\$`, `#`, `\#`,
+		`_`, `\_`, `{`, `\{`, `}`, `\}`, `~`, `\textasciitilde{}`, `^`, `\textasciicircum{}`,
+	)
+	typstEscaper = strings.NewReplacer(
+		`\`, `\\`, `#`, `\#`, `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`,
+		`$`, `\$`, `@`, `\@`, `<`, `\<`, "`", "\\`",
+	)
+)
+
+// resolveTitlePage reads the `titlepage` block, with the target's block taking precedence.
+// A target can disable a global title page with `titlepage: false`.
+//
+// Parameters:
+//   - `cfg`: the global config
+//   - `metaOut`: the format-specific config
+//
+// Returns:
+//   - *titlePage: the title page, or nil if none is configured
+func resolveTitlePage(cfg *config.Config, metaOut map[string]interface{}) *titlePage {
+	raw, ok := metaOut["titlepage"]
+	if !ok {
+		raw, ok = cfg.Generic["titlepage"]
+	}
+	if !ok {
+		return nil
+	}
+	block, ok := raw.(map[string]interface{})
+	if !ok {
+		if b, isBool := raw.(bool); !isBool || !b {
+			return nil
+		}
+		block = map[string]interface{}{}
+	}
+
+	get := func(key string) string {
+		if v, ok := block[key]; ok && v != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return ""
+	}
+	tp := &titlePage{
+		Title:       get("title"),
+		Subtitle:    get("subtitle"),
+		Author:      get("author"),
+		Institution: get("institution"),
+		Version:     get("version"),
+		Date:        get("date"),
+		Logo:        get("logo"),
+	}
+	if tp.Title == "" {
+		tp.Title = cfg.Title
+	}
+	if tp.Author == "" {
+		tp.Author = cfg.Author
+	}
+	if tp.Date == "" {
+		if d, ok := cfg.Generic["date"]; ok && d != nil {
+			tp.Date = fmt.Sprintf("%v", d)
+		}
+	}
+	return tp
+}
+
+// titlePageArgs renders the title page for a format and returns the pandoc arguments that add it.
+// Text formats get a generated file passed via --include-before-body (replacing pandoc's own
+// title block). Beamer builds its title frame from metadata, as do DOCX and other formats.
+//
+// Parameters:
+//   - `tp`: the title page description
+//   - `fmtStr`: the target pandoc format
+//
+// Returns:
+//   - []string: extra pandoc arguments
+//   - string: a temporary file the caller must remove ("" if none)
+//   - error: any error writing the temporary file
+func titlePageArgs(tp *titlePage, fmtStr string) ([]string, string, error) {
+	var content, ext string
+	var args []string
+	switch fmtStr {
+	case "beamer":
+		// Its \titlepage frame shows title, subtitle, author, institute, date, and titlegraphic
+		subtitle := tp.Subtitle
+		if tp.Version != "" {
+			subtitle = strings.TrimPrefix(subtitle+" · "+versionLabel(tp.Version), " · ")
+		}
+		args = titleMetadataArgs(tp, subtitle)
+		if tp.Author != "" {
+			args = append(args, "--metadata", "author="+tp.Author)
+		}
+		if tp.Date != "" {
+			args = append(args, "--metadata", "date="+tp.Date)
+		}
+		if tp.Logo != "" {
+			args = append(args, "--variable", "titlegraphic="+tp.Logo)
+		}
+		return args, "", nil
+	case "latex", "pdf":
+		content, ext = tp.latex(), ".tex"
+		// Suppress pandoc's \maketitle but keep the PDF title metadata
+		args = append(args, "--metadata", "title=", "--variable", "title-meta="+tp.Title)
+		if tp.Logo != "" {
+			args = append(args, "--variable", "graphics=true")
+		}
+	case "html", "html4", "html5":
+		content, ext = tp.html(), ".html"
+		args = append(args, "--metadata", "title=", "--metadata", "pagetitle="+tp.Title)
+	case "typst":
+		content, ext = tp.typst(), ".typ"
+		args = append(args, "--metadata", "title=")
+	default:
+		// DOCX and other formats render title, subtitle, author, and date from metadata
+		subtitle := tp.Subtitle
+		for _, extra := range []string{tp.Institution, versionLabel(tp.Version)} {
+			if extra != "" {
+				subtitle = strings.TrimPrefix(subtitle+" · "+extra, " · ")
+			}
+		}
+		return titleMetadataArgs(tp, subtitle), "", nil
+	}
+
+	// Each target gets its own file, so one target removing it cannot affect another
+	f, err := os.CreateTemp("", "panforge-titlepage-*"+ext)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to write title page: %w", err)
+	}
+	_, err = f.WriteString(content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return nil, "", fmt.Errorf("failed to write title page: %w", err)
+	}
+	args = append(args, "--include-before-body", f.Name())
+	return args, f.Name(), nil
+}
+
+// titleMetadataArgs passes the title page's fields as metadata, for formats that render
+// their own title block from it.
+func titleMetadataArgs(tp *titlePage, subtitle string) []string {
+	var args []string
+	if tp.Title != "" {
+		args = append(args, "--metadata", "title="+tp.Title)
+	}
+	if subtitle != "" {
+		args = append(args, "--metadata", "subtitle="+subtitle)
+	}
+	if tp.Institution != "" {
+		args = append(args, "--metadata", "institute="+tp.Institution)
+	}
+	return args
+}
+
+// versionLabel formats a version for display ("Version 1.2"), or "" if unset.
+func versionLabel(v string) string {
+	if v == "" {
+		return ""
+	}
+	return "Version " + v
+}
+
+// latex renders the title page as a LaTeX titlepage environment.
+func (tp *titlePage) latex() string {
+	var sb strings.Builder
+	sb.WriteString("\\begin{titlepage}\n\\centering\n")
+	if tp.Logo != "" {
+		fmt.Fprintf(&sb, "\\includegraphics[width=0.3\\textwidth]{%s}\\par\\vspace{2cm}\n", tp.Logo)
+	}
+	if tp.Institution != "" {
+		fmt.Fprintf(&sb, "{\\scshape\\Large %s\\par}\n\\vspace{1.5cm}\n", latexEscaper.Replace(tp.Institution))
+	}
+	fmt.Fprintf(&sb, "{\\huge\\bfseries %s\\par}\n", latexEscaper.Replace(tp.Title))
+	if tp.Subtitle != "" {
+		fmt.Fprintf(&sb, "\\vspace{0.5cm}\n{\\Large %s\\par}\n", latexEscaper.Replace(tp.Subtitle))
+	}
+	sb.WriteString("\\vspace{2cm}\n")
+	if tp.Author != "" {
+		fmt.Fprintf(&sb, "{\\Large\\itshape %s\\par}\n", latexEscaper.Replace(tp.Author))
+	}
+	sb.WriteString("\\vfill\n")
+	if tp.Version != "" {
+		fmt.Fprintf(&sb, "{\\large %s\\par}\n", latexEscaper.Replace(versionLabel(tp.Version)))
+	}
+	if tp.Date != "" {
+		fmt.Fprintf(&sb, "{\\large %s\\par}\n", latexEscaper.Replace(tp.Date))
+	}
+	sb.WriteString("\\end{titlepage}\n")
+	return sb.String()
+}
+
+// html renders the title page as an HTML header block.
+func (tp *titlePage) html() string {
+	var sb strings.Builder
+	sb.WriteString("<header class=\"titlepage\">\n")
+	if tp.Logo != "" {
+		fmt.Fprintf(&sb, "<img class=\"logo\" src=\"%s\" alt=\"\" />\n", html.EscapeString(tp.Logo))
+	}
+	if tp.Institution != "" {
+		fmt.Fprintf(&sb, "<p class=\"institution\">%s</p>\n", html.EscapeString(tp.Institution))
+	}
+	fmt.Fprintf(&sb, "<h1 class=\"title\">%s</h1>\n", html.EscapeString(tp.Title))
+	if tp.Subtitle != "" {
+		fmt.Fprintf(&sb, "<p class=\"subtitle\">%s</p>\n", html.EscapeString(tp.Subtitle))
+	}
+	if tp.Author != "" {
+		fmt.Fprintf(&sb, "<p class=\"author\">%s</p>\n", html.EscapeString(tp.Author))
+	}
+	if tp.Version != "" {
+		fmt.Fprintf(&sb, "<p class=\"version\">%s</p>\n", html.EscapeString(versionLabel(tp.Version)))
+	}
+	if tp.Date != "" {
+		fmt.Fprintf(&sb, "<p class=\"date\">%s</p>\n", html.EscapeString(tp.Date))
+	}
+	sb.WriteString("</header>\n")
+	return sb.String()
+}
+
+// typst renders the title page as Typst markup followed by a page break.
+func (tp *titlePage) typst() string {
+	var sb strings.Builder
+	sb.WriteString("#align(center)[\n")
+	if tp.Logo != "" {
+		fmt.Fprintf(&sb, "  #image(%q, width: 30%%)\n  #v(2cm)\n", tp.Logo)
+	}
+	if tp.Institution != "" {
+		fmt.Fprintf(&sb, "  #text(size: 16pt)[%s]\n  #v(1.5cm)\n", typstEscaper.Replace(tp.Institution))
+	}
+	fmt.Fprintf(&sb, "  #text(size: 24pt, weight: \"bold\")[%s]\n", typstEscaper.Replace(tp.Title))
+	if tp.Subtitle != "" {
+		fmt.Fprintf(&sb, "\n  #text(size: 16pt)[%s]\n", typstEscaper.Replace(tp.Subtitle))
+	}
+	if tp.Author != "" {
+		fmt.Fprintf(&sb, "  #v(2cm)\n  #text(size: 14pt, style: \"italic\")[%s]\n", typstEscaper.Replace(tp.Author))
+	}
+	sb.WriteString("  #v(1fr)\n")
+	if tp.Version != "" {
+		fmt.Fprintf(&sb, "  %s\n\n", typstEscaper.Replace(versionLabel(tp.Version)))
+	}
+	if tp.Date != "" {
+		fmt.Fprintf(&sb, "  %s\n", typstEscaper.Replace(tp.Date))
+	}
+	sb.WriteString("]\n#pagebreak()\n")
+	return sb.String()
+}
@@ -0,0 +1,98 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+func TestResolveTitlePage(t *testing.T) {
+	cfg := &config.Config{
+		Title:  "Report",
+		Author: "Jane",
+		Generic: map[string]interface{}{
+			"titlepage": map[string]interface{}{"subtitle": "Q3", "version": 1.2},
+		},
+	}
+
+	tp := resolveTitlePage(cfg, map[string]interface{}{})
+	if tp == nil || tp.Title != "Report" || tp.Author != "Jane" || tp.Subtitle != "Q3" || tp.Version != "1.2" {
+		t.Errorf("unexpected title page: %+v", tp)
+	}
+
+	if tp := resolveTitlePage(cfg, map[string]interface{}{"titlepage": false}); tp != nil {
+		t.Errorf("titlepage: false on the target should disable it, got %+v", tp)
+	}
+	if tp := resolveTitlePage(&config.Config{}, map[string]interface{}{}); tp != nil {
+		t.Errorf("expected no title page without config, got %+v", tp)
+	}
+}
+
+func TestTitlePageArgs(t *testing.T) {
+	tp := &titlePage{Title: "R&D 100%", Subtitle: "Q3", Institution: "ACME", Version: "2"}
+
+	args, file, err := titlePageArgs(tp, "pdf")
+	if err != nil {
+		t.Fatalf("titlePageArgs failed: %v", err)
+	}
+	defer func() { _ = os.Remove(file) }()
+	content, _ := os.ReadFile(file)
+	if !strings.Contains(string(content), `{\huge\bfseries R\&D 100\%\par}`) {
+		t.Errorf("unexpected LaTeX title page:\n%s", content)
+	}
+	if args[len(args)-2] != "--include-before-body" || args[len(args)-1] != file {
+		t.Errorf("unexpected args: %v", args)
+	}
+
+	args, file, _ = titlePageArgs(tp, "docx")
+	if file != "" {
+		t.Errorf("docx should not generate an include file, got %s", file)
+	}
+	if !strings.Contains(strings.Join(args, " "), "subtitle=Q3 · ACME · Version 2") {
+		t.Errorf("unexpected docx args: %v", args)
+	}
+
+	// Beamer has its own title frame, built from metadata
+	tp.Logo = "logo.png"
+	args, file, _ = titlePageArgs(tp, "beamer")
+	joined := strings.Join(args, " ")
+	if file != "" || !strings.Contains(joined, "subtitle=Q3 · Version 2") || !strings.Contains(joined, "institute=ACME") || !strings.Contains(joined, "titlegraphic=logo.png") {
+		t.Errorf("unexpected beamer args: %v (file %q)", args, file)
+	}
+
+	// Two targets with the same title page get files of their own
+	_, first, _ := titlePageArgs(tp, "pdf")
+	_, second, _ := titlePageArgs(tp, "pdf")
+	defer func() { _ = os.Remove(first); _ = os.Remove(second) }()
+	if first == second {
+		t.Errorf("expected distinct title page files, got %s twice", first)
+	}
+	if info, err := os.Stat(first); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected a private title page file, got %v, %v", info, err)
+	}
+}
+
+func TestProcess_TitlePageCached(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte("---\ntitle: Doc\ntitlepage: true\noutput:\n  html:\n    output: doc.html\n---\n# Doc\n"), 0600)
+
+	opts := options.Options{Force: true, Quiet: true}
+	runs := 0
+	for i := 0; i < 2; i++ {
+		rec := &argsRecorder{inputs: map[string]string{}, args: map[string][]string{}}
+		if _, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir}); err != nil {
+			t.Fatal(err)
+		}
+		runs += len(rec.args)
+	}
+	if runs != 1 {
+		t.Errorf("expected the second run to be up to date despite a new title page file, pandoc ran %d times", runs)
+	}
+}
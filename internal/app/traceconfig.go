@@ -0,0 +1,156 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+// configTrace records which config supplied each setting while the document, project,
+// workspace, recipe, and default configs are merged, for --trace-config. A nil trace
+// merges without recording.
+type configTrace struct {
+	// sources maps each top-level key, and each `output.<name>` entry, to its config.
+	sources map[string]string
+}
+
+// newConfigTrace starts a trace with the settings of the document.
+//
+// Parameters:
+//   - `cfg`: the document config
+//   - `source`: how to name the document
+func newConfigTrace(cfg *config.Config, source string) *configTrace {
+	t := &configTrace{sources: make(map[string]string)}
+	for _, key := range configKeys(cfg) {
+		t.sources[key] = source
+	}
+	return t
+}
+
+// merge merges defaults into cfg (see mergeConfig) and records the settings it added.
+//
+// Parameters:
+//   - `cfg`: the config, updated in place
+//   - `defaults`: the config merged in (may be nil)
+//   - `source`: how to name it
+func (t *configTrace) merge(cfg, defaults *config.Config, source string) {
+	mergeConfig(cfg, defaults)
+	if t == nil || defaults == nil {
+		return
+	}
+	for _, key := range configKeys(cfg) {
+		if _, ok := t.sources[key]; !ok {
+			t.sources[key] = source
+		}
+	}
+}
+
+// print writes every effective setting with its value and the config that supplied it,
+// one line per leaf, e.g. `output.html.toc  true  project config .panforge.yaml`.
+//
+// Parameters:
+//   - `w`: where to write
+//   - `inputFile`: the document
+//   - `cfg`: the merged config
+func (t *configTrace) print(w io.Writer, inputFile string, cfg *config.Config) {
+	if t == nil {
+		return
+	}
+	fmt.Fprintf(w, "Effective config for %s:\n", inputFile)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, key := range configKeys(cfg) {
+		for _, leaf := range flattenSetting(key, configValue(cfg, key)) {
+			fmt.Fprintf(tw, "  %s\t%v\t%s\n", leaf.key, leaf.value, t.sources[key])
+		}
+	}
+	_ = tw.Flush()
+}
+
+// traceWriter returns where --trace-config prints: stdout, unless the document or a
+// --report is written there.
+func traceWriter(opts options.Options) io.Writer {
+	if opts.Output == stdoutOutput || ReportToStdout(opts) {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// configKeys lists the settings of a config, sorted: its top-level keys, with the
+// `output` map split into one `output.<name>` key per entry, as mergeConfig merges them.
+func configKeys(cfg *config.Config) []string {
+	var keys []string
+	if cfg.Title != "" {
+		keys = append(keys, "title")
+	}
+	if cfg.Author != "" {
+		keys = append(keys, "author")
+	}
+	if cfg.Outputs != nil {
+		keys = append(keys, "outputs")
+	}
+	if cfg.FilenameTemplate != "" {
+		keys = append(keys, "filename-template")
+	}
+	if cfg.SlugifyFilename != nil {
+		keys = append(keys, "slugify-filename")
+	}
+	for name := range cfg.OutputMap {
+		keys = append(keys, "output."+name)
+	}
+	for key := range cfg.Generic {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// configValue returns the value of a key listed by configKeys.
+func configValue(cfg *config.Config, key string) interface{} {
+	switch key {
+	case "title":
+		return cfg.Title
+	case "author":
+		return cfg.Author
+	case "outputs":
+		return cfg.Outputs
+	case "filename-template":
+		return cfg.FilenameTemplate
+	case "slugify-filename":
+		return *cfg.SlugifyFilename
+	}
+	if name, ok := strings.CutPrefix(key, "output."); ok {
+		return cfg.OutputMap[name]
+	}
+	return cfg.Generic[key]
+}
+
+// tracedSetting is one leaf of a traced setting.
+type tracedSetting struct {
+	key   string
+	value interface{}
+}
+
+// flattenSetting splits a setting into its leaves, joining the keys of nested maps
+// with dots.
+func flattenSetting(key string, value interface{}) []tracedSetting {
+	m, ok := value.(map[string]interface{})
+	if !ok || len(m) == 0 {
+		return []tracedSetting{{key: key, value: value}}
+	}
+	subkeys := make([]string, 0, len(m))
+	for k := range m {
+		subkeys = append(subkeys, k)
+	}
+	sort.Strings(subkeys)
+	var leaves []tracedSetting
+	for _, k := range subkeys {
+		leaves = append(leaves, flattenSetting(key+"."+k, m[k])...)
+	}
+	return leaves
+}
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/config"
+)
+
+func TestConfigTrace(t *testing.T) {
+	doc := &config.Config{Title: "Doc", Generic: map[string]interface{}{"lang": "en"}}
+	project := &config.Config{
+		OutputMap: map[string]interface{}{"html": map[string]interface{}{"toc": true}},
+		Generic:   map[string]interface{}{"lang": "de"},
+	}
+	defaults := &config.Config{Title: "Default", Generic: map[string]interface{}{"toc": false}}
+
+	trace := newConfigTrace(doc, "document doc.md")
+	trace.merge(doc, project, "project config .panforge.yaml")
+	trace.merge(doc, nil, "workspace defaults")
+	trace.merge(doc, defaults, "default config default.yaml")
+
+	var out bytes.Buffer
+	trace.print(&out, "doc.md", doc)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	want := [][]string{
+		{"Effective config for doc.md:"},
+		{"lang", "en", "document doc.md"},
+		{"output.html.toc", "true", "project config .panforge.yaml"},
+		{"title", "Doc", "document doc.md"},
+		{"toc", "false", "default config default.yaml"},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d lines, got:\n%s", len(want), out.String())
+	}
+	for i, fields := range want {
+		if got := strings.Fields(lines[i]); strings.Join(got, " ") != strings.Join(fields, " ") {
+			t.Errorf("line %d: expected %v, got %q", i, fields, lines[i])
+		}
+	}
+
+	// A nil trace merges without recording or printing
+	var none *configTrace
+	cfg := &config.Config{}
+	none.merge(cfg, defaults, "default config")
+	none.print(&out, "doc.md", cfg)
+	if cfg.Title != "Default" {
+		t.Errorf("expected a nil trace to merge, got %+v", cfg)
+	}
+}
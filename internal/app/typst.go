@@ -0,0 +1,191 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+	"github.com/rapjul/panforge/internal/utils"
+)
+
+// typstConfig holds the resolved `typst-compile` settings of a target.
+type typstConfig struct {
+	// FontPaths are extra directories typst searches for fonts (--font-path).
+	FontPaths []string
+	// Root is the project root typst may read files from (--root), or "" for typst's default.
+	Root string
+}
+
+// resolveTypstCompile reads the `typst-compile` setting of a target: true, or a map with
+// `font-path` (a directory or a list) and `root` keys. Relative paths are taken relative
+// to the input's directory. The target value wins over the global one.
+//
+// Parameters:
+//   - `cfg`: the global config
+//   - `metaOut`: the format-specific config
+//   - `baseDir`: the input's directory
+//
+// Returns:
+//   - *typstConfig: the settings, or nil if panforge leaves typst to pandoc
+//   - error: if the value is invalid
+func resolveTypstCompile(cfg *config.Config, metaOut map[string]interface{}, baseDir string) (*typstConfig, error) {
+	raw, ok := metaOut["typst-compile"]
+	if !ok {
+		raw = cfg.Generic["typst-compile"]
+	}
+	tc := &typstConfig{}
+	switch v := raw.(type) {
+	case nil:
+		return nil, nil
+	case bool:
+		if !v {
+			return nil, nil
+		}
+	case map[string]interface{}:
+		switch paths := v["font-path"].(type) {
+		case nil:
+		case string:
+			tc.FontPaths = []string{paths}
+		case []interface{}:
+			for _, p := range paths {
+				s, ok := p.(string)
+				if !ok {
+					return nil, fmt.Errorf("typst-compile: font-path must be a directory or a list of directories")
+				}
+				tc.FontPaths = append(tc.FontPaths, s)
+			}
+		default:
+			return nil, fmt.Errorf("typst-compile: font-path must be a directory or a list of directories")
+		}
+		if root, ok := v["root"]; ok {
+			s, ok := root.(string)
+			if !ok {
+				return nil, fmt.Errorf("typst-compile: root must be a directory")
+			}
+			tc.Root = s
+		}
+	default:
+		return nil, fmt.Errorf("typst-compile must be true or a map with font-path and root")
+	}
+	for i, p := range tc.FontPaths {
+		tc.FontPaths[i] = resolveFrom(baseDir, p)
+	}
+	if tc.Root != "" {
+		tc.Root = resolveFrom(baseDir, tc.Root)
+	}
+	return tc, nil
+}
+
+// resolveFrom makes a relative path relative to dir.
+func resolveFrom(dir, path string) string {
+	if filepath.IsAbs(path) || dir == "" {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// pdfEngine returns the --pdf-engine of a pandoc command line, or "" if it has none.
+func pdfEngine(args []string) string {
+	engine := ""
+	for i, arg := range args {
+		if arg == "--pdf-engine" && i+1 < len(args) {
+			engine = args[i+1]
+		} else if v, ok := strings.CutPrefix(arg, "--pdf-engine="); ok {
+			engine = v
+		}
+	}
+	return strings.TrimSuffix(filepath.Base(engine), filepath.Ext(engine))
+}
+
+// typstSourcePath returns where the Typst source of a two-stage PDF conversion is
+// written: next to the input, so the paths of its images resolve the same way.
+//
+// Parameters:
+//   - `inputFile`: the converted document
+//   - `label`: the target, with its matrix variables
+func typstSourcePath(inputFile, label string) string {
+	stem := strings.TrimSuffix(filepath.Base(inputFile), filepath.Ext(inputFile))
+	return filepath.Join(filepath.Dir(inputFile), ".panforge-"+stem+"."+utils.Slugify(label)+".typ")
+}
+
+// sourceArgs turns the pandoc command of a PDF target into one that writes the source
+// its PDF engine would compile, e.g. the first stage of a two-stage Typst conversion.
+//
+// Parameters:
+//   - `args`: the pandoc arguments of the PDF target
+//   - `format`: the source format, e.g. typst or latex
+//   - `source`: where the source is written
+//
+// Returns:
+//   - []string: the arguments, with --to format, the source as --output, --standalone
+//     (as pandoc implies for PDF), and without --pdf-engine and --pdf-engine-opt
+func sourceArgs(args []string, format, source string) []string {
+	out := make([]string, 0, len(args)+1)
+	standalone := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--to" && i+1 < len(args):
+			out = append(out, arg, format)
+			i++
+		case arg == "--pdf-engine" || arg == "--pdf-engine-opt":
+			i++
+		case strings.HasPrefix(arg, "--pdf-engine=") || strings.HasPrefix(arg, "--pdf-engine-opt="):
+		default:
+			standalone = standalone || arg == "--standalone" || arg == "-s"
+			out = append(out, arg)
+		}
+	}
+	if !standalone {
+		out = append(out, "--standalone")
+	}
+	return withOutput(out, source)
+}
+
+// typstCompileArgs returns the arguments of `typst compile` for the second stage.
+//
+// Parameters:
+//   - `tc`: the typst settings
+//   - `source`: the Typst source pandoc wrote
+//   - `pdfFile`: the PDF to write
+func typstCompileArgs(tc *typstConfig, source, pdfFile string) []string {
+	args := []string{"compile"}
+	for _, p := range tc.FontPaths {
+		args = append(args, "--font-path", p)
+	}
+	if tc.Root != "" {
+		args = append(args, "--root", tc.Root)
+	}
+	return append(args, source, pdfFile)
+}
+
+// compileTypst runs the second stage of a two-stage PDF conversion: `typst compile` on
+// the source pandoc wrote.
+//
+// Parameters:
+//   - `ctx`: context for cancellation
+//   - `tc`: the typst settings
+//   - `source`: the Typst source
+//   - `pdfFile`: the PDF to write
+//   - `opts`: runtime options
+//   - `executor`: used to run typst
+//   - `stdout`: where typst's output goes
+//   - `stderr`: where typst's errors go
+//
+// Returns:
+//   - error: if typst failed
+func compileTypst(ctx context.Context, tc *typstConfig, source, pdfFile string, opts options.Options, executor CommandExecutor, stdout, stderr io.Writer) error {
+	args := typstCompileArgs(tc, source, pdfFile)
+	echoCommand(opts, formatCommand("typst", args))
+	if opts.DryRun {
+		return nil
+	}
+	if err := executor.Run(ctx, "typst", args, stdout, stderr); err != nil {
+		return fmt.Errorf("typst compile failed: %w", err)
+	}
+	return nil
+}
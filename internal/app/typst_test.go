@@ -0,0 +1,98 @@
+package app
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+// stageRecorder records every command and writes the file each one produces.
+type stageRecorder struct {
+	mu       sync.Mutex
+	commands [][]string
+}
+
+func (r *stageRecorder) Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	r.mu.Lock()
+	r.commands = append(r.commands, append([]string{name}, args...))
+	r.mu.Unlock()
+	out := args[len(args)-1]
+	for i, arg := range args {
+		if arg == "--output" && i+1 < len(args) {
+			out = args[i+1]
+		}
+	}
+	return os.WriteFile(out, []byte(name), 0600)
+}
+
+func TestResolveTypstCompile(t *testing.T) {
+	cfg := &config.Config{Generic: map[string]interface{}{}}
+	if tc, err := resolveTypstCompile(cfg, map[string]interface{}{}, "/docs"); tc != nil || err != nil {
+		t.Errorf("expected pandoc to run typst without a setting, got %+v, %v", tc, err)
+	}
+	tc, err := resolveTypstCompile(cfg, map[string]interface{}{"typst-compile": map[string]interface{}{
+		"font-path": []interface{}{"fonts", "/usr/share/fonts"},
+		"root":      "..",
+	}}, "/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &typstConfig{FontPaths: []string{"/docs/fonts", "/usr/share/fonts"}, Root: "/"}
+	if !reflect.DeepEqual(tc, want) {
+		t.Errorf("resolveTypstCompile() = %+v, want %+v", tc, want)
+	}
+	if _, err := resolveTypstCompile(cfg, map[string]interface{}{"typst-compile": "yes"}, "/docs"); err == nil {
+		t.Error("expected an error for an invalid value")
+	}
+}
+
+func TestSourceArgs(t *testing.T) {
+	args := []string{"doc.md", "--to", "pdf", "--output", "doc.pdf", "--pdf-engine", "typst", "--pdf-engine-opt=--ppi=300", "--toc"}
+	got := sourceArgs(args, "typst", ".panforge-doc.pdf.typ")
+	want := []string{"doc.md", "--to", "typst", "--output", ".panforge-doc.pdf.typ", "--toc", "--standalone"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sourceArgs() = %v, want %v", got, want)
+	}
+	if engine := pdfEngine([]string{"--pdf-engine=/usr/local/bin/typst"}); engine != "typst" {
+		t.Errorf("pdfEngine() = %q", engine)
+	}
+}
+
+func TestProcess_TypstCompile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte("---\noutput:\n  pdf:\n    output: doc.pdf\n    pdf-engine: typst\n    typst-compile:\n      font-path: fonts\n---\n# Doc\n"), 0600)
+
+	rec := &stageRecorder{}
+	opts := options.Options{Targets: []string{"pdf"}, NoCache: true, Quiet: true}
+	if _, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.commands) != 2 || rec.commands[0][0] != "pandoc" || rec.commands[1][0] != "typst" {
+		t.Fatalf("expected pandoc then typst, got %v", rec.commands)
+	}
+	pandocCmd := strings.Join(rec.commands[0], " ")
+	source := filepath.Join(dir, ".panforge-doc.pdf.typ")
+	if !strings.Contains(pandocCmd, "--to typst --output "+source) || strings.Contains(pandocCmd, "--pdf-engine") {
+		t.Errorf("unexpected pandoc command: %s", pandocCmd)
+	}
+	typst := rec.commands[1]
+	if strings.Join(typst[1:4], " ") != "compile --font-path "+filepath.Join(dir, "fonts") || typst[4] != source || !strings.HasSuffix(typst[5], "doc.pdf") {
+		t.Errorf("unexpected typst command: %v", typst)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "doc.pdf")); err != nil || string(data) != "typst" {
+		t.Errorf("expected typst to write the PDF, got %q, %v", data, err)
+	}
+	if _, err := os.Stat(source); !os.IsNotExist(err) {
+		t.Error("expected the Typst source to be removed")
+	}
+}
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+// RunValidate checks documents' front matter and the configs that apply to them against
+// the config schema (see config.Schema), without running pandoc. Each problem is printed
+// with its file, line, and key.
+//
+// Parameters:
+//   - `inputs`: the documents or config files to check; none checks the project config of
+//     the current directory and the default config
+//   - `opts`: runtime options (Config, Quiet)
+//   - `w`: where problems are written
+//
+// Returns:
+//   - error: a configuration error if any file has problems or cannot be parsed
+func RunValidate(inputs []string, opts options.Options, w io.Writer) error {
+	var files []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if path == "" {
+			return
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			abs = path
+		}
+		if seen[abs] {
+			return
+		}
+		seen[abs] = true
+		files = append(files, path)
+	}
+	if len(inputs) == 0 {
+		add(config.FindProjectConfig("."))
+		path, _, err := loadDefaultConfig(opts.Config)
+		if err != nil && path == "" {
+			return configError(err)
+		}
+		add(path)
+	}
+	for _, input := range inputs {
+		add(input)
+		add(config.FindProjectConfig(filepath.Dir(input)))
+	}
+
+	problems := 0
+	for _, file := range files {
+		errs, err := config.ValidateFile(file)
+		if err != nil {
+			_, _ = fmt.Fprintln(w, err)
+			problems++
+			continue
+		}
+		for _, e := range errs {
+			_, _ = fmt.Fprintln(w, e)
+		}
+		problems += len(errs)
+		if len(errs) == 0 && !opts.Quiet {
+			_, _ = fmt.Fprintf(w, "%s: ok\n", file)
+		}
+	}
+	if problems > 0 {
+		return configError(fmt.Errorf("%d problem(s) found", problems))
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package app
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/options"
+)
+
+func TestRunValidate(t *testing.T) {
+	dir := t.TempDir()
+	doc := filepath.Join(dir, "doc.md")
+	project := filepath.Join(dir, ".panforge.yaml")
+	_ = os.WriteFile(doc, []byte("---\ntitle: Doc\nreproducible: true\n---\n# Body\n"), 0600)
+	_ = os.WriteFile(project, []byte("output-collision: rename\n"), 0600)
+
+	var out bytes.Buffer
+	err := RunValidate([]string{doc}, options.Options{}, &out)
+	if ExitCode(err) != ExitConfig {
+		t.Fatalf("expected a configuration error, got %v", err)
+	}
+	if !strings.Contains(out.String(), doc+": ok") {
+		t.Errorf("expected the document to pass, got:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), project+`:1:19: output-collision: must be one of error, suffix, got "rename"`) {
+		t.Errorf("expected the project config problem with its line, got:\n%s", out.String())
+	}
+
+	_ = os.WriteFile(project, []byte("output-collision: suffix\n"), 0600)
+	out.Reset()
+	if err := RunValidate([]string{doc}, options.Options{Quiet: true}, &out); err != nil {
+		t.Fatalf("expected no problems, got %v:\n%s", err, out.String())
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected no output with --quiet, got:\n%s", out.String())
+	}
+}
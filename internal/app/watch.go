@@ -4,24 +4,23 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/rapjul/panforge/internal/options"
 )
 
-// Watch monitors the input file (and optional config file) for changes and re-runs the conversion.
-//
-// Watch monitors the input file (and optional config file) for changes and re-runs the conversion.
+// Watch monitors the input file (and its config files) for changes and re-runs the conversion.
 //
 // Parameters:
 //   - `ctx`: context for cancellation
 //   - `inputFile`: path to the file being watched
-//   - `configFile`: path to the optional config file
+//   - `configFiles`: paths of the project and default config files, if any
 //   - `postArgs`: arguments to pass to the pandoc command
 //   - `opts`: configuration options
 //   - `executor`: used to run the command
-func Watch(ctx context.Context, inputFile string, configFile string, postArgs []string, opts options.Options, executor CommandExecutor) error {
+func Watch(ctx context.Context, inputFile string, configFiles []string, postArgs []string, opts options.Options, executor CommandExecutor) error {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return fmt.Errorf("failed to create watcher: %w", err)
@@ -32,17 +31,28 @@ func Watch(ctx context.Context, inputFile string, configFile string, postArgs []
 	if err := watcher.Add(inputFile); err != nil {
 		return fmt.Errorf("failed to watch file %s: %w", inputFile, err)
 	}
-	if configFile != "" {
+	// Book mode: rebuild when any chapter changes
+	chapters := bookChapterFiles(inputFile)
+	for _, ch := range chapters {
+		if err := watcher.Add(ch); err != nil {
+			if opts.Logger != nil {
+				opts.Logger.Warn("failed to watch chapter", "file", ch, "error", err)
+			} else {
+				fmt.Fprintf(os.Stderr, "Warning: failed to watch chapter %s: %v\n", ch, err)
+			}
+		}
+	}
+	for _, configFile := range configFiles {
 		if err := watcher.Add(configFile); err != nil {
 			if opts.Logger != nil {
 				opts.Logger.Warn("failed to watch config file", "file", configFile, "error", err)
 			} else {
-				fmt.Printf("Warning: failed to watch config file %s: %v\n", configFile, err)
+				fmt.Fprintf(os.Stderr, "Warning: failed to watch config file %s: %v\n", configFile, err)
 			}
 		} else {
 			if opts.Logger != nil {
 				opts.Logger.Info("watching config file", "file", configFile)
-			} else {
+			} else if !opts.Quiet {
 				fmt.Printf("Watching config file: %s\n", configFile)
 			}
 		}
@@ -50,12 +60,15 @@ func Watch(ctx context.Context, inputFile string, configFile string, postArgs []
 
 	if opts.Logger != nil {
 		opts.Logger.Info("watching for changes (Press Ctrl+C to stop)", "file", inputFile)
-	} else {
+	} else if !opts.Quiet {
 		fmt.Printf("Watching %s for changes... (Press Ctrl+C to stop)\n", inputFile)
 	}
 
 	// Run initially
-	if err := Process(ctx, inputFile, postArgs, opts, executor); err != nil {
+	start := time.Now()
+	err = Process(ctx, inputFile, postArgs, opts, executor)
+	notifyResult(opts, inputFile, start, err)
+	if err != nil {
 		if opts.Logger != nil {
 			opts.Logger.Error("processing failed", "error", err)
 		} else {
@@ -84,18 +97,24 @@ func Watch(ctx context.Context, inputFile string, configFile string, postArgs []
 				debounceTimer = time.AfterFunc(debounceDuration, func() {
 					if opts.Logger != nil {
 						opts.Logger.Info("file changed, re-running...")
-					} else {
+					} else if !opts.Quiet {
 						fmt.Println("\nFile changed, re-running...")
 					}
 
 					// Re-add watches if they were removed (atomic save)
 					// Add input file to watcher
 					_ = watcher.Add(inputFile)
-					if configFile != "" {
+					for _, ch := range chapters {
+						_ = watcher.Add(ch)
+					}
+					for _, configFile := range configFiles {
 						_ = watcher.Add(configFile)
 					}
 
-					if err := Process(ctx, inputFile, postArgs, opts, executor); err != nil {
+					start := time.Now()
+					err := Process(ctx, inputFile, postArgs, opts, executor)
+					notifyResult(opts, inputFile, start, err)
+					if err != nil {
 						if opts.Logger != nil {
 							opts.Logger.Error("processing failed", "error", err)
 						} else {
@@ -104,7 +123,7 @@ func Watch(ctx context.Context, inputFile string, configFile string, postArgs []
 					} else {
 						if opts.Logger != nil {
 							opts.Logger.Info("done")
-						} else {
+						} else if !opts.Quiet {
 							fmt.Println("Done.")
 						}
 					}
@@ -65,7 +65,7 @@ outputs:
 	// Run Watch in goroutine
 	done := make(chan error)
 	go func() {
-		done <- Watch(ctx, absInput, "", []string{}, opts, mockExec)
+		done <- Watch(ctx, absInput, nil, []string{}, opts, mockExec)
 	}()
 
 	// Wait for startup (initial run)
@@ -0,0 +1,172 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// webhookTimeout bounds how long a webhook request may take.
+const webhookTimeout = 10 * time.Second
+
+// BuildEvent describes a finished run; it is the payload sent to webhooks.
+type BuildEvent struct {
+	// Input is the converted file.
+	Input string `json:"input"`
+	// Status is StatusSuccess or StatusFailed for the run as a whole.
+	Status string `json:"status"`
+	// Error holds the run's error message, if any.
+	Error string `json:"error,omitempty"`
+	// Duration is the wall-clock time of the run.
+	Duration time.Duration `json:"duration_ns"`
+	// Targets lists the per-target results, sorted by target name.
+	Targets []TargetResult `json:"targets"`
+	// Outputs lists the files that were produced.
+	Outputs []string `json:"outputs"`
+}
+
+// webhookConfig is the parsed form of the `webhook` config key.
+type webhookConfig struct {
+	URL     string
+	Payload string
+	Headers map[string]string
+}
+
+// newBuildEvent assembles a BuildEvent from target results.
+//
+// Parameters:
+//   - `inputFile`: the converted file
+//   - `results`: the per-target results
+//   - `duration`: the run's wall-clock time
+//   - `err`: the run's error, if any
+func newBuildEvent(inputFile string, results []TargetResult, duration time.Duration, err error) BuildEvent {
+	sorted := sortedResults(results)
+
+	event := BuildEvent{
+		Input:    inputFile,
+		Status:   StatusSuccess,
+		Duration: duration,
+		Targets:  sorted,
+		Outputs:  []string{},
+	}
+	if err != nil {
+		event.Status = StatusFailed
+		event.Error = err.Error()
+	}
+	for _, r := range sorted {
+		if r.Status == StatusSuccess && r.Output != "" {
+			event.Outputs = append(event.Outputs, r.Output)
+		}
+	}
+	return event
+}
+
+// parseWebhookConfig reads the `webhook` config value, which is either a URL string
+// or a map with `url`, optional `payload` template, and optional `headers`.
+//
+// Parameters:
+//   - `raw`: the raw config value
+func parseWebhookConfig(raw interface{}) (webhookConfig, error) {
+	var wc webhookConfig
+	switch v := raw.(type) {
+	case string:
+		wc.URL = v
+	case map[string]interface{}:
+		wc.URL, _ = v["url"].(string)
+		wc.Payload, _ = v["payload"].(string)
+		if headers, ok := v["headers"].(map[string]interface{}); ok {
+			wc.Headers = make(map[string]string, len(headers))
+			for k, hv := range headers {
+				wc.Headers[k] = fmt.Sprintf("%v", hv)
+			}
+		}
+	default:
+		return wc, fmt.Errorf("webhook must be a URL or a map with a url key")
+	}
+	if wc.URL == "" {
+		return wc, fmt.Errorf("webhook url is empty")
+	}
+	return wc, nil
+}
+
+// webhookFuncs are the functions available to payload templates: `json` encodes a
+// value as a JSON literal, quotes and escapes included.
+var webhookFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// sendWebhook POSTs a build event to the configured webhook.
+// Without a payload template the event is sent as JSON; with one, the template is
+// rendered against the event (e.g. `{"text": {{json .Input}}}`). A rendered payload
+// sent as JSON must be valid JSON.
+//
+// Parameters:
+//   - `ctx`: context for cancellation
+//   - `raw`: the raw `webhook` config value
+//   - `event`: the build event to send
+func sendWebhook(ctx context.Context, raw interface{}, event BuildEvent) error {
+	wc, err := parseWebhookConfig(raw)
+	if err != nil {
+		return err
+	}
+
+	var body []byte
+	if wc.Payload != "" {
+		tmpl, err := template.New("webhook").Funcs(webhookFuncs).Parse(wc.Payload)
+		if err != nil {
+			return fmt.Errorf("invalid webhook payload template: %w", err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, event); err != nil {
+			return fmt.Errorf("failed to render webhook payload: %w", err)
+		}
+		body = buf.Bytes()
+	} else {
+		body, err = json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to encode webhook payload: %w", err)
+		}
+	}
+
+	contentType := "application/json"
+	for k, v := range wc.Headers {
+		if strings.EqualFold(k, "Content-Type") {
+			contentType = v
+		}
+	}
+	if wc.Payload != "" && strings.Contains(contentType, "json") && !json.Valid(body) {
+		return fmt.Errorf("webhook payload is not valid JSON (use {{json .Field}} to quote values): %s", body)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wc.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "panforge")
+	for k, v := range wc.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
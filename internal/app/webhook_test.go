@@ -0,0 +1,84 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSendWebhook(t *testing.T) {
+	var gotBody []byte
+	var gotHeader string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = io.ReadAll(r.Body)
+		gotHeader = r.Header.Get("X-Token")
+	}))
+	defer srv.Close()
+
+	results := []TargetResult{
+		{Target: "pdf", Output: "/out/doc.pdf", Status: StatusSuccess},
+		{Target: "html", Output: "/out/doc.html", Status: StatusFailed, Error: "boom"},
+	}
+	event := newBuildEvent("/in/doc.md", results, time.Second, errors.New("pandoc failed"))
+
+	// Default JSON payload
+	if err := sendWebhook(context.Background(), srv.URL, event); err != nil {
+		t.Fatalf("sendWebhook failed: %v", err)
+	}
+	var decoded BuildEvent
+	if err := json.Unmarshal(gotBody, &decoded); err != nil {
+		t.Fatalf("payload is not JSON: %v", err)
+	}
+	if decoded.Status != StatusFailed || len(decoded.Targets) != 2 || decoded.Targets[0].Target != "html" {
+		t.Errorf("unexpected event: %+v", decoded)
+	}
+	if len(decoded.Outputs) != 1 || decoded.Outputs[0] != "/out/doc.pdf" {
+		t.Errorf("Outputs = %v", decoded.Outputs)
+	}
+
+	// Templated payload with headers
+	hook := map[string]interface{}{
+		"url":     srv.URL,
+		"payload": `{"text": {{json .Input}}, "status": "{{.Status}}"}`,
+		"headers": map[string]interface{}{"X-Token": "secret"},
+	}
+	if err := sendWebhook(context.Background(), hook, event); err != nil {
+		t.Fatalf("sendWebhook with template failed: %v", err)
+	}
+	if string(gotBody) != `{"text": "/in/doc.md", "status": "failed"}` || gotHeader != "secret" {
+		t.Errorf("templated payload = %s, header = %q", gotBody, gotHeader)
+	}
+
+	// Values with quotes and backslashes stay valid JSON through the json function
+	quoted := newBuildEvent(`C:\docs\"draft".md`, results, time.Second, nil)
+	gotBody = nil
+	if err := sendWebhook(context.Background(), hook, quoted); err != nil {
+		t.Fatalf("sendWebhook with quoted input failed: %v", err)
+	}
+	var text struct{ Text string }
+	if err := json.Unmarshal(gotBody, &text); err != nil || text.Text != quoted.Input {
+		t.Errorf("payload = %s (%v)", gotBody, err)
+	}
+
+	// A rendered payload that is not JSON is rejected before it is sent
+	gotBody = nil
+	hook["payload"] = `{"text": "{{.Input}}"}`
+	if err := sendWebhook(context.Background(), hook, quoted); err == nil || gotBody != nil {
+		t.Errorf("expected invalid JSON error without a request, got %v (body %s)", err, gotBody)
+	}
+
+	// Other content types are sent as rendered
+	hook["headers"] = map[string]interface{}{"Content-Type": "text/plain"}
+	if err := sendWebhook(context.Background(), hook, quoted); err != nil || gotBody == nil {
+		t.Errorf("plain text payload: %v", err)
+	}
+
+	if err := sendWebhook(context.Background(), 42, event); err == nil {
+		t.Error("expected error for invalid webhook config")
+	}
+}
@@ -0,0 +1,113 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+// workingDirInput is the --chdir and `working-dir` value that runs pandoc in the
+// document's directory.
+const workingDirInput = "input"
+
+// resolveWorkingDir reads where pandoc runs for a target: --chdir, else the target's
+// `working-dir`, else the global one. "input" is the document's directory; other
+// values are directories, relative to the working directory for --chdir and to the
+// document for `working-dir`. Relative paths in the pandoc arguments, such as
+// bibliographies, templates, and files the LaTeX engine reads with \input, then
+// resolve from there.
+//
+// Parameters:
+//   - `opts`: runtime options
+//   - `cfg`: the global config
+//   - `metaOut`: the format-specific config
+//   - `inputFile`: the document
+//
+// Returns:
+//   - string: the absolute directory, or "" to run pandoc in the working directory
+//   - error: if the directory does not exist
+func resolveWorkingDir(opts options.Options, cfg *config.Config, metaOut map[string]interface{}, inputFile string) (string, error) {
+	dir, base := opts.Chdir, ""
+	if dir == "" {
+		dir, base = stringSetting(cfg, metaOut, "working-dir"), filepath.Dir(inputFile)
+	}
+	switch {
+	case dir == "":
+		return "", nil
+	case dir == workingDirInput:
+		dir = filepath.Dir(inputFile)
+	case !filepath.IsAbs(dir) && base != "":
+		dir = filepath.Join(base, dir)
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(abs); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("working directory %s does not exist", abs)
+	}
+	return abs, nil
+}
+
+// withCommandScope returns an executor whose commands run in dir, with env added to
+// their environment. A RealExecutor is copied; other executors are wrapped and receive
+// the directory and environment through RunIn if they implement it.
+//
+// Parameters:
+//   - `executor`: the executor to scope
+//   - `dir`: the working directory ("" keeps the executor's)
+//   - `env`: "KEY=value" entries to add
+func withCommandScope(executor CommandExecutor, dir string, env []string) CommandExecutor {
+	if dir == "" && len(env) == 0 {
+		return executor
+	}
+	switch e := executor.(type) {
+	case *RealExecutor:
+		scoped := *e
+		if dir != "" {
+			scoped.Dir = dir
+		}
+		scoped.Env = append(append([]string(nil), e.Env...), env...)
+		return &scoped
+	case *scopedExecutor:
+		scoped := *e
+		if dir != "" {
+			scoped.dir = dir
+		}
+		scoped.env = append(append([]string(nil), e.env...), env...)
+		return &scoped
+	}
+	return &scopedExecutor{inner: executor, dir: dir, env: env}
+}
+
+// scopedExecutor runs the commands of another executor in a directory, with extra
+// environment variables; see withCommandScope.
+type scopedExecutor struct {
+	inner CommandExecutor
+	dir   string
+	env   []string
+}
+
+// Run runs a command through the wrapped executor, in the scope's directory.
+func (s *scopedExecutor) Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	if r, ok := s.inner.(dirRunner); ok {
+		return r.RunIn(ctx, s.dir, s.env, name, args, stdout, stderr)
+	}
+	return s.inner.Run(ctx, name, args, stdout, stderr)
+}
+
+// RunIn runs a command through the wrapped executor in dir, with the scope's
+// environment and env.
+func (s *scopedExecutor) RunIn(ctx context.Context, dir string, env []string, name string, args []string, stdout, stderr io.Writer) error {
+	return runInDir(ctx, s.inner, dir, append(append([]string(nil), s.env...), env...), name, args, stdout, stderr)
+}
+
+// RunStdin feeds a command's standard input through the wrapped executor.
+func (s *scopedExecutor) RunStdin(ctx context.Context, name string, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	return runWithStdin(ctx, s.inner, name, args, stdin, stdout, stderr)
+}
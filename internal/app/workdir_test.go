@@ -0,0 +1,117 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+// dirRecorder records the directory every command runs in and writes its output.
+type dirRecorder struct {
+	mu   sync.Mutex
+	dirs []string
+	args [][]string
+}
+
+func (r *dirRecorder) Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	return r.RunIn(ctx, "", nil, name, args, stdout, stderr)
+}
+
+func (r *dirRecorder) RunIn(ctx context.Context, dir string, env []string, name string, args []string, stdout, stderr io.Writer) error {
+	r.mu.Lock()
+	r.dirs = append(r.dirs, dir)
+	r.args = append(r.args, args)
+	r.mu.Unlock()
+	for i, arg := range args {
+		if arg == "--output" && i+1 < len(args) {
+			return os.WriteFile(args[i+1], []byte(name), 0600)
+		}
+	}
+	return nil
+}
+
+func TestResolveWorkingDir(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.MkdirAll(filepath.Join(dir, "doc", "tex"), 0750)
+	input := filepath.Join(dir, "doc", "paper.md")
+	cfg := &config.Config{Generic: map[string]interface{}{}}
+
+	if got, err := resolveWorkingDir(options.Options{}, cfg, nil, input); err != nil || got != "" {
+		t.Errorf("expected no working directory by default, got %q, %v", got, err)
+	}
+	if got, _ := resolveWorkingDir(options.Options{Chdir: "input"}, cfg, nil, input); got != filepath.Join(dir, "doc") {
+		t.Errorf("expected the document's directory, got %q", got)
+	}
+	meta := map[string]interface{}{"working-dir": "tex"}
+	if got, _ := resolveWorkingDir(options.Options{}, cfg, meta, input); got != filepath.Join(dir, "doc", "tex") {
+		t.Errorf("expected working-dir relative to the document, got %q", got)
+	}
+	if got, _ := resolveWorkingDir(options.Options{Chdir: dir}, cfg, meta, input); got != dir {
+		t.Errorf("expected --chdir to win, got %q", got)
+	}
+	if _, err := resolveWorkingDir(options.Options{}, cfg, map[string]interface{}{"working-dir": "missing"}, input); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
+
+func TestWithCommandScope(t *testing.T) {
+	base := &RealExecutor{Env: []string{"A=1"}}
+	scoped, ok := withCommandScope(base, "/tmp", []string{"B=2"}).(*RealExecutor)
+	if !ok || scoped.Dir != "/tmp" || strings.Join(scoped.Env, " ") != "A=1 B=2" {
+		t.Errorf("unexpected scoped executor %+v", scoped)
+	}
+	if base.Dir != "" || len(base.Env) != 1 {
+		t.Errorf("the original executor changed: %+v", base)
+	}
+	if withCommandScope(base, "", nil) != CommandExecutor(base) {
+		t.Error("an empty scope should keep the executor")
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	dir := t.TempDir()
+	var out bytes.Buffer
+	exec := withCommandScope(&RealExecutor{}, dir, []string{"PANFORGE_SCOPE=yes"})
+	if err := exec.Run(context.Background(), "sh", []string{"-c", "pwd; echo $PANFORGE_SCOPE"}, &out, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := filepath.EvalSymlinks(dir); out.String() != got+"\nyes\n" && out.String() != dir+"\nyes\n" {
+		t.Errorf("command ran with %q, want %s and the scope's environment", out.String(), dir)
+	}
+
+	// Other executors get the scope through RunIn
+	rec := &dirRecorder{}
+	if err := withCommandScope(rec, dir, nil).Run(context.Background(), "pandoc", []string{"x"}, io.Discard, io.Discard); err != nil || rec.dirs[0] != dir {
+		t.Errorf("dirs = %v, %v", rec.dirs, err)
+	}
+}
+
+func TestProcess_WorkingDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(dir, "data"))
+	_ = os.MkdirAll(filepath.Join(dir, "doc"), 0750)
+	input := filepath.Join(dir, "doc", "paper.md")
+	_ = os.WriteFile(input, []byte("---\noutputs: [html]\nworking-dir: input\n---\n# Paper\n"), 0600)
+
+	rec := &dirRecorder{}
+	opts := options.Options{NoCache: true, Quiet: true}
+	if _, err := process(context.Background(), input, nil, opts, rec, processEnv{baseDir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.dirs) != 1 || rec.dirs[0] != filepath.Join(dir, "doc") {
+		t.Errorf("expected pandoc to run in the document's directory, got %v", rec.dirs)
+	}
+	if !filepath.IsAbs(rec.args[0][0]) {
+		t.Errorf("expected an absolute input path, got %v", rec.args[0])
+	}
+}
@@ -0,0 +1,489 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+	"github.com/rapjul/panforge/internal/pandoc"
+	"gopkg.in/yaml.v3"
+)
+
+// workspaceDoc is one document of a workspace build and its outcome.
+type workspaceDoc struct {
+	project config.WorkspaceProject
+	input   string
+	name    string
+	results []TargetResult
+	err     error
+	// fingerprint identifies the document's inputs for the build state ("" if unknown).
+	fingerprint string
+}
+
+// RunWorkspace builds every project of a workspace. Documents are processed in parallel
+// and share a single --concurrency limit on pandoc processes; relative output paths are
+// resolved against each document's directory. An aggregated report is written to w.
+//
+// Parameters:
+//   - `ctx`: context for cancellation
+//   - `ws`: the workspace
+//   - `opts`: runtime options shared by all projects
+//   - `executor`: used to run the pandoc commands
+//   - `w`: where the report is written
+//
+// Returns:
+//   - error: the joined errors of all failed documents, or ErrSkipped if a target was skipped
+func RunWorkspace(ctx context.Context, ws *config.Workspace, opts options.Options, executor CommandExecutor, w io.Writer) error {
+	if opts.Output != "" {
+		return configError(fmt.Errorf("--output cannot be used with a workspace build"))
+	}
+	if _, err := reportFormat(opts); err != nil {
+		return configError(err)
+	}
+	if _, err := annotationsFormat(opts); err != nil {
+		return configError(err)
+	}
+
+	var docs []*workspaceDoc
+	for _, p := range ws.Projects {
+		path := p.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(ws.Dir(), path)
+		}
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("workspace project %s: %w", p.Path, err)
+		}
+		files, multi, err := resolveInputs(path, opts)
+		if err != nil {
+			return fmt.Errorf("workspace project %s: %w", p.Path, err)
+		}
+		if !multi {
+			files = []string{path}
+		}
+		for _, f := range files {
+			docs = append(docs, &workspaceDoc{project: p, input: f})
+		}
+	}
+
+	shared, err := newWorkspaceShared(ws)
+	if err != nil {
+		return configError(err)
+	}
+	for _, doc := range docs {
+		doc.name = doc.project.ID()
+		if info, err := os.Stat(filepath.Join(ws.Dir(), doc.project.Path)); err == nil && info.IsDir() {
+			base := filepath.Base(doc.input)
+			doc.name = strings.TrimSuffix(base, filepath.Ext(base))
+		}
+		shared.names[doc.input] = doc.name
+	}
+	for _, doc := range docs {
+		docOpts := opts
+		if len(doc.project.Targets) > 0 {
+			docOpts.Targets = doc.project.Targets
+		}
+		shared.outputs[doc.input] = planOutputs(doc.input, docOpts, shared)
+	}
+
+	// Skip documents unchanged since the last successful build, like make
+	var state *buildState
+	statePath := buildStatePath(ws)
+	if !opts.NoCache {
+		state = loadBuildState(statePath)
+		pandocVersion, _, _ := pandoc.InstalledVersion()
+		sharedFiles := append([]string{ws.Path}, shared.bibliography...)
+		if shared.csl != "" {
+			sharedFiles = append(sharedFiles, shared.csl)
+		}
+		for _, doc := range docs {
+			docOpts := opts
+			if len(doc.project.Targets) > 0 {
+				docOpts.Targets = doc.project.Targets
+			}
+			doc.fingerprint, _ = documentFingerprint(doc.input, sharedFiles, docOpts, pandocVersion)
+		}
+	}
+
+	start := time.Now()
+	sem := newSemaphore(opts.Concurrency)
+	// --archive and --manifest cover the outputs of every document at once
+	archive, manifest := opts.Archive, opts.Manifest
+	opts.Archive, opts.Manifest = "", ""
+	var wg sync.WaitGroup
+	for _, doc := range docs {
+		docOpts := opts
+		if len(doc.project.Targets) > 0 {
+			docOpts.Targets = doc.project.Targets
+		}
+		if state != nil && doc.fingerprint != "" {
+			if results := state.upToDate(doc.input, doc.fingerprint); results != nil {
+				if opts.Logger != nil {
+					opts.Logger.Info("unchanged since the last build, skipping", "file", doc.input)
+				}
+				doc.results = results
+				continue
+			}
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			env := processEnv{sem: sem, baseDir: filepath.Dir(doc.input), workspace: &workspaceEnv{workspaceShared: shared, name: doc.name}}
+			doc.results, doc.err = process(ctx, doc.input, nil, docOpts, executor, env)
+		}()
+	}
+	wg.Wait()
+
+	if state != nil && !opts.DryRun {
+		for _, doc := range docs {
+			if doc.fingerprint != "" {
+				state.record(doc.input, doc.fingerprint, doc.results, doc.err)
+			}
+		}
+		if err := state.save(statePath); err != nil {
+			if opts.Logger != nil {
+				opts.Logger.Warn("failed to save build state", "error", err)
+			} else {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}
+	}
+
+	// A report on stdout replaces the table
+	if !opts.Quiet && !ReportToStdout(opts) {
+		writeWorkspaceReport(w, ws.Dir(), docs, time.Since(start))
+	}
+
+	var errs []error
+	var all []TargetResult
+	failed := 0
+	for _, doc := range docs {
+		all = append(all, doc.results...)
+		if doc.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", doc.input, doc.err))
+			failed++
+		}
+	}
+	if opts.Annotations != "" {
+		writeAnnotations(annotationsWriter(opts), all)
+	}
+	if manifest != "" {
+		if err := finishManifest(manifest, all, time.Since(start), opts); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if archive != "" {
+		if err := finishArchive(archive, all, errors.Join(errs...), opts); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	notifySummary(opts, len(docs), failed, start, errors.Join(errs...))
+	err = skippedError(all, errors.Join(errs...))
+	if rerr := writeReport(opts, all, start, err, w); rerr != nil {
+		return errors.Join(err, rerr)
+	}
+	return err
+}
+
+// writeWorkspaceReport prints a table of every target built in a workspace and a summary line.
+//
+// Parameters:
+//   - `w`: where the report is written
+//   - `root`: the workspace directory, used to shorten paths
+//   - `docs`: the processed documents
+//   - `elapsed`: the total build time
+func writeWorkspaceReport(w io.Writer, root string, docs []*workspaceDoc, elapsed time.Duration) {
+	counts := make(map[string]int)
+	total := 0
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "DOCUMENT\tTARGET\tSTATUS\tTIME\tOUTPUT")
+	for _, doc := range docs {
+		name := relTo(root, doc.input)
+		if len(doc.results) == 0 && doc.err != nil {
+			_, _ = fmt.Fprintf(tw, "%s\t-\t%s\t-\t%v\n", name, StatusFailed, doc.err)
+			counts[StatusFailed]++
+			total++
+			continue
+		}
+		for _, r := range sortedResults(doc.results) {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", name, r.Target, r.Status, r.Duration.Round(time.Millisecond), relTo(root, r.Output))
+			counts[r.Status]++
+			total++
+		}
+	}
+	_ = tw.Flush()
+	_, _ = fmt.Fprintf(w, "\n%d document(s), %d target(s) in %s: %d succeeded, %d up to date, %d skipped, %d failed\n",
+		len(docs), total, elapsed.Round(time.Millisecond),
+		counts[StatusSuccess], counts[StatusUpToDate], counts[StatusSkipped], counts[StatusFailed])
+
+	var all []TargetResult
+	for _, doc := range docs {
+		all = append(all, doc.results...)
+	}
+	writeDiagnostics(w, root, all)
+}
+
+// relTo shortens a path relative to root when possible.
+func relTo(root, path string) string {
+	if path == "" {
+		return "-"
+	}
+	if rel, err := filepath.Rel(root, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// workspaceLink matches the target of a Markdown link to another Markdown file, e.g.
+// [Install](../guide/guide.md#install).
+var workspaceLink = regexp.MustCompile(`\]\(\s*<?([^)\s>#]+\.(?:md|markdown))(#[^)\s>]*)?>?[)\s]`)
+
+// plannedOutput is the output a workspace document will produce for one target.
+type plannedOutput struct {
+	Format string
+	Output string
+}
+
+// workspaceShared is what all documents of a workspace build share. It is filled in
+// before any document is built and only read afterwards.
+type workspaceShared struct {
+	// defaults is the YAML of the workspace defaults, decoded again for each document.
+	defaults []byte
+	// bibliography and csl are absolute paths.
+	bibliography []string
+	csl          string
+	namespaces   bool
+	// names maps each document's path to its name.
+	names map[string]string
+	// outputs maps each document's path to its planned outputs by target.
+	outputs map[string]map[string]plannedOutput
+}
+
+// workspaceEnv is the workspace context of a single document.
+type workspaceEnv struct {
+	*workspaceShared
+	// name is the document's name, used as its identifier prefix.
+	name string
+}
+
+// newWorkspaceShared resolves the shared settings of a workspace.
+//
+// Parameters:
+//   - `ws`: the workspace
+func newWorkspaceShared(ws *config.Workspace) (*workspaceShared, error) {
+	shared := &workspaceShared{
+		namespaces: ws.Namespaces,
+		names:      make(map[string]string),
+		outputs:    make(map[string]map[string]plannedOutput),
+	}
+	if len(ws.Defaults) > 0 {
+		data, err := yaml.Marshal(ws.Defaults)
+		if err != nil {
+			return nil, fmt.Errorf("invalid workspace defaults: %w", err)
+		}
+		shared.defaults = data
+	}
+	abs := func(p string) string {
+		if filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(ws.Dir(), p)
+	}
+	for _, b := range ws.Bibliography {
+		shared.bibliography = append(shared.bibliography, abs(b))
+	}
+	if ws.CSL != "" {
+		shared.csl = abs(ws.CSL)
+	}
+	return shared, nil
+}
+
+// defaultsConfig decodes a fresh copy of the workspace defaults, so documents built in
+// parallel never share (and modify) the same maps.
+func (w *workspaceShared) defaultsConfig() *config.Config {
+	if len(w.defaults) == 0 {
+		return nil
+	}
+	var cfg config.Config
+	if err := yaml.Unmarshal(w.defaults, &cfg); err != nil {
+		return nil
+	}
+	return &cfg
+}
+
+// planOutputs predicts the outputs of a workspace document, so other documents can link
+// to them before they are built.
+//
+// Parameters:
+//   - `input`: the document
+//   - `opts`: the document's options
+//   - `shared`: the workspace settings
+func planOutputs(input string, opts options.Options, shared *workspaceShared) map[string]plannedOutput {
+	planned := make(map[string]plannedOutput)
+	cfg, err := loadDocumentConfig(input, opts.From)
+	if err != nil {
+		return planned
+	}
+	_, projectCfg, _ := config.LoadProjectConfig(input)
+	mergeConfig(cfg, projectCfg)
+	mergeConfig(cfg, shared.defaultsConfig())
+	_, defaultCfg, _ := loadDefaultConfig(opts.Config)
+	mergeConfig(cfg, defaultCfg)
+	if err := config.ResolveTargetExtends(cfg); err != nil {
+		return planned
+	}
+	for _, t := range DetermineTargets(opts, cfg) {
+		fmtStr, metaOut := resolveTarget(cfg, t)
+		metaOut = expandTemplates(metaOut, newTemplateContext(cfg, t, fmtStr, input, time.Time{}))
+		req := namingRequest{Input: input, Target: t, Format: fmtStr, Config: cfg, Meta: metaOut, BaseDir: filepath.Dir(input), Peek: true}
+		name, err := outputFilename(context.Background(), req, configSandboxed(cfg) || isSandboxed(metaOut))
+		if err != nil {
+			continue
+		}
+		if opts.OutputDir != "" && !filepath.IsAbs(name) {
+			name = filepath.Join(opts.OutputDir, name)
+		}
+		out, err := resolveIn(filepath.Dir(input), name)
+		if err != nil {
+			continue
+		}
+		if fit, err := pathFitter(cfg, metaOut, name); err == nil && fit != nil {
+			if out, err = fit(out); err != nil {
+				continue
+			}
+		}
+		planned[t] = plannedOutput{Format: fmtStr, Output: out}
+	}
+	return planned
+}
+
+// pandocArgs returns the shared bibliography, citation style, and identifier prefix
+// arguments for a target, leaving out anything the document or target sets itself.
+//
+// Parameters:
+//   - `cfg`: the document config
+//   - `args`: the target's arguments so far
+//   - `fmtStr`: the target pandoc format
+func (w *workspaceEnv) pandocArgs(cfg *config.Config, args []string, fmtStr string) []string {
+	has := func(flag string) bool {
+		for _, a := range args {
+			if a == flag || strings.HasPrefix(a, flag+"=") {
+				return true
+			}
+		}
+		return false
+	}
+	var extra []string
+	if _, ok := cfg.Generic["bibliography"]; !ok && len(w.bibliography) > 0 && !has("--bibliography") {
+		for _, b := range w.bibliography {
+			extra = append(extra, "--bibliography", b)
+		}
+		if !has("--citeproc") && !has("-C") {
+			extra = append(extra, "--citeproc")
+		}
+	}
+	if _, ok := cfg.Generic["csl"]; !ok && w.csl != "" && !has("--csl") {
+		extra = append(extra, "--csl", w.csl)
+	}
+	if w.namespaces && htmlFormats[fmtStr] && !has("--id-prefix") {
+		extra = append(extra, "--id-prefix", w.name+"-")
+	}
+	return extra
+}
+
+// rewriteLinks points links to other workspace documents at their outputs for the same
+// target (or, failing that, the same format) and writes the result to a per-target copy.
+// With namespaces, anchors in HTML links get the linked document's identifier prefix.
+//
+// Parameters:
+//   - `input`: the document pandoc will read
+//   - `inputFile`: the original document, whose directory links are relative to
+//   - `outputFile`: the target's output path
+//   - `target`: the target name
+//   - `fmtStr`: the target pandoc format
+//
+// Returns:
+//   - string: the path of the copy, or "" if no link was rewritten
+//   - []string: links to workspace documents that have no matching output
+//   - error: any error reading or writing the document
+func (w *workspaceEnv) rewriteLinks(input, inputFile, outputFile, target, fmtStr string) (string, []string, error) {
+	//nolint:gosec // G304: reading the input file is intended
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read input for links: %w", err)
+	}
+	content := string(data)
+
+	var b strings.Builder
+	var unresolved []string
+	last := 0
+	for _, m := range workspaceLink.FindAllStringSubmatchIndex(content, -1) {
+		link := content[m[2]:m[3]]
+		if isRemote(link) {
+			continue
+		}
+		linked := filepath.Clean(filepath.Join(filepath.Dir(inputFile), filepath.FromSlash(link)))
+		outputs, ok := w.outputs[linked]
+		if !ok {
+			continue
+		}
+		out, ok := outputs[target]
+		if !ok {
+			for _, o := range outputs {
+				if o.Format == fmtStr {
+					out, ok = o, true
+					break
+				}
+			}
+		}
+		if !ok {
+			unresolved = append(unresolved, link)
+			continue
+		}
+		rel, err := filepath.Rel(filepath.Dir(outputFile), out.Output)
+		if err != nil {
+			continue
+		}
+		anchor := ""
+		if m[4] >= 0 {
+			anchor = content[m[4]:m[5]]
+			if w.namespaces && htmlFormats[out.Format] && len(anchor) > 1 {
+				anchor = "#" + w.names[linked] + "-" + anchor[1:]
+			}
+		}
+		b.WriteString(content[last:m[2]])
+		b.WriteString(filepath.ToSlash(rel) + anchor)
+		last = m[3]
+		if m[4] >= 0 {
+			last = m[5]
+		}
+	}
+	if last == 0 {
+		return "", unresolved, nil
+	}
+	b.WriteString(content[last:])
+
+	tmp, err := os.CreateTemp(filepath.Dir(input), ".panforge-links-*"+filepath.Ext(input))
+	if err != nil {
+		return "", unresolved, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	if _, err := tmp.WriteString(b.String()); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return "", unresolved, fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", unresolved, fmt.Errorf("failed to close temp file: %w", err)
+	}
+	return tmp.Name(), unresolved, nil
+}
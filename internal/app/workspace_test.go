@@ -0,0 +1,217 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/options"
+)
+
+// outputRecorder simulates pandoc by writing the --output file, recording every output path.
+type outputRecorder struct {
+	mu      sync.Mutex
+	outputs []string
+}
+
+func (r *outputRecorder) Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	for i, arg := range args {
+		if arg == "--output" && i+1 < len(args) {
+			r.mu.Lock()
+			r.outputs = append(r.outputs, args[i+1])
+			r.mu.Unlock()
+			return os.WriteFile(args[i+1], []byte("converted"), 0600)
+		}
+	}
+	return nil
+}
+
+func TestRunWorkspace(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(root, "data"))
+
+	docs := map[string]string{
+		"guide/guide.md":       "---\noutput:\n  html:\n    output: guide.html\n  pdf:\n    output: guide.pdf\n---\n# Guide\n",
+		"reports/q1.md":        "---\noutput:\n  html:\n    output: q1.html\n---\n# Q1\n",
+		"reports/q2.md":        "---\noutput:\n  html:\n    output: q2.html\n---\n# Q2\n",
+		"reports/.drafts/x.md": "---\noutput:\n  html: {}\n---\n",
+	}
+	for name, content := range docs {
+		path := filepath.Join(root, name)
+		_ = os.MkdirAll(filepath.Dir(path), 0750)
+		_ = os.WriteFile(path, []byte(content), 0600)
+	}
+	workFile := filepath.Join(root, config.WorkspaceFileName)
+	_ = os.WriteFile(workFile, []byte("projects:\n  - path: guide/guide.md\n    to: [html]\n  - reports\n"), 0600)
+
+	ws, err := config.LoadWorkspace(workFile)
+	if err != nil {
+		t.Fatalf("LoadWorkspace failed: %v", err)
+	}
+
+	executor := &outputRecorder{}
+	var report bytes.Buffer
+	if err := RunWorkspace(context.Background(), ws, options.Options{Force: true, NoCache: true}, executor, &report); err != nil {
+		t.Fatalf("RunWorkspace failed: %v", err)
+	}
+
+	// Outputs land next to each document, and the project's `to` limits the guide to HTML
+	for _, want := range []string{"guide/guide.html", "reports/q1.html", "reports/q2.html"} {
+		if _, err := os.Stat(filepath.Join(root, want)); err != nil {
+			t.Errorf("expected output %s: %v", want, err)
+		}
+	}
+	if len(executor.outputs) != 3 {
+		t.Errorf("expected 3 pandoc runs, got %v", executor.outputs)
+	}
+
+	out := report.String()
+	if !strings.Contains(out, filepath.Join("reports", "q2.md")) || !strings.Contains(out, "3 document(s), 3 target(s)") || !strings.Contains(out, "3 succeeded") {
+		t.Errorf("unexpected report:\n%s", out)
+	}
+}
+
+func TestRunWorkspace_RejectsOutput(t *testing.T) {
+	ws := &config.Workspace{Path: "/tmp/panforge.work", Projects: []config.WorkspaceProject{{Path: "a.md"}}}
+	if err := RunWorkspace(context.Background(), ws, options.Options{Output: "x.pdf"}, &outputRecorder{}, io.Discard); err == nil {
+		t.Error("expected --output to be rejected")
+	}
+}
+
+// argsRecorder simulates pandoc like outputRecorder and also keeps each run's input
+// content and arguments, keyed by output file name.
+type argsRecorder struct {
+	mu     sync.Mutex
+	inputs map[string]string
+	args   map[string][]string
+}
+
+func (r *argsRecorder) Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	for i, arg := range args {
+		if arg == "--output" && i+1 < len(args) {
+			data, _ := os.ReadFile(args[0])
+			name := filepath.Base(args[i+1])
+			// Record under the final output name rather than the temp file pandoc writes
+			if strings.HasPrefix(name, ".panforge-") {
+				name = strings.SplitN(name, "-", 3)[2]
+			}
+			r.mu.Lock()
+			r.inputs[name] = string(data)
+			r.args[name] = args
+			r.mu.Unlock()
+			return os.WriteFile(args[i+1], []byte("converted"), 0600)
+		}
+	}
+	return nil
+}
+
+func TestRunWorkspace_SharedSettingsAndCrossLinks(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(root, "data"))
+
+	docs := map[string]string{
+		"spec/spec.md":   "---\ntitle: Spec\noutput:\n  html:\n    output: ../site/spec.html\n---\n# Install {#install}\n\nSee the [guide](../guide/guide.md#usage).\n",
+		"guide/guide.md": "---\ntitle: Guide\nbibliography: own.bib\n---\n# Usage\n\nRead [the spec](../spec/spec.md#install) and [notes](notes.md).\n",
+	}
+	for name, content := range docs {
+		path := filepath.Join(root, name)
+		_ = os.MkdirAll(filepath.Dir(path), 0750)
+		_ = os.WriteFile(path, []byte(content), 0600)
+	}
+	_ = os.MkdirAll(filepath.Join(root, "site", "guide"), 0750)
+	workFile := filepath.Join(root, config.WorkspaceFileName)
+	work := `bibliography: refs.bib
+namespaces: true
+defaults:
+  output:
+    html:
+      output: ../site/guide/index.html
+projects:
+  - spec/spec.md
+  - path: guide/guide.md
+    name: user-guide
+`
+	_ = os.WriteFile(workFile, []byte(work), 0600)
+
+	ws, err := config.LoadWorkspace(workFile)
+	if err != nil {
+		t.Fatalf("LoadWorkspace failed: %v", err)
+	}
+	executor := &argsRecorder{inputs: map[string]string{}, args: map[string][]string{}}
+	if err := RunWorkspace(context.Background(), ws, options.Options{Force: true, NoCache: true, Quiet: true}, executor, io.Discard); err != nil {
+		t.Fatalf("RunWorkspace failed: %v", err)
+	}
+
+	// The guide takes its output from the workspace defaults; links follow the outputs.
+	spec := executor.inputs["spec.html"]
+	if !strings.Contains(spec, "[guide](guide/index.html#user-guide-usage)") {
+		t.Errorf("spec link not rewritten:\n%s", spec)
+	}
+	guide := executor.inputs["index.html"]
+	if !strings.Contains(guide, "[the spec](../spec.html#spec-install)") || !strings.Contains(guide, "[notes](notes.md)") {
+		t.Errorf("guide links not rewritten:\n%s", guide)
+	}
+
+	specArgs := strings.Join(executor.args["spec.html"], " ")
+	if !strings.Contains(specArgs, "--bibliography "+filepath.Join(root, "refs.bib")) || !strings.Contains(specArgs, "--citeproc") || !strings.Contains(specArgs, "--id-prefix spec-") {
+		t.Errorf("spec is missing shared arguments: %s", specArgs)
+	}
+	guideArgs := strings.Join(executor.args["index.html"], " ")
+	if strings.Contains(guideArgs, "refs.bib") || !strings.Contains(guideArgs, "--id-prefix user-guide-") {
+		t.Errorf("guide should keep its own bibliography: %s", guideArgs)
+	}
+}
+
+func TestRunWorkspace_SkipsUnchangedDocuments(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("APPDATA", filepath.Join(root, "data"))
+	doc := filepath.Join(root, "doc.md")
+	_ = os.WriteFile(doc, []byte("---\noutput:\n  html:\n    output: doc.html\n---\n# Doc\n"), 0600)
+	workFile := filepath.Join(root, config.WorkspaceFileName)
+	_ = os.WriteFile(workFile, []byte("projects:\n  - doc.md\n"), 0600)
+	ws, err := config.LoadWorkspace(workFile)
+	if err != nil {
+		t.Fatalf("LoadWorkspace failed: %v", err)
+	}
+
+	build := func() (*argsRecorder, string) {
+		t.Helper()
+		rec := &argsRecorder{inputs: map[string]string{}, args: map[string][]string{}}
+		var report bytes.Buffer
+		captureStdout(t, func() {
+			if err := RunWorkspace(context.Background(), ws, options.Options{Force: true}, rec, &report); err != nil {
+				t.Fatalf("RunWorkspace failed: %v", err)
+			}
+		})
+		return rec, report.String()
+	}
+
+	if rec, _ := build(); len(rec.args) != 1 {
+		t.Fatalf("expected the first build to run pandoc, got %v", rec.args)
+	}
+	state := loadBuildState(buildStatePath(ws))
+	if _, ok := state.Files[doc]; !ok {
+		t.Fatalf("expected the build to be recorded, got %+v", state)
+	}
+	rec, report := build()
+	if len(rec.args) != 0 || !strings.Contains(report, "1 up to date") {
+		t.Errorf("expected the unchanged document to be skipped, ran %v:\n%s", rec.args, report)
+	}
+
+	// Editing the document, or removing its output, rebuilds it
+	_ = os.WriteFile(doc, []byte("---\noutput:\n  html:\n    output: doc.html\n---\n# Changed\n"), 0600)
+	if rec, _ := build(); len(rec.args) != 1 {
+		t.Errorf("expected the edited document to be rebuilt, got %v", rec.args)
+	}
+	_ = os.Remove(filepath.Join(root, "doc.html"))
+	_, _ = build()
+	if _, err := os.Stat(filepath.Join(root, "doc.html")); err != nil {
+		t.Errorf("expected a missing output to be rebuilt: %v", err)
+	}
+}
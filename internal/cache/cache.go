@@ -0,0 +1,586 @@
+// Package cache stores build records used to skip conversions whose inputs have not changed.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rapjul/panforge/internal/config"
+)
+
+// Record describes the last successful build of one output file.
+type Record struct {
+	// Key is the hash of everything that influenced the build (input content, arguments, pandoc version).
+	Key string `json:"key"`
+	// Input is the source file that was converted.
+	Input string `json:"input"`
+	// Target is the target name that produced the output.
+	Target string `json:"target"`
+	// Output is the absolute path of the produced file.
+	Output string `json:"output"`
+	// OutputHash is the SHA-256 of the produced file, used to detect external modification.
+	OutputHash string `json:"output_hash"`
+	// BuiltAt is when the build finished.
+	BuiltAt time.Time `json:"built_at"`
+	// Path is the record's own file (not stored).
+	Path string `json:"-"`
+}
+
+// Problem describes a cache record that no longer matches the filesystem.
+type Problem struct {
+	// Record is the affected record (only Path is set for corrupt records).
+	Record Record
+	// Reason explains why the record is stale.
+	Reason string
+}
+
+// Stats are cumulative cache counters, kept in the cache directory.
+type Stats struct {
+	// Hits counts targets skipped because their output was up to date.
+	Hits int64 `json:"hits"`
+	// Restored counts targets whose output was copied from a build with the same key,
+	// e.g. on another branch or in another worktree.
+	Restored int64 `json:"restored"`
+	// Misses counts targets that had to be converted.
+	Misses int64 `json:"misses"`
+	// Since is when counting started.
+	Since time.Time `json:"since"`
+}
+
+// HitRate returns the fraction of lookups served from the cache (0 if there were none).
+func (s Stats) HitRate() float64 {
+	total := s.Hits + s.Restored + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits+s.Restored) / float64(total)
+}
+
+// DefaultMaxSize is the default limit on the total size of stored outputs.
+const DefaultMaxSize int64 = 1 << 30
+
+// Cache is a directory of build records keyed by output path, plus copies of built
+// outputs keyed by content hash so identical builds elsewhere can be restored.
+type Cache struct {
+	// Dir is the cache root directory.
+	Dir string
+	// MaxSize limits the total size of stored outputs in bytes; the least recently
+	// used ones are removed when a new output exceeds it. 0 means no limit.
+	MaxSize int64
+}
+
+// DefaultDir returns the build cache directory (see config.CacheDirName).
+func DefaultDir() string {
+	return config.CacheDirName()
+}
+
+// New returns a cache rooted at dir.
+//
+// Parameters:
+//   - `dir`: the cache root directory (created on first write)
+func New(dir string) *Cache {
+	return &Cache{Dir: dir, MaxSize: DefaultMaxSize}
+}
+
+// ComputeKey hashes the given parts into a single cache key.
+//
+// Parameters:
+//   - `parts`: the values that influence a build, in a stable order
+func ComputeKey(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		// Length-prefix each part so ("ab", "c") and ("a", "bc") differ
+		_, _ = fmt.Fprintf(h, "%d:%s;", len(p), p)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// HashFile returns the hex SHA-256 of a file's contents.
+//
+// Parameters:
+//   - `path`: the file to hash
+func HashFile(path string) (string, error) {
+	//nolint:gosec // G304: hashing user files is intended
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// RecordsDir returns the directory holding build records.
+func (c *Cache) RecordsDir() string {
+	return filepath.Join(c.Dir, "records")
+}
+
+// ObjectsDir returns the directory holding copies of built outputs, keyed by build key.
+func (c *Cache) ObjectsDir() string {
+	return filepath.Join(c.Dir, "objects")
+}
+
+// statsPath returns the file holding the cumulative Stats.
+func (c *Cache) statsPath() string {
+	return filepath.Join(c.Dir, "stats.json")
+}
+
+// objectPath returns the stored output for a build key.
+//
+// Parameters:
+//   - `key`: the build key
+func (c *Cache) objectPath(key string) string {
+	if len(key) < 3 {
+		return filepath.Join(c.ObjectsDir(), key)
+	}
+	return filepath.Join(c.ObjectsDir(), key[:2], key[2:])
+}
+
+// recordPath returns the record file for an output path.
+//
+// Parameters:
+//   - `output`: the absolute output path
+func (c *Cache) recordPath(output string) string {
+	sum := sha256.Sum256([]byte(output))
+	return filepath.Join(c.RecordsDir(), hex.EncodeToString(sum[:])+".json")
+}
+
+// Lookup returns the build record for an output path.
+//
+// Parameters:
+//   - `output`: the absolute output path
+//
+// Returns:
+//   - *Record: the stored record
+//   - error: os.ErrNotExist if there is none, or a decoding error
+func (c *Cache) Lookup(output string) (*Record, error) {
+	data, err := os.ReadFile(c.recordPath(output))
+	if err != nil {
+		return nil, err
+	}
+	var rec Record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("corrupt cache record for %s: %w", output, err)
+	}
+	return &rec, nil
+}
+
+// IsFresh reports whether output was built with the given key and is still unmodified.
+//
+// Parameters:
+//   - `output`: the absolute output path
+//   - `key`: the key computed for the pending build
+func (c *Cache) IsFresh(output, key string) bool {
+	rec, err := c.Lookup(output)
+	if err != nil || rec.Key != key {
+		return false
+	}
+	hash, err := HashFile(output)
+	if err != nil {
+		return false
+	}
+	return hash == rec.OutputHash
+}
+
+// Store writes a build record, hashing the output file, and keeps a copy of the
+// output within MaxSize.
+//
+// Parameters:
+//   - `rec`: the record to store (OutputHash and BuiltAt are filled in)
+func (c *Cache) Store(rec Record) error {
+	hash, err := HashFile(rec.Output)
+	if err != nil {
+		return fmt.Errorf("failed to hash output: %w", err)
+	}
+	rec.OutputHash = hash
+	rec.BuiltAt = time.Now()
+
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.RecordsDir(), 0750); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	added, err := c.storeObject(rec.Key, rec.Output)
+	if err != nil {
+		return err
+	}
+	if added {
+		if err := c.trimObjects(c.objectPath(rec.Key)); err != nil {
+			return fmt.Errorf("failed to trim cache: %w", err)
+		}
+	}
+	//nolint:gosec // G306: cache records are not sensitive
+	return os.WriteFile(c.recordPath(rec.Output), data, 0644)
+}
+
+// storeObject keeps a copy of an output under its build key, unless one already exists.
+//
+// Parameters:
+//   - `key`: the build key
+//   - `output`: the output file to copy
+//
+// Returns:
+//   - bool: true if a new copy was stored
+//   - error: any error copying the output
+func (c *Cache) storeObject(key, output string) (bool, error) {
+	dest := c.objectPath(key)
+	if _, err := os.Stat(dest); err == nil {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0750); err != nil {
+		return false, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return true, copyFile(output, dest)
+}
+
+// trimObjects removes the least recently used stored outputs until their total size
+// is within MaxSize. Restore marks an output as used.
+//
+// Parameters:
+//   - `keep`: the stored output that is never removed (the one just added)
+func (c *Cache) trimObjects(keep string) error {
+	if c.MaxSize <= 0 {
+		return nil
+	}
+	type object struct {
+		path string
+		size int64
+		used time.Time
+	}
+	var objects []object
+	var total int64
+	err := filepath.WalkDir(c.ObjectsDir(), func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+		if path != keep {
+			objects = append(objects, object{path: path, size: info.Size(), used: info.ModTime()})
+		}
+		return nil
+	})
+	if err != nil || total <= c.MaxSize {
+		return err
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].used.Before(objects[j].used) })
+	for _, o := range objects {
+		if total <= c.MaxSize {
+			break
+		}
+		if err := os.Remove(o.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		total -= o.size
+	}
+	return nil
+}
+
+// Restore copies the output of an earlier build with the same key to output, with
+// the mode the output was built with. This makes a build done on another branch or
+// in another worktree a cache hit.
+//
+// Parameters:
+//   - `key`: the key computed for the pending build
+//   - `output`: the absolute output path to write
+//
+// Returns:
+//   - bool: true if a stored output was restored
+//   - error: any error copying the stored output
+func (c *Cache) Restore(key, output string) (bool, error) {
+	src := c.objectPath(key)
+	if _, err := os.Stat(src); err != nil {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(output), 0750); err != nil {
+		return false, fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if err := copyFile(src, output); err != nil {
+		return false, fmt.Errorf("failed to restore %s from cache: %w", output, err)
+	}
+	// Mark the stored output as recently used, so trimObjects keeps it longer
+	now := time.Now()
+	_ = os.Chtimes(src, now, now)
+	return true, nil
+}
+
+// copyFile copies src to dest, with src's permissions, through a temporary file, so
+// dest is never left half-written.
+//
+// Parameters:
+//   - `src`: the file to copy
+//   - `dest`: the destination path
+func copyFile(src, dest string) error {
+	//nolint:gosec // G304: copying build outputs is intended
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".panforge-cache-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, in); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+// ReadStats returns the cumulative cache counters (zero if none were recorded yet).
+func (c *Cache) ReadStats() (Stats, error) {
+	var st Stats
+	data, err := os.ReadFile(c.statsPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return st, nil
+		}
+		return st, err
+	}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return Stats{}, fmt.Errorf("corrupt cache stats: %w", err)
+	}
+	return st, nil
+}
+
+// AddStats adds a run's counters to the cumulative Stats.
+//
+// Parameters:
+//   - `delta`: the counters to add (Since is ignored)
+func (c *Cache) AddStats(delta Stats) error {
+	st, err := c.ReadStats()
+	if err != nil {
+		st = Stats{} // start over after corruption
+	}
+	if st.Since.IsZero() {
+		st.Since = time.Now()
+	}
+	st.Hits += delta.Hits
+	st.Restored += delta.Restored
+	st.Misses += delta.Misses
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.Dir, 0750); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	//nolint:gosec // G306: cache stats are not sensitive
+	return os.WriteFile(c.statsPath(), data, 0644)
+}
+
+// Objects returns the number and total size of stored outputs.
+func (c *Cache) Objects() (int, int64, error) {
+	var count int
+	var size int64
+	err := filepath.WalkDir(c.ObjectsDir(), func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		count++
+		size += info.Size()
+		return nil
+	})
+	return count, size, err
+}
+
+// Records lists all build records, sorted by output path.
+//
+// Returns:
+//   - []Record: the readable records
+//   - []Problem: records that could not be decoded
+//   - error: any error listing the records directory
+func (c *Cache) Records() ([]Record, []Problem, error) {
+	entries, err := os.ReadDir(c.RecordsDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	var records []Record
+	var corrupt []Problem
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(c.RecordsDir(), e.Name())
+		//nolint:gosec // G304: reading files from the cache directory is intended
+		data, err := os.ReadFile(path)
+		var rec Record
+		if err == nil {
+			err = json.Unmarshal(data, &rec)
+		}
+		if err != nil {
+			corrupt = append(corrupt, Problem{Record: Record{Path: path}, Reason: "corrupt record"})
+			continue
+		}
+		rec.Path = path
+		records = append(records, rec)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Output < records[j].Output })
+	return records, corrupt, nil
+}
+
+// Size returns the total size in bytes and number of files under the cache directory.
+func (c *Cache) Size() (int64, int, error) {
+	var size int64
+	var files int
+	err := filepath.WalkDir(c.Dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		files++
+		return nil
+	})
+	return size, files, err
+}
+
+// Verify checks every record against the filesystem and returns the stale ones.
+func (c *Cache) Verify() ([]Problem, error) {
+	records, problems, err := c.Records()
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range records {
+		if reason := staleReason(rec); reason != "" {
+			problems = append(problems, Problem{Record: rec, Reason: reason})
+		}
+	}
+	return problems, nil
+}
+
+// staleReason explains why a record no longer matches the filesystem, or returns "".
+//
+// Parameters:
+//   - `rec`: the record to check
+func staleReason(rec Record) string {
+	if rec.Input != "" {
+		if _, err := os.Stat(rec.Input); err != nil {
+			return "input missing"
+		}
+	}
+	hash, err := HashFile(rec.Output)
+	if err != nil {
+		return "output missing"
+	}
+	if hash != rec.OutputHash {
+		return "output modified"
+	}
+	return ""
+}
+
+// Clean removes build records.
+//
+// Parameters:
+//   - `staleOnly`: remove only records reported by Verify instead of the whole cache
+//
+// Returns:
+//   - int: the number of records removed
+//   - error: any error removing files
+func (c *Cache) Clean(staleOnly bool) (int, error) {
+	if !staleOnly {
+		records, corrupt, err := c.Records()
+		if err != nil {
+			return 0, err
+		}
+		if err := os.RemoveAll(c.Dir); err != nil {
+			return 0, err
+		}
+		return len(records) + len(corrupt), nil
+	}
+
+	problems, err := c.Verify()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, p := range problems {
+		if err := os.Remove(p.Record.Path); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, c.pruneObjects()
+}
+
+// pruneObjects removes stored outputs that no remaining record refers to.
+func (c *Cache) pruneObjects() error {
+	records, _, err := c.Records()
+	if err != nil {
+		return err
+	}
+	keep := make(map[string]bool, len(records))
+	for _, rec := range records {
+		keep[c.objectPath(rec.Key)] = true
+	}
+	return filepath.WalkDir(c.ObjectsDir(), func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || keep[path] {
+			return nil
+		}
+		return os.Remove(path)
+	})
+}
@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheFreshness(t *testing.T) {
+	dir := t.TempDir()
+	c := New(filepath.Join(dir, "cache"))
+	output := filepath.Join(dir, "doc.html")
+	key := ComputeKey("content", "--to", "html")
+
+	if c.IsFresh(output, key) {
+		t.Fatal("empty cache reported a fresh output")
+	}
+
+	_ = os.WriteFile(output, []byte("<p>hi</p>"), 0600)
+	if err := c.Store(Record{Key: key, Output: output, Target: "html"}); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	if !c.IsFresh(output, key) {
+		t.Error("stored output not reported fresh")
+	}
+	if c.IsFresh(output, ComputeKey("changed")) {
+		t.Error("different key reported fresh")
+	}
+
+	// External modification of the output invalidates the record
+	_ = os.WriteFile(output, []byte("<p>edited</p>"), 0600)
+	if c.IsFresh(output, key) {
+		t.Error("modified output reported fresh")
+	}
+}
+
+func TestComputeKey(t *testing.T) {
+	if ComputeKey("ab", "c") == ComputeKey("a", "bc") {
+		t.Error("ComputeKey is ambiguous across part boundaries")
+	}
+}
+
+func TestCacheVerifyAndClean(t *testing.T) {
+	dir := t.TempDir()
+	c := New(filepath.Join(dir, "cache"))
+	input := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(input, []byte("# Doc"), 0600)
+
+	good := filepath.Join(dir, "good.html")
+	gone := filepath.Join(dir, "gone.html")
+	for _, out := range []string{good, gone} {
+		_ = os.WriteFile(out, []byte("x"), 0600)
+		if err := c.Store(Record{Key: "k", Input: input, Output: out}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = os.Remove(gone)
+	_ = os.WriteFile(filepath.Join(c.RecordsDir(), "broken.json"), []byte("{"), 0600)
+
+	records, corrupt, err := c.Records()
+	if err != nil || len(records) != 2 || len(corrupt) != 1 {
+		t.Fatalf("Records() = %d records, %d corrupt, %v", len(records), len(corrupt), err)
+	}
+
+	problems, err := c.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 2 {
+		t.Fatalf("Verify() found %d problems, want 2: %+v", len(problems), problems)
+	}
+
+	removed, err := c.Clean(true)
+	if err != nil || removed != 2 {
+		t.Fatalf("Clean(stale) = %d, %v", removed, err)
+	}
+	if !c.IsFresh(good, "k") {
+		t.Error("Clean(stale) removed a valid record")
+	}
+
+	if _, err := c.Clean(false); err != nil {
+		t.Fatal(err)
+	}
+	if _, files, _ := c.Size(); files != 0 {
+		t.Errorf("cache not empty after Clean(false): %d files", files)
+	}
+}
+
+func TestCacheRestoreAndStats(t *testing.T) {
+	dir := t.TempDir()
+	c := New(filepath.Join(dir, "cache"))
+	key := ComputeKey("content")
+
+	first := filepath.Join(dir, "a", "doc.html")
+	_ = os.MkdirAll(filepath.Dir(first), 0750)
+	_ = os.WriteFile(first, []byte("<p>built</p>"), 0600)
+	if err := c.Store(Record{Key: key, Output: first}); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	second := filepath.Join(dir, "b", "doc.html")
+	if ok, err := c.Restore(ComputeKey("other"), second); ok || err != nil {
+		t.Errorf("Restore of unknown key = %v, %v", ok, err)
+	}
+	if ok, err := c.Restore(key, second); !ok || err != nil {
+		t.Fatalf("Restore = %v, %v", ok, err)
+	}
+	if data, _ := os.ReadFile(second); string(data) != "<p>built</p>" {
+		t.Errorf("restored content = %q", data)
+	}
+	if info, err := os.Stat(second); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("restored output should keep the built mode, got %v (%v)", info.Mode(), err)
+	}
+	if n, _, _ := c.Objects(); n != 1 {
+		t.Errorf("expected 1 stored output, got %d", n)
+	}
+
+	_ = c.AddStats(Stats{Hits: 2, Misses: 1})
+	_ = c.AddStats(Stats{Restored: 1})
+	st, err := c.ReadStats()
+	if err != nil || st.Hits != 2 || st.Restored != 1 || st.Misses != 1 || st.Since.IsZero() {
+		t.Errorf("unexpected stats %+v, %v", st, err)
+	}
+	if st.HitRate() != 0.75 {
+		t.Errorf("HitRate() = %v", st.HitRate())
+	}
+
+	// Stored outputs without a record are pruned by clean --stale
+	_ = os.Remove(first)
+	if _, err := c.Clean(true); err != nil {
+		t.Fatalf("Clean failed: %v", err)
+	}
+	if n, _, _ := c.Objects(); n != 0 {
+		t.Errorf("expected orphaned outputs to be pruned, %d left", n)
+	}
+}
+
+func TestCacheMaxSize(t *testing.T) {
+	dir := t.TempDir()
+	c := New(filepath.Join(dir, "cache"))
+	c.MaxSize = 20
+
+	store := func(name, content string, age time.Duration) string {
+		t.Helper()
+		out := filepath.Join(dir, name)
+		_ = os.WriteFile(out, []byte(content), 0600)
+		key := ComputeKey(name)
+		if err := c.Store(Record{Key: key, Output: out}); err != nil {
+			t.Fatalf("Store failed: %v", err)
+		}
+		used := time.Now().Add(-age)
+		_ = os.Chtimes(c.objectPath(key), used, used)
+		return key
+	}
+	oldest := store("a.html", "0123456789", 2*time.Hour)
+	recent := store("b.html", "0123456789", time.Hour)
+
+	// Restoring marks an output as used, so the other one is the least recently used
+	if ok, err := c.Restore(oldest, filepath.Join(dir, "restored.html")); !ok || err != nil {
+		t.Fatalf("Restore = %v, %v", ok, err)
+	}
+	store("c.html", "0123456789", 0)
+
+	if n, size, _ := c.Objects(); n != 2 || size > c.MaxSize {
+		t.Errorf("expected 2 stored outputs within %d bytes, got %d (%d bytes)", c.MaxSize, n, size)
+	}
+	if ok, _ := c.Restore(recent, filepath.Join(dir, "gone.html")); ok {
+		t.Error("least recently used output should have been removed")
+	}
+	if ok, _ := c.Restore(oldest, filepath.Join(dir, "kept.html")); !ok {
+		t.Error("recently restored output should have been kept")
+	}
+}
@@ -29,7 +29,9 @@ type Config struct {
 	Generic map[string]interface{} `yaml:",inline"`
 }
 
-// LoadConfig loads the YAML configuration from a file.
+// LoadConfig loads the configuration from a file, with the files it includes (see
+// resolveIncludes) and extends (see resolveExtends). The extension picks the format (see parseConfigFile). Environment variable references in config files are expanded (see
+// expandEnv).
 //
 // Parameters:
 //   - `path`: the file path to the configuration file
@@ -48,24 +50,46 @@ func LoadConfig(path string) (string, *Config, error) {
 	if err != nil {
 		return "", nil, err
 	}
-	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return absPath, nil, fmt.Errorf("error parsing YAML in '%s': %w", absPath, err)
+	cfg, err := parseConfigFile(absPath, data)
+	if err != nil {
+		return absPath, nil, fmt.Errorf("error parsing %s in '%s': %w", formatName(absPath), absPath, err)
+	}
+	if expandsEnv(absPath) {
+		if err := expandEnv(cfg, absPath); err != nil {
+			return absPath, nil, err
+		}
+	}
+	if err := resolveIncludes(cfg, absPath, nil); err != nil {
+		return absPath, nil, err
 	}
-	return absPath, &cfg, nil
+	if err := resolveExtends(cfg, absPath, nil); err != nil {
+		return absPath, nil, err
+	}
+	return absPath, cfg, nil
 }
 
-// DataDirName returns the data directory for panforge.
-// It checks APPDATA environment variable first, then defaults to ~/.panforge.
-func DataDirName() string {
-	if appData := os.Getenv("APPDATA"); appData != "" {
-		return filepath.Join(appData, "panforge")
+// ParseConfig parses a YAML configuration, such as the YAML header of a document read
+// from stdin. A document may also start with TOML (`+++`) or JSON front matter.
+//
+// Parameters:
+//   - `data`: the YAML text, or a Markdown document starting with front matter
+//
+// Returns:
+//   - *Config: the parsed configuration struct
+//   - error: if the YAML is invalid
+func ParseConfig(data []byte) (*Config, error) {
+	if format, header, _ := splitForeignFrontmatter(data); format != "" {
+		return parseForeignFrontmatter(format, header)
 	}
-	home, _ := os.UserHomeDir()
-	return filepath.Join(home, ".panforge")
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
 }
 
-// LoadDefaultConfig tries to load a default YAML configuration by name or path.
+// LoadDefaultConfig tries to load a default configuration by name or path. A name is
+// looked up in the data directory with each of the ConfigExts.
 //
 // Parameters:
 //   - `name`: either a direct file path or the name of a config file in the default data directory
@@ -87,10 +111,53 @@ func LoadDefaultConfig(name string) (string, *Config, error) {
 		return "", nil, fmt.Errorf("could not find file %s", name)
 	}
 
-	// look in ~/.panforge/
-	path := filepath.Join(DataDirName(), name+".yaml")
-	if _, err := os.Stat(path); err == nil {
+	// look in the data directory
+	if path := FindDefaultConfig(name); path != "" {
 		return LoadConfig(path)
 	}
 	return "", &Config{}, nil
 }
+
+// FindDefaultConfig looks up a named config in the data directory, trying each of the
+// ConfigExts.
+//
+// Parameters:
+//   - `name`: the config's name, e.g. "default"
+//
+// Returns:
+//   - string: the config's path, or "" if there is none
+func FindDefaultConfig(name string) string {
+	for _, ext := range ConfigExts {
+		path := filepath.Join(DataDirName(), name+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// SplitFrontmatter separates a leading YAML metadata block from the document body.
+//
+// Parameters:
+//   - `content`: the full document text
+//
+// Returns:
+//   - string: the frontmatter including its `---` delimiters (empty if none)
+//   - string: the remaining document body
+func SplitFrontmatter(content string) (string, string) {
+	if !strings.HasPrefix(content, "---\n") && !strings.HasPrefix(content, "---\r\n") {
+		return "", content
+	}
+	lines := strings.SplitAfter(content, "\n")
+	for i := 1; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r\n")
+		if line == "---" || line == "..." {
+			end := 0
+			for _, l := range lines[:i+1] {
+				end += len(l)
+			}
+			return content[:end], content[end:]
+		}
+	}
+	return "", content
+}
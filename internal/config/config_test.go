@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -71,3 +72,83 @@ func TestLoadDefaultConfig_NotFound(t *testing.T) {
 		t.Error("LoadDefaultConfig returned nil config")
 	}
 }
+
+func TestSplitFrontmatter(t *testing.T) {
+	fm, body := SplitFrontmatter("---\ntitle: x\n---\n# Body\n")
+	if fm != "---\ntitle: x\n---\n" || body != "# Body\n" {
+		t.Errorf("SplitFrontmatter() = %q, %q", fm, body)
+	}
+
+	fm, body = SplitFrontmatter("# No header\n")
+	if fm != "" || body != "# No header\n" {
+		t.Errorf("SplitFrontmatter() without header = %q, %q", fm, body)
+	}
+}
+
+func TestLoadWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, WorkspaceFileName)
+	_ = os.WriteFile(path, []byte("projects:\n  - docs\n  - path: book/book.md\n    to: [pdf, epub]\n"), 0600)
+
+	ws, err := LoadWorkspace(path)
+	if err != nil {
+		t.Fatalf("LoadWorkspace failed: %v", err)
+	}
+	if len(ws.Projects) != 2 || ws.Projects[0].Path != "docs" || ws.Projects[1].Path != "book/book.md" {
+		t.Errorf("unexpected projects: %+v", ws.Projects)
+	}
+	if len(ws.Projects[1].Targets) != 2 || ws.Projects[1].Targets[0] != "pdf" {
+		t.Errorf("unexpected targets: %v", ws.Projects[1].Targets)
+	}
+
+	// Found from a nested directory
+	nested := filepath.Join(dir, "docs", "chapter")
+	_ = os.MkdirAll(nested, 0750)
+	found, err := FindWorkspace(nested)
+	if err != nil || found != path {
+		t.Errorf("FindWorkspace() = %q, %v; want %q", found, err, path)
+	}
+
+	// Shared settings, with a single bibliography written as a string
+	_ = os.WriteFile(path, []byte("bibliography: refs.bib\nnamespaces: true\ndefaults:\n  filename-template: out\nprojects:\n  - path: spec/spec.md\n    name: spec-v2\n  - guide/guide.md\n"), 0600)
+	ws, err = LoadWorkspace(path)
+	if err != nil {
+		t.Fatalf("LoadWorkspace failed: %v", err)
+	}
+	if len(ws.Bibliography) != 1 || ws.Bibliography[0] != "refs.bib" || !ws.Namespaces || ws.Defaults["filename-template"] != "out" {
+		t.Errorf("unexpected shared settings: %+v", ws)
+	}
+	if ws.Projects[0].ID() != "spec-v2" || ws.Projects[1].ID() != "guide" {
+		t.Errorf("unexpected project IDs: %q, %q", ws.Projects[0].ID(), ws.Projects[1].ID())
+	}
+
+	_ = os.WriteFile(path, []byte("projects: []\n"), 0600)
+	if _, err := LoadWorkspace(path); err == nil {
+		t.Error("expected an error for a workspace without projects")
+	}
+}
+
+func TestLoadProjectConfig(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.MkdirAll(filepath.Join(dir, "docs", "guide"), 0750)
+	input := filepath.Join(dir, "docs", "guide", "intro.md")
+
+	if path, cfg, err := LoadProjectConfig(input); path != "" || cfg != nil || err != nil {
+		t.Errorf("expected no project config, got %q %v %v", path, cfg, err)
+	}
+
+	_ = os.WriteFile(filepath.Join(dir, ProjectFileName), []byte("toc: true\n"), 0600)
+	path, cfg, err := LoadProjectConfig(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(dir, ProjectFileName) || cfg.Generic["toc"] != true {
+		t.Errorf("expected the config of the parent directory, got %q %v", path, cfg)
+	}
+
+	nearer := filepath.Join(dir, "docs", ProjectFileName)
+	_ = os.WriteFile(nearer, []byte("toc: false\n"), 0600)
+	if path, _, _ := LoadProjectConfig(input); path != nearer {
+		t.Errorf("expected the nearest project config, got %q", path)
+	}
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// legacyDirName is the directory in the home directory that held all of panforge's
+// files before the platform's locations were used.
+const legacyDirName = ".panforge"
+
+// DataDirName returns the data directory for panforge, which holds the default configs,
+// recipes, synced bundles, and run records. APPDATA wins when it is set. Otherwise it is
+// $XDG_CONFIG_HOME/panforge (~/.config/panforge) on Linux and other Unix systems, and
+// ~/Library/Application Support/panforge on macOS unless XDG_CONFIG_HOME is set. The
+// legacy ~/.panforge is used while it has not been moved (see MigrateDataDir).
+func DataDirName() string {
+	if appData := os.Getenv("APPDATA"); appData != "" {
+		return filepath.Join(appData, "panforge")
+	}
+	dir := platformDir("XDG_CONFIG_HOME", ".config")
+	if legacy := legacyDataDir(); !exists(dir) && isDir(legacy) {
+		return legacy
+	}
+	return dir
+}
+
+// CacheDirName returns the directory of the build cache: $XDG_DATA_HOME/panforge/cache
+// (~/.local/share/panforge/cache) on Linux and other Unix systems, and the cache
+// directory inside DataDirName elsewhere or when APPDATA is set. The legacy
+// ~/.panforge/cache is used while it has not been moved.
+func CacheDirName() string {
+	if os.Getenv("APPDATA") != "" {
+		return filepath.Join(DataDirName(), "cache")
+	}
+	dir := cacheHome()
+	if legacy := filepath.Join(legacyDataDir(), "cache"); !exists(dir) && isDir(legacy) {
+		return legacy
+	}
+	return dir
+}
+
+// MigrateDataDir moves the legacy ~/.panforge to the platform's locations: its build
+// cache to CacheDirName and everything else to DataDirName. Nothing is moved when
+// APPDATA is set, when there is no legacy directory, or when the new data directory
+// already exists.
+//
+// Returns:
+//   - string: the legacy directory that was moved, or "" if nothing was moved
+//   - string: the data directory it was moved to
+//   - error: if a move failed; the legacy directory keeps being used
+func MigrateDataDir() (string, string, error) {
+	legacy := legacyDataDir()
+	if os.Getenv("APPDATA") != "" || !isDir(legacy) {
+		return "", "", nil
+	}
+	dataDir := platformDir("XDG_CONFIG_HOME", ".config")
+	if exists(dataDir) {
+		return "", "", nil
+	}
+	oldCache, cacheDir := filepath.Join(legacy, "cache"), cacheHome()
+	if isDir(oldCache) && cacheDir != filepath.Join(dataDir, "cache") && !exists(cacheDir) {
+		if err := move(oldCache, cacheDir); err != nil {
+			return "", "", err
+		}
+	}
+	if err := move(legacy, dataDir); err != nil {
+		return "", "", err
+	}
+	return legacy, dataDir, nil
+}
+
+// cacheHome returns the build cache under XDG_DATA_HOME.
+func cacheHome() string {
+	return filepath.Join(platformDir("XDG_DATA_HOME", filepath.Join(".local", "share")), "cache")
+}
+
+// move renames a directory, creating the parents of its new path.
+func move(from, to string) error {
+	if err := os.MkdirAll(filepath.Dir(to), 0750); err != nil {
+		return fmt.Errorf("failed to move %s: %w", from, err)
+	}
+	if err := os.Rename(from, to); err != nil {
+		return fmt.Errorf("failed to move %s to %s: %w", from, to, err)
+	}
+	return nil
+}
+
+// platformDir returns panforge's directory under an XDG base directory: the variable's
+// value when it is an absolute path, else ~/Library/Application Support on macOS and
+// ~/<fallback> elsewhere.
+//
+// Parameters:
+//   - `env`: the XDG variable, e.g. XDG_CONFIG_HOME
+//   - `fallback`: its default relative to the home directory, e.g. .config
+func platformDir(env, fallback string) string {
+	if base := os.Getenv(env); filepath.IsAbs(base) {
+		return filepath.Join(base, "panforge")
+	}
+	home, _ := os.UserHomeDir()
+	if runtime.GOOS == "darwin" {
+		return filepath.Join(home, "Library", "Application Support", "panforge")
+	}
+	if runtime.GOOS == "windows" {
+		if dir, err := os.UserConfigDir(); err == nil {
+			return filepath.Join(dir, "panforge")
+		}
+	}
+	return filepath.Join(home, fallback, "panforge")
+}
+
+// legacyDataDir returns ~/.panforge.
+func legacyDataDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, legacyDirName)
+}
+
+// exists reports whether a path exists.
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return !errors.Is(err, os.ErrNotExist)
+}
+
+// isDir reports whether a path is a directory.
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
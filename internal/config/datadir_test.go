@@ -0,0 +1,82 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestDataDirName(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("XDG defaults apply on Linux")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("APPDATA", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_DATA_HOME", "")
+
+	if got := DataDirName(); got != filepath.Join(home, ".config", "panforge") {
+		t.Errorf("expected ~/.config/panforge, got %s", got)
+	}
+	if got := CacheDirName(); got != filepath.Join(home, ".local", "share", "panforge", "cache") {
+		t.Errorf("expected ~/.local/share/panforge/cache, got %s", got)
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "cfg"))
+	t.Setenv("XDG_DATA_HOME", "relative") // ignored, as the spec requires
+	if got := DataDirName(); got != filepath.Join(home, "cfg", "panforge") {
+		t.Errorf("expected XDG_CONFIG_HOME to be used, got %s", got)
+	}
+	if got := CacheDirName(); got != filepath.Join(home, ".local", "share", "panforge", "cache") {
+		t.Errorf("expected a relative XDG_DATA_HOME to be ignored, got %s", got)
+	}
+
+	t.Setenv("APPDATA", filepath.Join(home, "appdata"))
+	if got := DataDirName(); got != filepath.Join(home, "appdata", "panforge") {
+		t.Errorf("expected APPDATA to win, got %s", got)
+	}
+	if got := CacheDirName(); got != filepath.Join(home, "appdata", "panforge", "cache") {
+		t.Errorf("expected the cache inside APPDATA, got %s", got)
+	}
+}
+
+func TestMigrateDataDir(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("XDG defaults apply on Linux")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("APPDATA", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_DATA_HOME", "")
+
+	legacy := filepath.Join(home, ".panforge")
+	_ = os.MkdirAll(filepath.Join(legacy, "cache", "records"), 0750)
+	_ = os.WriteFile(filepath.Join(legacy, "default.yaml"), []byte("toc: true\n"), 0600)
+
+	if got := DataDirName(); got != legacy {
+		t.Errorf("expected the legacy directory before migrating, got %s", got)
+	}
+	from, to, err := MigrateDataDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != legacy || to != filepath.Join(home, ".config", "panforge") {
+		t.Errorf("unexpected move %s -> %s", from, to)
+	}
+	if _, err := os.Stat(filepath.Join(to, "default.yaml")); err != nil {
+		t.Errorf("expected the configs in the new directory: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(CacheDirName(), "records")); err != nil {
+		t.Errorf("expected the cache in the data home: %v", err)
+	}
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Errorf("expected the legacy directory to be gone, got %v", err)
+	}
+
+	if from, _, err := MigrateDataDir(); err != nil || from != "" {
+		t.Errorf("expected nothing to move the second time, got %q %v", from, err)
+	}
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrKeyNotSet is returned by GetValue for a key the config does not set.
+var ErrKeyNotSet = errors.New("not set")
+
+// GetValue reads one setting of a config file as written, before `${VAR}` references and
+// includes are resolved.
+//
+// Parameters:
+//   - `path`: the config file
+//   - `key`: the setting, with dots between nested keys, e.g. `output.pdf.pdf-engine`
+//
+// Returns:
+//   - *yaml.Node: the value
+//   - error: ErrKeyNotSet if the key is not set, or an error if the file cannot be read
+func GetValue(path, key string) (*yaml.Node, error) {
+	//nolint:gosec // G304: Potential file inclusion via variable is intended behavior for CLI file arguments
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	node, err := configNode(path, data)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s in '%s': %w", formatName(path), path, err)
+	}
+	for _, part := range strings.Split(key, ".") {
+		if node == nil || node.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("%s: %w", key, ErrKeyNotSet)
+		}
+		node = mappingValue(node, part)
+	}
+	if node == nil {
+		return nil, fmt.Errorf("%s: %w", key, ErrKeyNotSet)
+	}
+	return node, nil
+}
+
+// SetValue sets one setting of a YAML config file, creating the file and any missing
+// maps on the way to the key. The value is read as YAML, so `true` and `2` keep their
+// types and `[html, pdf]` is a list. The rest of the file is kept, comments included,
+// though its indentation may change.
+//
+// Parameters:
+//   - `path`: the config file
+//   - `key`: the setting, with dots between nested keys, e.g. `output.pdf.pdf-engine`
+//   - `value`: the new value, as YAML
+//
+// Returns:
+//   - error: if the file is not YAML, cannot be parsed or written, or a key on the way is not a map
+func SetValue(path, key, value string) error {
+	if formatName(path) != "YAML" {
+		return fmt.Errorf("cannot edit %s: only YAML configs can be edited", path)
+	}
+	//nolint:gosec // G304: Potential file inclusion via variable is intended behavior for CLI file arguments
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("error parsing YAML in '%s': %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+
+	var newValue yaml.Node
+	if err := yaml.Unmarshal([]byte(value), &newValue); err != nil {
+		return fmt.Errorf("invalid value %q: %w", value, err)
+	}
+	leaf := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+	if len(newValue.Content) > 0 {
+		leaf = newValue.Content[0]
+	}
+
+	node := doc.Content[0]
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		if node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null" {
+			// `html:` with no options becomes a map
+			*node = yaml.Node{Kind: yaml.MappingNode}
+		}
+		if node.Kind != yaml.MappingNode {
+			return fmt.Errorf("cannot set %s: %s is not a map", key, strings.Join(parts[:i], "."))
+		}
+		next := mappingValue(node, part)
+		if i == len(parts)-1 {
+			if next != nil {
+				// Keep the comments around the old value
+				leaf.HeadComment, leaf.LineComment, leaf.FootComment = next.HeadComment, next.LineComment, next.FootComment
+				*next = *leaf
+			} else {
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: part}, leaf)
+			}
+			break
+		}
+		if next == nil {
+			next = &yaml.Node{Kind: yaml.MappingNode}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: part}, next)
+		}
+		node = next
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0600)
+}
+
+// mappingValue returns the value of a key in a mapping node, or nil if it has none.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			value := node.Content[i+1]
+			if value.Kind == yaml.AliasNode && value.Alias != nil {
+				return value.Alias
+			}
+			return value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "default.yaml")
+	_ = os.WriteFile(path, []byte("# Team defaults\ntitle: Draft # placeholder\noutput:\n  html:\n"), 0600)
+
+	for _, kv := range [][2]string{
+		{"title", "Report"},
+		{"output.html.toc", "true"},
+		{"output.pdf.pdf-engine", "xelatex"},
+		{"outputs", "[html, pdf]"},
+	} {
+		if err := SetValue(path, kv[0], kv[1]); err != nil {
+			t.Fatalf("set %s: %v", kv[0], err)
+		}
+	}
+	data, _ := os.ReadFile(path)
+	for _, want := range []string{"# Team defaults", "title: Report # placeholder", "    toc: true", "    pdf-engine: xelatex", "outputs: [html, pdf]"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %q in:\n%s", want, data)
+		}
+	}
+	_, cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Title != "Report" || cfg.OutputMap["html"].(map[string]interface{})["toc"] != true {
+		t.Errorf("unexpected config %#v", cfg)
+	}
+
+	if err := SetValue(path, "title.sub", "x"); err == nil {
+		t.Error("expected an error for a key below a string")
+	}
+	if err := SetValue(filepath.Join(t.TempDir(), "a.toml"), "toc", "true"); err == nil {
+		t.Error("expected an error for a TOML config")
+	}
+
+	created := filepath.Join(t.TempDir(), "new", "default.yaml")
+	if err := SetValue(created, "output.pdf.toc", "true"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(created); err != nil {
+		t.Errorf("expected the config to be created: %v", err)
+	}
+}
+
+func TestGetValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "default.toml")
+	_ = os.WriteFile(path, []byte("toc = true\n[output.pdf]\npdf-engine = \"xelatex\"\n"), 0600)
+
+	node, err := GetValue(path, "output.pdf.pdf-engine")
+	if err != nil || node.Value != "xelatex" {
+		t.Errorf("expected xelatex, got %v, %v", node, err)
+	}
+	if _, err := GetValue(path, "output.html.toc"); !errors.Is(err, ErrKeyNotSet) {
+		t.Errorf("expected ErrKeyNotSet, got %v", err)
+	}
+	if _, err := GetValue(path, "toc.depth"); !errors.Is(err, ErrKeyNotSet) {
+		t.Errorf("expected ErrKeyNotSet below a scalar, got %v", err)
+	}
+}
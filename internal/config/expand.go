@@ -0,0 +1,112 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// envRef matches `${VAR}` and `${VAR:-default}` references, and the `$${` escape.
+var envRef = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// expandsEnv reports whether the file at path is a config file, whose values have
+// `${VAR}` references expanded. Document headers are not expanded, so converting a
+// document from elsewhere cannot copy the environment into its output.
+func expandsEnv(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, configExt := range ConfigExts {
+		if ext == configExt {
+			return true
+		}
+	}
+	return false
+}
+
+// expandEnv replaces the environment variable references in the string values of a
+// config: `${VAR}` is the variable's value, `${VAR:-default}` falls back to default when
+// it is unset or empty, and `$${` stands for a literal `${`. Keys are left alone.
+//
+// Parameters:
+//   - `cfg`: the config, updated in place
+//   - `path`: the file it was read from, for errors
+//
+// Returns:
+//   - error: naming the setting and the variable, if a variable is not set
+func expandEnv(cfg *Config, path string) error {
+	var err error
+	expand := envExpander(path, &err)
+	cfg.Title = expand("title", cfg.Title)
+	cfg.Author = expand("author", cfg.Author)
+	cfg.FilenameTemplate = expand("filename-template", cfg.FilenameTemplate)
+	for i, v := range cfg.Outputs {
+		cfg.Outputs[i] = expandValue("outputs", v, expand)
+	}
+	for k, v := range cfg.OutputMap {
+		cfg.OutputMap[k] = expandValue("output."+k, v, expand)
+	}
+	for k, v := range cfg.Generic {
+		cfg.Generic[k] = expandValue(k, v, expand)
+	}
+	return err
+}
+
+// envExpander returns the function expandValue calls to expand one string read from
+// path. After the first variable that is not set, it reports the error through err and
+// leaves the remaining strings alone.
+func envExpander(path string, err *error) func(key, s string) string {
+	return func(key string, s string) string {
+		if *err != nil {
+			return s
+		}
+		out, expandErr := expandString(s)
+		if expandErr != nil {
+			*err = fmt.Errorf("%s: %s: %w", path, key, expandErr)
+		}
+		return out
+	}
+}
+
+// expandValue expands the strings in a decoded YAML value, recursing into lists and maps.
+func expandValue(key string, v interface{}, expand func(key, s string) string) interface{} {
+	switch v := v.(type) {
+	case string:
+		return expand(key, v)
+	case []interface{}:
+		for i, item := range v {
+			v[i] = expandValue(key, item, expand)
+		}
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = expandValue(key+"."+k, item, expand)
+		}
+	}
+	return v
+}
+
+// expandString expands the references in one value (see expandEnv).
+func expandString(s string) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+	var missing string
+	out := envRef.ReplaceAllStringFunc(s, func(ref string) string {
+		if ref == "$${" {
+			return "${"
+		}
+		m := envRef.FindStringSubmatch(ref)
+		value, ok := os.LookupEnv(m[1])
+		if value == "" && strings.Contains(ref, ":-") {
+			return m[2]
+		}
+		if !ok && missing == "" {
+			missing = m[1]
+		}
+		return value
+	})
+	if missing != "" {
+		return "", fmt.Errorf("environment variable %s is not set (use ${%s:-default} for a fallback, or $${ for a literal ${)", missing, missing)
+	}
+	return out, nil
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig_ExpandEnv(t *testing.T) {
+	t.Setenv("TEMPLATES_DIR", "/srv/templates")
+	t.Setenv("EMPTY", "")
+	dir := t.TempDir()
+	path := filepath.Join(dir, "default.yaml")
+	_ = os.WriteFile(path, []byte(`author: ${USER_NAME:-Anonymous}
+output:
+  pdf:
+    template: ${TEMPLATES_DIR}/eisvogel.latex
+    variables:
+      footer: "$${not-expanded} and ${EMPTY:-fallback}"
+css: ["${TEMPLATES_DIR}/a.css", "$$x$$"]
+`), 0600)
+	t.Setenv("USER_NAME", "")
+
+	_, cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdf := cfg.OutputMap["pdf"].(map[string]interface{})
+	if pdf["template"] != "/srv/templates/eisvogel.latex" {
+		t.Errorf("expected the variable expanded, got %v", pdf["template"])
+	}
+	if footer := pdf["variables"].(map[string]interface{})["footer"]; footer != "${not-expanded} and fallback" {
+		t.Errorf("expected the escape and the fallback, got %v", footer)
+	}
+	if cfg.Author != "Anonymous" {
+		t.Errorf("expected the fallback for an empty variable, got %q", cfg.Author)
+	}
+	if css := cfg.Generic["css"].([]interface{}); css[0] != "/srv/templates/a.css" || css[1] != "$$x$$" {
+		t.Errorf("expected list items expanded and other dollars kept, got %v", css)
+	}
+}
+
+func TestLoadConfig_ExpandEnvErrors(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "default.yaml")
+	_ = os.WriteFile(path, []byte("output:\n  pdf:\n    template: ${PANFORGE_TEST_UNSET}/x.latex\n"), 0600)
+	_, _, err := LoadConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "PANFORGE_TEST_UNSET") || !strings.Contains(err.Error(), "output.pdf.template") {
+		t.Errorf("expected an error naming the setting and the variable, got %v", err)
+	}
+
+	// Document headers are not expanded
+	doc := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(doc, []byte("---\ntitle: ${PANFORGE_TEST_UNSET}\n---\n"), 0600)
+	if _, cfg, err := LoadConfig(doc); err != nil || cfg.Title != "${PANFORGE_TEST_UNSET}" {
+		t.Errorf("expected the document header left alone, got %v, %v", cfg, err)
+	}
+}
@@ -0,0 +1,218 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// extendsKey is the key a config or target uses to build on another one.
+const extendsKey = "extends"
+
+// resolveExtends deep-merges the configs a config names under `extends` beneath it, and
+// removes the key. A name without a path separator or dot is a config in the data
+// directory, like --config; a path is relative to the extending file. Unlike `include`,
+// maps are merged key by key at every depth, so a profile can override one variable of
+// a target and keep the others. Later bases override earlier ones.
+//
+// Parameters:
+//   - `cfg`: the config, updated in place
+//   - `path`: the absolute path of the file cfg was read from
+//   - `chain`: the files being extended, outermost first, to detect cycles
+//
+// Returns:
+//   - error: if a base is missing, invalid, or part of a cycle
+func resolveExtends(cfg *Config, path string, chain []string) error {
+	raw, ok := cfg.Generic[extendsKey]
+	if !ok {
+		return nil
+	}
+	delete(cfg.Generic, extendsKey)
+	names, err := includeFiles(raw)
+	if err != nil {
+		return fmt.Errorf("%s: %s", path, strings.Replace(err.Error(), includeKey, extendsKey, 1))
+	}
+	chain = append(chain, path)
+	for i := len(names) - 1; i >= 0; i-- {
+		file, err := extendsFile(names[i], path)
+		if err != nil {
+			return err
+		}
+		for _, seen := range chain {
+			if seen == file {
+				return fmt.Errorf("extends cycle: %s -> %s", strings.Join(chain, " -> "), file)
+			}
+		}
+		//nolint:gosec // G304: reading the base config is intended
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("%s: extends: %w", path, err)
+		}
+		base, err := parseConfigFile(file, data)
+		if err != nil {
+			return fmt.Errorf("error parsing %s in '%s': %w", formatName(file), file, err)
+		}
+		if expandsEnv(file) {
+			if err := expandEnv(base, file); err != nil {
+				return err
+			}
+		}
+		if err := resolveIncludes(base, file, nil); err != nil {
+			return err
+		}
+		if err := resolveExtends(base, file, chain); err != nil {
+			return err
+		}
+		deepFill(cfg, base)
+	}
+	return nil
+}
+
+// extendsFile finds the file an `extends` entry names.
+func extendsFile(name, from string) (string, error) {
+	if strings.ContainsAny(name, "./\\") {
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(filepath.Dir(from), name)
+		}
+		return name, nil
+	}
+	if path := FindDefaultConfig(name); path != "" {
+		return path, nil
+	}
+	return "", fmt.Errorf("%s: extends: config %s not found in %s", from, name, DataDirName())
+}
+
+// deepFill sets the settings cfg does not set from base, merging targets and maps key by
+// key at every depth.
+func deepFill(cfg, base *Config) {
+	cfg.Generic = deepMerge(cfg.Generic, base.Generic)
+	cfg.OutputMap = deepMerge(cfg.OutputMap, base.OutputMap)
+	fill(cfg, base)
+}
+
+// deepMerge fills dst with the keys of base it does not set, merging nested maps.
+// Values taken from base are copied, so later changes to dst do not reach base.
+func deepMerge(dst, base map[string]interface{}) map[string]interface{} {
+	if len(base) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]interface{}, len(base))
+	}
+	for k, v := range base {
+		existing, ok := dst[k]
+		if !ok {
+			dst[k] = copyValue(v)
+			continue
+		}
+		if em, ok := existing.(map[string]interface{}); ok {
+			if bm, ok := v.(map[string]interface{}); ok {
+				dst[k] = deepMerge(em, bm)
+			}
+		} else if existing == nil {
+			// `pdf:` with no options of its own takes all of the base's
+			if _, ok := v.(map[string]interface{}); ok {
+				dst[k] = copyValue(v)
+			}
+		}
+	}
+	return dst
+}
+
+// copyValue deep-copies the maps and lists of a config value.
+func copyValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			out[k] = copyValue(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = copyValue(item)
+		}
+		return out
+	}
+	return v
+}
+
+// ResolveTargetExtends lets a target of the output map build on another one with
+// `extends: TARGET`, e.g. a `draft` target that is `pdf` with a watermark. The base's
+// options are deep-merged beneath the target's own. A target that does not set `to`
+// gets the format of its base, so `draft` still converts to PDF. Call it on the fully
+// merged config, so a document's target can extend one from the default config.
+//
+// Parameters:
+//   - `cfg`: the merged configuration, updated in place
+//
+// Returns:
+//   - error: if a target extends one that does not exist, or targets extend each other in a cycle
+func ResolveTargetExtends(cfg *Config) error {
+	extends := false
+	for _, v := range cfg.OutputMap {
+		if target, ok := v.(map[string]interface{}); ok && target[extendsKey] != nil {
+			extends = true
+		}
+	}
+	if !extends {
+		return nil
+	}
+	// The targets may be shared with the configs cfg was merged from
+	cfg.OutputMap = copyValue(cfg.OutputMap).(map[string]interface{})
+	done := make(map[string]bool)
+	var resolve func(name string, chain []string) error
+	resolve = func(name string, chain []string) error {
+		if done[name] {
+			return nil
+		}
+		target, _ := cfg.OutputMap[name].(map[string]interface{})
+		baseName, ok := target[extendsKey].(string)
+		if !ok {
+			if _, set := target[extendsKey]; set {
+				return fmt.Errorf("output.%s: extends: expected a target name, got %v", name, target[extendsKey])
+			}
+			done[name] = true
+			return nil
+		}
+		for _, seen := range chain {
+			if seen == baseName {
+				return fmt.Errorf("output.%s: extends cycle: %s -> %s", name, strings.Join(chain, " -> "), baseName)
+			}
+		}
+		if _, exists := cfg.OutputMap[baseName]; !exists {
+			return fmt.Errorf("output.%s: extends unknown target %s", name, baseName)
+		}
+		if err := resolve(baseName, append(chain, baseName)); err != nil {
+			return err
+		}
+		delete(target, extendsKey)
+		base, _ := cfg.OutputMap[baseName].(map[string]interface{})
+		if _, ok := target["to"]; !ok {
+			if _, ok := base["to"]; !ok {
+				target["to"] = formatOf(baseName)
+			}
+		}
+		deepMerge(target, base)
+		done[name] = true
+		return nil
+	}
+	for name := range cfg.OutputMap {
+		if err := resolve(name, []string{name}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatOf returns the pandoc format a target name stands for, without extensions, as
+// pandoc.NormalizeFormat does.
+func formatOf(target string) string {
+	parts := strings.FieldsFunc(target, func(r rune) bool { return r == '+' || r == '-' })
+	if len(parts) == 0 {
+		return target
+	}
+	return parts[0]
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig_Extends(t *testing.T) {
+	t.Setenv("APPDATA", t.TempDir())
+	_ = os.MkdirAll(DataDirName(), 0750)
+	_ = os.WriteFile(filepath.Join(DataDirName(), "house.yaml"), []byte("author: House\noutput:\n  pdf:\n    pdf-engine: xelatex\n    variables:\n      fontsize: 11pt\n      geometry: margin=2cm\n"), 0600)
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "client.yaml"), []byte("extends: house\nlang: de\noutput:\n  pdf:\n    variables:\n      fontsize: 12pt\n"), 0600)
+	path := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(path, []byte("---\nextends: ./client.yaml\noutput:\n  pdf:\n    toc: true\n---\n"), 0600)
+
+	_, cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"toc":        true,
+		"pdf-engine": "xelatex",
+		"variables":  map[string]interface{}{"fontsize": "12pt", "geometry": "margin=2cm"},
+	}
+	if !reflect.DeepEqual(cfg.OutputMap["pdf"], want) {
+		t.Errorf("expected deep-merged pdf options, got %#v", cfg.OutputMap["pdf"])
+	}
+	if cfg.Author != "House" || cfg.Generic["lang"] != "de" || cfg.Generic["extends"] != nil {
+		t.Errorf("unexpected config %#v", cfg)
+	}
+
+	_ = os.WriteFile(filepath.Join(DataDirName(), "house.yaml"), []byte("extends: ./client.yaml\n"), 0600)
+	_ = os.WriteFile(filepath.Join(DataDirName(), "client.yaml"), []byte("extends: house\n"), 0600)
+	if _, _, err := LoadConfig(filepath.Join(dir, "client.yaml")); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("expected a cycle error, got %v", err)
+	}
+	_ = os.WriteFile(path, []byte("---\nextends: missing\n---\n"), 0600)
+	if _, _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "config missing not found") {
+		t.Errorf("expected a missing config error, got %v", err)
+	}
+}
+
+func TestResolveTargetExtends(t *testing.T) {
+	shared := map[string]interface{}{"pdf-engine": "xelatex", "variables": map[string]interface{}{"fontsize": "11pt"}}
+	cfg := &Config{OutputMap: map[string]interface{}{
+		"pdf":   shared,
+		"draft": map[string]interface{}{"extends": "pdf", "variables": map[string]interface{}{"watermark": "DRAFT"}},
+		"proof": map[string]interface{}{"extends": "draft", "to": "latex"},
+	}}
+	if err := ResolveTargetExtends(cfg); err != nil {
+		t.Fatal(err)
+	}
+	draft := map[string]interface{}{
+		"to":         "pdf",
+		"pdf-engine": "xelatex",
+		"variables":  map[string]interface{}{"fontsize": "11pt", "watermark": "DRAFT"},
+	}
+	if !reflect.DeepEqual(cfg.OutputMap["draft"], draft) {
+		t.Errorf("unexpected draft target %#v", cfg.OutputMap["draft"])
+	}
+	if proof := cfg.OutputMap["proof"].(map[string]interface{}); proof["to"] != "latex" || proof["pdf-engine"] != "xelatex" {
+		t.Errorf("unexpected proof target %#v", proof)
+	}
+	if len(shared["variables"].(map[string]interface{})) != 1 {
+		t.Error("the base target was modified")
+	}
+
+	for _, outputs := range []map[string]interface{}{
+		{"a": map[string]interface{}{"extends": "b"}, "b": map[string]interface{}{"extends": "a"}},
+		{"a": map[string]interface{}{"extends": "nope"}},
+	} {
+		if err := ResolveTargetExtends(&Config{OutputMap: outputs}); err == nil {
+			t.Errorf("expected an error for %v", outputs)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigExts are the extensions of config files, in the order they are looked for when
+// a directory may hold several, e.g. .panforge.yaml before .panforge.toml.
+var ConfigExts = []string{".yaml", ".yml", ".toml", ".json"}
+
+// parseConfigFile parses a config in the format its extension names: TOML for .toml,
+// JSON for .json, and YAML (or a Markdown document with a YAML header) otherwise.
+//
+// Parameters:
+//   - `path`: the file the config was read from
+//   - `data`: its content
+//
+// Returns:
+//   - *Config: the parsed configuration
+//   - error: if the config is invalid
+func parseConfigFile(path string, data []byte) (*Config, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		return ParseTOML(data)
+	case ".json":
+		return ParseJSON(data)
+	}
+	return ParseConfig(data)
+}
+
+// formatName names the format of a config file in errors.
+func formatName(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		return "TOML"
+	case ".json":
+		return "JSON"
+	}
+	return "YAML"
+}
+
+// ParseTOML parses a TOML configuration. It takes the same keys as YAML, with tables for
+// maps, e.g. `[output.html]` for the html target.
+//
+// Parameters:
+//   - `data`: the TOML text
+//
+// Returns:
+//   - *Config: the parsed configuration
+//   - error: if the TOML is invalid
+func ParseTOML(data []byte) (*Config, error) {
+	var m map[string]interface{}
+	if err := toml.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return fromMap(m)
+}
+
+// ParseJSON parses a JSON configuration, an object with the same keys as YAML.
+//
+// Parameters:
+//   - `data`: the JSON text
+//
+// Returns:
+//   - *Config: the parsed configuration
+//   - error: if the JSON is invalid
+func ParseJSON(data []byte) (*Config, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var m map[string]interface{}
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	return fromMap(m)
+}
+
+// fromMap builds a config from a decoded TOML or JSON document. Its values are first
+// converted to the types YAML decodes to (int for whole numbers, strings for dates), so
+// settings read the same whatever the format.
+func fromMap(m map[string]interface{}) (*Config, error) {
+	data, err := yaml.Marshal(normalizeValue(m))
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// normalizeValue converts a decoded TOML or JSON value to YAML's types.
+func normalizeValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = normalizeValue(item)
+		}
+		return v
+	case []map[string]interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = normalizeValue(item)
+		}
+		return out
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeValue(item)
+		}
+		return v
+	case int64:
+		return int(v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return int(i)
+		}
+		f, _ := v.Float64()
+		return f
+	case time.Time:
+		// TOML's local dates and times carry these zones, so they keep their own form
+		switch v.Location().String() {
+		case "date-local":
+			return v.Format("2006-01-02")
+		case "time-local":
+			return v.Format("15:04:05")
+		case "datetime-local":
+			return v.Format("2006-01-02T15:04:05")
+		}
+		return v.Format(time.RFC3339)
+	}
+	return v
+}
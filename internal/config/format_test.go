@@ -0,0 +1,50 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadConfig_Formats(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.yaml": "title: Doc\ntoc-depth: 2\ndate: \"2024-05-01\"\noutputs: [html]\noutput:\n  pdf:\n    pdf-engine: xelatex\n    variables:\n      fontsize: 11pt\n",
+		"a.toml": "title = \"Doc\"\ntoc-depth = 2\ndate = 2024-05-01\noutputs = [\"html\"]\n\n[output.pdf]\npdf-engine = \"xelatex\"\n\n[output.pdf.variables]\nfontsize = \"11pt\"\n",
+		"a.json": `{"title": "Doc", "toc-depth": 2, "date": "2024-05-01", "outputs": ["html"], "output": {"pdf": {"pdf-engine": "xelatex", "variables": {"fontsize": "11pt"}}}}`,
+	}
+	var configs []*Config
+	for _, name := range []string{"a.yaml", "a.toml", "a.json"} {
+		path := filepath.Join(dir, name)
+		_ = os.WriteFile(path, []byte(files[name]), 0600)
+		_, cfg, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		configs = append(configs, cfg)
+	}
+	for i, name := range []string{"a.toml", "a.json"} {
+		if !reflect.DeepEqual(configs[0], configs[i+1]) {
+			t.Errorf("%s: expected the same config as YAML\n got %#v\nwant %#v", name, configs[i+1], configs[0])
+		}
+	}
+
+	bad := filepath.Join(dir, "bad.toml")
+	_ = os.WriteFile(bad, []byte("title = \n"), 0600)
+	if _, _, err := LoadConfig(bad); err == nil {
+		t.Error("expected an error for invalid TOML")
+	}
+}
+
+func TestFindProjectConfig_Formats(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, ".panforge.toml"), []byte("toc = true\n"), 0600)
+	if got := FindProjectConfig(dir); got != filepath.Join(dir, ".panforge.toml") {
+		t.Errorf("expected the TOML project config, got %q", got)
+	}
+	_ = os.WriteFile(filepath.Join(dir, ProjectFileName), []byte("toc: true\n"), 0600)
+	if got := FindProjectConfig(dir); got != filepath.Join(dir, ProjectFileName) {
+		t.Errorf("expected YAML to win, got %q", got)
+	}
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// FormatDefaultsDir is the directory of the data directory that holds the options for
+// every target of a format, e.g. formats/pdf.yaml.
+const FormatDefaultsDir = "formats"
+
+// FindFormatDefaults returns the options file for a pandoc format in the data
+// directory: formats/FORMAT.yaml (or .yml, .toml, .json), or "" if there is none.
+//
+// Parameters:
+//   - `format`: the pandoc format, without extensions
+func FindFormatDefaults(format string) string {
+	if format == "" || strings.ContainsAny(format, "./\\") {
+		return ""
+	}
+	for _, ext := range ConfigExts {
+		path := filepath.Join(DataDirName(), FormatDefaultsDir, format+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// LoadFormatDefaults reads an options file of the formats directory: a map of target
+// options, as under `output.TARGET`, with `${VAR}` references expanded.
+//
+// Parameters:
+//   - `path`: the file
+//
+// Returns:
+//   - map[string]interface{}: the options
+//   - error: if the file cannot be read or parsed, or a variable is not set
+func LoadFormatDefaults(path string) (map[string]interface{}, error) {
+	//nolint:gosec // G304: the file is in the user's data directory
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var options map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		err = toml.Unmarshal(data, &options)
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(&options)
+	default:
+		err = yaml.Unmarshal(data, &options)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s in '%s': %w", formatName(path), path, err)
+	}
+	options, _ = normalizeValue(options).(map[string]interface{})
+
+	expand := envExpander(path, &err)
+	for k, v := range options {
+		options[k] = expandValue(k, v, expand)
+	}
+	return options, err
+}
+
+// FormatDefaults returns the options the user set for every target of a format, from
+// the formats directory, or nil if there are none.
+//
+// Parameters:
+//   - `format`: the pandoc format, with or without extensions (`html+smart`)
+//
+// Returns:
+//   - map[string]interface{}: the options
+//   - error: if the options file is invalid
+func FormatDefaults(format string) (map[string]interface{}, error) {
+	path := FindFormatDefaults(formatOf(format))
+	if path == "" {
+		return nil, nil
+	}
+	return LoadFormatDefaults(path)
+}
+
+// MergeOptions deep-merges defaults beneath a target's options: the target's own
+// options win key by key at every depth. The result is a copy, so the config the
+// options came from is left alone.
+//
+// Parameters:
+//   - `options`: the target's options
+//   - `defaults`: the options to fill in
+//
+// Returns:
+//   - map[string]interface{}: the merged options
+func MergeOptions(options, defaults map[string]interface{}) map[string]interface{} {
+	merged, _ := copyValue(options).(map[string]interface{})
+	return deepMerge(merged, defaults)
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFormatDefaults(t *testing.T) {
+	t.Setenv("APPDATA", t.TempDir())
+	t.Setenv("PDF_FONT", "Libertinus Serif")
+	formats := filepath.Join(DataDirName(), FormatDefaultsDir)
+	_ = os.MkdirAll(formats, 0750)
+	_ = os.WriteFile(filepath.Join(formats, "pdf.yaml"), []byte("pdf-engine: lualatex\nvariables:\n  mainfont: ${PDF_FONT}\n  geometry: margin=2cm\n"), 0600)
+	_ = os.WriteFile(filepath.Join(formats, "html.toml"), []byte("toc = true\n"), 0600)
+
+	defaults, err := FormatDefaults("pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := map[string]interface{}{"variables": map[string]interface{}{"geometry": "margin=3cm"}}
+	want := map[string]interface{}{
+		"pdf-engine": "lualatex",
+		"variables":  map[string]interface{}{"mainfont": "Libertinus Serif", "geometry": "margin=3cm"},
+	}
+	if got := MergeOptions(target, defaults); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the pdf defaults beneath the target's options, got %#v", got)
+	}
+	if len(target["variables"].(map[string]interface{})) != 1 {
+		t.Errorf("the target's options must not change, got %#v", target)
+	}
+
+	if html, err := FormatDefaults("html+smart"); err != nil || !reflect.DeepEqual(html, map[string]interface{}{"toc": true}) {
+		t.Errorf("expected the html defaults for html+smart, got %#v, %v", html, err)
+	}
+	if docx, err := FormatDefaults("docx"); docx != nil || err != nil {
+		t.Errorf("expected no defaults for docx, got %#v, %v", docx, err)
+	}
+
+	_ = os.WriteFile(filepath.Join(formats, "pdf.yaml"), []byte("variables:\n  mainfont: ${NO_SUCH_FONT}\n"), 0600)
+	if _, err := FormatDefaults("pdf"); err == nil || !strings.Contains(err.Error(), "NO_SUCH_FONT") {
+		t.Errorf("expected an error naming the unset variable, got %v", err)
+	}
+}
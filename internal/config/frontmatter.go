@@ -0,0 +1,98 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"gopkg.in/yaml.v3"
+)
+
+// tomlDelimiter opens and closes TOML front matter, as static-site generators such as
+// Hugo write it.
+const tomlDelimiter = "+++"
+
+// splitForeignFrontmatter separates TOML (`+++` ... `+++`) or JSON (a leading `{...}`
+// object) front matter from a document.
+//
+// Parameters:
+//   - `data`: the document
+//
+// Returns:
+//   - string: "toml", "json", or "" if the document has neither
+//   - []byte: the front matter, without TOML's delimiters
+//   - []byte: the rest of the document
+func splitForeignFrontmatter(data []byte) (string, []byte, []byte) {
+	if header, body, ok := splitTOMLFrontmatter(data); ok {
+		return "toml", header, body
+	}
+	if bytes.HasPrefix(data, []byte("{")) {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		var obj map[string]interface{}
+		// A document that merely starts with a brace, such as an include directive, is not JSON
+		if err := dec.Decode(&obj); err == nil {
+			end := dec.InputOffset()
+			return "json", data[:end], bytes.TrimLeft(data[end:], " \t\r\n")
+		}
+	}
+	return "", nil, data
+}
+
+// splitTOMLFrontmatter separates `+++`-delimited front matter from a document.
+func splitTOMLFrontmatter(data []byte) ([]byte, []byte, bool) {
+	first, rest, ok := bytes.Cut(data, []byte("\n"))
+	if !ok || string(bytes.TrimRight(first, "\r")) != tomlDelimiter {
+		return nil, nil, false
+	}
+	offset := 0
+	for offset < len(rest) {
+		line, _, _ := bytes.Cut(rest[offset:], []byte("\n"))
+		next := offset + len(line) + 1
+		if string(bytes.TrimRight(line, "\r")) == tomlDelimiter {
+			if next > len(rest) {
+				next = len(rest)
+			}
+			return rest[:offset], rest[next:], true
+		}
+		offset = next
+	}
+	return nil, nil, false
+}
+
+// parseForeignFrontmatter parses TOML or JSON front matter.
+func parseForeignFrontmatter(format string, header []byte) (*Config, error) {
+	if format == "toml" {
+		return ParseTOML(header)
+	}
+	return ParseJSON(header)
+}
+
+// YAMLFrontmatter rewrites TOML or JSON front matter as a YAML header, which pandoc
+// reads as the document's metadata.
+//
+// Parameters:
+//   - `data`: the document
+//
+// Returns:
+//   - []byte: the document with a YAML header, or data if it has no TOML or JSON front matter
+//   - bool: whether the front matter was rewritten
+//   - error: if the front matter is invalid
+func YAMLFrontmatter(data []byte) ([]byte, bool, error) {
+	format, header, body := splitForeignFrontmatter(data)
+	if format == "" {
+		return data, false, nil
+	}
+	cfg, err := parseForeignFrontmatter(format, header)
+	if err != nil {
+		return nil, false, err
+	}
+	yamlHeader, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, false, err
+	}
+	var b bytes.Buffer
+	b.WriteString("---\n")
+	b.Write(yamlHeader)
+	b.WriteString("---\n")
+	b.Write(body)
+	return b.Bytes(), true, nil
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseConfig_Frontmatter(t *testing.T) {
+	docs := map[string]string{
+		"toml": "+++\ntitle = \"Post\"\noutputs = [\"html\"]\n[output.html]\ntoc = true\n+++\n# Body\n",
+		"json": "{\n  \"title\": \"Post\",\n  \"outputs\": [\"html\"],\n  \"output\": {\"html\": {\"toc\": true}}\n}\n\n# Body\n",
+	}
+	for name, doc := range docs {
+		cfg, err := ParseConfig([]byte(doc))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		html, _ := cfg.OutputMap["html"].(map[string]interface{})
+		if cfg.Title != "Post" || len(cfg.Outputs) != 1 || html["toc"] != true {
+			t.Errorf("%s: unexpected config %+v", name, cfg)
+		}
+
+		converted, ok, err := YAMLFrontmatter([]byte(doc))
+		if err != nil || !ok {
+			t.Fatalf("%s: expected the front matter rewritten, got %v %v", name, ok, err)
+		}
+		if !strings.HasPrefix(string(converted), "---\ntitle: Post\n") || !strings.HasSuffix(string(converted), "---\n# Body\n") {
+			t.Errorf("%s: unexpected document:\n%s", name, converted)
+		}
+	}
+
+	// YAML headers and documents that merely start with a brace are left alone
+	for _, doc := range []string{"---\ntitle: Post\n---\n# Body\n", "{{include: intro.md}}\n", "+++ not front matter\n"} {
+		if _, ok, err := YAMLFrontmatter([]byte(doc)); ok || err != nil {
+			t.Errorf("expected %q left alone, got %v %v", doc, ok, err)
+		}
+	}
+
+	if _, _, err := YAMLFrontmatter([]byte("+++\ntitle = \n+++\n")); err == nil {
+		t.Error("expected an error for invalid TOML front matter")
+	}
+}
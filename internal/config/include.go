@@ -0,0 +1,194 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// includeKey is the config key that pulls in other config files.
+const includeKey = "include"
+
+// resolveIncludes merges the files a config lists under `include` into it, and removes
+// the key. A path is relative to the including file. Included files are applied in
+// order, each one overriding the ones before it, and the including file's own settings
+// override them all. Included files may include others; cycles are reported as errors.
+//
+// Parameters:
+//   - `cfg`: the config, updated in place
+//   - `path`: the absolute path of the file cfg was read from
+//   - `chain`: the files being included, outermost first, to detect cycles
+//
+// Returns:
+//   - error: if an included file is missing, invalid, or part of a cycle
+func resolveIncludes(cfg *Config, path string, chain []string) error {
+	raw, ok := cfg.Generic[includeKey]
+	if !ok {
+		return nil
+	}
+	delete(cfg.Generic, includeKey)
+	files, err := includeFiles(raw)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	chain = append(chain, path)
+	// Applied last to first, since the first setting found wins
+	for i := len(files) - 1; i >= 0; i-- {
+		file := files[i]
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(path), file)
+		}
+		for _, seen := range chain {
+			if seen == file {
+				return fmt.Errorf("include cycle: %s -> %s", strings.Join(chain, " -> "), file)
+			}
+		}
+		//nolint:gosec // G304: reading the included config is intended
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("%s: include: %w", path, err)
+		}
+		included, err := parseConfigFile(file, data)
+		if err != nil {
+			return fmt.Errorf("error parsing %s in '%s': %w", formatName(file), file, err)
+		}
+		if expandsEnv(file) {
+			if err := expandEnv(included, file); err != nil {
+				return err
+			}
+		}
+		if err := resolveIncludes(included, file, chain); err != nil {
+			return err
+		}
+		if err := resolveExtends(included, file, nil); err != nil {
+			return err
+		}
+		fill(cfg, included)
+	}
+	return nil
+}
+
+// IncludedFiles lists the files a config includes or extends, directly or through other
+// included files, in the order they are applied: each one overrides those before it,
+// and the config itself overrides them all.
+//
+// Parameters:
+//   - `path`: the config file or document
+//
+// Returns:
+//   - []string: the absolute paths of the included and extended files
+//   - error: if a file is missing, invalid, or part of a cycle
+func IncludedFiles(path string) ([]string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		absPath = path
+	}
+	return includedFiles(absPath, nil)
+}
+
+// includedFiles lists the files path includes or extends, with chain as in
+// resolveIncludes. Bases come first, since included files override them.
+func includedFiles(path string, chain []string) ([]string, error) {
+	//nolint:gosec // G304: reading the included config is intended
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := parseConfigFile(path, data)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s in '%s': %w", formatName(path), path, err)
+	}
+	if expandsEnv(path) {
+		if err := expandEnv(cfg, path); err != nil {
+			return nil, err
+		}
+	}
+	chain = append(chain, path)
+	var out []string
+	for _, key := range []string{extendsKey, includeKey} {
+		raw, ok := cfg.Generic[key]
+		if !ok {
+			continue
+		}
+		files, err := includeFiles(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, file := range files {
+			if key == extendsKey {
+				if file, err = extendsFile(file, path); err != nil {
+					return nil, err
+				}
+			} else if !filepath.IsAbs(file) {
+				file = filepath.Join(filepath.Dir(path), file)
+			}
+			for _, seen := range chain {
+				if seen == file {
+					return nil, fmt.Errorf("%s cycle: %s -> %s", key, strings.Join(chain, " -> "), file)
+				}
+			}
+			nested, err := includedFiles(file, chain)
+			if err != nil {
+				return nil, err
+			}
+			out = append(append(out, nested...), file)
+		}
+	}
+	return out, nil
+}
+
+// includeFiles reads the value of `include`: one path or a list of paths.
+func includeFiles(raw interface{}) ([]string, error) {
+	switch v := raw.(type) {
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		files := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok || s == "" {
+				return nil, fmt.Errorf("include: expected file paths, got %v", item)
+			}
+			files = append(files, s)
+		}
+		return files, nil
+	}
+	return nil, fmt.Errorf("include: expected a file path or a list of them, got %v", raw)
+}
+
+// fill sets the settings cfg does not set from base. Output entries and top-level keys
+// are filled one by one.
+func fill(cfg, base *Config) {
+	if cfg.Title == "" {
+		cfg.Title = base.Title
+	}
+	if cfg.Author == "" {
+		cfg.Author = base.Author
+	}
+	if cfg.Outputs == nil {
+		cfg.Outputs = base.Outputs
+	}
+	if cfg.FilenameTemplate == "" {
+		cfg.FilenameTemplate = base.FilenameTemplate
+	}
+	if cfg.SlugifyFilename == nil {
+		cfg.SlugifyFilename = base.SlugifyFilename
+	}
+	for k, v := range base.OutputMap {
+		if cfg.OutputMap == nil {
+			cfg.OutputMap = make(map[string]interface{})
+		}
+		if _, exists := cfg.OutputMap[k]; !exists {
+			cfg.OutputMap[k] = v
+		}
+	}
+	for k, v := range base.Generic {
+		if cfg.Generic == nil {
+			cfg.Generic = make(map[string]interface{})
+		}
+		if _, exists := cfg.Generic[k]; !exists {
+			cfg.Generic[k] = v
+		}
+	}
+}
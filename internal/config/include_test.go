@@ -0,0 +1,77 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig_Include(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.MkdirAll(filepath.Join(dir, "shared"), 0750)
+	_ = os.WriteFile(filepath.Join(dir, "shared", "base.yaml"), []byte("author: Base\ntoc: true\nlang: en\noutput:\n  html:\n    css: base.css\n  pdf:\n    pdf-engine: xelatex\n"), 0600)
+	_ = os.WriteFile(filepath.Join(dir, "shared", "client.yaml"), []byte("include: base.yaml\nlang: de\noutput:\n  html:\n    css: client.css\n"), 0600)
+	path := filepath.Join(dir, "default.yaml")
+	_ = os.WriteFile(path, []byte("include:\n  - shared/base.yaml\n  - shared/client.yaml\ntoc: false\n"), 0600)
+
+	_, cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Generic["toc"] != false {
+		t.Errorf("expected the including file to win, got toc=%v", cfg.Generic["toc"])
+	}
+	if cfg.Generic["lang"] != "de" || cfg.Author != "Base" {
+		t.Errorf("expected later includes to win over earlier ones, got lang=%v author=%q", cfg.Generic["lang"], cfg.Author)
+	}
+	if html, _ := cfg.OutputMap["html"].(map[string]interface{}); html["css"] != "client.css" || cfg.OutputMap["pdf"] == nil {
+		t.Errorf("expected output entries merged by name, got %v", cfg.OutputMap)
+	}
+	if _, ok := cfg.Generic["include"]; ok {
+		t.Error("expected the include key to be removed")
+	}
+}
+
+func TestLoadConfig_IncludeErrors(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.yaml"), filepath.Join(dir, "b.yaml")
+	_ = os.WriteFile(a, []byte("include: b.yaml\n"), 0600)
+	_ = os.WriteFile(b, []byte("include: a.yaml\n"), 0600)
+	if _, _, err := LoadConfig(a); err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("expected a cycle error, got %v", err)
+	}
+
+	_ = os.WriteFile(a, []byte("include: missing.yaml\n"), 0600)
+	if _, _, err := LoadConfig(a); err == nil {
+		t.Error("expected an error for a missing include")
+	}
+
+	_ = os.WriteFile(a, []byte("include: {file: b.yaml}\n"), 0600)
+	if _, _, err := LoadConfig(a); err == nil {
+		t.Error("expected an error for an invalid include")
+	}
+}
+
+func TestIncludedFiles(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "base.yaml"), []byte("toc: true\n"), 0600)
+	_ = os.WriteFile(filepath.Join(dir, "client.yaml"), []byte("include: base.yaml\n"), 0600)
+	_ = os.WriteFile(filepath.Join(dir, "extra.yaml"), []byte("lang: de\n"), 0600)
+	doc := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(doc, []byte("---\ninclude: [client.yaml, extra.yaml]\n---\n"), 0600)
+
+	got, err := IncludedFiles(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "base.yaml"), filepath.Join(dir, "client.yaml"), filepath.Join(dir, "extra.yaml")}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	_ = os.WriteFile(filepath.Join(dir, "base.yaml"), []byte("include: client.yaml\n"), 0600)
+	if _, err := IncludedFiles(doc); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("expected a cycle error, got %v", err)
+	}
+}
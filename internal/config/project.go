@@ -0,0 +1,60 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// ProjectFileName is the name of the project config that `panforge init` writes.
+const ProjectFileName = ".panforge.yaml"
+
+// projectFileStem names project configs in every format: .panforge.yaml, .panforge.toml,
+// and so on.
+const projectFileStem = ".panforge"
+
+// FindProjectConfig searches dir and its parents for the nearest project config, like
+// git searches for its repository. A directory with several uses the first of the
+// ConfigExts, so .panforge.yaml wins over .panforge.toml and .panforge.json.
+//
+// Parameters:
+//   - `dir`: the directory to start from, usually the document's
+//
+// Returns:
+//   - string: the path of the nearest project config, or "" if there is none
+func FindProjectConfig(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		for _, ext := range ConfigExts {
+			candidate := filepath.Join(dir, projectFileStem+ext)
+			if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+				return candidate
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// LoadProjectConfig loads the project config that applies to a document: the nearest
+// .panforge.yaml (or .toml or .json) in the document's directory or above it.
+//
+// Parameters:
+//   - `inputFile`: the document
+//
+// Returns:
+//   - string: the path of the project config, or "" if there is none
+//   - *Config: its settings, or nil if there is none
+//   - error: if it cannot be read or parsed
+func LoadProjectConfig(inputFile string) (string, *Config, error) {
+	path := FindProjectConfig(filepath.Dir(inputFile))
+	if path == "" {
+		return "", nil, nil
+	}
+	return LoadConfig(path)
+}
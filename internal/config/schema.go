@@ -0,0 +1,331 @@
+package config
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// schemaJSON is the JSON Schema of panforge configs, for editors and `panforge validate`.
+//
+//go:embed panforge.schema.json
+var schemaJSON []byte
+
+// Schema returns the JSON Schema describing configs: the keys panforge reads, at the top
+// level and per target in the output map. Other keys are allowed, as metadata or pandoc
+// options.
+//
+// Returns:
+//   - []byte: the schema document
+func Schema() []byte {
+	return schemaJSON
+}
+
+// SchemaError is a value that does not match the schema.
+type SchemaError struct {
+	// File the value was read from.
+	File string
+	// Line and Column of the value, 0 if unknown (TOML).
+	Line, Column int
+	// Path of the key, e.g. `output.pdf.toc`.
+	Path string
+	// Message says what is wrong.
+	Message string
+}
+
+// Error formats the error as `file:line:column: path: message`.
+func (e SchemaError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s: %s", e.File, e.Path, e.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s: %s", e.File, e.Line, e.Column, e.Path, e.Message)
+}
+
+// schemaNode is the subset of JSON Schema that panforge.schema.json uses.
+type schemaNode struct {
+	Ref                  string                 `json:"$ref"`
+	Type                 json.RawMessage        `json:"type"`
+	Enum                 []interface{}          `json:"enum"`
+	Minimum              *float64               `json:"minimum"`
+	Properties           map[string]*schemaNode `json:"properties"`
+	AdditionalProperties *schemaNode            `json:"additionalProperties"`
+	Items                *schemaNode            `json:"items"`
+	AllOf                []*schemaNode          `json:"allOf"`
+	AnyOf                []*schemaNode          `json:"anyOf"`
+	Defs                 map[string]*schemaNode `json:"$defs"`
+}
+
+// types returns the types the node allows, none if it does not restrict them.
+func (s *schemaNode) types() []string {
+	if len(s.Type) == 0 {
+		return nil
+	}
+	var one string
+	if json.Unmarshal(s.Type, &one) == nil {
+		return []string{one}
+	}
+	var many []string
+	_ = json.Unmarshal(s.Type, &many)
+	return many
+}
+
+// describe says what the node accepts, e.g. "a boolean" or "one of simple, timestamp".
+func (s *schemaNode) describe() string {
+	var parts []string
+	for _, t := range s.types() {
+		parts = append(parts, article(t))
+	}
+	if len(s.Enum) > 0 {
+		parts = append(parts, "one of "+enumList(s.Enum))
+	}
+	for _, alt := range s.AnyOf {
+		parts = append(parts, alt.describe())
+	}
+	return strings.Join(parts, " or ")
+}
+
+// article prefixes a type name with "a" or "an".
+func article(t string) string {
+	if strings.ContainsAny(t[:1], "aeiou") {
+		return "an " + t
+	}
+	return "a " + t
+}
+
+// enumList lists enum values for messages.
+func enumList(values []interface{}) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = fmt.Sprint(v)
+	}
+	return strings.Join(s, ", ")
+}
+
+// ValidateFile checks a config file, or the front matter of a document, against Schema.
+// Values holding `${VAR}` references or `{{= }}` template expressions are only known
+// after expansion, so they are not checked.
+//
+// Parameters:
+//   - `path`: a config file (see ConfigExts) or a document
+//
+// Returns:
+//   - []SchemaError: the values that do not match, with their lines (none for TOML)
+//   - error: if the file cannot be read or parsed
+func ValidateFile(path string) ([]SchemaError, error) {
+	//nolint:gosec // G304: Potential file inclusion via variable is intended behavior for CLI file arguments
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	node, err := configNode(path, data)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s in '%s': %w", formatName(path), path, err)
+	}
+	if node == nil {
+		return nil, nil
+	}
+	var root schemaNode
+	if err := json.Unmarshal(schemaJSON, &root); err != nil {
+		return nil, err
+	}
+	v := &validator{root: &root, file: path}
+	v.check(&root, node, "")
+	// Shared options are checked apart from the keys around them; report in file order
+	slices.SortStableFunc(v.errs, func(a, b SchemaError) int {
+		if a.Line != b.Line {
+			return a.Line - b.Line
+		}
+		return a.Column - b.Column
+	})
+	return v.errs, nil
+}
+
+// configNode parses a config or front matter into a YAML node tree, which keeps the
+// lines of values. JSON is read as YAML, which it is a subset of; TOML has no positions.
+func configNode(path string, data []byte) (*yaml.Node, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	switch {
+	case ext == ".toml":
+		return tomlNode(data)
+	case slices.Contains(ConfigExts, ext):
+		// YAML or JSON: the whole file is the config
+	default:
+		format, header, _ := splitForeignFrontmatter(data)
+		switch format {
+		case "toml":
+			return tomlNode(header)
+		case "json":
+			data = header
+		default:
+			header, _ := SplitFrontmatter(string(data))
+			if header == "" {
+				return nil, nil
+			}
+			data = []byte(header)
+		}
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	return doc.Content[0], nil
+}
+
+// tomlNode converts TOML to a YAML node tree without positions.
+func tomlNode(data []byte) (*yaml.Node, error) {
+	var m map[string]interface{}
+	if err := toml.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	var node yaml.Node
+	if err := node.Encode(normalizeValue(m)); err != nil {
+		return nil, err
+	}
+	clearLines(&node)
+	return &node, nil
+}
+
+// clearLines drops the positions of a node tree that was not read from the file.
+func clearLines(n *yaml.Node) {
+	n.Line, n.Column = 0, 0
+	for _, c := range n.Content {
+		clearLines(c)
+	}
+}
+
+// validator collects the errors of one file.
+type validator struct {
+	root *schemaNode
+	file string
+	errs []SchemaError
+}
+
+// fail records an error at a node.
+func (v *validator) fail(n *yaml.Node, path, format string, args ...interface{}) {
+	v.errs = append(v.errs, SchemaError{
+		File:    v.file,
+		Line:    n.Line,
+		Column:  n.Column,
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// check validates a node and its children against a schema node.
+func (v *validator) check(s *schemaNode, n *yaml.Node, path string) {
+	if n.Kind == yaml.AliasNode && n.Alias != nil {
+		n = n.Alias
+	}
+	if n.Kind == yaml.ScalarNode && n.ShortTag() == "!!str" && (strings.Contains(n.Value, "${") || strings.Contains(n.Value, "{{=")) {
+		return
+	}
+	if s.Ref != "" {
+		if def := v.root.Defs[strings.TrimPrefix(s.Ref, "#/$defs/")]; def != nil {
+			v.check(def, n, path)
+		}
+	}
+	if types := s.types(); len(types) > 0 && !typeMatches(types, nodeType(n)) {
+		v.fail(n, path, "expected %s, got %s", s.describe(), nodeType(n))
+		return
+	}
+	if len(s.Enum) > 0 && !enumMatches(s.Enum, n) {
+		v.fail(n, path, "must be %s, got %q", s.describe(), n.Value)
+		return
+	}
+	if len(s.AnyOf) > 0 && !slices.ContainsFunc(s.AnyOf, func(alt *schemaNode) bool { return v.matches(alt, n, path) }) {
+		v.fail(n, path, "must be %s", s.describe())
+		return
+	}
+	if s.Minimum != nil && n.Kind == yaml.ScalarNode {
+		if f, err := strconv.ParseFloat(n.Value, 64); err == nil && f < *s.Minimum {
+			v.fail(n, path, "must be at least %v, got %s", *s.Minimum, n.Value)
+		}
+	}
+	for _, sub := range s.AllOf {
+		v.check(sub, n, path)
+	}
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i].Value, n.Content[i+1]
+			if prop := s.Properties[key]; prop != nil {
+				v.check(prop, value, joinPath(path, key))
+			} else if s.AdditionalProperties != nil {
+				v.check(s.AdditionalProperties, value, joinPath(path, key))
+			}
+		}
+	case yaml.SequenceNode:
+		if s.Items != nil {
+			for i, item := range n.Content {
+				v.check(s.Items, item, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	}
+}
+
+// matches reports whether a node matches a schema node, without recording errors.
+func (v *validator) matches(s *schemaNode, n *yaml.Node, path string) bool {
+	probe := &validator{root: v.root, file: v.file}
+	probe.check(s, n, path)
+	return len(probe.errs) == 0
+}
+
+// joinPath appends a key to a dotted path.
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// nodeType names the JSON Schema type of a YAML node. Dates count as strings, as they
+// would be written in JSON.
+func nodeType(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	}
+	switch n.ShortTag() {
+	case "!!int":
+		return "integer"
+	case "!!float":
+		return "number"
+	case "!!bool":
+		return "boolean"
+	case "!!null":
+		return "null"
+	}
+	return "string"
+}
+
+// typeMatches reports whether a node type is one of the allowed types; integers are
+// numbers too.
+func typeMatches(allowed []string, t string) bool {
+	return slices.Contains(allowed, t) || (t == "integer" && slices.Contains(allowed, "number"))
+}
+
+// enumMatches reports whether a scalar node is one of the enum values.
+func enumMatches(values []interface{}, n *yaml.Node) bool {
+	if n.Kind != yaml.ScalarNode {
+		return false
+	}
+	for _, v := range values {
+		if fmt.Sprint(v) == n.Value {
+			return true
+		}
+	}
+	return false
+}
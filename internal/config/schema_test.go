@@ -0,0 +1,72 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSchema_IsJSON(t *testing.T) {
+	var v map[string]interface{}
+	if err := json.Unmarshal(Schema(), &v); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+}
+
+func TestValidateFile(t *testing.T) {
+	dir := t.TempDir()
+	doc := filepath.Join(dir, "doc.md")
+	_ = os.WriteFile(doc, []byte(`---
+title: Doc
+toc: true
+sandbox: "yes"
+keep-builds: 0
+output:
+  html:
+  pdf:
+    pdf-engine: xelatex
+    on-conflict: replace
+    backup: daily
+    minify-html: ${MINIFY}
+chapters: [a.md, 3]
+---
+# Body
+`), 0600)
+
+	errs, err := ValidateFile(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		doc + ":4:10: sandbox: expected a boolean, got string",
+		doc + ":5:14: keep-builds: must be at least 1, got 0",
+		doc + `:10:18: output.pdf.on-conflict: must be one of prompt, skip, overwrite, rename, trash, got "replace"`,
+		doc + ":11:13: output.pdf.backup: must be a boolean or one of simple, timestamp",
+		doc + ":13:18: chapters[1]: expected a string, got integer",
+	}
+	var got []string
+	for _, e := range errs {
+		got = append(got, e.Error())
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	toml := filepath.Join(dir, ".panforge.toml")
+	_ = os.WriteFile(toml, []byte("[output.html]\ninline-css = \"no\"\n"), 0600)
+	errs, err = ValidateFile(toml)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 1 || errs[0].Error() != toml+": output.html.inline-css: expected a boolean, got string" {
+		t.Errorf("unexpected TOML errors: %v", errs)
+	}
+
+	plain := filepath.Join(dir, "plain.md")
+	_ = os.WriteFile(plain, []byte("# No front matter\n"), 0600)
+	if errs, err := ValidateFile(plain); err != nil || len(errs) != 0 {
+		t.Errorf("expected no errors without front matter, got %v, %v", errs, err)
+	}
+}
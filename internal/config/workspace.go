@@ -0,0 +1,140 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// WorkspaceFileName is the name of the file that lists the projects of a workspace.
+const WorkspaceFileName = "panforge.work"
+
+// Workspace lists several document projects that are built together.
+type Workspace struct {
+	// Path is the absolute path of the workspace file.
+	Path string `yaml:"-"`
+	// Projects are the documents or directories to build, relative to the workspace file.
+	Projects []WorkspaceProject `yaml:"projects"`
+	// Defaults are settings every document inherits unless its own header sets them.
+	Defaults map[string]interface{} `yaml:"defaults,omitempty"`
+	// Bibliography files shared by all documents, relative to the workspace file.
+	Bibliography StringList `yaml:"bibliography,omitempty"`
+	// CSL is the citation style shared by all documents, relative to the workspace file.
+	CSL string `yaml:"csl,omitempty"`
+	// Namespaces prefixes each document's identifiers with its project name in HTML output.
+	Namespaces bool `yaml:"namespaces,omitempty"`
+}
+
+// StringList is a list of strings that may also be written as a single string.
+type StringList []string
+
+// UnmarshalYAML accepts either a single string or a list of strings.
+func (l *StringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = StringList{node.Value}
+		return nil
+	}
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// WorkspaceProject is a single entry of a workspace. It is either a path or a map with
+// a `path` and optional target formats.
+type WorkspaceProject struct {
+	// Path is a Markdown file or a directory of Markdown files.
+	Path string `yaml:"path"`
+	// Targets restricts the project to these formats (default: the document's outputs).
+	Targets []string `yaml:"to,omitempty"`
+	// Name identifies the project in cross-references (default: the file name without extension).
+	Name string `yaml:"name,omitempty"`
+}
+
+// UnmarshalYAML accepts either a plain path or a full project map.
+func (p *WorkspaceProject) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		p.Path = node.Value
+		return nil
+	}
+	type plain WorkspaceProject
+	return node.Decode((*plain)(p))
+}
+
+// ID returns the project's name, or the base name of its path without the extension.
+func (p WorkspaceProject) ID() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	base := filepath.Base(p.Path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// Dir returns the directory containing the workspace file.
+func (w *Workspace) Dir() string {
+	return filepath.Dir(w.Path)
+}
+
+// LoadWorkspace reads and validates a workspace file.
+//
+// Parameters:
+//   - `path`: the path to the workspace file
+//
+// Returns:
+//   - *Workspace: the parsed workspace
+//   - error: if the file cannot be read, parsed, or lists no projects
+func LoadWorkspace(path string) (*Workspace, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		absPath = path // fallback
+	}
+	//nolint:gosec // G304: reading the user-supplied workspace file is intended
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, err
+	}
+	ws := &Workspace{Path: absPath}
+	if err := yaml.Unmarshal(data, ws); err != nil {
+		return nil, fmt.Errorf("error parsing workspace '%s': %w", absPath, err)
+	}
+	if len(ws.Projects) == 0 {
+		return nil, fmt.Errorf("workspace '%s' lists no projects", absPath)
+	}
+	for i, p := range ws.Projects {
+		if p.Path == "" {
+			return nil, fmt.Errorf("workspace '%s': project %d has no path", absPath, i+1)
+		}
+	}
+	return ws, nil
+}
+
+// FindWorkspace looks for a workspace file in dir and its parent directories.
+//
+// Parameters:
+//   - `dir`: the directory to start searching from
+//
+// Returns:
+//   - string: the path of the nearest workspace file
+//   - error: if no workspace file is found
+func FindWorkspace(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		candidate := filepath.Join(dir, WorkspaceFileName)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no %s found in %s or any parent directory", WorkspaceFileName, dir)
+		}
+		dir = parent
+	}
+}
@@ -1,20 +1,56 @@
 // Package options defines the available command-line flags and arguments.
 package options
 
-import "log/slog"
+import (
+	"log/slog"
+	"time"
+)
 
 // Options holds CLI flags and runtime configuration.
 // It maps command line flags to struct fields.
 type Options struct {
-	Targets     []string     `flag:"to" shorthand:"t"`
-	Output      string       `flag:"output" shorthand:"o"`
-	Force       bool         `flag:"force" shorthand:"f"`
-	DryRun      bool         `flag:"dry-run" shorthand:"n"`
-	Verbose     bool         `flag:"verbose" shorthand:"v"`
-	Quiet       bool         `flag:"quiet" shorthand:"q"`
-	Log         string       `flag:"log" shorthand:"l"`
-	All         bool         `flag:"all" shorthand:"a"`
-	Watch       bool         `flag:"watch" shorthand:"w"`
-	Concurrency int          `flag:"concurrency" shorthand:"c"`
-	Logger      *slog.Logger // Not a flag
+	Targets           []string      `flag:"to" shorthand:"t"`
+	From              string        `flag:"from"`
+	Output            string        `flag:"output" shorthand:"o"`
+	OutputDir         string        `flag:"output-dir"`
+	DefaultTargets    []string      `flag:"default-to"`
+	Force             bool          `flag:"force" shorthand:"f"`
+	DryRun            bool          `flag:"dry-run" shorthand:"n"`
+	Verbose           bool          `flag:"verbose" shorthand:"v"`
+	Quiet             bool          `flag:"quiet" shorthand:"q"`
+	Log               string        `flag:"log" shorthand:"l"`
+	LogDir            string        `flag:"log-dir"`
+	All               bool          `flag:"all" shorthand:"a"`
+	Watch             bool          `flag:"watch" shorthand:"w"`
+	Concurrency       int           `flag:"concurrency" shorthand:"c"`
+	Notify            bool          `flag:"notify"`
+	NoCache           bool          `flag:"no-cache"`
+	ChangedSince      string        `flag:"changed-since"`
+	CheckPaths        bool          `flag:"check-paths"`
+	Strict            bool          `flag:"strict"`
+	TraceConfig       bool          `flag:"trace-config"`
+	NoInteractive     bool          `flag:"no-interactive"`
+	NoInput           string        `flag:"no-input"`
+	Recipe            string        `flag:"recipe"`
+	Timeout           time.Duration `flag:"timeout"`
+	Retries           int           `flag:"retries"`
+	KeepGoing         bool          `flag:"keep-going" shorthand:"k"`
+	Report            string        `flag:"report"`
+	ReportFile        string        `flag:"report-file"`
+	Annotations       string        `flag:"annotations"`
+	NoProgress        bool          `flag:"no-progress"`
+	Color             string        `flag:"color"`
+	LogFormat         string        `flag:"log-format"`
+	PandocPath        string        `flag:"pandoc-path"`
+	Config            string        `flag:"config"`
+	KeepIntermediates string        `flag:"keep-intermediates"`
+	Chdir             string        `flag:"chdir"`
+	SamplePages       int           `flag:"sample-pages"`
+	Archive           string        `flag:"archive"`
+	Manifest          string        `flag:"manifest"`
+	Matrix            []string      `flag:"matrix"`
+	Backup            string        `flag:"backup"`
+	OnConflict        string        `flag:"on-conflict"`
+	SubprocessOutput  string        `flag:"subprocess-output"`
+	Logger            *slog.Logger  // Not a flag
 }
@@ -0,0 +1,177 @@
+package pandoc
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Diagnostic severities.
+const (
+	// SeverityWarning marks a problem pandoc worked around.
+	SeverityWarning = "warning"
+	// SeverityError marks a problem that made the conversion fail.
+	SeverityError = "error"
+)
+
+// Diagnostic kinds recognized in pandoc's messages.
+const (
+	// KindUndefinedReference is a link reference or citation that could not be resolved.
+	KindUndefinedReference = "undefined-reference"
+	// KindMissingResource is an image or other resource pandoc could not fetch.
+	KindMissingResource = "missing-resource"
+	// KindDuplicateNote is a footnote defined more than once.
+	KindDuplicateNote = "duplicate-note"
+	// KindDuplicateIdentifier is a heading or element identifier used more than once.
+	KindDuplicateIdentifier = "duplicate-identifier"
+	// KindLaTeX is a message from the LaTeX engine while producing a PDF.
+	KindLaTeX = "latex"
+	// KindOther is any other pandoc message.
+	KindOther = "other"
+)
+
+// Diagnostic is a structured pandoc warning or error.
+type Diagnostic struct {
+	// Severity is SeverityWarning or SeverityError.
+	Severity string `json:"severity"`
+	// Kind classifies the message (e.g. KindUndefinedReference).
+	Kind string `json:"kind"`
+	// Message is the message text without the [WARNING] prefix.
+	Message string `json:"message"`
+	// File is the source file named in the message, if any.
+	File string `json:"file,omitempty"`
+	// Line and Column give the source position, or 0 when unknown.
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+}
+
+// String formats the diagnostic as `file:line:col: severity: message`, the form editors
+// and CI annotators recognize. Unknown parts of the position are left out.
+func (d Diagnostic) String() string {
+	var b strings.Builder
+	if d.File != "" {
+		b.WriteString(d.File)
+		if d.Line > 0 {
+			fmt.Fprintf(&b, ":%d", d.Line)
+			if d.Column > 0 {
+				fmt.Fprintf(&b, ":%d", d.Column)
+			}
+		}
+		b.WriteString(": ")
+	}
+	fmt.Fprintf(&b, "%s: %s", d.Severity, d.Message)
+	if d.Kind != KindOther {
+		fmt.Fprintf(&b, " [%s]", d.Kind)
+	}
+	return b.String()
+}
+
+var (
+	// logLine matches pandoc's log prefix, e.g. "[WARNING] Duplicate note reference".
+	logLine = regexp.MustCompile(`^\[(WARNING|ERROR)\]\s*(.*)$`)
+	// latexLine matches LaTeX errors and warnings that pandoc relays when producing a PDF.
+	latexLine = regexp.MustCompile(`^(?:! (LaTeX Error: .*)|(?:LaTeX|Package \S+) Warning: (.*))$`)
+	// position matches "at doc.md line 3 column 5", "at line 3 column 5", and `"doc.md" (line 3, column 5)`.
+	position = regexp.MustCompile(`(?:\bat\s+(?:"?([^"\s]+)"?\s+)?|"([^"]+)"\s+\()line\s+(\d+),?\s+column\s+(\d+)\)?`)
+	// latexPosition matches "on input line 12".
+	latexPosition = regexp.MustCompile(`on input line (\d+)`)
+)
+
+// ParseDiagnostics extracts structured diagnostics from pandoc's stderr. Pandoc's
+// `[WARNING]` and `[ERROR]` messages are always recognized; LaTeX messages are only
+// parsed for formats that go through a LaTeX engine, where their line numbers refer to
+// the generated .tex file rather than the source.
+//
+// Parameters:
+//   - `stderr`: pandoc's standard error output
+//   - `format`: the target output format (e.g. "pdf", "html")
+//
+// Returns:
+//   - []Diagnostic: the diagnostics in the order pandoc reported them
+func ParseDiagnostics(stderr, format string) []Diagnostic {
+	latex := usesLaTeX(format)
+	var diags []Diagnostic
+	scanner := bufio.NewScanner(strings.NewReader(stderr))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if m := logLine.FindStringSubmatch(line); m != nil {
+			d := Diagnostic{Severity: SeverityWarning, Message: m[2]}
+			if m[1] == "ERROR" {
+				d.Severity = SeverityError
+			}
+			diags = append(diags, d)
+			continue
+		}
+		if latex {
+			if m := latexLine.FindStringSubmatch(line); m != nil {
+				d := Diagnostic{Severity: SeverityWarning, Kind: KindLaTeX, Message: m[2]}
+				if m[1] != "" {
+					d.Severity = SeverityError
+					d.Message = m[1]
+				}
+				if pm := latexPosition.FindStringSubmatch(d.Message); pm != nil {
+					d.Line, _ = strconv.Atoi(pm[1])
+				}
+				diags = append(diags, d)
+				continue
+			}
+		}
+		// Pandoc indents the continuation lines of long messages.
+		if len(diags) > 0 && line != "" && (line[0] == ' ' || line[0] == '\t') && diags[len(diags)-1].Kind != KindLaTeX {
+			diags[len(diags)-1].Message += " " + strings.TrimSpace(line)
+		}
+	}
+
+	for i := range diags {
+		d := &diags[i]
+		if d.Kind == "" {
+			d.Kind = classifyDiagnostic(d.Message)
+			if m := position.FindStringSubmatch(d.Message); m != nil {
+				d.File = m[1]
+				if d.File == "" {
+					d.File = m[2]
+				}
+				d.Line, _ = strconv.Atoi(m[3])
+				d.Column, _ = strconv.Atoi(m[4])
+			}
+		}
+	}
+	return diags
+}
+
+// classifyDiagnostic assigns a kind to a pandoc message.
+//
+// Parameters:
+//   - `msg`: the message text
+func classifyDiagnostic(msg string) string {
+	lower := strings.ToLower(msg)
+	switch {
+	case strings.Contains(lower, "reference not found"),
+		strings.Contains(lower, "citation") && strings.Contains(lower, "not found"):
+		return KindUndefinedReference
+	case strings.Contains(lower, "could not fetch"),
+		strings.Contains(lower, "could not find") && (strings.Contains(lower, "image") || strings.Contains(lower, "resource")):
+		return KindMissingResource
+	case strings.Contains(lower, "duplicate note"):
+		return KindDuplicateNote
+	case strings.Contains(lower, "duplicate identifier"):
+		return KindDuplicateIdentifier
+	default:
+		return KindOther
+	}
+}
+
+// usesLaTeX reports whether a target format is produced through a LaTeX engine.
+//
+// Parameters:
+//   - `format`: the output format
+func usesLaTeX(format string) bool {
+	switch NormalizeFormat(format) {
+	case "pdf", "latex", "beamer":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,57 @@
+package pandoc
+
+import (
+	"testing"
+)
+
+func TestParseDiagnostics(t *testing.T) {
+	stderr := `[WARNING] Reference not found for 'Link "intro"' at doc.md line 4 column 3
+[WARNING] Could not fetch resource missing.png: replacing image with description
+[WARNING] Duplicate note reference '1' at line 12 column 1
+[WARNING] Citeproc: citation smith2020 not found
+[WARNING] Duplicate identifier 'intro'
+  "chapter.md" (line 8, column 1)
+some unrelated output
+! LaTeX Error: File ` + "`foo.sty'" + ` not found.
+`
+	diags := ParseDiagnostics(stderr, "html")
+	want := []Diagnostic{
+		{Severity: SeverityWarning, Kind: KindUndefinedReference, File: "doc.md", Line: 4, Column: 3},
+		{Severity: SeverityWarning, Kind: KindMissingResource},
+		{Severity: SeverityWarning, Kind: KindDuplicateNote, Line: 12, Column: 1},
+		{Severity: SeverityWarning, Kind: KindUndefinedReference},
+		{Severity: SeverityWarning, Kind: KindDuplicateIdentifier, File: "chapter.md", Line: 8, Column: 1},
+	}
+	if len(diags) != len(want) {
+		t.Fatalf("got %d diagnostics, want %d: %+v", len(diags), len(want), diags)
+	}
+	for i, w := range want {
+		d := diags[i]
+		if d.Severity != w.Severity || d.Kind != w.Kind || d.File != w.File || d.Line != w.Line || d.Column != w.Column {
+			t.Errorf("diagnostic %d = %+v, want %+v", i, d, w)
+		}
+	}
+
+	// LaTeX messages are only parsed for LaTeX-based formats.
+	diags = ParseDiagnostics(stderr, "pdf")
+	last := diags[len(diags)-1]
+	if last.Kind != KindLaTeX || last.Severity != SeverityError {
+		t.Errorf("expected a LaTeX error for pdf, got %+v", last)
+	}
+}
+
+func TestDiagnosticString(t *testing.T) {
+	tests := []struct {
+		d    Diagnostic
+		want string
+	}{
+		{Diagnostic{Severity: SeverityWarning, Kind: KindDuplicateNote, Message: "Duplicate note", File: "doc.md", Line: 3, Column: 1}, "doc.md:3:1: warning: Duplicate note [duplicate-note]"},
+		{Diagnostic{Severity: SeverityWarning, Kind: KindOther, Message: "odd"}, "warning: odd"},
+		{Diagnostic{Severity: SeverityError, Kind: KindLaTeX, Message: "boom", File: "doc.md"}, "doc.md: error: boom [latex]"},
+	}
+	for _, tt := range tests {
+		if got := tt.d.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,415 @@
+package pandoc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/rapjul/panforge/internal/config"
+	"github.com/rapjul/panforge/internal/utils"
+)
+
+// FilenameData is what a filename template is executed with, e.g.
+// `{{ .Title | slug | trunc 40 }}.{{ .Ext }}`.
+type FilenameData struct {
+	// Title is the document title, its first heading, or the input's name.
+	Title string
+	// TitleSlug is the slugified title.
+	TitleSlug string
+	// Author is the document author.
+	Author string
+	// AuthorSlug is the slugified author.
+	AuthorSlug string
+	// Date is the build date, formatted with `date-format`.
+	Date string
+	// Time is the build time, formatted with `time-format`.
+	Time string
+	// Ext is the output extension, without the dot.
+	Ext string
+	// Format is the pandoc output format.
+	Format string
+	// Input is the input's file name without its extension.
+	Input string
+	// Now is the build time, for the date function.
+	Now time.Time
+	// Meta holds the document's other metadata keys, e.g. `{{ .Meta.client }}`.
+	Meta map[string]interface{}
+	// Counter returns the value of the counter function, or is nil if there is none.
+	Counter func() (int, error)
+	// Git describes the input's git repository for the version, commit, and branch
+	// functions, or is nil outside a repository.
+	Git func() utils.GitInfo
+	// Slug customizes the slug function.
+	Slug utils.SlugOptions
+}
+
+// legacyMetaToken matches `{meta.key}`, which may name a nested key, e.g. `{meta.client.code}`.
+var legacyMetaToken = regexp.MustCompile(`\{meta\.([\w.-]+)\}`)
+
+// legacyFilenameTokens translates the `{title}`-style variables to template actions,
+// so templates written before text/template keep working.
+var legacyFilenameTokens = strings.NewReplacer(
+	"{title-slug}", "{{.TitleSlug}}",
+	"{author-slug}", "{{.AuthorSlug}}",
+	"{title}", "{{.Title}}",
+	"{author}", "{{.Author}}",
+	"{date}", "{{.Date}}",
+	"{time}", "{{.Time}}",
+	"{ext}", "{{.Ext}}",
+	"{counter}", "{{counter}}",
+	"{version}", "{{version}}",
+	"{commit}", "{{commit}}",
+	"{branch}", "{{branch}}",
+)
+
+// filenameFuncs are the functions a filename template can use besides the built-in ones.
+//
+// Parameters:
+//   - `data`: the variables, for the counter, git, date, meta, and slug functions
+func filenameFuncs(data FilenameData) template.FuncMap {
+	// The counter advances when it is read, so read it once however often it is used
+	var counter int
+	var counterErr error
+	counterRead := false
+	var git *utils.GitInfo
+	describe := func() utils.GitInfo {
+		if git == nil {
+			git = &utils.GitInfo{}
+			if data.Git != nil {
+				*git = data.Git()
+			}
+		}
+		return *git
+	}
+	return template.FuncMap{
+		"slug": func(s string) string {
+			return utils.SlugifyWith(s, data.Slug)
+		},
+		"lower": strings.ToLower,
+		"upper": strings.ToUpper,
+		"trim":  strings.TrimSpace,
+		"replace": func(old, new, s string) string {
+			return strings.ReplaceAll(s, old, new)
+		},
+		// trunc keeps the first n characters
+		"trunc": func(n int, s string) string {
+			if r := []rune(s); n >= 0 && len(r) > n {
+				return strings.TrimSpace(string(r[:n]))
+			}
+			return s
+		},
+		// default returns def when the value is empty
+		"default": func(def, s string) string {
+			if s == "" {
+				return def
+			}
+			return s
+		},
+		// date formats the build time with a Go layout or strftime format
+		"date": func(layout string) string {
+			return utils.FormatTime(data.Now, layout)
+		},
+		// counter returns the next number of the document's outputs in this format
+		"counter": func() (int, error) {
+			if !counterRead {
+				counterRead = true
+				if data.Counter == nil {
+					counterErr = fmt.Errorf("{counter} is not available here")
+				} else {
+					counter, counterErr = data.Counter()
+				}
+			}
+			return counter, counterErr
+		},
+		// version, commit, and branch describe the input's git repository
+		"version": func() string { return describe().Version },
+		"commit":  func() string { return describe().Commit },
+		"branch":  func() string { return describe().Branch },
+		// meta looks up a metadata key, "" if the document does not set it
+		"meta": func(key string) string {
+			return metaString(data, key)
+		},
+	}
+}
+
+// metaString formats a metadata value for a filename: a dotted key looks into nested
+// maps, and list items are joined with dashes.
+//
+// Parameters:
+//   - `data`: the variables
+//   - `key`: the key, e.g. "client" or "client.code"
+func metaString(data FilenameData, key string) string {
+	switch key {
+	case "title":
+		return data.Title
+	case "author":
+		return data.Author
+	}
+	var v interface{} = data.Meta
+	for _, part := range strings.Split(key, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		v = m[part]
+	}
+	switch v := v.(type) {
+	case nil, map[string]interface{}:
+		return ""
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, fmt.Sprint(item))
+		}
+		return strings.Join(items, "-")
+	case time.Time:
+		return v.Format(utils.DefaultDateFormat)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// slugOptions reads the `slug` setting: a map with `separator`, `max-length`, and
+// `lowercase` keys. The target value wins over the global one.
+//
+// Parameters:
+//   - `cfg`: global configuration
+//   - `metaOut`: format-specific configuration from YAML
+//
+// Returns:
+//   - utils.SlugOptions: the options (the zero value if unset)
+//   - error: if the setting is invalid
+func slugOptions(cfg *config.Config, metaOut map[string]interface{}) (utils.SlugOptions, error) {
+	raw, ok := metaOut["slug"]
+	if !ok {
+		raw = cfg.Generic["slug"]
+	}
+	var opts utils.SlugOptions
+	switch v := raw.(type) {
+	case nil:
+		return opts, nil
+	case map[string]interface{}:
+		if sep, ok := v["separator"]; ok {
+			s, ok := sep.(string)
+			if !ok || s == "" || strings.ContainsAny(s, `/\`) {
+				return opts, fmt.Errorf("slug: separator must be a non-empty string without slashes")
+			}
+			opts.Separator = s
+		}
+		if n, ok := v["max-length"]; ok {
+			length, ok := n.(int)
+			if !ok || length < 1 {
+				return opts, fmt.Errorf("slug: max-length must be a positive number")
+			}
+			opts.MaxLength = length
+		}
+		if lower, ok := v["lowercase"]; ok {
+			b, ok := lower.(bool)
+			if !ok {
+				return opts, fmt.Errorf("slug: lowercase must be true or false")
+			}
+			opts.KeepCase = !b
+		}
+		return opts, nil
+	default:
+		return opts, fmt.Errorf("slug must be a map with separator, max-length, and lowercase")
+	}
+}
+
+// sanitizeOptions reads the `sanitize` setting: a map with `replace` (a map of text to
+// its replacement), `forbidden` (extra characters to replace with underscores),
+// `strip-emoji`, and `portable` keys. The target value wins over the global one.
+//
+// Parameters:
+//   - `cfg`: global configuration
+//   - `metaOut`: format-specific configuration from YAML
+//
+// Returns:
+//   - utils.SanitizeOptions: the rules (the zero value if unset)
+//   - error: if the setting is invalid
+func sanitizeOptions(cfg *config.Config, metaOut map[string]interface{}) (utils.SanitizeOptions, error) {
+	raw, ok := metaOut["sanitize"]
+	if !ok {
+		raw = cfg.Generic["sanitize"]
+	}
+	var opts utils.SanitizeOptions
+	if raw == nil {
+		return opts, nil
+	}
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		return opts, fmt.Errorf("sanitize must be a map with replace, forbidden, strip-emoji, and portable")
+	}
+	switch replace := m["replace"].(type) {
+	case nil:
+	case map[string]interface{}:
+		opts.Replace = make(map[string]string, len(replace))
+		for from, to := range replace {
+			switch to := to.(type) {
+			case nil:
+				opts.Replace[from] = ""
+			case string:
+				opts.Replace[from] = to
+			default:
+				return opts, fmt.Errorf("sanitize: the replacement of %q must be a string", from)
+			}
+		}
+	default:
+		return opts, fmt.Errorf("sanitize: replace must be a map of text to its replacement")
+	}
+	if forbidden, ok := m["forbidden"]; ok {
+		s, ok := forbidden.(string)
+		if !ok {
+			return opts, fmt.Errorf("sanitize: forbidden must be a string of characters")
+		}
+		opts.Forbidden = s
+	}
+	for key, dest := range map[string]*bool{"strip-emoji": &opts.StripEmoji, "portable": &opts.Portable} {
+		if v, ok := m[key]; ok {
+			b, ok := v.(bool)
+			if !ok {
+				return opts, fmt.Errorf("sanitize: %s must be true or false", key)
+			}
+			*dest = b
+		}
+	}
+	return opts, nil
+}
+
+// PathLimit reads the `max-path-length` setting: the most bytes an output path may
+// have, or a map with `length` and `hash` (append a short hash of the full name to
+// truncated names). The target value wins over the global one.
+//
+// Parameters:
+//   - `cfg`: global configuration
+//   - `metaOut`: format-specific configuration from YAML
+//
+// Returns:
+//   - int: the limit (0 for none)
+//   - bool: whether truncated names get a hash
+//   - error: if the setting is invalid
+func PathLimit(cfg *config.Config, metaOut map[string]interface{}) (int, bool, error) {
+	raw, ok := metaOut["max-path-length"]
+	if !ok {
+		raw = cfg.Generic["max-path-length"]
+	}
+	length, hash := 0, false
+	switch v := raw.(type) {
+	case nil:
+		return 0, false, nil
+	case int:
+		length = v
+	case map[string]interface{}:
+		n, ok := v["length"].(int)
+		if !ok {
+			return 0, false, fmt.Errorf("max-path-length: length must be a number")
+		}
+		length = n
+		if h, ok := v["hash"]; ok {
+			if hash, ok = h.(bool); !ok {
+				return 0, false, fmt.Errorf("max-path-length: hash must be true or false")
+			}
+		}
+	default:
+		return 0, false, fmt.Errorf("max-path-length must be a number or a map with length and hash")
+	}
+	if length < 1 {
+		return 0, false, fmt.Errorf("max-path-length must be positive")
+	}
+	return length, hash, nil
+}
+
+// pathHashLength is how many hex digits of the full name a truncated name keeps.
+const pathHashLength = 8
+
+// FitPathLength shortens the file name of an output path so that the path has at most
+// limit bytes. Only the first stem bytes of the name are cut, at a character boundary;
+// what follows, such as a matrix or collision suffix and the extension, is kept. With
+// hash, a short hash of the full name is appended to a cut stem, so names that only
+// differ in the cut part stay apart.
+//
+// Parameters:
+//   - `path`: the output path
+//   - `stem`: how many bytes at the start of the file name may be cut
+//   - `limit`: the most bytes the path may have
+//   - `hash`: whether to append a hash when the name is cut
+//
+// Returns:
+//   - string: the path, shortened if needed
+//   - error: if not even a short name fits
+func FitPathLength(path string, stem, limit int, hash bool) (string, error) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if len(path) <= limit {
+		return path, nil
+	}
+	dir, name := filepath.Dir(path), filepath.Base(path)
+	stem = min(max(stem, 0), len(name))
+	head, tail := name[:stem], name[stem:]
+	suffix := ""
+	if hash {
+		sum := sha256.Sum256([]byte(name))
+		suffix = "-" + hex.EncodeToString(sum[:])[:pathHashLength]
+	}
+	keep := limit - len(dir) - len(string(filepath.Separator)) - len(tail) - len(suffix)
+	cut := 0
+	for i := range head {
+		if i > keep {
+			break
+		}
+		cut = i
+	}
+	if len(head) <= keep {
+		cut = len(head)
+	}
+	if keep < 1 || cut == 0 {
+		return "", fmt.Errorf("max-path-length %d leaves no room for a name in %s", limit, dir)
+	}
+	// Windows drops trailing dots and spaces, and a dangling separator looks odd
+	if trimmed := strings.TrimRight(head[:cut], " .-_"); trimmed != "" {
+		cut = len(trimmed)
+	}
+	return filepath.Join(dir, head[:cut]+suffix+tail), nil
+}
+
+// legacyTemplate rewrites the `{title}`-style variables of a filename template as
+// template actions.
+func legacyTemplate(tmpl string) string {
+	tmpl = legacyMetaToken.ReplaceAllString(tmpl, `{{meta "$1"}}`)
+	return legacyFilenameTokens.Replace(tmpl)
+}
+
+// ExpandFilenameTemplate executes a filename template. It is a Go text/template
+// (`{{ .Title | slug }}`) in which the `{title}`-style variables, and `{meta.key}` for
+// any metadata key, also still work; other text in braces, e.g. the `{profile}` token of
+// a matrix, is kept as written.
+//
+// Parameters:
+//   - `tmpl`: the template
+//   - `data`: the variables
+//
+// Returns:
+//   - string: the expanded name, before sanitization
+//   - error: if the template is invalid or fails
+func ExpandFilenameTemplate(tmpl string, data FilenameData) (string, error) {
+	t, err := template.New("filename-template").Option("missingkey=error").Funcs(filenameFuncs(data)).Parse(legacyTemplate(tmpl))
+	if err != nil {
+		return "", fmt.Errorf("invalid filename-template: %w", err)
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("filename-template: %w", err)
+	}
+	return b.String(), nil
+}
+
+// inputStem returns a file's name without its extension.
+func inputStem(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
@@ -0,0 +1,104 @@
+package pandoc
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExpandFilenameTemplate(t *testing.T) {
+	data := FilenameData{
+		Title:     "A Very Long Report Title",
+		TitleSlug: "a-very-long-report-title",
+		Date:      "2024-01-31",
+		Ext:       "pdf",
+		Format:    "pdf",
+		Input:     "report",
+		Now:       time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC),
+		Meta: map[string]interface{}{
+			"client":   "ACME",
+			"chapter":  3,
+			"project":  map[string]interface{}{"code": "P-17"},
+			"keywords": []interface{}{"draft", "internal"},
+		},
+	}
+	tests := []struct {
+		tmpl string
+		want string
+	}{
+		{"{title}_{date}.{ext}", "A Very Long Report Title_2024-01-31.pdf"},
+		{"{{ .Title | slug | trunc 11 }}.{{ .Ext }}", "a-very-long.pdf"},
+		{`{{ if .Author }}{{ .AuthorSlug }}-{{ end }}{{ .Input }}.{ext}`, "report.pdf"},
+		{`{{ .Author | default "anonymous" }}-{{ date "%Y" }}.{ext}`, "anonymous-2024.pdf"},
+		{"{title-slug}-{profile}.{ext}", "a-very-long-report-title-{profile}.pdf"},
+		{`{{ .Title | replace " " "" | lower }}`, "averylongreporttitle"},
+		{"{meta.client}-ch{meta.chapter}-{meta.project.code}.{ext}", "ACME-ch3-P-17.pdf"},
+		{"{meta.keywords}{meta.missing}", "draft-internal"},
+		{`{{ meta "client" | lower }}-{{ .Meta.chapter }}`, "acme-3"},
+	}
+	for _, tt := range tests {
+		got, err := ExpandFilenameTemplate(tt.tmpl, data)
+		if err != nil || got != tt.want {
+			t.Errorf("ExpandFilenameTemplate(%q) = %q, %v, want %q", tt.tmpl, got, err, tt.want)
+		}
+	}
+	for _, tmpl := range []string{"{{ .Title", "{{ .Missing }}", "{{ nope }}"} {
+		if _, err := ExpandFilenameTemplate(tmpl, data); err == nil {
+			t.Errorf("expected an error for %q", tmpl)
+		}
+	}
+}
+
+func TestExpandFilenameTemplate_Counter(t *testing.T) {
+	calls := 0
+	data := FilenameData{Title: "report", Ext: "pdf", Counter: func() (int, error) {
+		calls++
+		return 7, nil
+	}}
+	got, err := ExpandFilenameTemplate(`{title}_v{counter}{{ if gt counter 1 }}-rev{{ end }}.{ext}`, data)
+	if err != nil || got != "report_v7-rev.pdf" {
+		t.Errorf("ExpandFilenameTemplate() = %q, %v", got, err)
+	}
+	if calls != 1 {
+		t.Errorf("expected the counter to be read once, got %d calls", calls)
+	}
+	if got, _ := ExpandFilenameTemplate("{title}.{ext}", data); got != "report.pdf" || calls != 1 {
+		t.Errorf("expected the counter not to be read without {counter}, got %q after %d calls", got, calls)
+	}
+	if _, err := ExpandFilenameTemplate("{counter}", FilenameData{}); err == nil {
+		t.Error("expected an error without a counter")
+	}
+}
+
+func TestFitPathLength(t *testing.T) {
+	dir := "/docs"
+	name := "A Very Long Report Title With Many Words.pdf"
+	path := filepath.Join(dir, name)
+	stem := len(name) - len(".pdf")
+	if got, err := FitPathLength(path, stem, 100, false); err != nil || got != path {
+		t.Errorf("expected a short path to be kept, got %q, %v", got, err)
+	}
+	got, err := FitPathLength(path, stem, 26, false)
+	if err != nil || got != filepath.Join(dir, "A Very Long Repo.pdf") || len(got) > 26 {
+		t.Errorf("FitPathLength() = %q, %v", got, err)
+	}
+	hashed, err := FitPathLength(path, stem, 26, true)
+	if err != nil || len(hashed) > 26 || hashed[len(hashed)-13:len(hashed)-12] != "-" {
+		t.Errorf("expected a hash before the extension, got %q, %v", hashed, err)
+	}
+	other, _ := FitPathLength(filepath.Join(dir, "A Very Long Report Title With Other Words.pdf"), stem, 26, true)
+	if other == hashed {
+		t.Errorf("expected names cut to the same text to get different hashes, got %q", other)
+	}
+	if got, _ := FitPathLength(filepath.Join(dir, "Überlänge.pdf"), len("Überlänge"), 13, false); got != filepath.Join(dir, "Üb.pdf") {
+		t.Errorf("expected the cut at a character boundary, got %q", got)
+	}
+	// A suffix added after the name was generated is kept
+	suffixed := filepath.Join(dir, "A Very Long Report Title With Many Words-beamer.pdf")
+	if got, _ := FitPathLength(suffixed, stem, 30, false); got != filepath.Join(dir, "A Very Long R-beamer.pdf") {
+		t.Errorf("expected the suffix kept, got %q", got)
+	}
+	if _, err := FitPathLength(path, stem, 8, false); err == nil {
+		t.Error("expected an error when no name fits")
+	}
+}
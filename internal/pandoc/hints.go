@@ -0,0 +1,91 @@
+package pandoc
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// failureHint turns a well-known PDF engine error into advice on fixing it.
+type failureHint struct {
+	pattern *regexp.Regexp
+	// hint formats the advice from the pattern's submatches.
+	hint func(m []string) string
+}
+
+// failureHints are checked in order against the output of a failed run.
+var failureHints = []failureHint{
+	{
+		pattern: regexp.MustCompile(`(\S+) not found\. Please select a different --pdf-engine or install`),
+		hint: func(m []string) string {
+			return fmt.Sprintf("the PDF engine %s is not installed: install a TeX distribution (TeX Live, MiKTeX, or TinyTeX) or set pdf-engine to one that is installed (see panforge check)", m[1])
+		},
+	},
+	{
+		pattern: regexp.MustCompile("LaTeX Error: File `([^']+)\\.(sty|cls)' not found"),
+		hint: func(m []string) string {
+			kind := "package"
+			if m[2] == "cls" {
+				kind = "document class"
+			}
+			return fmt.Sprintf("the LaTeX %s %s is not installed: run `tlmgr install %s` (TeX Live), or install your distribution's extra packages (e.g. texlive-latex-extra)", kind, m[1], m[1])
+		},
+	},
+	{
+		pattern: regexp.MustCompile(`(?:The font "([^"]+)" cannot be found|font "([^"]+)" (?:not loadable|not found))`),
+		hint: func(m []string) string {
+			font := m[1]
+			if font == "" {
+				font = m[2]
+			}
+			return fmt.Sprintf("the font %q is not installed: install it (TeX fonts are in e.g. texlive-fontsextra) or set mainfont, sansfont, or monofont to an installed font (see fc-list)", font)
+		},
+	},
+	{
+		pattern: regexp.MustCompile(`Unicode character (\S+) \(U\+([0-9A-Fa-f]+)\)\s*(?:\(inputenc\)\s*)?not set up for use with LaTeX`),
+		hint: func(m []string) string {
+			return fmt.Sprintf("pdflatex cannot typeset %s (U+%s): set pdf-engine to xelatex or lualatex", m[1], m[2])
+		},
+	},
+	{
+		pattern: regexp.MustCompile(`LaTeX Error: Environment (\S+) undefined`),
+		hint: func(m []string) string {
+			return fmt.Sprintf("the LaTeX environment %s is not defined: add the package that provides it to header-includes, or check its spelling", m[1])
+		},
+	},
+	{
+		// LaTeX shows the source line after the error, e.g. "l.42 \mycommand"; the
+		// last command on it is the undefined one
+		pattern: regexp.MustCompile(`(?m)^! Undefined control sequence\.(?:[ \t]*\n(?:.*\n){0,3}?l\.\d+ .*?(\\[A-Za-z@]+)[ \t]*$)?`),
+		hint: func(m []string) string {
+			command := "a LaTeX command"
+			if m[1] != "" {
+				command = "the LaTeX command " + m[1]
+			}
+			return command + " is not defined: it may come from raw LaTeX in the document, header-includes, or a template, and may need a package"
+		},
+	},
+}
+
+// FailureHints explains well-known PDF engine errors in the output of a failed run: a
+// missing engine, package, or font, a character pdflatex cannot typeset, and undefined
+// commands or environments.
+//
+// Parameters:
+//   - `output`: what pandoc wrote to stderr
+//
+// Returns:
+//   - []string: one hint per problem found, in the order checked (nil if none)
+func FailureHints(output string) []string {
+	var hints []string
+	seen := map[string]bool{}
+	for _, h := range failureHints {
+		for _, m := range h.pattern.FindAllStringSubmatch(output, -1) {
+			hint := h.hint(m)
+			if !seen[hint] {
+				seen[hint] = true
+				hints = append(hints, hint)
+			}
+		}
+	}
+	return hints
+}
@@ -0,0 +1,33 @@
+package pandoc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFailureHints(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{"engine", "pdflatex not found. Please select a different --pdf-engine or install pdflatex", "PDF engine pdflatex is not installed"},
+		{"package", "Error producing PDF.\n! LaTeX Error: File `fontawesome5.sty' not found.\n", "tlmgr install fontawesome5"},
+		{"class", "! LaTeX Error: File `memoir.cls' not found.", "document class memoir"},
+		{"font", `! Package fontspec Error: The font "Inter" cannot be found.`, `font "Inter" is not installed`},
+		{"unicode", "! Package inputenc Error: Unicode character ✓ (U+2713)\n(inputenc)                not set up for use with LaTeX.", "xelatex or lualatex"},
+		{"undefined command", "! Undefined control sequence.\nl.42 Some text \\mycommand\n", `LaTeX command \mycommand is not defined`},
+		{"undefined without line", "! Undefined control sequence.\n", "a LaTeX command is not defined"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hints := FailureHints(tt.output)
+			if len(hints) != 1 || !strings.Contains(hints[0], tt.want) {
+				t.Errorf("FailureHints() = %q, want one hint containing %q", hints, tt.want)
+			}
+		})
+	}
+	if hints := FailureHints("[WARNING] Could not fetch resource a.png"); hints != nil {
+		t.Errorf("expected no hints, got %q", hints)
+	}
+}
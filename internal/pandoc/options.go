@@ -0,0 +1,151 @@
+package pandoc
+
+import (
+	"sort"
+	"strings"
+)
+
+// pandocOptions are the long options of pandoc 3.8 (`pandoc --help`), with the older
+// spellings it still accepts and the plural keys of defaults files, which configs
+// often borrow (`variables`, `filters`).
+var pandocOptions = map[string]bool{
+	"from": true, "read": true, "to": true, "write": true, "output": true, "data-dir": true,
+	"metadata": true, "metadata-file": true, "defaults": true, "file-scope": true,
+	"sandbox": true, "standalone": true, "template": true, "variable": true,
+	"variable-json": true, "wrap": true, "ascii": true, "toc": true,
+	"table-of-contents": true, "toc-depth": true, "lof": true, "list-of-figures": true,
+	"lot": true, "list-of-tables": true, "number-sections": true, "number-offset": true,
+	"top-level-division": true, "extract-media": true, "resource-path": true,
+	"include-in-header": true, "include-before-body": true, "include-after-body": true,
+	"no-highlight": true, "highlight-style": true, "syntax-definition": true,
+	"syntax-highlighting": true, "dpi": true, "eol": true, "columns": true,
+	"preserve-tabs": true, "tab-stop": true, "pdf-engine": true, "pdf-engine-opt": true,
+	"reference-doc": true, "self-contained": true, "embed-resources": true,
+	"link-images": true, "request-header": true, "no-check-certificate": true,
+	"abbreviations": true, "indented-code-classes": true, "default-image-extension": true,
+	"filter": true, "lua-filter": true, "shift-heading-level-by": true,
+	"base-header-level": true, "track-changes": true, "strip-comments": true,
+	"reference-links": true, "reference-location": true, "figure-caption-position": true,
+	"table-caption-position": true, "markdown-headings": true, "list-tables": true,
+	"listings": true, "incremental": true, "slide-level": true, "section-divs": true,
+	"html-q-tags": true, "email-obfuscation": true, "id-prefix": true,
+	"title-prefix": true, "css": true, "epub-subdirectory": true,
+	"epub-cover-image": true, "epub-title-page": true, "epub-metadata": true,
+	"epub-embed-font": true, "split-level": true, "epub-chapter-level": true,
+	"chunk-template": true, "ipynb-output": true, "citeproc": true, "bibliography": true,
+	"csl": true, "citation-abbreviations": true, "natbib": true, "biblatex": true,
+	"mathml": true, "webtex": true, "mathjax": true, "katex": true, "gladtex": true,
+	"trace": true, "dump-args": true, "ignore-args": true, "verbose": true, "quiet": true,
+	"fail-if-warnings": true, "log": true, "latex-engine": true, "latex-engine-opt": true,
+	"atx-headers": true, "print-default-template": true, "print-default-data-file": true,
+	"print-highlight-style": true,
+	// Defaults-file keys
+	"variables": true, "filters": true, "metadata-files": true, "html-math-method": true,
+	"cite-method": true, "epub-fonts": true, "request-headers": true,
+	"pdf-engine-opts": true, "verbosity": true, "log-file": true, "input-files": true,
+	"input-file": true, "reader": true, "writer": true,
+}
+
+// UnknownOption is an output-map key that is neither a panforge setting nor a pandoc
+// option, such as a misspelled `tocc`.
+type UnknownOption struct {
+	// Key as written in the config.
+	Key string
+	// Suggestion is the closest pandoc option, or "" if none is close.
+	Suggestion string
+}
+
+// String describes the key, with a suggestion when there is one.
+func (u UnknownOption) String() string {
+	if u.Suggestion == "" {
+		return "unknown pandoc option " + u.Key
+	}
+	return "unknown pandoc option " + u.Key + " (did you mean " + u.Suggestion + "?)"
+}
+
+// UnknownOptions lists the keys of a target's options that GetArgs would pass to pandoc
+// although pandoc has no such option. Like pandoc, an unambiguous prefix of an option
+// (`number-sec`) counts as that option.
+//
+// Parameters:
+//   - `meta`: the target's options
+//
+// Returns:
+//   - []UnknownOption: the unknown keys, sorted
+func UnknownOptions(meta map[string]interface{}) []UnknownOption {
+	var unknown []UnknownOption
+	for key := range meta {
+		if key == "t" || key == "pandoc-args" || key == "pandoc_args" || panforgeKeys[key] {
+			continue
+		}
+		name := strings.ReplaceAll(key, "_", "-")
+		if internalFlags["--"+name] || isPandocOption(name) {
+			continue
+		}
+		unknown = append(unknown, UnknownOption{Key: key, Suggestion: suggestOption(name)})
+	}
+	sort.Slice(unknown, func(i, j int) bool { return unknown[i].Key < unknown[j].Key })
+	return unknown
+}
+
+// isPandocOption reports whether name is a pandoc option or an unambiguous prefix of one.
+func isPandocOption(name string) bool {
+	if pandocOptions[name] {
+		return true
+	}
+	matches := 0
+	for option := range pandocOptions {
+		if strings.HasPrefix(option, name) {
+			matches++
+		}
+	}
+	return matches == 1
+}
+
+// suggestOption returns the pandoc option closest to a misspelled name, or "" if none is
+// within a third of its length in edits.
+func suggestOption(name string) string {
+	options := make([]string, 0, len(pandocOptions))
+	for option := range pandocOptions {
+		options = append(options, option)
+	}
+	return Closest(name, options)
+}
+
+// Closest returns the candidate closest to a misspelled name, or "" if none is within a
+// third of its length in edits. Ties go to the alphabetically first candidate.
+//
+// Parameters:
+//   - `name`: the misspelled name
+//   - `candidates`: the names it may stand for
+func Closest(name string, candidates []string) string {
+	best, bestDist := "", len(name)/3+1
+	for _, candidate := range candidates {
+		d := editDistance(name, candidate)
+		if d < bestDist || (d == bestDist && best != "" && candidate < best) {
+			best, bestDist = candidate, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between two strings.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
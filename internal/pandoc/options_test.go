@@ -0,0 +1,32 @@
+package pandoc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnknownOptions(t *testing.T) {
+	meta := map[string]interface{}{
+		"toc":             true,
+		"tocc":            true,
+		"pdf_engine":      "xelatex",
+		"number-sec":      true,
+		"variables":       map[string]interface{}{"fontsize": "11pt"},
+		"stanalone":       true,
+		"overwrite":       true,
+		"pandoc_args":     []interface{}{"--foo"},
+		"to":              "html5",
+		"completely-made": "x",
+	}
+	want := []UnknownOption{
+		{Key: "completely-made"},
+		{Key: "stanalone", Suggestion: "standalone"},
+		{Key: "tocc", Suggestion: "toc"},
+	}
+	if got := UnknownOptions(meta); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := (UnknownOption{Key: "tocc", Suggestion: "toc"}).String(); got != "unknown pandoc option tocc (did you mean toc?)" {
+		t.Errorf("unexpected message %q", got)
+	}
+}
@@ -6,8 +6,11 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"reflect"
@@ -19,6 +22,49 @@ import (
 
 var internalFlags map[string]bool
 
+// panforgeKeys are output-map keys consumed by panforge itself and never passed to pandoc.
+var panforgeKeys = map[string]bool{
+	"overwrite":           true,
+	"slugify-filename":    true,
+	"changes":             true,
+	"titlepage":           true,
+	"sandbox":             true,
+	"from-options":        true,
+	"chapters":            true,
+	"split-chapters":      true,
+	"postprocess":         true,
+	"mermaid":             true,
+	"plantuml":            true,
+	"media":               true,
+	"assets":              true,
+	"compress-pdf":        true,
+	"naming-strategy":     true,
+	"pdf-protect":         true,
+	"archive":             true,
+	"manifest":            true,
+	"matrix":              true,
+	"backup":              true,
+	"on-conflict":         true,
+	"subprocess-output":   true,
+	"timeout":             true,
+	"minify-html":         true,
+	"inline-css":          true,
+	"typst-compile":       true,
+	"latex-passes":        true,
+	"reproducible":        true,
+	"filename-timestamps": true,
+	"date-format":         true,
+	"time-format":         true,
+	"output-collision":    true,
+	"slug":                true,
+	"sanitize":            true,
+	"max-path-length":     true,
+	"working-dir":         true,
+	"git-metadata":        true,
+	"include":             true,
+	"extends":             true,
+}
+
 func init() {
 	internalFlags = make(map[string]bool)
 	val := options.Options{}
@@ -86,13 +132,87 @@ func ExtForFormat(fmtStr string) string {
 	}
 }
 
+// InputFormatForExt returns the pandoc reader format for a given file extension.
+//
+// Parameters:
+//   - `ext`: the file extension, with or without the leading dot (e.g. ".docx")
+//
+// Returns:
+//   - string: the pandoc reader name, or "" if the extension is not recognized
+func InputFormatForExt(ext string) string {
+	switch strings.ToLower(strings.TrimPrefix(ext, ".")) {
+	case "docx":
+		return "docx"
+	case "odt":
+		return "odt"
+	case "rtf":
+		return "rtf"
+	case "epub":
+		return "epub"
+	case "html", "htm":
+		return "html"
+	default:
+		return ""
+	}
+}
+
+// SourceFormatForExt returns the pandoc reader for any supported source file extension,
+// including the plain-text formats that InputFormatForExt leaves out.
+//
+// Parameters:
+//   - `ext`: the file extension, with or without the leading dot (e.g. ".org")
+//
+// Returns:
+//   - string: the pandoc reader name, or "" if the extension is not recognized
+func SourceFormatForExt(ext string) string {
+	switch strings.ToLower(strings.TrimPrefix(ext, ".")) {
+	case "md", "markdown", "mkd", "mdown":
+		return "markdown"
+	case "org":
+		return "org"
+	case "rst":
+		return "rst"
+	case "tex", "latex":
+		return "latex"
+	case "textile":
+		return "textile"
+	case "ipynb":
+		return "ipynb"
+	case "typ":
+		return "typst"
+	default:
+		return InputFormatForExt(ext)
+	}
+}
+
+// binary is the pandoc executable panforge runs.
+var binary = "pandoc"
+
+// SetBinary selects the pandoc executable panforge runs, for the rest of the process.
+// It is called before pandoc is first queried.
+//
+// Parameters:
+//   - `path`: a path to the executable or a name looked up on the PATH ("" for "pandoc")
+func SetBinary(path string) {
+	if path == "" {
+		path = "pandoc"
+	}
+	binary = path
+	installedOnce = sync.Once{}
+}
+
+// Binary returns the pandoc executable panforge runs (see SetBinary).
+func Binary() string {
+	return binary
+}
+
 // GetSupportedFormats queries pandoc for supported formats.
 //
 // Returns:
 //   - []string: a slice of supported format names
 //   - error: any error encountered (e.g. pandoc not found)
 func GetSupportedFormats() ([]string, error) {
-	cmd := exec.Command("pandoc", "--list-output-formats")
+	cmd := exec.Command(binary, "--list-output-formats")
 	out, err := cmd.Output()
 	if err == nil && len(out) > 0 {
 		lines := strings.Split(string(out), "\n")
@@ -107,6 +227,62 @@ func GetSupportedFormats() ([]string, error) {
 	return []string{}, nil // Fallback or empty if not found
 }
 
+// BuiltinOutputFormats is the output format list of pandoc 3.x, used for planning
+// (dry runs, shell completion) when pandoc itself is not installed.
+var BuiltinOutputFormats = []string{
+	"ansi", "asciidoc", "asciidoc_legacy", "asciidoctor", "beamer", "bibtex", "biblatex",
+	"chunkedhtml", "commonmark", "commonmark_x", "context", "csljson", "djot", "docbook",
+	"docbook4", "docbook5", "docx", "dokuwiki", "dzslides", "epub", "epub2", "epub3", "fb2",
+	"gfm", "haddock", "html", "html4", "html5", "icml", "ipynb", "jats", "jats_archiving",
+	"jats_articleauthoring", "jats_publishing", "jira", "json", "latex", "man", "markdown",
+	"markdown_github", "markdown_mmd", "markdown_phpextra", "markdown_strict", "markua",
+	"mediawiki", "ms", "muse", "native", "odt", "opendocument", "opml", "org", "pdf", "plain",
+	"pptx", "revealjs", "rst", "rtf", "s5", "slideous", "slidy", "tei", "texinfo", "textile",
+	"typst", "xwiki", "zimwiki",
+}
+
+// OutputFormats returns pandoc's output formats, falling back to BuiltinOutputFormats
+// when pandoc cannot be run.
+//
+// Returns:
+//   - []string: the output format names
+//   - bool: whether the list came from an installed pandoc
+func OutputFormats() ([]string, bool) {
+	if formats, err := GetSupportedFormats(); err == nil && len(formats) > 0 {
+		return formats, true
+	}
+	return BuiltinOutputFormats, false
+}
+
+// IsOutputFormat reports whether a target name is a pandoc output format, with or
+// without extensions (`html+smart`), looking at the installed pandoc only when the
+// format is not one of BuiltinOutputFormats.
+//
+// Parameters:
+//   - `target`: the target name
+func IsOutputFormat(target string) bool {
+	format := NormalizeFormat(target)
+	if slices.Contains(BuiltinOutputFormats, format) {
+		return true
+	}
+	formats, _ := GetSupportedFormats()
+	return slices.Contains(formats, format)
+}
+
+// GetVersion returns the first line of `pandoc --version` (e.g. "pandoc 3.1.11").
+//
+// Returns:
+//   - string: the version line
+//   - error: any error running pandoc
+func GetVersion() (string, error) {
+	out, err := exec.Command(binary, "--version").Output()
+	if err != nil {
+		return "", err
+	}
+	line, _, _ := strings.Cut(string(out), "\n")
+	return strings.TrimSpace(line), nil
+}
+
 // GenerateOutputFilename logic determines the output filename based on configuration.
 //
 // Parameters:
@@ -116,11 +292,44 @@ func GetSupportedFormats() ([]string, error) {
 //   - `pandocFmt`: target pandoc format
 //
 // Returns:
-//   - string: the generated filename
+//   - string: the generated filename, or "" if `filename-template` is invalid
 func GenerateOutputFilename(inputFile string, cfg *config.Config, metaOut map[string]interface{}, pandocFmt string) string {
+	name, err := GenerateOutputFilenameWith(inputFile, cfg, metaOut, pandocFmt, FilenameVars{Now: time.Now()})
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
+// FilenameVars are the values of the filename variables that do not come from the
+// document.
+type FilenameVars struct {
+	// Now is the time of {date} and {time}, e.g. a fixed time for reproducible builds.
+	Now time.Time
+	// Counter returns the value of {counter}; it is only called if the template uses it.
+	Counter func() (int, error)
+	// Git describes the input's git repository for {version}, {commit}, and {branch};
+	// it is only called if the template uses them.
+	Git func() utils.GitInfo
+}
+
+// GenerateOutputFilenameWith determines the output filename like GenerateOutputFilename,
+// with the date, time, counter, and git description taken from vars.
+//
+// Parameters:
+//   - `inputFile`: path to the input file
+//   - `cfg`: global configuration
+//   - `metaOut`: format-specific configuration from YAML
+//   - `pandocFmt`: target pandoc format
+//   - `vars`: the date, time, counter, and git description
+//
+// Returns:
+//   - string: the generated filename
+//   - error: if `filename-template` is invalid or {counter} is not available
+func GenerateOutputFilenameWith(inputFile string, cfg *config.Config, metaOut map[string]interface{}, pandocFmt string, vars FilenameVars) (string, error) {
 	if val, ok := metaOut["output"]; ok {
 		if s, ok := val.(string); ok && s != "" {
-			return s
+			return s, nil
 		}
 	}
 
@@ -138,7 +347,7 @@ func GenerateOutputFilename(inputFile string, cfg *config.Config, metaOut map[st
 		}
 	}
 	if title == "" {
-		title = strings.TrimSuffix(filepath.Base(inputFile), filepath.Ext(inputFile))
+		title = inputStem(inputFile)
 	}
 
 	// Template
@@ -146,25 +355,43 @@ func GenerateOutputFilename(inputFile string, cfg *config.Config, metaOut map[st
 	if tmpl == "" {
 		// Default
 		tmpl = "{title}_{date}.{ext}"
+		if !filenameTimestamps(cfg, metaOut) {
+			tmpl = "{title}.{ext}"
+		}
 	}
 
-	// Variables
-	dateStr := utils.FormatDate()
-	timeStr := time.Now().Format("15-04-05")
-	ext := ExtForFormat(pandocFmt)
-	author := cfg.Author
+	slug, err := slugOptions(cfg, metaOut)
+	if err != nil {
+		return "", err
+	}
 
 	// Substitution
-	result := strings.ReplaceAll(tmpl, "{date}", dateStr)
-	result = strings.ReplaceAll(result, "{time}", timeStr)
-	result = strings.ReplaceAll(result, "{title}", title)
-	result = strings.ReplaceAll(result, "{author}", author)
-	result = strings.ReplaceAll(result, "{title-slug}", utils.Slugify(title))
-	result = strings.ReplaceAll(result, "{author-slug}", utils.Slugify(author))
-	result = strings.ReplaceAll(result, "{ext}", ext)
+	result, err := ExpandFilenameTemplate(tmpl, FilenameData{
+		Title:      title,
+		TitleSlug:  utils.SlugifyWith(title, slug),
+		Author:     cfg.Author,
+		AuthorSlug: utils.SlugifyWith(cfg.Author, slug),
+		Date:       utils.FormatTime(vars.Now, formatSetting(cfg, metaOut, "date-format", utils.DefaultDateFormat)),
+		Time:       utils.FormatTime(vars.Now, formatSetting(cfg, metaOut, "time-format", utils.DefaultTimeFormat)),
+		Ext:        ExtForFormat(pandocFmt),
+		Format:     pandocFmt,
+		Input:      inputStem(inputFile),
+		Now:        vars.Now,
+		Meta:       cfg.Generic,
+		Counter:    vars.Counter,
+		Git:        vars.Git,
+		Slug:       slug,
+	})
+	if err != nil {
+		return "", err
+	}
 
 	// Ensure sanitized
-	result = utils.SanitizeFilename(result)
+	sanitizeRules, err := sanitizeOptions(cfg, metaOut)
+	if err != nil {
+		return "", err
+	}
+	result = utils.SanitizeFilenameWith(result, sanitizeRules)
 
 	// Slugify Filename?
 	shouldSlugify := false
@@ -179,10 +406,60 @@ func GenerateOutputFilename(inputFile string, cfg *config.Config, metaOut map[st
 	if shouldSlugify {
 		ext := filepath.Ext(result)
 		base := strings.TrimSuffix(result, ext)
-		result = utils.Slugify(base) + ext
+		result = utils.SlugifyWith(base, slug) + ext
+	}
+
+	return result, nil
+}
+
+// formatSetting returns the layout of a filename date or time variable: the target's
+// value, else the global one, else def.
+//
+// Parameters:
+//   - `cfg`: global configuration
+//   - `metaOut`: format-specific configuration from YAML
+//   - `key`: `date-format` or `time-format`
+//   - `def`: the default layout
+func formatSetting(cfg *config.Config, metaOut map[string]interface{}, key, def string) string {
+	for _, v := range []interface{}{metaOut[key], cfg.Generic[key]} {
+		switch v := v.(type) {
+		case string:
+			if v != "" {
+				return v
+			}
+		case int:
+			// An unquoted layout such as 20060102 is read as a number
+			return strconv.Itoa(v)
+		}
 	}
+	return def
+}
 
-	return result
+// filenameTimestamps reports whether the default filename template includes the date.
+// `filename-timestamps` decides, the target value winning over the global one; without
+// it, documents that configure their targets in an `output` map get stable names, so
+// rebuilds overwrite their outputs and the build cache recognizes them.
+//
+// Parameters:
+//   - `cfg`: global configuration
+//   - `metaOut`: format-specific configuration from YAML
+func filenameTimestamps(cfg *config.Config, metaOut map[string]interface{}) bool {
+	if v, ok := metaOut["filename-timestamps"].(bool); ok {
+		return v
+	}
+	if v, ok := cfg.Generic["filename-timestamps"].(bool); ok {
+		return v
+	}
+	return len(cfg.OutputMap) == 0
+}
+
+// IsPanforgeKey reports whether a config key is a panforge setting rather than a pandoc
+// option or metadata.
+//
+// Parameters:
+//   - `key`: the config key
+func IsPanforgeKey(key string) bool {
+	return panforgeKeys[key]
 }
 
 // GetArgs converts a metadata map to pandoc arguments.
@@ -195,15 +472,17 @@ func GenerateOutputFilename(inputFile string, cfg *config.Config, metaOut map[st
 func GetArgs(meta map[string]interface{}) []string {
 	var args []string
 
-	// Check if `pandoc_args` exists and handle it separately
+	// `pandoc-args` (or the deprecated `pandoc_args`) are passed as written, after the rest
 	var pandocArgs []string
-	if val, ok := meta["pandoc_args"]; ok {
-		if list, ok := val.([]interface{}); ok {
-			for _, item := range list {
-				pandocArgs = append(pandocArgs, fmt.Sprintf("%v", item))
+	for _, key := range []string{"pandoc-args", "pandoc_args"} {
+		if val, ok := meta[key]; ok {
+			if list, ok := val.([]interface{}); ok {
+				for _, item := range list {
+					pandocArgs = append(pandocArgs, fmt.Sprintf("%v", item))
+				}
 			}
+			delete(meta, key)
 		}
-		delete(meta, "pandoc_args")
 	}
 
 	// Sort keys for deterministic output
@@ -215,7 +494,7 @@ func GetArgs(meta map[string]interface{}) []string {
 
 	for _, key := range keys {
 		val := meta[key]
-		if key == "to" || key == "t" || key == "output" || key == "from" {
+		if key == "to" || key == "t" || key == "output" || key == "from" || panforgeKeys[key] {
 			continue
 		}
 
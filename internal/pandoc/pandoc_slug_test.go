@@ -3,6 +3,7 @@ package pandoc_test
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/rapjul/panforge/internal/config"
 	"github.com/rapjul/panforge/internal/pandoc"
@@ -92,6 +93,69 @@ func TestGenerateOutputFilename(t *testing.T) {
 			fmt:      "html",
 			expected: "", // "Part 1_2" (slash replaced)
 		},
+		{
+			name: "Slug Options",
+			cfg: &config.Config{
+				Title:            "My Long Report Title",
+				FilenameTemplate: "{title-slug}.{ext}",
+				Generic:          map[string]interface{}{"slug": map[string]interface{}{"separator": "_", "max-length": 14, "lowercase": false}},
+			},
+			meta:     map[string]interface{}{},
+			fmt:      "pdf",
+			expected: "My_Long_Report.pdf",
+		},
+		{
+			name: "Sanitize Rules",
+			cfg: &config.Config{
+				Title:            "Q&A Launch",
+				FilenameTemplate: "{title}.{ext}",
+				Generic:          map[string]interface{}{"sanitize": map[string]interface{}{"replace": map[string]interface{}{" ": "_", "&": nil}}},
+			},
+			meta:     map[string]interface{}{},
+			fmt:      "pdf",
+			expected: "QA_Launch.pdf",
+		},
+		{
+			name: "Metadata Variable",
+			cfg: &config.Config{
+				Title:            "My Title",
+				FilenameTemplate: "{meta.client}-{title-slug}.{ext}",
+				Generic:          map[string]interface{}{"client": "ACME"},
+			},
+			meta:     map[string]interface{}{},
+			fmt:      "pdf",
+			expected: "ACME-my-title.pdf",
+		},
+		{
+			name: "Timestamps Off",
+			cfg: &config.Config{
+				Title:   "My Title",
+				Generic: map[string]interface{}{"filename-timestamps": false},
+			},
+			meta:     map[string]interface{}{},
+			fmt:      "html",
+			expected: "My Title.html",
+		},
+		{
+			name: "Output Map Without Timestamps",
+			cfg: &config.Config{
+				Title:     "My Title",
+				OutputMap: map[string]interface{}{"html": map[string]interface{}{}},
+			},
+			meta:     map[string]interface{}{},
+			fmt:      "html",
+			expected: "My Title.html",
+		},
+		{
+			name: "Output Map With Timestamps On",
+			cfg: &config.Config{
+				Title:     "My Title",
+				OutputMap: map[string]interface{}{"html": map[string]interface{}{}},
+			},
+			meta:     map[string]interface{}{"filename-timestamps": true},
+			fmt:      "html",
+			expected: "My Title_" + dateStr + ".html",
+		},
 		{
 			name: "Meta Override Slugify",
 			cfg: &config.Config{
@@ -151,3 +215,27 @@ func TestGenerateOutputFilename_Time(t *testing.T) {
 		t.Errorf("expected time format with dashes, got %q", base)
 	}
 }
+
+func TestGenerateOutputFilenameWith(t *testing.T) {
+	cfg := &config.Config{Title: "Pinned", FilenameTemplate: "{title}_{date}_{time}.{ext}"}
+	now := time.Date(2024, 3, 9, 8, 5, 1, 0, time.UTC)
+	got, err := pandoc.GenerateOutputFilenameWith("input.md", cfg, map[string]interface{}{}, "html", pandoc.FilenameVars{Now: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Pinned_2024-03-09_08-05-01.html" {
+		t.Errorf("GenerateOutputFilenameWith() = %q", got)
+	}
+}
+
+func TestGenerateOutputFilenameWith_Formats(t *testing.T) {
+	now := time.Date(2024, 1, 31, 8, 5, 1, 0, time.UTC)
+	cfg := &config.Config{Title: "Doc", FilenameTemplate: "{title}_{date}_{time}.{ext}", Generic: map[string]interface{}{"date-format": 20060102}}
+	got, err := pandoc.GenerateOutputFilenameWith("input.md", cfg, map[string]interface{}{"time-format": "%Hh%M"}, "html", pandoc.FilenameVars{Now: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Doc_20240131_08h05.html" {
+		t.Errorf("GenerateOutputFilenameWith() = %q", got)
+	}
+}
@@ -147,6 +147,11 @@ func TestGetArgs(t *testing.T) {
 			map[string]interface{}{"toc_depth": 2},
 			[]string{"--toc-depth", "2"},
 		},
+		{
+			"pandoc-args and the deprecated pandoc_args",
+			map[string]interface{}{"pandoc-args": []interface{}{"--toc"}, "pandoc_args": []interface{}{"--number-sections"}},
+			[]string{"--toc", "--number-sections"},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -168,3 +173,14 @@ func TestGetArgs(t *testing.T) {
 		})
 	}
 }
+
+func TestOutputFormats_Fallback(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	formats, installed := OutputFormats()
+	if installed {
+		t.Fatal("expected pandoc to be reported missing")
+	}
+	if len(formats) == 0 || formats[0] != BuiltinOutputFormats[0] {
+		t.Errorf("expected the built-in format list, got %v", formats)
+	}
+}
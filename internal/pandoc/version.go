@@ -0,0 +1,236 @@
+package pandoc
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Version is a pandoc release, e.g. 3.1.11.
+type Version struct {
+	Major, Minor, Patch int
+}
+
+// String formats the version as "major.minor.patch".
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// AtLeast reports whether v is o or a later release.
+//
+// Parameters:
+//   - `o`: the release to compare with
+func (v Version) AtLeast(o Version) bool {
+	if v.Major != o.Major {
+		return v.Major > o.Major
+	}
+	if v.Minor != o.Minor {
+		return v.Minor > o.Minor
+	}
+	return v.Patch >= o.Patch
+}
+
+// versionPattern finds the release in the first line of `pandoc --version`.
+var versionPattern = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// ParseVersion reads the release from the first line of `pandoc --version`, e.g.
+// "pandoc 3.1.11" or "pandoc.exe 2.19.2".
+//
+// Parameters:
+//   - `line`: the version line
+//
+// Returns:
+//   - Version: the release
+//   - bool: whether the line contains a version
+func ParseVersion(line string) (Version, bool) {
+	m := versionPattern.FindStringSubmatch(line)
+	if m == nil {
+		return Version{}, false
+	}
+	var v Version
+	v.Major, _ = strconv.Atoi(m[1])
+	v.Minor, _ = strconv.Atoi(m[2])
+	if m[3] != "" {
+		v.Patch, _ = strconv.Atoi(m[3])
+	}
+	return v, true
+}
+
+var (
+	installedOnce    sync.Once
+	installedLine    string
+	installedVersion Version
+	installedKnown   bool
+)
+
+// InstalledVersion runs `pandoc --version` once per process and returns the result of
+// that first run afterwards.
+//
+// Returns:
+//   - string: the version line (e.g. "pandoc 3.1.11"), "" if pandoc could not be run
+//   - Version: the parsed release
+//   - bool: whether the release is known
+func InstalledVersion() (string, Version, bool) {
+	installedOnce.Do(func() {
+		line, err := GetVersion()
+		if err != nil {
+			return
+		}
+		installedLine = line
+		installedVersion, installedKnown = ParseVersion(line)
+	})
+	return installedLine, installedVersion, installedKnown
+}
+
+// optionsSince lists pandoc options added in pandoc 2 or later, with the release that
+// introduced them. Options that were renamed are in flagSpellings instead.
+var optionsSince = map[string]Version{
+	"--metadata-file":           {2, 3, 0},
+	"--ipynb-output":            {2, 6, 0},
+	"--defaults":                {2, 8, 0},
+	"--citeproc":                {2, 11, 0},
+	"--sandbox":                 {2, 15, 0},
+	"--chunk-template":          {3, 0, 0},
+	"--figure-caption-position": {3, 5, 0},
+	"--table-caption-position":  {3, 5, 0},
+}
+
+// flagSpelling is a pandoc option that was renamed: pandoc before since only knows the
+// old spelling, and later releases deprecate it.
+type flagSpelling struct {
+	old, current string
+	since        Version
+	hasValue     bool
+	// toCurrent and toOld spell one use of the option the other way, or return nil if it
+	// has no equivalent.
+	toCurrent, toOld func(value string) []string
+}
+
+// respell spells an option as flag, keeping its value.
+func respell(flag string, hasValue bool) func(string) []string {
+	return func(value string) []string {
+		if !hasValue {
+			return []string{flag}
+		}
+		return []string{flag, value}
+	}
+}
+
+// flagSpellings are checked in order; the first match wins.
+var flagSpellings = []flagSpelling{
+	{old: "--latex-engine", current: "--pdf-engine", since: Version{2, 0, 0}, hasValue: true,
+		toCurrent: respell("--pdf-engine", true), toOld: respell("--latex-engine", true)},
+	{old: "--latex-engine-opt", current: "--pdf-engine-opt", since: Version{2, 0, 0}, hasValue: true,
+		toCurrent: respell("--pdf-engine-opt", true), toOld: respell("--latex-engine-opt", true)},
+	{old: "--base-header-level", current: "--shift-heading-level-by", since: Version{2, 8, 0}, hasValue: true,
+		// A base level of 1 leaves headings alone, a shift of 0 does
+		toCurrent: func(value string) []string {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil
+			}
+			return []string{"--shift-heading-level-by", strconv.Itoa(n - 1)}
+		},
+		toOld: func(value string) []string {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil
+			}
+			return []string{"--base-header-level", strconv.Itoa(n + 1)}
+		}},
+	{old: "--self-contained", current: "--embed-resources", since: Version{2, 19, 0},
+		toCurrent: func(string) []string { return []string{"--embed-resources", "--standalone"} },
+		toOld:     respell("--self-contained", false)},
+	{old: "--epub-chapter-level", current: "--split-level", since: Version{3, 0, 0}, hasValue: true,
+		toCurrent: respell("--split-level", true), toOld: respell("--epub-chapter-level", true)},
+	{old: "--highlight-style", current: "--syntax-highlighting", since: Version{3, 8, 0}, hasValue: true,
+		toCurrent: respell("--syntax-highlighting", true),
+		toOld: func(value string) []string {
+			switch value {
+			case "none":
+				return []string{"--no-highlight"}
+			case "default":
+				return []string{}
+			case "idiomatic":
+				return nil
+			}
+			return []string{"--highlight-style", value}
+		}},
+	{old: "--no-highlight", current: "--syntax-highlighting", since: Version{3, 8, 0},
+		toCurrent: func(string) []string { return []string{"--syntax-highlighting", "none"} }},
+}
+
+// AdaptArgs fits pandoc arguments to the installed release: renamed options are spelled
+// the way it expects (e.g. --latex-engine becomes --pdf-engine from pandoc 2.0, and
+// --pdf-engine becomes --latex-engine before it), and options it is too old to know
+// are reported.
+//
+// Parameters:
+//   - `args`: the pandoc arguments
+//   - `v`: the installed release
+//
+// Returns:
+//   - []string: the arguments for that release
+//   - []string: warnings about options it does not support
+func AdaptArgs(args []string, v Version) ([]string, []string) {
+	var out, warnings []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, inline := strings.Cut(arg, "=")
+		if !strings.HasPrefix(name, "--") {
+			out = append(out, arg)
+			continue
+		}
+		if since, ok := optionsSince[name]; ok && !v.AtLeast(since) {
+			warnings = append(warnings, fmt.Sprintf("%s needs pandoc %s or later (installed: %s)", name, since, v))
+		}
+		rule, current := matchSpelling(name, v)
+		if rule == nil {
+			out = append(out, arg)
+			continue
+		}
+		if rule.hasValue && !inline {
+			if i+1 >= len(args) {
+				out = append(out, arg)
+				continue
+			}
+			i++
+			value = args[i]
+		}
+		convert := rule.toOld
+		if current {
+			convert = rule.toCurrent
+		}
+		spelled := convert(value)
+		if spelled == nil {
+			warnings = append(warnings, fmt.Sprintf("%s %s has no equivalent before pandoc %s (installed: %s); ignoring it", name, value, rule.since, v))
+			continue
+		}
+		out = append(out, spelled...)
+	}
+	return out, warnings
+}
+
+// matchSpelling finds the rule that respells an option for release v.
+//
+// Parameters:
+//   - `name`: the option, e.g. "--latex-engine"
+//   - `v`: the installed release
+//
+// Returns:
+//   - *flagSpelling: the rule, or nil if the option is spelled right for v
+//   - bool: whether the option must take its current spelling (otherwise its old one)
+func matchSpelling(name string, v Version) (*flagSpelling, bool) {
+	for i := range flagSpellings {
+		rule := &flagSpellings[i]
+		if v.AtLeast(rule.since) && name == rule.old {
+			return rule, true
+		}
+		if !v.AtLeast(rule.since) && name == rule.current && rule.toOld != nil {
+			return rule, false
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,67 @@
+package pandoc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		line string
+		want Version
+		ok   bool
+	}{
+		{"pandoc 3.1.11", Version{3, 1, 11}, true},
+		{"pandoc.exe 2.19.2", Version{2, 19, 2}, true},
+		{"pandoc 3.5", Version{3, 5, 0}, true},
+		{"pandoc", Version{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseVersion(tt.line)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ParseVersion(%q) = %v, %v, want %v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+	if !(Version{3, 1, 11}).AtLeast(Version{2, 19, 0}) || (Version{2, 19, 2}).AtLeast(Version{3, 0, 0}) || !(Version{3, 0, 0}).AtLeast(Version{3, 0, 0}) {
+		t.Error("unexpected AtLeast ordering")
+	}
+}
+
+func TestAdaptArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		version  Version
+		want     []string
+		warnings int
+	}{
+		{"current spelling", []string{"doc.md", "--latex-engine", "xelatex", "--self-contained"}, Version{3, 1, 11},
+			[]string{"doc.md", "--pdf-engine", "xelatex", "--embed-resources", "--standalone"}, 0},
+		{"old spelling", []string{"--pdf-engine=xelatex", "--split-level", "2", "--shift-heading-level-by", "1"}, Version{1, 19, 2},
+			[]string{"--latex-engine", "xelatex", "--epub-chapter-level", "2", "--base-header-level", "2"}, 0},
+		{"base header level", []string{"--base-header-level", "1"}, Version{3, 0, 0},
+			[]string{"--shift-heading-level-by", "0"}, 0},
+		{"highlighting", []string{"--no-highlight", "--highlight-style", "tango"}, Version{3, 8, 0},
+			[]string{"--syntax-highlighting", "none", "--syntax-highlighting", "tango"}, 0},
+		{"no equivalent", []string{"--syntax-highlighting", "idiomatic", "--toc"}, Version{3, 1, 11},
+			[]string{"--toc"}, 1},
+		{"too old", []string{"--citeproc", "--toc"}, Version{2, 9, 2},
+			[]string{"--citeproc", "--toc"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, warnings := AdaptArgs(tt.args, tt.version)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AdaptArgs() = %v, want %v", got, tt.want)
+			}
+			if len(warnings) != tt.warnings {
+				t.Errorf("expected %d warnings, got %q", tt.warnings, warnings)
+			}
+		})
+	}
+	_, warnings := AdaptArgs([]string{"--citeproc"}, Version{2, 9, 2})
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "--citeproc needs pandoc 2.11.0 or later (installed: 2.9.2)") {
+		t.Errorf("unexpected warning %q", warnings)
+	}
+}
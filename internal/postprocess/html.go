@@ -0,0 +1,183 @@
+// Package postprocess implements built-in transformations applied to outputs after conversion.
+package postprocess
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	// rawBlock matches elements whose content must be kept byte for byte.
+	rawBlock = regexp.MustCompile(`(?is)<(pre|textarea|script)\b.*?</(?:pre|textarea|script)>`)
+	// styleBlock matches inline stylesheets, which are minified as CSS.
+	styleBlock = regexp.MustCompile(`(?is)(<style\b[^>]*>)(.*?)(</style>)`)
+	// htmlComment matches comments, except conditional comments for old browsers and mail clients.
+	htmlComment = regexp.MustCompile(`(?s)<!--[^\[].*?-->`)
+	// interTagSpace matches whitespace between two tags.
+	interTagSpace = regexp.MustCompile(`>\s+<`)
+	// spaceRun matches runs of whitespace.
+	spaceRun = regexp.MustCompile(`\s+`)
+	// cssComment matches CSS comments.
+	cssComment = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	// cssPunctuation matches whitespace around CSS punctuation.
+	cssPunctuation = regexp.MustCompile(`\s*([{}:;,>])\s*`)
+	// stylesheetLink matches <link> elements that reference a stylesheet.
+	stylesheetLink = regexp.MustCompile(`(?is)<link\b[^>]*>`)
+	// linkAttr matches an attribute of a <link> element.
+	linkAttr = regexp.MustCompile(`(?is)\b(rel|href|media)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+)
+
+// MinifyHTML removes comments and collapses whitespace in an HTML document. The content
+// of <pre>, <textarea>, and <script> elements is kept as is, and <style> elements are
+// minified as CSS. Whitespace between inline elements is reduced to one space rather
+// than removed, so rendered text does not change.
+//
+// Parameters:
+//   - `doc`: the HTML document
+//
+// Returns:
+//   - string: the minified document
+func MinifyHTML(doc string) string {
+	// Set raw blocks aside so the rules below cannot touch them. The placeholders look
+	// like the element's opening tag, so whitespace around them is handled the same way.
+	var raw []string
+	placeholder := func(block string) string {
+		raw = append(raw, block)
+		return fmt.Sprintf("<%s \x00%d\x00>", tagName(block), len(raw)-1)
+	}
+	doc = rawBlock.ReplaceAllStringFunc(doc, placeholder)
+	doc = styleBlock.ReplaceAllStringFunc(doc, func(m string) string {
+		parts := styleBlock.FindStringSubmatch(m)
+		return placeholder(parts[1] + MinifyCSS(parts[2]) + parts[3])
+	})
+
+	doc = htmlComment.ReplaceAllString(doc, "")
+	doc = collapseInterTagSpace(doc)
+	doc = strings.TrimSpace(spaceRun.ReplaceAllString(doc, " "))
+
+	for i, block := range raw {
+		doc = strings.Replace(doc, fmt.Sprintf("<%s \x00%d\x00>", tagName(block), i), block, 1)
+	}
+	return doc
+}
+
+// blockTags are elements around which whitespace never renders.
+var blockTags = map[string]bool{
+	"html": true, "head": true, "body": true, "title": true, "meta": true, "link": true, "style": true, "script": true,
+	"header": true, "footer": true, "main": true, "nav": true, "section": true, "article": true, "aside": true,
+	"div": true, "p": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "li": true, "dl": true, "dt": true, "dd": true, "blockquote": true, "pre": true,
+	"table": true, "thead": true, "tbody": true, "tfoot": true, "tr": true, "th": true, "td": true, "caption": true,
+	"figure": true, "figcaption": true, "hr": true, "br": true, "!doctype": true,
+}
+
+// tagName returns the lower-case element name of a tag such as "<p class=x>" or "</p>".
+func tagName(tag string) string {
+	name := strings.TrimLeft(tag, "</")
+	if i := strings.IndexAny(name, " \t\r\n/>"); i >= 0 {
+		name = name[:i]
+	}
+	return strings.ToLower(name)
+}
+
+// collapseInterTagSpace removes whitespace between tags when either side is a block
+// element, and reduces it to one space between inline elements, where it is visible.
+func collapseInterTagSpace(doc string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range interTagSpace.FindAllStringIndex(doc, -1) {
+		before := doc[strings.LastIndex(doc[:m[0]+1], "<"):m[0]]
+		after := doc[m[1]-1:]
+		if end := strings.IndexByte(after, '>'); end >= 0 {
+			after = after[:end]
+		}
+		b.WriteString(doc[last:m[0]])
+		if blockTags[tagName(before)] || blockTags[tagName(after)] {
+			b.WriteString("><")
+		} else {
+			b.WriteString("> <")
+		}
+		last = m[1]
+	}
+	b.WriteString(doc[last:])
+	return b.String()
+}
+
+// MinifyCSS removes comments and unneeded whitespace from a stylesheet.
+//
+// Parameters:
+//   - `css`: the stylesheet
+func MinifyCSS(css string) string {
+	css = cssComment.ReplaceAllString(css, "")
+	css = spaceRun.ReplaceAllString(css, " ")
+	css = cssPunctuation.ReplaceAllString(css, "$1")
+	css = strings.ReplaceAll(css, ";}", "}")
+	return strings.TrimSpace(css)
+}
+
+// InlineCSS replaces <link rel="stylesheet"> elements that point at local files with
+// <style> elements holding the stylesheets, so the document can be sent as a single file.
+// Remote stylesheets are left alone.
+//
+// Parameters:
+//   - `doc`: the HTML document
+//   - `dirs`: directories to resolve relative stylesheet paths against, in order
+//
+// Returns:
+//   - string: the document with local stylesheets inlined
+//   - []string: stylesheets that could not be found
+func InlineCSS(doc string, dirs ...string) (string, []string) {
+	var missing []string
+	doc = stylesheetLink.ReplaceAllStringFunc(doc, func(tag string) string {
+		attrs := make(map[string]string)
+		for _, a := range linkAttr.FindAllStringSubmatch(tag, -1) {
+			attrs[strings.ToLower(a[1])] = html.UnescapeString(a[2] + a[3] + a[4])
+		}
+		href := attrs["href"]
+		if !strings.EqualFold(attrs["rel"], "stylesheet") || href == "" || isRemote(href) {
+			return tag
+		}
+		css, ok := readStylesheet(href, dirs)
+		if !ok {
+			missing = append(missing, href)
+			return tag
+		}
+		open := "<style>"
+		if media := attrs["media"]; media != "" {
+			open = fmt.Sprintf(`<style media="%s">`, html.EscapeString(media))
+		}
+		// A literal </style> inside the stylesheet would end the element early
+		css = strings.ReplaceAll(css, "</style", `<\/style`)
+		return open + "\n" + css + "\n</style>"
+	})
+	return doc, missing
+}
+
+// readStylesheet reads a stylesheet relative to the first directory that has it.
+func readStylesheet(href string, dirs []string) (string, bool) {
+	path := filepath.FromSlash(strings.SplitN(href, "?", 2)[0])
+	candidates := []string{path}
+	if !filepath.IsAbs(path) {
+		candidates = candidates[:0]
+		for _, dir := range dirs {
+			candidates = append(candidates, filepath.Join(dir, path))
+		}
+	}
+	for _, c := range candidates {
+		//nolint:gosec // G304: reading stylesheets referenced by the output is intended
+		if data, err := os.ReadFile(c); err == nil {
+			return string(data), true
+		}
+	}
+	return "", false
+}
+
+// isRemote reports whether a reference is a URL or a data URI rather than a local file.
+func isRemote(ref string) bool {
+	lower := strings.ToLower(ref)
+	return strings.Contains(lower, "://") || strings.HasPrefix(lower, "//") || strings.HasPrefix(lower, "data:")
+}
@@ -0,0 +1,83 @@
+package postprocess
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMinifyHTML(t *testing.T) {
+	doc := `<!DOCTYPE html>
+<html>
+  <head>
+    <!-- generated -->
+    <!--[if lt IE 9]><script src="shiv.js"></script><![endif]-->
+    <style>
+      /* base */
+      body { margin : 0 ;  color: #333; }
+    </style>
+  </head>
+  <body>
+    <p>Hello   <em>big</em>
+       world</p>
+    <pre>  keep
+    this  </pre>
+    <script>var a  =  1;</script>
+  </body>
+</html>
+`
+	got := MinifyHTML(doc)
+	for _, want := range []string{
+		"<!DOCTYPE html><html><head>",
+		"<!--[if lt IE 9]>",
+		"<style>body{margin:0;color:#333}</style>",
+		"<p>Hello <em>big</em> world</p>",
+		"<pre>  keep\n    this  </pre>",
+		"<script>var a  =  1;</script>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("minified output lacks %q:\n%s", want, got)
+		}
+	}
+	if got := MinifyHTML("<p><em>a</em>\n  <strong>b</strong></p>"); got != "<p><em>a</em> <strong>b</strong></p>" {
+		t.Errorf("space between inline elements should be kept, got %q", got)
+	}
+	if strings.Contains(got, "generated") {
+		t.Errorf("comments should be removed:\n%s", got)
+	}
+}
+
+func TestMinifyCSS(t *testing.T) {
+	if got := MinifyCSS("a , b {\n  color : red ;\n}\n/* x */"); got != "a,b{color:red}" {
+		t.Errorf("MinifyCSS = %q", got)
+	}
+}
+
+func TestInlineCSS(t *testing.T) {
+	out := t.TempDir()
+	src := t.TempDir()
+	_ = os.MkdirAll(filepath.Join(out, "css"), 0750)
+	_ = os.WriteFile(filepath.Join(out, "css", "site.css"), []byte("body{color:red}"), 0644)
+	_ = os.WriteFile(filepath.Join(src, "print.css"), []byte("p{margin:0}"), 0644)
+
+	doc := `<link rel="stylesheet" href="css/site.css" />
+<link rel="stylesheet" href="print.css" media="print">
+<link rel="stylesheet" href="https://cdn.example.com/x.css">
+<link rel="icon" href="favicon.ico">
+<link rel="stylesheet" href="missing.css">`
+	got, missing := InlineCSS(doc, out, src)
+
+	if !strings.Contains(got, "<style>\nbody{color:red}\n</style>") {
+		t.Errorf("site.css not inlined:\n%s", got)
+	}
+	if !strings.Contains(got, "<style media=\"print\">\np{margin:0}\n</style>") {
+		t.Errorf("print.css not inlined from the second directory:\n%s", got)
+	}
+	if !strings.Contains(got, `href="https://cdn.example.com/x.css"`) || !strings.Contains(got, `rel="icon"`) {
+		t.Errorf("remote stylesheets and other links should be kept:\n%s", got)
+	}
+	if len(missing) != 1 || missing[0] != "missing.css" {
+		t.Errorf("missing = %v", missing)
+	}
+}
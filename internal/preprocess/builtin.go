@@ -0,0 +1,63 @@
+package preprocess
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+// Builtin is a built-in preprocessing step. It receives the document content and the
+// path of the original file (for resolving relative references) and returns the new content.
+type Builtin func(content, path string) (string, error)
+
+var (
+	envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+	htmlComment  = regexp.MustCompile(`(?s)<!--.*?-->\n?`)
+)
+
+var builtins = map[string]Builtin{
+	// envsubst replaces ${NAME} with the environment variable NAME (empty if unset).
+	// Bare $NAME is left alone so prices and TeX math survive.
+	"envsubst": func(content, _ string) (string, error) {
+		return envReference.ReplaceAllStringFunc(content, func(m string) string {
+			return os.Getenv(envReference.FindStringSubmatch(m)[1])
+		}), nil
+	},
+	// include expands {{include: file}} and !include directives.
+	"include": func(content, path string) (string, error) {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve %s: %w", path, err)
+		}
+		return expandContent(content, abs, []string{abs})
+	},
+	// strip-comments removes HTML comments.
+	"strip-comments": func(content, _ string) (string, error) {
+		return htmlComment.ReplaceAllString(content, ""), nil
+	},
+}
+
+// LookupBuiltin returns the built-in preprocessing step with the given name.
+//
+// Parameters:
+//   - `name`: the step name (e.g. "envsubst")
+//
+// Returns:
+//   - Builtin: the step
+//   - bool: false if there is no such built-in
+func LookupBuiltin(name string) (Builtin, bool) {
+	b, ok := builtins[name]
+	return b, ok
+}
+
+// BuiltinNames returns the names of all built-in preprocessing steps, sorted.
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,30 @@
+package preprocess
+
+import "testing"
+
+func TestBuiltins(t *testing.T) {
+	t.Setenv("PANFORGE_TEST_USER", "ann")
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"envsubst", "hi ${PANFORGE_TEST_USER}, ${PANFORGE_TEST_UNSET}$x$ and $5", "hi ann, $x$ and $5"},
+		{"strip-comments", "a <!-- note -->b\n<!--\nmulti\n-->\nc", "a b\nc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fn, ok := LookupBuiltin(tt.name)
+			if !ok {
+				t.Fatalf("builtin %s not found", tt.name)
+			}
+			got, err := fn(tt.input, "doc.md")
+			if err != nil || got != tt.want {
+				t.Errorf("%s() = %q, %v; want %q", tt.name, got, err, tt.want)
+			}
+		})
+	}
+	if _, ok := LookupBuiltin("missing"); ok {
+		t.Error("unexpected builtin")
+	}
+}
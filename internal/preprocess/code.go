@@ -0,0 +1,115 @@
+package preprocess
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// codePlaceholder matches the placeholders MaskCode puts in place of code.
+var codePlaceholder = regexp.MustCompile("\x00([0-9]+)\x00")
+
+// MaskCode replaces fenced code blocks and inline code spans with placeholders, so text
+// rewrites leave code alone. Placeholders contain no whitespace or newlines, and the
+// lines of a fenced block keep their line break after the placeholder.
+//
+// Parameters:
+//   - `content`: the Markdown source
+//
+// Returns:
+//   - string: the content with code replaced by placeholders
+//   - func(string) string: puts the code back into a (rewritten) masked content
+func MaskCode(content string) (string, func(string) string) {
+	var code []string
+	hold := func(s string) string {
+		code = append(code, s)
+		return "\x00" + strconv.Itoa(len(code)-1) + "\x00"
+	}
+
+	var sb, prose, block strings.Builder
+	flushProse := func() {
+		sb.WriteString(maskCodeSpans(prose.String(), hold))
+		prose.Reset()
+	}
+	flushBlock := func() {
+		text := block.String()
+		block.Reset()
+		if text == "" {
+			return
+		}
+		trimmed := strings.TrimSuffix(text, "\n")
+		sb.WriteString(hold(trimmed))
+		sb.WriteString(text[len(trimmed):])
+	}
+	forEachLine(content, func(line string, inFence bool) {
+		if inFence {
+			flushProse()
+			block.WriteString(line)
+			return
+		}
+		flushBlock()
+		prose.WriteString(line)
+	})
+	flushBlock()
+	flushProse()
+
+	restore := func(s string) string {
+		return codePlaceholder.ReplaceAllStringFunc(s, func(m string) string {
+			i, err := strconv.Atoi(m[1 : len(m)-1])
+			if err != nil || i >= len(code) {
+				return m
+			}
+			return code[i]
+		})
+	}
+	return sb.String(), restore
+}
+
+// maskCodeSpans replaces the inline code spans of Markdown text outside fenced blocks.
+// A span opens with a run of backticks and closes with a run of the same length;
+// an unmatched run is ordinary text.
+//
+// Parameters:
+//   - `text`: the Markdown text
+//   - `hold`: stores a span and returns its placeholder
+func maskCodeSpans(text string, hold func(string) string) string {
+	var sb strings.Builder
+	for i := 0; i < len(text); {
+		if text[i] != '`' || (i > 0 && text[i-1] == '\\') {
+			sb.WriteByte(text[i])
+			i++
+			continue
+		}
+		n := backtickRun(text, i)
+		end := -1
+		for j := i + n; j < len(text); {
+			if text[j] != '`' {
+				j++
+				continue
+			}
+			m := backtickRun(text, j)
+			if m == n {
+				end = j + m
+				break
+			}
+			j += m
+		}
+		if end < 0 {
+			sb.WriteString(text[i : i+n])
+			i += n
+			continue
+		}
+		sb.WriteString(hold(text[i:end]))
+		i = end
+	}
+	return sb.String()
+}
+
+// backtickRun returns the number of consecutive backticks at position i.
+func backtickRun(text string, i int) int {
+	n := 0
+	for i+n < len(text) && text[i+n] == '`' {
+		n++
+	}
+	return n
+}
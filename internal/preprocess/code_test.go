@@ -0,0 +1,26 @@
+package preprocess
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMaskCode(t *testing.T) {
+	content := "Use \"quotes\" and `a \"b\"` or ``x ` y``.\n\n```go\ns := \"x\"\n\n\n```\nAn \\`escaped tick and a lone ` one.\n"
+	masked, restore := MaskCode(content)
+	if strings.Count(masked, "\"") != 2 {
+		t.Errorf("code was not masked: %q", masked)
+	}
+	if !strings.Contains(masked, "An \\`escaped tick and a lone ` one.") {
+		t.Errorf("unmatched backticks should stay text: %q", masked)
+	}
+	if got := restore(masked); got != content {
+		t.Errorf("restore(MaskCode()) =\n%q\nwant\n%q", got, content)
+	}
+
+	rewritten := restore(strings.ReplaceAll(masked, "\"", "'"))
+	want := "Use 'quotes' and `a \"b\"` or ``x ` y``.\n\n```go\ns := \"x\"\n\n\n```\nAn \\`escaped tick and a lone ` one.\n"
+	if rewritten != want {
+		t.Errorf("rewrite touched code:\n%q\nwant\n%q", rewritten, want)
+	}
+}
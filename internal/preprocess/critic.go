@@ -0,0 +1,186 @@
+// Package preprocess implements source transformations applied to input documents before conversion.
+package preprocess
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Supported CriticMarkup policies for the `changes` config key.
+const (
+	// ChangesAccept applies all suggested changes.
+	ChangesAccept = "accept"
+	// ChangesReject discards all suggested changes.
+	ChangesReject = "reject"
+	// ChangesShow renders changes visibly (tracked changes in DOCX, colors in HTML/PDF).
+	ChangesShow = "show"
+)
+
+var (
+	criticHighlightComment = regexp.MustCompile(`(?s)\{==(.*?)==\}\s*\{>>(.*?)<<\}`)
+	criticSubstitution     = regexp.MustCompile(`(?s)\{~~(.*?)~>(.*?)~~\}`)
+	criticInsertion        = regexp.MustCompile(`(?s)\{\+\+(.*?)\+\+\}`)
+	criticDeletion         = regexp.MustCompile(`(?s)\{--(.*?)--\}`)
+	criticHighlight        = regexp.MustCompile(`(?s)\{==(.*?)==\}`)
+	criticComment          = regexp.MustCompile(`(?s)\{>>(.*?)<<\}`)
+)
+
+// HasCriticMarkup reports whether the content contains any CriticMarkup outside code.
+//
+// Parameters:
+//   - `content`: the Markdown source
+func HasCriticMarkup(content string) bool {
+	content, _ = MaskCode(content)
+	for _, re := range []*regexp.Regexp{criticSubstitution, criticInsertion, criticDeletion, criticHighlight, criticComment} {
+		if re.MatchString(content) {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyCriticMarkup rewrites CriticMarkup according to a policy. Markup inside fenced
+// code blocks and inline code spans is left as written.
+//
+// Parameters:
+//   - `content`: the Markdown source
+//   - `policy`: one of ChangesAccept, ChangesReject, or ChangesShow
+//   - `format`: the target pandoc format, used to pick the rendering for ChangesShow
+//   - `author`: the author attributed to tracked changes in DOCX output
+//
+// Returns:
+//   - string: the transformed Markdown
+//   - error: if the policy is unknown
+func ApplyCriticMarkup(content, policy, format, author string) (string, error) {
+	content, restore := MaskCode(content)
+	switch policy {
+	case ChangesAccept, ChangesReject:
+		accept := policy == ChangesAccept
+		content = criticHighlightComment.ReplaceAllString(content, "$1")
+		content = criticSubstitution.ReplaceAllStringFunc(content, func(m string) string {
+			parts := criticSubstitution.FindStringSubmatch(m)
+			if accept {
+				return parts[2]
+			}
+			return parts[1]
+		})
+		content = criticInsertion.ReplaceAllStringFunc(content, func(m string) string {
+			if accept {
+				return criticInsertion.FindStringSubmatch(m)[1]
+			}
+			return ""
+		})
+		content = criticDeletion.ReplaceAllStringFunc(content, func(m string) string {
+			if accept {
+				return ""
+			}
+			return criticDeletion.FindStringSubmatch(m)[1]
+		})
+		content = criticHighlight.ReplaceAllString(content, "$1")
+		content = criticComment.ReplaceAllString(content, "")
+		return restore(content), nil
+	case ChangesShow:
+		r := rendererFor(format, author)
+		content = criticHighlightComment.ReplaceAllStringFunc(content, func(m string) string {
+			parts := criticHighlightComment.FindStringSubmatch(m)
+			return r.comment(parts[1], parts[2])
+		})
+		content = criticSubstitution.ReplaceAllStringFunc(content, func(m string) string {
+			parts := criticSubstitution.FindStringSubmatch(m)
+			return r.deletion(parts[1]) + r.insertion(parts[2])
+		})
+		content = criticInsertion.ReplaceAllStringFunc(content, func(m string) string {
+			return r.insertion(criticInsertion.FindStringSubmatch(m)[1])
+		})
+		content = criticDeletion.ReplaceAllStringFunc(content, func(m string) string {
+			return r.deletion(criticDeletion.FindStringSubmatch(m)[1])
+		})
+		content = criticHighlight.ReplaceAllStringFunc(content, func(m string) string {
+			return r.highlight(criticHighlight.FindStringSubmatch(m)[1])
+		})
+		content = criticComment.ReplaceAllStringFunc(content, func(m string) string {
+			return r.comment("", criticComment.FindStringSubmatch(m)[1])
+		})
+		return restore(content), nil
+	default:
+		return "", fmt.Errorf("unknown changes policy %q (expected accept, reject, or show)", policy)
+	}
+}
+
+// changeRenderer produces Markdown that pandoc renders as visible changes in a given format.
+type changeRenderer struct {
+	insertion func(text string) string
+	deletion  func(text string) string
+	highlight func(text string) string
+	comment   func(text, note string) string
+}
+
+// rendererFor picks the change rendering for a target format.
+//
+// Parameters:
+//   - `format`: the target pandoc format
+//   - `author`: the author attributed to tracked changes
+func rendererFor(format, author string) changeRenderer {
+	if author == "" {
+		author = "Unknown"
+	}
+	switch format {
+	case "docx":
+		// pandoc's docx writer turns these spans into native tracked changes and comments
+		attrs := fmt.Sprintf(`author=%s`, strconv.Quote(author))
+		id := 0
+		return changeRenderer{
+			insertion: func(text string) string { return fmt.Sprintf("[%s]{.insertion %s}", text, attrs) },
+			deletion:  func(text string) string { return fmt.Sprintf("[%s]{.deletion %s}", text, attrs) },
+			highlight: func(text string) string { return text },
+			comment: func(text, note string) string {
+				id++
+				return fmt.Sprintf(`[%s]{.comment-start id="%d" %s}%s[]{.comment-end id="%d"}`, strings.TrimSpace(note), id, attrs, text, id)
+			},
+		}
+	case "html", "html4", "html5", "epub", "epub2", "epub3", "revealjs", "slidy":
+		return changeRenderer{
+			insertion: func(text string) string { return rawWrap("html", `<ins class="critic">`, text, `</ins>`) },
+			deletion:  func(text string) string { return rawWrap("html", `<del class="critic">`, text, `</del>`) },
+			highlight: func(text string) string { return rawWrap("html", `<mark class="critic">`, text, `</mark>`) },
+			comment: func(text, note string) string {
+				open := fmt.Sprintf(`<span class="critic comment" title="%s">`, strings.ReplaceAll(strings.TrimSpace(note), `"`, "&quot;"))
+				if text == "" {
+					return rawWrap("html", open, "💬", `</span>`)
+				}
+				return rawWrap("html", open, text, `</span>`)
+			},
+		}
+	case "latex", "pdf", "beamer":
+		return changeRenderer{
+			insertion: func(text string) string { return rawWrap("latex", `\textcolor{blue}{`, text, `}`) },
+			deletion:  func(text string) string { return rawWrap("latex", `\textcolor{red}{`, "~~"+text+"~~", `}`) },
+			highlight: func(text string) string { return rawWrap("latex", `\textcolor{orange}{`, text, `}`) },
+			comment: func(text, note string) string {
+				return text + "^[" + strings.TrimSpace(note) + "]"
+			},
+		}
+	default:
+		return changeRenderer{
+			insertion: func(text string) string { return "[" + text + "]{.underline}" },
+			deletion:  func(text string) string { return "~~" + text + "~~" },
+			highlight: func(text string) string { return "[" + text + "]{.mark}" },
+			comment: func(text, note string) string {
+				return text + "^[" + strings.TrimSpace(note) + "]"
+			},
+		}
+	}
+}
+
+// rawWrap surrounds Markdown text with raw inline markup for a pandoc writer.
+//
+// Parameters:
+//   - `writer`: the raw format name (e.g. "html", "latex")
+//   - `open`: the opening markup
+//   - `text`: the Markdown text to wrap
+//   - `closing`: the closing markup
+func rawWrap(writer, open, text, closing string) string {
+	return fmt.Sprintf("`%s`{=%s}%s`%s`{=%s}", open, writer, text, closing, writer)
+}
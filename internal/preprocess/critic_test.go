@@ -0,0 +1,65 @@
+package preprocess
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyCriticMarkup(t *testing.T) {
+	src := "A {++new++} {--old--} {~~red~>blue~~} {==key==}{>>check<<} end{>>note<<}."
+
+	tests := []struct {
+		name   string
+		policy string
+		format string
+		want   string
+	}{
+		{"accept", ChangesAccept, "html", "A new  blue key end."},
+		{"reject", ChangesReject, "html", "A  old red key end."},
+		{"show docx", ChangesShow, "docx", `A [new]{.insertion author="Jane"} [old]{.deletion author="Jane"} [red]{.deletion author="Jane"}[blue]{.insertion author="Jane"} [check]{.comment-start id="1" author="Jane"}key[]{.comment-end id="1"} end[note]{.comment-start id="2" author="Jane"}[]{.comment-end id="2"}.`},
+		{"show other", ChangesShow, "odt", "A [new]{.underline} ~~old~~ ~~red~~[blue]{.underline} key^[check] end^[note]."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyCriticMarkup(src, tt.policy, tt.format, "Jane")
+			if err != nil {
+				t.Fatalf("ApplyCriticMarkup failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ApplyCriticMarkup() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	got, _ := ApplyCriticMarkup("{++x++}", ChangesShow, "html", "")
+	if !strings.Contains(got, "`<ins class=\"critic\">`{=html}x`</ins>`{=html}") {
+		t.Errorf("html rendering = %q", got)
+	}
+
+	if _, err := ApplyCriticMarkup(src, "bogus", "html", ""); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+
+	// Markup in code is documentation, not a change
+	code := "Write `{++text++}` to insert {++this++}.\n\n```\na {--b--} {~~c~>d~~}\n```\n"
+	got, _ = ApplyCriticMarkup(code, ChangesAccept, "html", "")
+	if want := "Write `{++text++}` to insert this.\n\n```\na {--b--} {~~c~>d~~}\n```\n"; got != want {
+		t.Errorf("ApplyCriticMarkup() = %q, want %q", got, want)
+	}
+	got, _ = ApplyCriticMarkup("{++`{--x--}` stays++}", ChangesReject, "html", "")
+	if got != "" {
+		t.Errorf("rejecting an insertion that holds code = %q", got)
+	}
+}
+
+func TestHasCriticMarkup(t *testing.T) {
+	if HasCriticMarkup("plain {text}") {
+		t.Error("plain text reported as CriticMarkup")
+	}
+	if !HasCriticMarkup("with {--deletion--}") {
+		t.Error("deletion not detected")
+	}
+	if HasCriticMarkup("Write `{--x--}`:\n\n~~~\n{++y++}\n~~~\n") {
+		t.Error("markup in code reported as CriticMarkup")
+	}
+}
@@ -0,0 +1,127 @@
+package preprocess
+
+import (
+	"regexp"
+	"strings"
+)
+
+// DiagramBlock is a fenced code block holding diagram source, such as ```mermaid.
+type DiagramBlock struct {
+	// Code is the diagram source.
+	Code string
+	// Caption is the block's `caption` attribute, used as the image's alt text.
+	Caption string
+	// Attrs are the remaining attributes (e.g. `#fig-flow width=50%`), copied to the image.
+	Attrs string
+}
+
+var (
+	fenceOpen      = regexp.MustCompile("^\\s*(`{3,}|~{3,})\\s*(.*?)\\s*$")
+	captionAttr    = regexp.MustCompile(`caption\s*=\s*"([^"]*)"`)
+	whitespaceRuns = regexp.MustCompile(`\s+`)
+)
+
+// HasDiagramBlocks reports whether the content contains a fenced block of the given language.
+//
+// Parameters:
+//   - `content`: the Markdown source
+//   - `lang`: the block language (e.g. "mermaid")
+func HasDiagramBlocks(content, lang string) bool {
+	found := false
+	_, _ = ReplaceDiagramBlocks(content, lang, func(b DiagramBlock) (string, error) {
+		found = true
+		return "", nil
+	})
+	return found
+}
+
+// ReplaceDiagramBlocks replaces every fenced code block of the given language with the
+// Markdown returned by replace. Both ```mermaid and ```{.mermaid caption="..."} forms
+// are recognized.
+//
+// Parameters:
+//   - `content`: the Markdown source
+//   - `lang`: the block language (e.g. "mermaid")
+//   - `replace`: renders a block and returns its replacement
+//
+// Returns:
+//   - string: the rewritten content
+//   - error: the first error returned by replace
+func ReplaceDiagramBlocks(content, lang string, replace func(DiagramBlock) (string, error)) (string, error) {
+	lines := strings.SplitAfter(content, "\n")
+	var sb strings.Builder
+	for i := 0; i < len(lines); i++ {
+		m := fenceOpen.FindStringSubmatch(strings.TrimRight(lines[i], "\r\n"))
+		if m == nil {
+			sb.WriteString(lines[i])
+			continue
+		}
+		fence := m[1]
+		block, ok := parseDiagramInfo(m[2], lang)
+
+		// Find the closing fence: same character, at least as long
+		end := -1
+		for j := i + 1; j < len(lines); j++ {
+			t := strings.TrimSpace(lines[j])
+			if strings.HasPrefix(t, fence) && strings.Trim(t, fence[:1]) == "" {
+				end = j
+				break
+			}
+		}
+		if end < 0 {
+			// Unterminated fence: leave the rest untouched
+			for ; i < len(lines); i++ {
+				sb.WriteString(lines[i])
+			}
+			break
+		}
+		if !ok {
+			for ; i <= end; i++ {
+				sb.WriteString(lines[i])
+			}
+			i = end
+			continue
+		}
+
+		block.Code = strings.Join(lines[i+1:end], "")
+		out, err := replace(block)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(out)
+		if !strings.HasSuffix(out, "\n") {
+			sb.WriteString("\n")
+		}
+		i = end
+	}
+	return sb.String(), nil
+}
+
+// parseDiagramInfo checks a fence info string for the language and extracts attributes.
+//
+// Parameters:
+//   - `info`: the text after the opening fence
+//   - `lang`: the wanted language
+func parseDiagramInfo(info, lang string) (DiagramBlock, bool) {
+	var block DiagramBlock
+	if !strings.HasPrefix(info, "{") {
+		fields := strings.Fields(info)
+		return block, len(fields) > 0 && fields[0] == lang
+	}
+	attrs := strings.TrimSuffix(strings.TrimPrefix(info, "{"), "}")
+	if c := captionAttr.FindStringSubmatch(attrs); c != nil {
+		block.Caption = c[1]
+		attrs = strings.Replace(attrs, c[0], "", 1)
+	}
+	found := false
+	var rest []string
+	for _, f := range strings.Fields(attrs) {
+		if f == "."+lang {
+			found = true
+			continue
+		}
+		rest = append(rest, f)
+	}
+	block.Attrs = whitespaceRuns.ReplaceAllString(strings.Join(rest, " "), " ")
+	return block, found
+}
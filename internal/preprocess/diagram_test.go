@@ -0,0 +1,29 @@
+package preprocess
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestReplaceDiagramBlocks(t *testing.T) {
+	content := "Intro\n\n```mermaid\ngraph TD\n  A-->B\n```\n\n~~~~ {.mermaid caption=\"Flow chart\" #fig-flow width=50%}\nsequenceDiagram\n~~~~\n\n```python\nprint(1)\n```\n"
+	var blocks []DiagramBlock
+	got, err := ReplaceDiagramBlocks(content, "mermaid", func(b DiagramBlock) (string, error) {
+		blocks = append(blocks, b)
+		return fmt.Sprintf("![%s](d%d.svg)", b.Caption, len(blocks)), nil
+	})
+	if err != nil {
+		t.Fatalf("ReplaceDiagramBlocks failed: %v", err)
+	}
+	want := "Intro\n\n![](d1.svg)\n\n![Flow chart](d2.svg)\n\n```python\nprint(1)\n```\n"
+	if got != want {
+		t.Errorf("got\n%q\nwant\n%q", got, want)
+	}
+	if len(blocks) != 2 || blocks[0].Code != "graph TD\n  A-->B\n" || blocks[1].Attrs != "#fig-flow width=50%" {
+		t.Errorf("unexpected blocks: %+v", blocks)
+	}
+
+	if !HasDiagramBlocks(content, "mermaid") || HasDiagramBlocks(content, "plantuml") {
+		t.Error("HasDiagramBlocks mismatch")
+	}
+}
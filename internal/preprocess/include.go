@@ -0,0 +1,150 @@
+package preprocess
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/rapjul/panforge/internal/config"
+)
+
+// includeDirective matches a line that consists of `{{include: path}}` or `!include path`.
+var includeDirective = regexp.MustCompile(`^\s*(?:\{\{\s*include:\s*(.+?)\s*\}\}|!include\s+(.+?))\s*$`)
+
+// ExpandIncludes reads a Markdown file and replaces include directives with the contents
+// of the referenced files, recursively. Paths are resolved relative to the including file,
+// YAML headers of included files are dropped, and directives inside fenced code blocks
+// are left alone.
+//
+// Parameters:
+//   - `path`: the Markdown file to read
+//
+// Returns:
+//   - string: the expanded content
+//   - error: if a file cannot be read or the includes form a cycle
+func ExpandIncludes(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	return expandIncludes(abs, nil)
+}
+
+// HasIncludes reports whether the content contains an include directive outside code blocks.
+//
+// Parameters:
+//   - `content`: the Markdown source
+func HasIncludes(content string) bool {
+	found := false
+	forEachLine(content, func(line string, inFence bool) {
+		if !inFence && includeDirective.MatchString(line) {
+			found = true
+		}
+	})
+	return found
+}
+
+// expandIncludes expands one file, tracking the chain of including files to detect cycles.
+//
+// Parameters:
+//   - `path`: the absolute path of the file
+//   - `stack`: the files currently being expanded, outermost first
+func expandIncludes(path string, stack []string) (string, error) {
+	for i, p := range stack {
+		if p == path {
+			chain := append(append([]string(nil), stack[i:]...), path)
+			for j := range chain {
+				chain[j] = filepath.Base(chain[j])
+			}
+			return "", fmt.Errorf("include cycle: %s", strings.Join(chain, " -> "))
+		}
+	}
+	//nolint:gosec // G304: included files are named by the document itself
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if len(stack) > 0 {
+			return "", fmt.Errorf("failed to include %s from %s: %w", path, stack[len(stack)-1], err)
+		}
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	content := string(data)
+	if len(stack) > 0 {
+		_, content = config.SplitFrontmatter(content)
+	}
+	return expandContent(content, path, append(stack, path))
+}
+
+// expandContent replaces the include directives in content read from path.
+//
+// Parameters:
+//   - `content`: the Markdown text
+//   - `path`: the absolute path the content belongs to, used to resolve relative includes
+//   - `stack`: the files currently being expanded, including path
+func expandContent(content, path string, stack []string) (string, error) {
+
+	var sb strings.Builder
+	var expandErr error
+	forEachLine(content, func(line string, inFence bool) {
+		if expandErr != nil {
+			return
+		}
+		m := includeDirective.FindStringSubmatch(strings.TrimRight(line, "\r\n"))
+		if inFence || m == nil {
+			sb.WriteString(line)
+			return
+		}
+		target := m[1]
+		if target == "" {
+			target = m[2]
+		}
+		target = strings.Trim(target, `"'`)
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		included, err := expandIncludes(target, stack)
+		if err != nil {
+			expandErr = err
+			return
+		}
+		sb.WriteString(strings.TrimRight(included, "\n"))
+		sb.WriteString("\n")
+	})
+	if expandErr != nil {
+		return "", expandErr
+	}
+	return sb.String(), nil
+}
+
+// forEachLine calls fn for each line (including its newline), reporting whether the line
+// is inside a fenced code block. Fence delimiter lines count as inside.
+//
+// Parameters:
+//   - `content`: the Markdown source
+//   - `fn`: called for every line
+func forEachLine(content string, fn func(line string, inFence bool)) {
+	var fence string
+	for _, line := range strings.SplitAfter(content, "\n") {
+		if line == "" {
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		marker := ""
+		if strings.HasPrefix(trimmed, "```") {
+			marker = "```"
+		} else if strings.HasPrefix(trimmed, "~~~") {
+			marker = "~~~"
+		}
+		switch {
+		case fence == "" && marker != "":
+			fence = marker
+			fn(line, true)
+		case fence != "" && marker == fence:
+			fence = ""
+			fn(line, true)
+		default:
+			fn(line, fence != "")
+		}
+	}
+}
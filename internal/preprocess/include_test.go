@@ -0,0 +1,53 @@
+package preprocess
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandIncludes(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.MkdirAll(filepath.Join(dir, "parts"), 0750)
+	files := map[string]string{
+		"main.md":        "---\ntitle: Main\n---\n# Main\n\n{{include: parts/a.md}}\n\n```\n{{include: parts/a.md}}\n```\n",
+		"parts/a.md":     "---\ntitle: ignored\n---\nPart A\n!include b.md\n",
+		"parts/b.md":     "Part B\n",
+		"cycle/one.md":   "{{include: two.md}}\n",
+		"cycle/two.md":   "!include one.md\n",
+		"broken/main.md": "{{ include: nope.md }}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		_ = os.MkdirAll(filepath.Dir(path), 0750)
+		_ = os.WriteFile(path, []byte(content), 0600)
+	}
+
+	got, err := ExpandIncludes(filepath.Join(dir, "main.md"))
+	if err != nil {
+		t.Fatalf("ExpandIncludes failed: %v", err)
+	}
+	want := "---\ntitle: Main\n---\n# Main\n\nPart A\nPart B\n\n```\n{{include: parts/a.md}}\n```\n"
+	if got != want {
+		t.Errorf("ExpandIncludes() =\n%q\nwant\n%q", got, want)
+	}
+
+	_, err = ExpandIncludes(filepath.Join(dir, "cycle", "one.md"))
+	if err == nil || !strings.Contains(err.Error(), "one.md -> two.md -> one.md") {
+		t.Errorf("expected a cycle error, got %v", err)
+	}
+
+	if _, err := ExpandIncludes(filepath.Join(dir, "broken", "main.md")); err == nil {
+		t.Error("expected an error for a missing include")
+	}
+}
+
+func TestHasIncludes(t *testing.T) {
+	if !HasIncludes("text\n!include x.md\n") {
+		t.Error("expected directive to be found")
+	}
+	if HasIncludes("~~~\n!include x.md\n~~~\n") {
+		t.Error("directives in code blocks should be ignored")
+	}
+}
@@ -0,0 +1,73 @@
+package preprocess
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Numbering counts the numbered elements of a document, used to continue numbering
+// across documents that are converted separately.
+type Numbering struct {
+	// Chapters is the number of numbered top-level (`# `) headings.
+	Chapters int
+	// Figures is the number of captioned images standing alone in a paragraph.
+	Figures int
+	// Tables is the number of captioned tables.
+	Tables int
+}
+
+var (
+	// unnumberedAttr matches the attributes that exclude a heading from numbering.
+	unnumberedAttr = regexp.MustCompile(`\{(?:[^}]*\s)?(-|\.unnumbered)(\s|\})`)
+	// figureLine matches an image with a caption alone on its line, which pandoc makes a figure.
+	figureLine = regexp.MustCompile(`^\s*!\[[^\]]+\]\([^)]*\)(\{[^}]*\})?\s*$`)
+	// tableCaption matches a pandoc table caption line.
+	tableCaption = regexp.MustCompile(`^(Table)?:\s+\S`)
+)
+
+// CountNumbering counts the numbered chapters, figures, and tables of a Markdown
+// document, ignoring its YAML header and fenced code blocks. Captions written as
+// `: caption` only count next to a table row.
+//
+// Parameters:
+//   - `content`: the Markdown source
+func CountNumbering(content string) Numbering {
+	_, body := splitHeader(content)
+	var n Numbering
+	var lines []string
+	forEachLine(body, func(line string, inFence bool) {
+		if inFence {
+			lines = append(lines, "")
+			return
+		}
+		lines = append(lines, strings.TrimRight(line, "\r\n"))
+	})
+
+	isTableRow := func(i int) bool {
+		if i < 0 || i >= len(lines) {
+			return false
+		}
+		t := strings.TrimSpace(lines[i])
+		return strings.HasPrefix(t, "|") || strings.HasPrefix(t, "+-") || strings.HasPrefix(t, "--")
+	}
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "# "):
+			if !unnumberedAttr.MatchString(line) {
+				n.Chapters++
+			}
+		case figureLine.MatchString(line):
+			n.Figures++
+		case tableCaption.MatchString(line):
+			if strings.HasPrefix(line, "Table:") || isTableRow(i-1) || isTableRow(i-2) || isTableRow(i+1) || isTableRow(i+2) {
+				n.Tables++
+			}
+		}
+	}
+	return n
+}
+
+// Add returns the sum of two counts.
+func (n Numbering) Add(o Numbering) Numbering {
+	return Numbering{Chapters: n.Chapters + o.Chapters, Figures: n.Figures + o.Figures, Tables: n.Tables + o.Tables}
+}
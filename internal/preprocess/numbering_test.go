@@ -0,0 +1,42 @@
+package preprocess
+
+import "testing"
+
+func TestCountNumbering(t *testing.T) {
+	content := `---
+title: "# Not a heading"
+---
+# Intro
+
+![A figure](a.png)
+
+Inline ![icon](i.png) images and ![](b.png) without a caption are not figures.
+
+| a | b |
+|---|---|
+| 1 | 2 |
+
+: Results
+
+# Appendix {.unnumbered}
+
+# Notes {#notes -}
+
+` + "```" + `
+# code comment
+![Not a figure](c.png)
+` + "```" + `
+
+Table: Summary
+
+Term
+: a definition, not a caption
+
+# Methods
+`
+	got := CountNumbering(content)
+	want := Numbering{Chapters: 2, Figures: 1, Tables: 2}
+	if got != want {
+		t.Errorf("CountNumbering = %+v, want %+v", got, want)
+	}
+}
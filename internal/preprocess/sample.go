@@ -0,0 +1,67 @@
+package preprocess
+
+import (
+	"regexp"
+	"strings"
+)
+
+// atxHeading matches a Markdown ATX heading and captures its level markers.
+var atxHeading = regexp.MustCompile(`^(#{1,6})(\s|$)`)
+
+// TruncateSections keeps the YAML header, anything before the first heading, and
+// the first n sections at the document's top heading level. Headings inside
+// fenced code blocks are ignored.
+//
+// Parameters:
+//   - `content`: the Markdown source
+//   - `n`: the number of sections to keep
+//
+// Returns:
+//   - string: the shortened source (content itself if it has at most n sections)
+//   - int: the number of top-level sections in content
+func TruncateSections(content string, n int) (string, int) {
+	header, body := splitHeader(content)
+
+	top := 7
+	forEachLine(body, func(line string, inFence bool) {
+		if m := atxHeading.FindStringSubmatch(line); m != nil && !inFence && len(m[1]) < top {
+			top = len(m[1])
+		}
+	})
+	if top == 7 {
+		return content, 0
+	}
+
+	var sb strings.Builder
+	sections := 0
+	forEachLine(body, func(line string, inFence bool) {
+		if m := atxHeading.FindStringSubmatch(line); m != nil && !inFence && len(m[1]) == top {
+			sections++
+		}
+		if sections <= n {
+			sb.WriteString(line)
+		}
+	})
+	if sections <= n {
+		return content, sections
+	}
+	return header + sb.String(), sections
+}
+
+// splitHeader separates a leading YAML header (with its delimiters) from the body.
+func splitHeader(content string) (string, string) {
+	if !strings.HasPrefix(content, "---\n") && !strings.HasPrefix(content, "---\r\n") {
+		return "", content
+	}
+	offset := 0
+	for i, line := range strings.SplitAfter(content, "\n") {
+		offset += len(line)
+		if i == 0 {
+			continue
+		}
+		if t := strings.TrimRight(line, "\r\n"); t == "---" || t == "..." {
+			return content[:offset], content[offset:]
+		}
+	}
+	return "", content
+}
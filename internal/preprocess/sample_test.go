@@ -0,0 +1,22 @@
+package preprocess
+
+import "testing"
+
+func TestTruncateSections(t *testing.T) {
+	doc := "---\ntitle: Book\n# not a heading\n---\nIntro\n\n## One\n\n```sh\n## comment\n```\n\n### Sub\n\n## Two\n\ntext\n\n## Three\n"
+
+	got, total := TruncateSections(doc, 1)
+	want := "---\ntitle: Book\n# not a heading\n---\nIntro\n\n## One\n\n```sh\n## comment\n```\n\n### Sub\n\n"
+	if got != want || total != 3 {
+		t.Errorf("TruncateSections(1) = %q, %d\nwant %q, 3", got, total, want)
+	}
+
+	if got, total := TruncateSections(doc, 3); got != doc || total != 3 {
+		t.Errorf("keeping every section should return the input, got %q, %d", got, total)
+	}
+
+	plain := "Just text\n"
+	if got, total := TruncateSections(plain, 1); got != plain || total != 0 {
+		t.Errorf("document without headings = %q, %d", got, total)
+	}
+}
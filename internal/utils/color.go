@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Color modes accepted by --color.
+const (
+	// ColorAuto colors output written to a terminal, unless NO_COLOR is set.
+	ColorAuto = "auto"
+	// ColorAlways colors all output, even when piped or NO_COLOR is set.
+	ColorAlways = "always"
+	// ColorNever never colors output.
+	ColorNever = "never"
+)
+
+// ColorModes lists the modes accepted by --color.
+var ColorModes = []string{ColorAuto, ColorAlways, ColorNever}
+
+// ANSI styles used for terminal output.
+const (
+	Red    = "31"
+	Green  = "32"
+	Yellow = "33"
+	Dim    = "2"
+)
+
+// colorMode is the mode set by SetColorMode.
+var colorMode = ColorAuto
+
+// SetColorMode sets whether output is colored, for the rest of the process.
+//
+// Parameters:
+//   - `mode`: ColorAuto, ColorAlways, or ColorNever ("" for ColorAuto)
+//
+// Returns:
+//   - error: if the mode is not supported
+func SetColorMode(mode string) error {
+	switch strings.ToLower(mode) {
+	case "", ColorAuto:
+		colorMode = ColorAuto
+	case ColorAlways:
+		colorMode = ColorAlways
+	case ColorNever:
+		colorMode = ColorNever
+	default:
+		return fmt.Errorf("invalid --color %q (want %s)", mode, strings.Join(ColorModes, ", "))
+	}
+	return nil
+}
+
+// UseColor reports whether output written to f is colored: always with ColorAlways,
+// never with ColorNever, and otherwise if f is a terminal and neither NO_COLOR is set
+// nor TERM is dumb.
+//
+// Parameters:
+//   - `f`: the file the output goes to (e.g. os.Stdout)
+func UseColor(f *os.File) bool {
+	switch colorMode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return IsTerminal(f)
+}
+
+// Colorize wraps s in an ANSI style if output written to f is colored.
+//
+// Parameters:
+//   - `f`: the file s is written to
+//   - `style`: the style, e.g. Red
+//   - `s`: the text
+func Colorize(f *os.File, style, s string) string {
+	if !UseColor(f) {
+		return s
+	}
+	return "\x1b[" + style + "m" + s + "\x1b[0m"
+}
+
+// LevelColorWriter colors the lines of a slog text handler by level: errors red,
+// warnings yellow, and debug messages dimmed.
+type LevelColorWriter struct {
+	W io.Writer
+}
+
+// Write colors one log record.
+func (w LevelColorWriter) Write(p []byte) (int, error) {
+	style := ""
+	switch {
+	case bytes.Contains(p, []byte("level=ERROR")):
+		style = Red
+	case bytes.Contains(p, []byte("level=WARN")):
+		style = Yellow
+	case bytes.Contains(p, []byte("level=DEBUG")):
+		style = Dim
+	}
+	if style == "" {
+		return w.W.Write(p)
+	}
+	line := bytes.TrimSuffix(p, []byte("\n"))
+	if _, err := fmt.Fprintf(w.W, "\x1b[%sm%s\x1b[0m\n", style, line); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestUseColor(t *testing.T) {
+	defer func() { _ = SetColorMode(ColorAuto) }()
+	if err := SetColorMode("sometimes"); err == nil {
+		t.Error("expected an error for an unknown color mode")
+	}
+
+	t.Setenv("NO_COLOR", "")
+	_ = SetColorMode(ColorAuto)
+	if UseColor(os.Stdin) && !IsTerminal(os.Stdin) {
+		t.Error("auto must not color output that is not a terminal")
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	_ = SetColorMode(ColorAlways)
+	if got := Colorize(os.Stdout, Red, "Error:"); got != "\x1b[31mError:\x1b[0m" {
+		t.Errorf("always must color despite NO_COLOR, got %q", got)
+	}
+	_ = SetColorMode(ColorNever)
+	if got := Colorize(os.Stdout, Red, "Error:"); got != "Error:" {
+		t.Errorf("never must not color, got %q", got)
+	}
+}
+
+func TestLevelColorWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := LevelColorWriter{W: &buf}
+	_, _ = w.Write([]byte("time=x level=WARN msg=careful\n"))
+	_, _ = w.Write([]byte("time=x level=INFO msg=fine\n"))
+	want := "\x1b[33mtime=x level=WARN msg=careful\x1b[0m\ntime=x level=INFO msg=fine\n"
+	if buf.String() != want {
+		t.Errorf("LevelColorWriter wrote %q, want %q", buf.String(), want)
+	}
+}
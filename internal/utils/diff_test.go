@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	if got := UnifiedDiff("a", "b", "same\n", "same\n", 3); got != "" {
+		t.Errorf("identical texts produced a diff: %q", got)
+	}
+
+	from := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\n"
+	to := "one\ntwo\nTHREE\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	want := `--- a
++++ b
+@@ -2,3 +2,3 @@
+ two
+-three
++THREE
+ four
+@@ -9,1 +9,2 @@
+ nine
++ten
+`
+	if got := UnifiedDiff("a", "b", from, to, 1); got != want {
+		t.Errorf("UnifiedDiff() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	// apply replays an edit script, returning both sides
+	apply := func(ops []diffOp) (string, string) {
+		var from, to []string
+		for _, op := range ops {
+			if op.kind != '+' {
+				from = append(from, op.text)
+			}
+			if op.kind != '-' {
+				to = append(to, op.text)
+			}
+		}
+		return strings.Join(from, "\n"), strings.Join(to, "\n")
+	}
+
+	small := diffLines([]string{"a", "b", "c", "d"}, []string{"a", "x", "c", "d", "e"})
+	if from, to := apply(small); from != "a\nb\nc\nd" || to != "a\nx\nc\nd\ne" {
+		t.Errorf("edit script does not reproduce the inputs: %q, %q", from, to)
+	}
+	if len(small) != 6 {
+		t.Errorf("expected a shortest script of 6 ops, got %d: %v", len(small), small)
+	}
+
+	// Past maxDiffEdits, the differing middle is replaced and the shared ends kept
+	var a, b []string
+	for i := 0; i < 3*maxDiffEdits; i++ {
+		a = append(a, fmt.Sprintf("a%d", i))
+		b = append(b, fmt.Sprintf("b%d", i))
+	}
+	a = append([]string{"head"}, append(a, "tail")...)
+	b = append([]string{"head"}, append(b, "tail")...)
+	ops := diffLines(a, b)
+	if from, to := apply(ops); from != strings.Join(a, "\n") || to != strings.Join(b, "\n") {
+		t.Error("fallback edit script does not reproduce the inputs")
+	}
+	if ops[0] != (diffOp{' ', "head"}) || ops[len(ops)-1] != (diffOp{' ', "tail"}) {
+		t.Errorf("shared lines should stay unchanged: %v ... %v", ops[0], ops[len(ops)-1])
+	}
+}
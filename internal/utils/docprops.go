@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DocumentProperties holds the descriptive metadata stored inside an office document.
+type DocumentProperties struct {
+	Title       string
+	Author      string
+	Subject     string
+	Description string
+	Keywords    []string
+	Created     string
+}
+
+// propertyParts lists the archive members that carry document properties,
+// for DOCX (docProps/core.xml) and ODT (meta.xml) respectively.
+var propertyParts = []string{"docProps/core.xml", "meta.xml"}
+
+// ReadDocumentProperties extracts title, author, and related properties from a DOCX or ODT file.
+//
+// Parameters:
+//   - `path`: the path to the office document
+//
+// Returns:
+//   - *DocumentProperties: the properties found (empty fields if absent)
+//   - error: any error encountered opening or parsing the archive
+func ReadDocumentProperties(path string) (*DocumentProperties, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s as an office document: %w", path, err)
+	}
+	defer func() { _ = r.Close() }()
+
+	props := &DocumentProperties{}
+	for _, f := range r.File {
+		if !contains(propertyParts, f.Name) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+		}
+		err = parseProperties(rc, props)
+		_ = rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", f.Name, err)
+		}
+	}
+	return props, nil
+}
+
+// parseProperties scans a properties XML stream and fills in matching fields.
+// Elements are matched by local name so both the Dublin Core names used by DOCX
+// and the meta: names used by ODT are recognized.
+//
+// Parameters:
+//   - `r`: the XML stream
+//   - `props`: the properties to populate
+func parseProperties(r io.Reader, props *DocumentProperties) error {
+	dec := xml.NewDecoder(r)
+	var current string
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			current = t.Name.Local
+		case xml.EndElement:
+			current = ""
+		case xml.CharData:
+			value := strings.TrimSpace(string(t))
+			if value == "" {
+				continue
+			}
+			switch current {
+			case "title":
+				props.Title = value
+			case "creator", "initial-creator":
+				if props.Author == "" {
+					props.Author = value
+				}
+			case "subject":
+				props.Subject = value
+			case "description":
+				props.Description = value
+			case "keywords":
+				for _, k := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' }) {
+					if k = strings.TrimSpace(k); k != "" {
+						props.Keywords = append(props.Keywords, k)
+					}
+				}
+			case "keyword":
+				props.Keywords = append(props.Keywords, value)
+			case "created", "creation-date":
+				props.Created = value
+			}
+		}
+	}
+}
+
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
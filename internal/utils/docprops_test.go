@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadDocumentProperties(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "doc.docx")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	w, _ := zw.Create("docProps/core.xml")
+	_, _ = w.Write([]byte(`<?xml version="1.0"?>
+<cp:coreProperties xmlns:cp="cp" xmlns:dc="dc" xmlns:dcterms="dcterms">
+  <dc:title>Quarterly Report</dc:title>
+  <dc:creator>Jane Doe</dc:creator>
+  <cp:keywords>finance, q3</cp:keywords>
+  <dcterms:created>2024-01-31T10:00:00Z</dcterms:created>
+</cp:coreProperties>`))
+	_ = zw.Close()
+	_ = f.Close()
+
+	props, err := ReadDocumentProperties(path)
+	if err != nil {
+		t.Fatalf("ReadDocumentProperties failed: %v", err)
+	}
+	if props.Title != "Quarterly Report" || props.Author != "Jane Doe" {
+		t.Errorf("unexpected properties: %+v", props)
+	}
+	if len(props.Keywords) != 2 || props.Keywords[1] != "q3" {
+		t.Errorf("Keywords = %v", props.Keywords)
+	}
+	if props.Created != "2024-01-31T10:00:00Z" {
+		t.Errorf("Created = %q", props.Created)
+	}
+}
@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// runGit runs a git command in dir and returns its trimmed standard output.
+//
+// Parameters:
+//   - `dir`: the working directory
+//   - `args`: the git arguments
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", strings.Join(args, " "), msg)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// GitRoot returns the top-level directory of the git repository containing dir.
+//
+// Parameters:
+//   - `dir`: a directory inside the repository
+func GitRoot(dir string) (string, error) {
+	return runGit(dir, "rev-parse", "--show-toplevel")
+}
+
+// GitChangedFiles lists files that differ from a git ref, including uncommitted
+// and untracked (but not ignored) files.
+//
+// Parameters:
+//   - `dir`: a directory inside the repository
+//   - `ref`: the ref to compare against (e.g. "HEAD", "origin/main")
+//
+// Returns:
+//   - []string: absolute paths of changed files that still exist or were added
+//   - error: if dir is not in a git repository or the ref is unknown
+func GitChangedFiles(dir, ref string) ([]string, error) {
+	root, err := GitRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	changed, err := runGit(root, "diff", "--name-only", "--diff-filter=ACMR", ref, "--")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := runGit(root, "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(changed+"\n"+untracked, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		path := filepath.Join(root, filepath.FromSlash(line))
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+// GitFetch checks out a single ref of a repository into an empty directory without history.
+//
+// Parameters:
+//   - `dir`: the directory to check out into (created by the caller)
+//   - `repo`: the repository URL or path
+//   - `ref`: the branch, tag, or commit to check out (e.g. "HEAD", "v1.2.0")
+//
+// Returns:
+//   - string: the commit that was checked out
+//   - error: if the repository or ref cannot be fetched
+func GitFetch(dir, repo, ref string) (string, error) {
+	if _, err := runGit(dir, "init", "-q"); err != nil {
+		return "", err
+	}
+	if _, err := runGit(dir, "fetch", "-q", "--depth", "1", repo, ref); err != nil {
+		return "", err
+	}
+	if _, err := runGit(dir, "checkout", "-q", "FETCH_HEAD"); err != nil {
+		return "", err
+	}
+	return runGit(dir, "rev-parse", "HEAD")
+}
+
+// GitInfo describes the commit a repository is checked out at.
+type GitInfo struct {
+	// Version is the nearest tag, or "" if no tag is reachable.
+	Version string
+	// Commit is the abbreviated commit hash.
+	Commit string
+	// Branch is the current branch, or "" if HEAD is detached.
+	Branch string
+}
+
+// GitDescribe returns the nearest tag, commit, and branch of the repository containing dir.
+//
+// Parameters:
+//   - `dir`: a directory inside the repository
+//
+// Returns:
+//   - GitInfo: the description
+//   - error: if dir is not in a git repository or it has no commits
+func GitDescribe(dir string) (GitInfo, error) {
+	commit, err := runGit(dir, "rev-parse", "--short", "HEAD")
+	if err != nil {
+		return GitInfo{}, err
+	}
+	info := GitInfo{Commit: commit}
+	if branch, err := runGit(dir, "rev-parse", "--abbrev-ref", "HEAD"); err == nil && branch != "HEAD" {
+		info.Branch = branch
+	}
+	if tag, err := runGit(dir, "describe", "--tags", "--abbrev=0"); err == nil {
+		info.Version = tag
+	}
+	return info, nil
+}
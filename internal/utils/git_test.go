@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitChangedFiles(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=t", "-c", "user.email=t@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	_ = os.WriteFile(filepath.Join(dir, "a.md"), []byte("a"), 0600)
+	_ = os.WriteFile(filepath.Join(dir, "b.md"), []byte("b"), 0600)
+	git("add", ".")
+	git("commit", "-q", "-m", "init")
+
+	_ = os.WriteFile(filepath.Join(dir, "b.md"), []byte("changed"), 0600)
+	_ = os.WriteFile(filepath.Join(dir, "c.md"), []byte("new"), 0600)
+
+	files, err := GitChangedFiles(dir, "HEAD")
+	if err != nil {
+		t.Fatalf("GitChangedFiles failed: %v", err)
+	}
+	root, _ := GitRoot(dir)
+	want := map[string]bool{filepath.Join(root, "b.md"): true, filepath.Join(root, "c.md"): true}
+	if len(files) != 2 || !want[files[0]] || !want[files[1]] {
+		t.Errorf("GitChangedFiles() = %v", files)
+	}
+
+	if _, err := GitChangedFiles(dir, "no-such-ref"); err == nil {
+		t.Error("expected error for unknown ref")
+	}
+}
+
+func TestGitDescribe(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=t", "-c", "user.email=t@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	if _, err := GitDescribe(dir); err == nil {
+		t.Error("expected an error outside a repository")
+	}
+	git("init", "-q", "-b", "main")
+	_ = os.WriteFile(filepath.Join(dir, "a.md"), []byte("a"), 0600)
+	git("add", ".")
+	git("commit", "-q", "-m", "init")
+	git("tag", "v1.2.0")
+	git("commit", "-q", "--allow-empty", "-m", "next")
+
+	info, err := GitDescribe(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != "v1.2.0" || info.Branch != "main" || len(info.Commit) < 7 {
+		t.Errorf("GitDescribe() = %+v", info)
+	}
+}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// notifyTimeout bounds how long a notification command may run, so a hung
+// notification daemon cannot keep panforge from exiting.
+var notifyTimeout = 5 * time.Second
+
+// Notify shows a native desktop notification.
+// It uses notify-send on Linux/BSD, osascript on macOS, and PowerShell on Windows.
+// The command is killed if it takes longer than a few seconds.
+//
+// Parameters:
+//   - `title`: the notification title
+//   - `message`: the notification body
+//
+// Returns:
+//   - error: if no notification mechanism is available or it failed
+func Notify(title, message string) error {
+	name, args := notifyCommand(runtime.GOOS, title, message)
+	if _, err := exec.LookPath(name); err != nil {
+		return fmt.Errorf("desktop notifications unavailable: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	//nolint:gosec // G204: command and arguments are built from fixed templates
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.WaitDelay = time.Second
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("desktop notification timed out after %s", notifyTimeout)
+		}
+		return err
+	}
+	return nil
+}
+
+// notifyCommand builds the OS-specific notification command.
+//
+// Parameters:
+//   - `osName`: the operating system name (e.g., "linux", "darwin")
+//   - `title`: the notification title
+//   - `message`: the notification body
+func notifyCommand(osName, title, message string) (string, []string) {
+	switch osName {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptQuote(message), appleScriptQuote(title))
+		return "osascript", []string{"-e", script}
+	case "windows":
+		script := fmt.Sprintf(`[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$t = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$x = $t.GetElementsByTagName('text')
+$x.Item(0).AppendChild($t.CreateTextNode('%s')) > $null
+$x.Item(1).AppendChild($t.CreateTextNode('%s')) > $null
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('panforge').Show([Windows.UI.Notifications.ToastNotification]::new($t))`,
+			strings.ReplaceAll(title, "'", "''"), strings.ReplaceAll(message, "'", "''"))
+		return "powershell", []string{"-NoProfile", "-Command", script}
+	default:
+		return "notify-send", []string{"--app-name=panforge", title, message}
+	}
+}
+
+// appleScriptQuote quotes a string for use as an AppleScript literal.
+//
+// Parameters:
+//   - `s`: the string to quote
+func appleScriptQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
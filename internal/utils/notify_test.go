@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotifyCommand(t *testing.T) {
+	name, args := notifyCommand("linux", "panforge", "done")
+	if name != "notify-send" || args[len(args)-1] != "done" {
+		t.Errorf("linux: got %s %v", name, args)
+	}
+
+	name, args = notifyCommand("darwin", "panforge", `say "hi"`)
+	if name != "osascript" || !strings.Contains(args[1], `"say \"hi\""`) {
+		t.Errorf("darwin: got %s %v", name, args)
+	}
+
+	name, _ = notifyCommand("windows", "panforge", "it's done")
+	if name != "powershell" {
+		t.Errorf("windows: got %s", name)
+	}
+}
+
+func TestNotifyTimeout(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("uses a notify-send script")
+	}
+	dir := t.TempDir()
+	//nolint:gosec // G306: the fake notify-send must be executable
+	if err := os.WriteFile(filepath.Join(dir, "notify-send"), []byte("#!/bin/sh\nexec sleep 10\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	orig := notifyTimeout
+	notifyTimeout = 100 * time.Millisecond
+	defer func() { notifyTimeout = orig }()
+
+	start := time.Now()
+	if err := Notify("panforge", "done"); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected a timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Notify blocked for %s", elapsed)
+	}
+}
@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Trash moves a file to the OS trash, so it can still be restored.
+// It uses Finder on macOS, the recycle bin via PowerShell on Windows, and `gio trash`
+// or the freedesktop.org trash directory on Linux/BSD.
+//
+// Parameters:
+//   - `path`: the file to move
+//
+// Returns:
+//   - error: if the file could not be moved to the trash
+func Trash(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	name, args := trashCommand(runtime.GOOS, abs)
+	if name == "" {
+		return trashFreedesktop(abs, time.Now())
+	}
+	if _, err := exec.LookPath(name); err != nil {
+		return fmt.Errorf("trash unavailable: %w", err)
+	}
+	//nolint:gosec // G204: command and arguments are built from fixed templates
+	if out, err := exec.Command(name, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to move %s to the trash: %w: %s", path, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// trashCommand builds the OS-specific trash command; "" means the freedesktop.org
+// trash directory is used directly.
+//
+// Parameters:
+//   - `osName`: the operating system name (e.g., "linux", "darwin")
+//   - `path`: the absolute path of the file
+func trashCommand(osName, path string) (string, []string) {
+	switch osName {
+	case "darwin":
+		script := fmt.Sprintf("tell application \"Finder\" to delete POSIX file %s", appleScriptQuote(path))
+		return "osascript", []string{"-e", script}
+	case "windows":
+		script := fmt.Sprintf("Add-Type -AssemblyName Microsoft.VisualBasic; [Microsoft.VisualBasic.FileIO.FileSystem]::DeleteFile('%s', 'OnlyErrorDialogs', 'SendToRecycleBin')",
+			strings.ReplaceAll(path, "'", "''"))
+		return "powershell", []string{"-NoProfile", "-Command", script}
+	default:
+		if _, err := exec.LookPath("gio"); err == nil {
+			return "gio", []string{"trash", path}
+		}
+		return "", nil
+	}
+}
+
+// trashFreedesktop moves a file into the home trash of the freedesktop.org trash
+// specification ($XDG_DATA_HOME/Trash), writing the .trashinfo record file managers
+// use to restore it.
+//
+// Parameters:
+//   - `path`: the absolute path of the file
+//   - `now`: the deletion time to record
+func trashFreedesktop(path string, now time.Time) error {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("trash unavailable: %w", err)
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	files := filepath.Join(dataHome, "Trash", "files")
+	infoDir := filepath.Join(dataHome, "Trash", "info")
+	for _, dir := range []string{files, infoDir} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return fmt.Errorf("failed to create trash directory: %w", err)
+		}
+	}
+
+	// Reserve a unique name through its .trashinfo file, as the specification requires
+	base := filepath.Base(path)
+	ext := filepath.Ext(base)
+	name := base
+	var info *os.File
+	for i := 2; ; i++ {
+		f, err := os.OpenFile(filepath.Join(infoDir, name+".trashinfo"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			info = f
+			break
+		}
+		if !os.IsExist(err) {
+			return fmt.Errorf("failed to move %s to the trash: %w", path, err)
+		}
+		name = strings.TrimSuffix(base, ext) + "." + strconv.Itoa(i) + ext
+	}
+	record := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: path}).EscapedPath(), now.Format("2006-01-02T15:04:05"))
+	_, werr := info.WriteString(record)
+	cerr := info.Close()
+	if werr == nil {
+		werr = cerr
+	}
+	if werr == nil {
+		werr = moveFile(path, filepath.Join(files, name))
+	}
+	if werr != nil {
+		_ = os.Remove(info.Name())
+		return fmt.Errorf("failed to move %s to the trash: %w", path, werr)
+	}
+	return nil
+}
+
+// moveFile renames a file, falling back to copy and remove when the destination is on
+// another file system.
+func moveFile(src, dest string) error {
+	if err := os.Rename(src, dest); err == nil {
+		return nil
+	}
+	//nolint:gosec // G304: moving the user's own output is intended
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(dest, data, 0600); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
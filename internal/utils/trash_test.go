@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrashCommand(t *testing.T) {
+	name, args := trashCommand("darwin", "/tmp/a.pdf")
+	if name != "osascript" || !strings.Contains(args[1], `POSIX file "/tmp/a.pdf"`) {
+		t.Errorf("darwin: got %s %v", name, args)
+	}
+	name, args = trashCommand("windows", `C:\it's.pdf`)
+	if name != "powershell" || !strings.Contains(args[2], `'C:\it''s.pdf'`) {
+		t.Errorf("windows: got %s %v", name, args)
+	}
+}
+
+func TestTrashFreedesktop(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", filepath.Join(dir, "data"))
+	now := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		file := filepath.Join(dir, "my doc.pdf")
+		_ = os.WriteFile(file, []byte("v"), 0600)
+		if err := trashFreedesktop(file, now); err != nil {
+			t.Fatalf("trashFreedesktop failed: %v", err)
+		}
+		if _, err := os.Stat(file); !os.IsNotExist(err) {
+			t.Errorf("expected the file to be moved")
+		}
+	}
+
+	trash := filepath.Join(dir, "data", "Trash")
+	for _, name := range []string{"my doc.pdf", "my doc.2.pdf"} {
+		if _, err := os.Stat(filepath.Join(trash, "files", name)); err != nil {
+			t.Errorf("missing trashed file %s: %v", name, err)
+		}
+	}
+	info, _ := os.ReadFile(filepath.Join(trash, "info", "my doc.pdf.trashinfo"))
+	if !strings.Contains(string(info), "Path="+filepath.ToSlash(filepath.Join(dir, "my%20doc.pdf"))) || !strings.Contains(string(info), "DeletionDate=2024-05-01T12:30:00") {
+		t.Errorf("unexpected trashinfo:\n%s", info)
+	}
+}
@@ -2,11 +2,14 @@ package utils
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
 	"strings"
 	"time"
+	"unicode"
 )
 
 var slugRegex = regexp.MustCompile("[^a-z0-9]+")
@@ -38,18 +41,57 @@ func ResolvePath(path string) (string, error) {
 // Returns:
 //   - string: the slugified string
 func Slugify(title string) string {
-	if title == "" {
-		return ""
-	}
+	return SlugifyWith(title, SlugOptions{})
+}
+
+// SlugOptions customize SlugifyWith; the zero value gives Slugify's lower-case,
+// dash-separated slugs.
+type SlugOptions struct {
+	// Separator replaces every run of other characters ("" for "-").
+	Separator string
+	// MaxLength cuts the slug to at most this many characters, at a separator where
+	// possible (0 for no limit).
+	MaxLength int
+	// KeepCase keeps upper-case letters instead of lowercasing them.
+	KeepCase bool
+}
 
-	s := strings.ToLower(strings.TrimSpace(title))
-	// replace non-alphanum with dashes
-	s = slugRegex.ReplaceAllString(s, "-")
-	// remove leading/trailing dashes
-	s = strings.Trim(s, "-")
+var slugKeepCaseRegex = regexp.MustCompile("[^A-Za-z0-9]+")
 
-	if s == "" {
-		return ""
+// SlugifyWith converts a title to a safe filename like Slugify, with a custom separator,
+// length limit, or case.
+//
+// Parameters:
+//   - `title`: the string to slugify
+//   - `opts`: the options
+//
+// Returns:
+//   - string: the slugified string
+func SlugifyWith(title string, opts SlugOptions) string {
+	sep := opts.Separator
+	if sep == "" {
+		sep = "-"
+	}
+	s := strings.TrimSpace(title)
+	re := slugKeepCaseRegex
+	if !opts.KeepCase {
+		s = strings.ToLower(s)
+		re = slugRegex
+	}
+	// replace non-alphanum with the separator
+	s = re.ReplaceAllString(s, sep)
+	// remove leading/trailing separators
+	s = strings.Trim(s, sep)
+
+	if opts.MaxLength > 0 && len(s) > opts.MaxLength {
+		cut := s[:opts.MaxLength]
+		// Cut at a word boundary unless that leaves nothing
+		if !strings.HasPrefix(s[opts.MaxLength:], sep) {
+			if i := strings.LastIndex(cut, sep); i > 0 {
+				cut = cut[:i]
+			}
+		}
+		s = strings.Trim(cut, sep)
 	}
 	return s
 }
@@ -66,6 +108,82 @@ func SanitizeFilename(name string) string {
 	return sanitize(name, runtime.GOOS)
 }
 
+// SanitizeOptions customize SanitizeFilenameWith.
+type SanitizeOptions struct {
+	// Replace maps text to its replacement (e.g. " " to "_"); longer keys are replaced first.
+	Replace map[string]string
+	// Forbidden lists extra characters that are replaced with underscores.
+	Forbidden string
+	// StripEmoji removes emoji and other pictographic symbols.
+	StripEmoji bool
+	// Portable applies the Windows rules on every system, so names work everywhere.
+	Portable bool
+}
+
+// SanitizeFilenameWith sanitizes a filename like SanitizeFilename, after removing emoji,
+// then applying the replacements, then replacing the forbidden characters.
+//
+// Parameters:
+//   - `name`: the filename to sanitize
+//   - `opts`: the extra rules
+//
+// Returns:
+//   - string: the sanitized filename
+func SanitizeFilenameWith(name string, opts SanitizeOptions) string {
+	if opts.StripEmoji {
+		name = strings.Map(func(r rune) rune {
+			if isEmoji(r) {
+				return -1
+			}
+			return r
+		}, name)
+		// Don't leave double spaces where emoji were
+		name = strings.Join(strings.Fields(name), " ")
+	}
+	if len(opts.Replace) > 0 {
+		keys := make([]string, 0, len(opts.Replace))
+		for k := range opts.Replace {
+			if k != "" {
+				keys = append(keys, k)
+			}
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if len(keys[i]) != len(keys[j]) {
+				return len(keys[i]) > len(keys[j])
+			}
+			return keys[i] < keys[j]
+		})
+		pairs := make([]string, 0, 2*len(keys))
+		for _, k := range keys {
+			pairs = append(pairs, k, opts.Replace[k])
+		}
+		name = strings.NewReplacer(pairs...).Replace(name)
+	}
+	if opts.Forbidden != "" {
+		name = strings.Map(func(r rune) rune {
+			if strings.ContainsRune(opts.Forbidden, r) {
+				return '_'
+			}
+			return r
+		}, name)
+	}
+	osName := runtime.GOOS
+	if opts.Portable {
+		osName = "windows"
+	}
+	return sanitize(name, osName)
+}
+
+// isEmoji reports whether r is an emoji or another pictographic symbol, or one of the
+// joiners and modifiers emoji sequences are built with.
+func isEmoji(r rune) bool {
+	switch {
+	case r == 0x200D, r == 0xFE0F, r >= 0x1F3FB && r <= 0x1F3FF:
+		return true
+	}
+	return unicode.Is(unicode.So, r)
+}
+
 // sanitize performs OS-specific sanitization.
 //
 // Parameters:
@@ -109,7 +227,70 @@ func sanitize(name, osName string) string {
 	return s
 }
 
+// Default layouts of the {date} and {time} filename variables.
+const (
+	DefaultDateFormat = "2006-01-02"
+	DefaultTimeFormat = "15-04-05"
+)
+
 // FormatDate returns the current date in YYYY-MM-DD format.
 func FormatDate() string {
-	return time.Now().Format("2006-01-02")
+	return FormatTime(time.Now(), DefaultDateFormat)
+}
+
+// strftimeLayouts maps strftime directives to Go layouts.
+var strftimeLayouts = map[byte]string{
+	'Y': "2006", 'y': "06", 'm': "01", 'd': "02", 'e': "_2", 'j': "002",
+	'b': "Jan", 'h': "Jan", 'B': "January", 'a': "Mon", 'A': "Monday",
+	'H': "15", 'I': "03", 'M': "04", 'S': "05", 'p': "PM",
+	'Z': "MST", 'z': "-0700", 'F': "2006-01-02", 'T': "15:04:05",
+}
+
+// FormatTime formats a time with a Go layout (e.g. "20060102") or, if the layout
+// contains a %, a strftime format (e.g. "%b-%Y"). Unknown strftime directives are
+// kept as written.
+//
+// Parameters:
+//   - `t`: the time
+//   - `layout`: the Go layout or strftime format
+//
+// Returns:
+//   - string: the formatted time
+func FormatTime(t time.Time, layout string) string {
+	if !strings.Contains(layout, "%") {
+		return t.Format(layout)
+	}
+	var b strings.Builder
+	for i := 0; i < len(layout); i++ {
+		if layout[i] != '%' || i+1 == len(layout) {
+			b.WriteByte(layout[i])
+			continue
+		}
+		i++
+		if layout[i] == '%' {
+			b.WriteByte('%')
+		} else if goLayout, ok := strftimeLayouts[layout[i]]; ok {
+			b.WriteString(t.Format(goLayout))
+		} else {
+			b.WriteByte('%')
+			b.WriteByte(layout[i])
+		}
+	}
+	return b.String()
+}
+
+// IsTerminal reports whether a file is an interactive terminal: a character device
+// other than the null device.
+//
+// Parameters:
+//   - `f`: the file to check (e.g. os.Stdin)
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	if null, err := os.Stat(os.DevNull); err == nil && os.SameFile(info, null) {
+		return false
+	}
+	return true
 }
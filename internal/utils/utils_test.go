@@ -1,9 +1,11 @@
 package utils
 
 import (
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestSlugify(t *testing.T) {
@@ -78,3 +80,91 @@ func TestSanitizeFilename(t *testing.T) {
 		})
 	}
 }
+
+func TestIsTerminal(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	if IsTerminal(f) {
+		t.Error("a regular file is not a terminal")
+	}
+
+	null, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Skip("no null device")
+	}
+	defer func() { _ = null.Close() }()
+	if IsTerminal(null) {
+		t.Error("the null device is not a terminal")
+	}
+}
+
+func TestFormatTime(t *testing.T) {
+	at := time.Date(2024, 1, 31, 9, 5, 0, 0, time.UTC)
+	tests := []struct {
+		layout string
+		want   string
+	}{
+		{DefaultDateFormat, "2024-01-31"},
+		{"20060102", "20240131"},
+		{"Jan-2006", "Jan-2024"},
+		{"%b-%Y", "Jan-2024"},
+		{"%Y%m%d_%H%M", "20240131_0905"},
+		{"100%% %q", "100% %q"},
+	}
+	for _, tt := range tests {
+		if got := FormatTime(at, tt.layout); got != tt.want {
+			t.Errorf("FormatTime(%q) = %q, want %q", tt.layout, got, tt.want)
+		}
+	}
+}
+
+func TestSlugifyWith(t *testing.T) {
+	tests := []struct {
+		name string
+		opts SlugOptions
+		want string
+	}{
+		{"default", SlugOptions{}, "the-quick-brown-fox"},
+		{"underscore", SlugOptions{Separator: "_"}, "the_quick_brown_fox"},
+		{"keep case", SlugOptions{KeepCase: true}, "The-Quick-Brown-Fox"},
+		{"max length at word", SlugOptions{MaxLength: 12}, "the-quick"},
+		{"max length at separator", SlugOptions{MaxLength: 9}, "the-quick"},
+		{"max length inside one word", SlugOptions{MaxLength: 2}, "th"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SlugifyWith("  The Quick Brown Fox! ", tt.opts); got != tt.want {
+				t.Errorf("SlugifyWith() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeFilenameWith(t *testing.T) {
+	tests := []struct {
+		name string
+		opts SanitizeOptions
+		want string
+	}{
+		{"no rules", SanitizeOptions{}, "Q&A: Launch 🚀 plan"},
+		{"replace", SanitizeOptions{Replace: map[string]string{" ": "_", "&": "and", ": ": "-"}}, "QandA-Launch_🚀_plan"},
+		{"strip emoji", SanitizeOptions{StripEmoji: true, Replace: map[string]string{" ": "_", ":": ""}}, "Q&A_Launch_plan"},
+		{"forbidden", SanitizeOptions{Forbidden: "&: "}, "Q_A__Launch_🚀_plan"},
+		{"portable", SanitizeOptions{Portable: true}, "Q&A_ Launch 🚀 plan"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := "Q&A: Launch 🚀 plan"
+			if tt.name == "no rules" {
+				// Without rules the result depends on the system
+				tt.want = SanitizeFilename(name)
+			}
+			if got := SanitizeFilenameWith(name, tt.opts); got != tt.want {
+				t.Errorf("SanitizeFilenameWith() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}